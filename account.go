@@ -0,0 +1,130 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// accountLabelsTTL is how long the accounts fetched from the API are reused.
+	accountLabelsTTL = time.Hour
+
+	// accountRetryInterval is the minimum interval between two requests for an unknown account.
+	accountRetryInterval = time.Minute
+)
+
+// AccountLabeler is implemented by the messaging services that label the accounts of the events,
+// such as "Production" for the account 1010.
+type AccountLabeler interface {
+	// AccountLabel returns the label of the account, false if not labeled.
+	AccountLabel(accountID int64) (string, bool)
+}
+
+// accountDisplay returns the label of the account: the label of the service, the account name
+// fetched from the API, empty if unknown, or the display name of the webhook.
+func accountDisplay(s Formatter, account *webhook.Account, name string) string {
+	if labeler, ok := s.(AccountLabeler); ok {
+		if label, ok := labeler.AccountLabel(account.ID); ok {
+			return label
+		}
+	}
+	if name != "" {
+		return name
+	}
+	return account.Display
+}
+
+// eventAccountDisplay returns the label of the account of the event, with the account name carried by its details.
+func eventAccountDisplay(s Formatter, e *webhook.Event) string {
+	return accountDisplay(s, e.Account, detailsOf(e).Account)
+}
+
+// parseAccountLabels returns the labels of the configuration, by account ID.
+func parseAccountLabels(labels map[string]string) (map[int64]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	parsed := make(map[int64]string, len(labels))
+	for id, label := range labels {
+		accountID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || accountID <= 0 {
+			return nil, fmt.Errorf("accounts: invalid account ID %q", id)
+		}
+		if label == "" {
+			return nil, fmt.Errorf("accounts: missing label for the account %s", id)
+		}
+		parsed[accountID] = label
+	}
+	return parsed, nil
+}
+
+// accountResolver fetches the names of the accounts of the events from the DNSimple API.
+type accountResolver struct {
+	client *dnsimple.Client
+
+	mu          sync.Mutex
+	names       map[int64]string
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+// newAccountResolver returns the resolver using the client, nil if the API is not configured.
+func newAccountResolver(client *dnsimple.Client) *accountResolver {
+	if client == nil {
+		return nil
+	}
+	return &accountResolver{client: client, names: map[int64]string{}}
+}
+
+// resolve returns the name of the account of the event, empty if unknown. It fetches the accounts accessible
+// with the token when the account is unknown, or the accounts are stale. The errors are logged,
+// and the messages keep the display name of the webhook.
+func (r *accountResolver) resolve(ctx context.Context, e *webhook.Event) string {
+	if r == nil || e.Account == nil {
+		return ""
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	name, known := r.names[e.Account.ID]
+	if known && now.Sub(r.fetchedAt) < accountLabelsTTL {
+		return name
+	}
+	if now.Sub(r.attemptedAt) < accountRetryInterval {
+		return name
+	}
+	r.attemptedAt = now
+
+	accounts, err := r.accounts(ctx)
+	if err != nil {
+		withEvent(log.Warn(), e).Err(err).Msg("Error fetching the accounts")
+		return name
+	}
+	for _, account := range accounts {
+		r.names[account.ID] = account.Email
+	}
+	r.fetchedAt = now
+	return r.names[e.Account.ID]
+}
+
+// accounts returns the accounts of the user token, or the account of the account token.
+func (r *accountResolver) accounts(ctx context.Context) ([]dnsimple.Account, error) {
+	response, err := r.client.Accounts.ListAccounts(ctx, nil)
+	if err == nil {
+		return response.Data, nil
+	}
+	// The account tokens can't list the accounts, but identify their account.
+	whoami, whoamiErr := r.client.Identity.Whoami(ctx)
+	if whoamiErr != nil || whoami.Data == nil || whoami.Data.Account == nil {
+		return nil, err
+	}
+	return []dnsimple.Account{*whoami.Data.Account}, nil
+}
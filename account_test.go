@@ -0,0 +1,87 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestAccountResolver_Resolve(t *testing.T) {
+	requests := 0
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/v2/accounts":
+			fmt.Fprint(w, `{"data": [{"id": 2020, "email": "ops@example.com"}, {"id": 2021, "email": "staging@example.com"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	client, err := newAPIClient(&APIConfig{Token: "api-token", URL: api.URL}, nil)
+	if err != nil {
+		t.Fatalf("newAPIClient returned error: %v", err)
+	}
+	resolver := newAccountResolver(client)
+
+	event, err := webhook.ParseEvent([]byte(`{"name": "domain.create", "data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 2020, "display": "Personal"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver.resolve(context.Background(), event)
+	name := resolver.resolve(context.Background(), event)
+	if want, got := 1, requests; want != got {
+		t.Errorf("resolve expected %d API request, got %d", want, got)
+	}
+	event = withDetails(event, &eventDetails{Account: name})
+
+	want := "[<ops@example.com|https://dnsimple.com/a/2020/account>] example@example.com created the domain <example.com|https://dnsimple.com/a/2020/domains/example.com>"
+	if got := Message(NewTestMessagingService("test"), event); want != got {
+		t.Errorf("Message expected %q, got %q", want, got)
+	}
+
+	slack := &SlackService{AccountLabels: map[int64]string{2020: "Production"}}
+	if want, got := "Production", eventAccountDisplay(slack, event); want != got {
+		t.Errorf("accountDisplay expected the configured label %q, got %q", want, got)
+	}
+}
+
+func TestAccountResolver_ResolveWhoami(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/accounts":
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "Authentication failed"}`)
+		case "/v2/whoami":
+			fmt.Fprint(w, `{"data": {"user": null, "account": {"id": 3030, "email": "team@example.com"}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	client, _ := newAPIClient(&APIConfig{Token: "api-token", URL: api.URL}, nil)
+	resolver := newAccountResolver(client)
+	event, _ := webhook.ParseEvent([]byte(`{"name": "domain.create", "data": {}, "account": {"id": 3030, "display": "Team"}}`))
+	if want, got := "team@example.com", resolver.resolve(context.Background(), event); want != got {
+		t.Errorf("resolve expected %q, got %q", want, got)
+	}
+}
+
+func Test_parseAccountLabels(t *testing.T) {
+	labels, err := parseAccountLabels(map[string]string{"1010": "Production"})
+	if err != nil || labels[1010] != "Production" {
+		t.Errorf("parseAccountLabels expected the label of 1010, got %v, %v", labels, err)
+	}
+	if _, err := parseAccountLabels(map[string]string{"production": "Production"}); err == nil {
+		t.Errorf("parseAccountLabels with an invalid account ID expected error")
+	}
+	if _, err := parseAccountLabels(map[string]string{"1010": ""}); err == nil {
+		t.Errorf("parseAccountLabels with an empty label expected error")
+	}
+}
@@ -0,0 +1,307 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultActivityReportSchedule reports the activity every Monday at 08:00.
+	defaultActivityReportSchedule = "0 8 * * 1"
+
+	// activityReportPeriod is the period reported, ending when the report is sent.
+	activityReportPeriod = 7 * 24 * time.Hour
+
+	// defaultActivityReportActors is the number of top actors reported.
+	defaultActivityReportActors = 5
+
+	// defaultActivityReportExpirations is the number of days of the upcoming expirations reported.
+	defaultActivityReportExpirations = 30
+
+	// maxActivityReportDomains is the number of domains listed in a report, the others are counted.
+	maxActivityReportDomains = 20
+)
+
+// ActivityReportConfig configures the weekly reports of the activity of the accounts: the events by type,
+// the top actors, the domains changed and the upcoming expirations. The events are read from the history.
+type ActivityReportConfig struct {
+	// Destinations are the names of the destinations receiving the reports.
+	Destinations []string `json:"destinations"`
+
+	// Accounts are the IDs of the accounts reported. Defaults to the accounts with events in the week.
+	Accounts []int64 `json:"accounts,omitempty"`
+
+	// Schedule is a cron expression for the reports, in the Timezone. Defaults to every Monday at 08:00.
+	Schedule string `json:"schedule,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+
+	// Actors is the number of top actors reported. Defaults to 5.
+	Actors int `json:"actors,omitempty"`
+
+	// ExpirationDays is the number of days of the upcoming expirations of the domains reported,
+	// checked with the DNSimple API when configured. Defaults to 30.
+	ExpirationDays int `json:"expiration_days,omitempty"`
+}
+
+func (c *ActivityReportConfig) validate(destinations map[string]bool) error {
+	if c == nil {
+		return nil
+	}
+	if len(c.Destinations) == 0 {
+		return fmt.Errorf("activity report: missing destinations")
+	}
+	for _, name := range c.Destinations {
+		if !destinations[name] {
+			return fmt.Errorf("activity report: unknown destination %q", name)
+		}
+	}
+	if c.Actors < 0 || c.ExpirationDays < 0 {
+		return fmt.Errorf("activity report: actors and expiration_days must be positive")
+	}
+	if _, err := parseCron(c.schedule()); err != nil {
+		return fmt.Errorf("activity report: %v", err)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("activity report: %v", err)
+	}
+	return nil
+}
+
+func (c *ActivityReportConfig) schedule() string {
+	if c.Schedule == "" {
+		return defaultActivityReportSchedule
+	}
+	return c.Schedule
+}
+
+func (c *ActivityReportConfig) actors() int {
+	if c.Actors == 0 {
+		return defaultActivityReportActors
+	}
+	return c.Actors
+}
+
+func (c *ActivityReportConfig) expirationDays() int {
+	if c.ExpirationDays == 0 {
+		return defaultActivityReportExpirations
+	}
+	return c.ExpirationDays
+}
+
+// ProcessActivityReports sends the activity reports when they are scheduled,
+// checking the schedule at every interval, until done is closed or the server is shut down.
+func (s *Server) ProcessActivityReports(interval time.Duration, done <-chan struct{}) {
+	s.processSchedule(interval, done, s.activityReportsDue, s.sendActivityReports)
+}
+
+// activityReportsDue returns true if the activity reports are scheduled at the minute.
+func (s *Server) activityReportsDue(minute time.Time) bool {
+	config := s.currentRouting().config.ActivityReport
+	return config != nil && scheduleDue(config.schedule(), config.Timezone, minute)
+}
+
+// activityCount is the number of events of a name or of an actor.
+type activityCount struct {
+	name  string
+	count int
+}
+
+// activityReport is the activity of an account in the period of a report.
+type activityReport struct {
+	accountID   int64
+	since       time.Time
+	events      int
+	names       []activityCount
+	actors      []activityCount
+	domains     []string
+	expirations []activityExpiration
+
+	// expirationDays is the number of days of the expirations, 0 if they weren't checked.
+	expirationDays int
+}
+
+// activityExpiration is an upcoming expiration of a domain.
+type activityExpiration struct {
+	domain    string
+	expiresAt time.Time
+}
+
+// sendActivityReports sends the reports of the activity of the accounts in the week before now.
+func (s *Server) sendActivityReports(ctx context.Context, now time.Time) {
+	routing := s.currentRouting()
+	config := routing.config.ActivityReport
+	if config == nil {
+		return
+	}
+	if s.history == nil {
+		log.Warn().Msg("Skipping the activity reports: the history is disabled")
+		return
+	}
+
+	since := now.Add(-activityReportPeriod)
+	events, err := s.history.Query(&HistoryFilter{Since: since, Until: now})
+	if err != nil {
+		log.Error().Err(err).Msg("Error querying the events of the activity reports")
+		return
+	}
+	reports := activityReports(routing.name, events, config, since)
+
+	for _, accountID := range config.Accounts {
+		if reports[accountID] == nil {
+			reports[accountID] = &activityReport{accountID: accountID, since: since}
+		}
+	}
+	accountIDs := config.Accounts
+	if len(accountIDs) == 0 {
+		for accountID := range reports {
+			accountIDs = append(accountIDs, accountID)
+		}
+		sort.Slice(accountIDs, func(i, j int) bool { return accountIDs[i] < accountIDs[j] })
+	}
+
+	for _, accountID := range accountIDs {
+		report := reports[accountID]
+		if routing.api != nil {
+			report.expirationDays = config.expirationDays()
+			if report.expirations, err = upcomingExpirations(ctx, routing, accountID, now, report.expirationDays); err != nil {
+				log.Error().Err(err).Int64("account_id", accountID).Msg("Error listing the domains of the activity report")
+				report.expirationDays = 0
+			}
+		}
+		routing.postScheduled(ctx, config.Destinations, func(s Formatter) string {
+			return activityReportMessage(s, report, now)
+		})
+	}
+}
+
+// activityReports returns the activity of the accounts of the tenant in the events, by account ID.
+func activityReports(tenant string, events []*HistoryEvent, config *ActivityReportConfig, since time.Time) map[int64]*activityReport {
+	type counts struct {
+		names, actors map[string]int
+		domains       map[string]bool
+	}
+	reports := map[int64]*activityReport{}
+	accounts := map[int64]*counts{}
+	for _, event := range events {
+		if event.Tenant != tenant || event.AccountID == 0 {
+			continue
+		}
+		report := reports[event.AccountID]
+		if report == nil {
+			report = &activityReport{accountID: event.AccountID, since: since}
+			reports[event.AccountID] = report
+			accounts[event.AccountID] = &counts{names: map[string]int{}, actors: map[string]int{}, domains: map[string]bool{}}
+		}
+		c := accounts[event.AccountID]
+		report.events++
+		c.names[event.Name]++
+		if actor := historyActor(event); actor != "" {
+			c.actors[actor]++
+		}
+		if event.Domain != "" {
+			c.domains[strings.ToLower(event.Domain)] = true
+		}
+	}
+
+	for accountID, report := range reports {
+		c := accounts[accountID]
+		report.names = sortedCounts(c.names, 0)
+		report.actors = sortedCounts(c.actors, config.actors())
+		for domain := range c.domains {
+			report.domains = append(report.domains, domain)
+		}
+		sort.Strings(report.domains)
+	}
+	return reports
+}
+
+// sortedCounts returns the counts, the largest first, at most max when positive.
+func sortedCounts(counts map[string]int, max int) []activityCount {
+	sorted := make([]activityCount, 0, len(counts))
+	for name, count := range counts {
+		sorted = append(sorted, activityCount{name: name, count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	if max > 0 && len(sorted) > max {
+		sorted = sorted[:max]
+	}
+	return sorted
+}
+
+// upcomingExpirations returns the domains of the account expiring within the days, the soonest first.
+func upcomingExpirations(ctx context.Context, routing *routingTable, accountID int64, now time.Time, days int) ([]activityExpiration, error) {
+	domains, err := listDomains(ctx, routing.api, accountID)
+	if err != nil {
+		return nil, err
+	}
+	var expirations []activityExpiration
+	for _, domain := range domains {
+		if domain.ExpiresAt == "" {
+			continue
+		}
+		expiresAt, ok := parseTimestamp(domain.ExpiresAt)
+		if !ok {
+			continue
+		}
+		if left := daysUntil(now, expiresAt); left >= 0 && left <= days {
+			expirations = append(expirations, activityExpiration{domain: domain.Name, expiresAt: expiresAt})
+		}
+	}
+	sort.Slice(expirations, func(i, j int) bool { return expirations[i].expiresAt.Before(expirations[j].expiresAt) })
+	return expirations, nil
+}
+
+// activityReportMessage returns the report of the activity of the account, one line per section.
+func activityReportMessage(s Formatter, report *activityReport, now time.Time) string {
+	account := scheduledAccountLink(s, report.accountID)
+	domainLink := func(domain string) string {
+		return s.FormatLink(domain, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s", report.accountID, domain))
+	}
+	list := func(items []string, total int) string {
+		if len(items) == 0 {
+			return translate(s, "none")
+		}
+		if total > len(items) {
+			items = append(items, tprintf(s, "%d more", total-len(items)))
+		}
+		return strings.Join(items, ", ")
+	}
+
+	var names, actors, domains, expirations []string
+	for _, c := range report.names {
+		names = append(names, fmt.Sprintf("%s (%d)", c.name, c.count))
+	}
+	for _, c := range report.actors {
+		actors = append(actors, fmt.Sprintf("%s (%d)", escape(s, c.name), c.count))
+	}
+	for i, domain := range report.domains {
+		if i == maxActivityReportDomains {
+			break
+		}
+		domains = append(domains, domainLink(domain))
+	}
+	for _, expiration := range report.expirations {
+		expirations = append(expirations, tprintf(s, "%s expires on %s", domainLink(expiration.domain), expiration.expiresAt.Format(certificateDateFormat)))
+	}
+
+	lines := []string{
+		fmt.Sprintf("[%s] %s", account, tprintf(s, "Weekly activity from %s to %s: %d events", report.since.Format(certificateDateFormat), now.Format(certificateDateFormat), report.events)),
+		tprintf(s, "Events: %s", list(names, len(names))),
+		tprintf(s, "Top actors: %s", list(actors, len(actors))),
+		tprintf(s, "Domains changed: %s", list(domains, len(report.domains))),
+	}
+	if report.expirationDays > 0 {
+		lines = append(lines, tprintf(s, "Expiring within %d days: %s", report.expirationDays, list(expirations, len(expirations))))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,120 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_SendActivityReports(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/1010/domains":
+			fmt.Fprint(w, `{"data": [
+				{"id": 1, "name": "example.com", "auto_renew": true, "expires_at": "2021-03-20T10:00:00Z"},
+				{"id": 2, "name": "example.org", "auto_renew": false, "expires_at": "2021-03-10T10:00:00Z"},
+				{"id": 3, "name": "example.net", "auto_renew": false, "expires_at": "2021-06-01T10:00:00Z"}
+			], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 3, "total_pages": 1}}`)
+		case "/v2/5050/domains":
+			fmt.Fprint(w, `{"data": [], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 0, "total_pages": 1}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"api": {"token": "api-token", "url": %q},
+		"activity_report": {"destinations": ["ops"], "actors": 2}
+	}`, api.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetHistory(history)
+	service := &failingService{}
+	server.routing.services["ops"] = service
+
+	now := time.Date(2021, 3, 8, 8, 0, 0, 0, time.UTC)
+	events := []struct {
+		tenant    string
+		name      string
+		accountID int64
+		domain    string
+		actor     string
+		age       time.Duration
+	}{
+		{"", "zone_record.create", 1010, "example.com", "jane@example.com", time.Hour},
+		{"", "zone_record.create", 1010, "Example.com", "jane@example.com", 2 * time.Hour},
+		{"", "zone_record.delete", 1010, "example.org", "terraform", 3 * time.Hour},
+		{"", "domain.auto_renewal_disable", 1010, "example.org", "john@example.com", 4 * time.Hour},
+		{"", "zone_record.create", 1010, "example.net", "jane@example.com", 8 * 24 * time.Hour},
+		{"team-a", "zone_record.create", 1010, "example.io", "jane@example.com", time.Hour},
+		{"", "contact.update", 5050, "", "", time.Hour},
+	}
+	for i, event := range events {
+		err := history.Record(&HistoryEvent{
+			Tenant:     event.tenant,
+			Name:       event.name,
+			RequestID:  fmt.Sprintf("a8c4d7f9-activity-%d", i),
+			AccountID:  event.accountID,
+			Domain:     event.domain,
+			ReceivedAt: now.Add(-event.age),
+			Payload:    []byte(fmt.Sprintf(`{"actor": {"pretty": %q}}`, event.actor)),
+		})
+		if err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+	}
+
+	server.sendActivityReports(context.Background(), now)
+
+	want := []string{
+		"[<https://dnsimple.com/a/1010/account|1010>] Weekly activity from 2021-03-01 to 2021-03-08: 4 events\n" +
+			"Events: zone_record.create (2), domain.auto_renewal_disable (1), zone_record.delete (1)\n" +
+			"Top actors: jane@example.com (2), john@example.com (1)\n" +
+			"Domains changed: <https://dnsimple.com/a/1010/domains/example.com|example.com>, <https://dnsimple.com/a/1010/domains/example.org|example.org>\n" +
+			"Expiring within 30 days: <https://dnsimple.com/a/1010/domains/example.org|example.org> expires on 2021-03-10, <https://dnsimple.com/a/1010/domains/example.com|example.com> expires on 2021-03-20",
+		"[<https://dnsimple.com/a/5050/account|5050>] Weekly activity from 2021-03-01 to 2021-03-08: 1 events\n" +
+			"Events: contact.update (1)\n" +
+			"Top actors: none\n" +
+			"Domains changed: none\n" +
+			"Expiring within 30 days: none",
+	}
+	if got := service.messages; strings.Join(want, "\n\n") != strings.Join(got, "\n\n") {
+		t.Errorf("sendActivityReports expected\n%v\ngot\n%v", strings.Join(want, "\n\n"), strings.Join(got, "\n\n"))
+	}
+}
+
+func TestActivityReportConfig_validate(t *testing.T) {
+	destinations := map[string]bool{"ops": true}
+	tests := []struct {
+		config *ActivityReportConfig
+		valid  bool
+	}{
+		{nil, true},
+		{&ActivityReportConfig{Destinations: []string{"ops"}, Accounts: []int64{1010}, Schedule: "0 9 * * 5", Timezone: "Europe/Rome", Actors: 10, ExpirationDays: 60}, true},
+		{&ActivityReportConfig{}, false},
+		{&ActivityReportConfig{Destinations: []string{"pager"}}, false},
+		{&ActivityReportConfig{Destinations: []string{"ops"}, Actors: -1}, false},
+		{&ActivityReportConfig{Destinations: []string{"ops"}, Schedule: "weekly"}, false},
+		{&ActivityReportConfig{Destinations: []string{"ops"}, Timezone: "Mars/Olympus"}, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate(destinations)
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package strillone
+
+import (
+	"strings"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// ActorMentioner is implemented by the messaging services that mention the actors of the events,
+// such as Slack with the actors mapped to Slack users.
+type ActorMentioner interface {
+	// MentionActor returns the mention of the user with the given email, false if not mapped.
+	MentionActor(email string) (string, bool)
+}
+
+// formatActor describes who performed the event: the user, the API token or DNSimple itself,
+// such as "jane@example.com via API token 'terraform'".
+func formatActor(s Formatter, actor *webhook.Actor) string {
+	if actor == nil {
+		return "DNSimple"
+	}
+
+	switch actor.Entity {
+	case "dnsimple", "system":
+		return "DNSimple"
+	case "access_token", "api_token", "token":
+		// The tokens are described as the token name, or as the owner with the token name in parentheses.
+		owner, name := actorToken(actor.Pretty)
+		if owner == "" {
+			return tprintf(s, "API token '%s'", escape(s, name))
+		}
+		return tprintf(s, "%s via API token '%s'", mentionActor(s, owner), escape(s, name))
+	case "oauth_application", "application":
+		return tprintf(s, "application '%s'", escape(s, actor.Pretty))
+	default:
+		return mentionActor(s, actor.Pretty)
+	}
+}
+
+// actorToken splits the description of a token, such as "jane@example.com (terraform)",
+// into the owner and the token name.
+func actorToken(pretty string) (owner, name string) {
+	if i := strings.LastIndex(pretty, " ("); i > 0 && strings.HasSuffix(pretty, ")") {
+		return pretty[:i], pretty[i+2 : len(pretty)-1]
+	}
+	return "", pretty
+}
+
+// mentionActor returns the mention of the user when the service maps it, the user otherwise.
+func mentionActor(s Formatter, user string) string {
+	if mentioner, ok := s.(ActorMentioner); ok {
+		if mention, ok := mentioner.MentionActor(user); ok {
+			return mention
+		}
+	}
+	return escape(s, user)
+}
+
+// matchActor returns true if one of the patterns, using the path.Match syntax, matches the actor:
+// the user, such as "jane@example.com", or the name of the API token, such as "terraform".
+func matchActor(patterns []string, actor *webhook.Actor) bool {
+	if actor == nil {
+		return false
+	}
+	switch actor.Entity {
+	case "access_token", "api_token", "token":
+		owner, name := actorToken(actor.Pretty)
+		return matchesAny(patterns, name) || (owner != "" && matchesAny(patterns, owner))
+	default:
+		return matchesAny(patterns, actor.Pretty)
+	}
+}
+
+// matchesActor returns true if the route receives the events performed by the actor.
+func (r *RouteConfig) matchesActor(actor *webhook.Actor) bool {
+	if len(r.Actors) > 0 && !matchActor(r.Actors, actor) {
+		return false
+	}
+	return !matchActor(r.IgnoreActors, actor)
+}
+
+// excludeActor returns the exclusion of the routes not receiving the events performed by the actor.
+func excludeActor(actor *webhook.Actor, exclude func(route *RouteConfig) bool) func(route *RouteConfig) bool {
+	return func(route *RouteConfig) bool {
+		return !route.matchesActor(actor) || (exclude != nil && exclude(route))
+	}
+}
@@ -0,0 +1,77 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_matchActor(t *testing.T) {
+	tests := []struct {
+		actor *webhook.Actor
+		want  bool
+	}{
+		{&webhook.Actor{Entity: "api_token", Pretty: "terraform"}, true},
+		{&webhook.Actor{Entity: "access_token", Pretty: "ops@example.com (terraform-prod)"}, true},
+		{&webhook.Actor{Entity: "user", Pretty: "jane@example.com"}, false},
+		{&webhook.Actor{Entity: "user", Pretty: "bot@example.com"}, true},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := matchActor([]string{"terraform*", "bot@*"}, test.actor); test.want != got {
+			t.Errorf("matchActor(%+v) expected %v, got %v", test.actor, test.want, got)
+		}
+	}
+}
+
+func TestEvents_IgnoreActors(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "automation", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [
+			{"destinations": ["ops"], "ignore_actors": ["terraform", "external-dns"]},
+			{"destinations": ["automation"], "actors": ["terraform"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops, automation := &failingService{}, &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["automation"] = automation
+
+	payload := `{"name": "zone_record.update", "request_identifier": "c4e0f3b5-actors-0000-00000000000%d", "data": {"zone_record": {"id": %d, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}, "actor": %s, "account": {"id": 1010, "display": "User"}}`
+	tests := []struct {
+		actor  string
+		status string
+	}{
+		{`{"entity": "api_token", "pretty": "terraform"}`, ""},
+		{`{"entity": "access_token", "pretty": "ops@example.com (external-dns)"}`, "skipped;no-route"},
+		{`{"entity": "user", "pretty": "jane@example.com"}`, ""},
+	}
+	for i, test := range tests {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, i, i, test.actor)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := test.status, response.Header().Get(headerProcessingStatus); want != got {
+			t.Errorf("POST /events by %v expected status %q, got %q", test.actor, want, got)
+		}
+	}
+	if want, got := 1, ops.sent; want != got {
+		t.Errorf("ops expected %v events delivered, got %v", want, got)
+	}
+	if want, got := 1, automation.sent; want != got {
+		t.Errorf("automation expected %v events delivered, got %v", want, got)
+	}
+
+	if _, err := ParseConfig([]byte(`{"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}], "routes": [{"destinations": ["ops"], "ignore_actors": ["[terraform"]}]}`)); err == nil {
+		t.Errorf("ParseConfig expected an error for an invalid actor pattern")
+	}
+}
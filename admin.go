@@ -0,0 +1,358 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// adminError is an error returned by a configuration change, with the HTTP status to report.
+type adminError struct {
+	status  int
+	message string
+}
+
+func (e *adminError) Error() string {
+	return e.message
+}
+
+func (s *Server) registerAdminRoutes(router *httprouter.Router) {
+	router.GET("/admin/destinations", s.adminAuth(RoleViewer, s.AdminListDestinations))
+	router.POST("/admin/destinations", s.adminAuth(RoleAdmin, s.AdminCreateDestination))
+	router.GET("/admin/destinations/:name", s.adminAuth(RoleViewer, s.AdminGetDestination))
+	router.GET("/admin/destinations/:name/health", s.adminAuth(RoleViewer, s.AdminCheckDestination))
+	router.PUT("/admin/destinations/:name", s.adminAuth(RoleAdmin, s.AdminUpdateDestination))
+	router.DELETE("/admin/destinations/:name", s.adminAuth(RoleAdmin, s.AdminDeleteDestination))
+
+	router.GET("/admin/routes", s.adminAuth(RoleViewer, s.AdminListRoutes))
+	router.POST("/admin/routes", s.adminAuth(RoleAdmin, s.AdminCreateRoute))
+	router.GET("/admin/routes/:name", s.adminAuth(RoleViewer, s.AdminGetRoute))
+	router.PUT("/admin/routes/:name", s.adminAuth(RoleAdmin, s.AdminUpdateRoute))
+	router.DELETE("/admin/routes/:name", s.adminAuth(RoleAdmin, s.AdminDeleteRoute))
+
+	router.GET("/admin/ownership", s.adminAuth(RoleViewer, s.AdminListOwnership))
+	router.PUT("/admin/ownership", s.adminAuth(RoleAdmin, s.AdminReplaceOwnership))
+	router.POST("/admin/ownership", s.adminAuth(RoleAdmin, s.AdminSetOwnership))
+	router.DELETE("/admin/ownership", s.adminAuth(RoleAdmin, s.AdminDeleteOwnership))
+
+	router.GET("/admin/tenants", s.adminAuth(RoleViewer, s.AdminListTenants))
+	router.GET("/admin/stats", s.adminAuth(RoleViewer, s.AdminGetStats))
+	router.GET("/admin/audit", s.adminAuth(RoleViewer, s.AdminListAudit))
+
+	router.GET("/admin/dlq", s.adminAuth(RoleViewer, s.AdminListDeadLetters))
+	router.POST("/admin/dlq", s.adminAuth(RoleOperator, s.AdminRedeliverDeadLetters))
+	router.POST("/admin/dlq/:id", s.adminAuth(RoleOperator, s.AdminRedeliverDeadLetter))
+	router.DELETE("/admin/dlq/:id", s.adminAuth(RoleOperator, s.AdminDeleteDeadLetter))
+
+	router.GET("/admin/escalations", s.adminAuth(RoleViewer, s.AdminListEscalations))
+	router.POST("/admin/escalations/:id/acknowledge", s.adminAuth(RoleOperator, s.AdminAcknowledgeEscalation))
+
+	router.GET("/api/events", s.adminAuth(RoleViewer, s.APIListEvents))
+	router.GET("/api/events/export", s.adminAuth(RoleViewer, s.APIExportEvents))
+	router.POST("/api/events/purge", s.adminAuth(RoleAdmin, s.APIPurgeEvents))
+	router.POST("/api/preview", s.adminAuth(RoleViewer, s.APIPreview))
+	router.GET("/api/silences", s.adminAuth(RoleViewer, s.APIListSilences))
+	router.POST("/api/silences", s.adminAuth(RoleOperator, s.APICreateSilence))
+	router.DELETE("/api/silences/:id", s.adminAuth(RoleOperator, s.APIDeleteSilence))
+
+	router.GET("/dashboard", s.dashboardAuth(RoleViewer, s.Dashboard))
+	router.POST("/dashboard/redeliver", s.dashboardAuth(RoleOperator, s.DashboardRedeliver))
+	router.GET("/dashboard/login", s.DashboardLogin)
+	router.GET("/dashboard/callback", s.DashboardCallback)
+	router.GET("/dashboard/logout", s.DashboardLogout)
+
+	router.GET("/debug/status", s.debugAuth(s.DebugStatus))
+	router.GET("/debug/pprof/*profile", s.debugAuth(s.DebugPprof))
+	router.POST("/debug/pprof/*profile", s.debugAuth(s.DebugPprof))
+}
+
+// adminAuth wraps an admin handler and requires the admin token, or an API key with the role, as the bearer token.
+// When no admin token or key is configured the admin API is disabled.
+func (s *Server) adminAuth(role string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Admin request")
+
+		keys := s.currentRouting().adminKeys
+		if len(keys) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		key := authenticate(keys, bearerToken(r))
+		if key == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="strillone"`)
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if !key.allows(role) {
+			writeJSONError(w, http.StatusForbidden, fmt.Sprintf("the %s role is required", role))
+			return
+		}
+
+		handle(w, withAdminKey(r, key), params)
+	}
+}
+
+// AdminListDestinations returns the configured destinations.
+func (s *Server) AdminListDestinations(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	destinations := s.currentRouting().config.Destinations
+	if !requestAllows(r, RoleAdmin) {
+		destinations = maskDestinations(destinations)
+	}
+	writeJSON(w, http.StatusOK, destinations)
+}
+
+// AdminGetDestination returns a destination.
+func (s *Server) AdminGetDestination(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	config := s.currentRouting().config
+	i := findDestination(config, params.ByName("name"))
+	if i < 0 {
+		writeJSONError(w, http.StatusNotFound, "destination not found")
+		return
+	}
+	destination := config.Destinations[i]
+	if !requestAllows(r, RoleAdmin) {
+		destination = maskDestinations(config.Destinations[i : i+1])[0]
+	}
+	writeJSON(w, http.StatusOK, destination)
+}
+
+// AdminCreateDestination adds a new destination.
+func (s *Server) AdminCreateDestination(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	destination := DestinationConfig{}
+	if !readJSON(w, r, &destination) {
+		return
+	}
+
+	err := s.updateConfig(adminAudit(r, "destination.create", destination.Name), func(config *Config) error {
+		if findDestination(config, destination.Name) >= 0 {
+			return &adminError{http.StatusConflict, "destination already exists"}
+		}
+		config.Destinations = append(config.Destinations, destination)
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, destination)
+}
+
+// AdminUpdateDestination replaces a destination.
+func (s *Server) AdminUpdateDestination(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	destination := DestinationConfig{}
+	if !readJSON(w, r, &destination) {
+		return
+	}
+	name := params.ByName("name")
+	if destination.Name == "" {
+		destination.Name = name
+	}
+	if destination.Name != name {
+		writeJSONError(w, http.StatusBadRequest, "destination name can't be changed")
+		return
+	}
+
+	err := s.updateConfig(adminAudit(r, "destination.update", name), func(config *Config) error {
+		i := findDestination(config, name)
+		if i < 0 {
+			return &adminError{http.StatusNotFound, "destination not found"}
+		}
+		config.Destinations[i] = destination
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, destination)
+}
+
+// AdminDeleteDestination removes a destination that is not used by any route.
+func (s *Server) AdminDeleteDestination(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	name := params.ByName("name")
+	err := s.updateConfig(adminAudit(r, "destination.delete", name), func(config *Config) error {
+		i := findDestination(config, name)
+		if i < 0 {
+			return &adminError{http.StatusNotFound, "destination not found"}
+		}
+		for _, route := range config.Routes {
+			for _, d := range route.Destinations {
+				if d == name {
+					return &adminError{http.StatusConflict, "destination is used by a route"}
+				}
+			}
+		}
+		config.Destinations = append(config.Destinations[:i], config.Destinations[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminListRoutes returns the configured routes.
+func (s *Server) AdminListRoutes(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	writeJSON(w, http.StatusOK, s.currentRouting().config.Routes)
+}
+
+// AdminGetRoute returns a route.
+func (s *Server) AdminGetRoute(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	config := s.currentRouting().config
+	i := findRoute(config, params.ByName("name"))
+	if i < 0 {
+		writeJSONError(w, http.StatusNotFound, "route not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, config.Routes[i])
+}
+
+// AdminCreateRoute adds a new route.
+func (s *Server) AdminCreateRoute(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	route := RouteConfig{}
+	if !readJSON(w, r, &route) {
+		return
+	}
+	if route.Name == "" {
+		writeJSONError(w, http.StatusBadRequest, "route name is required")
+		return
+	}
+
+	err := s.updateConfig(adminAudit(r, "route.create", route.Name), func(config *Config) error {
+		if findRoute(config, route.Name) >= 0 {
+			return &adminError{http.StatusConflict, "route already exists"}
+		}
+		config.Routes = append(config.Routes, route)
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, route)
+}
+
+// AdminUpdateRoute replaces a route.
+func (s *Server) AdminUpdateRoute(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	route := RouteConfig{}
+	if !readJSON(w, r, &route) {
+		return
+	}
+	name := params.ByName("name")
+	if route.Name == "" {
+		route.Name = name
+	}
+	if route.Name != name {
+		writeJSONError(w, http.StatusBadRequest, "route name can't be changed")
+		return
+	}
+
+	err := s.updateConfig(adminAudit(r, "route.update", name), func(config *Config) error {
+		i := findRoute(config, name)
+		if i < 0 {
+			return &adminError{http.StatusNotFound, "route not found"}
+		}
+		config.Routes[i] = route
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, route)
+}
+
+// AdminDeleteRoute removes a route.
+func (s *Server) AdminDeleteRoute(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	name := params.ByName("name")
+	err := s.updateConfig(adminAudit(r, "route.delete", name), func(config *Config) error {
+		i := findRoute(config, name)
+		if i < 0 {
+			return &adminError{http.StatusNotFound, "route not found"}
+		}
+		config.Routes = append(config.Routes[:i], config.Routes[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateConfig applies the change to a copy of the current configuration,
+// then validates, persists, loads and audits the result.
+func (s *Server) updateConfig(entry *AuditEntry, change func(config *Config) error) error {
+	s.adminMu.Lock()
+	defer s.adminMu.Unlock()
+
+	current := s.currentRouting().config
+	config := current.Clone()
+	if err := change(config); err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return &adminError{http.StatusUnprocessableEntity, err.Error()}
+	}
+	s.encryptCredentials(config)
+	if err := s.configStore.Save(config); err != nil {
+		return fmt.Errorf("error saving configuration: %v", err)
+	}
+	if err := s.Reload(config); err != nil {
+		return err
+	}
+	s.audit(entry, current, config)
+	return nil
+}
+
+func findDestination(config *Config, name string) int {
+	for i := range config.Destinations {
+		if config.Destinations[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func findRoute(config *Config, name string) int {
+	for i := range config.Routes {
+		if config.Routes[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Error encoding response")
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	if e, ok := err.(*adminError); ok {
+		writeJSONError(w, e.status, e.message)
+		return
+	}
+	log.Error().Err(err).Msg("Internal error")
+	writeJSONError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,116 @@
+package strillone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newAdminTestServer() (*Server, *MemoryConfigStore) {
+	server := NewServer(&Config{Admin: AdminConfig{Token: "secret"}})
+	store := &MemoryConfigStore{}
+	server.SetConfigStore(store)
+	return server, store
+}
+
+func adminRequest(server *Server, method, path, body string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer secret")
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+func TestAdmin_Unauthorized(t *testing.T) {
+	server, _ := newAdminTestServer()
+
+	request, _ := http.NewRequest("GET", "/admin/destinations", nil)
+	request.Header.Set("Authorization", "Bearer wrong")
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusUnauthorized; want != response.Code {
+		t.Errorf("GET /admin/destinations expected HTTP %v, got %v", want, response.Code)
+	}
+}
+
+func TestAdmin_Disabled(t *testing.T) {
+	server := NewServer(nil)
+
+	request, _ := http.NewRequest("GET", "/admin/destinations", nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusNotFound; want != response.Code {
+		t.Errorf("GET /admin/destinations expected HTTP %v, got %v", want, response.Code)
+	}
+}
+
+func TestAdmin_Destinations(t *testing.T) {
+	server, store := newAdminTestServer()
+
+	response := adminRequest(server, "POST", "/admin/destinations", `{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/X/Y/Z"}`)
+	if want := http.StatusCreated; want != response.Code {
+		t.Fatalf("POST /admin/destinations expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+
+	response = adminRequest(server, "POST", "/admin/destinations", `{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/X/Y/Z"}`)
+	if want := http.StatusConflict; want != response.Code {
+		t.Errorf("POST /admin/destinations (duplicate) expected HTTP %v, got %v", want, response.Code)
+	}
+
+	response = adminRequest(server, "POST", "/admin/destinations", `{"name": "bad", "type": "unknown"}`)
+	if want := http.StatusUnprocessableEntity; want != response.Code {
+		t.Errorf("POST /admin/destinations (invalid) expected HTTP %v, got %v", want, response.Code)
+	}
+
+	response = adminRequest(server, "PUT", "/admin/destinations/ops", `{"type": "slack", "url": "https://hooks.slack.com/services/A/B/C"}`)
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("PUT /admin/destinations/ops expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+
+	response = adminRequest(server, "GET", "/admin/destinations", "")
+	var destinations []DestinationConfig
+	if err := json.Unmarshal(response.Body.Bytes(), &destinations); err != nil {
+		t.Fatalf("GET /admin/destinations returned invalid JSON: %v", err)
+	}
+	if want, got := 1, len(destinations); want != got {
+		t.Fatalf("GET /admin/destinations expected %v destinations, got %v", want, got)
+	}
+	if want, got := "https://hooks.slack.com/services/A/B/C", destinations[0].URL; want != got {
+		t.Errorf("GET /admin/destinations expected URL %v, got %v", want, got)
+	}
+
+	saved, _ := store.Load()
+	if want, got := 1, len(saved.Destinations); want != got {
+		t.Errorf("store expected %v destinations, got %v", want, got)
+	}
+
+	response = adminRequest(server, "POST", "/admin/routes", `{"name": "all", "destinations": ["ops"]}`)
+	if want := http.StatusCreated; want != response.Code {
+		t.Fatalf("POST /admin/routes expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+	if names := server.currentRouting().Lookup("domain.create"); len(names) != 1 || names[0] != "ops" {
+		t.Errorf("Lookup expected [ops], got %v", names)
+	}
+
+	response = adminRequest(server, "DELETE", "/admin/destinations/ops", "")
+	if want := http.StatusConflict; want != response.Code {
+		t.Errorf("DELETE /admin/destinations/ops (in use) expected HTTP %v, got %v", want, response.Code)
+	}
+
+	response = adminRequest(server, "DELETE", "/admin/routes/all", "")
+	if want := http.StatusNoContent; want != response.Code {
+		t.Errorf("DELETE /admin/routes/all expected HTTP %v, got %v", want, response.Code)
+	}
+	response = adminRequest(server, "DELETE", "/admin/destinations/ops", "")
+	if want := http.StatusNoContent; want != response.Code {
+		t.Errorf("DELETE /admin/destinations/ops expected HTTP %v, got %v", want, response.Code)
+	}
+	response = adminRequest(server, "GET", "/admin/destinations/ops", "")
+	if want := http.StatusNotFound; want != response.Code {
+		t.Errorf("GET /admin/destinations/ops expected HTTP %v, got %v", want, response.Code)
+	}
+}
@@ -0,0 +1,202 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultAggregationWindow is how long the records of a zone are collected after the first one.
+	defaultAggregationWindow = 30 * time.Second
+
+	// aggregateSummaryRecords is the number of records listed by the destinations without detail lists.
+	aggregateSummaryRecords = 10
+)
+
+// AggregationConfig collapses the bursts of zone_record.create events for the same zone,
+// such as a zone import, in a single message.
+type AggregationConfig struct {
+	// Window is how long the records created in a zone are collected after the first one. Defaults to 30s.
+	Window Duration `json:"window,omitempty"`
+}
+
+func (c *AggregationConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Window < 0 {
+		return fmt.Errorf("aggregation: invalid window %v", time.Duration(c.Window))
+	}
+	return nil
+}
+
+func (c *AggregationConfig) window() time.Duration {
+	if c.Window == 0 {
+		return defaultAggregationWindow
+	}
+	return time.Duration(c.Window)
+}
+
+// DetailPoster is implemented by the messaging services showing a message with an expandable
+// list of details, such as the records of an aggregated zone import.
+type DetailPoster interface {
+	PostDetails(ctx context.Context, text string, details []string) error
+}
+
+// eventAggregate is a burst of records created in a zone by the same actor.
+type eventAggregate struct {
+	tenant      string
+	destination string
+	events      []*webhook.Event
+}
+
+// eventAggregates are the pending aggregates, by tenant, destination, zone and actor.
+type eventAggregates struct {
+	mu         sync.Mutex
+	aggregates map[string]*eventAggregate
+}
+
+func newEventAggregates() *eventAggregates {
+	return &eventAggregates{aggregates: map[string]*eventAggregate{}}
+}
+
+// aggregateKey returns the key of the aggregate of the event, empty if the event isn't aggregated.
+func aggregateKey(tenant, destination string, event *webhook.Event) string {
+	if event.Name != "zone_record.create" || event.Account == nil {
+		return ""
+	}
+	data := parseZoneRecordEvent(event)
+	if data == nil || data.ZoneRecord == nil || data.ZoneRecord.ZoneID == "" {
+		return ""
+	}
+	var actor string
+	if event.Actor != nil {
+		actor = event.Actor.Pretty
+	}
+	return fmt.Sprintf("%s/%s/%d/%s/%s", tenant, destination, event.Account.ID, data.ZoneRecord.ZoneID, actor)
+}
+
+// aggregate collects the record created in a zone with the records created afterwards in the same zone,
+// by the same actor, within the window. At the end of the window, the records are sent as a single message.
+// It returns false if the event was aggregated.
+func (s *Server) aggregate(routing *routingTable, name string, event *webhook.Event, config *AggregationConfig) bool {
+	if config == nil {
+		return true
+	}
+	key := aggregateKey(routing.name, name, event)
+	if key == "" {
+		return true
+	}
+
+	s.aggregates.mu.Lock()
+	defer s.aggregates.mu.Unlock()
+
+	if pending, ok := s.aggregates.aggregates[key]; ok {
+		pending.events = append(pending.events, event)
+		return false
+	}
+	withEvent(log.Info(), event).Str("tenant", routing.name).Str("destination", name).Str("outcome", "aggregated").Msg("Aggregating the records of the zone")
+	s.aggregates.aggregates[key] = &eventAggregate{tenant: routing.name, destination: name, events: []*webhook.Event{event}}
+	time.AfterFunc(config.window(), func() { s.flushAggregate(key) })
+	return false
+}
+
+// flushAggregate sends the aggregate at the end of its window.
+func (s *Server) flushAggregate(key string) {
+	s.aggregates.mu.Lock()
+	pending, ok := s.aggregates.aggregates[key]
+	delete(s.aggregates.aggregates, key)
+	s.aggregates.mu.Unlock()
+
+	if ok {
+		s.sendAggregate(pending)
+	}
+}
+
+// flushAllAggregates sends all the pending aggregates, before the end of their windows.
+func (s *Server) flushAllAggregates() {
+	s.aggregates.mu.Lock()
+	aggregates := s.aggregates.aggregates
+	s.aggregates.aggregates = map[string]*eventAggregate{}
+	s.aggregates.mu.Unlock()
+
+	for _, pending := range aggregates {
+		s.sendAggregate(pending)
+	}
+}
+
+// sendAggregate sends the aggregate with the current configuration: the event itself when alone,
+// or a summary of the records, with the list of the records as details. The events are enqueued again if it fails.
+func (s *Server) sendAggregate(pending *eventAggregate) {
+	routing := s.currentRouting().tenantByName(pending.tenant)
+	if routing == nil || routing.services[pending.destination] == nil {
+		log.Warn().Str("tenant", pending.tenant).Str("destination", pending.destination).Int("events", len(pending.events)).Msg("Dropping the aggregated events: unknown destination")
+		return
+	}
+	service := routing.services[pending.destination]
+
+	ctx, cancel := routing.deliveryContext(context.Background())
+	defer cancel()
+
+	var err error
+	if len(pending.events) == 1 {
+		_, err = service.PostEvent(ctx, pending.events[0])
+	} else {
+		formatter := unwrapService(service)
+		text, details := aggregateSummary(formatter, pending.events), aggregateDetails(formatter, pending.events)
+		// the services of the dry runs only log the messages
+		_, dryRun := service.(*dryRunService)
+		if poster, ok := formatter.(DetailPoster); ok && !dryRun {
+			err = poster.PostDetails(ctx, text, details)
+		} else {
+			err = service.PostMessage(ctx, text+"\n"+strings.Join(truncateDetails(details, aggregateSummaryRecords), "\n"))
+		}
+	}
+
+	stats := s.stats.Tenant(pending.tenant)
+	if err != nil {
+		log.Error().Err(err).Str("tenant", pending.tenant).Str("destination", pending.destination).Int("events", len(pending.events)).Msg("Error sending the aggregated events")
+		for range pending.events {
+			stats.failed()
+		}
+		s.requeue(pending.tenant, pending.destination, pending.events, err)
+		return
+	}
+	for range pending.events {
+		stats.delivered()
+	}
+}
+
+// aggregateSummary returns the summary of the records created in a zone, such as
+// "42 records added to example.com by terraform".
+func aggregateSummary(s Formatter, events []*webhook.Event) string {
+	first := events[0]
+	zone := parseZoneRecordEvent(first).ZoneRecord.ZoneID
+	zoneLink := s.FormatLink(zone, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s/records", first.Account.ID, zone))
+	return tprintf(s, "%d records added to %s by %s", len(events), zoneLink, formatActor(s, first.Actor))
+}
+
+// aggregateDetails returns the records created, one per line.
+func aggregateDetails(s Formatter, events []*webhook.Event) []string {
+	details := make([]string, 0, len(events))
+	for _, event := range events {
+		record := parseZoneRecordEvent(event).ZoneRecord
+		display := fmt.Sprintf("%s %s.%s %s", record.Type, record.Name, record.ZoneID, record.Content)
+		details = append(details, s.FormatLink(display, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s/records/%d", event.Account.ID, record.ZoneID, record.ID)))
+	}
+	return details
+}
+
+// truncateDetails returns the first max details, followed by the number of the others.
+func truncateDetails(details []string, max int) []string {
+	if len(details) <= max {
+		return details
+	}
+	return append(details[:max:max], fmt.Sprintf("...and %d more", len(details)-max))
+}
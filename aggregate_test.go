@@ -0,0 +1,88 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+type detailService struct {
+	failingService
+	details [][]string
+}
+
+func (s *detailService) PostDetails(_ context.Context, text string, details []string) error {
+	s.messages = append(s.messages, text)
+	s.details = append(s.details, details)
+	return nil
+}
+
+func TestServer_Aggregate(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A", "aggregation": {"window": "1h"}}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops := &detailService{}
+	server.routing.services["ops"] = ops
+
+	payload := `{"name": "%s", "data": {"zone_record": {"id": %d, "zone_id": "%s", "type": "A", "name": "www%d", "content": "1.2.3.4"}}, "actor": {"pretty": "terraform"}, "account": {"id": 1010, "display": "User"}}`
+	events := []string{
+		fmt.Sprintf(payload, "zone_record.create", 1, "example.com", 1),
+		fmt.Sprintf(payload, "zone_record.create", 2, "example.com", 2),
+		fmt.Sprintf(payload, "zone_record.create", 3, "example.org", 3),
+		fmt.Sprintf(payload, "zone_record.delete", 1, "example.com", 1),
+		fmt.Sprintf(payload, "zone_record.create", 4, "example.com", 4),
+	}
+	for _, data := range events {
+		event, err := webhook.ParseEvent([]byte(data))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, data)
+		}
+		if _, err := server.deliver(context.Background(), server.routing, "ops", event); err != nil {
+			t.Fatalf("deliver returned error: %v", err)
+		}
+	}
+	if want, got := 1, ops.sent; want != got {
+		t.Fatalf("expected %v event delivered immediately, got %v", want, got)
+	}
+
+	server.flushAllAggregates()
+	if want, got := 2, ops.sent; want != got {
+		t.Errorf("expected the record of example.org delivered alone, got %v events", got)
+	}
+	if want, got := 1, len(ops.messages); want != got {
+		t.Fatalf("expected %v aggregated message, got %v", want, got)
+	}
+	if want := "3 records added to <https://dnsimple.com/a/1010/domains/example.com/records|example.com> by terraform"; ops.messages[0] != want {
+		t.Errorf("expected message %q, got %q", want, ops.messages[0])
+	}
+	if want, got := 3, len(ops.details[0]); want != got {
+		t.Errorf("expected %v details, got %v", want, got)
+	}
+}
+
+func Test_slackDetailBlocks(t *testing.T) {
+	var details []string
+	for i := 0; i < 10000; i++ {
+		details = append(details, fmt.Sprintf("A www%d.example.com 1.2.3.4", i))
+	}
+	blocks := slackDetailBlocks(details)
+	if want, got := slackDetailSections, len(blocks); want != got {
+		t.Fatalf("expected %v sections, got %v", want, got)
+	}
+	for _, block := range blocks {
+		if len(block.Text.Text) > slackSectionLimit {
+			t.Errorf("expected sections within %v characters, got %v", slackSectionLimit, len(block.Text.Text))
+		}
+	}
+	if last := blocks[len(blocks)-1].Text.Text; !strings.Contains(last, "more") {
+		t.Errorf("expected the last section to count the details left out, got %q", last[len(last)-40:])
+	}
+}
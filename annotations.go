@@ -0,0 +1,78 @@
+package strillone
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// maxAnnotations is the maximum number of annotations of a route, the number of elements of a Slack context block.
+const maxAnnotations = 10
+
+type annotationsContext struct{}
+
+// withAnnotations returns the context of the delivery of a message, with the annotations of its routes.
+func withAnnotations(ctx context.Context, annotations map[string]string) context.Context {
+	if len(annotations) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, annotationsContext{}, annotations)
+}
+
+// Annotations returns the annotations of the routes of the message delivered, from the context of Destination.Deliver,
+// so that the destinations render them, such as the fields of the Slack messages or the attributes of structured records.
+func Annotations(ctx context.Context) map[string]string {
+	annotations, _ := ctx.Value(annotationsContext{}).(map[string]string)
+	return annotations
+}
+
+// annotations returns the annotations of the routes delivering the event to the destination.
+// When several routes set the same annotation, the first one applies.
+func (t *routingTable) annotations(eventName, destination string) map[string]string {
+	var annotations map[string]string
+	for i := range t.routes {
+		route := &t.routes[i]
+		if len(route.Annotations) == 0 || !t.routeMatches(i, eventName) {
+			continue
+		}
+		for _, name := range route.Destinations {
+			if name != destination {
+				continue
+			}
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			for key, value := range route.Annotations {
+				if _, ok := annotations[key]; !ok {
+					annotations[key] = value
+				}
+			}
+		}
+	}
+	return annotations
+}
+
+// sortedAnnotations returns the names of the annotations, sorted.
+func sortedAnnotations(annotations map[string]string) []string {
+	names := make([]string, 0, len(annotations))
+	for name := range annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// slackAnnotationsBlock returns the context block listing the annotations at the bottom of the Slack messages.
+// The URLs are links named after their annotation, such as a runbook.
+func slackAnnotationsBlock(r Renderer, annotations map[string]string) slackBlock {
+	block := slackBlock{Type: "context"}
+	for _, name := range sortedAnnotations(annotations) {
+		value := annotations[name]
+		if strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://") {
+			block.Elements = append(block.Elements, mrkdwn(r.FormatLink(name, value)))
+			continue
+		}
+		block.Elements = append(block.Elements, mrkdwn("*"+r.Escape(name)+":* "+r.Escape(value)))
+	}
+	return block
+}
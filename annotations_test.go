@@ -0,0 +1,78 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoutingTable_annotations(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}, {"name": "dev", "type": "slack", "url": "https://hooks.slack.com/services/B"}],
+		"routes": [
+			{"events": ["zone_record.*"], "destinations": ["ops"], "annotations": {"team": "dns", "runbook": "https://runbooks.example.com/dns"}},
+			{"destinations": ["ops", "dev"], "annotations": {"environment": "prod", "team": "platform"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	routing := NewServer(config).currentRouting()
+
+	tests := []struct {
+		event       string
+		destination string
+		expected    map[string]string
+	}{
+		{"zone_record.create", "ops", map[string]string{"team": "dns", "runbook": "https://runbooks.example.com/dns", "environment": "prod"}},
+		{"domain.create", "ops", map[string]string{"team": "platform", "environment": "prod"}},
+		{"zone_record.create", "dev", map[string]string{"team": "platform", "environment": "prod"}},
+		{"zone_record.create", "qa", nil},
+	}
+	for _, test := range tests {
+		if got := routing.annotations(test.event, test.destination); !reflect.DeepEqual(test.expected, got) {
+			t.Errorf("annotations(%v, %v) expected %v, got %v", test.event, test.destination, test.expected, got)
+		}
+	}
+
+	if _, err := ParseConfig([]byte(`{"routes": [{"annotations": {"": "prod"}}]}`)); err == nil {
+		t.Errorf("ParseConfig with an empty annotation name expected error")
+	}
+}
+
+func TestSlackService_Annotations(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"admin": {"token": "secret"},
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"], "annotations": {"environment": "prod", "runbook": "https://runbooks.example.com/dns"}}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	response := adminRequest(server, "POST", "/api/preview?destination=ops&raw=1", previewTestPayload)
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("POST /api/preview expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+	var payload slackPayload
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	blocks := payload.Attachments[0].Blocks
+	footer := blocks[len(blocks)-1]
+	var texts []string
+	for _, element := range footer.Elements {
+		texts = append(texts, element.(map[string]interface{})["text"].(string))
+	}
+	if want, got := "*environment:* prod,<https://runbooks.example.com/dns|runbook>", strings.Join(texts, ","); footer.Type != "context" || want != got {
+		t.Errorf("expected the annotations %v in a context block, got %v %v", want, footer.Type, got)
+	}
+
+	if got := Annotations(context.Background()); got != nil {
+		t.Errorf("Annotations without annotations expected nil, got %v", got)
+	}
+}
@@ -0,0 +1,274 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultAnomalyInterval is the period over which the events are counted.
+	defaultAnomalyInterval = 5 * time.Minute
+
+	// defaultAnomalyBaseline is the rolling period of the usual volume.
+	defaultAnomalyBaseline = 24 * time.Hour
+
+	// defaultAnomalyFactor is how many times the usual volume is unusual.
+	defaultAnomalyFactor = 5
+
+	// defaultAnomalyMinEvents is the volume below which nothing is unusual.
+	defaultAnomalyMinEvents = 20
+
+	// maxAnomalyCounters is the number of accounts and zones tracked before the idle ones are forgotten.
+	maxAnomalyCounters = 10000
+)
+
+// AnomalyConfig alerts when the volume of the events of an account or of a zone spikes, such as
+// a compromised account or a runaway automation.
+type AnomalyConfig struct {
+	// Destinations are the names of the destinations receiving the anomaly alerts.
+	Destinations []string `json:"destinations"`
+
+	// Interval is the period over which the events are counted. Defaults to 5m.
+	Interval Duration `json:"interval,omitempty"`
+
+	// Baseline is the rolling period of the usual volume, averaged by interval. Defaults to 24h.
+	Baseline Duration `json:"baseline,omitempty"`
+
+	// Factor is how many times the usual volume of an interval is unusual. Defaults to 5.
+	Factor float64 `json:"factor,omitempty"`
+
+	// MinEvents is the number of events in an interval below which the volume is never unusual. Defaults to 20.
+	MinEvents int `json:"min_events,omitempty"`
+
+	// URL is the external URL of Strillone, such as https://strillone.example.com, to link the alerts
+	// to the burst in the dashboard, optional.
+	URL string `json:"url,omitempty"`
+}
+
+func (c *AnomalyConfig) validate(destinations map[string]bool) error {
+	if c == nil {
+		return nil
+	}
+	if len(c.Destinations) == 0 {
+		return errors.New("anomalies: missing destinations")
+	}
+	for _, name := range c.Destinations {
+		if !destinations[name] {
+			return fmt.Errorf("anomalies: unknown destination %q", name)
+		}
+	}
+	if c.Interval < 0 || c.Baseline < 0 || c.Factor < 0 || c.MinEvents < 0 {
+		return errors.New("anomalies: interval, baseline, factor and min_events must be positive")
+	}
+	if c.baseline() < 2*c.interval() {
+		return errors.New("anomalies: the baseline must be at least two intervals")
+	}
+	if c.Factor != 0 && c.Factor <= 1 {
+		return errors.New("anomalies: factor must be greater than 1")
+	}
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("anomalies: invalid url %q", c.URL)
+		}
+	}
+	return nil
+}
+
+func (c *AnomalyConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return defaultAnomalyInterval
+	}
+	return time.Duration(c.Interval)
+}
+
+func (c *AnomalyConfig) baseline() time.Duration {
+	if c.Baseline == 0 {
+		return defaultAnomalyBaseline
+	}
+	return time.Duration(c.Baseline)
+}
+
+func (c *AnomalyConfig) factor() float64 {
+	if c.Factor == 0 {
+		return defaultAnomalyFactor
+	}
+	return c.Factor
+}
+
+func (c *AnomalyConfig) minEvents() int {
+	if c.MinEvents == 0 {
+		return defaultAnomalyMinEvents
+	}
+	return c.MinEvents
+}
+
+// eventVolume counts the events of an account or of a zone by interval.
+type eventVolume struct {
+	// history are the counts of the past intervals of the baseline, the oldest first,
+	// and count the one of the interval starting at start.
+	history []int
+	start   time.Time
+	count   int
+
+	// observed is the number of intervals observed, up to the length of the baseline.
+	observed int
+
+	// alerted is true if the interval was already alerted.
+	alerted bool
+}
+
+// advance moves the counts to the interval of now.
+func (v *eventVolume) advance(now time.Time, interval time.Duration) {
+	current := now.Truncate(interval)
+	for steps := 0; v.start.Before(current); steps++ {
+		if steps == len(v.history) {
+			// idle for the whole baseline
+			for i := range v.history {
+				v.history[i] = 0
+			}
+			v.start = current
+			break
+		}
+		copy(v.history, v.history[1:])
+		v.history[len(v.history)-1] = v.count
+		v.count, v.alerted = 0, false
+		v.start = v.start.Add(interval)
+		if v.observed < len(v.history) {
+			v.observed++
+		}
+	}
+}
+
+// average returns the average count of the observed intervals of the baseline.
+func (v *eventVolume) average() float64 {
+	if v.observed == 0 {
+		return 0
+	}
+	sum := 0
+	for _, count := range v.history[len(v.history)-v.observed:] {
+		sum += count
+	}
+	return float64(sum) / float64(v.observed)
+}
+
+// eventVolumes are the volumes of the events, by tenant, account and zone.
+type eventVolumes struct {
+	mu      sync.Mutex
+	volumes map[string]*eventVolume
+}
+
+func newEventVolumes() *eventVolumes {
+	return &eventVolumes{volumes: map[string]*eventVolume{}}
+}
+
+// anomaly is an unusual volume of events of an account or of a zone.
+type anomaly struct {
+	account *webhook.Account
+	domain  string
+	count   int
+	average float64
+	since   time.Time
+}
+
+// observe counts an event of the account and of the domain, and returns the volumes becoming unusual.
+func (e *eventVolumes) observe(tenant string, account *webhook.Account, domain string, config *AnomalyConfig, now time.Time) []anomaly {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.volumes) >= maxAnomalyCounters {
+		e.prune(now, config.baseline())
+	}
+
+	scopes := []string{""}
+	if domain != "" {
+		scopes = append(scopes, domain)
+	}
+
+	var anomalies []anomaly
+	interval := config.interval()
+	intervals := int(config.baseline() / interval)
+	for _, domain := range scopes {
+		key := tenant + "/" + strconv.FormatInt(account.ID, 10) + "/" + domain
+		volume, ok := e.volumes[key]
+		if !ok || len(volume.history) != intervals {
+			volume = &eventVolume{history: make([]int, intervals), start: now.Truncate(interval)}
+			e.volumes[key] = volume
+		}
+		volume.advance(now, interval)
+		volume.count++
+
+		average := volume.average()
+		if volume.alerted || volume.count < config.minEvents() || float64(volume.count) <= config.factor()*average {
+			continue
+		}
+		volume.alerted = true
+		anomalies = append(anomalies, anomaly{account: account, domain: domain, count: volume.count, average: average, since: volume.start})
+	}
+	return anomalies
+}
+
+// prune forgets the volumes idle for longer than the baseline.
+func (e *eventVolumes) prune(now time.Time, baseline time.Duration) {
+	for key, volume := range e.volumes {
+		if now.Sub(volume.start) > baseline {
+			delete(e.volumes, key)
+		}
+	}
+}
+
+// detectAnomalies counts the event, and alerts the destinations of the anomalies when its volume is unusual.
+func (s *Server) detectAnomalies(ctx context.Context, routing *routingTable, event *webhook.Event, now time.Time) {
+	config := routing.config.Anomalies
+	if config == nil || event.Account == nil {
+		return
+	}
+	for _, anomaly := range s.volumes.observe(routing.name, event.Account, strings.ToLower(eventDomain(event)), config, now) {
+		withEvent(log.Warn(), event).Str("tenant", routing.name).Str("domain", anomaly.domain).Int("events", anomaly.count).Float64("average", anomaly.average).Msg("Unusual event volume")
+		link := anomalyLink(config.URL, routing.name, anomaly, now)
+		for _, name := range config.Destinations {
+			service := routing.services[name]
+			if err := routing.postMessage(ctx, service, anomalyAlert(unwrapService(service), anomaly, config.interval(), link)); err != nil {
+				log.Error().Err(err).Str("tenant", routing.name).Str("destination", name).Msg("Error sending the anomaly alert")
+			}
+		}
+	}
+}
+
+// anomalyAlert returns the message of the anomaly, with the link to the burst in the dashboard if set.
+func anomalyAlert(s Formatter, anomaly anomaly, interval time.Duration, link string) string {
+	scope := tprintf(s, "the account %s", escape(s, accountDisplay(s, anomaly.account, "")))
+	if anomaly.domain != "" {
+		scope = escape(s, anomaly.domain)
+	}
+	text := tprintf(s, "Unusual event volume: %d events about %s in %s, instead of %s on average", anomaly.count, scope, shortDuration(interval), strconv.FormatFloat(anomaly.average, 'f', 1, 64))
+	if link == "" {
+		return text
+	}
+	return text + " " + s.FormatLink(translate(s, "Show in the dashboard"), link)
+}
+
+// anomalyLink returns the link to the events of the anomaly in the dashboard, empty without the URL of Strillone.
+func anomalyLink(base, tenant string, anomaly anomaly, now time.Time) string {
+	if base == "" {
+		return ""
+	}
+	query := url.Values{}
+	if tenant != "" {
+		query.Set("tenant", tenant)
+	}
+	if anomaly.domain != "" {
+		query.Set("domain", anomaly.domain)
+	}
+	query.Set("since", anomaly.since.UTC().Format(time.RFC3339))
+	query.Set("until", now.Add(time.Second).UTC().Format(time.RFC3339))
+	return strings.TrimSuffix(base, "/") + "/dashboard?" + query.Encode()
+}
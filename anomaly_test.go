@@ -0,0 +1,109 @@
+package strillone
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestEventVolumes_observe(t *testing.T) {
+	volumes := newEventVolumes()
+	config := &AnomalyConfig{Interval: Duration(time.Minute), Baseline: Duration(10 * time.Minute), Factor: 3, MinEvents: 5}
+	account := &webhook.Account{Account: dnsimple.Account{ID: 1010}}
+	start, _ := time.Parse(time.RFC3339, "2021-03-03T10:00:00Z")
+
+	// 4 events per minute for 10 minutes
+	for minute := 0; minute < 10; minute++ {
+		for i := 0; i < 4; i++ {
+			if anomalies := volumes.observe("", account, "example.com", config, start.Add(time.Duration(minute)*time.Minute)); len(anomalies) != 0 {
+				t.Fatalf("observe expected no anomaly at minute %d, got %+v", minute, anomalies)
+			}
+		}
+	}
+
+	// 13 events in the next minute
+	now := start.Add(10 * time.Minute)
+	var anomalies []anomaly
+	for i := 0; i < 13; i++ {
+		anomalies = append(anomalies, volumes.observe("", account, "example.com", config, now)...)
+	}
+	if want, got := 2, len(anomalies); want != got {
+		t.Fatalf("observe expected %v anomalies, got %+v", want, anomalies)
+	}
+	if want, got := (anomaly{account: account, count: 13, average: 4, since: now}), anomalies[0]; want != got {
+		t.Errorf("observe expected %+v, got %+v", want, got)
+	}
+	if want, got := "example.com", anomalies[1].domain; want != got {
+		t.Errorf("observe expected an anomaly of %v, got %v", want, got)
+	}
+
+	// a new zone is compared to nothing, but needs the minimum of events
+	for i := 0; i < 4; i++ {
+		if anomalies := volumes.observe("", &webhook.Account{Account: dnsimple.Account{ID: 2020}}, "", config, now); len(anomalies) != 0 {
+			t.Errorf("observe expected no anomaly below the minimum, got %+v", anomalies)
+		}
+	}
+	if anomalies := volumes.observe("", &webhook.Account{Account: dnsimple.Account{ID: 2020}}, "", config, now); len(anomalies) != 1 {
+		t.Errorf("observe expected an anomaly at the minimum, got %+v", anomalies)
+	}
+
+	// after the baseline without events, the volume starts over
+	later := now.Add(time.Hour)
+	volumes.observe("", account, "", config, later)
+	if volume := volumes.volumes["/1010/"]; volume.average() != 0 || !volume.start.Equal(later) {
+		t.Errorf("observe expected the volume to start over, got %+v", volume)
+	}
+}
+
+func TestAnomalyConfig_validate(t *testing.T) {
+	destinations := map[string]bool{"ops": true}
+	if err := (&AnomalyConfig{Destinations: []string{"ops"}, URL: "https://strillone.example.com"}).validate(destinations); err != nil {
+		t.Errorf("validate returned error: %v", err)
+	}
+	tests := []*AnomalyConfig{
+		{},
+		{Destinations: []string{"pager"}},
+		{Destinations: []string{"ops"}, Interval: Duration(time.Hour), Baseline: Duration(time.Hour)},
+		{Destinations: []string{"ops"}, Factor: 0.5},
+		{Destinations: []string{"ops"}, MinEvents: -1},
+		{Destinations: []string{"ops"}, URL: "strillone.example.com"},
+	}
+	for _, test := range tests {
+		if err := test.validate(destinations); err == nil {
+			t.Errorf("validate(%+v) expected error", test)
+		}
+	}
+}
+
+func TestServer_detectAnomalies(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "security", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [],
+		"anomalies": {"destinations": ["security"], "min_events": 3, "url": "https://strillone.example.com/"}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	security := &failingService{}
+	server.routing.services["security"] = security
+
+	event, _ := webhook.ParseEvent([]byte(`{"name": "zone_record.delete", "data": {"zone_record": {"id": 1, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`))
+	now, _ := time.Parse(time.RFC3339, "2021-03-03T10:02:00Z")
+	for i := 0; i < 5; i++ {
+		server.detectAnomalies(context.Background(), server.routing, event, now)
+	}
+	if want, got := 2, len(security.messages); want != got {
+		t.Fatalf("detectAnomalies expected %v alerts, got %v", want, got)
+	}
+	if want, got := "Unusual event volume: 3 events about the account User in 5m, instead of 0.0 on average <https://strillone.example.com/dashboard?since=2021-03-03T10%3A00%3A00Z&until=2021-03-03T10%3A02%3A01Z|Show in the dashboard>", security.messages[0]; want != got {
+		t.Errorf("detectAnomalies expected %q, got %q", want, got)
+	}
+	if want, got := "Unusual event volume: 3 events about example.com in 5m", security.messages[1]; !strings.HasPrefix(got, want) || !strings.Contains(got, "domain=example.com") {
+		t.Errorf("detectAnomalies expected %q with the domain in the link, got %q", want, got)
+	}
+}
@@ -0,0 +1,85 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+)
+
+// defaultAPITimeout is the timeout of the requests to the DNSimple API.
+const defaultAPITimeout = 5 * time.Second
+
+// APIConfig configures the client of the DNSimple API, used to complete the events
+// with the details missing from the webhooks.
+type APIConfig struct {
+	// Token is an account access token, or a reference to a secret.
+	Token string `json:"token"`
+
+	// URL is the base URL of the API, such as https://api.sandbox.dnsimple.com for the sandbox accounts.
+	// Defaults to https://api.dnsimple.com.
+	URL string `json:"url,omitempty"`
+
+	// Timeout is the timeout of the requests. Defaults to 5s.
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// RateLimitReserve is the number of requests of the hourly rate limit left to the other tools:
+	// once the remaining requests reach it, the requests wait for the reset of the limit.
+	// Defaults to 10% of the rate limit.
+	RateLimitReserve int `json:"rate_limit_reserve,omitempty"`
+
+	// Cache configures the cache of the details completing the events, optional.
+	Cache *APICacheConfig `json:"cache,omitempty"`
+}
+
+func (c *APIConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Token == "" {
+		return fmt.Errorf("api: missing token")
+	}
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("api: invalid url %q", c.URL)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("api: timeout must be positive")
+	}
+	if c.RateLimitReserve < 0 {
+		return fmt.Errorf("api: rate limit reserve must be positive")
+	}
+	if err := c.Cache.validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newAPIClient returns the client of the DNSimple API, nil if the API is not configured.
+func newAPIClient(c *APIConfig, secrets *Secrets) (*dnsimple.Client, error) {
+	if c == nil {
+		return nil, nil
+	}
+	token, err := secrets.Resolve(c.Token)
+	if err != nil {
+		return nil, fmt.Errorf("api: %v", err)
+	}
+
+	timeout := defaultAPITimeout
+	if c.Timeout > 0 {
+		timeout = time.Duration(c.Timeout)
+	}
+	// The timeout is applied by the transport, after the waits for the rate limit.
+	httpClient := dnsimple.StaticTokenHTTPClient(context.Background(), token)
+	httpClient.Transport = newAPIRateLimitTransport(httpClient.Transport, timeout, c.RateLimitReserve)
+	client := dnsimple.NewClient(httpClient)
+	client.SetUserAgent(Program)
+	if c.URL != "" {
+		client.BaseURL = c.URL
+	}
+	return client, nil
+}
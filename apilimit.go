@@ -0,0 +1,118 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultAPIRateLimitReserve is the share of the hourly API rate limit left to the other tools, by default.
+const defaultAPIRateLimitReserve = 0.1
+
+// errAPIRateLimited is returned by the requests to the DNSimple API that can't wait for the reset of the rate limit.
+var errAPIRateLimited = errors.New("api: rate limit reserve reached")
+
+// apiRateLimitTransport paces the requests to the DNSimple API with the X-RateLimit-* headers of the responses:
+// when the remaining requests reach the reserve, the requests wait for the reset of the limit,
+// or the end of their context. The wait is not counted in the timeout of the requests.
+type apiRateLimitTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+
+	// reserve is the number of requests left to the other tools, 0 for a share of the limit.
+	reserve int
+
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func newAPIRateLimitTransport(base http.RoundTripper, timeout time.Duration, reserve int) *apiRateLimitTransport {
+	return &apiRateLimitTransport{base: base, timeout: timeout, reserve: reserve, sleep: sleepContext, remaining: -1}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *apiRateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	now := time.Now()
+	if wait := t.wait(now); wait > 0 {
+		// The requests that can't wait long enough, such as the enrichments, fail right away.
+		if deadline, ok := req.Context().Deadline(); ok && now.Add(wait).After(deadline) {
+			return nil, errAPIRateLimited
+		}
+		log.Warn().Dur("wait", wait).Str("path", req.URL.Path).Msg("Waiting for the reset of the API rate limit")
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	t.update(resp, time.Now())
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// wait returns how long the next request waits at now, 0 if the remaining requests are above the reserve.
+func (t *apiRateLimitTransport) wait(now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.remaining < 0 || !now.Before(t.reset) {
+		return 0
+	}
+	reserve := t.reserve
+	if reserve == 0 {
+		reserve = int(float64(t.limit) * defaultAPIRateLimitReserve)
+	}
+	if t.remaining > reserve {
+		return 0
+	}
+	return t.reset.Sub(now)
+}
+
+// update records the rate limit of the response.
+func (t *apiRateLimitTransport) update(resp *http.Response, now time.Time) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		remaining = 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limit = limit
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+}
+
+// cancelingBody cancels the context of the request once its response is read.
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,64 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAPIRateLimitTransport(t *testing.T) {
+	reset := time.Now().Add(30 * time.Minute).Unix()
+	var remaining = 12
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining--
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		fmt.Fprint(w, `{"data": []}`)
+	}))
+	defer api.Close()
+
+	transport := newAPIRateLimitTransport(http.DefaultTransport, time.Second, 0)
+	var waits []time.Duration
+	transport.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	client := &http.Client{Transport: transport}
+	get := func(ctx context.Context) error {
+		request, _ := http.NewRequestWithContext(ctx, "GET", api.URL, nil)
+		resp, err := client.Do(request)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// 11 then 10 requests remaining: the second one reaches the reserve of 10%.
+	for i := 0; i < 2; i++ {
+		if err := get(context.Background()); err != nil {
+			t.Fatalf("request #%d returned error: %v", i, err)
+		}
+	}
+	if len(waits) != 0 {
+		t.Errorf("expected no wait above the reserve, got %v", waits)
+	}
+
+	if err := get(context.Background()); err != nil {
+		t.Fatalf("request returned error: %v", err)
+	}
+	if len(waits) != 1 || waits[0] < 29*time.Minute || waits[0] > 30*time.Minute {
+		t.Errorf("expected a wait for the reset of the limit, got %v", waits)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := get(ctx); !errors.Is(err, errAPIRateLimited) {
+		t.Errorf("request with a short deadline expected %v, got %v", errAPIRateLimited, err)
+	}
+}
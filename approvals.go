@@ -0,0 +1,486 @@
+package strillone
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultApprovalTimeout is the time after which an approval request not acknowledged is escalated.
+	defaultApprovalTimeout = 30 * time.Minute
+
+	// defaultApprovalMaxEscalations is the number of escalations of an approval request not acknowledged.
+	defaultApprovalMaxEscalations = 3
+)
+
+// ApprovalsConfig configures the events held for an approval in Slack, with Approve and Acknowledge buttons.
+type ApprovalsConfig struct {
+	// SigningSecret is the signing secret of the Slack app receiving the button clicks, to verify the requests.
+	SigningSecret string `json:"signing_secret"`
+
+	// Rules are the events held for an approval.
+	Rules []ApprovalRule `json:"rules"`
+}
+
+// ApprovalRule holds the matching events for an approval, and escalates them until they are acknowledged.
+type ApprovalRule struct {
+	Name string `json:"name"`
+
+	// Events are the event name patterns matched by the rule, using the path.Match syntax (e.g. "zone_record.*").
+	Events []string `json:"events"`
+
+	// Zones are the domain or zone name patterns matched by the rule, using the path.Match syntax.
+	// An empty list matches every domain.
+	Zones []string `json:"zones,omitempty"`
+
+	// RecordTypes are the types of the records matched by the rule for the zone_record.* events (e.g. NS).
+	// An empty list matches every type.
+	RecordTypes []string `json:"record_types,omitempty"`
+
+	// Destination is the name of the Slack destination asked for the approval.
+	Destination string `json:"destination"`
+
+	// Escalation are the names of the Slack destinations notified when the approval is not acknowledged in time.
+	// Defaults to the destination.
+	Escalation []string `json:"escalation,omitempty"`
+
+	// Timeout is the time after which the approval is escalated. Defaults to 30m.
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// MaxEscalations is the number of escalations, after which the approval is dropped. Defaults to 3.
+	MaxEscalations int `json:"max_escalations,omitempty"`
+}
+
+func (c *ApprovalsConfig) validate(destinations []DestinationConfig) error {
+	if c == nil {
+		return nil
+	}
+	if c.SigningSecret == "" {
+		return fmt.Errorf("approvals: missing signing secret")
+	}
+	types := make(map[string]string, len(destinations))
+	for _, d := range destinations {
+		types[d.Name] = d.Type
+	}
+	names := make(map[string]bool, len(c.Rules))
+	for i, rule := range c.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("approval #%d: missing name", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("approval %q: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+		if len(rule.Events) == 0 {
+			return fmt.Errorf("approval %q: missing events", rule.Name)
+		}
+		for _, pattern := range append(append([]string{}, rule.Events...), rule.Zones...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("approval %q: invalid pattern %q", rule.Name, pattern)
+			}
+		}
+		for _, name := range append([]string{rule.Destination}, rule.Escalation...) {
+			switch types[name] {
+			case "slack":
+			case "":
+				return fmt.Errorf("approval %q: unknown destination %q", rule.Name, name)
+			default:
+				return fmt.Errorf("approval %q: destination %q is not a Slack destination", rule.Name, name)
+			}
+		}
+		if rule.Timeout < 0 || rule.MaxEscalations < 0 {
+			return fmt.Errorf("approval %q: timeout and max escalations must be positive", rule.Name)
+		}
+	}
+	return nil
+}
+
+// matches returns true if the event is held by the rule.
+func (r *ApprovalRule) matches(e *webhook.Event) bool {
+	if !matchesAny(r.Events, e.Name) {
+		return false
+	}
+	if len(r.Zones) > 0 && !matchesAny(r.Zones, eventDomain(e)) {
+		return false
+	}
+	if len(r.RecordTypes) > 0 {
+		data := parseZoneRecordEvent(e)
+		if data == nil || data.ZoneRecord == nil {
+			return false
+		}
+		for _, recordType := range r.RecordTypes {
+			if strings.EqualFold(recordType, data.ZoneRecord.Type) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (r *ApprovalRule) timeout() time.Duration {
+	if r.Timeout == 0 {
+		return defaultApprovalTimeout
+	}
+	return time.Duration(r.Timeout)
+}
+
+func (r *ApprovalRule) maxEscalations() int {
+	if r.MaxEscalations == 0 {
+		return defaultApprovalMaxEscalations
+	}
+	return r.MaxEscalations
+}
+
+func (r *ApprovalRule) escalation() []string {
+	if len(r.Escalation) == 0 {
+		return []string{r.Destination}
+	}
+	return r.Escalation
+}
+
+// matchesAny returns true if the value matches one of the path.Match patterns.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Approver is implemented by the messaging services asking for an approval, with buttons sending
+// the approval ID back: Approve and Acknowledge, or only Acknowledge when approve is false.
+// It returns the timestamp of the message, empty if unknown, to acknowledge it with a reaction.
+type Approver interface {
+	PostApproval(ctx context.Context, text, approvalID string, approve bool) (string, error)
+}
+
+// pendingApproval is an event held for an approval, or waiting for the acknowledgement of an escalation policy.
+type pendingApproval struct {
+	id          string
+	tenant      string
+	rule        ApprovalRule
+	event       *webhook.Event
+	deadline    time.Time
+	escalations int
+
+	// acknowledgement is true for the events of the escalation policies, that are delivered
+	// to the escalation destinations instead of being asked again.
+	acknowledgement bool
+
+	// ts is the timestamp of the message asking for the acknowledgement.
+	ts string
+}
+
+// approvals are the events held for an approval, by approval ID.
+type approvals struct {
+	mu      sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+func newApprovals() *approvals {
+	return &approvals{pending: map[string]*pendingApproval{}}
+}
+
+// add holds the event of the tenant for the rule, and returns the approval.
+func (a *approvals) add(tenant string, rule ApprovalRule, acknowledgement bool, event *webhook.Event, now time.Time) *pendingApproval {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	approval := &pendingApproval{
+		id:              hex.EncodeToString(id),
+		tenant:          tenant,
+		rule:            rule,
+		event:           event,
+		deadline:        now.Add(rule.timeout()),
+		acknowledgement: acknowledgement,
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending[approval.id] = approval
+	return approval
+}
+
+// posted remembers the timestamp of the message asking for the approval.
+func (a *approvals) posted(id, ts string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if approval, ok := a.pending[id]; ok {
+		approval.ts = ts
+	}
+}
+
+// resolve releases the approval, and returns it, nil if unknown or already resolved.
+func (a *approvals) resolve(id string) *pendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval := a.pending[id]
+	delete(a.pending, id)
+	return approval
+}
+
+// acknowledgeMessage releases the acknowledgement asked by the message, and returns it, nil if none.
+func (a *approvals) acknowledgeMessage(ts string) *pendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, approval := range a.pending {
+		if ts != "" && approval.acknowledgement && approval.ts == ts {
+			delete(a.pending, id)
+			return approval
+		}
+	}
+	return nil
+}
+
+// due returns the approvals to escalate at now, and drops the ones escalated too many times.
+func (a *approvals) due(now time.Time) []*pendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var due []*pendingApproval
+	for id, approval := range a.pending {
+		if now.Before(approval.deadline) {
+			continue
+		}
+		if approval.escalations >= approval.rule.maxEscalations() {
+			withEvent(log.Warn(), approval.event).Str("approval", approval.rule.Name).Msg("Dropping approval: not acknowledged")
+			delete(a.pending, id)
+			continue
+		}
+		approval.escalations++
+		approval.deadline = now.Add(approval.rule.timeout())
+		due = append(due, approval)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].id < due[j].id })
+	return due
+}
+
+// requiresApproval returns true if a rule of the approvals matches the event.
+func (t *routingTable) requiresApproval(event *webhook.Event) bool {
+	if t.config.Approvals == nil {
+		return false
+	}
+	for _, rule := range t.config.Approvals.Rules {
+		if rule.matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdForApproval asks for the approval of the event to the destinations of the matching rules.
+func (s *Server) holdForApproval(ctx context.Context, routing *routingTable, event *webhook.Event) {
+	config := routing.config.Approvals
+	if config == nil {
+		return
+	}
+	for _, rule := range config.Rules {
+		if !rule.matches(event) {
+			continue
+		}
+		approval := s.approvals.add(routing.name, rule, false, event, time.Now())
+		withEvent(log.Info(), event).Str("approval", rule.Name).Str("approval_id", approval.id).Msg("Event held for approval")
+		routing.postApproval(ctx, rule.Destination, approval, func(s Formatter) string {
+			return tprintf(s, "Approval required: %s", Message(s, event))
+		})
+	}
+}
+
+// postApproval asks for the approval to the destination, and returns the timestamp of the message, empty if unknown.
+func (t *routingTable) postApproval(ctx context.Context, destination string, approval *pendingApproval, message func(s Formatter) string) string {
+	service := unwrapService(t.services[destination])
+	approver, ok := service.(Approver)
+	if !ok {
+		log.Error().Str("destination", destination).Msg("Error asking for approval: not a Slack destination")
+		return ""
+	}
+	ctx, cancel := t.deliveryContext(ctx)
+	defer cancel()
+	ts, err := approver.PostApproval(ctx, message(service), approval.id, !approval.acknowledgement)
+	if err != nil {
+		log.Error().Err(err).Str("destination", destination).Str("approval_id", approval.id).Msg("Error asking for approval")
+	}
+	return ts
+}
+
+// ProcessApprovals escalates the approvals not acknowledged in time, at every interval,
+// until done is closed or the server is shut down.
+func (s *Server) ProcessApprovals(interval time.Duration, done <-chan struct{}) {
+	s.workers.Add(1)
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.escalateApprovals(now)
+		}
+	}
+}
+
+// escalateApprovals asks again for the approvals due at now to their escalation destinations,
+// and delivers the events of the acknowledgements due to theirs.
+func (s *Server) escalateApprovals(now time.Time) {
+	for _, approval := range s.approvals.due(now) {
+		approval := approval
+		routing := s.currentRouting().tenantByName(approval.tenant)
+		if routing == nil {
+			continue
+		}
+		if approval.acknowledgement {
+			s.escalate(routing, approval)
+			continue
+		}
+		withEvent(log.Warn(), approval.event).Str("approval", approval.rule.Name).Int("escalation", approval.escalations).Msg("Escalating approval")
+		for _, name := range approval.rule.escalation() {
+			routing.postApproval(context.Background(), name, approval, func(s Formatter) string {
+				return tprintf(s, "Still not acknowledged after %s: %s", shortDuration(approval.rule.timeout()*time.Duration(approval.escalations)), Message(s, approval.event))
+			})
+		}
+	}
+}
+
+// shortDuration formats the duration without the zero units, e.g. 1h instead of 1h0m0s.
+func shortDuration(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// slackInteraction is the payload of a click on a button of a Slack message.
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	Message struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	ResponseURL string `json:"response_url"`
+}
+
+// SlackInteraction handles the clicks on the buttons of the Slack messages: the Approve and Acknowledge
+// buttons of the approvals and of the escalation policies, and the Confirm and Cancel buttons of the records created by slash command.
+func (s *Server) SlackInteraction(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Request")
+
+	routing := s.currentRouting()
+	if routing.config.Approvals == nil && routing.config.Commands == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.drain.enter() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.drain.leave()
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, routing.config.Inbound.maxBodySize()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var interaction slackInteraction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &interaction); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	// The interaction is verified with the signing secret of the feature of the button,
+	// since the approvals and the slash command can be different Slack apps.
+	var secret string
+	var handle func(ctx context.Context, routing *routingTable, interaction *slackInteraction)
+	if interaction.Type == "block_actions" && len(interaction.Actions) > 0 {
+		switch interaction.Actions[0].ActionID {
+		case "approve", "acknowledge":
+			if routing.config.Approvals != nil {
+				secret, handle = routing.approvalsSecret, s.resolveApproval
+			}
+		case recordConfirmAction, recordCancelAction:
+			if routing.config.Commands != nil && routing.api != nil {
+				secret, handle = routing.commandsSecret, s.confirmRecord
+			}
+		}
+	}
+	if handle == nil {
+		http.Error(w, "unknown interaction", http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackSignature(secret, r.Header, body, time.Now()); err != nil {
+		log.Warn().Err(err).Msg("Rejecting Slack interaction")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	handle(r.Context(), routing, &interaction)
+}
+
+// resolveApproval releases the approval of the button, and replaces the message with the name of the user who clicked.
+func (s *Server) resolveApproval(ctx context.Context, routing *routingTable, interaction *slackInteraction) {
+	action := interaction.Actions[0]
+	approval := s.approvals.resolve(action.Value)
+	if approval == nil {
+		log.Info().Str("approval_id", action.Value).Msg("Approval already resolved")
+		return
+	}
+	withEvent(log.Info(), approval.event).Str("approval", approval.rule.Name).Str("user_id", interaction.User.ID).Str("action", action.ActionID).Msg("Approval resolved")
+
+	service := unwrapService(routing.services[approval.rule.Destination])
+	if service == nil || interaction.ResponseURL == "" {
+		return
+	}
+	// The message is replaced through the response URL of the interaction.
+	text := interaction.Message.Text + " — " + approvalResolution(service, action.ActionID, interaction.User.ID)
+	reply := map[string]interface{}{"replace_original": true, "text": text}
+	ctx, cancel := routing.deliveryContext(ctx)
+	defer cancel()
+	if err := postJSON(ctx, defaultHTTPClient, interaction.ResponseURL, reply); err != nil {
+		log.Error().Err(err).Str("approval_id", approval.id).Msg("Error updating the approval message")
+	}
+}
+
+// approvalResolution describes the action of the user on the approval.
+func approvalResolution(s Formatter, actionID, userID string) string {
+	user := fmt.Sprintf("<@%s>", userID)
+	if actionID == "approve" {
+		return tprintf(s, "approved by %s", user)
+	}
+	return tprintf(s, "acknowledged by %s", user)
+}
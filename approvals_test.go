@@ -0,0 +1,134 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServer_Approvals(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]map[string]interface{}{}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], payload)
+		mu.Unlock()
+	}))
+	defer target.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "%[1]s/ops"},
+			{"name": "oncall", "type": "slack", "url": "%[1]s/oncall"}
+		],
+		"approvals": {
+			"signing_secret": "slack-secret",
+			"rules": [{"name": "delegation", "events": ["zone_record.*"], "zones": ["example.com"], "record_types": ["NS"], "destination": "ops", "escalation": ["oncall"]}]
+		}
+	}`, target.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	payload := `{"name": "zone_record.update", "request_identifier": "%s", "data": {"zone_record": {"id": 1, "zone_id": "example.com", "name": "", "type": "%s", "content": "ns1.example.net"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	for i, recordType := range []string{"A", "NS"} {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, fmt.Sprintf("a1b2c3d4-approval-0000-00000000000%d", i), recordType)))
+		server.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	mu.Lock()
+	requests := received["/ops"]
+	mu.Unlock()
+	if want, got := 1, len(requests); want != got {
+		t.Fatalf("expected %d approval request, got %d", want, got)
+	}
+	want := "Approval required: [<https://dnsimple.com/a/1010/account|User>] example@example.com updated the record <https://dnsimple.com/a/1010/domains/example.com/records/1|@ NS ns1.example.net (unchanged)> in example.com"
+	if got := requests[0]["text"]; want != got {
+		t.Errorf("approval request expected text %q, got %q", want, got)
+	}
+	var approvalID string
+	blocks, _ := json.Marshal(requests[0]["attachments"])
+	if !strings.Contains(string(blocks), `"action_id":"approve"`) || !strings.Contains(string(blocks), `"action_id":"acknowledge"`) {
+		t.Errorf("approval request expected Approve and Acknowledge buttons, got %s", blocks)
+	}
+	for id := range server.approvals.pending {
+		approvalID = id
+	}
+
+	// The approval is escalated when not acknowledged in time.
+	server.escalateApprovals(time.Now().Add(10 * time.Minute))
+	server.escalateApprovals(time.Now().Add(31 * time.Minute))
+	mu.Lock()
+	escalations := received["/oncall"]
+	mu.Unlock()
+	if want, got := 1, len(escalations); want != got {
+		t.Fatalf("expected %d escalation, got %d", want, got)
+	}
+	if got := escalations[0]["text"].(string); !strings.HasPrefix(got, "Still not acknowledged after 30m: ") {
+		t.Errorf("escalation expected the time not acknowledged, got %q", got)
+	}
+
+	// The approval is released by the button, and the message replaced.
+	interaction := fmt.Sprintf(`{"type": "block_actions", "user": {"id": "U0JANE"}, "actions": [{"action_id": "approve", "value": %q}], "message": {"text": "Approval required"}, "response_url": "%s/response"}`, approvalID, target.URL)
+	for i := 0; i < 2; i++ {
+		request := newSlackCommandRequest("slack-secret", url.Values{"payload": {interaction}})
+		request.URL.Path = "/interactions/slack"
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := http.StatusOK, response.Code; want != got {
+			t.Errorf("POST /interactions/slack expected HTTP %v, got %v", want, got)
+		}
+	}
+	mu.Lock()
+	replies := received["/response"]
+	mu.Unlock()
+	if want, got := 1, len(replies); want != got {
+		t.Fatalf("expected %d reply, got %d", want, got)
+	}
+	if want, got := "Approval required — approved by <@U0JANE>", replies[0]["text"]; want != got {
+		t.Errorf("reply expected text %q, got %q", want, got)
+	}
+	if len(server.approvals.pending) != 0 {
+		t.Errorf("expected no pending approvals, got %d", len(server.approvals.pending))
+	}
+}
+
+func TestApprovalsConfig_validate(t *testing.T) {
+	destinations := []DestinationConfig{{Name: "ops", Type: "slack"}, {Name: "pager", Type: "pagerduty"}}
+	rule := func(modify func(*ApprovalRule)) *ApprovalsConfig {
+		r := ApprovalRule{Name: "delegation", Events: []string{"zone_record.*"}, Destination: "ops"}
+		modify(&r)
+		return &ApprovalsConfig{SigningSecret: "slack-secret", Rules: []ApprovalRule{r}}
+	}
+	tests := []struct {
+		config *ApprovalsConfig
+		valid  bool
+	}{
+		{nil, true},
+		{rule(func(r *ApprovalRule) {}), true},
+		{&ApprovalsConfig{Rules: []ApprovalRule{{Name: "delegation", Events: []string{"*"}, Destination: "ops"}}}, false},
+		{rule(func(r *ApprovalRule) { r.Events = nil }), false},
+		{rule(func(r *ApprovalRule) { r.Zones = []string{"[example.com"} }), false},
+		{rule(func(r *ApprovalRule) { r.Destination = "unknown" }), false},
+		{rule(func(r *ApprovalRule) { r.Escalation = []string{"pager"} }), false},
+		{rule(func(r *ApprovalRule) { r.Timeout = -1 }), false},
+	}
+	for _, test := range tests {
+		err := test.config.validate(destinations)
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package strillone
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// maxArchiveEvents is the maximum number of events waiting to be archived.
+// When the uploads keep failing, the oldest events are dropped beyond it.
+const maxArchiveEvents = 100000
+
+// ArchiveStore stores the archive objects.
+type ArchiveStore interface {
+	Put(ctx context.Context, key string, body []byte) error
+}
+
+// S3ArchiveStore stores the archive objects in an S3 bucket,
+// or in any storage compatible with the S3 API, such as GCS with HMAC keys.
+type S3ArchiveStore struct {
+	Bucket      string
+	Credentials *AWSCredentials
+
+	// Endpoint overrides the regional S3 endpoint, e.g. https://storage.googleapis.com.
+	// The objects are then addressed with the bucket in the path.
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// Put implements ArchiveStore
+func (s *S3ArchiveStore) Put(ctx context.Context, key string, body []byte) error {
+	location := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Credentials.Region, key)
+	if s.Endpoint != "" {
+		location = fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.Endpoint, "/"), s.Bucket, key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", location, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	signAWSRequest(req, body, "s3", s.Credentials, time.Now())
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{StatusCode: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+	return nil
+}
+
+// OpenArchiveStore returns the store of the archive location, with the object key prefix.
+//
+// The location is s3://bucket/prefix, with the credentials of the standard AWS environment variables,
+// or gs://bucket/prefix, with the GCS HMAC key in GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET.
+func OpenArchiveStore(location string) (ArchiveStore, string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, "", fmt.Errorf("archive: %v", err)
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("archive: missing bucket in %q", location)
+	}
+	prefix := strings.Trim(u.Path, "/")
+
+	switch u.Scheme {
+	case "s3":
+		creds := AWSCredentialsFromEnv()
+		if creds == nil {
+			return nil, "", fmt.Errorf("archive: missing AWS credentials")
+		}
+		return &S3ArchiveStore{Bucket: u.Host, Credentials: creds}, prefix, nil
+	case "gs":
+		creds := &AWSCredentials{
+			Region:          "auto",
+			AccessKeyID:     os.Getenv("GCS_HMAC_ACCESS_ID"),
+			SecretAccessKey: os.Getenv("GCS_HMAC_SECRET"),
+		}
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return nil, "", fmt.Errorf("archive: missing GCS HMAC key")
+		}
+		return &S3ArchiveStore{Bucket: u.Host, Credentials: creds, Endpoint: "https://storage.googleapis.com"}, prefix, nil
+	}
+	return nil, "", fmt.Errorf("archive: unsupported location %q", location)
+}
+
+// archiveLine is a line of the archive objects.
+type archiveLine struct {
+	Tenant     string          `json:"tenant,omitempty"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// archiveBuffer holds the lines waiting to be archived.
+type archiveBuffer struct {
+	mu    sync.Mutex
+	lines [][]byte
+}
+
+// add appends a line.
+func (b *archiveBuffer) add(line []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	b.trim()
+}
+
+// take removes and returns all the lines.
+func (b *archiveBuffer) take() [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lines := b.lines
+	b.lines = nil
+	return lines
+}
+
+// restore puts back lines that could not be archived, before the ones added since.
+func (b *archiveBuffer) restore(lines [][]byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(lines, b.lines...)
+	b.trim()
+}
+
+// trim drops the oldest lines beyond the limit. The lock must be held.
+func (b *archiveBuffer) trim() {
+	if len(b.lines) > maxArchiveEvents {
+		log.Warn().Int("dropped", len(b.lines)-maxArchiveEvents).Msg("Archive full, dropping the oldest events")
+		b.lines = b.lines[len(b.lines)-maxArchiveEvents:]
+	}
+}
+
+// SetArchive archives the raw payload of every received event to the store,
+// in gzipped NDJSON objects whose keys start with the prefix.
+// The objects are uploaded by ProcessArchive, and on shutdown.
+func (s *Server) SetArchive(store ArchiveStore, prefix string) {
+	s.archiveStore = store
+	s.archivePrefix = prefix
+}
+
+// archiveEvent adds the received event to the next archive object.
+func (s *Server) archiveEvent(routing *routingTable, event *webhook.Event) {
+	if s.archiveStore == nil {
+		return
+	}
+
+	line, err := json.Marshal(&archiveLine{
+		Tenant:     routing.name,
+		ReceivedAt: time.Now().UTC(),
+		Payload:    event.GetPayload(),
+	})
+	if err != nil {
+		withEvent(log.Error(), event).Err(err).Msg("Error archiving the event")
+		return
+	}
+	s.archive.add(line)
+}
+
+// ProcessArchive uploads the archived events every interval, until done is closed or the server shuts down.
+func (s *Server) ProcessArchive(interval time.Duration, done <-chan struct{}) {
+	s.workers.Add(1)
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.flushArchive(context.Background(), now)
+		}
+	}
+}
+
+// flushArchive uploads the archived events in an object named after now.
+// The events are kept for the next upload when it fails.
+func (s *Server) flushArchive(ctx context.Context, now time.Time) error {
+	lines := s.archive.take()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	for _, line := range lines {
+		zw.Write(line)
+		zw.Write([]byte("\n"))
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	key := archiveKey(s.archivePrefix, now)
+	if err := s.archiveStore.Put(ctx, key, body.Bytes()); err != nil {
+		log.Error().Err(err).Str("key", key).Int("events", len(lines)).Msg("Error uploading the archive")
+		s.archive.restore(lines)
+		return err
+	}
+	log.Info().Str("key", key).Int("events", len(lines)).Msg("Archive uploaded")
+	return nil
+}
+
+// archiveKey returns the key of the archive object uploaded at now, partitioned by day.
+func archiveKey(prefix string, now time.Time) string {
+	now = now.UTC()
+	key := now.Format("2006/01/02/20060102T150405.000000000Z") + ".ndjson.gz"
+	if prefix != "" {
+		key = prefix + "/" + key
+	}
+	return key
+}
@@ -0,0 +1,120 @@
+package strillone
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryArchiveStore struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (s *memoryArchiveStore) Put(ctx context.Context, key string, body []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.objects[key] = body
+	return nil
+}
+
+func TestS3ArchiveStore(t *testing.T) {
+	var path, encoding string
+	var body []byte
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		path, encoding = r.URL.Path, r.Header.Get("Content-Encoding")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer s3.Close()
+
+	store := &S3ArchiveStore{
+		Bucket:      "audit",
+		Credentials: &AWSCredentials{Region: "auto", AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Endpoint:    s3.URL,
+	}
+	if err := store.Put(context.Background(), "dns/events.ndjson.gz", []byte("data")); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if want, got := "/audit/dns/events.ndjson.gz", path; want != got {
+		t.Errorf("Put expected path %v, got %v", want, got)
+	}
+	if want, got := "gzip", encoding; want != got {
+		t.Errorf("Put expected encoding %v, got %v", want, got)
+	}
+	if want, got := "data", string(body); want != got {
+		t.Errorf("Put expected body %v, got %v", want, got)
+	}
+
+	store.Credentials.AccessKeyID = "other"
+	if err := store.Put(context.Background(), "dns/events.ndjson.gz", []byte("data")); err == nil {
+		t.Errorf("Put with invalid credentials expected error")
+	}
+}
+
+func TestServer_Archive(t *testing.T) {
+	server := NewServer(nil)
+	store := &memoryArchiveStore{objects: map[string][]byte{}, err: errors.New("unavailable")}
+	server.SetArchive(store, "dns")
+
+	for _, id := range []string{"1", "2"} {
+		payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "5c9a2e7f-archive-` + id + `"}`
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+		server.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	now := time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+	if err := server.flushArchive(context.Background(), now); err == nil {
+		t.Fatalf("flushArchive expected error")
+	}
+
+	store.err = nil
+	if err := server.flushArchive(context.Background(), now); err != nil {
+		t.Fatalf("flushArchive returned error: %v", err)
+	}
+	object, ok := store.objects["dns/2021/03/01/20210301T123000.000000000Z.ndjson.gz"]
+	if !ok {
+		t.Fatalf("flushArchive expected the object to be uploaded, got %v", store.objects)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(object))
+	if err != nil {
+		t.Fatalf("archive is not gzipped: %v", err)
+	}
+	var ids []string
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var line struct {
+			Payload struct {
+				RequestID string `json:"request_identifier"`
+			} `json:"payload"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("archive line is invalid JSON: %v", err)
+		}
+		ids = append(ids, line.Payload.RequestID)
+	}
+	if want, got := "5c9a2e7f-archive-1,5c9a2e7f-archive-2", strings.Join(ids, ","); want != got {
+		t.Errorf("archive expected events %v, got %v", want, got)
+	}
+
+	if err := server.flushArchive(context.Background(), now.Add(time.Minute)); err != nil {
+		t.Fatalf("flushArchive returned error: %v", err)
+	}
+	if want, got := 1, len(store.objects); want != got {
+		t.Errorf("flushArchive without events expected %v objects, got %v", want, got)
+	}
+}
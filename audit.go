@@ -0,0 +1,348 @@
+package strillone
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// headerAuditActor is the optional request header naming who makes an admin request, since the admin token is shared.
+// It is set by the client, so it is recorded apart from the authenticated actor.
+const headerAuditActor = "X-Strillone-Actor"
+
+// sharedTokenActor is the actor of the requests authenticated with the shared admin token.
+const sharedTokenActor = "admin-token"
+
+// AuditEntry represents a change of the configuration.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+
+	// Source is how the configuration was changed: admin-api or reload.
+	Source string `json:"source"`
+
+	// Actor identifies who changed the configuration: the name of the API key of the admin request,
+	// admin-token for the shared admin token, or the configuration file reloaded.
+	Actor      string `json:"actor,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+
+	// ClaimedActor is the X-Strillone-Actor header of the admin request. It is set by the client, and isn't verified.
+	ClaimedActor string `json:"claimed_actor,omitempty"`
+
+	// Action is the admin operation, such as destination.update, or reload.
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+
+	// Changes describe the parts of the configuration that changed, without their values.
+	Changes []string `json:"changes"`
+}
+
+// AuditFilter restricts the entries returned by an AuditLog.
+type AuditFilter struct {
+	Action string
+	Since  time.Time
+	Until  time.Time
+
+	// Limit is the maximum number of entries returned, newest first.
+	Limit int
+}
+
+// Matches returns true if the entry matches the filter.
+func (f *AuditFilter) Matches(entry *AuditEntry) bool {
+	if f.Action != "" && f.Action != entry.Action {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditLog is an append-only log of the configuration changes.
+type AuditLog interface {
+	Record(entry *AuditEntry) error
+
+	// Query returns the entries matching the filter, newest first.
+	Query(filter *AuditFilter) ([]*AuditEntry, error)
+
+	Close() error
+}
+
+// FileAuditLog is an AuditLog appended to a file, one JSON entry per line.
+type FileAuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileAuditLog opens, or creates, the audit log in the file.
+func OpenFileAuditLog(filename string) (*FileAuditLog, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditLog{file: file}, nil
+}
+
+// Record implements AuditLog
+func (l *FileAuditLog) Record(entry *AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Query implements AuditLog
+func (l *FileAuditLog) Query(filter *AuditFilter) ([]*AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	var entries []*AuditEntry
+	scanner := bufio.NewScanner(l.file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := &AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, err
+		}
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	newest := make([]*AuditEntry, 0, filter.Limit)
+	for i := len(entries) - 1; i >= 0 && len(newest) < filter.Limit; i-- {
+		newest = append(newest, entries[i])
+	}
+	return newest, nil
+}
+
+// Close implements AuditLog
+func (l *FileAuditLog) Close() error {
+	return l.file.Close()
+}
+
+// SetAuditLog records the configuration changes made with the admin API or reloaded from the file
+// in the audit log, and enables the /admin/audit endpoint.
+func (s *Server) SetAuditLog(audit AuditLog) {
+	s.auditLog = audit
+}
+
+// adminAudit returns the audit entry of an admin request.
+func adminAudit(r *http.Request, action, target string) *AuditEntry {
+	return &AuditEntry{
+		Source:       "admin-api",
+		Actor:        requestActor(r),
+		RemoteAddr:   r.RemoteAddr,
+		ClaimedActor: r.Header.Get(headerAuditActor),
+		Action:       action,
+		Target:       target,
+	}
+}
+
+// requestActor returns the name of the key that authenticated the request, empty if none.
+func requestActor(r *http.Request) string {
+	key := requestAdminKey(r)
+	switch {
+	case key == nil:
+		return ""
+	case key.name == "":
+		return sharedTokenActor
+	default:
+		return key.name
+	}
+}
+
+// audit records the change of the configuration from before to after, if any.
+func (s *Server) audit(entry *AuditEntry, before, after *Config) {
+	entry.Time = time.Now().UTC()
+	entry.Changes = configChanges(before, after)
+	if len(entry.Changes) == 0 {
+		return
+	}
+
+	log.Info().
+		Str("source", entry.Source).
+		Str("actor", entry.Actor).
+		Str("claimed_actor", entry.ClaimedActor).
+		Str("action", entry.Action).
+		Str("target", entry.Target).
+		Strs("changes", entry.Changes).
+		Msg("Configuration changed")
+
+	if s.auditLog == nil {
+		return
+	}
+	if err := s.auditLog.Record(entry); err != nil {
+		log.Error().Err(err).Str("action", entry.Action).Msg("Error recording the configuration change")
+	}
+}
+
+// configChanges describes the differences between two configurations: the destinations,
+// routes and tenants added, removed or changed, and the other settings changed.
+// The values are left out, since they may be secrets.
+func configChanges(before, after *Config) []string {
+	if before == nil {
+		before = &Config{}
+	}
+	if after == nil {
+		after = &Config{}
+	}
+
+	var changes []string
+	named := func(kind string, before, after map[string][]byte) {
+		var names []string
+		for name := range before {
+			names = append(names, name)
+		}
+		for name := range after {
+			if _, ok := before[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prev, existed := before[name]
+			next, exists := after[name]
+			switch {
+			case !existed:
+				changes = append(changes, fmt.Sprintf("%s %s added", kind, name))
+			case !exists:
+				changes = append(changes, fmt.Sprintf("%s %s removed", kind, name))
+			case !bytes.Equal(prev, next):
+				changes = append(changes, fmt.Sprintf("%s %s changed", kind, name))
+			}
+		}
+	}
+
+	named("destination", destinationsByName(before.Destinations), destinationsByName(after.Destinations))
+	named("route", routesByName(before.Routes), routesByName(after.Routes))
+	tenantsBefore, tenantsAfter := map[string][]byte{}, map[string][]byte{}
+	for i := range before.Tenants {
+		tenantsBefore[before.Tenants[i].Name] = mustMarshal(&before.Tenants[i])
+	}
+	for i := range after.Tenants {
+		tenantsAfter[after.Tenants[i].Name] = mustMarshal(&after.Tenants[i])
+	}
+	named("tenant", tenantsBefore, tenantsAfter)
+
+	settings := []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"admin", before.Admin, after.Admin},
+		{"inbound", before.Inbound, after.Inbound},
+		{"health_destination", before.HealthDestination, after.HealthDestination},
+		{"operator_destination", before.OperatorDestination, after.OperatorDestination},
+		{"dedup_window", before.DedupWindow, after.DedupWindow},
+		{"severities", before.Severities, after.Severities},
+		{"templates", before.Templates, after.Templates},
+		{"dashboard_url", before.DashboardURL, after.DashboardURL},
+		{"presentation", before.Presentation, after.Presentation},
+		{"translations", before.Translations, after.Translations},
+		{"api", before.API, after.API},
+		{"accounts", before.Accounts, after.Accounts},
+		{"reminders", before.Reminders, after.Reminders},
+		{"certificate_monitor", before.CertificateMonitor, after.CertificateMonitor},
+		{"drift", before.Drift, after.Drift},
+		{"registrar_watchdog", before.RegistrarWatchdog, after.RegistrarWatchdog},
+		{"activity_report", before.ActivityReport, after.ActivityReport},
+		{"commands", before.Commands, after.Commands},
+		{"approvals", before.Approvals, after.Approvals},
+		{"timeouts", before.Timeouts, after.Timeouts},
+		{"environments", before.Environments, after.Environments},
+		{"egress", before.Egress, after.Egress},
+		{"escalations", before.Escalations, after.Escalations},
+		{"ownership", before.Ownership, after.Ownership},
+		{"silences", before.Silences, after.Silences},
+		{"redaction", before.Redaction, after.Redaction},
+		{"severity_presentation", before.SeverityPresentation, after.SeverityPresentation},
+		{"sampling", before.Sampling, after.Sampling},
+		{"change_windows", before.ChangeWindows, after.ChangeWindows},
+		{"anomalies", before.Anomalies, after.Anomalies},
+		{"security", before.Security, after.Security},
+		{"feed", before.Feed, after.Feed},
+	}
+	for _, setting := range settings {
+		if !bytes.Equal(mustMarshal(setting.before), mustMarshal(setting.after)) {
+			changes = append(changes, setting.name+" changed")
+		}
+	}
+	return changes
+}
+
+func destinationsByName(destinations []DestinationConfig) map[string][]byte {
+	byName := make(map[string][]byte, len(destinations))
+	for i := range destinations {
+		byName[destinations[i].Name] = mustMarshal(&destinations[i])
+	}
+	return byName
+}
+
+// routesByName indexes the routes by name, or by position for the unnamed ones.
+func routesByName(routes []RouteConfig) map[string][]byte {
+	byName := make(map[string][]byte, len(routes))
+	for i := range routes {
+		name := routes[i].Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		byName[name] = mustMarshal(&routes[i])
+	}
+	return byName
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// AdminListAudit returns the audit log entries, filtered by the action, since and until query parameters.
+func (s *Server) AdminListAudit(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if s.auditLog == nil {
+		writeJSONError(w, http.StatusNotFound, "audit log not enabled")
+		return
+	}
+
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	entries, err := s.auditLog.Query(&AuditFilter{
+		Action: r.URL.Query().Get("action"),
+		Since:  filter.Since,
+		Until:  filter.Until,
+		Limit:  filter.Limit,
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
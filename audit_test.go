@@ -0,0 +1,101 @@
+package strillone
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigChanges(t *testing.T) {
+	before := &Config{
+		Destinations: []DestinationConfig{{Name: "ops", Type: "slack", URL: "https://hooks.slack.com/services/A"}, {Name: "dev", Type: "slack"}},
+		Routes:       []RouteConfig{{Destinations: []string{"ops"}}},
+	}
+	after := before.Clone()
+	after.Destinations[0].URL = "https://hooks.slack.com/services/B"
+	after.Destinations = append(after.Destinations[:1], DestinationConfig{Name: "qa", Type: "slack"})
+	after.Admin.Token = "secret"
+	after.Silences = []Silence{{ID: "migration", Domains: []string{"example.com"}}}
+
+	expected := []string{"destination dev removed", "destination ops changed", "destination qa added", "admin changed", "silences changed"}
+	if want, got := strings.Join(expected, ","), strings.Join(configChanges(before, after), ","); want != got {
+		t.Errorf("configChanges expected %v, got %v", want, got)
+	}
+	if got := configChanges(before, before.Clone()); len(got) != 0 {
+		t.Errorf("configChanges of the same configuration expected no changes, got %v", got)
+	}
+}
+
+func TestServer_AuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	audit, err := OpenFileAuditLog(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatalf("OpenFileAuditLog returned error: %v", err)
+	}
+	defer audit.Close()
+
+	server, _ := newAdminTestServer()
+	if want, got := http.StatusNotFound, adminRequest(server, "GET", "/admin/audit", "").Code; want != got {
+		t.Errorf("GET /admin/audit without audit log expected HTTP %v, got %v", want, got)
+	}
+	server.SetAuditLog(audit)
+
+	request, _ := http.NewRequest("POST", "/admin/destinations", strings.NewReader(`{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}`))
+	request.Header.Set("Authorization", "Bearer secret")
+	request.Header.Set("X-Strillone-Actor", "alice")
+	server.ServeHTTP(httptest.NewRecorder(), request)
+
+	configPath := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configPath, []byte(`{"admin": {"token": "secret"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reloader := &ConfigReloader{Path: configPath, Server: server}
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+
+	response := adminRequest(server, "GET", "/admin/audit", "")
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("GET /admin/audit expected HTTP %v, got %v", want, got)
+	}
+	var entries []*AuditEntry
+	if err := json.Unmarshal(response.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("GET /admin/audit returned invalid JSON: %v", err)
+	}
+	if want, got := 2, len(entries); want != got {
+		t.Fatalf("GET /admin/audit expected %v entries, got %v", want, got)
+	}
+	if want, got := "reload", entries[0].Action; want != got {
+		t.Errorf("newest entry expected action %v, got %v", want, got)
+	}
+	if want, got := "destination ops removed", strings.Join(entries[0].Changes, ","); want != got {
+		t.Errorf("reload expected changes %v, got %v", want, got)
+	}
+	if want, got := "destination.create", entries[1].Action; want != got {
+		t.Errorf("oldest entry expected action %v, got %v", want, got)
+	}
+	if want, got := sharedTokenActor, entries[1].Actor; want != got {
+		t.Errorf("oldest entry expected the actor of the token %v, got %v", want, got)
+	}
+	if want, got := "alice", entries[1].ClaimedActor; want != got {
+		t.Errorf("oldest entry expected claimed actor %v, got %v", want, got)
+	}
+
+	response = adminRequest(server, "GET", "/admin/audit?action=reload", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("GET /admin/audit returned invalid JSON: %v", err)
+	}
+	if want, got := 1, len(entries); want != got {
+		t.Errorf("GET /admin/audit?action=reload expected %v entries, got %v", want, got)
+	}
+}
@@ -0,0 +1,160 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// batchSummaryMessages is the number of event messages included in a batch summary.
+const batchSummaryMessages = 5
+
+// eventBatch is a set of events waiting for the rate limit of a destination.
+type eventBatch struct {
+	tenant      string
+	destination string
+	events      []*webhook.Event
+}
+
+// eventBatches coalesces the events to the rate limited destinations, by tenant and name.
+type eventBatches struct {
+	mu      sync.Mutex
+	batches map[string]*eventBatch
+}
+
+func newEventBatches() *eventBatches {
+	return &eventBatches{batches: map[string]*eventBatch{}}
+}
+
+// rateLimit delivers the event if the rate limit of the destination allows it.
+// Otherwise the event is added to a batch, that is sent as a single summary message
+// as soon as the rate limit allows it. It returns false if the event was batched.
+func (s *Server) rateLimit(routing *routingTable, name string, event *webhook.Event, limit *RateLimitConfig) bool {
+	if limit == nil {
+		return true
+	}
+	key := routing.name + "/" + name
+
+	s.batches.mu.Lock()
+	defer s.batches.mu.Unlock()
+
+	// keep the order of the events while a batch is pending
+	if batch, ok := s.batches.batches[key]; ok {
+		batch.events = append(batch.events, event)
+		return false
+	}
+
+	ok, wait := s.outboundLimiter.Allow(key, limit)
+	if ok {
+		return true
+	}
+
+	withEvent(log.Info(), event).Str("tenant", routing.name).Str("destination", name).Str("outcome", "batched").Msg("Rate limit exceeded, batching the event")
+	s.batches.batches[key] = &eventBatch{tenant: routing.name, destination: name, events: []*webhook.Event{event}}
+	time.AfterFunc(wait, func() { s.flushBatch(key) })
+	return false
+}
+
+// flushBatch sends the batch as a single summary message, with the current configuration,
+// or waits again if the rate limit doesn't allow it yet.
+func (s *Server) flushBatch(key string) {
+	s.batches.mu.Lock()
+	batch, ok := s.batches.batches[key]
+	if !ok {
+		s.batches.mu.Unlock()
+		return
+	}
+
+	routing := s.currentRouting().tenantByName(batch.tenant)
+	var service MessagingService
+	var limit *RateLimitConfig
+	if routing != nil {
+		service = routing.services[batch.destination]
+		if i := findDestination(routing.config, batch.destination); i >= 0 {
+			limit = routing.config.Destinations[i].RateLimit
+		}
+	}
+
+	if service != nil {
+		if ok, wait := s.outboundLimiter.Allow(key, limit); !ok {
+			s.batches.mu.Unlock()
+			time.AfterFunc(wait, func() { s.flushBatch(key) })
+			return
+		}
+	}
+	delete(s.batches.batches, key)
+	s.batches.mu.Unlock()
+
+	s.sendBatch(key, batch, service)
+}
+
+// flushAllBatches sends all the pending batches, regardless of the rate limits.
+func (s *Server) flushAllBatches() {
+	s.batches.mu.Lock()
+	batches := s.batches.batches
+	s.batches.batches = map[string]*eventBatch{}
+	s.batches.mu.Unlock()
+
+	for key, batch := range batches {
+		var service MessagingService
+		if routing := s.currentRouting().tenantByName(batch.tenant); routing != nil {
+			service = routing.services[batch.destination]
+		}
+		s.sendBatch(key, batch, service)
+	}
+}
+
+// sendBatch sends the batch as a single summary message to the service.
+// The events are enqueued again if the summary fails.
+func (s *Server) sendBatch(key string, batch *eventBatch, service MessagingService) {
+	if service == nil {
+		log.Warn().Str("tenant", batch.tenant).Str("destination", batch.destination).Int("events", len(batch.events)).Msg("Dropping the batched events: unknown destination")
+		return
+	}
+
+	stats := s.stats.Tenant(batch.tenant)
+	if err := s.currentRouting().postMessage(context.Background(), service, batchSummary(service, batch.events, batchSummaryMessages)); err != nil {
+		log.Error().Err(err).Str("tenant", batch.tenant).Str("destination", batch.destination).Int("events", len(batch.events)).Msg("Error sending the batched events")
+		for range batch.events {
+			stats.failed()
+		}
+		s.requeue(batch.tenant, batch.destination, batch.events, err)
+		return
+	}
+	for range batch.events {
+		stats.delivered()
+	}
+}
+
+// batchSummary returns the summary of the events: the count by event name,
+// followed by the messages of the first max events.
+func batchSummary(service MessagingService, events []*webhook.Event, max int) string {
+	var names []string
+	counts := map[string]int{}
+	for _, event := range events {
+		if counts[event.Name] == 0 {
+			names = append(names, event.Name)
+		}
+		counts[event.Name]++
+	}
+
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s (%d)", name, counts[name]))
+	}
+
+	lines := []string{fmt.Sprintf("%d events: %s", len(events), strings.Join(parts, ", "))}
+	for i, event := range events {
+		if i == max {
+			lines = append(lines, fmt.Sprintf("...and %d more", len(events)-max))
+			break
+		}
+		lines = append(lines, Message(service, event))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,103 @@
+package strillone
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestBatchSummary(t *testing.T) {
+	var events []*webhook.Event
+	for i := 0; i < 7; i++ {
+		name, data := "zone_record.create", `{"zone_record": {"id": 1, "zone_id": "example.com", "name": "www", "type": "A", "content": "192.0.2.1"}}`
+		if i == 6 {
+			name, data = "zone.create", `{"zone": {"id": 1, "name": "example.com"}}`
+		}
+		event, _ := webhook.ParseEvent([]byte(fmt.Sprintf(`{"name": "%s", "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "data": %s}`, name, data)))
+		events = append(events, event)
+	}
+
+	summary := batchSummary(NewTestMessagingService("test"), events, batchSummaryMessages)
+	lines := strings.Split(summary, "\n")
+	if want, got := "7 events: zone_record.create (6), zone.create (1)", lines[0]; want != got {
+		t.Errorf("batchSummary expected %q, got %q", want, got)
+	}
+	if want, got := 1+batchSummaryMessages+1, len(lines); want != got {
+		t.Errorf("batchSummary expected %v lines, got %v", want, got)
+	}
+	if want, got := "...and 2 more", lines[len(lines)-1]; want != got {
+		t.Errorf("batchSummary expected last line %q, got %q", want, got)
+	}
+}
+
+func TestEvents_DestinationRateLimit(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A", "rate_limit": {"rate": 1, "burst": 1}}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	now := time.Now()
+	server.outboundLimiter.now = func() time.Time { return now }
+	service := &failingService{}
+	server.routing.services["ops"] = service
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+	for i := 1; i <= 3; i++ {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, fmt.Sprintf("e1c5a9d3-batch-0000-00000000000%d", i))))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want := http.StatusOK; want != response.Code {
+			t.Errorf("POST /events #%d expected HTTP %v, got %v", i, want, response.Code)
+		}
+	}
+
+	if want, got := 1, service.sent; want != got {
+		t.Errorf("destination expected %v event sent, got %v", want, got)
+	}
+
+	now = now.Add(time.Second)
+	server.flushBatch("/ops")
+	if want, got := 1, len(service.messages); want != got {
+		t.Fatalf("destination expected %v batched message, got %v", want, got)
+	}
+	if !strings.HasPrefix(service.messages[0], "2 events: domain.create (2)") {
+		t.Errorf("batched message unexpected: %v", service.messages[0])
+	}
+}
+
+func TestSendBatch_Requeue(t *testing.T) {
+	queue, cleanup := openTestQueue(t)
+	defer cleanup()
+	server := NewServer(nil)
+	server.SetQueue(queue, 0)
+
+	var events []*webhook.Event
+	for i := 1; i <= 2; i++ {
+		event, _ := webhook.ParseEvent([]byte(fmt.Sprintf(`{"name": "domain.create", "request_identifier": "%d", "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "data": {"domain": {"id": 1, "name": "example.com"}}}`, i)))
+		events = append(events, event)
+	}
+	server.sendBatch("/ops", &eventBatch{destination: "ops", events: events}, &failingService{messageErr: errors.New("unreachable")})
+
+	jobs, err := queue.Due(time.Now().Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("Due returned error: %v", err)
+	}
+	if want, got := 2, len(jobs); want != got {
+		t.Fatalf("queue expected the %v batched events enqueued again, got %v", want, got)
+	}
+	for _, job := range jobs {
+		if job.Destination != "ops" || job.Attempts != 1 || job.LastError != "unreachable" || !job.NextAttempt.After(job.CreatedAt) {
+			t.Errorf("queue expected a failed job to ops, got %+v", job)
+		}
+	}
+}
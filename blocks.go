@@ -0,0 +1,187 @@
+package strillone
+
+import (
+	"fmt"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+const (
+	// slackSectionLimit is the maximum length of the text of a section block, and slackDetailSections
+	// the number of sections listing the details of a message.
+	slackSectionLimit   = 3000
+	slackDetailSections = 40
+)
+
+// slackPayload is a Slack message with Block Kit blocks. The blocks are in an attachment,
+// so that the message is colored by severity.
+type slackPayload struct {
+	// Text is the notification text, also shown by the clients without blocks.
+	Text        string            `json:"text"`
+	Username    string            `json:"username,omitempty"`
+	IconURL     string            `json:"icon_url,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+
+	// Channel and ThreadTS are the channel, and the parent message, of the Web API messages.
+	Channel  string `json:"channel,omitempty"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+
+	// TS is the message replaced by chat.update.
+	TS string `json:"ts,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color,omitempty"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackBlock is a Block Kit layout block: header, context, section or actions.
+type slackBlock struct {
+	Type     string        `json:"type"`
+	Text     *slackText    `json:"text,omitempty"`
+	Fields   []*slackText  `json:"fields,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// slackText is a Block Kit text object, plain_text or mrkdwn.
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackButton is a Block Kit button opening a URL.
+type slackButton struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text"`
+	URL  string     `json:"url"`
+}
+
+// slackActionButton is a Block Kit button sending an interaction to the Slack app.
+type slackActionButton struct {
+	Type     string     `json:"type"`
+	Text     *slackText `json:"text"`
+	ActionID string     `json:"action_id"`
+	Value    string     `json:"value"`
+	Style    string     `json:"style,omitempty"`
+}
+
+func plainText(text string) *slackText {
+	return &slackText{Type: "plain_text", Text: text}
+}
+
+func mrkdwn(text string) *slackText {
+	return &slackText{Type: "mrkdwn", Text: text}
+}
+
+// slackEventBlocks returns the blocks of the message about the event: a header with the event name
+// marked with the emoji, the actor and the account, the message with the fields of the event,
+// and a button to DNSimple.
+func slackEventBlocks(s Formatter, e *webhook.Event, text string, severity Severity, emoji string) []slackBlock {
+	base := dashboardURL(s)
+	context := []interface{}{mrkdwn("*" + translate(s, "Actor") + ":* " + formatActor(s, e.Actor))}
+	if e.Account != nil {
+		context = append(context, mrkdwn("*"+translate(s, "Account")+":* "+s.FormatLink(eventAccountDisplay(s, e), fmtDashboardURL(base, "/a/%d/account", e.Account.ID))))
+	}
+
+	var fields []*slackText
+	if domain := eventDomain(e); domain != "" {
+		fields = append(fields, mrkdwn("*"+translate(s, "Domain")+"*\n"+domain))
+	}
+	fields = append(fields, mrkdwn("*"+translate(s, "Severity")+"*\n"+translate(s, string(severity))))
+
+	header := e.Name
+	if emoji != "" {
+		header = emoji + " " + header
+	}
+	return []slackBlock{
+		{Type: "header", Text: plainText(header)},
+		{Type: "context", Elements: context},
+		{Type: "section", Text: mrkdwn(text), Fields: fields},
+		{Type: "actions", Elements: []interface{}{
+			&slackButton{Type: "button", Text: plainText(translate(s, "Open in DNSimple")), URL: eventURL(base, e)},
+		}},
+	}
+}
+
+// slackTextBlocks returns the blocks of a message from Strillone itself.
+func slackTextBlocks(title, text string) []slackBlock {
+	return []slackBlock{
+		{Type: "header", Text: plainText(title)},
+		{Type: "section", Text: mrkdwn(text)},
+	}
+}
+
+// slackDetailBlocks returns the blocks listing the details, one per line, within the limits
+// of the sections and of the blocks of a message.
+func slackDetailBlocks(details []string) []slackBlock {
+	var blocks []slackBlock
+	var section string
+	for i, detail := range details {
+		// keep room for the number of the details left out
+		if len(section)+len(detail)+1 > slackSectionLimit-32 {
+			if len(blocks) == slackDetailSections-1 {
+				section += fmt.Sprintf("\n...and %d more", len(details)-i)
+				break
+			}
+			blocks = append(blocks, slackBlock{Type: "section", Text: mrkdwn(section)})
+			section = ""
+		}
+		if section != "" {
+			section += "\n"
+		}
+		section += detail
+	}
+	if section != "" {
+		blocks = append(blocks, slackBlock{Type: "section", Text: mrkdwn(section)})
+	}
+	return blocks
+}
+
+// slackApprovalBlocks returns the blocks of an approval request, with the Approve and Acknowledge buttons
+// sending the approval ID, or only the Acknowledge button when approve is false.
+func slackApprovalBlocks(s Formatter, text, approvalID string, approve bool) []slackBlock {
+	buttons := []interface{}{&slackActionButton{Type: "button", Text: plainText(translate(s, "Acknowledge")), ActionID: "acknowledge", Value: approvalID}}
+	if approve {
+		buttons = append([]interface{}{&slackActionButton{Type: "button", Text: plainText(translate(s, "Approve")), ActionID: "approve", Value: approvalID, Style: "primary"}}, buttons...)
+	}
+	return []slackBlock{
+		{Type: "section", Text: mrkdwn(text)},
+		{Type: "actions", Elements: buttons},
+	}
+}
+
+// slackConfirmationBlocks returns the blocks of a confirmation prompt, with the Confirm and Cancel buttons
+// sending the value.
+func slackConfirmationBlocks(text, confirmID, cancelID, value string) []slackBlock {
+	return []slackBlock{
+		{Type: "section", Text: mrkdwn(text)},
+		{Type: "actions", Elements: []interface{}{
+			&slackActionButton{Type: "button", Text: plainText("Confirm"), ActionID: confirmID, Value: value, Style: "primary"},
+			&slackActionButton{Type: "button", Text: plainText("Cancel"), ActionID: cancelID, Value: value},
+		}},
+	}
+}
+
+// newSlackPayload returns the message with the blocks, colored and with the icon.
+func newSlackPayload(text string, blocks []slackBlock, color, icon string) *slackPayload {
+	payload := &slackPayload{
+		Text:        text,
+		Username:    "DNSimple",
+		Attachments: []slackAttachment{{Color: color, Blocks: blocks}},
+	}
+	if emojiPattern.MatchString(icon) {
+		payload.IconEmoji = icon
+	} else {
+		payload.IconURL = icon
+	}
+	return payload
+}
+
+// slackNotification returns the notification text of the message, preceded by the mentions, if any.
+func slackNotification(text, mentions string) string {
+	if mentions != "" {
+		return fmt.Sprintf("%s %s", mentions, text)
+	}
+	return text
+}
@@ -0,0 +1,42 @@
+package strillone
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_slackEventBlocks(t *testing.T) {
+	service := NewTestMessagingService("dummyMessagingService")
+	event, _ := webhook.ParseEvent([]byte(`{"data": {"zone_record": {"id": 7, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "zone_record.delete"}`))
+
+	blocks := slackEventBlocks(service, event, "deleted the record", SeverityWarning, ":warning:")
+	var types []string
+	for _, block := range blocks {
+		types = append(types, block.Type)
+	}
+	if want, got := "header,context,section,actions", strings.Join(types, ","); want != got {
+		t.Fatalf("slackEventBlocks expected blocks %v, got %v", want, got)
+	}
+
+	if want, got := "*Account:* <User|https://dnsimple.com/a/1010/account>", blocks[1].Elements[1].(*slackText).Text; want != got {
+		t.Errorf("slackEventBlocks expected context %v, got %v", want, got)
+	}
+
+	data, err := json.Marshal(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"text":":warning: zone_record.delete"`,
+		`"text":"*Actor:* example@example.com"`,
+		`"text":"*Domain*\nexample.com"`,
+		`"url":"https://dnsimple.com/a/1010/domains/example.com/records/7"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("slackEventBlocks expected %v in %s", want, data)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = time.Minute
+)
+
+// CircuitBreakerConfig represents when the deliveries to a failing destination are paused.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed deliveries that opens the circuit. Defaults to 5.
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+
+	// Cooldown is how long the deliveries are paused before trying again. Defaults to 1m.
+	Cooldown Duration `json:"cooldown,omitempty"`
+}
+
+func (c *CircuitBreakerConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.FailureThreshold < 0 || c.Cooldown < 0 {
+		return errors.New("circuit breaker: values must be positive")
+	}
+	return nil
+}
+
+func (c *CircuitBreakerConfig) settings() (int, time.Duration) {
+	threshold, cooldown := defaultBreakerFailureThreshold, defaultBreakerCooldown
+	if c != nil && c.FailureThreshold > 0 {
+		threshold = c.FailureThreshold
+	}
+	if c != nil && c.Cooldown > 0 {
+		cooldown = time.Duration(c.Cooldown)
+	}
+	return threshold, cooldown
+}
+
+// circuitOpenError is returned when a delivery is skipped because the circuit is open.
+type circuitOpenError struct {
+	until time.Time
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("destination paused after repeated failures until %v", e.until.Format(time.RFC3339))
+}
+
+// circuitBreaker tracks the consecutive failures of a destination.
+//
+// After FailureThreshold failures the circuit opens, and the deliveries fail immediately
+// for the cool-down period. Then a single delivery is let through: if it succeeds the circuit
+// closes, otherwise it opens again.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	open      bool
+	openUntil time.Time
+	trial     bool
+}
+
+// allow returns a *circuitOpenError if the delivery must be skipped.
+func (b *circuitBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return nil
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return &circuitOpenError{until: b.openUntil}
+	}
+	b.trial = true
+	return nil
+}
+
+// record records the result of a delivery, and returns the change of state, if any:
+// "opened" when the circuit opens, "closed" when it closes again.
+func (b *circuitBreaker) record(err error, now time.Time, config *CircuitBreakerConfig) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	threshold, cooldown := config.settings()
+	b.trial = false
+
+	if err == nil {
+		b.failures = 0
+		if b.open {
+			b.open = false
+			return "closed"
+		}
+		return ""
+	}
+
+	b.failures++
+	if b.open {
+		b.openUntil = now.Add(cooldown)
+		return ""
+	}
+	if b.failures >= threshold {
+		b.open = true
+		b.openUntil = now.Add(cooldown)
+		return "opened"
+	}
+	return ""
+}
+
+// circuitBreakers are the circuit breakers of the destinations, by tenant and name.
+// They are kept across configuration reloads.
+type circuitBreakers struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newCircuitBreakers() *circuitBreakers {
+	return &circuitBreakers{breakers: map[string]*circuitBreaker{}}
+}
+
+func (c *circuitBreakers) get(tenant, destination string) *circuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := tenant + "/" + destination
+	breaker, ok := c.breakers[key]
+	if !ok {
+		breaker = &circuitBreaker{}
+		c.breakers[key] = breaker
+	}
+	return breaker
+}
+
+// deliver posts the event to the destination, through its quiet windows, aggregation, rate limit
+// and circuit breaker. An event held, dropped, aggregated, or coalesced in a batch is reported as delivered,
+// so its job leaves the queue: the held events are enqueued again if their summary fails (see requeue).
+func (s *Server) deliver(ctx context.Context, routing *routingTable, name string, event *webhook.Event) (string, error) {
+	_, span := startDeliverSpan(ctx, routing.name, name, event)
+	defer span.End()
+
+	var config *CircuitBreakerConfig
+	var limit *RateLimitConfig
+	var windows []QuietWindowConfig
+	var aggregation *AggregationConfig
+	if i := findDestination(routing.config, name); i >= 0 {
+		config = routing.config.Destinations[i].CircuitBreaker
+		limit = routing.config.Destinations[i].RateLimit
+		windows = routing.config.Destinations[i].QuietWindows
+		aggregation = routing.config.Destinations[i].Aggregation
+	}
+	breaker := s.breakers.get(routing.name, name)
+
+	start := time.Now()
+	if !s.quiet(routing, name, event, windows) {
+		span.SetAttributes(attribute.String("delivery.outcome", "quiet"))
+		s.recordAttempt(routing, name, event, "quiet", start, nil)
+		return Message(routing.services[name], event), nil
+	}
+	if !s.aggregate(routing, name, event, aggregation) {
+		span.SetAttributes(attribute.String("delivery.outcome", "aggregated"))
+		s.recordAttempt(routing, name, event, "aggregated", start, nil)
+		return Message(routing.services[name], event), nil
+	}
+	if !s.rateLimit(routing, name, event, limit) {
+		span.SetAttributes(attribute.String("delivery.outcome", "batched"))
+		s.recordAttempt(routing, name, event, "batched", start, nil)
+		return Message(routing.services[name], event), nil
+	}
+
+	if err := breaker.allow(time.Now()); err != nil {
+		span.SetAttributes(attribute.String("delivery.outcome", "circuit-open"))
+		recordError(span, err)
+		withEvent(log.Warn(), event).Str("tenant", routing.name).Str("destination", name).Str("outcome", "circuit-open").Msg("Event not delivered: circuit open")
+		s.recordAttempt(routing, name, event, "circuit-open", start, err)
+		return "", err
+	}
+
+	deliveryCtx, cancel := routing.deliveryContext(ctx)
+	defer cancel()
+	deliveryCtx = withAnnotations(deliveryCtx, routing.securityAnnotations(event, name, routing.annotations(event.Name, name)))
+	text, err := routing.services[name].PostEvent(deliveryCtx, event)
+	logDelivery(event, routing.name, name, start, err)
+	s.recordAttempt(routing, name, event, deliveryStatus(err), start, err)
+	span.SetAttributes(attribute.String("delivery.outcome", deliveryStatus(err)))
+	if err != nil {
+		recordError(span, err)
+	}
+
+	switch breaker.record(err, time.Now(), config) {
+	case "opened":
+		_, cooldown := config.settings()
+		s.notifyHealth(routing, name, fmt.Sprintf("Destination %s is failing, deliveries are paused for %v: %v", name, cooldown, err))
+	case "closed":
+		s.notifyHealth(routing, name, fmt.Sprintf("Destination %s recovered, deliveries are resumed", name))
+	}
+
+	return text, err
+}
+
+// notifyHealth logs a change of health of a destination, and posts it to the health destination.
+func (s *Server) notifyHealth(routing *routingTable, name, text string) {
+	log.Warn().Str("tenant", routing.name).Str("destination", name).Msg(text)
+
+	health := routing.config.HealthDestination
+	if health == "" || health == name {
+		return
+	}
+	if err := routing.postMessage(context.Background(), routing.services[health], text); err != nil {
+		log.Error().Err(err).Str("tenant", routing.name).Str("destination", health).Msg("Error sending the health notification")
+	}
+}
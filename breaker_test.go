@@ -0,0 +1,92 @@
+package strillone
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := &CircuitBreakerConfig{FailureThreshold: 2, Cooldown: Duration(time.Minute)}
+	breaker := &circuitBreaker{}
+	failure := errors.New("failure")
+
+	if want, got := "", breaker.record(failure, now, config); want != got {
+		t.Errorf("record #1 expected %q, got %q", want, got)
+	}
+	if want, got := "opened", breaker.record(failure, now, config); want != got {
+		t.Errorf("record #2 expected %q, got %q", want, got)
+	}
+	if err := breaker.allow(now.Add(30 * time.Second)); err == nil {
+		t.Errorf("allow during the cool-down expected error")
+	}
+
+	// after the cool-down a single trial is let through
+	later := now.Add(2 * time.Minute)
+	if err := breaker.allow(later); err != nil {
+		t.Errorf("allow after the cool-down returned error: %v", err)
+	}
+	if err := breaker.allow(later); err == nil {
+		t.Errorf("allow during the trial expected error")
+	}
+	if want, got := "", breaker.record(failure, later, config); want != got {
+		t.Errorf("record failed trial expected %q, got %q", want, got)
+	}
+	if err := breaker.allow(later.Add(30 * time.Second)); err == nil {
+		t.Errorf("allow after the failed trial expected error")
+	}
+
+	later = later.Add(2 * time.Minute)
+	breaker.allow(later)
+	if want, got := "closed", breaker.record(nil, later, config); want != got {
+		t.Errorf("record successful trial expected %q, got %q", want, got)
+	}
+	if err := breaker.allow(later); err != nil {
+		t.Errorf("allow after closing returned error: %v", err)
+	}
+}
+
+func TestEvents_CircuitBreaker(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A", "circuit_breaker": {"failure_threshold": 2, "cooldown": "1h"}},
+			{"name": "health", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [{"destinations": ["ops"]}],
+		"health_destination": "health"
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops := &failingService{errs: []error{errors.New("down"), errors.New("down"), errors.New("down")}}
+	health := &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["health"] = health
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+	for i := 1; i <= 3; i++ {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, fmt.Sprintf("b7d0e4c2-breaker-0000-00000000000%d", i))))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want := http.StatusInternalServerError; want != response.Code {
+			t.Errorf("POST /events #%d expected HTTP %v, got %v", i, want, response.Code)
+		}
+	}
+
+	if want, got := 1, len(ops.errs); want != got {
+		t.Errorf("open circuit expected the third delivery to be skipped, %v errors left", got)
+	}
+	if want, got := 1, len(health.messages); want != got {
+		t.Fatalf("health destination expected %v notification, got %v", want, got)
+	}
+	if !strings.Contains(health.messages[0], "Destination ops is failing") {
+		t.Errorf("health notification unexpected: %v", health.messages[0])
+	}
+}
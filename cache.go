@@ -0,0 +1,136 @@
+package strillone
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultDetailsCacheTTL is how long the details fetched from the API are reused for the next events.
+	defaultDetailsCacheTTL = time.Hour
+
+	// maxCachedDetails is the number of details kept by the in-memory cache.
+	maxCachedDetails = 10000
+)
+
+// APICacheConfig configures the cache of the details fetched from the DNSimple API to complete the events.
+type APICacheConfig struct {
+	// TTL is how long the details are reused for the next events. Defaults to 1h.
+	TTL Duration `json:"ttl,omitempty"`
+
+	// Redis is the URL of a Redis server shared by the instances (e.g. redis://:password@localhost:6379/0),
+	// or a reference to a secret. Defaults to an in-memory cache.
+	Redis string `json:"redis,omitempty"`
+}
+
+func (c *APICacheConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.TTL < 0 {
+		return fmt.Errorf("api: cache ttl must be positive")
+	}
+	// The references to secrets are checked once resolved.
+	if strings.HasPrefix(c.Redis, "redis://") || strings.HasPrefix(c.Redis, "rediss://") {
+		if _, err := parseRedisURL(c.Redis); err != nil {
+			return fmt.Errorf("api: cache: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *APICacheConfig) ttl() time.Duration {
+	if c == nil || c.TTL == 0 {
+		return defaultDetailsCacheTTL
+	}
+	return time.Duration(c.TTL)
+}
+
+// detailsCache caches the details fetched from the DNSimple API, encoded, by key.
+// The errors are logged, and handled as missing entries.
+type detailsCache interface {
+	get(key string) ([]byte, bool)
+	set(key string, value []byte, ttl time.Duration)
+}
+
+// newDetailsCache returns the cache of the details, in Redis when configured, in memory otherwise.
+func newDetailsCache(c *APICacheConfig, secrets *Secrets) (detailsCache, error) {
+	if c == nil || c.Redis == "" {
+		return newMemoryCache(maxCachedDetails), nil
+	}
+	rawURL, err := secrets.Resolve(c.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("api: cache: %v", err)
+	}
+	options, err := parseRedisURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("api: cache: %v", err)
+	}
+	return &redisCache{client: &redisClient{options: options}}, nil
+}
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// memoryCache is a detailsCache in memory, dropping the oldest entries beyond its size.
+type memoryCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]memoryCacheEntry
+	order   []string
+}
+
+func newMemoryCache(size int) *memoryCache {
+	return &memoryCache{size: size, entries: map[string]memoryCacheEntry{}}
+}
+
+func (c *memoryCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *memoryCache) set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = memoryCacheEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	for len(c.order) > c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// redisCache is a detailsCache in Redis, shared by the instances.
+type redisCache struct {
+	client *redisClient
+}
+
+func (c *redisCache) get(key string) ([]byte, bool) {
+	reply, err := c.client.do("GET", key)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error reading the API cache")
+		return nil, false
+	}
+	value, ok := reply.([]byte)
+	return value, ok
+}
+
+func (c *redisCache) set(key string, value []byte, ttl time.Duration) {
+	if _, err := c.client.do("SET", key, string(value), "PX", strconv.FormatInt(int64(ttl/time.Millisecond), 10)); err != nil {
+		log.Warn().Err(err).Msg("Error writing the API cache")
+	}
+}
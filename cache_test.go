@@ -0,0 +1,66 @@
+package strillone
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	cache := newMemoryCache(2)
+	cache.set("a", []byte("1"), time.Hour)
+	cache.set("b", []byte("2"), -time.Second)
+	if value, ok := cache.get("a"); !ok || string(value) != "1" {
+		t.Errorf("get(a) expected 1, got %q %v", value, ok)
+	}
+	if _, ok := cache.get("b"); ok {
+		t.Errorf("get(b) expected the entry to be expired")
+	}
+	cache.set("c", []byte("3"), time.Hour)
+	if _, ok := cache.get("a"); ok {
+		t.Errorf("get(a) expected the oldest entry to be dropped")
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	url, redis := startFakeRedis(t)
+	cache, err := newDetailsCache(&APICacheConfig{Redis: strings.Replace(url, "redis://", "redis://:secret@", 1) + "/2"}, nil)
+	if err != nil {
+		t.Fatalf("newDetailsCache returned error: %v", err)
+	}
+
+	if _, ok := cache.get("strillone:domain:1010/1"); ok {
+		t.Errorf("get expected a missing entry")
+	}
+	cache.set("strillone:domain:1010/1", []byte(`{"Name":"example.com"}`), time.Minute)
+	if value, ok := cache.get("strillone:domain:1010/1"); !ok || string(value) != `{"Name":"example.com"}` {
+		t.Errorf("get expected the entry, got %q %v", value, ok)
+	}
+
+	want := []string{"AUTH secret", "SELECT 2", "GET strillone:domain:1010/1", `SET strillone:domain:1010/1 {"Name":"example.com"} PX 60000`, "GET strillone:domain:1010/1"}
+	if got := redis.commands(); strings.Join(want, "\n") != strings.Join(got, "\n") {
+		t.Errorf("expected the commands\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestAPICacheConfig_validate(t *testing.T) {
+	tests := []struct {
+		config *APICacheConfig
+		valid  bool
+	}{
+		{nil, true},
+		{&APICacheConfig{TTL: Duration(time.Minute), Redis: "redis://localhost:6379/0"}, true},
+		{&APICacheConfig{Redis: "vault:secret/data/strillone#redis_url"}, true},
+		{&APICacheConfig{TTL: -1}, false},
+		{&APICacheConfig{Redis: "redis://localhost/db"}, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate()
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package strillone
+
+// builtinCatalogs are the translations of the messages, by language, keyed by the English message.
+var builtinCatalogs = map[string]map[string]string{
+	"es": {
+		"%d records added to %s by %s":                                          "%d registros añadidos a %s por %s",
+		"%s accepted invitation to account %s":                                  "%s aceptó la invitación a la cuenta %s",
+		"%s accepted the push of the domain %s":                                 "%s aceptó la transferencia del dominio %s",
+		"%s cancelled the subscription to the %s plan":                          "%s canceló la suscripción al plan %s",
+		"%s changed the delegation for the domain %s to %s":                     "%s cambió la delegación del dominio %s a %s",
+		"%s changed the registrant for the domain %s to %s":                     "%s cambió el titular del dominio %s a %s",
+		"%s changed the subscription to the %s plan":                            "%s cambió la suscripción al plan %s",
+		"%s completed the DNSSEC key rotation for the domain %s":                "%s completó la rotación de claves DNSSEC del dominio %s",
+		"%s created the contact %s":                                             "%s creó el contacto %s",
+		"%s created the domain %s":                                              "%s creó el dominio %s",
+		"%s created the email forward %s":                                       "%s creó el reenvío de correo %s",
+		"%s created the record %s":                                              "%s creó el registro %s",
+		"%s created the webhook %s":                                             "%s creó el webhook %s",
+		"%s created the zone %s":                                                "%s creó la zona %s",
+		"%s deleted the contact %s":                                             "%s eliminó el contacto %s",
+		"%s deleted the domain %s":                                              "%s eliminó el dominio %s",
+		"%s deleted the email forward %s":                                       "%s eliminó el reenvío de correo %s",
+		"%s deleted the private key for the certificate %s%s":                   "%s eliminó la clave privada del certificado %s%s",
+		"%s deleted the record %s":                                              "%s eliminó el registro %s",
+		"%s deleted the webhook %s":                                             "%s eliminó el webhook %s",
+		"%s deleted the zone %s":                                                "%s eliminó la zona %s",
+		"%s deregistered the name server %s":                                    "%s dio de baja el servidor de nombres %s",
+		"%s disabled DNSSEC for the domain %s":                                  "%s desactivó DNSSEC para el dominio %s",
+		"%s disabled auto-renewal for the certificate %s%s":                     "%s desactivó la renovación automática del certificado %s%s",
+		"%s disabled auto-renewal for the domain %s":                            "%s desactivó la renovación automática del dominio %s",
+		"%s disabled resolution for the domain %s":                              "%s desactivó la resolución del dominio %s",
+		"%s disabled the transfer lock for the domain %s":                       "%s desactivó el bloqueo de transferencia del dominio %s",
+		"%s disabled whois privacy for the domain %s":                           "%s desactivó la privacidad whois del dominio %s",
+		"%s enabled DNSSEC for the domain %s":                                   "%s activó DNSSEC para el dominio %s",
+		"%s enabled auto-renewal for the certificate %s%s":                      "%s activó la renovación automática del certificado %s%s",
+		"%s enabled auto-renewal for the domain %s":                             "%s activó la renovación automática del dominio %s",
+		"%s enabled resolution for the domain %s":                               "%s activó la resolución del dominio %s",
+		"%s enabled the transfer lock for the domain %s":                        "%s activó el bloqueo de transferencia del dominio %s",
+		"%s enabled whois privacy for the domain %s":                            "%s activó la privacidad whois del dominio %s",
+		"%s failed to auto-renew the certificate %s%s":                          "%s no pudo renovar automáticamente el certificado %s%s",
+		"%s initiated the push of the domain %s to another account":             "%s inició la transferencia del dominio %s a otra cuenta",
+		"%s invited %s to account %s":                                           "%s invitó a %s a la cuenta %s",
+		"%s issued the certificate %s%s":                                        "%s emitió el certificado %s%s",
+		"%s performed %s on domain %s":                                          "%s realizó %s en el dominio %s",
+		"%s performed %s on name server %s":                                     "%s realizó %s en el servidor de nombres %s",
+		"%s performed %s on record %s":                                          "%s realizó %s en el registro %s",
+		"%s performed %s on zone %s":                                            "%s realizó %s en la zona %s",
+		"%s performed %s":                                                       "%s realizó %s",
+		"%s performed %s on %s":                                                 "%s realizó %s en %s",
+		"%s purchased whois privacy for the domain %s":                          "%s compró la privacidad whois del dominio %s",
+		"%s registered the domain %s":                                           "%s registró el dominio %s",
+		"%s registered the name server %s":                                      "%s registró el servidor de nombres %s",
+		"%s reissued the certificate %s%s":                                      "%s volvió a emitir el certificado %s%s",
+		"%s rejected invitation to account %s":                                  "%s rechazó la invitación a la cuenta %s",
+		"%s rejected the push of the domain %s":                                 "%s rechazó la transferencia del dominio %s",
+		"%s removed %s from account %s":                                         "%s eliminó a %s de la cuenta %s",
+		"%s renewed the domain %s":                                              "%s renovó el dominio %s",
+		"%s renewed the subscription to the %s plan":                            "%s renovó la suscripción al plan %s",
+		"%s renewed whois privacy for the domain %s":                            "%s renovó la privacidad whois del dominio %s",
+		"%s reset the token for the domain %s":                                  "%s restableció el token del dominio %s",
+		"%s started the DNSSEC key rotation for the domain %s":                  "%s inició la rotación de claves DNSSEC del dominio %s",
+		"%s subscribed to the %s plan":                                          "%s se suscribió al plan %s",
+		"%s transferred the domain %s":                                          "%s transfirió el dominio %s",
+		"%s updated the billing settings of the account %s":                     "%s actualizó la configuración de facturación de la cuenta %s",
+		"%s updated the contact %s":                                             "%s actualizó el contacto %s",
+		"%s updated the email forward %s":                                       "%s actualizó el reenvío de correo %s",
+		"%s updated the record %s in %s":                                        "%s actualizó el registro %s en %s",
+		"%s updated the record %s":                                              "%s actualizó el registro %s",
+		"%s updated the settings of the account %s":                             "%s actualizó la configuración de la cuenta %s",
+		"%s via API token '%s'":                                                 "%s mediante el token de API '%s'",
+		"API token '%s'":                                                        "token de API '%s'",
+		"The domain %s expires in %d days, on %s, and auto-renewal is disabled": "El dominio %s caduca en %d días, el %s, y la renovación automática está desactivada",
+		"The domain %s expires tomorrow, on %s, and auto-renewal is disabled":   "El dominio %s caduca mañana, el %s, y la renovación automática está desactivada",
+		"The certificate %s expires on %s, and auto-renewal is disabled":        "El certificado %s caduca el %s, y la renovación automática está desactivada",
+		"The certificate %s expires on %s, and its auto-renewal appears stuck":  "El certificado %s caduca el %s, y su renovación automática parece bloqueada",
+		"The name server %s failed to answer for the zone %s: %s":               "El servidor de nombres %s no respondió para la zona %s: %s",
+		"The name server %s serves a stale version of the zone %s, with the serial %s instead of %s": "El servidor de nombres %s sirve una versión obsoleta de la zona %s, con el número de serie %s en lugar de %s",
+		"The name server %s answers %s for %s in the zone %s, instead of %s":                         "El servidor de nombres %s responde %s para %s en la zona %s, en lugar de %s",
+		"no record": "ningún registro",
+		"The domain %s is no longer locked against transfers":                                       "El dominio %s ya no está bloqueado contra transferencias",
+		"The WHOIS privacy of the domain %s is no longer enabled":                                   "La privacidad WHOIS del dominio %s ya no está activada",
+		"Unusual event volume: %d events about %s in %s, instead of %s on average":                  "Volumen de eventos inusual: %d eventos sobre %s en %s, en lugar de %s de media",
+		"Registrar audit of %s domains: transfer lock disabled on %s, WHOIS privacy disabled on %s": "Auditoría del registrador de %s dominios: bloqueo de transferencia desactivado en %s, privacidad WHOIS desactivada en %s",
+		"Weekly activity from %s to %s: %d events":                                                  "Actividad semanal del %s al %s: %d eventos",
+		"none":                                "ninguno",
+		"%d more":                             "%d más",
+		"Events: %s":                          "Eventos: %s",
+		"Top actors: %s":                      "Autores principales: %s",
+		"Domains changed: %s":                 "Dominios modificados: %s",
+		"Expiring within %d days: %s":         "Caducan en los próximos %d días: %s",
+		"Acknowledgement required: %s":        "Confirmación requerida: %s",
+		"Approval required: %s":               "Aprobación requerida: %s",
+		"Still not acknowledged after %s: %s": "Sigue sin confirmarse después de %s: %s",
+		"Out-of-window change (%s): %s":       "Cambio fuera de la ventana aprobada (%s): %s",
+		"the account %s":                      "la cuenta %s",
+		"Show in the dashboard":               "Ver en el panel",
+		"approved by %s":                      "aprobado por %s",
+		"acknowledged by %s":                  "confirmado por %s",
+		"Approve":                             "Aprobar",
+		"Acknowledge":                         "Confirmar",
+		"%s expires on %s":                    "%s caduca el %s",
+		"Account":                             "Cuenta",
+		"Actor":                               "Autor",
+		"Domain":                              "Dominio",
+		"Open in DNSimple":                    "Abrir en DNSimple",
+		"Severity":                            "Gravedad",
+		"application '%s'":                    "aplicación '%s'",
+		"auto-renewal disabled":               "renovación automática desactivada",
+		"auto-renewal enabled":                "renovación automática activada",
+		"covering %s":                         "que cubre %s",
+		"expiring on %s":                      "que caduca el %s",
+		"registrant %s":                       "titular %s",
+		"info":                                "información",
+		"warning":                             "advertencia",
+		"critical":                            "crítico",
+	},
+	"fr": {
+		"%d records added to %s by %s":                                          "%d enregistrements ajoutés à %s par %s",
+		"%s accepted invitation to account %s":                                  "%s a accepté l'invitation au compte %s",
+		"%s accepted the push of the domain %s":                                 "%s a accepté le transfert du domaine %s",
+		"%s cancelled the subscription to the %s plan":                          "%s a résilié l'abonnement au forfait %s",
+		"%s changed the delegation for the domain %s to %s":                     "%s a changé la délégation du domaine %s en %s",
+		"%s changed the registrant for the domain %s to %s":                     "%s a changé le titulaire du domaine %s en %s",
+		"%s changed the subscription to the %s plan":                            "%s a changé l'abonnement pour le forfait %s",
+		"%s completed the DNSSEC key rotation for the domain %s":                "%s a terminé la rotation des clés DNSSEC du domaine %s",
+		"%s created the contact %s":                                             "%s a créé le contact %s",
+		"%s created the domain %s":                                              "%s a créé le domaine %s",
+		"%s created the email forward %s":                                       "%s a créé la redirection d'e-mail %s",
+		"%s created the record %s":                                              "%s a créé l'enregistrement %s",
+		"%s created the webhook %s":                                             "%s a créé le webhook %s",
+		"%s created the zone %s":                                                "%s a créé la zone %s",
+		"%s deleted the contact %s":                                             "%s a supprimé le contact %s",
+		"%s deleted the domain %s":                                              "%s a supprimé le domaine %s",
+		"%s deleted the email forward %s":                                       "%s a supprimé la redirection d'e-mail %s",
+		"%s deleted the private key for the certificate %s%s":                   "%s a supprimé la clé privée du certificat %s%s",
+		"%s deleted the record %s":                                              "%s a supprimé l'enregistrement %s",
+		"%s deleted the webhook %s":                                             "%s a supprimé le webhook %s",
+		"%s deleted the zone %s":                                                "%s a supprimé la zone %s",
+		"%s deregistered the name server %s":                                    "%s a désenregistré le serveur de noms %s",
+		"%s disabled DNSSEC for the domain %s":                                  "%s a désactivé DNSSEC pour le domaine %s",
+		"%s disabled auto-renewal for the certificate %s%s":                     "%s a désactivé le renouvellement automatique du certificat %s%s",
+		"%s disabled auto-renewal for the domain %s":                            "%s a désactivé le renouvellement automatique du domaine %s",
+		"%s disabled resolution for the domain %s":                              "%s a désactivé la résolution du domaine %s",
+		"%s disabled the transfer lock for the domain %s":                       "%s a désactivé le verrou de transfert du domaine %s",
+		"%s disabled whois privacy for the domain %s":                           "%s a désactivé la confidentialité whois du domaine %s",
+		"%s enabled DNSSEC for the domain %s":                                   "%s a activé DNSSEC pour le domaine %s",
+		"%s enabled auto-renewal for the certificate %s%s":                      "%s a activé le renouvellement automatique du certificat %s%s",
+		"%s enabled auto-renewal for the domain %s":                             "%s a activé le renouvellement automatique du domaine %s",
+		"%s enabled resolution for the domain %s":                               "%s a activé la résolution du domaine %s",
+		"%s enabled the transfer lock for the domain %s":                        "%s a activé le verrou de transfert du domaine %s",
+		"%s enabled whois privacy for the domain %s":                            "%s a activé la confidentialité whois du domaine %s",
+		"%s failed to auto-renew the certificate %s%s":                          "%s n'a pas pu renouveler automatiquement le certificat %s%s",
+		"%s initiated the push of the domain %s to another account":             "%s a initié le transfert du domaine %s vers un autre compte",
+		"%s invited %s to account %s":                                           "%s a invité %s au compte %s",
+		"%s issued the certificate %s%s":                                        "%s a émis le certificat %s%s",
+		"%s performed %s on domain %s":                                          "%s a effectué %s sur le domaine %s",
+		"%s performed %s on name server %s":                                     "%s a effectué %s sur le serveur de noms %s",
+		"%s performed %s on record %s":                                          "%s a effectué %s sur l'enregistrement %s",
+		"%s performed %s on zone %s":                                            "%s a effectué %s sur la zone %s",
+		"%s performed %s":                                                       "%s a effectué %s",
+		"%s performed %s on %s":                                                 "%s a effectué %s sur %s",
+		"%s purchased whois privacy for the domain %s":                          "%s a acheté la confidentialité whois du domaine %s",
+		"%s registered the domain %s":                                           "%s a enregistré le domaine %s",
+		"%s registered the name server %s":                                      "%s a enregistré le serveur de noms %s",
+		"%s reissued the certificate %s%s":                                      "%s a réémis le certificat %s%s",
+		"%s rejected invitation to account %s":                                  "%s a refusé l'invitation au compte %s",
+		"%s rejected the push of the domain %s":                                 "%s a refusé le transfert du domaine %s",
+		"%s removed %s from account %s":                                         "%s a retiré %s du compte %s",
+		"%s renewed the domain %s":                                              "%s a renouvelé le domaine %s",
+		"%s renewed the subscription to the %s plan":                            "%s a renouvelé l'abonnement au forfait %s",
+		"%s renewed whois privacy for the domain %s":                            "%s a renouvelé la confidentialité whois du domaine %s",
+		"%s reset the token for the domain %s":                                  "%s a réinitialisé le jeton du domaine %s",
+		"%s started the DNSSEC key rotation for the domain %s":                  "%s a démarré la rotation des clés DNSSEC du domaine %s",
+		"%s subscribed to the %s plan":                                          "%s s'est abonné au forfait %s",
+		"%s transferred the domain %s":                                          "%s a transféré le domaine %s",
+		"%s updated the billing settings of the account %s":                     "%s a mis à jour les paramètres de facturation du compte %s",
+		"%s updated the contact %s":                                             "%s a mis à jour le contact %s",
+		"%s updated the email forward %s":                                       "%s a mis à jour la redirection d'e-mail %s",
+		"%s updated the record %s in %s":                                        "%s a mis à jour l'enregistrement %s dans %s",
+		"%s updated the record %s":                                              "%s a mis à jour l'enregistrement %s",
+		"%s updated the settings of the account %s":                             "%s a mis à jour les paramètres du compte %s",
+		"%s via API token '%s'":                                                 "%s via le jeton d'API '%s'",
+		"API token '%s'":                                                        "jeton d'API '%s'",
+		"The domain %s expires in %d days, on %s, and auto-renewal is disabled": "Le domaine %s expire dans %d jours, le %s, et le renouvellement automatique est désactivé",
+		"The domain %s expires tomorrow, on %s, and auto-renewal is disabled":   "Le domaine %s expire demain, le %s, et le renouvellement automatique est désactivé",
+		"The certificate %s expires on %s, and auto-renewal is disabled":        "Le certificat %s expire le %s, et le renouvellement automatique est désactivé",
+		"The certificate %s expires on %s, and its auto-renewal appears stuck":  "Le certificat %s expire le %s, et son renouvellement automatique semble bloqué",
+		"The name server %s failed to answer for the zone %s: %s":               "Le serveur de noms %s n'a pas répondu pour la zone %s : %s",
+		"The name server %s serves a stale version of the zone %s, with the serial %s instead of %s": "Le serveur de noms %s sert une version obsolète de la zone %s, avec le numéro de série %s au lieu de %s",
+		"The name server %s answers %s for %s in the zone %s, instead of %s":                         "Le serveur de noms %s répond %s pour %s dans la zone %s, au lieu de %s",
+		"no record": "aucun enregistrement",
+		"The domain %s is no longer locked against transfers":                                       "Le domaine %s n'est plus verrouillé contre les transferts",
+		"The WHOIS privacy of the domain %s is no longer enabled":                                   "La confidentialité WHOIS du domaine %s n'est plus activée",
+		"Unusual event volume: %d events about %s in %s, instead of %s on average":                  "Volume d'événements inhabituel : %d événements concernant %s en %s, au lieu de %s en moyenne",
+		"Registrar audit of %s domains: transfer lock disabled on %s, WHOIS privacy disabled on %s": "Audit du registraire de %s domaines : verrou de transfert désactivé sur %s, confidentialité WHOIS désactivée sur %s",
+		"Weekly activity from %s to %s: %d events":                                                  "Activité hebdomadaire du %s au %s : %d événements",
+		"none":                                "aucun",
+		"%d more":                             "%d de plus",
+		"Events: %s":                          "Événements : %s",
+		"Top actors: %s":                      "Auteurs principaux : %s",
+		"Domains changed: %s":                 "Domaines modifiés : %s",
+		"Expiring within %d days: %s":         "Expirent dans les %d prochains jours : %s",
+		"Acknowledgement required: %s":        "Prise en compte requise : %s",
+		"Approval required: %s":               "Approbation requise : %s",
+		"Still not acknowledged after %s: %s": "Toujours pas pris en compte après %s : %s",
+		"Out-of-window change (%s): %s":       "Changement hors de la fenêtre autorisée (%s) : %s",
+		"the account %s":                      "le compte %s",
+		"Show in the dashboard":               "Voir dans le tableau de bord",
+		"approved by %s":                      "approuvé par %s",
+		"acknowledged by %s":                  "pris en compte par %s",
+		"Approve":                             "Approuver",
+		"Acknowledge":                         "Prendre en compte",
+		"%s expires on %s":                    "%s expire le %s",
+		"Account":                             "Compte",
+		"Actor":                               "Auteur",
+		"Domain":                              "Domaine",
+		"Open in DNSimple":                    "Ouvrir dans DNSimple",
+		"Severity":                            "Gravité",
+		"application '%s'":                    "application '%s'",
+		"auto-renewal disabled":               "renouvellement automatique désactivé",
+		"auto-renewal enabled":                "renouvellement automatique activé",
+		"covering %s":                         "couvrant %s",
+		"expiring on %s":                      "expirant le %s",
+		"registrant %s":                       "titulaire %s",
+		"info":                                "info",
+		"warning":                             "avertissement",
+		"critical":                            "critique",
+	},
+}
@@ -0,0 +1,48 @@
+package strillone
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+)
+
+// certificateDateFormat is the format of the expiration dates in the messages.
+const certificateDateFormat = "2006-01-02"
+
+// CertificateEvent represents the data of the certificate.* events.
+type CertificateEvent struct {
+	Certificate *dnsimple.Certificate `json:"certificate"`
+}
+
+// AlternateNames returns the names covered by the certificate besides the common name.
+func (e *CertificateEvent) AlternateNames() []string {
+	var names []string
+	for _, name := range e.Certificate.AlternateNames {
+		if name != e.Certificate.CommonName {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ExpiresAt returns the expiration of the certificate, false if unknown or invalid.
+func (e *CertificateEvent) ExpiresAt() (time.Time, bool) {
+	return parseTimestamp(e.Certificate.ExpiresAt)
+}
+
+// certificateContext describes the names covered by the certificate and its expiration,
+// such as " covering example.com, expiring on 2022-06-16", to append to the messages.
+func certificateContext(s Formatter, e *CertificateEvent) string {
+	var context []string
+	if names := e.AlternateNames(); len(names) > 0 {
+		context = append(context, tprintf(s, "covering %s", escape(s, strings.Join(names, ", "))))
+	}
+	if expiresAt, ok := e.ExpiresAt(); ok {
+		context = append(context, tprintf(s, "expiring on %s", expiresAt.Format(certificateDateFormat)))
+	}
+	if len(context) == 0 {
+		return ""
+	}
+	return " " + strings.Join(context, ", ")
+}
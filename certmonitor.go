@@ -0,0 +1,187 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultCertificateDays is the threshold of the certificates expiring without auto-renewal.
+	defaultCertificateDays = 30
+
+	// defaultCertificateRenewalDays is the threshold of the auto-renewed certificates:
+	// DNSimple renews them 30 days before their expiration, so they are stuck if still not renewed.
+	defaultCertificateRenewalDays = 21
+)
+
+// CertificateMonitorConfig configures the checks of the certificates expiring soon,
+// with the DNSimple API independently of the webhooks.
+type CertificateMonitorConfig struct {
+	// Destinations are the names of the destinations receiving the alerts.
+	Destinations []string `json:"destinations"`
+
+	// Accounts are the IDs of the accounts whose certificates are checked.
+	// Defaults to the accounts accessible with the API token.
+	Accounts []int64 `json:"accounts,omitempty"`
+
+	// Days is the threshold, in days before the expiration, of the certificates without auto-renewal. Defaults to 30.
+	Days int `json:"days,omitempty"`
+
+	// RenewalDays is the threshold, in days before the expiration, of the certificates with auto-renewal,
+	// whose renewal appears stuck. Defaults to 21.
+	RenewalDays int `json:"renewal_days,omitempty"`
+
+	// Schedule is a cron expression for the checks, in the Timezone. Defaults to every night at 02:00.
+	Schedule string `json:"schedule,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (c *CertificateMonitorConfig) validate(destinations map[string]bool, api *APIConfig) error {
+	if c == nil {
+		return nil
+	}
+	if api == nil {
+		return fmt.Errorf("certificate monitor: the api is required")
+	}
+	if len(c.Destinations) == 0 {
+		return fmt.Errorf("certificate monitor: missing destinations")
+	}
+	for _, name := range c.Destinations {
+		if !destinations[name] {
+			return fmt.Errorf("certificate monitor: unknown destination %q", name)
+		}
+	}
+	if c.Days < 0 || c.RenewalDays < 0 {
+		return fmt.Errorf("certificate monitor: days must be positive")
+	}
+	if _, err := parseCron(c.schedule()); err != nil {
+		return fmt.Errorf("certificate monitor: %v", err)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("certificate monitor: %v", err)
+	}
+	return nil
+}
+
+func (c *CertificateMonitorConfig) schedule() string {
+	if c.Schedule == "" {
+		return defaultReminderSchedule
+	}
+	return c.Schedule
+}
+
+func (c *CertificateMonitorConfig) days() int {
+	if c.Days == 0 {
+		return defaultCertificateDays
+	}
+	return c.Days
+}
+
+func (c *CertificateMonitorConfig) renewalDays() int {
+	if c.RenewalDays == 0 {
+		return defaultCertificateRenewalDays
+	}
+	return c.RenewalDays
+}
+
+// ProcessCertificateChecks checks the certificates expiring soon when the checks are scheduled,
+// checking the schedule at every interval, until done is closed or the server is shut down.
+func (s *Server) ProcessCertificateChecks(interval time.Duration, done <-chan struct{}) {
+	s.processSchedule(interval, done, s.certificateChecksDue, s.checkCertificates)
+}
+
+// certificateChecksDue returns true if the checks of the certificates are scheduled at the minute.
+func (s *Server) certificateChecksDue(minute time.Time) bool {
+	config := s.currentRouting().config.CertificateMonitor
+	return config != nil && scheduleDue(config.schedule(), config.Timezone, minute)
+}
+
+// checkCertificates alerts about the latest certificate of each name expiring within the threshold:
+// the certificates without auto-renewal are about to expire, those with auto-renewal weren't renewed in time.
+func (s *Server) checkCertificates(ctx context.Context, now time.Time) {
+	routing := s.currentRouting()
+	config := routing.config.CertificateMonitor
+	if config == nil || routing.api == nil {
+		return
+	}
+
+	accountIDs, err := routing.scheduledAccounts(ctx, config.Accounts)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching the accounts of the certificate monitor")
+		return
+	}
+
+	for _, accountID := range accountIDs {
+		domains, err := listDomains(ctx, routing.api, accountID)
+		if err != nil {
+			log.Error().Err(err).Int64("account_id", accountID).Msg("Error listing the domains of the certificate monitor")
+			continue
+		}
+		for _, domain := range domains {
+			certificates, err := listCertificates(ctx, routing.api, accountID, domain.ID)
+			if err != nil {
+				log.Error().Err(err).Int64("account_id", accountID).Str("domain", domain.Name).Msg("Error listing the certificates of the certificate monitor")
+				continue
+			}
+			for _, certificate := range latestCertificates(certificates) {
+				event := &CertificateEvent{Certificate: certificate}
+				expiresAt, ok := event.ExpiresAt()
+				if !ok {
+					continue
+				}
+				days := daysUntil(now, expiresAt)
+				threshold := config.days()
+				if certificate.AutoRenew {
+					threshold = config.renewalDays()
+				}
+				if days < 0 || days > threshold {
+					continue
+				}
+				routing.postScheduled(ctx, config.Destinations, func(s Formatter) string {
+					return certificateAlertMessage(s, accountID, certificate, expiresAt)
+				})
+			}
+		}
+	}
+}
+
+// latestCertificates returns the issued certificate expiring last for each common name,
+// ignoring the certificates already replaced by a renewal.
+func latestCertificates(certificates []dnsimple.Certificate) []*dnsimple.Certificate {
+	var names []string
+	latest := map[string]*dnsimple.Certificate{}
+	for i := range certificates {
+		certificate := &certificates[i]
+		if certificate.State != "issued" {
+			continue
+		}
+		current, ok := latest[certificate.CommonName]
+		if !ok {
+			names = append(names, certificate.CommonName)
+		}
+		// The expiration dates of the API are in UTC, so that they compare as strings.
+		if !ok || certificate.ExpiresAt > current.ExpiresAt {
+			latest[certificate.CommonName] = certificate
+		}
+	}
+	result := make([]*dnsimple.Certificate, 0, len(names))
+	for _, name := range names {
+		result = append(result, latest[name])
+	}
+	return result
+}
+
+// certificateAlertMessage returns the alert of the certificate expiring soon.
+func certificateAlertMessage(s Formatter, accountID int64, certificate *dnsimple.Certificate, expiresAt time.Time) string {
+	account := scheduledAccountLink(s, accountID)
+	certificateLink := s.FormatLink(certificate.CommonName, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%d/certificates/%d", accountID, certificate.DomainID, certificate.ID))
+	date := expiresAt.Format(certificateDateFormat)
+	if certificate.AutoRenew {
+		return fmt.Sprintf("[%s] %s", account, tprintf(s, "The certificate %s expires on %s, and its auto-renewal appears stuck", certificateLink, date))
+	}
+	return fmt.Sprintf("[%s] %s", account, tprintf(s, "The certificate %s expires on %s, and auto-renewal is disabled", certificateLink, date))
+}
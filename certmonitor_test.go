@@ -0,0 +1,85 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_CheckCertificates(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/accounts":
+			fmt.Fprint(w, `{"data": [{"id": 1010, "email": "ops@example.com"}]}`)
+		case "/v2/1010/domains":
+			fmt.Fprint(w, `{"data": [{"id": 1, "name": "example.com"}], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 1, "total_pages": 1}}`)
+		case "/v2/1010/domains/1/certificates":
+			fmt.Fprint(w, `{"data": [
+				{"id": 10, "domain_id": 1, "common_name": "www.example.com", "state": "issued", "auto_renew": true, "expires_at": "2021-03-15T00:00:00Z"},
+				{"id": 11, "domain_id": 1, "common_name": "api.example.com", "state": "issued", "auto_renew": true, "expires_at": "2021-03-15T00:00:00Z"},
+				{"id": 12, "domain_id": 1, "common_name": "api.example.com", "state": "issued", "auto_renew": true, "expires_at": "2021-05-30T00:00:00Z"},
+				{"id": 13, "domain_id": 1, "common_name": "shop.example.com", "state": "issued", "auto_renew": false, "expires_at": "2021-03-25T00:00:00Z"},
+				{"id": 14, "domain_id": 1, "common_name": "blog.example.com", "state": "issued", "auto_renew": false, "expires_at": "2021-06-25T00:00:00Z"},
+				{"id": 15, "domain_id": 1, "common_name": "old.example.com", "state": "issued", "auto_renew": false, "expires_at": "2021-02-25T00:00:00Z"},
+				{"id": 16, "domain_id": 1, "common_name": "new.example.com", "state": "requesting", "auto_renew": false}
+			], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 7, "total_pages": 1}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"api": {"token": "api-token", "url": %q},
+		"accounts": {"1010": "Production"},
+		"certificate_monitor": {"destinations": ["ops"]}
+	}`, api.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	service := &failingService{SlackService: SlackService{AccountLabels: map[int64]string{1010: "Production"}}}
+	server.routing.services["ops"] = service
+
+	server.checkCertificates(context.Background(), time.Date(2021, 3, 1, 2, 0, 0, 0, time.UTC))
+
+	want := []string{
+		"[<https://dnsimple.com/a/1010/account|Production>] The certificate <https://dnsimple.com/a/1010/domains/1/certificates/10|www.example.com> expires on 2021-03-15, and its auto-renewal appears stuck",
+		"[<https://dnsimple.com/a/1010/account|Production>] The certificate <https://dnsimple.com/a/1010/domains/1/certificates/13|shop.example.com> expires on 2021-03-25, and auto-renewal is disabled",
+	}
+	if got := service.messages; strings.Join(want, "\n") != strings.Join(got, "\n") {
+		t.Errorf("checkCertificates expected\n%v\ngot\n%v", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestCertificateMonitorConfig_validate(t *testing.T) {
+	destinations := map[string]bool{"ops": true}
+	api := &APIConfig{Token: "api-token"}
+	tests := []struct {
+		config *CertificateMonitorConfig
+		api    *APIConfig
+		valid  bool
+	}{
+		{nil, nil, true},
+		{&CertificateMonitorConfig{Destinations: []string{"ops"}, Days: 14, RenewalDays: 7, Schedule: "0 9 * * 1-5", Timezone: "Europe/Rome"}, api, true},
+		{&CertificateMonitorConfig{Destinations: []string{"ops"}}, nil, false},
+		{&CertificateMonitorConfig{}, api, false},
+		{&CertificateMonitorConfig{Destinations: []string{"pager"}}, api, false},
+		{&CertificateMonitorConfig{Destinations: []string{"ops"}, Days: -1}, api, false},
+		{&CertificateMonitorConfig{Destinations: []string{"ops"}, Timezone: "Mars/Olympus"}, api, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate(destinations, test.api)
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// ChangeWindowPolicy flags the changes made outside the approved windows, such as the NS and MX records
+// of the production domains changed on a weekend, with an out-of-window change alert.
+type ChangeWindowPolicy struct {
+	Name string `json:"name"`
+
+	// Events are the event name patterns matched by the policy, using the path.Match syntax (e.g. "zone_record.*").
+	// An empty list matches every event.
+	Events []string `json:"events,omitempty"`
+
+	// Domains are the domain or zone name patterns matched by the policy, using the path.Match syntax.
+	// An empty list matches every domain.
+	Domains []string `json:"domains,omitempty"`
+
+	// RecordTypes restrict the policy to the zone_record.* events about the records of the types, such as NS or MX.
+	RecordTypes []string `json:"record_types,omitempty"`
+
+	// Windows are the approved windows: the changes made outside all of them are flagged.
+	Windows []ChangeWindowConfig `json:"windows"`
+
+	// Destinations are the names of the destinations receiving the out-of-window change alerts.
+	Destinations []string `json:"destinations"`
+
+	// Page are the names of the destinations also receiving the events themselves, such as a pager
+	// or an SMS gateway registered with RegisterDestination, optional.
+	Page []string `json:"page,omitempty"`
+}
+
+// ChangeWindowConfig is an approved window, starting at every time matching Schedule and lasting Duration.
+type ChangeWindowConfig struct {
+	// Schedule is a cron expression (minute, hour, day of month, month, day of week)
+	// for the start of the window, e.g. "0 9 * * 1-5" for the weekdays at 9:00.
+	Schedule string   `json:"schedule"`
+	Duration Duration `json:"duration"`
+
+	// Timezone is the IANA timezone of the schedule, e.g. "Europe/Rome". Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (c *ChangeWindowConfig) quietWindow() *QuietWindowConfig {
+	return &QuietWindowConfig{Schedule: c.Schedule, Duration: c.Duration, Timezone: c.Timezone}
+}
+
+func validateChangeWindows(policies []ChangeWindowPolicy, destinations map[string]bool) error {
+	names := make(map[string]bool, len(policies))
+	for i, policy := range policies {
+		if policy.Name == "" {
+			return fmt.Errorf("change window policy #%d: missing name", i)
+		}
+		if names[policy.Name] {
+			return fmt.Errorf("change window policy %q: duplicate name", policy.Name)
+		}
+		names[policy.Name] = true
+		if err := policy.validate(destinations); err != nil {
+			return fmt.Errorf("change window policy %q: %v", policy.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p *ChangeWindowPolicy) validate(destinations map[string]bool) error {
+	for _, pattern := range append(append([]string{}, p.Events...), p.Domains...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	if len(p.Windows) == 0 {
+		return errors.New("missing windows")
+	}
+	for _, window := range p.Windows {
+		if window.Schedule == "" {
+			return errors.New("a window requires a schedule")
+		}
+		if err := window.quietWindow().validate(); err != nil {
+			return err
+		}
+	}
+	if len(p.Destinations) == 0 && len(p.Page) == 0 {
+		return errors.New("missing destinations")
+	}
+	for _, name := range append(append([]string{}, p.Destinations...), p.Page...) {
+		if !destinations[name] {
+			return fmt.Errorf("unknown destination %q", name)
+		}
+	}
+	return nil
+}
+
+func (p *ChangeWindowPolicy) matches(event *webhook.Event) bool {
+	if len(p.Events) > 0 && !matchesAny(p.Events, event.Name) {
+		return false
+	}
+	if len(p.Domains) > 0 && !matchesAny(p.Domains, strings.ToLower(eventDomain(event))) {
+		return false
+	}
+	if len(p.RecordTypes) == 0 {
+		return true
+	}
+	if !strings.HasPrefix(event.Name, "zone_record.") {
+		return false
+	}
+	data := parseZoneRecordEvent(event)
+	if data == nil || data.ZoneRecord == nil {
+		return false
+	}
+	for _, recordType := range p.RecordTypes {
+		if strings.EqualFold(recordType, data.ZoneRecord.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// approved returns true if one of the windows is active at now.
+func (p *ChangeWindowPolicy) approved(now time.Time) bool {
+	for i := range p.Windows {
+		if _, ok := p.Windows[i].quietWindow().activeUntil(now); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChangeWindows alerts the destinations of the policies flagging the event, changed outside their approved windows,
+// and pages their pagers with the event.
+func (s *Server) checkChangeWindows(ctx context.Context, routing *routingTable, event *webhook.Event, now time.Time) {
+	for i := range routing.config.ChangeWindows {
+		policy := &routing.config.ChangeWindows[i]
+		if !policy.matches(event) || policy.approved(now) {
+			continue
+		}
+		withEvent(log.Warn(), event).Str("tenant", routing.name).Str("policy", policy.Name).Msg("Out-of-window change")
+
+		for _, name := range policy.Destinations {
+			service := routing.services[name]
+			text := outOfWindowAlert(unwrapService(service), policy, event)
+			if err := routing.postMessage(ctx, service, text); err != nil {
+				log.Error().Err(err).Str("tenant", routing.name).Str("destination", name).Str("policy", policy.Name).Msg("Error sending the out-of-window change alert")
+			}
+		}
+		for _, name := range policy.Page {
+			postCtx, cancel := routing.deliveryContext(ctx)
+			_, err := routing.services[name].PostEvent(postCtx, event)
+			cancel()
+			if err != nil {
+				log.Error().Err(err).Str("tenant", routing.name).Str("destination", name).Str("policy", policy.Name).Msg("Error paging the out-of-window change")
+			}
+		}
+	}
+}
+
+// outOfWindowAlert returns the message flagging the event changed outside the approved windows of the policy.
+func outOfWindowAlert(s Formatter, policy *ChangeWindowPolicy, event *webhook.Event) string {
+	return tprintf(s, "Out-of-window change (%s): %s", escape(s, policy.Name), Message(s, event))
+}
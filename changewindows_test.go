@@ -0,0 +1,118 @@
+package strillone
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestChangeWindowPolicy_matches(t *testing.T) {
+	policy := &ChangeWindowPolicy{Events: []string{"zone_record.*"}, Domains: []string{"example.com"}, RecordTypes: []string{"NS", "MX"}}
+	tests := []struct {
+		payload string
+		want    bool
+	}{
+		{`{"name": "zone_record.update", "data": {"zone_record": {"zone_id": "example.com", "type": "NS", "content": "ns1.example.net"}}}`, true},
+		{`{"name": "zone_record.delete", "data": {"zone_record": {"zone_id": "Example.com", "type": "mx", "content": "mx.example.net"}}}`, true},
+		{`{"name": "zone_record.update", "data": {"zone_record": {"zone_id": "example.com", "type": "A", "content": "1.2.3.4"}}}`, false},
+		{`{"name": "zone_record.update", "data": {"zone_record": {"zone_id": "example.org", "type": "NS", "content": "ns1.example.net"}}}`, false},
+		{`{"name": "domain.delete", "data": {"domain": {"name": "example.com"}}}`, false},
+	}
+	for _, test := range tests {
+		event, err := webhook.ParseEvent([]byte(test.payload))
+		if err != nil {
+			t.Fatalf("ParseEvent returned error: %v", err)
+		}
+		if got := policy.matches(event); test.want != got {
+			t.Errorf("matches(%v) expected %v, got %v", test.payload, test.want, got)
+		}
+	}
+}
+
+func TestChangeWindowPolicy_approved(t *testing.T) {
+	policy := &ChangeWindowPolicy{Windows: []ChangeWindowConfig{{Schedule: "0 9 * * 1-5", Duration: Duration(8 * time.Hour), Timezone: "Europe/Rome"}}}
+	tests := []struct {
+		now  string
+		want bool
+	}{
+		{"2021-03-03T10:00:00Z", true},
+		{"2021-03-03T16:30:00Z", false},
+		{"2021-03-06T10:00:00Z", false},
+	}
+	for _, test := range tests {
+		now, _ := time.Parse(time.RFC3339, test.now)
+		if got := policy.approved(now); test.want != got {
+			t.Errorf("approved(%v) expected %v, got %v", test.now, test.want, got)
+		}
+	}
+}
+
+func Test_validateChangeWindows(t *testing.T) {
+	destinations := map[string]bool{"ops": true}
+	window := []ChangeWindowConfig{{Schedule: "0 9 * * 1-5", Duration: Duration(8 * time.Hour)}}
+	if err := validateChangeWindows([]ChangeWindowPolicy{{Name: "prod", Windows: window, Destinations: []string{"ops"}}}, destinations); err != nil {
+		t.Errorf("validateChangeWindows returned error: %v", err)
+	}
+	tests := [][]ChangeWindowPolicy{
+		{{Windows: window, Destinations: []string{"ops"}}},
+		{{Name: "prod", Destinations: []string{"ops"}}},
+		{{Name: "prod", Windows: []ChangeWindowConfig{{Schedule: "0 9 * * 1-5"}}, Destinations: []string{"ops"}}},
+		{{Name: "prod", Windows: window}},
+		{{Name: "prod", Windows: window, Destinations: []string{"ops"}, Page: []string{"pager"}}},
+		{{Name: "prod", Windows: window, Destinations: []string{"ops"}}, {Name: "prod", Windows: window, Destinations: []string{"ops"}}},
+	}
+	for _, policies := range tests {
+		if err := validateChangeWindows(policies, destinations); err == nil {
+			t.Errorf("validateChangeWindows(%+v) expected error", policies)
+		}
+	}
+}
+
+func TestServer_checkChangeWindows(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "pager", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [{"destinations": ["ops"]}],
+		"change_windows": [
+			{
+				"name": "production DNS",
+				"domains": ["example.com"],
+				"record_types": ["NS", "MX"],
+				"windows": [{"schedule": "0 9 * * 1-5", "duration": "8h"}],
+				"destinations": ["ops"],
+				"page": ["pager"]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops, pager := &failingService{}, &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["pager"] = pager
+
+	event, _ := webhook.ParseEvent([]byte(`{"name": "zone_record.update", "data": {"zone_record": {"id": 1, "zone_id": "example.com", "type": "NS", "name": "", "content": "ns1.example.net"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`))
+	weekday, _ := time.Parse(time.RFC3339, "2021-03-03T10:00:00Z")
+	server.checkChangeWindows(context.Background(), server.routing, event, weekday)
+	if len(ops.messages) != 0 || pager.sent != 0 {
+		t.Errorf("checkChangeWindows expected no alert in the window, got %v messages and %v pages", len(ops.messages), pager.sent)
+	}
+
+	weekend, _ := time.Parse(time.RFC3339, "2021-03-06T10:00:00Z")
+	server.checkChangeWindows(context.Background(), server.routing, event, weekend)
+	if want, got := 1, len(ops.messages); want != got {
+		t.Fatalf("checkChangeWindows expected %v alert, got %v", want, got)
+	}
+	if want, got := "Out-of-window change (production DNS): ", ops.messages[0]; !strings.HasPrefix(got, want) {
+		t.Errorf("checkChangeWindows expected an alert starting with %q, got %q", want, got)
+	}
+	if want, got := 1, pager.sent; want != got {
+		t.Errorf("checkChangeWindows expected %v page, got %v", want, got)
+	}
+}
@@ -0,0 +1,76 @@
+package strillone
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// cloudFunction is the server of the Cloud Function, created on the first request.
+var cloudFunction struct {
+	once    sync.Once
+	handler http.Handler
+}
+
+// CloudFunction is the entry point of the HTTP Google Cloud Function:
+//
+//	gcloud functions deploy strillone --runtime go113 --trigger-http --entry-point CloudFunction
+//
+// It serves the webhooks with the configuration in STRILLONE_CONFIG, a file deployed with the function
+// or a secret reference such as gcp-sm:strillone-config, and delivers through Pub/Sub
+// when STRILLONE_PUBSUB_TOPIC is set.
+func CloudFunction(w http.ResponseWriter, r *http.Request) {
+	cloudFunction.once.Do(func() {
+		cloudFunction.handler = newCloudFunctionHandler()
+	})
+	cloudFunction.handler.ServeHTTP(w, r)
+}
+
+// newCloudFunctionHandler returns the server configured with the environment,
+// or a handler responding with an error when the configuration is invalid.
+func newCloudFunctionHandler() http.Handler {
+	logging := &LoggingOptions{Level: os.Getenv("STRILLONE_LOG_LEVEL")}
+	if err := SetupLogging(logging); err != nil {
+		return cloudFunctionError(err)
+	}
+
+	secrets := NewSecretsFromEnv()
+	server := NewServer(nil)
+	if os.Getenv("STRILLONE_DRY_RUN") != "" {
+		server.EnableDryRun()
+	}
+	if err := server.SetSecrets(secrets); err != nil {
+		return cloudFunctionError(err)
+	}
+	location := os.Getenv("STRILLONE_CONFIG")
+	if location == "" {
+		return cloudFunctionError(errors.New("STRILLONE_CONFIG is required"))
+	}
+	config, err := LoadConfigFrom(secrets, location)
+	if err != nil {
+		return cloudFunctionError(err)
+	}
+	if err := server.Reload(config); err != nil {
+		return cloudFunctionError(err)
+	}
+
+	topic, token, err := PubSubFromEnv()
+	if err != nil {
+		return cloudFunctionError(err)
+	}
+	if topic != nil {
+		server.SetPubSub(topic, token)
+	}
+	return server
+}
+
+// cloudFunctionError logs the error of the configuration, and responds with an error to every request.
+func cloudFunctionError(err error) http.Handler {
+	log.Error().Err(err).Msg("Error configuring the Cloud Function")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+	})
+}
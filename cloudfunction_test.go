@@ -0,0 +1,38 @@
+package strillone
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func Test_newCloudFunctionHandler(t *testing.T) {
+	file, err := ioutil.TempFile("", "strillone-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}]}`)
+	file.Close()
+
+	defer os.Setenv("STRILLONE_CONFIG", os.Getenv("STRILLONE_CONFIG"))
+	tests := []struct {
+		config string
+		want   int
+	}{
+		{file.Name(), http.StatusOK},
+		{"", http.StatusInternalServerError},
+		{file.Name() + ".missing", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		os.Setenv("STRILLONE_CONFIG", tt.config)
+		request, _ := http.NewRequest("GET", "/", nil)
+		response := httptest.NewRecorder()
+		newCloudFunctionHandler().ServeHTTP(response, request)
+		if tt.want != response.Code {
+			t.Errorf("newCloudFunctionHandler with %q expected HTTP %v, got %v", tt.config, tt.want, response.Code)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Command strillone-lambda runs Strillone in AWS Lambda, behind an API Gateway REST API,
+// an HTTP API or a function URL.
+//
+// The configuration is read from STRILLONE_CONFIG, either a file bundled with the function
+// or a secret reference such as aws-ssm:/strillone/config.
+package main
+
+import (
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/dnsimple/strillone"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// Program name
+	Program = "dnsimple-strillone"
+
+	// Version is replaced at compilation time
+	Version string
+)
+
+func main() {
+	logging := &strillone.LoggingOptions{
+		Level:  os.Getenv("STRILLONE_LOG_LEVEL"),
+		Pretty: os.Getenv("STRILLONE_LOG_PRETTY") != "",
+	}
+	if err := strillone.SetupLogging(logging); err != nil {
+		log.Fatal().Err(err).Msg("Error configuring the logs")
+	}
+
+	log.Info().Str("version", Version).Msgf("Starting %s in AWS Lambda", Program)
+
+	secrets := strillone.NewSecretsFromEnv()
+	server := strillone.NewServer(nil)
+	if os.Getenv("STRILLONE_DRY_RUN") != "" {
+		server.EnableDryRun()
+	}
+	if err := server.SetSecrets(secrets); err != nil {
+		log.Fatal().Err(err).Msg("Error loading secrets")
+	}
+
+	// The configuration is loaded once per execution environment, on cold start.
+	configLocation := os.Getenv("STRILLONE_CONFIG")
+	if configLocation == "" {
+		log.Fatal().Msg("STRILLONE_CONFIG is required")
+	}
+	config, err := strillone.LoadConfigFrom(secrets, configLocation)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error loading configuration")
+	}
+	if err := server.Reload(config); err != nil {
+		log.Fatal().Err(err).Msg("Error loading configuration")
+	}
+
+	if os.Getenv("STRILLONE_DEBUG") != "" {
+		server.EnableDebug()
+	}
+
+	lambda.StartHandler(&strillone.LambdaHandler{Handler: server})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/dnsimple/strillone"
+)
+
+// dev runs the `strillone dev` subcommand, that serves the webhooks with the configuration in STRILLONE_CONFIG,
+// delivering the messages of every destination to a fake Slack shown on a local web page.
+// The configuration and the templates are loaded again for every webhook.
+func dev(args []string) int {
+	flags := flag.NewFlagSet("dev", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s dev [flags]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", os.Getenv("STRILLONE_CONFIG"), "configuration file")
+	port := flags.String("port", "4000", "port of the webhooks and of the page")
+	flags.Parse(args)
+	if *configPath == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	load := func() (*strillone.Config, error) {
+		return strillone.LoadConfig(*configPath)
+	}
+	if _, err := load(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		return 1
+	}
+	server := strillone.NewServer(nil)
+	if _, err := setSecrets(server); err != nil {
+		fmt.Fprintf(os.Stderr, "secrets: %v\n", err)
+		return 1
+	}
+
+	url := "http://localhost:" + *port
+	receiver := strillone.NewDevReceiver(url)
+	fmt.Printf("Messages: %s\nWebhooks: %s/events\n", url, url)
+	if err := http.ListenAndServe(":"+*port, strillone.NewDevHandler(server, receiver, load)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dnsimple/strillone"
+)
+
+// export runs the `strillone export` subcommand, that writes the events of the history in STRILLONE_HISTORY
+// matching a search to the standard output, as CSV or NDJSON.
+// It returns the exit status: 1 if the history could not be read.
+func export(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s export [flags] [search]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	historyPath := flags.String("history", os.Getenv("STRILLONE_HISTORY"), "history file, or PostgreSQL URL")
+	format := flags.String("format", "csv", "output format: "+strings.Join(strillone.HistoryExportFormats, " or "))
+	flags.Parse(args)
+	if *historyPath == "" {
+		flags.Usage()
+		return 2
+	}
+
+	filter, err := strillone.ParseHistorySearch(strings.Join(flags.Args(), " "))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "search: %v\n", err)
+		return 2
+	}
+	cipher, err := loadCipher(strillone.NewSecretsFromEnv())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "STRILLONE_ENCRYPTION_KEY: %v\n", err)
+		return 1
+	}
+	store, err := strillone.OpenStore(*historyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *historyPath, err)
+		return 1
+	}
+	history, err := store.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *historyPath, err)
+		return 1
+	}
+	defer history.Close()
+	if cipher != nil {
+		history = strillone.EncryptHistory(history, cipher)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	if err := strillone.ExportHistory(out, history, filter, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *historyPath, err)
+		return 1
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	return 0
+}
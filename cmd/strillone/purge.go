@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/strillone"
+)
+
+// purge runs the `strillone purge` subcommand, that removes the events of the history in STRILLONE_HISTORY,
+// and the dead letters of the queue in STRILLONE_QUEUE, matching an email or a domain, and writes the deletion report
+// to the standard output. It returns the exit status: 1 if the events could not be purged.
+func purge(args []string) int {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s purge [flags]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	historyPath := flags.String("history", os.Getenv("STRILLONE_HISTORY"), "history file, or PostgreSQL URL")
+	queuePath := flags.String("queue", os.Getenv("STRILLONE_QUEUE"), "queue file, or PostgreSQL or Redis URL, optional")
+	request := &strillone.PurgeRequest{}
+	flags.StringVar(&request.Email, "email", "", "email address of the contact")
+	flags.StringVar(&request.Domain, "domain", "", "domain name, including its subdomains")
+	flags.BoolVar(&request.DryRun, "dry-run", false, "report the events without removing them")
+	flags.Parse(args)
+	if *historyPath == "" || (request.Email == "" && request.Domain == "") {
+		flags.Usage()
+		return 2
+	}
+
+	cipher, err := loadCipher(strillone.NewSecretsFromEnv())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "STRILLONE_ENCRYPTION_KEY: %v\n", err)
+		return 1
+	}
+	store, err := strillone.OpenStore(*historyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *historyPath, err)
+		return 1
+	}
+	history, err := store.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *historyPath, err)
+		return 1
+	}
+	defer history.Close()
+	if cipher != nil {
+		history = strillone.EncryptHistory(history, cipher)
+	}
+
+	var queue strillone.Queue
+	if *queuePath != "" {
+		if strings.HasPrefix(*queuePath, "redis://") || strings.HasPrefix(*queuePath, "rediss://") {
+			queue, err = strillone.OpenRedisQueue(*queuePath)
+		} else if store, err = strillone.OpenStore(*queuePath); err == nil {
+			queue, err = store.Queue()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *queuePath, err)
+			return 1
+		}
+		defer queue.Close()
+		if cipher != nil {
+			queue = strillone.EncryptQueue(queue, cipher)
+		}
+	}
+
+	report, err := strillone.PurgeEvents(history, queue, request, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "purge: %v\n", err)
+		return 1
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		fmt.Fprintf(os.Stderr, "purge: %v\n", err)
+		return 1
+	}
+	return 0
+}
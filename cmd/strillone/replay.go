@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dnsimple/strillone"
+	"github.com/rs/zerolog/log"
+)
+
+// replay runs the `strillone replay` subcommand, that delivers again the events of archives,
+// NDJSON files or directories of payload files, with the configuration in STRILLONE_CONFIG.
+// It returns the exit status: 1 if an event could not be replayed.
+func replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s replay [flags] <file or directory>...\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", os.Getenv("STRILLONE_CONFIG"), "configuration file")
+	tenant := flags.String("tenant", "", "tenant whose routes and destinations are used")
+	destinations := flags.String("destination", "", "comma-separated destinations overriding the routes")
+	dryRun := flags.Bool("dry-run", false, "print the messages without delivering them")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var config *strillone.Config
+	if *configPath != "" {
+		var err error
+		if config, err = strillone.LoadConfig(*configPath); err != nil {
+			log.Fatal().Err(err).Msg("Error loading configuration")
+		}
+	}
+	server := strillone.NewServer(nil)
+	if _, err := setSecrets(server); err != nil {
+		log.Fatal().Err(err).Msg("Error loading secrets")
+	}
+	if config != nil {
+		if err := server.Reload(config); err != nil {
+			log.Fatal().Err(err).Msg("Error loading configuration")
+		}
+	}
+
+	options := &strillone.ReplayOptions{
+		Tenant:       *tenant,
+		Destinations: splitList(*destinations),
+		DryRun:       *dryRun,
+	}
+	status := 0
+	for _, path := range flags.Args() {
+		payloads, err := strillone.ReadPayloads(path)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error reading the events")
+		}
+		for _, payload := range payloads {
+			result, err := server.Replay(context.Background(), payload, options)
+			if err != nil {
+				log.Error().Err(err).Str("path", path).Msg("Error replaying the event")
+				status = 1
+				continue
+			}
+			if printReplayResult(result) {
+				status = 1
+			}
+		}
+	}
+
+	// The shutdown sends the events held by the rate limits.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Error shutting down")
+		return 1
+	}
+	return status
+}
+
+// printReplayResult prints the outcome of the replay of an event, and returns true if it failed.
+func printReplayResult(result *strillone.ReplayResult) (failed bool) {
+	fmt.Printf("%s %s\n", result.RequestID, result.Event)
+
+	var names []string
+	for name := range result.Messages {
+		names = append(names, name)
+	}
+	for name := range result.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		fmt.Println("  no route matches")
+	}
+	for _, name := range names {
+		if err, ok := result.Errors[name]; ok {
+			fmt.Printf("  %s: error: %v\n", name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("  %s: %s\n", name, result.Messages[name])
+	}
+	return failed
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+
+	"github.com/dnsimple/strillone"
+)
+
+// setSecrets sets the secrets resolved with the backends configured in the environment on the server,
+// along with the encryption key of STRILLONE_ENCRYPTION_KEY. It returns the cipher of the key, nil when not set.
+func setSecrets(server *strillone.Server) (*strillone.Cipher, error) {
+	secrets := strillone.NewSecretsFromEnv()
+	cipher, err := loadCipher(secrets)
+	if err != nil {
+		return nil, err
+	}
+	if cipher != nil {
+		server.SetCipher(cipher)
+	}
+	return cipher, server.SetSecrets(secrets)
+}
+
+// loadCipher returns the cipher of the encryption key of STRILLONE_ENCRYPTION_KEY, registered in the secrets
+// to decrypt the encrypted credentials, or nil when not set.
+func loadCipher(secrets *strillone.Secrets) (*strillone.Cipher, error) {
+	key := os.Getenv("STRILLONE_ENCRYPTION_KEY")
+	if key == "" {
+		return nil, nil
+	}
+	cipher, err := strillone.LoadCipher(secrets, key)
+	if err != nil {
+		return nil, err
+	}
+	secrets.Register(strillone.EncryptionScheme, cipher)
+	return cipher, nil
+}
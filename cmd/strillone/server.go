@@ -1,11 +1,18 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/dnsimple/strillone"
+	"github.com/rs/zerolog/log"
 )
 
 var (
@@ -17,17 +24,425 @@ var (
 )
 
 func main() {
-	log.Printf("Starting %s/%s\n", Program, Version)
+	logging := &strillone.LoggingOptions{
+		Level:  os.Getenv("STRILLONE_LOG_LEVEL"),
+		Pretty: os.Getenv("STRILLONE_LOG_PRETTY") != "",
+	}
+	if err := strillone.SetupLogging(logging); err != nil {
+		log.Fatal().Err(err).Msg("Error configuring the logs")
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(replay(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "test-event" {
+		os.Exit(testEvent(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "dev" {
+		os.Exit(dev(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(export(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		os.Exit(purge(os.Args[2:]))
+	}
+
+	dryRun := flag.Bool("dry-run", os.Getenv("STRILLONE_DRY_RUN") != "", "log the messages and return them in the responses instead of delivering them")
+	flag.Parse()
+
+	log.Info().Str("version", Version).Msgf("Starting %s", Program)
 
 	httpPort := os.Getenv("PORT")
 	if httpPort == "" {
 		httpPort = "4000"
 	}
 
-	server := strillone.NewServer()
+	var config *strillone.Config
+	configPath := os.Getenv("STRILLONE_CONFIG")
+	if configPath != "" {
+		var err error
+		if config, err = strillone.LoadConfig(configPath); err != nil {
+			log.Fatal().Err(err).Msg("Error loading configuration")
+		}
+	}
+
+	shutdownTracing := setupTracing()
+
+	server := strillone.NewServer(nil)
+	if *dryRun {
+		server.EnableDryRun()
+	}
+	cipher, err := setSecrets(server)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error loading secrets")
+	}
+	if config != nil {
+		if err := server.Reload(config); err != nil {
+			log.Fatal().Err(err).Msg("Error loading configuration")
+		}
+	}
+	if interval, err := time.ParseDuration(os.Getenv("STRILLONE_SECRETS_REFRESH")); err == nil {
+		server.WatchSecrets(interval, nil)
+	}
+
+	if election := os.Getenv("STRILLONE_LEADER_ELECTION"); election != "" {
+		var elector strillone.LeaderElector
+		if election == "kubernetes" {
+			name := os.Getenv("STRILLONE_LEADER_LEASE")
+			if name == "" {
+				name = "strillone"
+			}
+			elector, err = strillone.NewKubernetesElector(name, 0)
+		} else {
+			elector, err = strillone.NewRedisElector(election, 0)
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid STRILLONE_LEADER_ELECTION")
+		}
+		server.SetLeaderElector(elector)
+	}
+
+	go server.ProcessDigests(time.Minute, nil)
+	go server.ProcessSampling(time.Minute, nil)
+	go server.ProcessReminders(time.Minute, nil)
+	go server.ProcessCertificateChecks(time.Minute, nil)
+	go server.ProcessDriftChecks(time.Minute, nil)
+	go server.ProcessRegistrarAudits(time.Minute, nil)
+	go server.ProcessActivityReports(time.Minute, nil)
+	go server.ProcessApprovals(time.Minute, nil)
+
+	if os.Getenv("STRILLONE_DEBUG") != "" {
+		server.EnableDebug()
+	}
+
+	workers, depth := 4, 100
+	if os.Getenv("K_SERVICE") != "" {
+		// Cloud Run allocates the CPU only while serving the requests.
+		workers = 0
+	}
+	if env := os.Getenv("STRILLONE_WORKERS"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid STRILLONE_WORKERS")
+		}
+		workers = n
+	}
+	if env := os.Getenv("STRILLONE_QUEUE_DEPTH"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid STRILLONE_QUEUE_DEPTH")
+		}
+		depth = n
+	}
+	if workers > 0 {
+		server.SetWorkerPool(workers, depth)
+	}
+
+	topic, pubsubToken, err := strillone.PubSubFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error configuring Pub/Sub")
+	}
+	if topic != nil {
+		server.SetPubSub(topic, pubsubToken)
+	}
+
+	if historyPath := os.Getenv("STRILLONE_HISTORY"); historyPath != "" {
+		store, err := strillone.OpenStore(historyPath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error opening the history")
+		}
+		history, err := store.History()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error opening the history")
+		}
+		if cipher != nil {
+			history = strillone.EncryptHistory(history, cipher)
+		}
+		server.SetHistory(history)
+
+		retention := &strillone.HistoryRetention{}
+		if env := os.Getenv("STRILLONE_HISTORY_MAX_AGE"); env != "" {
+			if retention.MaxAge, err = time.ParseDuration(env); err != nil {
+				log.Fatal().Err(err).Msg("Invalid STRILLONE_HISTORY_MAX_AGE")
+			}
+		}
+		if env := os.Getenv("STRILLONE_HISTORY_MAX_EVENTS"); env != "" {
+			if retention.MaxEvents, err = strconv.Atoi(env); err != nil {
+				log.Fatal().Err(err).Msg("Invalid STRILLONE_HISTORY_MAX_EVENTS")
+			}
+		}
+		if location := os.Getenv("STRILLONE_HISTORY_ARCHIVE"); location != "" {
+			if retention.Archive, retention.ArchivePrefix, err = strillone.OpenArchiveStore(location); err != nil {
+				log.Fatal().Err(err).Msg("Error configuring the history archive")
+			}
+		}
+		if retention.MaxAge > 0 || retention.MaxEvents > 0 {
+			server.SetHistoryRetention(retention)
+			go server.ProcessHistoryRetention(time.Hour, nil)
+		}
+	}
+
+	if auditPath := os.Getenv("STRILLONE_AUDIT_LOG"); auditPath != "" {
+		audit, err := strillone.OpenFileAuditLog(auditPath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error opening the audit log")
+		}
+		server.SetAuditLog(audit)
+	}
+
+	if location := os.Getenv("STRILLONE_ARCHIVE"); location != "" {
+		store, prefix, err := strillone.OpenArchiveStore(location)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error configuring the archive")
+		}
+		interval := 5 * time.Minute
+		if env := os.Getenv("STRILLONE_ARCHIVE_INTERVAL"); env != "" {
+			if interval, err = time.ParseDuration(env); err != nil {
+				log.Fatal().Err(err).Msg("Invalid STRILLONE_ARCHIVE_INTERVAL")
+			}
+		}
+		server.SetArchive(store, prefix)
+		go server.ProcessArchive(interval, nil)
+	}
+
+	if redisURL := os.Getenv("STRILLONE_REDIS"); redisURL != "" {
+		if err := server.SetRedis(redisURL); err != nil {
+			log.Fatal().Err(err).Msg("Invalid STRILLONE_REDIS")
+		}
+	}
+
+	if queuePath := os.Getenv("STRILLONE_QUEUE"); queuePath != "" {
+		var queue strillone.Queue
+		if strings.HasPrefix(queuePath, "redis://") || strings.HasPrefix(queuePath, "rediss://") {
+			queue, err = strillone.OpenRedisQueue(queuePath)
+		} else {
+			var store strillone.Store
+			if store, err = strillone.OpenStore(queuePath); err == nil {
+				queue, err = store.Queue()
+			}
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error opening the queue")
+		}
+		if cipher != nil {
+			queue = strillone.EncryptQueue(queue, cipher)
+		}
+		maxAttempts, _ := strconv.Atoi(os.Getenv("STRILLONE_QUEUE_MAX_ATTEMPTS"))
+		server.SetQueue(queue, maxAttempts)
+		go server.ProcessQueue(time.Second, nil)
+	}
+
+	if configPath != "" {
+		server.SetConfigStore(&strillone.FileConfigStore{Path: configPath})
+		reloader := &strillone.ConfigReloader{Path: configPath, Server: server}
+		watchReloadSignal(reloader)
+		if os.Getenv("STRILLONE_CONFIG_WATCH") != "" {
+			if err := reloader.Watch(nil); err != nil {
+				log.Fatal().Err(err).Msg("Error watching configuration")
+			}
+		}
+	}
+
+	tlsOptions := &strillone.TLSOptions{
+		CertFile:         os.Getenv("STRILLONE_TLS_CERT"),
+		KeyFile:          os.Getenv("STRILLONE_TLS_KEY"),
+		AutocertDomains:  splitList(os.Getenv("STRILLONE_TLS_AUTOCERT_DOMAINS")),
+		AutocertCacheDir: os.Getenv("STRILLONE_TLS_AUTOCERT_CACHE"),
+		AutocertEmail:    os.Getenv("STRILLONE_TLS_AUTOCERT_EMAIL"),
+		ClientCAFile:     os.Getenv("STRILLONE_TLS_CLIENT_CA"),
+	}
+	listenerOptions := listenerOptionsFromEnv()
+	server.SetStreamTimeout(listenerOptions.WriteTimeout)
+	var httpServer *http.Server
+	if tlsOptions.Enabled() {
+		httpServer = listenAndServeTLS(server, httpPort, listenerOptions, tlsOptions)
+	} else {
+		httpServer = listenAndServe(server, httpPort, listenerOptions)
+	}
+	httpServer.RegisterOnShutdown(server.CloseStreams)
+
+	waitForShutdown(server, httpServer, shutdownTracing)
+}
+
+// setupTracing exports the traces to the OTLP collector in OTEL_EXPORTER_OTLP_ENDPOINT, if any,
+// with the OTEL_EXPORTER_OTLP_HEADERS (key=value pairs, comma-separated), OTEL_SERVICE_NAME
+// and OTEL_TRACES_SAMPLER_ARG (the fraction of the webhooks traced) variables.
+func setupTracing() func(context.Context) error {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		return nil
+	}
+
+	options := &strillone.TracingOptions{
+		Endpoint:    endpoint,
+		Headers:     map[string]string{},
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+	}
+	for _, header := range splitList(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")) {
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) != 2 {
+			log.Fatal().Str("header", header).Msg("Invalid OTEL_EXPORTER_OTLP_HEADERS")
+		}
+		options.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	if env := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); env != "" {
+		ratio, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid OTEL_TRACES_SAMPLER_ARG")
+		}
+		options.SampleRatio = ratio
+	}
+
+	shutdown, err := strillone.SetupTracing(options)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error setting up tracing")
+	}
+	log.Info().Str("endpoint", endpoint).Msg("Exporting traces")
+	return shutdown
+}
+
+// listenAndServe serves HTTP on the port, in the background.
+func listenAndServe(handler http.Handler, httpPort string, listenerOptions *strillone.ListenerOptions) *http.Server {
+	listener, err := listenerOptions.Listen(":" + httpPort)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error listening")
+	}
+	server := listenerOptions.HTTPServer(":"+httpPort, handler)
+	log.Info().Str("port", httpPort).Msgf("%s listening", Program)
+	go func() {
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("Error serving")
+		}
+	}()
+	return server
+}
+
+// waitForShutdown waits for SIGINT or SIGTERM, then stops accepting connections and
+// drains the in-flight webhooks, deliveries and traces, within STRILLONE_SHUTDOWN_TIMEOUT (default 30s).
+func waitForShutdown(server *strillone.Server, httpServer *http.Server, shutdownTracing func(context.Context) error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+
+	timeout := 30 * time.Second
+	if env := os.Getenv("STRILLONE_SHUTDOWN_TIMEOUT"); env != "" {
+		d, err := time.ParseDuration(env)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid STRILLONE_SHUTDOWN_TIMEOUT")
+		}
+		timeout = d
+	}
+	log.Info().Str("signal", sig.String()).Dur("timeout", timeout).Msg("Shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Error shutting down the HTTP server")
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Error shutting down")
+		os.Exit(1)
+	}
+	if shutdownTracing != nil {
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("Error flushing the traces")
+		}
+	}
+	log.Info().Msgf("%s stopped", Program)
+}
+
+// listenerOptionsFromEnv returns the default listener options,
+// overridden by the STRILLONE_*_TIMEOUT and STRILLONE_MAX_CONNS_PER_IP variables.
+func listenerOptionsFromEnv() *strillone.ListenerOptions {
+	options := strillone.DefaultListenerOptions()
+	durations := map[string]*time.Duration{
+		"STRILLONE_READ_HEADER_TIMEOUT": &options.ReadHeaderTimeout,
+		"STRILLONE_READ_TIMEOUT":        &options.ReadTimeout,
+		"STRILLONE_WRITE_TIMEOUT":       &options.WriteTimeout,
+		"STRILLONE_IDLE_TIMEOUT":        &options.IdleTimeout,
+	}
+	for name, value := range durations {
+		if env := os.Getenv(name); env != "" {
+			d, err := time.ParseDuration(env)
+			if err != nil {
+				log.Fatal().Err(err).Msgf("Invalid %s", name)
+			}
+			*value = d
+		}
+	}
+	if env := os.Getenv("STRILLONE_MAX_CONNS_PER_IP"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid STRILLONE_MAX_CONNS_PER_IP")
+		}
+		options.MaxConnsPerIP = n
+	}
+	return options
+}
+
+// listenAndServeTLS serves HTTPS on the port, in the background. With automatic certificates,
+// it also serves the ACME challenges on the port in STRILLONE_TLS_HTTP_PORT (default 80).
+func listenAndServeTLS(handler http.Handler, httpsPort string, listenerOptions *strillone.ListenerOptions, options *strillone.TLSOptions) *http.Server {
+	tlsConfig, challengeHandler, err := options.TLSConfig()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error configuring TLS")
+	}
+
+	if challengeHandler != nil {
+		challengePort := os.Getenv("STRILLONE_TLS_HTTP_PORT")
+		if challengePort == "" {
+			challengePort = "80"
+		}
+		go func() {
+			log.Info().Str("port", challengePort).Msgf("%s listening for ACME challenges", Program)
+			if err := http.ListenAndServe(":"+challengePort, challengeHandler); err != nil {
+				log.Fatal().Err(err).Msg("Error serving ACME challenges")
+			}
+		}()
+	}
+
+	listener, err := listenerOptions.Listen(":" + httpsPort)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error listening")
+	}
+	server := listenerOptions.HTTPServer(":"+httpsPort, handler)
+	server.TLSConfig = tlsConfig
+	log.Info().Str("port", httpsPort).Msgf("%s listening with TLS", Program)
+	go func() {
+		if err := server.ServeTLS(listener, "", ""); err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("Error serving")
+		}
+	}()
+	return server
+}
+
+// watchReloadSignal reloads the configuration when the process receives SIGHUP.
+func watchReloadSignal(reloader *strillone.ConfigReloader) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			log.Info().Str("path", reloader.Path).Msg("SIGHUP received, reloading the configuration")
+			if err := reloader.Reload(); err != nil {
+				log.Error().Err(err).Msg("Error reloading configuration")
+			}
+		}
+	}()
+}
 
-	log.Printf("%s listening on %s...\n", Program, httpPort)
-	if err := http.ListenAndServe(":"+httpPort, server); err != nil {
-		log.Fatal(err.Error())
+// splitList splits a comma-separated list, ignoring the empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dnsimple/strillone"
+	"github.com/rs/zerolog/log"
+)
+
+// testEvent runs the `strillone test-event` subcommand, that generates a synthetic event
+// and runs it through the formatting and delivery pipeline, with the configuration in STRILLONE_CONFIG.
+// It returns the exit status: 1 if the event could not be delivered.
+func testEvent(args []string) int {
+	flags := flag.NewFlagSet("test-event", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s test-event [flags]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", os.Getenv("STRILLONE_CONFIG"), "configuration file")
+	name := flags.String("type", "", "event name, such as domain.delete")
+	domain := flags.String("domain", "example.com", "domain of the event")
+	accountID := flags.Int64("account", 1010, "account of the event")
+	tenant := flags.String("tenant", "", "tenant whose routes and destinations are used")
+	destinations := flags.String("to", "", "comma-separated destinations overriding the routes")
+	dryRun := flags.Bool("dry-run", false, "print the message without delivering it")
+	flags.Parse(args)
+	if *name == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	payload, err := strillone.SyntheticEvent(&strillone.SyntheticEventOptions{Name: *name, Domain: *domain, AccountID: *accountID})
+	if err != nil {
+		log.Error().Err(err).Msg("Error generating the event")
+		return 2
+	}
+
+	var config *strillone.Config
+	if *configPath != "" {
+		if config, err = strillone.LoadConfig(*configPath); err != nil {
+			log.Fatal().Err(err).Msg("Error loading configuration")
+		}
+	}
+	server := strillone.NewServer(nil)
+	if _, err := setSecrets(server); err != nil {
+		log.Fatal().Err(err).Msg("Error loading secrets")
+	}
+	if config != nil {
+		if err := server.Reload(config); err != nil {
+			log.Fatal().Err(err).Msg("Error loading configuration")
+		}
+	}
+
+	status := 0
+	result, err := server.Replay(context.Background(), payload, &strillone.ReplayOptions{
+		Tenant:       *tenant,
+		Destinations: splitList(*destinations),
+		DryRun:       *dryRun,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Error sending the event")
+		status = 1
+	} else if printReplayResult(result) {
+		status = 1
+	}
+
+	// The shutdown sends the events held by the rate limits.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Error shutting down")
+		return 1
+	}
+	return status
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dnsimple/strillone"
+)
+
+// validate runs the `strillone validate` subcommand, that loads the configuration in STRILLONE_CONFIG,
+// compiles the templates, and formats the example payload of every event for every destination.
+// It returns the exit status: 1 if the configuration or a message is invalid.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s validate [flags]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", os.Getenv("STRILLONE_CONFIG"), "configuration file")
+	flags.Parse(args)
+	if *configPath == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	config, err := strillone.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		return 1
+	}
+	server := strillone.NewServer(nil)
+	if _, err := setSecrets(server); err != nil {
+		fmt.Fprintf(os.Stderr, "secrets: %v\n", err)
+		return 1
+	}
+	if err := server.Reload(config); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		return 1
+	}
+
+	errs := server.ValidateMessages()
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Printf("%s: valid, %d events formatted for each destination\n", *configPath, len(strillone.EventNames))
+	return 0
+}
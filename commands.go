@@ -0,0 +1,453 @@
+package strillone
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// commandTimeout is the timeout of the API requests of a slash command,
+	// since Slack waits 3 seconds for the response.
+	commandTimeout = 2500 * time.Millisecond
+
+	// commandMaxAge is the maximum age of the slash command requests, to reject the replayed ones.
+	commandMaxAge = 5 * time.Minute
+
+	// commandMaxRecords is the maximum number of records listed by a slash command.
+	commandMaxRecords = 50
+
+	// commandMaxActionValue is the maximum length of the value of a button, set by Slack.
+	commandMaxActionValue = 2000
+
+	// recordConfirmAction and recordCancelAction are the IDs of the buttons of the record changes.
+	recordConfirmAction = "record_confirm"
+	recordCancelAction  = "record_cancel"
+)
+
+// CommandsConfig configures the Slack slash command querying the DNSimple API, e.g. /dnsimple status example.com.
+type CommandsConfig struct {
+	// SigningSecret is the signing secret of the Slack app, to verify the requests.
+	SigningSecret string `json:"signing_secret"`
+
+	// Account is the ID of the account queried. Defaults to the first account accessible with the API token.
+	Account int64 `json:"account,omitempty"`
+
+	// Channels are the IDs of the Slack channels allowed to run the command. Defaults to all the channels.
+	Channels []string `json:"channels,omitempty"`
+
+	// Writers are the IDs of the Slack users allowed to create records, after a confirmation. Defaults to nobody.
+	Writers []string `json:"writers,omitempty"`
+}
+
+func (c *CommandsConfig) validate(api *APIConfig) error {
+	if c == nil {
+		return nil
+	}
+	if api == nil {
+		return fmt.Errorf("commands: the api is required")
+	}
+	if c.SigningSecret == "" {
+		return fmt.Errorf("commands: missing signing secret")
+	}
+	if c.Account < 0 {
+		return fmt.Errorf("commands: invalid account %d", c.Account)
+	}
+	return nil
+}
+
+// allowedChannel returns true if the command can be run in the channel.
+func (c *CommandsConfig) allowedChannel(channel string) bool {
+	if len(c.Channels) == 0 {
+		return true
+	}
+	for _, allowed := range c.Channels {
+		if allowed == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedWriter returns true if the user can change the records.
+func (c *CommandsConfig) allowedWriter(user string) bool {
+	for _, writer := range c.Writers {
+		if writer == user {
+			return true
+		}
+	}
+	return false
+}
+
+// commandRequest is a slash command sent by Slack.
+type commandRequest struct {
+	userID    string
+	channelID string
+	args      []string
+	accountID int64
+}
+
+// commandResponse is the answer to a slash command, visible to the user only.
+type commandResponse struct {
+	ResponseType string       `json:"response_type,omitempty"`
+	Text         string       `json:"text"`
+	Blocks       []slackBlock `json:"blocks,omitempty"`
+
+	// ReplaceOriginal replaces the answer, when sent to the response URL of an interaction.
+	ReplaceOriginal bool `json:"replace_original,omitempty"`
+}
+
+func commandText(text string) *commandResponse {
+	return &commandResponse{ResponseType: "ephemeral", Text: text}
+}
+
+// slashCommand runs a slash command and returns the response.
+type slashCommand func(ctx context.Context, routing *routingTable, request *commandRequest) (*commandResponse, error)
+
+// slashCommands are the slash commands, by name.
+var slashCommands = map[string]slashCommand{
+	"status":  commandStatus,
+	"records": commandRecords,
+	"check":   commandCheck,
+	"record":  commandRecord,
+}
+
+const commandUsage = "Usage:\n" +
+	"• `status example.com`: the registration of the domain\n" +
+	"• `records example.com`: the records of the zone\n" +
+	"• `check example.com`: the availability of the domain\n" +
+	"• `record add example.com A www 192.0.2.1`: creates the record, after a confirmation"
+
+// SlackCommand handles the Slack slash commands: the command verifies the signature of Slack,
+// runs the subcommand with the DNSimple API, and answers the user privately.
+func (s *Server) SlackCommand(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Request")
+
+	routing := s.currentRouting()
+	config := routing.config.Commands
+	if config == nil || routing.api == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.drain.enter() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.drain.leave()
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, routing.config.Inbound.maxBodySize()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackSignature(routing.commandsSecret, r.Header, body, time.Now()); err != nil {
+		log.Warn().Err(err).Msg("Rejecting slash command")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := &commandRequest{userID: form.Get("user_id"), channelID: form.Get("channel_id"), args: strings.Fields(form.Get("text"))}
+	log.Info().Str("user_id", request.userID).Str("channel_id", request.channelID).Str("text", form.Get("text")).Msg("Slash command")
+	if !config.allowedChannel(request.channelID) {
+		writeCommandResponse(w, "This command is not allowed in this channel.")
+		return
+	}
+	if len(request.args) == 0 || slashCommands[request.args[0]] == nil {
+		writeCommandResponse(w, commandUsage)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), commandTimeout)
+	defer cancel()
+	request.accountID = config.Account
+	if request.accountID == 0 {
+		accountIDs, err := routing.scheduledAccounts(ctx, nil)
+		if err != nil || len(accountIDs) == 0 {
+			log.Error().Err(err).Msg("Error fetching the account of the slash command")
+			writeCommandResponse(w, "The DNSimple account is not available, try again later.")
+			return
+		}
+		request.accountID = accountIDs[0]
+	}
+
+	response, err := slashCommands[request.args[0]](ctx, routing, request)
+	if err != nil {
+		log.Error().Err(err).Str("command", request.args[0]).Msg("Error running the slash command")
+		response = commandError(err)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// writeCommandResponse answers the slash command with a message visible to the user only.
+func writeCommandResponse(w http.ResponseWriter, text string) {
+	writeJSON(w, http.StatusOK, commandText(text))
+}
+
+// commandError returns the response of a failed command.
+func commandError(err error) *commandResponse {
+	return commandText(fmt.Sprintf("The command failed: %s", mrkdwnRenderer{}.Escape(err.Error())))
+}
+
+// verifySlackSignature checks the signature of a Slack request: the hex-encoded HMAC-SHA256
+// of "v0:<timestamp>:<body>" with the signing secret, sent less than 5 minutes ago.
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get("X-Slack-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return errSignatureMissing
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > commandMaxAge || age < -commandMaxAge {
+		return errSignatureInvalid
+	}
+	given, err := hex.DecodeString(strings.TrimPrefix(header.Get("X-Slack-Signature"), "v0="))
+	if err != nil || len(given) == 0 {
+		return errSignatureInvalid
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:%s", timestamp, body)
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return errSignatureInvalid
+	}
+	return nil
+}
+
+// commandFormatter returns the formatter of the responses, with the settings of the messages.
+func commandFormatter(routing *routingTable) *SlackService {
+	slack := &SlackService{}
+	if routing.formatting != nil {
+		routing.formatting.configure(slack)
+	}
+	return slack
+}
+
+// commandDomainArg returns the domain name argument of the command.
+func commandDomainArg(request *commandRequest) (string, error) {
+	if len(request.args) != 2 {
+		return "", fmt.Errorf("expected a domain name, e.g. `%s example.com`", request.args[0])
+	}
+	return strings.ToLower(strings.TrimSuffix(request.args[1], ".")), nil
+}
+
+// commandStatus describes the registration of the domain.
+func commandStatus(ctx context.Context, routing *routingTable, request *commandRequest) (*commandResponse, error) {
+	name, err := commandDomainArg(request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := routing.api.Domains.GetDomain(ctx, strconv.FormatInt(request.accountID, 10), name)
+	if err != nil {
+		return nil, err
+	}
+	domain := response.Data
+	s := commandFormatter(routing)
+	details := []string{s.Escape(domain.State)}
+	if t, ok := parseTimestamp(domain.ExpiresAt); ok {
+		details = append(details, "expires on "+t.Format(certificateDateFormat))
+	}
+	if domain.AutoRenew {
+		details = append(details, "auto-renewal enabled")
+	} else {
+		details = append(details, "auto-renewal disabled")
+	}
+	if domain.PrivateWhois {
+		details = append(details, "WHOIS privacy enabled")
+	} else {
+		details = append(details, "WHOIS privacy disabled")
+	}
+	link := s.FormatLink(domain.Name, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s", request.accountID, domain.Name))
+	return commandText(fmt.Sprintf("%s: %s", link, strings.Join(details, ", "))), nil
+}
+
+// commandRecords lists the records of the zone.
+func commandRecords(ctx context.Context, routing *routingTable, request *commandRequest) (*commandResponse, error) {
+	name, err := commandDomainArg(request)
+	if err != nil {
+		return nil, err
+	}
+	records, err := listZoneRecords(ctx, routing.api, request.accountID, name)
+	if err != nil {
+		return nil, err
+	}
+	s := commandFormatter(routing)
+	link := s.FormatLink(name, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s/records", request.accountID, name))
+	if len(records) == 0 {
+		return commandText(fmt.Sprintf("The zone %s has no records.", link)), nil
+	}
+
+	lines := make([]string, 0, len(records))
+	for i, record := range records {
+		if i == commandMaxRecords {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(records)-commandMaxRecords))
+			break
+		}
+		recordName := record.Name
+		if recordName == "" {
+			recordName = "@"
+		}
+		line := fmt.Sprintf("%s %d %s %s", recordName, record.TTL, record.Type, record.Content)
+		if record.Type == "MX" || record.Type == "SRV" {
+			line = fmt.Sprintf("%s %d %s %d %s", recordName, record.TTL, record.Type, record.Priority, record.Content)
+		}
+		lines = append(lines, s.Escape(line))
+	}
+	return commandText(fmt.Sprintf("The records of the zone %s:\n```\n%s\n```", link, strings.Join(lines, "\n"))), nil
+}
+
+// commandCheck checks the availability of the domain.
+func commandCheck(ctx context.Context, routing *routingTable, request *commandRequest) (*commandResponse, error) {
+	name, err := commandDomainArg(request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := routing.api.Registrar.CheckDomain(ctx, strconv.FormatInt(request.accountID, 10), name)
+	if err != nil {
+		return nil, err
+	}
+	check := response.Data
+	name = commandFormatter(routing).Escape(check.Domain)
+	switch {
+	case check.Available && check.Premium:
+		return commandText(fmt.Sprintf("The domain %s is available, at a premium price.", name)), nil
+	case check.Available:
+		return commandText(fmt.Sprintf("The domain %s is available.", name)), nil
+	default:
+		return commandText(fmt.Sprintf("The domain %s is not available.", name)), nil
+	}
+}
+
+// recordChange is a record to create, sent back by the buttons of the confirmation prompt.
+type recordChange struct {
+	Account  int64  `json:"account"`
+	Zone     string `json:"zone"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Content  string `json:"content"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// String returns the record in the zone file syntax, e.g. www A 192.0.2.1.
+func (c *recordChange) String() string {
+	name := c.Name
+	if name == "" {
+		name = "@"
+	}
+	if c.Priority != 0 {
+		return fmt.Sprintf("%s %s %d %s", name, c.Type, c.Priority, c.Content)
+	}
+	return fmt.Sprintf("%s %s %s", name, c.Type, c.Content)
+}
+
+// commandRecord asks the confirmation of the record to create, e.g. record add example.com A www 192.0.2.1.
+// The record is created by the Confirm button, see confirmRecord.
+func commandRecord(_ context.Context, routing *routingTable, request *commandRequest) (*commandResponse, error) {
+	args := request.args
+	if len(args) < 6 || args[1] != "add" {
+		return nil, fmt.Errorf("expected a record, e.g. `record add example.com A www 192.0.2.1`")
+	}
+	if !routing.config.Commands.allowedWriter(request.userID) {
+		return commandText("You are not allowed to change the records."), nil
+	}
+
+	change := &recordChange{
+		Account: request.accountID,
+		Zone:    strings.ToLower(strings.TrimSuffix(args[2], ".")),
+		Type:    strings.ToUpper(args[3]),
+		Name:    args[4],
+		Content: strings.Join(args[5:], " "),
+	}
+	if change.Name == "@" {
+		change.Name = ""
+	}
+	if (change.Type == "MX" || change.Type == "SRV") && len(args) > 6 {
+		if priority, err := strconv.Atoi(args[5]); err == nil {
+			change.Priority = priority
+			change.Content = strings.Join(args[6:], " ")
+		}
+	}
+	value, err := json.Marshal(change)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) > commandMaxActionValue {
+		return nil, fmt.Errorf("the record is too long")
+	}
+
+	s := commandFormatter(routing)
+	link := s.FormatLink(change.Zone, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s/records", change.Account, change.Zone))
+	text := fmt.Sprintf("Create the record `%s` in the zone %s?", s.Escape(change.String()), link)
+	return &commandResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+		Blocks:       slackConfirmationBlocks(text, recordConfirmAction, recordCancelAction, string(value)),
+	}, nil
+}
+
+// confirmRecord handles the Confirm and Cancel buttons of a record change: the record is created
+// if the user is still allowed to, and the prompt is replaced with the result.
+func (s *Server) confirmRecord(ctx context.Context, routing *routingTable, interaction *slackInteraction) {
+	action := interaction.Actions[0]
+	var response *commandResponse
+	switch {
+	case action.ActionID == recordCancelAction:
+		response = commandText("The record was not created.")
+	case !routing.config.Commands.allowedWriter(interaction.User.ID):
+		response = commandText("You are not allowed to change the records.")
+	default:
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+		text, err := createRecord(ctx, routing, action.Value, interaction.User.ID)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", interaction.User.ID).Msg("Error creating the record of the slash command")
+			response = commandError(err)
+		} else {
+			response = commandText(text)
+		}
+	}
+
+	if interaction.ResponseURL == "" {
+		return
+	}
+	response.ReplaceOriginal = true
+	replyCtx, cancel := routing.deliveryContext(ctx)
+	defer cancel()
+	if err := postJSON(replyCtx, defaultHTTPClient, interaction.ResponseURL, response); err != nil {
+		log.Error().Err(err).Msg("Error updating the record prompt")
+	}
+}
+
+// createRecord creates the record confirmed by the user, and returns the text of the result.
+func createRecord(ctx context.Context, routing *routingTable, value, userID string) (string, error) {
+	var change recordChange
+	if err := json.Unmarshal([]byte(value), &change); err != nil {
+		return "", fmt.Errorf("invalid record")
+	}
+	name := change.Name
+	attributes := dnsimple.ZoneRecordAttributes{Type: change.Type, Name: &name, Content: change.Content, Priority: change.Priority}
+	response, err := routing.api.Zones.CreateRecord(ctx, strconv.FormatInt(change.Account, 10), change.Zone, attributes)
+	if err != nil {
+		return "", err
+	}
+	log.Info().Str("user_id", userID).Int64("account_id", change.Account).Str("zone", change.Zone).Str("record", change.String()).Msg("Record created by slash command")
+
+	s := commandFormatter(routing)
+	link := s.FormatLink(change.String(), fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s/records/%d", change.Account, change.Zone, response.Data.ID))
+	return fmt.Sprintf("Created the record %s in the zone %s.", link, s.Escape(change.Zone)), nil
+}
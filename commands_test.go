@@ -0,0 +1,200 @@
+package strillone
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSlackCommandRequest returns a slash command request signed with the secret.
+func newSlackCommandRequest(secret string, form url.Values) *http.Request {
+	body := form.Encode()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+
+	request, _ := http.NewRequest("POST", "/commands/slack", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	request.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return request
+}
+
+func newCommandsTestServer(t *testing.T, commands string) (*Server, func()) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/1010/domains/example.com":
+			fmt.Fprint(w, `{"data": {"id": 1, "name": "example.com", "state": "registered", "auto_renew": true, "private_whois": false, "expires_at": "2022-06-16T12:00:00Z"}}`)
+		case "/v2/1010/zones/example.com/records":
+			if r.Method == "POST" {
+				var record map[string]interface{}
+				_ = json.NewDecoder(r.Body).Decode(&record)
+				record["id"] = 3
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": record})
+				return
+			}
+			fmt.Fprint(w, `{"data": [
+				{"id": 1, "name": "", "type": "A", "content": "192.0.2.1", "ttl": 3600},
+				{"id": 2, "name": "", "type": "MX", "content": "mx.example.com", "ttl": 3600, "priority": 10}
+			], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 2, "total_pages": 1}}`)
+		case "/v2/1010/registrar/domains/example.org/check":
+			fmt.Fprint(w, `{"data": {"domain": "example.org", "available": true, "premium": false}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"api": {"token": "api-token", "url": %q},
+		"commands": %s
+	}`, api.URL, commands)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	return NewServer(config), api.Close
+}
+
+func TestServer_SlackCommand(t *testing.T) {
+	server, stop := newCommandsTestServer(t, `{"signing_secret": "slack-secret", "account": 1010, "channels": ["C0OPS"]}`)
+	defer stop()
+
+	tests := []struct {
+		channel string
+		text    string
+		want    string
+	}{
+		{"C0OPS", "status example.com", "<https://dnsimple.com/a/1010/domains/example.com|example.com>: registered, expires on 2022-06-16, auto-renewal enabled, WHOIS privacy disabled"},
+		{"C0OPS", "records example.com", "The records of the zone <https://dnsimple.com/a/1010/domains/example.com/records|example.com>:\n```\n@ 3600 A 192.0.2.1\n@ 3600 MX 10 mx.example.com\n```"},
+		{"C0OPS", "check example.org", "The domain example.org is available."},
+		{"C0OPS", "status", "The command failed: expected a domain name, e.g. `status example.com`"},
+		{"C0OPS", "", commandUsage},
+		{"C0DEV", "status example.com", "This command is not allowed in this channel."},
+	}
+	for _, test := range tests {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newSlackCommandRequest("slack-secret", url.Values{"channel_id": {test.channel}, "user_id": {"U0JANE"}, "text": {test.text}}))
+		if want, got := http.StatusOK, response.Code; want != got {
+			t.Fatalf("POST /commands/slack %q expected HTTP %v, got %v", test.text, want, got)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := "ephemeral", body["response_type"]; want != got {
+			t.Errorf("POST /commands/slack %q expected response type %q, got %q", test.text, want, got)
+		}
+		if got := body["text"]; test.want != got {
+			t.Errorf("POST /commands/slack %q expected\n%v\ngot\n%v", test.text, test.want, got)
+		}
+	}
+}
+
+func TestServer_SlackCommandSignature(t *testing.T) {
+	server, stop := newCommandsTestServer(t, `{"signing_secret": "slack-secret", "account": 1010}`)
+	defer stop()
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, newSlackCommandRequest("other-secret", url.Values{"text": {"status example.com"}}))
+	if want, got := http.StatusUnauthorized, response.Code; want != got {
+		t.Errorf("POST /commands/slack with an invalid signature expected HTTP %v, got %v", want, got)
+	}
+
+	request := newSlackCommandRequest("slack-secret", url.Values{"text": {"status example.com"}})
+	request.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusUnauthorized, response.Code; want != got {
+		t.Errorf("POST /commands/slack with an old timestamp expected HTTP %v, got %v", want, got)
+	}
+}
+
+func TestServer_SlackCommandRecord(t *testing.T) {
+	server, stop := newCommandsTestServer(t, `{"signing_secret": "slack-secret", "account": 1010, "writers": ["U0JANE"]}`)
+	defer stop()
+
+	var replies []commandResponse
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reply commandResponse
+		_ = json.NewDecoder(r.Body).Decode(&reply)
+		replies = append(replies, reply)
+	}))
+	defer target.Close()
+
+	command := func(user, text string) commandResponse {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newSlackCommandRequest("slack-secret", url.Values{"user_id": {user}, "text": {text}}))
+		var body commandResponse
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	click := func(secret, user, actionID, value string) int {
+		interaction := fmt.Sprintf(`{"type": "block_actions", "user": {"id": %q}, "actions": [{"action_id": %q, "value": %q}], "response_url": "%s/response"}`, user, actionID, value, target.URL)
+		request := newSlackCommandRequest(secret, url.Values{"payload": {interaction}})
+		request.URL.Path = "/interactions/slack"
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	if want, got := "You are not allowed to change the records.", command("U0BOB", "record add example.com A www 192.0.2.2").Text; want != got {
+		t.Errorf("record add by a reader expected %q, got %q", want, got)
+	}
+	if want, got := "The command failed: expected a record, e.g. `record add example.com A www 192.0.2.1`", command("U0JANE", "record add example.com A www").Text; want != got {
+		t.Errorf("record add without content expected %q, got %q", want, got)
+	}
+	if want, got := "Create the record `@ MX 10 mx.example.com` in the zone <https://dnsimple.com/a/1010/domains/example.com/records|example.com>?", command("U0JANE", "record add example.com mx @ 10 mx.example.com").Text; want != got {
+		t.Errorf("record add MX expected %q, got %q", want, got)
+	}
+
+	prompt := command("U0JANE", "record add example.com A www 192.0.2.2")
+	if want, got := "Create the record `www A 192.0.2.2` in the zone <https://dnsimple.com/a/1010/domains/example.com/records|example.com>?", prompt.Text; want != got {
+		t.Fatalf("record add expected %q, got %q", want, got)
+	}
+	if len(prompt.Blocks) != 2 || len(prompt.Blocks[1].Elements) != 2 {
+		t.Fatalf("record add expected the Confirm and Cancel buttons, got %+v", prompt.Blocks)
+	}
+	buttons, _ := json.Marshal(prompt.Blocks[1].Elements)
+	var actions []slackActionButton
+	if err := json.Unmarshal(buttons, &actions); err != nil {
+		t.Fatal(err)
+	}
+	value := actions[0].Value
+
+	if want, got := http.StatusUnauthorized, click("other-secret", "U0JANE", recordConfirmAction, value); want != got {
+		t.Errorf("POST /interactions/slack with an invalid signature expected HTTP %v, got %v", want, got)
+	}
+	tests := []struct {
+		user     string
+		actionID string
+		want     string
+	}{
+		{"U0JANE", recordCancelAction, "The record was not created."},
+		{"U0BOB", recordConfirmAction, "You are not allowed to change the records."},
+		{"U0JANE", recordConfirmAction, "Created the record <https://dnsimple.com/a/1010/domains/example.com/records/3|www A 192.0.2.2> in the zone example.com."},
+	}
+	for _, test := range tests {
+		replies = nil
+		if want, got := http.StatusOK, click("slack-secret", test.user, test.actionID, value); want != got {
+			t.Fatalf("POST /interactions/slack %s expected HTTP %v, got %v", test.actionID, want, got)
+		}
+		if len(replies) != 1 {
+			t.Fatalf("POST /interactions/slack %s expected 1 reply, got %d", test.actionID, len(replies))
+		}
+		if got := replies[0]; test.want != got.Text || !got.ReplaceOriginal {
+			t.Errorf("POST /interactions/slack %s by %s expected to replace the prompt with %q, got %+v", test.actionID, test.user, test.want, got)
+		}
+	}
+}
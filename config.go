@@ -0,0 +1,659 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+)
+
+// Config represents the Strillone configuration file.
+//
+// The configuration is optional: without it, Strillone only serves the
+// /slack/:slackAlpha/:slackBeta/:slackGamma endpoints where the destination
+// is encoded in the URL.
+type Config struct {
+	// Destinations are the named targets where events can be published.
+	Destinations []DestinationConfig `json:"destinations"`
+
+	// Routes map the incoming events to one or more destinations.
+	Routes []RouteConfig `json:"routes"`
+
+	// Ownership maps the domains to the destinations of the teams owning them, in addition to the routes.
+	Ownership []OwnershipRule `json:"ownership,omitempty"`
+
+	// Admin configures the administrative API.
+	Admin AdminConfig `json:"admin"`
+
+	// Inbound configures how the webhooks are received.
+	Inbound InboundConfig `json:"inbound"`
+
+	// Tenants are the teams sharing this instance, each one with its own
+	// inbound URL, destinations and routes.
+	Tenants []TenantConfig `json:"tenants,omitempty"`
+
+	// HealthDestination is the destination notified when another destination starts
+	// or stops failing, optional.
+	HealthDestination string `json:"health_destination,omitempty"`
+
+	// OperatorDestination is the destination notified of the payloads that can't be formatted,
+	// optional.
+	OperatorDestination string `json:"operator_destination,omitempty"`
+
+	// DedupWindow, when set, is the window in which the events with the same content
+	// (name, actor, account and data) are notified only once.
+	DedupWindow Duration `json:"dedup_window,omitempty"`
+
+	// Severities override the severities of the events, by event name pattern, optional.
+	Severities SeverityConfig `json:"severities,omitempty"`
+
+	// Templates is the directory of the message templates overriding the default messages,
+	// one file per event name (e.g. domain.create.tmpl), optional.
+	Templates string `json:"templates,omitempty"`
+
+	// DashboardURL is the base URL of the DNSimple dashboard linked in the messages,
+	// such as https://sandbox.dnsimple.com for the sandbox accounts. Defaults to https://dnsimple.com.
+	DashboardURL string `json:"dashboard_url,omitempty"`
+
+	// Presentation overrides the emoji, color and icon of the messages, by event family
+	// (e.g. domain, zone_record or certificate), optional.
+	Presentation map[string]PresentationConfig `json:"presentation,omitempty"`
+
+	// SeverityPresentation sets the presentation of the messages by severity, in every destination, optional.
+	// The presentation of the event families takes precedence.
+	SeverityPresentation map[Severity]PresentationConfig `json:"severity_presentation,omitempty"`
+
+	// Translations is the directory of the translation catalogs overriding the built-in ones,
+	// one JSON file per language (e.g. fr.json), optional.
+	Translations string `json:"translations,omitempty"`
+
+	// Accounts label the accounts in the messages, by account ID (e.g. {"1010": "Production"}), optional.
+	// With the API, the other accounts are labeled with their name.
+	Accounts map[string]string `json:"accounts,omitempty"`
+
+	// Reminders configures the reminders of the domains expiring without auto-renewal, optional.
+	Reminders *RemindersConfig `json:"reminders,omitempty"`
+
+	// CertificateMonitor configures the alerts of the certificates expiring soon, optional.
+	CertificateMonitor *CertificateMonitorConfig `json:"certificate_monitor,omitempty"`
+
+	// Drift configures the comparison of the zones with the live DNS, optional.
+	Drift *DriftConfig `json:"drift,omitempty"`
+
+	// RegistrarWatchdog configures the audits of the transfer lock and the WHOIS privacy of the domains, optional.
+	RegistrarWatchdog *RegistrarWatchdogConfig `json:"registrar_watchdog,omitempty"`
+
+	// ActivityReport configures the weekly reports of the activity of the accounts, from the history, optional.
+	ActivityReport *ActivityReportConfig `json:"activity_report,omitempty"`
+
+	// Commands configures the Slack slash command querying the DNSimple API, optional.
+	Commands *CommandsConfig `json:"commands,omitempty"`
+
+	// Approvals configures the events held for an approval in Slack, optional.
+	Approvals *ApprovalsConfig `json:"approvals,omitempty"`
+
+	// Silences suppress the notifications of the matching events during planned changes,
+	// managed with the /api/silences endpoints.
+	Silences []Silence `json:"silences,omitempty"`
+
+	// Sampling delivers 1 in N of the matching events, such as the changes of an automation token,
+	// with periodic summaries of the suppressed ones, optional.
+	Sampling []SamplingConfig `json:"sampling,omitempty"`
+
+	// ChangeWindows flag the changes made outside the approved windows, such as the changes
+	// of the production name servers on a weekend, optional.
+	ChangeWindows []ChangeWindowPolicy `json:"change_windows,omitempty"`
+
+	// Anomalies alert when the volume of the events of an account or of a zone spikes, optional.
+	Anomalies *AnomalyConfig `json:"anomalies,omitempty"`
+
+	// Security routes the security-sensitive events to a security destination, regardless of the routes, optional.
+	Security *SecurityConfig `json:"security,omitempty"`
+
+	// Feed exposes the recent events as an authenticated Atom feed, from the history, optional.
+	Feed *FeedConfig `json:"feed,omitempty"`
+
+	// Escalations configures the events acknowledged in Slack, and escalated when they aren't, optional.
+	Escalations *EscalationsConfig `json:"escalations,omitempty"`
+
+	// Redaction configures the personal data masked in the messages.
+	// Defaults to the emails, phone numbers and postal addresses of the contact.* events.
+	Redaction *RedactionConfig `json:"redaction,omitempty"`
+
+	// API configures the client of the DNSimple API, used to complete the events
+	// with the details missing from the webhooks, optional.
+	API *APIConfig `json:"api,omitempty"`
+
+	// Timeouts configures the timeouts of the enrichment and the delivery of the events, optional.
+	Timeouts *TimeoutsConfig `json:"timeouts,omitempty"`
+
+	// Egress configures the proxy and the allowed hosts of the connections to the destinations, optional.
+	Egress *EgressConfig `json:"egress,omitempty"`
+
+	// Environments are the other DNSimple environments sending their events, such as the sandbox,
+	// each one received on /e/<name>/events, optional.
+	Environments []EnvironmentConfig `json:"environments,omitempty"`
+}
+
+// TenantConfig represents a tenant. Events for the tenant are received on /t/<token>/events.
+type TenantConfig struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+
+	// SigningSecret overrides the inbound signing secret for the tenant.
+	SigningSecret string `json:"signing_secret,omitempty"`
+
+	// RateLimit limits the webhooks accepted for the tenant.
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty"`
+
+	Destinations  []DestinationConfig  `json:"destinations"`
+	Routes        []RouteConfig        `json:"routes"`
+	Ownership     []OwnershipRule      `json:"ownership,omitempty"`
+	Sampling      []SamplingConfig     `json:"sampling,omitempty"`
+	ChangeWindows []ChangeWindowPolicy `json:"change_windows,omitempty"`
+	Anomalies     *AnomalyConfig       `json:"anomalies,omitempty"`
+	Security      *SecurityConfig      `json:"security,omitempty"`
+	Feed          *FeedConfig          `json:"feed,omitempty"`
+
+	// HealthDestination is the tenant destination notified when another one starts or stops failing.
+	HealthDestination string `json:"health_destination,omitempty"`
+
+	// OperatorDestination is the tenant destination notified of the payloads that can't be formatted.
+	OperatorDestination string `json:"operator_destination,omitempty"`
+
+	// DashboardURL overrides the base URL of the DNSimple dashboard for the tenant.
+	DashboardURL string `json:"dashboard_url,omitempty"`
+}
+
+// routingConfig returns the configuration of the destinations and routes of the tenant.
+func (t *TenantConfig) routingConfig() *Config {
+	return &Config{Destinations: t.Destinations, Routes: t.Routes, Ownership: t.Ownership, Sampling: t.Sampling, ChangeWindows: t.ChangeWindows, Anomalies: t.Anomalies, Security: t.Security, Feed: t.Feed, HealthDestination: t.HealthDestination, OperatorDestination: t.OperatorDestination}
+}
+
+// AdminConfig represents the configuration of the administrative API.
+type AdminConfig struct {
+	// Token is the bearer token required to access the /admin endpoints, with the admin role.
+	// The administrative API is disabled when the token is empty and there are no keys.
+	Token string `json:"token"`
+
+	// Keys are the API keys of the admin API and the dashboard, each with a role.
+	Keys []APIKeyConfig `json:"keys,omitempty"`
+
+	// OIDC enables the login to the dashboard with OpenID Connect.
+	OIDC *OIDCConfig `json:"oidc,omitempty"`
+}
+
+// APIKeyConfig represents an API key of the admin API and the dashboard.
+type APIKeyConfig struct {
+	// Name identifies the key in the audit log.
+	Name  string `json:"name"`
+	Token string `json:"token"`
+
+	// Role is viewer, operator or admin.
+	Role string `json:"role"`
+}
+
+// InboundConfig represents the configuration of the webhook receiver.
+type InboundConfig struct {
+	// SigningSecret is the secret shared with DNSimple to sign the webhook payloads.
+	// When set, webhooks without a valid signature are rejected.
+	SigningSecret string `json:"signing_secret,omitempty"`
+
+	// SignatureHeader is the request header with the payload signature.
+	// Defaults to X-DNSimple-Signature.
+	SignatureHeader string `json:"signature_header,omitempty"`
+
+	// AllowedIPs is the list of networks (CIDR) allowed to send webhooks.
+	// When empty, webhooks are accepted from any address.
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
+
+	// TrustedProxies is the list of networks (CIDR) of the proxies in front of Strillone,
+	// whose X-Forwarded-For header is trusted to determine the client address.
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+
+	// Username and Password, when set, require HTTP basic authentication on the webhook endpoints.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// BearerToken, when set, requires the token in the Authorization header of the webhooks.
+	// When both basic authentication and a bearer token are configured, either one is accepted.
+	BearerToken string `json:"bearer_token,omitempty"`
+
+	// MaxBodySize is the maximum size of a webhook payload, in bytes. Defaults to 1 MiB.
+	MaxBodySize int64 `json:"max_body_size,omitempty"`
+
+	// RateLimit limits the webhooks accepted from the same source IP.
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty"`
+
+	// ReplayWindow, when set, enables the replay protection: webhooks must carry a timestamp
+	// within the window, and webhooks with a request identifier already seen in the window are rejected.
+	ReplayWindow Duration `json:"replay_window,omitempty"`
+
+	// TimestampHeader is the request header with the webhook timestamp, in seconds since the epoch.
+	// Defaults to X-DNSimple-Timestamp.
+	TimestampHeader string `json:"timestamp_header,omitempty"`
+}
+
+func (c *InboundConfig) maxBodySize() int64 {
+	if c.MaxBodySize <= 0 {
+		return defaultMaxBodySize
+	}
+	return c.MaxBodySize
+}
+
+func (c *InboundConfig) timestampHeader() string {
+	if c.TimestampHeader == "" {
+		return defaultTimestampHeader
+	}
+	return c.TimestampHeader
+}
+
+func (c *InboundConfig) signatureHeader() string {
+	if c.SignatureHeader == "" {
+		return defaultSignatureHeader
+	}
+	return c.SignatureHeader
+}
+
+// DestinationConfig represents a named target where events are published.
+type DestinationConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// URL is the endpoint of the destination (e.g. the Slack incoming webhook URL).
+	URL string `json:"url"`
+
+	// TLS configures the connection to the destination, optional.
+	TLS *DestinationTLSConfig `json:"tls,omitempty"`
+
+	// HTTP configures the timeouts and the proxy of the connection to the destination, optional.
+	HTTP *DestinationHTTPConfig `json:"http,omitempty"`
+
+	// Retry configures the retries of the failed deliveries, optional.
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// CircuitBreaker configures when the deliveries to a failing destination are paused, optional.
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+
+	// RateLimit limits the messages sent to the destination, optional.
+	// The events over the limit are coalesced in a single summary message.
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty"`
+
+	// QuietWindows are the quiet hours and maintenance windows of the destination, optional.
+	QuietWindows []QuietWindowConfig `json:"quiet_windows,omitempty"`
+
+	// Aggregation collapses the records created in a zone in a burst, such as a zone import,
+	// in a single message, optional.
+	Aggregation *AggregationConfig `json:"aggregation,omitempty"`
+
+	// Actors maps the emails of the actors to the Slack user IDs, to @-mention them in the messages, optional.
+	Actors map[string]string `json:"actors,omitempty"`
+
+	// BotToken, when set, posts the Slack messages to the Channel with the Slack Web API
+	// instead of the incoming webhook URL, and threads the events about the same domain
+	// or certificate under the first message.
+	BotToken string `json:"bot_token,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+
+	// ThreadWindow is how long a thread is continued after its last message. Defaults to 24h.
+	ThreadWindow Duration `json:"thread_window,omitempty"`
+
+	// Language is the language of the messages, such as fr or es. Defaults to English.
+	Language string `json:"language,omitempty"`
+
+	// Mentions attach @here, @channel or user group mentions to the messages about the matching events.
+	// There are no mentions by default.
+	Mentions []MentionConfig `json:"mentions,omitempty"`
+
+	// Correlations pair the events starting an operation with the events completing it, so that
+	// the completion updates the message of the start, with a bot token. Defaults to the DNSSEC
+	// key rotations and the domain pushes; an empty list disables the correlations.
+	Correlations []CorrelationConfig `json:"correlations"`
+
+	// Settings are the settings of the destinations of the types registered with RegisterDestination.
+	// The values can be secret references.
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// RouteConfig represents a routing rule.
+type RouteConfig struct {
+	// Name identifies the route in the administrative API. It is optional.
+	Name string `json:"name,omitempty"`
+
+	// Events is the list of event name patterns matched by the route,
+	// using the path.Match syntax (e.g. "domain.*"). An empty list matches every event.
+	Events []string `json:"events"`
+
+	// Destinations is the list of destination names that receive the matching events.
+	Destinations []string `json:"destinations"`
+
+	// Digest, when set, accumulates the matching events and sends them to the destinations
+	// as a single summary at every period (e.g. "1h" or "24h"), instead of immediately.
+	// A destination also receiving the event from a route without digest receives it immediately.
+	Digest Duration `json:"digest,omitempty"`
+
+	// MinSeverity, when set, restricts the route to the events at least as severe (e.g. "critical").
+	MinSeverity Severity `json:"min_severity,omitempty"`
+
+	// Tags, when set, restrict the route to the events about the domains with one of the tags
+	// (e.g. "production"), returned by the DomainTagger of the server.
+	Tags []string `json:"tags,omitempty"`
+
+	// Actors, when set, restrict the route to the events performed by the actors matching one of the patterns,
+	// and IgnoreActors excludes the events performed by them, such as the API tokens of the automations
+	// ("terraform" or "external-dns"). The patterns use the path.Match syntax, and match the user or the token name.
+	Actors       []string `json:"actors,omitempty"`
+	IgnoreActors []string `json:"ignore_actors,omitempty"`
+
+	// Skip lists the stages of the webhook pipeline skipped for the route: "deduplicate" delivers
+	// the events with the same content as a recent one, and "enrich" delivers the events without
+	// the details fetched from the DNSimple API.
+	Skip []string `json:"skip,omitempty"`
+
+	// Annotations are static context added to the messages delivered by the route, such as environment=prod,
+	// team=platform or the link of a runbook, rendered at the bottom of the Slack messages.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// LoadConfig reads and validates the configuration file at the given path.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// LoadConfigFrom loads the configuration from a file, or from a secret reference such as
+// aws-ssm:/strillone/config for the deployments without a file system, such as AWS Lambda.
+func LoadConfigFrom(secrets *Secrets, location string) (*Config, error) {
+	if !secrets.IsReference(location) {
+		return LoadConfig(location)
+	}
+	data, err := secrets.Resolve(location)
+	if err != nil {
+		return nil, fmt.Errorf("configuration %s: %v", location, err)
+	}
+	return ParseConfig([]byte(data))
+}
+
+// ParseConfig parses and validates a JSON configuration.
+func ParseConfig(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks the configuration for consistency.
+func (c *Config) Validate() error {
+	if err := c.validateRouting(); err != nil {
+		return err
+	}
+	if _, err := newIPAllowlist(c.Inbound.AllowedIPs, c.Inbound.TrustedProxies); err != nil {
+		return fmt.Errorf("inbound: %v", err)
+	}
+	if (c.Inbound.Username == "") != (c.Inbound.Password == "") {
+		return fmt.Errorf("inbound: username and password must be set together")
+	}
+	if err := c.Inbound.RateLimit.validate(); err != nil {
+		return fmt.Errorf("inbound: %v", err)
+	}
+	if c.Inbound.ReplayWindow < 0 {
+		return fmt.Errorf("inbound: replay window must be positive")
+	}
+	if c.DedupWindow < 0 {
+		return fmt.Errorf("dedup window must be positive")
+	}
+	if err := c.Severities.validate(); err != nil {
+		return fmt.Errorf("severities: %v", err)
+	}
+	if err := c.Redaction.validate(); err != nil {
+		return err
+	}
+	if err := c.Admin.validate(); err != nil {
+		return err
+	}
+	if err := validateDashboardURL(c.DashboardURL); err != nil {
+		return err
+	}
+	if err := validatePresentation(c.Presentation); err != nil {
+		return err
+	}
+	if err := validateSeverityPresentation(c.SeverityPresentation); err != nil {
+		return err
+	}
+	if err := c.API.validate(); err != nil {
+		return err
+	}
+	if err := c.Commands.validate(c.API); err != nil {
+		return err
+	}
+	if err := c.Timeouts.validate(); err != nil {
+		return err
+	}
+	if err := c.Egress.validate(); err != nil {
+		return err
+	}
+	environmentNames := make(map[string]bool, len(c.Environments))
+	for i := range c.Environments {
+		if err := c.Environments[i].validate(); err != nil {
+			return err
+		}
+		if environmentNames[c.Environments[i].Name] {
+			return fmt.Errorf("environment %q: duplicate name", c.Environments[i].Name)
+		}
+		environmentNames[c.Environments[i].Name] = true
+	}
+	if _, err := parseAccountLabels(c.Accounts); err != nil {
+		return err
+	}
+
+	tenantNames := make(map[string]bool, len(c.Tenants))
+	tenantTokens := make(map[string]bool, len(c.Tenants))
+	for i, t := range c.Tenants {
+		if t.Name == "" {
+			return fmt.Errorf("tenant #%d: missing name", i)
+		}
+		if tenantNames[t.Name] {
+			return fmt.Errorf("tenant %q: duplicate name", t.Name)
+		}
+		if t.Name == defaultTenant {
+			return fmt.Errorf("tenant %q: reserved name, used for the top-level configuration", t.Name)
+		}
+		if len(t.Token) < 16 {
+			return fmt.Errorf("tenant %q: token must be at least 16 characters", t.Name)
+		}
+		if tenantTokens[t.Token] {
+			return fmt.Errorf("tenant %q: duplicate token", t.Name)
+		}
+		if err := t.RateLimit.validate(); err != nil {
+			return fmt.Errorf("tenant %q: %v", t.Name, err)
+		}
+		if err := validateDashboardURL(t.DashboardURL); err != nil {
+			return fmt.Errorf("tenant %q: %v", t.Name, err)
+		}
+		if err := t.routingConfig().validateRouting(); err != nil {
+			return fmt.Errorf("tenant %q: %v", t.Name, err)
+		}
+		tenantNames[t.Name] = true
+		tenantTokens[t.Token] = true
+	}
+
+	return nil
+}
+
+// validateRouting checks the destinations and routes for consistency.
+func (c *Config) validateRouting() error {
+	names := make(map[string]bool, len(c.Destinations))
+	for i, d := range c.Destinations {
+		if d.Name == "" {
+			return fmt.Errorf("destination #%d: missing name", i)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("destination %q: duplicate name", d.Name)
+		}
+		if _, err := newDestinationService(d, &formatting{severities: c.Severities}); err != nil {
+			return fmt.Errorf("destination %q: %v", d.Name, err)
+		}
+		names[d.Name] = true
+	}
+
+	routeNames := make(map[string]bool, len(c.Routes))
+	for i, r := range c.Routes {
+		if r.Name != "" {
+			if routeNames[r.Name] {
+				return fmt.Errorf("route %q: duplicate name", r.Name)
+			}
+			routeNames[r.Name] = true
+		}
+		if r.Digest < 0 {
+			return fmt.Errorf("route #%d: digest must be positive", i)
+		}
+		if _, ok := severityRanks[r.MinSeverity]; r.MinSeverity != "" && !ok {
+			return fmt.Errorf("route #%d: invalid min severity %q", i, r.MinSeverity)
+		}
+		for _, tag := range r.Tags {
+			if tag == "" {
+				return fmt.Errorf("route #%d: empty tag", i)
+			}
+		}
+		for _, stage := range r.Skip {
+			if !skippableStages[stage] {
+				return fmt.Errorf("route #%d: stage %q can't be skipped", i, stage)
+			}
+		}
+		if len(r.Annotations) > maxAnnotations {
+			return fmt.Errorf("route #%d: at most %d annotations", i, maxAnnotations)
+		}
+		for name := range r.Annotations {
+			if name == "" {
+				return fmt.Errorf("route #%d: empty annotation name", i)
+			}
+		}
+		for _, pattern := range r.Events {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("route #%d: invalid event pattern %q", i, pattern)
+			}
+		}
+		for _, pattern := range append(append([]string{}, r.Actors...), r.IgnoreActors...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("route #%d: invalid actor pattern %q", i, pattern)
+			}
+		}
+		for _, name := range r.Destinations {
+			if !names[name] {
+				return fmt.Errorf("route #%d: unknown destination %q", i, name)
+			}
+		}
+	}
+
+	if err := validateOwnership(c.Ownership, names); err != nil {
+		return err
+	}
+
+	if c.HealthDestination != "" && !names[c.HealthDestination] {
+		return fmt.Errorf("health destination: unknown destination %q", c.HealthDestination)
+	}
+	if c.OperatorDestination != "" && !names[c.OperatorDestination] {
+		return fmt.Errorf("operator destination: unknown destination %q", c.OperatorDestination)
+	}
+	if err := c.Reminders.validate(names, c.API); err != nil {
+		return err
+	}
+	if err := c.CertificateMonitor.validate(names, c.API); err != nil {
+		return err
+	}
+	if err := c.Drift.validate(names, c.API); err != nil {
+		return err
+	}
+	if err := c.RegistrarWatchdog.validate(names, c.API); err != nil {
+		return err
+	}
+	if err := c.ActivityReport.validate(names); err != nil {
+		return err
+	}
+	for i := range c.Silences {
+		if err := c.Silences[i].validate(); err != nil {
+			return err
+		}
+	}
+	for i := range c.Sampling {
+		if err := c.Sampling[i].validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateChangeWindows(c.ChangeWindows, names); err != nil {
+		return err
+	}
+	if err := c.Anomalies.validate(names); err != nil {
+		return err
+	}
+	if err := c.Security.validate(names); err != nil {
+		return err
+	}
+	if err := c.Feed.validate(); err != nil {
+		return err
+	}
+	if err := c.Escalations.validate(c.Destinations, c.Approvals); err != nil {
+		return err
+	}
+	if err := c.Approvals.validate(c.Destinations); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Clone returns a deep copy of the configuration.
+func (c *Config) Clone() *Config {
+	data, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	clone := &Config{}
+	if err := json.Unmarshal(data, clone); err != nil {
+		panic(err)
+	}
+	return clone
+}
+
+// Matches returns true if the route matches the event name.
+func (r *RouteConfig) Matches(eventName string) bool {
+	if len(r.Events) == 0 {
+		return true
+	}
+	for _, pattern := range r.Events {
+		if ok, _ := path.Match(pattern, eventName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Duration is a time.Duration encoded in JSON as a string, e.g. "5m".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string, e.g. \"5m\"")
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
@@ -0,0 +1,97 @@
+package strillone
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/X/Y/Z"}
+		],
+		"routes": [
+			{"events": ["domain.*", "zone_record.*"], "destinations": ["ops"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+
+	if want, got := 1, len(config.Destinations); want != got {
+		t.Fatalf("Destinations expected %v, got %v", want, got)
+	}
+	if want, got := "https://hooks.slack.com/services/X/Y/Z", config.Destinations[0].URL; want != got {
+		t.Errorf("Destination URL expected %v, got %v", want, got)
+	}
+}
+
+func TestParseConfig_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"syntax":                `{"destinations": [}`,
+		"missing name":          `{"destinations": [{"type": "slack", "url": "https://example.com"}]}`,
+		"duplicate name":        `{"destinations": [{"name": "a", "type": "slack", "url": "https://example.com"}, {"name": "a", "type": "slack", "url": "https://example.com"}]}`,
+		"unsupported type":      `{"destinations": [{"name": "a", "type": "carrier-pigeon"}]}`,
+		"unknown destination":   `{"routes": [{"destinations": ["a"]}]}`,
+		"invalid event pattern": `{"routes": [{"events": ["domain.["]}]}`,
+	}
+
+	for name, data := range tests {
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("ParseConfig(%v) expected error", name)
+		}
+	}
+}
+
+func TestLoadConfigFrom(t *testing.T) {
+	data := `{"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/X/Y/Z"}]}`
+	secrets := NewSecrets(map[string]SecretBackend{"test": staticSecretBackend{"strillone/config#": data}})
+
+	config, err := LoadConfigFrom(secrets, "test:strillone/config")
+	if err != nil {
+		t.Fatalf("LoadConfigFrom returned error: %v", err)
+	}
+	if want, got := "ops", config.Destinations[0].Name; want != got {
+		t.Errorf("LoadConfigFrom expected destination %v, got %v", want, got)
+	}
+
+	if _, err := LoadConfigFrom(secrets, "test:strillone/missing"); err == nil {
+		t.Errorf("LoadConfigFrom expected error for a missing secret")
+	}
+
+	file, err := ioutil.TempFile("", "strillone-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(data)
+	file.Close()
+	if config, err = LoadConfigFrom(secrets, file.Name()); err != nil {
+		t.Fatalf("LoadConfigFrom returned error: %v", err)
+	}
+	if want, got := "ops", config.Destinations[0].Name; want != got {
+		t.Errorf("LoadConfigFrom expected destination %v, got %v", want, got)
+	}
+}
+
+func TestRouteConfig_Matches(t *testing.T) {
+	route := RouteConfig{Events: []string{"domain.*", "zone_record.create"}}
+
+	tests := map[string]bool{
+		"domain.create":      true,
+		"domain.delete":      true,
+		"zone_record.create": true,
+		"zone_record.delete": false,
+		"contact.create":     false,
+	}
+	for name, want := range tests {
+		if got := route.Matches(name); want != got {
+			t.Errorf("Matches(%v) expected %v, got %v", name, want, got)
+		}
+	}
+
+	if !(&RouteConfig{}).Matches("anything") {
+		t.Errorf("Matches expected empty route to match every event")
+	}
+}
@@ -0,0 +1,155 @@
+package strillone
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+const (
+	// correlationWindow is how long an operation waits for the event completing it, such as a domain transfer.
+	correlationWindow = 14 * 24 * time.Hour
+
+	// maxCorrelations is the number of operations in progress remembered by a destination.
+	maxCorrelations = 10000
+)
+
+// CorrelationConfig pairs the event starting an operation with the events completing it,
+// such as dnssec.rotation_start and dnssec.rotation_complete.
+type CorrelationConfig struct {
+	Start    string   `json:"start"`
+	Complete []string `json:"complete"`
+}
+
+func (c *CorrelationConfig) validate() error {
+	if c.Start == "" {
+		return fmt.Errorf("correlation: missing start event")
+	}
+	if len(c.Complete) == 0 {
+		return fmt.Errorf("correlation %q: missing complete events", c.Start)
+	}
+	for _, name := range c.Complete {
+		if name == "" || name == c.Start {
+			return fmt.Errorf("correlation %q: invalid complete event %q", c.Start, name)
+		}
+	}
+	return nil
+}
+
+// defaultCorrelations are the operations of DNSimple notified by a start and a complete event.
+var defaultCorrelations = []CorrelationConfig{
+	{Start: "dnssec.rotation_start", Complete: []string{"dnssec.rotation_complete"}},
+	{Start: "push.initiate", Complete: []string{"push.accept", "push.reject"}},
+}
+
+// correlations remembers the messages of the operations in progress, so that the event completing
+// an operation updates the message of the event that started it.
+type correlations struct {
+	// starts are the start events, by complete event.
+	starts  map[string]string
+	isStart map[string]bool
+
+	mu         sync.Mutex
+	operations map[string]*operation
+	order      []string
+}
+
+// operation is the message of the event starting an operation.
+type operation struct {
+	ts      string
+	text    string
+	started time.Time
+}
+
+// newCorrelations returns the correlations of the configuration, the default ones when nil.
+func newCorrelations(configs []CorrelationConfig) *correlations {
+	if configs == nil {
+		configs = defaultCorrelations
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+	c := &correlations{starts: map[string]string{}, isStart: map[string]bool{}, operations: map[string]*operation{}}
+	for _, config := range configs {
+		c.isStart[config.Start] = true
+		for _, name := range config.Complete {
+			c.starts[name] = config.Start
+		}
+	}
+	return c
+}
+
+// correlationKey returns the key of the operation started by the event: the start event and its
+// certificate or domain, such as the domain of the DNSSEC records. It returns an empty key
+// for the events without resource.
+func correlationKey(start string, e *webhook.Event) string {
+	key := slackThreadKey(e)
+	if key == "" && e.Account != nil {
+		if id := eventDomainID(e); id != 0 {
+			key = fmt.Sprintf("%d/domain-id/%d", e.Account.ID, id)
+		}
+	}
+	if key == "" {
+		return ""
+	}
+	return start + "/" + key
+}
+
+// start remembers the message of the event if it starts an operation.
+func (c *correlations) start(e *webhook.Event, ts, text string, now time.Time) {
+	if c == nil || ts == "" || !c.isStart[e.Name] {
+		return
+	}
+	key := correlationKey(e.Name, e)
+	if key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.operations[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.operations[key] = &operation{ts: ts, text: text, started: now}
+	for len(c.order) > maxCorrelations {
+		delete(c.operations, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// complete returns the key and the operation completed by the event. It returns nil if the event
+// doesn't complete an operation, or if the start of the operation wasn't received within the window.
+func (c *correlations) complete(e *webhook.Event, now time.Time) (string, *operation) {
+	if c == nil {
+		return "", nil
+	}
+	start, ok := c.starts[e.Name]
+	if !ok {
+		return "", nil
+	}
+	key := correlationKey(start, e)
+	if key == "" {
+		return "", nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	op, ok := c.operations[key]
+	if !ok || now.Sub(op.started) > correlationWindow {
+		return "", nil
+	}
+	return key, op
+}
+
+// forget removes the completed operation.
+func (c *correlations) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.operations, key)
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
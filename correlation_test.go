@@ -0,0 +1,95 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestSlackService_PostEventCorrelations(t *testing.T) {
+	var requests []string
+	var payloads []slackPayload
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload slackPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid Slack payload: %v", err)
+		}
+		requests = append(requests, r.URL.Path)
+		payloads = append(payloads, payload)
+		fmt.Fprintf(w, `{"ok": true, "ts": "1600000000.00000%d"}`, len(payloads))
+	}))
+	defer target.Close()
+
+	service, err := newDestinationService(DestinationConfig{Name: "ops", Type: "slack", BotToken: "xoxb-token", Channel: "#ops", ThreadWindow: Duration(time.Nanosecond)}, &formatting{})
+	if err != nil {
+		t.Fatalf("newDestinationService returned error: %v", err)
+	}
+	slack := service.(*retryingService).MessagingService.(*SlackService)
+	slack.APIURL = target.URL
+
+	events := []string{
+		`{"name": "push.initiate", "data": {"push": {"id": 1, "domain_id": 2}, "domain": {"id": 2, "name": "example.com"}}}`,
+		`{"name": "push.accept", "data": {"push": {"id": 1, "domain_id": 2}, "domain": {"id": 2, "name": "example.com"}}}`,
+		`{"name": "push.reject", "data": {"push": {"id": 1, "domain_id": 2}, "domain": {"id": 2, "name": "example.com"}}}`,
+	}
+	for _, payload := range events {
+		event, err := webhook.ParseEvent([]byte(payload[:len(payload)-1] + `, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, payload)
+		}
+		if _, err := slack.PostEvent(context.Background(), event); err != nil {
+			t.Fatalf("PostEvent returned error: %v", err)
+		}
+	}
+
+	if want, got := fmt.Sprint([]string{"/chat.postMessage", "/chat.postMessage", "/chat.update", "/chat.postMessage"}), fmt.Sprint(requests); want != got {
+		t.Fatalf("PostEvent expected requests %v, got %v", want, got)
+	}
+	if want, got := "1600000000.000001", payloads[1].ThreadTS; want != got {
+		t.Errorf("PostEvent expected the completion in thread %v, got %v", want, got)
+	}
+	if want, got := "1600000000.000001", payloads[2].TS; want != got {
+		t.Errorf("PostEvent expected the update of %v, got %v", want, got)
+	}
+	if want, got := payloads[0].Text, payloads[2].Text; want != got {
+		t.Errorf("PostEvent expected the update to keep the text %q, got %q", want, got)
+	}
+	if want, got := "", payloads[3].ThreadTS; want != got {
+		t.Errorf("PostEvent expected the operation to be completed once, got thread %v", got)
+	}
+}
+
+func TestCorrelations(t *testing.T) {
+	event := func(name string) *webhook.Event {
+		event, err := webhook.ParseEvent([]byte(`{"name": "` + name + `", "data": {"delegation_signer_record": {"id": 1, "domain_id": 2}}, "account": {"id": 1010}}`))
+		if err != nil {
+			t.Fatalf("Error parsing: %v", err)
+		}
+		return event
+	}
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	c := newCorrelations(nil)
+	c.start(event("dnssec.rotation_start"), "1600000000.000001", "rotation", now)
+	if _, op := c.complete(event("dnssec.rotation_complete"), now.Add(correlationWindow+time.Second)); op != nil {
+		t.Errorf("complete expected no operation after the window, got %v", op.ts)
+	}
+	key, op := c.complete(event("dnssec.rotation_complete"), now.Add(time.Hour))
+	if op == nil || op.ts != "1600000000.000001" {
+		t.Fatalf("complete expected the operation, got %v", op)
+	}
+	c.forget(key)
+	if _, op := c.complete(event("dnssec.rotation_complete"), now.Add(time.Hour)); op != nil {
+		t.Errorf("complete expected no operation once forgotten, got %v", op.ts)
+	}
+
+	if c := newCorrelations([]CorrelationConfig{}); c != nil {
+		t.Errorf("newCorrelations expected no correlations when disabled")
+	}
+}
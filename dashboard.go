@@ -0,0 +1,280 @@
+package strillone
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultDashboardLimit is the number of events listed by the dashboard.
+const defaultDashboardLimit = 50
+
+// dashboardAuth wraps a dashboard handler and requires the admin token, or an API key with the role, either as a bearer token
+// or as the password of the HTTP basic authentication, so that the browsers can prompt for it.
+// With the OpenID Connect login, the users logged in are authorized with the role of their session,
+// and the others are redirected to the login. Like the admin API, the dashboard is disabled
+// when no admin token, key or login is configured, and also when the history is not enabled.
+func (s *Server) dashboardAuth(role string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Dashboard request")
+
+		routing := s.currentRouting()
+		if (len(routing.adminKeys) == 0 && routing.oidc == nil) || s.history == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		given := bearerToken(r)
+		if _, password, ok := r.BasicAuth(); ok {
+			given = password
+		}
+		key := authenticate(routing.adminKeys, given)
+		if key == nil && routing.oidc != nil {
+			key = routing.oidc.session(r, time.Now())
+		}
+		if key == nil && routing.oidc != nil && r.Method == "GET" && given == "" {
+			http.Redirect(w, r, "/dashboard/login?"+url.Values{"return": {r.URL.RequestURI()}}.Encode(), http.StatusFound)
+			return
+		}
+		if key == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="strillone"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !key.allows(role) {
+			http.Error(w, fmt.Sprintf("the %s role is required", role), http.StatusForbidden)
+			return
+		}
+
+		handle(w, withAdminKey(r, key), params)
+	}
+}
+
+// dashboardEvent is an event listed by the dashboard.
+type dashboardEvent struct {
+	*HistoryEvent
+
+	// Message is the text of the event, or an explanation of why it could not be formatted.
+	Message string
+
+	// Payload is the indented payload.
+	Payload string
+}
+
+// dashboardPage is the data of the dashboard template.
+type dashboardPage struct {
+	Filter *HistoryFilter
+	Search string
+	Events []*dashboardEvent
+	Notice string
+	Error  string
+	CSRF   string
+
+	// Operator is true if the user can redeliver the events.
+	Operator bool
+
+	// User is the user logged in with OpenID Connect.
+	User string
+}
+
+// Dashboard lists the recent events, their details and their delivery attempts,
+// filtered by the same query parameters and search as /api/events.
+func (s *Server) Dashboard(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	page := &dashboardPage{
+		Notice: r.URL.Query().Get("notice"),
+		Search: r.URL.Query().Get("q"),
+		CSRF:   s.dashboardCSRF(),
+
+		Operator: requestAllows(r, RoleOperator),
+	}
+	if key := requestAdminKey(r); key != nil && key.token == "" {
+		page.User = key.name
+	}
+
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		page.Error = err.Error()
+		filter = &HistoryFilter{}
+	} else {
+		if r.URL.Query().Get("limit") == "" {
+			filter.Limit = defaultDashboardLimit
+		}
+		events, err := s.history.Query(filter)
+		if err != nil {
+			log.Error().Err(err).Msg("Error querying the history")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, event := range events {
+			page.Events = append(page.Events, newDashboardEvent(event))
+		}
+	}
+	page.Filter = filter
+
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, page); err != nil {
+		log.Error().Err(err).Msg("Error rendering the dashboard")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	buf.WriteTo(w)
+}
+
+// DashboardRedeliver redelivers an event of the history to the destinations currently routed,
+// then redirects back to the dashboard.
+func (s *Server) DashboardRedeliver(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(s.dashboardCSRF())) {
+		http.Error(w, "invalid form token", http.StatusForbidden)
+		return
+	}
+
+	tenant, requestID := r.PostFormValue("tenant"), r.PostFormValue("request_id")
+	notice := fmt.Sprintf("Event %s redelivered", requestID)
+	if err := s.redeliver(r.Context(), tenant, requestID); err != nil {
+		notice = fmt.Sprintf("Event %s not redelivered: %v", requestID, err)
+	}
+	http.Redirect(w, r, "/dashboard?notice="+url.QueryEscape(notice), http.StatusSeeOther)
+}
+
+// redeliver delivers again the event of the history to the destinations routed by the tenant.
+// The attempts are added to the event in the history.
+func (s *Server) redeliver(ctx context.Context, tenant, requestID string) error {
+	entry, err := s.history.Get(tenant, requestID)
+	if err != nil {
+		return err
+	}
+	result, err := s.Replay(ctx, entry.Payload, &ReplayOptions{Tenant: tenant})
+	if err != nil {
+		return err
+	}
+	if len(result.Messages)+len(result.Errors) == 0 {
+		return fmt.Errorf("no route matches %s", result.Event)
+	}
+
+	var failed []string
+	for name := range result.Errors {
+		failed = append(failed, name)
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("delivery failed to %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// dashboardCSRF returns the token of the dashboard forms, derived from the admin token and keys,
+// and the session key of the login, that the pages of other sites can't guess.
+func (s *Server) dashboardCSRF() string {
+	routing := s.currentRouting()
+	var secret []byte
+	for _, key := range routing.adminKeys {
+		secret = append(secret, key.token...)
+	}
+	if routing.oidc != nil {
+		secret = append(secret, routing.oidc.sessionKey...)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("dashboard"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDashboardEvent(entry *HistoryEvent) *dashboardEvent {
+	event := &dashboardEvent{HistoryEvent: entry, Payload: string(entry.Payload)}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, entry.Payload, "", "  "); err == nil {
+		event.Payload = indented.String()
+	}
+	event.Message = dashboardMessage(entry.Payload)
+	return event
+}
+
+// dashboardMessage formats the payload as plain text.
+func dashboardMessage(payload []byte) string {
+	event, err := webhook.ParseEvent(payload)
+	if err != nil {
+		return fmt.Sprintf("invalid payload: %v", err)
+	}
+	text, err := formatMessage(textRenderer{}, event)
+	if err != nil {
+		return err.Error()
+	}
+	return text
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Strillone</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; font-size: 14px; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; vertical-align: top; padding: 6px 8px; border-bottom: 1px solid #ddd; }
+pre { background: #f6f6f6; padding: 8px; max-height: 24em; overflow: auto; }
+form.filter input { width: 10em; }
+form.filter input.search { width: 30em; }
+.notice { background: #eef6ff; padding: 8px; }
+.error { background: #ffeeee; padding: 8px; }
+.delivered { color: #1a7f37; }
+.failed, .circuit-open { color: #cf222e; }
+.quiet, .batched { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>Strillone</h1>
+{{with .User}}<p>{{.}} &middot; <a href="/dashboard/logout">Log out</a></p>{{end}}
+{{with .Notice}}<p class="notice">{{.}}</p>{{end}}
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+<form class="filter" method="get" action="/dashboard">
+<input class="search" name="q" placeholder="search, e.g. domain:example.com type:zone_record.update since:7d" value="{{.Search}}">
+<input name="event" placeholder="event, e.g. domain.*" value="{{.Filter.Event}}">
+<input name="tenant" placeholder="tenant" value="{{.Filter.Tenant}}">
+<input name="domain" placeholder="domain" value="{{.Filter.Domain}}">
+<input name="status" placeholder="status" value="{{.Filter.Status}}">
+<button type="submit">Filter</button>
+</form>
+<table>
+<thead><tr><th>Received</th><th>Tenant</th><th>Event</th><th>Message</th><th>Deliveries</th><th></th></tr></thead>
+<tbody>
+{{range .Events}}
+<tr>
+<td>{{.ReceivedAt.Format "2006-01-02 15:04:05 MST"}}</td>
+<td>{{.Tenant}}</td>
+<td>{{.Name}}<br><small>{{.RequestID}}</small></td>
+<td>{{.Message}}
+<details><summary>Payload</summary><pre>{{.Payload}}</pre></details></td>
+<td>{{range .Deliveries}}
+<div class="{{.Status}}">{{.Destination}}: {{.Status}}{{with .StatusCode}} (HTTP {{.}}){{end}} in {{.LatencyMs}}ms{{with .Response}}<br><small>{{.}}</small>{{end}}</div>
+{{else}}<em>none</em>{{end}}</td>
+<td>{{if $.Operator}}<form method="post" action="/dashboard/redeliver">
+<input type="hidden" name="csrf" value="{{$.CSRF}}">
+<input type="hidden" name="tenant" value="{{.Tenant}}">
+<input type="hidden" name="request_id" value="{{.RequestID}}">
+<button type="submit">Redeliver</button>
+</form>{{end}}</td>
+</tr>
+{{else}}
+<tr><td colspan="6"><em>No events.</em></td></tr>
+{{end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,99 @@
+package strillone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServer_Dashboard(t *testing.T) {
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	config, err := ParseConfig([]byte(`{
+		"admin": {"token": "secret"},
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A", "retry": {"max_attempts": 1}}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetHistory(history)
+	failing := &failingService{errs: []error{&statusError{StatusCode: 500, message: "internal_error"}}}
+	server.routing.services["ops"] = failing
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "5c9a2e7f-dashboard-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	server.ServeHTTP(httptest.NewRecorder(), request)
+
+	request, _ = http.NewRequest("GET", "/dashboard", nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusUnauthorized, response.Code; want != got {
+		t.Fatalf("GET /dashboard without credentials expected HTTP %v, got %v", want, got)
+	}
+	if want, got := `Basic realm="strillone"`, response.Header().Get("WWW-Authenticate"); want != got {
+		t.Errorf("GET /dashboard expected challenge %v, got %v", want, got)
+	}
+
+	request, _ = http.NewRequest("GET", "/dashboard", nil)
+	request.SetBasicAuth("admin", "secret")
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("GET /dashboard expected HTTP %v, got %v", want, got)
+	}
+	body := response.Body.String()
+	for _, expected := range []string{"domain.create", "[User] example@example.com created the domain example.com", "ops: failed (HTTP 500)"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("GET /dashboard expected to contain %q, got %v", expected, body)
+		}
+	}
+
+	redeliver := func(csrf string) *httptest.ResponseRecorder {
+		form := url.Values{"csrf": {csrf}, "request_id": {"5c9a2e7f-dashboard-0000-000000000001"}}
+		request, _ := http.NewRequest("POST", "/dashboard/redeliver", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth("admin", "secret")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	if want, got := http.StatusForbidden, redeliver("forged").Code; want != got {
+		t.Errorf("POST /dashboard/redeliver with an invalid form token expected HTTP %v, got %v", want, got)
+	}
+
+	response = redeliver(server.dashboardCSRF())
+	if want, got := http.StatusSeeOther, response.Code; want != got {
+		t.Fatalf("POST /dashboard/redeliver expected HTTP %v, got %v", want, got)
+	}
+	if want, got := "/dashboard?notice=Event+5c9a2e7f-dashboard-0000-000000000001+redelivered", response.Header().Get("Location"); want != got {
+		t.Errorf("POST /dashboard/redeliver expected redirect to %v, got %v", want, got)
+	}
+
+	event, err := history.Get("", "5c9a2e7f-dashboard-0000-000000000001")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if want, got := 2, len(event.Deliveries); want != got {
+		t.Fatalf("event expected %v deliveries, got %v", want, got)
+	}
+	if want, got := "delivered", event.Deliveries[1].Status; want != got {
+		t.Errorf("redelivery expected %v, got %v", want, got)
+	}
+}
+
+func TestServer_DashboardDisabled(t *testing.T) {
+	server, _ := newAdminTestServer()
+	request, _ := http.NewRequest("GET", "/dashboard", nil)
+	request.SetBasicAuth("admin", "secret")
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusNotFound, response.Code; want != got {
+		t.Errorf("GET /dashboard without history expected HTTP %v, got %v", want, got)
+	}
+}
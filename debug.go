@@ -0,0 +1,181 @@
+package strillone
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// EnableDebug enables the /debug/status and /debug/pprof endpoints, that require the admin token.
+func (s *Server) EnableDebug() {
+	s.debug = true
+}
+
+// debugAuth wraps a diagnostics handler, that is not found unless the diagnostics are enabled.
+func (s *Server) debugAuth(handle httprouter.Handle) httprouter.Handle {
+	admin := s.adminAuth(RoleAdmin, handle)
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if !s.debug {
+			http.NotFound(w, r)
+			return
+		}
+		admin(w, r, params)
+	}
+}
+
+// DebugPprof serves the runtime profiles of net/http/pprof.
+func (s *Server) DebugPprof(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	switch strings.TrimPrefix(params.ByName("profile"), "/") {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
+
+// DebugStatus represents the runtime state of the server.
+type DebugStatus struct {
+	Uptime     string `json:"uptime"`
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heap_alloc"`
+	HeapSys    uint64 `json:"heap_sys"`
+	NumGC      uint32 `json:"num_gc"`
+
+	// Inflight is the number of webhooks being received.
+	Inflight int `json:"inflight"`
+
+	Pool  *DebugPoolStatus  `json:"pool,omitempty"`
+	Queue *DebugQueueStatus `json:"queue,omitempty"`
+
+	// Batches and Digests are the number of pending batches and digests, including the held events.
+	Batches int `json:"batches"`
+	Digests int `json:"digests"`
+
+	Breakers []DebugBreakerStatus `json:"breakers"`
+}
+
+// DebugPoolStatus represents the state of the worker pool.
+type DebugPoolStatus struct {
+	Workers  int `json:"workers"`
+	Waiting  int `json:"waiting"`
+	Capacity int `json:"capacity"`
+}
+
+// DebugQueueStatus represents the state of the delivery queue.
+type DebugQueueStatus struct {
+	Jobs        int `json:"jobs"`
+	DeadLetters int `json:"dead_letters"`
+}
+
+// DebugBreakerStatus represents the state of the circuit breaker of a destination.
+type DebugBreakerStatus struct {
+	Tenant      string     `json:"tenant"`
+	Destination string     `json:"destination"`
+	State       string     `json:"state"`
+	Failures    int        `json:"failures"`
+	OpenUntil   *time.Time `json:"open_until,omitempty"`
+}
+
+// DebugStatus returns the goroutines, memory, queue depths and circuit breaker states.
+func (s *Server) DebugStatus(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	status := &DebugStatus{
+		Uptime:     time.Since(s.started).Round(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  memory.HeapAlloc,
+		HeapSys:    memory.HeapSys,
+		NumGC:      memory.NumGC,
+		Inflight:   s.drain.count(),
+		Batches:    s.batches.len(),
+		Digests:    s.digests.len(),
+		Breakers:   s.breakers.status(),
+	}
+	if s.pool != nil {
+		status.Pool = &DebugPoolStatus{Workers: s.pool.size, Waiting: len(s.pool.tasks), Capacity: cap(s.pool.tasks)}
+	}
+	if s.queue != nil {
+		jobs, deadLetters, err := s.queue.Len()
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		status.Queue = &DebugQueueStatus{Jobs: jobs, DeadLetters: deadLetters}
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// count returns the number of in-flight webhooks.
+func (d *drain) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inflight
+}
+
+// len returns the number of pending batches.
+func (b *eventBatches) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.batches)
+}
+
+// len returns the number of pending digests.
+func (d *digests) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.digests)
+}
+
+// status returns the state of the circuit breakers, sorted by tenant and destination.
+func (c *circuitBreakers) status() []DebugBreakerStatus {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.breakers))
+	for key := range c.breakers {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+	sort.Strings(keys)
+
+	statuses := []DebugBreakerStatus{}
+	now := time.Now()
+	for _, key := range keys {
+		c.mu.Lock()
+		breaker := c.breakers[key]
+		c.mu.Unlock()
+
+		parts := strings.SplitN(key, "/", 2)
+		status := DebugBreakerStatus{Tenant: parts[0], Destination: parts[1]}
+
+		breaker.mu.Lock()
+		status.Failures = breaker.failures
+		switch {
+		case !breaker.open:
+			status.State = "closed"
+		case breaker.trial || !now.Before(breaker.openUntil):
+			status.State = "half-open"
+		default:
+			status.State = "open"
+		}
+		if breaker.open {
+			until := breaker.openUntil
+			status.OpenUntil = &until
+		}
+		breaker.mu.Unlock()
+
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
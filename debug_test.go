@@ -0,0 +1,90 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDebug_Disabled(t *testing.T) {
+	server, _ := newAdminTestServer()
+
+	for _, path := range []string{"/debug/status", "/debug/pprof/"} {
+		if want, got := http.StatusNotFound, adminRequest(server, "GET", path, "").Code; want != got {
+			t.Errorf("GET %v expected HTTP %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestDebug_Unauthorized(t *testing.T) {
+	server, _ := newAdminTestServer()
+	server.EnableDebug()
+
+	request, _ := http.NewRequest("GET", "/debug/status", nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusUnauthorized, response.Code; want != got {
+		t.Errorf("GET /debug/status without token expected HTTP %v, got %v", want, got)
+	}
+}
+
+func TestDebugStatus(t *testing.T) {
+	server, _ := newAdminTestServer()
+	server.EnableDebug()
+	server.SetWorkerPool(2, 10)
+	defer server.pool.close(context.Background())
+
+	queue, cleanup := openTestQueue(t)
+	defer cleanup()
+	server.SetQueue(queue, 0)
+	queue.Enqueue(&Job{Destination: "ops", NextAttempt: time.Now()})
+
+	now := time.Now()
+	breaker := server.breakers.get("", "ops")
+	for i := 0; i < defaultBreakerFailureThreshold; i++ {
+		breaker.record(errors.New("downstream outage"), now, nil)
+	}
+
+	response := adminRequest(server, "GET", "/debug/status", "")
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("GET /debug/status expected HTTP %v, got %v", want, got)
+	}
+
+	status := &DebugStatus{}
+	if err := json.Unmarshal(response.Body.Bytes(), status); err != nil {
+		t.Fatalf("GET /debug/status returned invalid JSON: %v", err)
+	}
+	if status.Goroutines == 0 {
+		t.Errorf("status expected goroutines")
+	}
+	if status.Pool == nil || status.Pool.Workers != 2 || status.Pool.Capacity != 10 {
+		t.Errorf("status expected pool of 2 workers and capacity 10, got %+v", status.Pool)
+	}
+	if status.Queue == nil || status.Queue.Jobs != 1 {
+		t.Errorf("status expected 1 queued job, got %+v", status.Queue)
+	}
+	if want, got := 1, len(status.Breakers); want != got {
+		t.Fatalf("status expected %v breakers, got %v", want, got)
+	}
+	if want, got := "open", status.Breakers[0].State; want != got {
+		t.Errorf("breaker expected state %v, got %v", want, got)
+	}
+}
+
+func TestDebugPprof(t *testing.T) {
+	server, _ := newAdminTestServer()
+	server.EnableDebug()
+
+	response := adminRequest(server, "GET", "/debug/pprof/goroutine?debug=1", "")
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Errorf("GET /debug/pprof/goroutine expected HTTP %v, got %v", want, got)
+	}
+	response = adminRequest(server, "GET", "/debug/pprof/cmdline", "")
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Errorf("GET /debug/pprof/cmdline expected HTTP %v, got %v", want, got)
+	}
+}
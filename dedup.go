@@ -0,0 +1,33 @@
+package strillone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// dedupKey returns the key identifying the content of the event: the event name, actor,
+// account and data, regardless of the request identifier.
+func (t *routingTable) dedupKey(event *webhook.Event) string {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(event.GetPayload(), &payload); err != nil {
+		return t.cacheKey(event)
+	}
+	delete(payload, "request_identifier")
+
+	// json.Marshal sorts the map keys, so the same content always has the same key
+	data, _ := json.Marshal(payload)
+	sum := sha256.Sum256(data)
+	return t.name + "/" + hex.EncodeToString(sum[:])
+}
+
+// forget releases the event from the replay and duplicate tracking,
+// so that it can be received again after a failed delivery.
+func (s *Server) forget(routing *routingTable, event *webhook.Event) {
+	s.replays.release(routing.cacheKey(event))
+	if routing.dedupWindow > 0 {
+		s.duplicates.release(routing.dedupKey(event))
+	}
+}
@@ -0,0 +1,47 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEvents_DedupWindow(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}],
+		"dedup_window": "10m"
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.routing.services["ops"] = &SlackService{Token: "-"}
+
+	payload := `{"data": {"domain": {"id": 1, "name": "%s"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+	tests := []struct {
+		domain    string
+		requestID string
+		want      string
+	}{
+		{"example.com", "f0a3b6d9-dedup-0000-000000000001", ""},
+		// same content, different request identifier
+		{"example.com", "f0a3b6d9-dedup-0000-000000000002", "skipped;duplicate"},
+		{"example.org", "f0a3b6d9-dedup-0000-000000000003", ""},
+	}
+
+	for _, tt := range tests {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, tt.domain, tt.requestID)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want := http.StatusOK; want != response.Code {
+			t.Errorf("POST /events %v expected HTTP %v, got %v", tt.requestID, want, response.Code)
+		}
+		if got := response.Header().Get(headerProcessingStatus); tt.want != got {
+			t.Errorf("POST /events %v expected status %q, got %q", tt.requestID, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Destination is a target of the messages, such as Slack.
+//
+// The destinations are created by the factories registered by type with RegisterDestination,
+// and wrapped by Strillone with the retries, the circuit breaker, the rate limiting, the quiet windows,
+// the metrics and the history of the deliveries, like the Slack destinations.
+type Destination interface {
+	// Name returns the name of the destination in the configuration.
+	Name() string
+
+	// Format returns the message of the event, in the markup of the destination.
+	// It returns an error if the event can't be formatted, such as an invalid template.
+	Format(event *webhook.Event) (string, error)
+
+	// Deliver delivers the message of the event. The event is nil for the messages of Strillone itself,
+	// such as the health notifications.
+	Deliver(ctx context.Context, event *webhook.Event, text string) error
+
+	// HealthCheck returns an error if the destination can't be reached, or rejects the credentials.
+	HealthCheck(ctx context.Context) error
+}
+
+// DestinationOptions are the settings of Strillone available to the destination factories.
+type DestinationOptions struct {
+	// Format formats the messages with the templates, the translations and the settings of the configuration.
+	Format *MessageFormat
+
+	// HTTPClient is the client of the destination, with its TLS settings.
+	HTTPClient *http.Client
+}
+
+// DestinationFactory returns the destination of the configuration. The settings specific to the type
+// of destination are in config.Settings.
+type DestinationFactory func(config DestinationConfig, options *DestinationOptions) (Destination, error)
+
+var (
+	destinationFactoriesMu sync.RWMutex
+	destinationFactories   = map[string]DestinationFactory{}
+)
+
+func init() {
+	RegisterDestination("slack", newSlackDestination)
+}
+
+// RegisterDestination makes the destinations of the type available in the configuration.
+// It is usually called in an init function, and panics if the type is already registered.
+func RegisterDestination(kind string, factory DestinationFactory) {
+	destinationFactoriesMu.Lock()
+	defer destinationFactoriesMu.Unlock()
+	if factory == nil {
+		panic("strillone: RegisterDestination factory is nil")
+	}
+	if _, ok := destinationFactories[kind]; ok {
+		panic("strillone: RegisterDestination called twice for " + kind)
+	}
+	destinationFactories[kind] = factory
+}
+
+// DestinationTypes returns the registered types of destination, sorted.
+func DestinationTypes() []string {
+	destinationFactoriesMu.RLock()
+	defer destinationFactoriesMu.RUnlock()
+	kinds := make([]string, 0, len(destinationFactories))
+	for kind := range destinationFactories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func destinationFactory(kind string) (DestinationFactory, bool) {
+	destinationFactoriesMu.RLock()
+	defer destinationFactoriesMu.RUnlock()
+	factory, ok := destinationFactories[kind]
+	return factory, ok
+}
+
+// MessageFormat formats the messages of the events like the Slack destinations: with the templates,
+// the severities, the translations, the account labels and the environment of the configuration.
+type MessageFormat struct {
+	formatting *formatting
+	catalog    map[string]string
+}
+
+// Message returns the message of the event, in the markup of the renderer.
+// It returns the error of the template of the event.
+func (f *MessageFormat) Message(renderer Renderer, event *webhook.Event) (string, error) {
+	r := &formatRenderer{Renderer: renderer, format: f}
+	text, err := f.formatting.templates.render(r, event, f.Severity(event.Name))
+	if err != nil {
+		return "", err
+	}
+	if f.formatting.environment != "" {
+		text = fmt.Sprintf("[%s] %s", renderer.Escape(f.formatting.environment), text)
+	}
+	return text, nil
+}
+
+// Severity returns the severity of the event.
+func (f *MessageFormat) Severity(name string) Severity {
+	return f.formatting.severities.Of(name)
+}
+
+// Presentation returns the presentation of the event: its emoji, color, sound and alert severity,
+// with the presentation of its family and of its severity in the configuration, and the defaults of its severity.
+func (f *MessageFormat) Presentation(event *webhook.Event) PresentationConfig {
+	return eventPresentation(f.formatting.presentation, f.formatting.severityPresentation, event.Name, f.Severity(event.Name))
+}
+
+// formatRenderer completes a renderer with the translations, the account labels and the dashboard of the format.
+type formatRenderer struct {
+	Renderer
+	format *MessageFormat
+}
+
+// Translate implements Translator
+func (r *formatRenderer) Translate(message string) string {
+	return r.format.catalog[message]
+}
+
+// AccountLabel implements AccountLabeler
+func (r *formatRenderer) AccountLabel(accountID int64) (string, bool) {
+	label, ok := r.format.formatting.accountLabels[accountID]
+	return label, ok
+}
+
+// DashboardURL implements DashboardLinker
+func (r *formatRenderer) DashboardURL() string {
+	return r.format.formatting.dashboardURL
+}
+
+// destinationService delivers the messages to a Destination, so that the destinations registered
+// by type share the delivery pipeline of the messaging services.
+type destinationService struct {
+	Destination
+	renderer Renderer
+}
+
+// newDestinationMessagingService returns the messaging service of the destination.
+// The links of the messages of Strillone itself are formatted by the destination when it is a Renderer,
+// and as plain text otherwise.
+func newDestinationMessagingService(destination Destination) MessagingService {
+	if service, ok := destination.(MessagingService); ok {
+		return service
+	}
+	renderer, ok := destination.(Renderer)
+	if !ok {
+		renderer = textRenderer{}
+	}
+	return &destinationService{Destination: destination, renderer: renderer}
+}
+
+// FormatLink implements MessagingService
+func (d *destinationService) FormatLink(name, url string) string {
+	return d.renderer.FormatLink(name, url)
+}
+
+// Escape implements Renderer
+func (d *destinationService) Escape(text string) string {
+	return d.renderer.Escape(text)
+}
+
+// PostEvent implements MessagingService
+func (d *destinationService) PostEvent(ctx context.Context, event *webhook.Event) (string, error) {
+	text, err := d.Format(event)
+	if err != nil {
+		return "", err
+	}
+	return text, d.Deliver(ctx, event, text)
+}
+
+// PostMessage implements MessagingService
+func (d *destinationService) PostMessage(ctx context.Context, text string) error {
+	return d.Deliver(ctx, nil, text)
+}
+
+// DestinationHealth represents the result of the health check of a destination.
+type DestinationHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AdminCheckDestination runs the health check of the destination.
+func (s *Server) AdminCheckDestination(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	name := params.ByName("name")
+	service, ok := s.currentRouting().services[name]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "destination not found")
+		return
+	}
+	health := &DestinationHealth{Name: name, Healthy: true}
+	if destination, ok := unwrapService(service).(Destination); ok {
+		if err := destination.HealthCheck(r.Context()); err != nil {
+			health.Healthy, health.Error = false, err.Error()
+		}
+	}
+	writeJSON(w, http.StatusOK, health)
+}
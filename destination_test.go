@@ -0,0 +1,200 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// recordingDestination is a destination of the "recording" type, recording the messages delivered.
+type recordingDestination struct {
+	name     string
+	format   *MessageFormat
+	settings map[string]string
+	errs     []error
+	messages []string
+}
+
+var recordingDestinations = map[string]*recordingDestination{}
+
+func init() {
+	RegisterDestination("recording", func(config DestinationConfig, options *DestinationOptions) (Destination, error) {
+		if config.Settings["channel"] == "" {
+			return nil, errors.New("missing channel")
+		}
+		destination := &recordingDestination{name: config.Name, format: options.Format, settings: config.Settings}
+		recordingDestinations[config.Name] = destination
+		return destination, nil
+	})
+}
+
+func (d *recordingDestination) Name() string {
+	return d.name
+}
+
+func (d *recordingDestination) Format(event *webhook.Event) (string, error) {
+	return d.format.Message(markdownRenderer{}, event)
+}
+
+func (d *recordingDestination) Deliver(_ context.Context, event *webhook.Event, text string) error {
+	if len(d.errs) > 0 {
+		err := d.errs[0]
+		d.errs = d.errs[1:]
+		return err
+	}
+	d.messages = append(d.messages, d.settings["channel"]+": "+text)
+	return nil
+}
+
+func (d *recordingDestination) HealthCheck(_ context.Context) error {
+	if d.settings["channel"] == "#down" {
+		return errors.New("channel not found")
+	}
+	return nil
+}
+
+func TestRegisterDestination(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"dashboard_url": "https://dnsimple.test",
+		"destinations": [{
+			"name": "chat", "type": "recording", "url": "",
+			"settings": {"channel": "#ops"},
+			"retry": {"max_attempts": 2, "initial_backoff": "1ms"}
+		}],
+		"routes": [{"events": ["domain.*"], "destinations": ["chat"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	chat := recordingDestinations["chat"]
+	chat.errs = []error{&statusError{StatusCode: http.StatusBadGateway, message: "bad gateway"}}
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "d7a3e5c1-destination-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("POST /events expected HTTP %v, got %v: %s", want, response.Code, response.Body)
+	}
+	// The failed delivery is retried, like for Slack.
+	want := "#ops: [[User](<https://dnsimple.test/a/1010/account>)] example@example.com created the domain [example.com](<https://dnsimple.test/a/1010/domains/example.com>)"
+	if len(chat.messages) != 1 || want != chat.messages[0] {
+		t.Errorf("POST /events expected messages %q, got %q", want, chat.messages)
+	}
+
+	// The messages of Strillone itself are delivered without event.
+	if err := server.routing.services["chat"].PostMessage(context.Background(), "Destination ops recovered"); err != nil {
+		t.Fatalf("PostMessage returned error: %v", err)
+	}
+	if want, got := "#ops: Destination ops recovered", chat.messages[len(chat.messages)-1]; want != got {
+		t.Errorf("PostMessage expected message %q, got %q", want, got)
+	}
+}
+
+func TestRegisterDestination_Invalid(t *testing.T) {
+	_, err := ParseConfig([]byte(`{"destinations": [{"name": "chat", "type": "recording", "url": ""}]}`))
+	if err == nil || !strings.Contains(err.Error(), "missing channel") {
+		t.Errorf("ParseConfig expected the error of the factory, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterDestination expected to panic for a registered type")
+		}
+	}()
+	RegisterDestination("slack", newSlackDestination)
+}
+
+func TestDestinationTypes(t *testing.T) {
+	if want, got := "recording,slack", strings.Join(DestinationTypes(), ","); want != got {
+		t.Errorf("DestinationTypes expected %v, got %v", want, got)
+	}
+}
+
+func TestAdminCheckDestination(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"admin": {"token": "secret"},
+		"destinations": [
+			{"name": "up", "type": "recording", "url": "", "settings": {"channel": "#ops"}},
+			{"name": "down", "type": "recording", "url": "", "settings": {"channel": "#down"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	tests := []struct {
+		name string
+		code int
+		want DestinationHealth
+	}{
+		{"up", http.StatusOK, DestinationHealth{Name: "up", Healthy: true}},
+		{"down", http.StatusOK, DestinationHealth{Name: "down", Error: "channel not found"}},
+		{"missing", http.StatusNotFound, DestinationHealth{}},
+	}
+	for _, tt := range tests {
+		request, _ := http.NewRequest("GET", "/admin/destinations/"+tt.name+"/health", nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if tt.code != response.Code {
+			t.Errorf("GET /admin/destinations/%s/health expected HTTP %v, got %v", tt.name, tt.code, response.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var got DestinationHealth
+		if err := json.Unmarshal(response.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if tt.want != got {
+			t.Errorf("GET /admin/destinations/%s/health expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSlackService_HealthCheck(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/valid":
+			http.Error(w, "no_text", http.StatusBadRequest)
+		case "/api/auth.test":
+			if r.Header.Get("Authorization") != "Bearer xoxb-valid" {
+				fmt.Fprint(w, `{"ok": false, "error": "invalid_auth"}`)
+				return
+			}
+			fmt.Fprint(w, `{"ok": true}`)
+		default:
+			http.Error(w, "no_service", http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		service *SlackService
+		healthy bool
+	}{
+		{&SlackService{URL: target.URL + "/services/valid"}, true},
+		{&SlackService{URL: target.URL + "/services/revoked"}, false},
+		{&SlackService{BotToken: "xoxb-valid", Channel: "#ops", APIURL: target.URL + "/api"}, true},
+		{&SlackService{BotToken: "xoxb-revoked", Channel: "#ops", APIURL: target.URL + "/api"}, false},
+		{&SlackService{Token: "-"}, true},
+	}
+	for _, tt := range tests {
+		err := tt.service.HealthCheck(context.Background())
+		if healthy := err == nil; tt.healthy != healthy {
+			t.Errorf("HealthCheck of %s expected healthy %v, got %v", tt.service.webhookURL(), tt.healthy, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package strillone
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// detailsField is the field of the payload carrying the details of the event.
+const detailsField = "strillone"
+
+// eventDetails complete an event with what the pipeline learned about it, such as the domain fetched
+// from the API. They are carried in the payload of the event, under the strillone field, so that they
+// reach the formatters with the event, including through the queue and the approvals.
+type eventDetails struct {
+	// Timestamp is when DNSimple sent the webhook, from the timestamp header.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+
+	// Account is the name of the account of the event, fetched from the API.
+	Account string `json:"account,omitempty"`
+
+	// Domain are the details of the domain of the events that only include the domain ID, fetched from the API.
+	Domain *DomainDetails `json:"domain,omitempty"`
+
+	// PreviousZoneRecord is the version of the record replaced by a zone_record.update.
+	PreviousZoneRecord *dnsimple.ZoneRecord `json:"previous_zone_record,omitempty"`
+
+	// ContactEmailChanged is true if a contact.update changed the email of the contact.
+	ContactEmailChanged bool `json:"contact_email_changed,omitempty"`
+}
+
+func (d *eventDetails) empty() bool {
+	return *d == eventDetails{}
+}
+
+// detailsOf returns the details carried by the event, empty if none.
+func detailsOf(e *webhook.Event) *eventDetails {
+	var container struct {
+		Details *eventDetails `json:"strillone"`
+	}
+	if err := json.Unmarshal(e.GetPayload(), &container); err != nil || container.Details == nil {
+		return &eventDetails{}
+	}
+	return container.Details
+}
+
+// withDetails returns the event carrying the details, in place of the details it carried.
+// The event is returned unchanged without details, or if its payload can't be completed.
+func withDetails(e *webhook.Event, details *eventDetails) *webhook.Event {
+	if details.empty() {
+		return e
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(e.GetPayload(), &payload); err != nil {
+		return e
+	}
+	payload[detailsField] = mustMarshal(details)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return e
+	}
+	completed, err := webhook.ParseEvent(data)
+	if err != nil {
+		withEvent(log.Warn(), e).Err(err).Msg("Error adding the details to the event")
+		return e
+	}
+	return completed
+}
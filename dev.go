@@ -0,0 +1,272 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxDevMessages is the number of messages kept by the development receiver.
+const maxDevMessages = 200
+
+// slackLinkPattern matches the links and the mentions of the Slack messages, such as <https://dnsimple.com|DNSimple> or <@U0JANE>.
+var slackLinkPattern = regexp.MustCompile(`<([^<>|]+)(?:\|([^<>]+))?>`)
+
+// DevMessage is a message received by the development receiver.
+type DevMessage struct {
+	ID          int       `json:"id"`
+	Destination string    `json:"destination"`
+	ReceivedAt  time.Time `json:"received_at"`
+	Color       string    `json:"color,omitempty"`
+
+	// HTML is the text of the message rendered as HTML, with the links of the Slack message.
+	HTML string `json:"html"`
+
+	// Error is the error of the configuration or of the webhook, instead of a message.
+	Error string `json:"error,omitempty"`
+
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// DevReceiver is a fake Slack, receiving the messages of the destinations in the development mode,
+// and showing them live on a web page.
+type DevReceiver struct {
+	// URL is the base URL of the receiver, such as http://localhost:4000.
+	URL string
+
+	mu       sync.Mutex
+	messages []*DevMessage
+	lastID   int
+}
+
+// NewDevReceiver returns a receiver at the base URL.
+func NewDevReceiver(url string) *DevReceiver {
+	return &DevReceiver{URL: strings.TrimSuffix(url, "/")}
+}
+
+// hookURL returns the incoming webhook URL of the destination.
+func (d *DevReceiver) hookURL(destination string) string {
+	return d.URL + "/hooks/" + destination
+}
+
+// ServeHTTP serves the page at /, the messages received after the ?after ID at /messages,
+// and receives the Slack messages on /hooks/<destination>.
+func (d *DevReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/hooks/"):
+		d.receive(w, r, strings.TrimPrefix(r.URL.Path, "/hooks/"))
+	case r.Method == http.MethodGet && r.URL.Path == "/messages":
+		after, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		writeJSON(w, http.StatusOK, d.after(after))
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, devPage)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// receive records the message of a Slack incoming webhook.
+func (d *DevReceiver) receive(w http.ResponseWriter, r *http.Request, destination string) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var payload slackPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+		return
+	}
+
+	message := &DevMessage{Destination: destination, HTML: slackHTML(payload.Text), Payload: data}
+	if len(payload.Attachments) > 0 {
+		message.Color = payload.Attachments[0].Color
+	}
+	d.add(message)
+	log.Info().Str("destination", destination).Str("text", payload.Text).Msg("Development message received")
+	fmt.Fprint(w, "ok")
+}
+
+// Error shows the error on the page, such as an invalid template.
+func (d *DevReceiver) Error(err error) {
+	d.add(&DevMessage{Error: err.Error()})
+}
+
+func (d *DevReceiver) add(message *DevMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastID++
+	message.ID = d.lastID
+	message.ReceivedAt = time.Now()
+	d.messages = append(d.messages, message)
+	if len(d.messages) > maxDevMessages {
+		d.messages = d.messages[len(d.messages)-maxDevMessages:]
+	}
+}
+
+// after returns the messages received after the ID.
+func (d *DevReceiver) after(id int) []*DevMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	messages := []*DevMessage{}
+	for _, message := range d.messages {
+		if message.ID > id {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Config returns a copy of the configuration delivering the messages of every destination to the receiver,
+// and accepting the webhooks without verification, so that sample payloads can be posted with curl.
+func (d *DevReceiver) Config(config *Config) *Config {
+	dev := *config
+	dev.Inbound = InboundConfig{MaxBodySize: config.Inbound.MaxBodySize}
+	dev.DedupWindow = 0
+	dev.Egress = nil
+	dev.Destinations = d.destinations(config.Destinations)
+	dev.Tenants = make([]TenantConfig, len(config.Tenants))
+	for i, tenant := range config.Tenants {
+		tenant.SigningSecret, tenant.RateLimit = "", nil
+		tenant.Destinations = d.destinations(tenant.Destinations)
+		dev.Tenants[i] = tenant
+	}
+	dev.Environments = make([]EnvironmentConfig, len(config.Environments))
+	for i, environment := range config.Environments {
+		environment.SigningSecret = ""
+		dev.Environments[i] = environment
+	}
+	return &dev
+}
+
+func (d *DevReceiver) destinations(destinations []DestinationConfig) []DestinationConfig {
+	dev := make([]DestinationConfig, len(destinations))
+	for i, destination := range destinations {
+		destination.URL = d.hookURL(destination.Name)
+		destination.BotToken, destination.Channel, destination.TLS, destination.HTTP = "", "", nil, nil
+		dev[i] = destination
+	}
+	return dev
+}
+
+// NewDevHandler returns the handler of the development mode: the webhooks are handled by the server,
+// and the rest by the receiver. The configuration is loaded again before each webhook,
+// so that the changes of the templates and of the configuration are applied immediately.
+func NewDevHandler(server *Server, receiver *DevReceiver, load func() (*Config, error)) http.Handler {
+	server.devMode = true
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebhookPath(r.URL.Path) {
+			receiver.ServeHTTP(w, r)
+			return
+		}
+		config, err := load()
+		if err == nil {
+			err = server.Reload(receiver.Config(config))
+		}
+		if err != nil {
+			receiver.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		server.ServeHTTP(w, r)
+	})
+}
+
+// isWebhookPath returns true for the inbound URLs of the webhooks.
+func isWebhookPath(path string) bool {
+	return path == "/events" || strings.HasPrefix(path, "/t/") || strings.HasPrefix(path, "/e/")
+}
+
+// slackHTML renders the text of a Slack message as HTML, with its links.
+func slackHTML(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range slackLinkPattern.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(html.EscapeString(slackUnescape(text[last:match[0]])))
+		target := slackUnescape(text[match[2]:match[3]])
+		label := target
+		if match[4] >= 0 {
+			label = slackUnescape(text[match[4]:match[5]])
+		}
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			fmt.Fprintf(&b, `<a href="%s" target="_blank">%s</a>`, html.EscapeString(target), html.EscapeString(label))
+		} else {
+			b.WriteString(html.EscapeString(strings.TrimPrefix(label, "@")))
+		}
+		last = match[1]
+	}
+	b.WriteString(html.EscapeString(slackUnescape(text[last:])))
+	return b.String()
+}
+
+// slackUnescape reverts the escaping of the Slack messages.
+func slackUnescape(text string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(text)
+}
+
+const devPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Strillone development</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; font-size: 14px; margin: 2em; color: #222; }
+.message { border-left: 4px solid #ddd; padding: 6px 10px; margin-bottom: 10px; }
+.meta { color: #666; font-size: 12px; }
+.error { background: #ffeeee; }
+pre { background: #f6f6f6; padding: 8px; max-height: 24em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>Strillone development</h1>
+<p>Post the webhooks to <code>/events</code>, such as <code>curl -d @payload.json http://localhost:4000/events</code>. The messages of the destinations are shown here.</p>
+<div id="messages"><em id="empty">No messages yet.</em></div>
+<script>
+var last = 0;
+var colors = {good: "#2eb67d", warning: "#ecb22e", danger: "#e01e5a"};
+function poll() {
+  fetch("/messages?after=" + last).then(function (response) { return response.json(); }).then(function (messages) {
+    messages.forEach(function (message) {
+      last = message.id;
+      var empty = document.getElementById("empty");
+      if (empty) { empty.remove(); }
+      var div = document.createElement("div");
+      div.className = "message" + (message.error ? " error" : "");
+      div.style.borderLeftColor = colors[message.color] || message.color || "#ddd";
+      var meta = document.createElement("div");
+      meta.className = "meta";
+      meta.textContent = new Date(message.received_at).toLocaleTimeString() + " " + (message.destination || "error");
+      div.appendChild(meta);
+      var text = document.createElement("div");
+      if (message.error) { text.textContent = message.error; } else { text.innerHTML = message.html; }
+      div.appendChild(text);
+      if (message.payload) {
+        var details = document.createElement("details");
+        var summary = document.createElement("summary");
+        summary.textContent = "Payload";
+        var pre = document.createElement("pre");
+        pre.textContent = JSON.stringify(message.payload, null, 2);
+        details.appendChild(summary);
+        details.appendChild(pre);
+        div.appendChild(details);
+      }
+      var list = document.getElementById("messages");
+      list.insertBefore(div, list.firstChild);
+    });
+  }).catch(function () {}).then(function () { setTimeout(poll, 1000); });
+}
+poll();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,152 @@
+package strillone
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_slackHTML(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"created <https://dnsimple.com/a/1010/domains/example.com|example.com>", `created <a href="https://dnsimple.com/a/1010/domains/example.com" target="_blank">example.com</a>`},
+		{"see <https://dnsimple.com>", `see <a href="https://dnsimple.com" target="_blank">https://dnsimple.com</a>`},
+		{"cc <@U0JANE>", "cc U0JANE"},
+		{"a &lt;b&gt; &amp; <script>", "a &lt;b&gt; &amp; script"},
+		{`<javascript:alert(1)|click> "quoted"`, "click &#34;quoted&#34;"},
+	}
+	for _, tt := range tests {
+		if got := slackHTML(tt.text); tt.want != got {
+			t.Errorf("slackHTML(%q) expected %q, got %q", tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestDevReceiver_Config(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"inbound": {"signing_secret": "secret", "bearer_token": "token", "replay_window": "5m"},
+		"dedup_window": "1m",
+		"destinations": [{"name": "ops", "type": "slack", "url": "", "bot_token": "xoxb-1", "channel": "C1"}],
+		"routes": [{"destinations": ["ops"]}],
+		"tenants": [{
+			"name": "team-a", "token": "team-a-token-0123456789", "signing_secret": "team-secret",
+			"destinations": [{"name": "alerts", "type": "slack", "url": "https://hooks.slack.com/services/B"}],
+			"routes": [{"destinations": ["alerts"]}]
+		}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+
+	dev := NewDevReceiver("http://localhost:4000/").Config(config)
+	if want, got := "http://localhost:4000/hooks/ops", dev.Destinations[0].URL; want != got {
+		t.Errorf("Config expected URL %q, got %q", want, got)
+	}
+	if dev.Destinations[0].BotToken != "" || dev.Destinations[0].Channel != "" {
+		t.Errorf("Config expected no bot token, got %q in %q", dev.Destinations[0].BotToken, dev.Destinations[0].Channel)
+	}
+	if want, got := "http://localhost:4000/hooks/alerts", dev.Tenants[0].Destinations[0].URL; want != got {
+		t.Errorf("Config expected tenant URL %q, got %q", want, got)
+	}
+	if dev.Inbound.SigningSecret != "" || dev.Inbound.BearerToken != "" || dev.Inbound.ReplayWindow != 0 || dev.DedupWindow != 0 || dev.Tenants[0].SigningSecret != "" {
+		t.Errorf("Config expected no verification, got %+v", dev.Inbound)
+	}
+
+	// The original configuration is unchanged.
+	if want, got := "xoxb-1", config.Destinations[0].BotToken; want != got {
+		t.Errorf("Config expected original bot token %q, got %q", want, got)
+	}
+	if want, got := "https://hooks.slack.com/services/B", config.Tenants[0].Destinations[0].URL; want != got {
+		t.Errorf("Config expected original tenant URL %q, got %q", want, got)
+	}
+}
+
+func TestNewDevHandler(t *testing.T) {
+	configJSON := `{
+		"inbound": {"signing_secret": "secret"},
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops"]}]
+	}`
+	var loadErr error
+	load := func() (*Config, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return ParseConfig([]byte(configJSON))
+	}
+
+	receiver := NewDevReceiver("")
+	handler := NewDevHandler(NewServer(nil), receiver, load)
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	receiver.URL = httpServer.URL
+
+	post := func() *http.Response {
+		t.Helper()
+		payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "d7a3e5c1-dev-0000-000000000001"}`
+		response, err := http.Post(httpServer.URL+"/events", "application/json", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		return response
+	}
+	messages := func(after string) []*DevMessage {
+		t.Helper()
+		response, err := http.Get(httpServer.URL + "/messages?after=" + after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		var messages []*DevMessage
+		if err := json.NewDecoder(response.Body).Decode(&messages); err != nil {
+			t.Fatal(err)
+		}
+		return messages
+	}
+
+	// The unsigned webhook is accepted, and the same payload can be posted again.
+	for i := 0; i < 2; i++ {
+		if want, got := http.StatusOK, post().StatusCode; want != got {
+			t.Fatalf("POST /events expected HTTP %v, got %v", want, got)
+		}
+	}
+	got := messages("0")
+	if want := 2; want != len(got) {
+		t.Fatalf("GET /messages expected %v messages, got %v", want, len(got))
+	}
+	if want := "ops"; want != got[0].Destination {
+		t.Errorf("GET /messages expected destination %q, got %q", want, got[0].Destination)
+	}
+	want := `[<a href="https://dnsimple.com/a/1010/account" target="_blank">User</a>] example@example.com created the domain <a href="https://dnsimple.com/a/1010/domains/example.com" target="_blank">example.com</a>`
+	if want != got[0].HTML {
+		t.Errorf("GET /messages expected HTML %q, got %q", want, got[0].HTML)
+	}
+	if got := messages("1"); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("GET /messages?after=1 expected the second message, got %+v", got)
+	}
+
+	// The configuration errors are shown on the page.
+	loadErr = errors.New("templates: invalid template")
+	if want, got := http.StatusInternalServerError, post().StatusCode; want != got {
+		t.Fatalf("POST /events expected HTTP %v, got %v", want, got)
+	}
+	if got := messages("2"); len(got) != 1 || got[0].Error != loadErr.Error() {
+		t.Errorf("GET /messages?after=2 expected the error, got %+v", got)
+	}
+
+	response, err := http.Get(httpServer.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if want, got := "text/html; charset=utf-8", response.Header.Get("Content-Type"); want != got {
+		t.Errorf("GET / expected Content-Type %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,188 @@
+package strillone
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// digestSummaryMessages is the number of event messages included in a digest.
+const digestSummaryMessages = 20
+
+// digestTarget is a destination receiving an event in a digest.
+type digestTarget struct {
+	destination string
+	period      time.Duration
+}
+
+// LookupDigests returns the destinations that should receive the event in a digest,
+// excluding the ones receiving it immediately, by the routes without tags. When several digest routes match
+// the same destination, the shortest period is used.
+func (t *routingTable) LookupDigests(eventName string, immediate []string) []digestTarget {
+	return t.lookupDigests(eventName, immediate, excludeTags(nil, nil))
+}
+
+// lookupDigests returns the digests of the event, without the routes excluded, if exclude is set.
+func (t *routingTable) lookupDigests(eventName string, immediate []string, exclude func(route *RouteConfig) bool) []digestTarget {
+	skip := map[string]bool{}
+	for _, name := range immediate {
+		skip[name] = true
+	}
+
+	var targets []digestTarget
+	index := map[string]int{}
+	for i := range t.routes {
+		period := time.Duration(t.routes[i].Digest)
+		if period <= 0 || !t.routeMatches(i, eventName) || (exclude != nil && exclude(&t.routes[i])) {
+			continue
+		}
+		for _, name := range t.routes[i].Destinations {
+			if skip[name] {
+				continue
+			}
+			if j, ok := index[name]; ok {
+				if period < targets[j].period {
+					targets[j].period = period
+				}
+				continue
+			}
+			index[name] = len(targets)
+			targets = append(targets, digestTarget{destination: name, period: period})
+		}
+	}
+	return targets
+}
+
+// digest is the set of events accumulated for a destination until it is due.
+type digest struct {
+	tenant      string
+	destination string
+	title       string
+	due         time.Time
+	events      []*webhook.Event
+
+	// held is true for the events held during a quiet window, enqueued again if the digest fails.
+	held bool
+}
+
+// digests are the pending digests, by tenant, destination and period.
+type digests struct {
+	mu      sync.Mutex
+	digests map[string]*digest
+}
+
+func newDigests() *digests {
+	return &digests{digests: map[string]*digest{}}
+}
+
+// add accumulates the event in the digest of the destination. The digests are due
+// at the end of the period, aligned on the period (e.g. at the top of the hour).
+func (d *digests) add(tenant string, target digestTarget, event *webhook.Event, now time.Time) {
+	key := tenant + "/" + target.destination + "/" + target.period.String()
+	d.hold(key, &digest{tenant: tenant, destination: target.destination, title: "Digest", due: now.Truncate(target.period).Add(target.period)}, event)
+}
+
+// hold accumulates the event in the digest with the key, or in the empty one if none is pending.
+func (d *digests) hold(key string, empty *digest, event *webhook.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pending, ok := d.digests[key]
+	if !ok {
+		pending = empty
+		d.digests[key] = pending
+	}
+	pending.events = append(pending.events, event)
+}
+
+// all removes and returns all the pending digests.
+func (d *digests) all() []*digest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var all []*digest
+	for key, pending := range d.digests {
+		all = append(all, pending)
+		delete(d.digests, key)
+	}
+	return all
+}
+
+// due removes and returns the digests due at now.
+func (d *digests) due(now time.Time) []*digest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var due []*digest
+	for key, pending := range d.digests {
+		if !now.Before(pending.due) {
+			due = append(due, pending)
+			delete(d.digests, key)
+		}
+	}
+	return due
+}
+
+// addToDigests accumulates the event in the digests of the targets.
+func (s *Server) addToDigests(routing *routingTable, event *webhook.Event, targets []digestTarget) {
+	now := time.Now()
+	for _, target := range targets {
+		s.digests.add(routing.name, target, event, now)
+	}
+}
+
+// ProcessDigests sends the digests when they are due, checking at every interval,
+// until done is closed or the server is shut down.
+func (s *Server) ProcessDigests(interval time.Duration, done <-chan struct{}) {
+	s.workers.Add(1)
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.flushDigests(now)
+		}
+	}
+}
+
+// flushDigests sends the digests due at now.
+func (s *Server) flushDigests(now time.Time) {
+	s.sendDigests(s.digests.due(now))
+}
+
+// sendDigests sends the digests with the current configuration.
+func (s *Server) sendDigests(list []*digest) {
+	for _, pending := range list {
+		routing := s.currentRouting().tenantByName(pending.tenant)
+		if routing == nil || routing.services[pending.destination] == nil {
+			log.Warn().Str("tenant", pending.tenant).Str("destination", pending.destination).Int("events", len(pending.events)).Msg("Dropping the digest: unknown destination")
+			continue
+		}
+		service := routing.services[pending.destination]
+
+		stats := s.stats.Tenant(pending.tenant)
+		if err := routing.postMessage(context.Background(), service, pending.title+": "+batchSummary(service, pending.events, digestSummaryMessages)); err != nil {
+			log.Error().Err(err).Str("tenant", pending.tenant).Str("destination", pending.destination).Int("events", len(pending.events)).Msg("Error sending the digest")
+			for range pending.events {
+				stats.failed()
+			}
+			if pending.held {
+				s.requeue(pending.tenant, pending.destination, pending.events, err)
+			}
+			continue
+		}
+		for range pending.events {
+			stats.delivered()
+		}
+	}
+}
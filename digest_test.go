@@ -0,0 +1,80 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoutingTable_LookupDigests(t *testing.T) {
+	routing := &routingTable{routes: []RouteConfig{
+		{Events: []string{"zone_record.*"}, Destinations: []string{"ops", "audit"}, Digest: Duration(time.Hour)},
+		{Events: []string{"zone_record.*"}, Destinations: []string{"audit"}, Digest: Duration(24 * time.Hour)},
+		{Events: []string{"zone_record.delete"}, Destinations: []string{"ops"}},
+	}}
+
+	names := routing.Lookup("zone_record.delete")
+	if want, got := "ops", strings.Join(names, ","); want != got {
+		t.Errorf("Lookup expected %v, got %v", want, got)
+	}
+
+	targets := routing.LookupDigests("zone_record.delete", names)
+	if want, got := 1, len(targets); want != got {
+		t.Fatalf("LookupDigests expected %v targets, got %v", want, got)
+	}
+	if want, got := (digestTarget{destination: "audit", period: time.Hour}), targets[0]; want != got {
+		t.Errorf("LookupDigests expected %+v, got %+v", want, got)
+	}
+
+	targets = routing.LookupDigests("zone_record.create", routing.Lookup("zone_record.create"))
+	if want, got := 2, len(targets); want != got {
+		t.Errorf("LookupDigests expected %v targets, got %v", want, got)
+	}
+}
+
+func TestEvents_Digest(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [
+			{"events": ["domain.*"], "destinations": ["ops"], "digest": "1h"},
+			{"events": ["domain.delete"], "destinations": ["ops"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	service := &failingService{}
+	server.routing.services["ops"] = service
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "%s", "request_identifier": "%s"}`
+	for i, name := range []string{"domain.create", "domain.create", "domain.delete"} {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, name, fmt.Sprintf("a7c2e8f1-digest-0000-00000000000%d", i))))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want := http.StatusOK; want != response.Code {
+			t.Errorf("POST /events %v expected HTTP %v, got %v", name, want, response.Code)
+		}
+	}
+
+	if want, got := 1, service.sent; want != got {
+		t.Errorf("destination expected %v event sent immediately, got %v", want, got)
+	}
+
+	server.flushDigests(time.Now())
+	if want, got := 0, len(service.messages); want != got {
+		t.Errorf("digest expected to be pending, got %v messages", got)
+	}
+
+	server.flushDigests(time.Now().Add(time.Hour))
+	if want, got := 1, len(service.messages); want != got {
+		t.Fatalf("digest expected %v message, got %v", want, got)
+	}
+	if !strings.HasPrefix(service.messages[0], "Digest: 2 events: domain.create (2)") {
+		t.Errorf("digest unexpected: %v", service.messages[0])
+	}
+}
@@ -0,0 +1,102 @@
+package strillone
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// AdminListDeadLetters returns the deliveries that exhausted their attempts.
+func (s *Server) AdminListDeadLetters(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !s.requireQueue(w) {
+		return
+	}
+	jobs, err := s.queue.DeadLetters()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// AdminRedeliverDeadLetters moves all the dead letters back to the queue.
+func (s *Server) AdminRedeliverDeadLetters(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !s.requireQueue(w) {
+		return
+	}
+	jobs, err := s.queue.DeadLetters()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	now := time.Now()
+	count := 0
+	for _, job := range jobs {
+		if err := s.queue.Redeliver(job.ID, now); err != nil && err != ErrJobNotFound {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		count++
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"redelivered": count})
+}
+
+// AdminRedeliverDeadLetter moves a dead letter back to the queue.
+func (s *Server) AdminRedeliverDeadLetter(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if !s.requireQueue(w) {
+		return
+	}
+	id, ok := deadLetterID(w, params)
+	if !ok {
+		return
+	}
+	if err := s.queue.Redeliver(id, time.Now()); err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"redelivered": 1})
+}
+
+// AdminDeleteDeadLetter discards a dead letter.
+func (s *Server) AdminDeleteDeadLetter(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if !s.requireQueue(w) {
+		return
+	}
+	id, ok := deadLetterID(w, params)
+	if !ok {
+		return
+	}
+	if err := s.queue.DeleteDeadLetter(id); err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) requireQueue(w http.ResponseWriter) bool {
+	if s.queue == nil {
+		writeJSONError(w, http.StatusNotFound, "delivery queue not enabled")
+		return false
+	}
+	return true
+}
+
+func deadLetterID(w http.ResponseWriter, params httprouter.Params) (uint64, bool) {
+	id, err := strconv.ParseUint(params.ByName("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "dead letter not found")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	if err == ErrJobNotFound {
+		writeJSONError(w, http.StatusNotFound, "dead letter not found")
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,70 @@
+package strillone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdmin_DeadLetters(t *testing.T) {
+	queue, cleanup := openTestQueue(t)
+	defer cleanup()
+
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}],
+		"admin": {"token": "secret"}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetQueue(queue, 1)
+	service := &failingService{errs: []error{errors.New("downstream outage")}}
+	server.routing.services["ops"] = service
+
+	payload := []byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "c3e9a1f4-dlq-0000-000000000001"}`)
+	now := time.Now()
+	queue.Enqueue(&Job{Destination: "ops", Payload: payload, CreatedAt: now, NextAttempt: now})
+	server.processDueJobs(now)
+
+	response := adminRequest(server, "GET", "/admin/dlq", "")
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("GET /admin/dlq expected HTTP %v, got %v", want, response.Code)
+	}
+	var jobs []*Job
+	json.Unmarshal(response.Body.Bytes(), &jobs)
+	if len(jobs) != 1 || jobs[0].LastError != "downstream outage" || jobs[0].FailedAt == nil {
+		t.Fatalf("GET /admin/dlq expected the failed job, got %v", response.Body.String())
+	}
+
+	response = adminRequest(server, "POST", "/admin/dlq/12345", "")
+	if want := http.StatusNotFound; want != response.Code {
+		t.Errorf("POST /admin/dlq/12345 expected HTTP %v, got %v", want, response.Code)
+	}
+
+	response = adminRequest(server, "POST", fmt.Sprintf("/admin/dlq/%d", jobs[0].ID), "")
+	if want := http.StatusOK; want != response.Code {
+		t.Errorf("POST /admin/dlq/:id expected HTTP %v, got %v", want, response.Code)
+	}
+
+	server.processDueJobs(time.Now())
+	if want, got := 1, service.sent; want != got {
+		t.Errorf("redelivered job expected %v sent, got %v", want, got)
+	}
+	if jobs, _ := queue.DeadLetters(); len(jobs) != 0 {
+		t.Errorf("dead letters expected to be empty, got %v", len(jobs))
+	}
+}
+
+func TestAdmin_DeadLettersWithoutQueue(t *testing.T) {
+	server, _ := newAdminTestServer()
+
+	response := adminRequest(server, "GET", "/admin/dlq", "")
+	if want := http.StatusNotFound; want != response.Code {
+		t.Errorf("GET /admin/dlq expected HTTP %v, got %v", want, response.Code)
+	}
+}
@@ -0,0 +1,457 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultDriftSchedule runs the drift checks every 30 minutes.
+	defaultDriftSchedule = "*/30 * * * *"
+
+	// defaultDriftTimeout is the timeout of each DNS query.
+	defaultDriftTimeout = 5 * time.Second
+)
+
+// defaultDriftTypes are the types of the records compared with the live DNS.
+var defaultDriftTypes = []string{"A", "AAAA", "CNAME", "MX", "TXT"}
+
+// DriftConfig configures the checks comparing the zones of the DNSimple API with the answers
+// of their name servers, to detect stale secondaries and records changed outside of DNSimple.
+type DriftConfig struct {
+	// Destinations are the names of the destinations receiving the alerts.
+	Destinations []string `json:"destinations"`
+
+	// Accounts are the IDs of the accounts whose zones are checked.
+	// Defaults to the accounts accessible with the API token.
+	Accounts []int64 `json:"accounts,omitempty"`
+
+	// Zones are the names of the zones checked. Defaults to all the zones of the accounts.
+	Zones []string `json:"zones,omitempty"`
+
+	// NameServers are the name servers queried, as host or host:port.
+	// Defaults to the name servers of the NS records of each zone.
+	NameServers []string `json:"name_servers,omitempty"`
+
+	// Types are the types of the records compared. Defaults to A, AAAA, CNAME, MX and TXT.
+	Types []string `json:"types,omitempty"`
+
+	// Timeout is the timeout of each DNS query. Defaults to 5s.
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// Schedule is a cron expression for the checks, in the Timezone. Defaults to every 30 minutes.
+	Schedule string `json:"schedule,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (c *DriftConfig) validate(destinations map[string]bool, api *APIConfig) error {
+	if c == nil {
+		return nil
+	}
+	if api == nil {
+		return fmt.Errorf("drift: the api is required")
+	}
+	if len(c.Destinations) == 0 {
+		return fmt.Errorf("drift: missing destinations")
+	}
+	for _, name := range c.Destinations {
+		if !destinations[name] {
+			return fmt.Errorf("drift: unknown destination %q", name)
+		}
+	}
+	for _, recordType := range c.Types {
+		if _, ok := dns.StringToType[strings.ToUpper(recordType)]; !ok {
+			return fmt.Errorf("drift: unknown record type %q", recordType)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("drift: timeout must be positive")
+	}
+	if _, err := parseCron(c.schedule()); err != nil {
+		return fmt.Errorf("drift: %v", err)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("drift: %v", err)
+	}
+	return nil
+}
+
+func (c *DriftConfig) schedule() string {
+	if c.Schedule == "" {
+		return defaultDriftSchedule
+	}
+	return c.Schedule
+}
+
+func (c *DriftConfig) types() []string {
+	if len(c.Types) == 0 {
+		return defaultDriftTypes
+	}
+	return c.Types
+}
+
+func (c *DriftConfig) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return defaultDriftTimeout
+	}
+	return time.Duration(c.Timeout)
+}
+
+// ProcessDriftChecks compares the zones with the live DNS when the checks are scheduled,
+// checking the schedule at every interval, until done is closed or the server is shut down.
+func (s *Server) ProcessDriftChecks(interval time.Duration, done <-chan struct{}) {
+	s.processSchedule(interval, done, s.driftChecksDue, s.checkDrift)
+}
+
+// driftChecksDue returns true if the drift checks are scheduled at the minute.
+func (s *Server) driftChecksDue(minute time.Time) bool {
+	config := s.currentRouting().config.Drift
+	return config != nil && scheduleDue(config.schedule(), config.Timezone, minute)
+}
+
+// checkDrift compares the zones of the accounts with the answers of their name servers,
+// and alerts about the differences not found by the previous check.
+func (s *Server) checkDrift(ctx context.Context, _ time.Time) {
+	routing := s.currentRouting()
+	config := routing.config.Drift
+	if config == nil || routing.api == nil {
+		return
+	}
+
+	accountIDs, err := routing.scheduledAccounts(ctx, config.Accounts)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching the accounts of the drift checks")
+		return
+	}
+
+	selected := map[string]bool{}
+	for _, zone := range config.Zones {
+		selected[strings.ToLower(strings.TrimSuffix(zone, "."))] = true
+	}
+
+	checker := &driftChecker{config: config, client: &dns.Client{Timeout: config.timeout()}}
+	for _, accountID := range accountIDs {
+		zones, err := listZones(ctx, routing.api, accountID)
+		if err != nil {
+			log.Error().Err(err).Int64("account_id", accountID).Msg("Error listing the zones of the drift checks")
+			continue
+		}
+		for _, zone := range zones {
+			if len(selected) > 0 && !selected[zone.Name] {
+				continue
+			}
+			records, err := listZoneRecords(ctx, routing.api, accountID, zone.Name)
+			if err != nil {
+				log.Error().Err(err).Int64("account_id", accountID).Str("zone", zone.Name).Msg("Error listing the records of the drift checks")
+				continue
+			}
+			drifts := checker.check(ctx, zone.Name, records)
+			for _, drift := range s.drifts.update(accountID, zone.Name, drifts) {
+				drift := drift
+				routing.postScheduled(ctx, config.Destinations, func(s Formatter) string {
+					return driftMessage(s, accountID, zone.Name, drift)
+				})
+			}
+		}
+	}
+}
+
+// driftKind is the kind of difference between a zone and a name server.
+type driftKind int
+
+const (
+	// driftUnreachable is a name server failing to answer.
+	driftUnreachable driftKind = iota
+	// driftStale is a name server serving an older serial than the other name servers.
+	driftStale
+	// driftRecord is a name server answering other records than the zone.
+	driftRecord
+)
+
+// zoneDrift is a difference between a zone and the answers of one of its name servers.
+type zoneDrift struct {
+	kind       driftKind
+	nameServer string
+
+	// record is the name and type of the record set, e.g. "www.example.com A", for driftRecord.
+	record string
+
+	// got is the answer of the name server, want the content of the zone.
+	// For driftStale, they are the serial of the name server and the latest serial.
+	// For driftUnreachable, got is the error.
+	got, want string
+}
+
+// key identifies the drift across the checks. The errors are left out, since they vary between the queries.
+func (d zoneDrift) key() string {
+	if d.kind == driftUnreachable {
+		return strings.Join([]string{strconv.Itoa(int(d.kind)), d.nameServer}, "|")
+	}
+	return strings.Join([]string{strconv.Itoa(int(d.kind)), d.nameServer, d.record, d.got, d.want}, "|")
+}
+
+// driftChecker queries the name servers of the zones.
+type driftChecker struct {
+	config *DriftConfig
+	client *dns.Client
+}
+
+// check returns the differences between the records of the zone and the answers of its name servers.
+func (c *driftChecker) check(ctx context.Context, zone string, records []dnsimple.ZoneRecord) []zoneDrift {
+	nameServers := c.config.NameServers
+	if len(nameServers) == 0 {
+		for _, record := range records {
+			if record.Type == "NS" && record.Name == "" {
+				nameServers = append(nameServers, strings.TrimSuffix(record.Content, "."))
+			}
+		}
+	}
+	expected := expectedRecordSets(zone, records, c.config.types())
+
+	var drifts []zoneDrift
+	serials := map[string]uint32{}
+	var latest uint32
+	for _, nameServer := range nameServers {
+		answers, err := c.query(ctx, nameServer, zone, dns.TypeSOA)
+		if err != nil {
+			drifts = append(drifts, zoneDrift{kind: driftUnreachable, nameServer: nameServer, got: err.Error()})
+			continue
+		}
+		for _, answer := range answers {
+			if soa, ok := answer.(*dns.SOA); ok {
+				serials[nameServer] = soa.Serial
+				if soa.Serial > latest {
+					latest = soa.Serial
+				}
+			}
+		}
+
+		for _, set := range expected {
+			answers, err := c.query(ctx, nameServer, set.name, set.rrtype)
+			if err != nil {
+				drifts = append(drifts, zoneDrift{kind: driftUnreachable, nameServer: nameServer, got: err.Error()})
+				break
+			}
+			got := answerValues(answers, set.rrtype)
+			if strings.Join(got, " ") != strings.Join(set.values, " ") {
+				drifts = append(drifts, zoneDrift{
+					kind:       driftRecord,
+					nameServer: nameServer,
+					record:     strings.TrimSuffix(set.name, ".") + " " + dns.TypeToString[set.rrtype],
+					got:        strings.Join(got, ", "),
+					want:       strings.Join(set.values, ", "),
+				})
+			}
+		}
+	}
+	for _, nameServer := range nameServers {
+		if serial, ok := serials[nameServer]; ok && serial < latest {
+			drifts = append(drifts, zoneDrift{kind: driftStale, nameServer: nameServer, got: fmt.Sprint(serial), want: fmt.Sprint(latest)})
+		}
+	}
+	return drifts
+}
+
+// query sends a non-recursive query to the name server, over TCP when the UDP answer is truncated,
+// and returns the answers of the type.
+func (c *driftChecker) query(ctx context.Context, nameServer, name string, rrtype uint16) ([]dns.RR, error) {
+	address := nameServer
+	if _, _, err := net.SplitHostPort(nameServer); err != nil {
+		address = net.JoinHostPort(nameServer, "53")
+	}
+	message := new(dns.Msg)
+	message.SetQuestion(dns.Fqdn(name), rrtype)
+	message.RecursionDesired = false
+
+	response, _, err := c.client.ExchangeContext(ctx, message, address)
+	if err == nil && response.Truncated {
+		tcp := &dns.Client{Net: "tcp", Timeout: c.client.Timeout}
+		response, _, err = tcp.ExchangeContext(ctx, message, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s answer for %s", dns.RcodeToString[response.Rcode], name)
+	}
+	var answers []dns.RR
+	for _, answer := range response.Answer {
+		if answer.Header().Rrtype == rrtype {
+			answers = append(answers, answer)
+		}
+	}
+	return answers, nil
+}
+
+// recordSet is the content of the records of a name and a type.
+type recordSet struct {
+	name   string
+	rrtype uint16
+	values []string
+}
+
+// expectedRecordSets returns the record sets of the zone with the types, sorted by name and type.
+// The regional records are skipped, since their answers depend on the name server.
+func expectedRecordSets(zone string, records []dnsimple.ZoneRecord, types []string) []*recordSet {
+	compared := map[uint16]bool{}
+	for _, recordType := range types {
+		compared[dns.StringToType[strings.ToUpper(recordType)]] = true
+	}
+
+	sets := map[string]*recordSet{}
+	for _, record := range records {
+		rrtype := dns.StringToType[record.Type]
+		if !compared[rrtype] || !globalRecord(record) {
+			continue
+		}
+		name := dns.Fqdn(zone)
+		if record.Name != "" {
+			name = dns.Fqdn(record.Name + "." + zone)
+		}
+		name = strings.ToLower(name)
+		key := name + " " + record.Type
+		if sets[key] == nil {
+			sets[key] = &recordSet{name: name, rrtype: rrtype}
+		}
+		sets[key].values = append(sets[key].values, recordValue(record))
+	}
+
+	result := make([]*recordSet, 0, len(sets))
+	for _, set := range sets {
+		sort.Strings(set.values)
+		result = append(result, set)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].name != result[j].name {
+			return result[i].name < result[j].name
+		}
+		return result[i].rrtype < result[j].rrtype
+	})
+	return result
+}
+
+// globalRecord returns true if the record is served by all the name servers.
+func globalRecord(record dnsimple.ZoneRecord) bool {
+	if len(record.Regions) == 0 {
+		return true
+	}
+	for _, region := range record.Regions {
+		if region == "global" {
+			return true
+		}
+	}
+	return false
+}
+
+// recordValue returns the content of the record of the API, normalized like the answers.
+func recordValue(record dnsimple.ZoneRecord) string {
+	switch record.Type {
+	case "A", "AAAA":
+		if ip := net.ParseIP(record.Content); ip != nil {
+			return ip.String()
+		}
+	case "CNAME", "NS":
+		return strings.ToLower(dns.Fqdn(record.Content))
+	case "MX":
+		return fmt.Sprintf("%d %s", record.Priority, strings.ToLower(dns.Fqdn(record.Content)))
+	case "TXT":
+		// The quoted contents may have several strings, e.g. "v=DKIM1; " "p=MIGf...".
+		if strings.HasPrefix(record.Content, `"`) {
+			if rr, err := dns.NewRR(". TXT " + record.Content); err == nil {
+				return strings.Join(rr.(*dns.TXT).Txt, "")
+			}
+		}
+	}
+	return record.Content
+}
+
+// answerValues returns the normalized and sorted values of the answers.
+func answerValues(answers []dns.RR, rrtype uint16) []string {
+	values := []string{}
+	for _, answer := range answers {
+		switch rr := answer.(type) {
+		case *dns.A:
+			values = append(values, rr.A.String())
+		case *dns.AAAA:
+			values = append(values, rr.AAAA.String())
+		case *dns.CNAME:
+			values = append(values, strings.ToLower(rr.Target))
+		case *dns.NS:
+			values = append(values, strings.ToLower(rr.Ns))
+		case *dns.MX:
+			values = append(values, fmt.Sprintf("%d %s", rr.Preference, strings.ToLower(rr.Mx)))
+		case *dns.TXT:
+			values = append(values, strings.Join(rr.Txt, ""))
+		default:
+			values = append(values, strings.TrimPrefix(answer.String(), answer.Header().String()))
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// driftMessage returns the alert of the drift of the zone.
+func driftMessage(s Formatter, accountID int64, zone string, drift zoneDrift) string {
+	account := scheduledAccountLink(s, accountID)
+	zoneLink := s.FormatLink(zone, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s/records", accountID, zone))
+	nameServer := escape(s, drift.nameServer)
+	var text string
+	switch drift.kind {
+	case driftUnreachable:
+		text = tprintf(s, "The name server %s failed to answer for the zone %s: %s", nameServer, zoneLink, escape(s, drift.got))
+	case driftStale:
+		text = tprintf(s, "The name server %s serves a stale version of the zone %s, with the serial %s instead of %s", nameServer, zoneLink, drift.got, drift.want)
+	default:
+		got := drift.got
+		if got == "" {
+			got = translate(s, "no record")
+		}
+		want := drift.want
+		if want == "" {
+			want = translate(s, "no record")
+		}
+		text = tprintf(s, "The name server %s answers %s for %s in the zone %s, instead of %s", nameServer, escape(s, got), escape(s, drift.record), zoneLink, escape(s, want))
+	}
+	return fmt.Sprintf("[%s] %s", account, text)
+}
+
+// driftAlerts remembers the drifts of each zone found by the last check,
+// so that a drift is alerted when it appears and not again while it persists.
+type driftAlerts struct {
+	mu    sync.Mutex
+	zones map[string]map[string]bool
+}
+
+func newDriftAlerts() *driftAlerts {
+	return &driftAlerts{zones: map[string]map[string]bool{}}
+}
+
+// update records the drifts of the zone and returns the ones not found by the last check.
+func (a *driftAlerts) update(accountID int64, zone string, drifts []zoneDrift) []zoneDrift {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	zoneKey := fmt.Sprintf("%d/%s", accountID, zone)
+	previous := a.zones[zoneKey]
+	current := make(map[string]bool, len(drifts))
+	var appeared []zoneDrift
+	for _, drift := range drifts {
+		key := drift.key()
+		if !previous[key] && !current[key] {
+			appeared = append(appeared, drift)
+		}
+		current[key] = true
+	}
+	a.zones[zoneKey] = current
+	return appeared
+}
@@ -0,0 +1,158 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/miekg/dns"
+)
+
+// startTestNameServer serves the records on a local UDP port, and returns its address.
+func startTestNameServer(t *testing.T, records ...string) (string, func()) {
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+		for _, rr := range rrs {
+			if rr.Header().Name == r.Question[0].Name && rr.Header().Rrtype == r.Question[0].Qtype {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	return conn.LocalAddr().String(), func() { _ = server.Shutdown() }
+}
+
+func TestServer_CheckDrift(t *testing.T) {
+	primary, stopPrimary := startTestNameServer(t,
+		"example.com. 3600 IN SOA ns1.dnsimple.com. admin.dnsimple.com. 1700000002 86400 7200 604800 300",
+		"example.com. 3600 IN A 192.0.2.1",
+		"www.example.com. 3600 IN CNAME example.com.",
+		"example.com. 3600 IN MX 10 mx.example.com.",
+		`example.com. 3600 IN TXT "v=spf1 include:_spf.example.com ~all"`,
+	)
+	defer stopPrimary()
+	secondary, stopSecondary := startTestNameServer(t,
+		"example.com. 3600 IN SOA ns1.dnsimple.com. admin.dnsimple.com. 1700000001 86400 7200 604800 300",
+		"example.com. 3600 IN A 198.51.100.1",
+		"www.example.com. 3600 IN CNAME example.com.",
+		"example.com. 3600 IN MX 10 mx.example.com.",
+		`example.com. 3600 IN TXT "v=spf1 include:_spf.example.com ~all"`,
+	)
+	defer stopSecondary()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/1010/zones":
+			fmt.Fprint(w, `{"data": [{"id": 1, "name": "example.com"}, {"id": 2, "name": "example.org"}], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 2, "total_pages": 1}}`)
+		case "/v2/1010/zones/example.com/records":
+			fmt.Fprint(w, `{"data": [
+				{"id": 1, "zone_id": "example.com", "name": "", "type": "NS", "content": "ns1.dnsimple.com", "system_record": true},
+				{"id": 2, "zone_id": "example.com", "name": "", "type": "A", "content": "192.0.2.1"},
+				{"id": 3, "zone_id": "example.com", "name": "www", "type": "CNAME", "content": "example.com"},
+				{"id": 4, "zone_id": "example.com", "name": "", "type": "MX", "content": "mx.example.com", "priority": 10},
+				{"id": 5, "zone_id": "example.com", "name": "", "type": "TXT", "content": "v=spf1 include:_spf.example.com ~all"},
+				{"id": 6, "zone_id": "example.com", "name": "eu", "type": "A", "content": "192.0.2.2", "regions": ["ams"]}
+			], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 6, "total_pages": 1}}`)
+		default:
+			t.Errorf("unexpected API request %v", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"api": {"token": "api-token", "url": %q},
+		"drift": {"destinations": ["ops"], "accounts": [1010], "zones": ["example.com"], "name_servers": [%q, %q], "timeout": "1s"}
+	}`, api.URL, primary, secondary)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	service := &failingService{}
+	server.routing.services["ops"] = service
+
+	server.checkDrift(context.Background(), time.Now())
+
+	want := []string{
+		fmt.Sprintf("[<https://dnsimple.com/a/1010/account|1010>] The name server %s answers 198.51.100.1 for example.com A in the zone <https://dnsimple.com/a/1010/domains/example.com/records|example.com>, instead of 192.0.2.1", secondary),
+		fmt.Sprintf("[<https://dnsimple.com/a/1010/account|1010>] The name server %s serves a stale version of the zone <https://dnsimple.com/a/1010/domains/example.com/records|example.com>, with the serial 1700000001 instead of 1700000002", secondary),
+	}
+	if got := service.messages; strings.Join(want, "\n") != strings.Join(got, "\n") {
+		t.Errorf("checkDrift expected\n%v\ngot\n%v", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// The drifts are alerted once while they persist.
+	server.checkDrift(context.Background(), time.Now())
+	if want, got := 2, len(service.messages); want != got {
+		t.Errorf("checkDrift expected %d messages, got %d", want, got)
+	}
+}
+
+func Test_recordValue(t *testing.T) {
+	tests := []struct {
+		typ, content string
+		priority     int
+		want         string
+	}{
+		{"AAAA", "2001:DB8::1", 0, "2001:db8::1"},
+		{"CNAME", "Example.com", 0, "example.com."},
+		{"MX", "mx.example.com", 10, "10 mx.example.com."},
+		{"TXT", "v=spf1 ~all", 0, "v=spf1 ~all"},
+		{"TXT", `"v=DKIM1; " "p=MIGf"`, 0, "v=DKIM1; p=MIGf"},
+	}
+	for _, test := range tests {
+		record := dnsimple.ZoneRecord{Type: test.typ, Content: test.content, Priority: test.priority}
+		if got := recordValue(record); test.want != got {
+			t.Errorf("recordValue(%v %v) expected %q, got %q", test.typ, test.content, test.want, got)
+		}
+	}
+}
+
+func TestDriftConfig_validate(t *testing.T) {
+	destinations := map[string]bool{"ops": true}
+	api := &APIConfig{Token: "api-token"}
+	tests := []struct {
+		config *DriftConfig
+		api    *APIConfig
+		valid  bool
+	}{
+		{nil, nil, true},
+		{&DriftConfig{Destinations: []string{"ops"}, Types: []string{"a", "CAA"}, Schedule: "0 * * * *"}, api, true},
+		{&DriftConfig{Destinations: []string{"ops"}}, nil, false},
+		{&DriftConfig{}, api, false},
+		{&DriftConfig{Destinations: []string{"pager"}}, api, false},
+		{&DriftConfig{Destinations: []string{"ops"}, Types: []string{"SPF1"}}, api, false},
+		{&DriftConfig{Destinations: []string{"ops"}, Timeout: -1}, api, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate(destinations, test.api)
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package strillone
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// dryRunParameter is the query parameter of the webhooks whose messages are returned instead of delivered,
+// such as /events?dryrun=1.
+const dryRunParameter = "dryrun"
+
+// DryRunResponse represents the response to a webhook received in dry-run mode.
+type DryRunResponse struct {
+	Event     string `json:"event"`
+	RequestID string `json:"request_id"`
+
+	// Messages are the formatted messages, by destination.
+	Messages map[string]string `json:"messages"`
+
+	// Errors are the formatting errors, by destination.
+	Errors map[string]string `json:"errors,omitempty"`
+
+	// Digests are the destinations whose digests would include the event.
+	Digests []string `json:"digests,omitempty"`
+}
+
+// EnableDryRun logs the messages instead of delivering them, and returns the messages of the webhooks
+// in the responses. It applies to the configurations loaded afterwards.
+func (s *Server) EnableDryRun() {
+	s.dryRun = true
+}
+
+// isDryRun returns true if the messages of the webhook are returned instead of delivered.
+func (s *Server) isDryRun(r *http.Request) bool {
+	if s.dryRun {
+		return true
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get(dryRunParameter))
+	return dryRun
+}
+
+// preview writes the messages of the event in the response instead of delivering them.
+func (s *Server) preview(p *Webhook) {
+	response := &DryRunResponse{
+		Event:     p.Event.Name,
+		RequestID: p.Event.RequestID,
+		Messages:  p.Messages,
+	}
+	for _, digest := range p.digests {
+		response.Digests = append(response.Digests, digest.destination)
+	}
+	for _, name := range p.Destinations {
+		if err, ok := p.Errors[name]; ok {
+			if response.Errors == nil {
+				response.Errors = map[string]string{}
+			}
+			response.Errors[name] = err.Error()
+			withEvent(log.Warn(), p.Event).Err(err).Str("tenant", p.Tenant).Str("destination", name).Msg("Dry run: error formatting the event")
+			continue
+		}
+		withEvent(log.Info(), p.Event).Str("tenant", p.Tenant).Str("destination", name).Str("text", p.Messages[name]).Msg("Dry run: event not delivered")
+	}
+
+	w := p.Response
+	w.Header().Set(headerProcessingStatus, "dry-run")
+	writeJSON(w, http.StatusOK, response)
+}
+
+// dryRunService logs the messages instead of delivering them, in the global dry-run mode,
+// so that the reminders, the digests and the notifications aren't delivered either.
+type dryRunService struct {
+	MessagingService
+	name string
+}
+
+// PostEvent implements MessagingService
+func (d *dryRunService) PostEvent(_ context.Context, event *webhook.Event) (string, error) {
+	text, err := formatMessage(d.MessagingService, event)
+	if err != nil {
+		return "", err
+	}
+	withEvent(log.Info(), event).Str("destination", d.name).Str("text", text).Msg("Dry run: event not delivered")
+	return text, nil
+}
+
+// PostMessage implements MessagingService
+func (d *dryRunService) PostMessage(_ context.Context, text string) error {
+	log.Info().Str("destination", d.name).Str("text", text).Msg("Dry run: message not delivered")
+	return nil
+}
+
+// withDryRun replaces the services of the routing table, of its tenants and of its environments
+// with services logging the messages.
+func (t *routingTable) withDryRun() {
+	for name, service := range t.services {
+		t.services[name] = &dryRunService{MessagingService: service, name: name}
+	}
+	for _, tenant := range t.tenants {
+		tenant.withDryRun()
+	}
+	for _, environment := range t.environments {
+		environment.withDryRun()
+	}
+}
@@ -0,0 +1,125 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEvents_DryRun(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops := &failingService{}
+	server.routing.services["ops"] = ops
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "d7a3e5c1-dryrun-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events?dryrun=1", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("POST /events?dryrun=1 expected HTTP %v, got %v", want, response.Code)
+	}
+	if want, got := "dry-run", response.Header().Get(headerProcessingStatus); want != got {
+		t.Errorf("POST /events?dryrun=1 expected %v %q, got %q", headerProcessingStatus, want, got)
+	}
+	var result DryRunResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	want := "[<https://dnsimple.com/a/1010/account|User>] example@example.com created the domain <https://dnsimple.com/a/1010/domains/example.com|example.com>"
+	if got := result.Messages["ops"]; want != got {
+		t.Errorf("POST /events?dryrun=1 expected message %q, got %q", want, got)
+	}
+	if want, got := 0, ops.sent; want != got {
+		t.Errorf("POST /events?dryrun=1 expected %v deliveries, got %v", want, got)
+	}
+
+	// The webhook is delivered when received again without the dry-run mode.
+	request, _ = http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := 1, ops.sent; want != got {
+		t.Errorf("POST /events expected %v deliveries, got %v", want, got)
+	}
+}
+
+func TestEvents_DryRunLeavesStates(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.routing.services["ops"] = &failingService{}
+
+	payloads := []string{
+		`{"data": {"zone_record": {"id": 7, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4", "ttl": 3600, "updated_at": "2021-03-01T11:00:00Z"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "zone_record.update", "request_identifier": "d7a3e5c1-dryrun-0000-000000000003"}`,
+		`{"data": {"contact": {"id": 42, "email": "jane@example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "contact.update", "request_identifier": "d7a3e5c1-dryrun-0000-000000000004"}`,
+	}
+	for _, payload := range payloads {
+		request, _ := http.NewRequest("POST", "/events?dryrun=1", strings.NewReader(payload))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := "dry-run", response.Header().Get(headerProcessingStatus); want != got {
+			t.Errorf("POST /events?dryrun=1 expected %v %q, got %q", headerProcessingStatus, want, got)
+		}
+	}
+
+	if records := server.zoneRecords.records; len(records) != 0 {
+		t.Errorf("POST /events?dryrun=1 expected no zone record remembered, got %v", records)
+	}
+	if emails := server.contactEmails.emails; len(emails) != 0 {
+		t.Errorf("POST /events?dryrun=1 expected no contact email remembered, got %v", emails)
+	}
+}
+
+func TestServer_EnableDryRun(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(nil)
+	server.EnableDryRun()
+	if err := server.Reload(config); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	service, ok := server.routing.services["ops"].(*dryRunService)
+	if !ok {
+		t.Fatalf("EnableDryRun expected a dry-run service, got %T", server.routing.services["ops"])
+	}
+	ops := &failingService{}
+	service.MessagingService = ops
+
+	if err := server.routing.postMessage(context.Background(), service, "hello"); err != nil {
+		t.Errorf("postMessage returned error: %v", err)
+	}
+	if want, got := 0, len(ops.messages); want != got {
+		t.Errorf("postMessage expected %v messages, got %v", want, got)
+	}
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "d7a3e5c1-dryrun-0000-000000000002"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := "dry-run", response.Header().Get(headerProcessingStatus); want != got {
+		t.Errorf("POST /events expected %v %q, got %q", headerProcessingStatus, want, got)
+	}
+	if want, got := 0, ops.sent; want != got {
+		t.Errorf("POST /events expected %v deliveries, got %v", want, got)
+	}
+}
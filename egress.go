@@ -0,0 +1,110 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EgressConfig controls the outbound connections to the destinations, for the deployments
+// in locked-down networks.
+type EgressConfig struct {
+	// Proxy is the proxy of the destinations without their own proxy, in place of the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables: an HTTP proxy such as http://proxy.internal:3128,
+	// or a SOCKS5 proxy such as socks5://proxy.internal:1080.
+	Proxy string `json:"proxy,omitempty"`
+
+	// AllowedHosts are the host names the destinations can connect to, such as hooks.slack.com,
+	// or *.slack.com for the subdomains. When empty, every host is allowed.
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+}
+
+func (c *EgressConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Proxy != "" && c.Proxy != directProxy {
+		if _, err := parseProxyURL(c.Proxy); err != nil {
+			return fmt.Errorf("egress: %v", err)
+		}
+	}
+	for _, host := range c.AllowedHosts {
+		if host == "" || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			return fmt.Errorf("egress: invalid allowed host %q", host)
+		}
+	}
+	return nil
+}
+
+// apply returns the HTTP settings of a destination with the proxy and the allowed hosts of the egress.
+func (c *EgressConfig) apply(config *DestinationHTTPConfig) *DestinationHTTPConfig {
+	if c == nil {
+		return config
+	}
+	var applied DestinationHTTPConfig
+	if config != nil {
+		applied = *config
+	}
+	if applied.Proxy == "" {
+		applied.Proxy = c.Proxy
+	}
+	applied.allowedHosts = c.AllowedHosts
+	return &applied
+}
+
+// allowsHost returns true if the egress allows the connections to the host.
+func (c *EgressConfig) allowsHost(host string) bool {
+	return c == nil || allowedHost(c.AllowedHosts, host)
+}
+
+// allowedHost returns true if the host matches one of the allowed hosts, or if there are none.
+func allowedHost(allowed []string, host string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// egressTransport rejects the requests to the hosts not allowed, before connecting,
+// so that the destinations can't reach other hosts, even through the proxy.
+type egressTransport struct {
+	allowed   []string
+	transport http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *egressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !allowedHost(t.allowed, req.URL.Hostname()) {
+		return nil, fmt.Errorf("egress: host %q not allowed", req.URL.Hostname())
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// checkDestinationHost returns an error if the egress doesn't allow the host of the destination URL.
+func (c *EgressConfig) checkDestinationHost(destinationURL string) error {
+	if destinationURL == "" {
+		return nil
+	}
+	u, err := url.Parse(destinationURL)
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	if !c.allowsHost(u.Hostname()) {
+		return fmt.Errorf("host %q not allowed by the egress", u.Hostname())
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package strillone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAllowedHost(t *testing.T) {
+	allowed := []string{"hooks.slack.com", "*.example.com"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"hooks.slack.com", true},
+		{"HOOKS.slack.com.", true},
+		{"slack.com", false},
+		{"chat.example.com", true},
+		{"a.b.example.com", true},
+		{"example.com", false},
+		{"evil-example.com", false},
+	}
+	for _, tt := range tests {
+		if got := allowedHost(allowed, tt.host); tt.want != got {
+			t.Errorf("allowedHost(%q) expected %v, got %v", tt.host, tt.want, got)
+		}
+	}
+	if !allowedHost(nil, "anything.test") {
+		t.Errorf("allowedHost without allowed hosts expected true")
+	}
+}
+
+func TestNewHTTPClient_Egress(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	egress := &EgressConfig{AllowedHosts: []string{"127.0.0.1"}}
+	client, err := newHTTPClient(nil, egress.apply(nil))
+	if err != nil {
+		t.Fatalf("newHTTPClient returned error: %v", err)
+	}
+	if err := postJSON(context.Background(), client, target.URL, map[string]string{"text": "hello"}); err != nil {
+		t.Errorf("postJSON to an allowed host returned error: %v", err)
+	}
+
+	egress = &EgressConfig{AllowedHosts: []string{"hooks.slack.com"}}
+	client, err = newHTTPClient(nil, egress.apply(&DestinationHTTPConfig{Proxy: "socks5://127.0.0.1:1080"}))
+	if err != nil {
+		t.Fatalf("newHTTPClient returned error: %v", err)
+	}
+	err = postJSON(context.Background(), client, target.URL, map[string]string{"text": "hello"})
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("postJSON to a host not allowed expected an egress error, got %v", err)
+	}
+}
+
+func TestServer_ReloadEgress(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}],
+		"egress": {"proxy": "socks5://proxy.internal:1080", "allowed_hosts": ["*.slack.com"]}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(nil)
+	if err := server.Reload(config); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+
+	config.Egress.AllowedHosts = []string{"chat.example.com"}
+	if err := server.Reload(config); err == nil {
+		t.Errorf("Reload expected an error for a destination host not allowed")
+	}
+
+	for _, egress := range []string{`{"proxy": "ftp://proxy.internal"}`, `{"allowed_hosts": ["hooks.*.com"]}`} {
+		if _, err := ParseConfig([]byte(`{"egress": ` + egress + `}`)); err == nil {
+			t.Errorf("ParseConfig with egress %v expected error", egress)
+		}
+	}
+}
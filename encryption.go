@@ -0,0 +1,519 @@
+package strillone
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EncryptionScheme is the scheme of the secret references encrypted with a Cipher, such as enc:bXlzZWNyZXQ...
+const EncryptionScheme = "enc"
+
+// encryptedPrefix starts the encrypted values.
+const encryptedPrefix = EncryptionScheme + ":"
+
+// Cipher encrypts the payloads and the credentials stored by Strillone with AES-GCM,
+// as the webhook payloads can contain the personal data of the contacts.
+//
+// A Cipher is also the SecretBackend of the enc scheme, decrypting the credentials of the configuration.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns the cipher of an AES key of 16, 24 or 32 bytes.
+func NewCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// LoadCipher returns the cipher of the key, encoded in base64, or of the secret reference to the key,
+// such as aws-kms:<encrypted data key> to decrypt a data key with AWS KMS.
+func LoadCipher(secrets *Secrets, value string) (*Cipher, error) {
+	resolved, err := secrets.Resolve(value)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(resolved))
+	if err != nil || !validAESKeySize(len(key)) {
+		// the data keys decrypted with KMS are raw bytes
+		key = []byte(resolved)
+	}
+	if !validAESKeySize(len(key)) {
+		return nil, errors.New("encryption key must be 16, 24 or 32 bytes, encoded in base64")
+	}
+	return NewCipher(key)
+}
+
+func validAESKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// Encrypt returns the plaintext encrypted, as an enc: reference.
+func (c *Cipher) Encrypt(plaintext []byte) string {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, plaintext, nil))
+}
+
+// Decrypt returns the plaintext of an enc: reference.
+func (c *Cipher) Decrypt(value string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted value: %v", err)
+	}
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("invalid encrypted value: too short")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.New("invalid encrypted value: wrong key or corrupted data")
+	}
+	return plaintext, nil
+}
+
+// Resolve implements SecretBackend
+func (c *Cipher) Resolve(ctx context.Context, path, key string) (string, error) {
+	plaintext, err := c.Decrypt(path)
+	if err != nil {
+		return "", err
+	}
+	return jsonSecretKey(string(plaintext), key)
+}
+
+// encryptPayload returns the payload encrypted, as a JSON string so that it is still valid JSON.
+func (c *Cipher) encryptPayload(payload json.RawMessage) json.RawMessage {
+	if len(payload) == 0 {
+		return payload
+	}
+	encrypted, _ := json.Marshal(c.Encrypt(payload))
+	return encrypted
+}
+
+// decryptPayload returns the payload decrypted. The payloads stored before the encryption was enabled are returned unchanged.
+func (c *Cipher) decryptPayload(payload json.RawMessage) (json.RawMessage, error) {
+	if !bytes.HasPrefix(payload, []byte(`"`+encryptedPrefix)) {
+		return payload, nil
+	}
+	var value string
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return nil, err
+	}
+	return c.Decrypt(value)
+}
+
+// EncryptHistory returns the history storing the payloads of the events encrypted with the cipher.
+func EncryptHistory(history History, c *Cipher) History {
+	return &encryptedHistory{History: history, cipher: c}
+}
+
+type encryptedHistory struct {
+	History
+	cipher *Cipher
+}
+
+// Record implements History
+func (h *encryptedHistory) Record(event *HistoryEvent) error {
+	encrypted := *event
+	encrypted.Payload = h.cipher.encryptPayload(event.Payload)
+	return h.History.Record(&encrypted)
+}
+
+// encryptedHistoryPage is the number of the stored events decrypted at once to match their actors and keywords.
+const encryptedHistoryPage = 100
+
+// Query implements History
+//
+// The actors and the keywords are searched in the payloads, so they are matched once decrypted,
+// scanning the stored events by pages until the limit is reached.
+func (h *encryptedHistory) Query(filter *HistoryFilter) ([]*HistoryEvent, error) {
+	if filter.Actor == "" && len(filter.Keywords) == 0 {
+		events, err := h.History.Query(filter)
+		if err != nil {
+			return nil, err
+		}
+		return h.readable(events), nil
+	}
+
+	stored := *filter
+	stored.Actor, stored.Keywords, stored.Limit = "", nil, encryptedHistoryPage
+	matching := []*HistoryEvent{}
+	for {
+		events, err := h.History.Query(&stored)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range h.readable(events) {
+			if filter.Matches(event) {
+				matching = append(matching, event)
+				if len(matching) == filter.Limit {
+					return matching, nil
+				}
+			}
+		}
+		if len(events) < stored.Limit {
+			return matching, nil
+		}
+		stored.Until = events[len(events)-1].ReceivedAt
+	}
+}
+
+// Get implements History
+func (h *encryptedHistory) Get(tenant, requestID string) (*HistoryEvent, error) {
+	event, err := h.History.Get(tenant, requestID)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := h.cipher.decryptPayload(event.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("event %s: %v", event.RequestID, err)
+	}
+	event.Payload = payload
+	return event, nil
+}
+
+// Expired implements HistoryPruner
+//
+// The events that can't be decrypted are pruned too, and archived as stored.
+func (h *encryptedHistory) Expired(before time.Time, max int, limit int) ([]*HistoryEvent, error) {
+	pruner, ok := h.History.(HistoryPruner)
+	if !ok {
+		return nil, nil
+	}
+	events, err := pruner.Expired(before, max, limit)
+	if err != nil {
+		return nil, err
+	}
+	h.decrypt(events)
+	return events, nil
+}
+
+// Remove implements HistoryPruner
+func (h *encryptedHistory) Remove(events []*HistoryEvent) error {
+	pruner, ok := h.History.(HistoryPruner)
+	if !ok {
+		return nil
+	}
+	return pruner.Remove(events)
+}
+
+// decrypt decrypts the payloads of the events, and returns the events that can't be decrypted,
+// such as the events encrypted with another key, whose payloads are left as stored.
+func (h *encryptedHistory) decrypt(events []*HistoryEvent) map[*HistoryEvent]bool {
+	undecryptable := map[*HistoryEvent]bool{}
+	for _, event := range events {
+		payload, err := h.cipher.decryptPayload(event.Payload)
+		if err != nil {
+			log.Error().Err(err).Str("request_id", event.RequestID).Str("tenant", event.Tenant).Msg("Error decrypting the history event")
+			undecryptable[event] = true
+			continue
+		}
+		event.Payload = payload
+	}
+	return undecryptable
+}
+
+// readable returns the events decrypted, leaving out the events that can't be decrypted.
+func (h *encryptedHistory) readable(events []*HistoryEvent) []*HistoryEvent {
+	undecryptable := h.decrypt(events)
+	if len(undecryptable) == 0 {
+		return events
+	}
+	readable := make([]*HistoryEvent, 0, len(events)-len(undecryptable))
+	for _, event := range events {
+		if !undecryptable[event] {
+			readable = append(readable, event)
+		}
+	}
+	return readable
+}
+
+// EncryptQueue returns the queue storing the payloads of the jobs encrypted with the cipher.
+func EncryptQueue(queue Queue, c *Cipher) Queue {
+	return &encryptedQueue{Queue: queue, cipher: c}
+}
+
+type encryptedQueue struct {
+	Queue
+	cipher *Cipher
+}
+
+// Enqueue implements Queue
+func (q *encryptedQueue) Enqueue(jobs ...*Job) error {
+	encrypted := make([]*Job, len(jobs))
+	for i, job := range jobs {
+		encrypted[i] = q.encrypt(job)
+	}
+	err := q.Queue.Enqueue(encrypted...)
+	for i, job := range jobs {
+		job.ID = encrypted[i].ID
+	}
+	return err
+}
+
+// Due implements Queue
+//
+// The jobs that can't be decrypted, such as the jobs encrypted with another key, are moved to the dead letters
+// as stored, rather than failing the delivery of the other jobs.
+func (q *encryptedQueue) Due(now time.Time, limit int) ([]*Job, error) {
+	jobs, err := q.Queue.Due(now, limit)
+	if err != nil {
+		return nil, err
+	}
+	due := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		if err := q.decrypt(job); err != nil {
+			log.Error().Err(err).Uint64("job_id", job.ID).Str("tenant", job.Tenant).Str("destination", job.Destination).Msg("Error decrypting job, moving it to the dead letters")
+			job.LastError = "decryption: " + err.Error()
+			job.FailedAt = &now
+			if err := q.Queue.Bury(job); err != nil {
+				log.Error().Err(err).Uint64("job_id", job.ID).Msg("Error burying job")
+			}
+			continue
+		}
+		due = append(due, job)
+	}
+	return due, nil
+}
+
+// Update implements Queue
+func (q *encryptedQueue) Update(job *Job) error {
+	return q.Queue.Update(q.encrypt(job))
+}
+
+// Bury implements Queue
+func (q *encryptedQueue) Bury(job *Job) error {
+	return q.Queue.Bury(q.encrypt(job))
+}
+
+// DeadLetters implements Queue
+//
+// The dead letters that can't be decrypted are listed with their payloads as stored.
+func (q *encryptedQueue) DeadLetters() ([]*Job, error) {
+	jobs, err := q.Queue.DeadLetters()
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if err := q.decrypt(job); err != nil {
+			log.Error().Err(err).Uint64("job_id", job.ID).Msg("Error decrypting dead letter")
+		}
+	}
+	return jobs, nil
+}
+
+func (q *encryptedQueue) encrypt(job *Job) *Job {
+	encrypted := *job
+	encrypted.Payload = q.cipher.encryptPayload(job.Payload)
+	return &encrypted
+}
+
+// decrypt decrypts the payload of the job, left as stored if it can't be decrypted.
+func (q *encryptedQueue) decrypt(job *Job) error {
+	payload, err := q.cipher.decryptPayload(job.Payload)
+	if err != nil {
+		return err
+	}
+	job.Payload = payload
+	return nil
+}
+
+// SetCipher encrypts the credentials of the configuration saved with the admin API, as enc: references.
+// The cipher must be registered in the secrets with the enc scheme to decrypt them.
+func (s *Server) SetCipher(c *Cipher) {
+	s.cipher = c
+}
+
+// encryptCredentials encrypts the credentials of the configuration that aren't secret references already.
+func (s *Server) encryptCredentials(config *Config) {
+	if s.cipher == nil {
+		return
+	}
+	updateCredentials(config, func(credential string) string {
+		if credential == "" || s.secrets.IsReference(credential) {
+			return credential
+		}
+		return s.cipher.Encrypt([]byte(credential))
+	})
+}
+
+// updateCredentials replaces the credentials of the configuration, the fields resolved as secrets, with update.
+func updateCredentials(config *Config, update func(credential string) string) {
+	fields := []*string{
+		&config.Admin.Token,
+		&config.Inbound.SigningSecret,
+		&config.Inbound.Password,
+		&config.Inbound.BearerToken,
+	}
+	if config.Commands != nil {
+		fields = append(fields, &config.Commands.SigningSecret)
+	}
+	if config.Approvals != nil {
+		fields = append(fields, &config.Approvals.SigningSecret)
+	}
+	if config.Feed != nil {
+		fields = append(fields, &config.Feed.Token)
+	}
+	apis := []*APIConfig{config.API}
+	for i := range config.Admin.Keys {
+		fields = append(fields, &config.Admin.Keys[i].Token)
+	}
+	if config.Admin.OIDC != nil {
+		fields = append(fields, &config.Admin.OIDC.ClientSecret)
+	}
+	for i := range config.Tenants {
+		fields = append(fields, &config.Tenants[i].SigningSecret)
+		if config.Tenants[i].Feed != nil {
+			fields = append(fields, &config.Tenants[i].Feed.Token)
+		}
+	}
+	for i := range config.Environments {
+		fields = append(fields, &config.Environments[i].SigningSecret)
+		apis = append(apis, config.Environments[i].API)
+	}
+	for _, api := range apis {
+		if api == nil {
+			continue
+		}
+		fields = append(fields, &api.Token)
+		if api.Cache != nil {
+			fields = append(fields, &api.Cache.Redis)
+		}
+	}
+	for _, field := range fields {
+		*field = update(*field)
+	}
+	updateDestinationCredentials(config.Destinations, update)
+	for i := range config.Tenants {
+		updateDestinationCredentials(config.Tenants[i].Destinations, update)
+	}
+}
+
+func updateDestinationCredentials(destinations []DestinationConfig, update func(credential string) string) {
+	for i := range destinations {
+		d := &destinations[i]
+		d.URL = update(d.URL)
+		d.BotToken = update(d.BotToken)
+		for key, value := range d.Settings {
+			d.Settings[key] = update(value)
+		}
+	}
+}
+
+// AWSKMSBackend decrypts the ciphertexts encrypted with AWS KMS, such as the data keys of the encryption.
+// The path is the ciphertext blob, encoded in base64.
+type AWSKMSBackend struct {
+	Credentials *AWSCredentials
+
+	// Endpoint overrides the regional endpoint, mostly useful for testing.
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// Resolve implements SecretBackend
+func (b *AWSKMSBackend) Resolve(ctx context.Context, path, key string) (string, error) {
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com/", b.Credentials.Region)
+	}
+
+	payload, err := json.Marshal(map[string]string{"CiphertextBlob": path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	signAWSRequest(req, payload, "kms", b.Credentials, time.Now())
+
+	body, err := doSecretsRequest(b.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Plaintext string `json:"Plaintext"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(response.Plaintext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// GCPKMSBackend decrypts the ciphertexts encrypted with Google Cloud KMS, such as the data keys of the encryption.
+// The path is the name of the key, such as projects/my-project/locations/global/keyRings/strillone/cryptoKeys/storage,
+// and the key the ciphertext, encoded in base64.
+type GCPKMSBackend struct {
+	Credentials *GCPCredentials
+
+	// Endpoint overrides the Cloud KMS endpoint, mostly useful for testing.
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// Resolve implements SecretBackend
+func (b *GCPKMSBackend) Resolve(ctx context.Context, path, key string) (string, error) {
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = "https://cloudkms.googleapis.com"
+	}
+	if key == "" {
+		return "", errors.New("missing ciphertext, expected gcp-kms:<key name>#<ciphertext>")
+	}
+
+	payload, err := json.Marshal(map[string]string{"ciphertext": key})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v1/%s:decrypt", endpoint, path), bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := b.Credentials.authorize(req); err != nil {
+		return "", err
+	}
+	body, err := doSecretsRequest(b.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(response.Plaintext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
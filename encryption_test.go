@@ -0,0 +1,290 @@
+package strillone
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCipher(t *testing.T) *Cipher {
+	cipher, err := NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewCipher returned error: %v", err)
+	}
+	return cipher
+}
+
+func TestCipher(t *testing.T) {
+	cipher := newTestCipher(t)
+
+	encrypted := cipher.Encrypt([]byte("jane@example.com"))
+	if !strings.HasPrefix(encrypted, "enc:") || strings.Contains(encrypted, "jane") {
+		t.Errorf("Encrypt expected an enc: reference, got %v", encrypted)
+	}
+	if encrypted == cipher.Encrypt([]byte("jane@example.com")) {
+		t.Errorf("Encrypt expected a random nonce")
+	}
+	plaintext, err := cipher.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %v", err)
+	}
+	if want, got := "jane@example.com", string(plaintext); want != got {
+		t.Errorf("Decrypt expected %v, got %v", want, got)
+	}
+
+	other, _ := NewCipher([]byte("fedcba9876543210fedcba9876543210"))
+	if _, err := other.Decrypt(encrypted); err == nil {
+		t.Errorf("Decrypt with another key expected error")
+	}
+
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	loaded, err := LoadCipher(nil, key)
+	if err != nil {
+		t.Fatalf("LoadCipher returned error: %v", err)
+	}
+	if _, err := loaded.Decrypt(encrypted); err != nil {
+		t.Errorf("Decrypt with the loaded key returned error: %v", err)
+	}
+	if _, err := LoadCipher(nil, "short"); err == nil {
+		t.Errorf("LoadCipher of an invalid key expected error")
+	}
+}
+
+func TestEncryptHistory(t *testing.T) {
+	bolt, cleanup := openTestHistory(t)
+	defer cleanup()
+	history := EncryptHistory(bolt, newTestCipher(t))
+
+	now := time.Now()
+	payloads := []string{
+		`{"name":"contact.update","actor":{"pretty":"jane@example.com"},"data":{"contact":{"email":"jane@example.com"}}}`,
+		`{"name":"domain.create","actor":{"pretty":"john@example.com"},"data":{"domain":{"name":"example.com"}}}`,
+	}
+	for i, payload := range payloads {
+		event := &HistoryEvent{Name: fmt.Sprintf("event.%d", i), RequestID: fmt.Sprint(i), ReceivedAt: now.Add(time.Duration(i) * time.Minute), Payload: []byte(payload)}
+		if err := history.Record(event); err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+		if want, got := payload, string(event.Payload); want != got {
+			t.Errorf("Record expected the event unchanged, got %v", got)
+		}
+	}
+
+	stored, _ := bolt.Get("", "0")
+	if strings.Contains(string(stored.Payload), "jane") {
+		t.Errorf("expected the stored payload to be encrypted, got %v", string(stored.Payload))
+	}
+	event, err := history.Get("", "0")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if want, got := payloads[0], string(event.Payload); want != got {
+		t.Errorf("Get expected payload %v, got %v", want, got)
+	}
+
+	// the keywords are matched in the decrypted payloads
+	found, err := history.Query(&HistoryFilter{Keywords: []string{"jane@example.com"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if len(found) != 1 || found[0].RequestID != "0" {
+		t.Errorf("Query expected the event of jane@example.com, got %v events", len(found))
+	}
+	found, _ = history.Query(&HistoryFilter{Limit: 1})
+	if len(found) != 1 || string(found[0].Payload) != payloads[1] {
+		t.Errorf("Query expected the newest event decrypted, got %v events", len(found))
+	}
+
+	expired, err := history.(HistoryPruner).Expired(now.Add(30*time.Second), 0, 10)
+	if err != nil {
+		t.Fatalf("Expired returned error: %v", err)
+	}
+	if len(expired) != 1 || string(expired[0].Payload) != payloads[0] {
+		t.Errorf("Expired expected the oldest event decrypted, got %v events", len(expired))
+	}
+}
+
+func TestEncryptHistory_QueryPages(t *testing.T) {
+	bolt, cleanup := openTestHistory(t)
+	defer cleanup()
+	history := EncryptHistory(bolt, newTestCipher(t))
+	other, _ := NewCipher([]byte("fedcba9876543210fedcba9876543210"))
+
+	// jane is the actor of the oldest events, beyond the first page, and of an event encrypted with another key
+	now := time.Now()
+	for i := 0; i < 2*encryptedHistoryPage+10; i++ {
+		actor := "john@example.com"
+		if i < 5 {
+			actor = "jane@example.com"
+		}
+		event := &HistoryEvent{Name: "domain.create", RequestID: fmt.Sprint(i), ReceivedAt: now.Add(time.Duration(i) * time.Second),
+			Payload: []byte(fmt.Sprintf(`{"name":"domain.create","actor":{"pretty":%q}}`, actor))}
+		if err := history.Record(event); err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+	}
+	undecryptable := &HistoryEvent{Name: "domain.create", RequestID: "other", ReceivedAt: now.Add(time.Hour),
+		Payload: other.encryptPayload([]byte(`{"name":"domain.create","actor":{"pretty":"jane@example.com"}}`))}
+	if err := bolt.Record(undecryptable); err != nil {
+		t.Fatalf("Record returned error: %v", err)
+	}
+
+	found, err := history.Query(&HistoryFilter{Actor: "jane", Limit: 3})
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if len(found) != 3 || found[0].RequestID != "4" || found[2].RequestID != "2" {
+		t.Errorf("Query expected the 3 newest events of jane, got %v events", len(found))
+	}
+	if found, _ := history.Query(&HistoryFilter{Actor: "jane"}); len(found) != 5 {
+		t.Errorf("Query without limit expected the 5 events of jane, got %v events", len(found))
+	}
+	if found, _ := history.Query(&HistoryFilter{Limit: 1}); len(found) != 0 {
+		t.Errorf("Query expected the undecryptable event left out, got %v events", len(found))
+	}
+	if _, err := history.Get("", "other"); err == nil {
+		t.Errorf("Get expected error for the undecryptable event")
+	}
+}
+
+func TestEncryptQueue(t *testing.T) {
+	bolt, cleanup := openTestQueue(t)
+	defer cleanup()
+	queue := EncryptQueue(bolt, newTestCipher(t))
+
+	now := time.Now()
+	payload := `{"name":"contact.create","data":{"contact":{"email":"jane@example.com"}}}`
+	job := &Job{Destination: "ops", Payload: []byte(payload), NextAttempt: now}
+	if err := queue.Enqueue(job); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if job.ID == 0 {
+		t.Errorf("Enqueue expected the ID of the job")
+	}
+
+	stored, _ := bolt.Due(now, 10)
+	if len(stored) != 1 || strings.Contains(string(stored[0].Payload), "jane") {
+		t.Fatalf("expected the stored payload to be encrypted, got %v jobs", len(stored))
+	}
+	due, err := queue.Due(now, 10)
+	if err != nil {
+		t.Fatalf("Due returned error: %v", err)
+	}
+	if len(due) != 1 || string(due[0].Payload) != payload {
+		t.Fatalf("Due expected the job decrypted, got %v jobs", len(due))
+	}
+
+	if err := queue.Bury(due[0]); err != nil {
+		t.Fatalf("Bury returned error: %v", err)
+	}
+	dead, err := queue.DeadLetters()
+	if err != nil {
+		t.Fatalf("DeadLetters returned error: %v", err)
+	}
+	if len(dead) != 1 || string(dead[0].Payload) != payload {
+		t.Errorf("DeadLetters expected the job decrypted, got %v jobs", len(dead))
+	}
+}
+
+func TestServer_encryptCredentials(t *testing.T) {
+	cipher := newTestCipher(t)
+	server, store := newAdminTestServer()
+	server.SetCipher(cipher)
+	if err := server.SetSecrets(NewSecrets(map[string]SecretBackend{EncryptionScheme: cipher})); err != nil {
+		t.Fatalf("SetSecrets returned error: %v", err)
+	}
+
+	response := adminRequest(server, "POST", "/admin/destinations", `{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/X/Y/Z"}`)
+	if want := http.StatusCreated; want != response.Code {
+		t.Fatalf("POST /admin/destinations expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+
+	config, _ := store.Load()
+	url := config.Destinations[0].URL
+	if !strings.HasPrefix(url, "enc:") || !strings.HasPrefix(config.Admin.Token, "enc:") {
+		t.Fatalf("expected the credentials to be encrypted, got %v and %v", url, config.Admin.Token)
+	}
+	if resolved, _ := server.secrets.Resolve(url); resolved != "https://hooks.slack.com/services/X/Y/Z" {
+		t.Errorf("Resolve expected the URL decrypted, got %v", resolved)
+	}
+
+	// the encrypted admin token still authorizes the requests, and the encrypted credentials are kept
+	response = adminRequest(server, "DELETE", "/admin/destinations/ops", "")
+	if want := http.StatusNoContent; want != response.Code {
+		t.Errorf("DELETE /admin/destinations/ops expected HTTP %v, got %v", want, response.Code)
+	}
+	if saved, _ := store.Load(); saved.Admin.Token != config.Admin.Token {
+		t.Errorf("expected the encrypted admin token unchanged, got %v", saved.Admin.Token)
+	}
+}
+
+func TestAWSKMSBackend(t *testing.T) {
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(r.Header.Get("Authorization"), "/kms/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if want, got := "TrentService.Decrypt", r.Header.Get("X-Amz-Target"); want != got {
+			t.Errorf("request target expected %v, got %v", want, got)
+		}
+		if want, got := `{"CiphertextBlob":"AQIDBA=="}`, string(body); want != got {
+			t.Errorf("request body expected %v, got %v", want, got)
+		}
+		fmt.Fprintf(w, `{"KeyId": "arn:aws:kms:us-east-1:123456789012:key/1", "Plaintext": "%s"}`, base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	}))
+	defer aws.Close()
+
+	backend := &AWSKMSBackend{
+		Credentials: &AWSCredentials{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Endpoint:    aws.URL,
+	}
+	secrets := NewSecrets(map[string]SecretBackend{"aws-kms": backend})
+	if _, err := LoadCipher(secrets, "aws-kms:AQIDBA=="); err != nil {
+		t.Fatalf("LoadCipher returned error: %v", err)
+	}
+	got, err := backend.Resolve(context.Background(), "AQIDBA==", "")
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if want := "0123456789abcdef"; want != got {
+		t.Errorf("Resolve expected %v, got %v", want, got)
+	}
+}
+
+func TestEncryptQueue_Undecryptable(t *testing.T) {
+	bolt, cleanup := openTestQueue(t)
+	defer cleanup()
+	queue := EncryptQueue(bolt, newTestCipher(t))
+	other, _ := NewCipher([]byte("fedcba9876543210fedcba9876543210"))
+
+	now := time.Now()
+	undecryptable := &Job{Destination: "ops", Payload: other.encryptPayload([]byte(`{"name":"contact.create"}`)), NextAttempt: now}
+	if err := bolt.Enqueue(undecryptable); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if err := queue.Enqueue(&Job{Destination: "ops", Payload: []byte(`{"name":"domain.create"}`), NextAttempt: now}); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+
+	due, err := queue.Due(now, 10)
+	if err != nil {
+		t.Fatalf("Due returned error: %v", err)
+	}
+	if len(due) != 1 || string(due[0].Payload) != `{"name":"domain.create"}` {
+		t.Errorf("Due expected the job decrypted, got %v jobs", len(due))
+	}
+	dead, err := queue.DeadLetters()
+	if err != nil {
+		t.Fatalf("DeadLetters returned error: %v", err)
+	}
+	if len(dead) != 1 || dead[0].ID != undecryptable.ID || dead[0].FailedAt == nil {
+		t.Errorf("DeadLetters expected the undecryptable job, got %v jobs", len(dead))
+	}
+}
@@ -0,0 +1,159 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// DomainDetails are the details of the domain of an event, fetched from the DNSimple API
+// for the events that only include the domain ID.
+type DomainDetails struct {
+	Name       string
+	ExpiresAt  string
+	AutoRenew  bool
+	Registrant string
+}
+
+// domainEnricher fetches the details of the domains of the events from the DNSimple API.
+type domainEnricher struct {
+	client *dnsimple.Client
+	cache  detailsCache
+	ttl    time.Duration
+}
+
+// newDomainEnricher returns the enricher using the client and the cache of the details,
+// nil if the API is not configured.
+func newDomainEnricher(client *dnsimple.Client, cache detailsCache, ttl time.Duration) *domainEnricher {
+	if client == nil {
+		return nil
+	}
+	return &domainEnricher{client: client, cache: cache, ttl: ttl}
+}
+
+// enrich fetches the details of the domain of the event, when the event only includes the domain ID,
+// so that the messages include them. It returns nil without details: the errors are logged,
+// and the messages sent without the details.
+func (d *domainEnricher) enrich(ctx context.Context, e *webhook.Event) *DomainDetails {
+	if d == nil || e.Account == nil {
+		return nil
+	}
+	domainID := eventDomainID(e)
+	if domainID == 0 {
+		return nil
+	}
+
+	details, err := d.details(ctx, strconv.FormatInt(e.Account.ID, 10), strconv.FormatInt(domainID, 10))
+	if err != nil {
+		withEvent(log.Warn(), e).Err(err).Int64("domain_id", domainID).Msg("Error fetching the domain details")
+		return nil
+	}
+	return details
+}
+
+// details returns the details of the domain, from the cache or from the API.
+func (d *domainEnricher) details(ctx context.Context, accountID, domainID string) (*DomainDetails, error) {
+	key := "strillone:domain:" + accountID + "/" + domainID
+	if data, ok := d.cache.get(key); ok {
+		details := &DomainDetails{}
+		if err := json.Unmarshal(data, details); err == nil {
+			return details, nil
+		}
+	}
+
+	response, err := d.client.Domains.GetDomain(ctx, accountID, domainID)
+	if err != nil {
+		return nil, err
+	}
+	domain := response.Data
+	details := &DomainDetails{Name: domain.Name, ExpiresAt: domain.ExpiresAt, AutoRenew: domain.AutoRenew}
+	if domain.RegistrantID != 0 {
+		if details.Registrant, err = d.registrant(ctx, accountID, domain.RegistrantID); err != nil {
+			return nil, err
+		}
+	}
+
+	if data, err := json.Marshal(details); err == nil {
+		d.cache.set(key, data, d.ttl)
+	}
+	return details, nil
+}
+
+// registrant returns the name of the registrant contact, from the cache or from the API,
+// since the domains of an account often share their registrant.
+func (d *domainEnricher) registrant(ctx context.Context, accountID string, contactID int64) (string, error) {
+	key := fmt.Sprintf("strillone:contact:%s/%d", accountID, contactID)
+	if data, ok := d.cache.get(key); ok {
+		return string(data), nil
+	}
+	contact, err := d.client.Contacts.GetContact(ctx, accountID, contactID)
+	if err != nil {
+		return "", err
+	}
+	name := contactName(contact.Data)
+	d.cache.set(key, []byte(name), d.ttl)
+	return name, nil
+}
+
+// contactName returns the name of the contact, or its organization or label.
+func contactName(contact *dnsimple.Contact) string {
+	if name := strings.TrimSpace(contact.FirstName + " " + contact.LastName); name != "" {
+		return name
+	}
+	if contact.Organization != "" {
+		return contact.Organization
+	}
+	return contact.Label
+}
+
+// eventDomainID returns the ID of the domain of the events that don't include the domain itself, 0 otherwise.
+func eventDomainID(e *webhook.Event) int64 {
+	switch data := eventData(e).(type) {
+	case *CertificateEvent:
+		return data.Certificate.DomainID
+	case *webhook.DNSSECEventData:
+		if data.DelegationSignerRecord != nil {
+			return data.DelegationSignerRecord.DomainID
+		}
+	case *webhook.EmailForwardEventData:
+		if data.EmailForward != nil {
+			return data.EmailForward.DomainID
+		}
+	case *PushEventData:
+		if data.Domain == nil && data.Push != nil {
+			return data.Push.DomainID
+		}
+	}
+	return 0
+}
+
+// domainContext describes the domain of the event fetched from the API, such as
+// " (example.com expires on 2022-06-16, auto-renewal disabled, registrant Jane Doe)", to append to the messages.
+func domainContext(s Formatter, e *webhook.Event) string {
+	details := detailsOf(e).Domain
+	if details == nil || details.ExpiresAt == "" {
+		return ""
+	}
+
+	expiresAt := details.ExpiresAt
+	if t, ok := parseTimestamp(expiresAt); ok {
+		expiresAt = t.Format(certificateDateFormat)
+	}
+	context := []string{tprintf(s, "%s expires on %s", escape(s, details.Name), expiresAt)}
+	if details.AutoRenew {
+		context = append(context, translate(s, "auto-renewal enabled"))
+	} else {
+		context = append(context, translate(s, "auto-renewal disabled"))
+	}
+	if details.Registrant != "" {
+		context = append(context, tprintf(s, "registrant %s", escape(s, details.Registrant)))
+	}
+	return " (" + strings.Join(context, ", ") + ")"
+}
@@ -0,0 +1,86 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestDomainEnricher_Enrich(t *testing.T) {
+	requests := 0
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if want, got := "Bearer api-token", r.Header.Get("Authorization"); want != got {
+			t.Errorf("expected Authorization %v, got %v", want, got)
+		}
+		switch r.URL.Path {
+		case "/v2/1010/domains/1":
+			fmt.Fprint(w, `{"data": {"id": 1, "account_id": 1010, "registrant_id": 2, "name": "example.com", "auto_renew": false, "expires_at": "2022-06-16T12:00:00Z"}}`)
+		case "/v2/1010/contacts/2":
+			fmt.Fprint(w, `{"data": {"id": 2, "first_name": "Jane", "last_name": "Doe"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	client, err := newAPIClient(&APIConfig{Token: "api-token", URL: api.URL}, nil)
+	if err != nil {
+		t.Fatalf("newAPIClient returned error: %v", err)
+	}
+	enricher := newDomainEnricher(client, newMemoryCache(maxCachedDetails), defaultDetailsCacheTTL)
+
+	payload := `{"request_identifier": "%s", "name": "email_forward.create", "data": {"email_forward": {"id": 1, "domain_id": %d, "from": "jane@example.com", "to": "jane@example.org"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	event, err := webhook.ParseEvent([]byte(fmt.Sprintf(payload, "enrich-0000-0000-000000000001", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event = withDetails(event, &eventDetails{Domain: enricher.enrich(context.Background(), event)})
+
+	want := "[<User|https://dnsimple.com/a/1010/account>] example@example.com created the email forward <jane@example.com → jane@example.org|https://dnsimple.com/a/1010/domains/1/email_forwards> (example.com expires on 2022-06-16, auto-renewal disabled, registrant Jane Doe)"
+	if got := Message(NewTestMessagingService("test"), event); want != got {
+		t.Errorf("Message expected %q, got %q", want, got)
+	}
+
+	// The details are cached.
+	event, _ = webhook.ParseEvent([]byte(fmt.Sprintf(payload, "enrich-0000-0000-000000000002", 1)))
+	enricher.enrich(context.Background(), event)
+	if want, got := 2, requests; want != got {
+		t.Errorf("enrich expected %d API requests, got %d", want, got)
+	}
+
+	// The messages are sent without the details when the API fails.
+	event, _ = webhook.ParseEvent([]byte(fmt.Sprintf(payload, "enrich-0000-0000-000000000003", 404)))
+	if details := enricher.enrich(context.Background(), event); details != nil {
+		t.Errorf("enrich expected no details, got %+v", details)
+	}
+	if got := domainContext(NewTestMessagingService("test"), event); got != "" {
+		t.Errorf("domainContext expected no details, got %q", got)
+	}
+}
+
+func TestAPIConfig_validate(t *testing.T) {
+	tests := []struct {
+		config *APIConfig
+		valid  bool
+	}{
+		{nil, true},
+		{&APIConfig{Token: "api-token", URL: "https://api.sandbox.dnsimple.com"}, true},
+		{&APIConfig{}, false},
+		{&APIConfig{Token: "api-token", URL: "api.dnsimple.com"}, false},
+		{&APIConfig{Token: "api-token", Timeout: -1}, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate()
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
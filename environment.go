@@ -0,0 +1,132 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sandboxEnvironment is the name of the DNSimple sandbox environment, whose URLs are known.
+	sandboxEnvironment = "sandbox"
+
+	sandboxDashboardURL = "https://sandbox.dnsimple.com"
+	sandboxAPIURL       = "https://api.sandbox.dnsimple.com"
+)
+
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// EnvironmentConfig is another DNSimple environment sending its events to this instance, such as the sandbox.
+// Its events are received on /e/<name>/events, delivered with the destinations and the routes of the configuration,
+// and tagged with the label of the environment.
+type EnvironmentConfig struct {
+	Name string `json:"name"`
+
+	// Label tags the messages of the environment. Defaults to the name.
+	Label string `json:"label,omitempty"`
+
+	// SigningSecret overrides the inbound signing secret for the environment.
+	SigningSecret string `json:"signing_secret,omitempty"`
+
+	// DashboardURL is the base URL of the dashboard of the environment.
+	// Defaults to https://sandbox.dnsimple.com for the sandbox, and to the dashboard_url otherwise.
+	DashboardURL string `json:"dashboard_url,omitempty"`
+
+	// API configures the client of the API of the environment, completing its events.
+	// The URL defaults to https://api.sandbox.dnsimple.com for the sandbox. Without API, the events are not completed.
+	API *APIConfig `json:"api,omitempty"`
+}
+
+func (c *EnvironmentConfig) validate() error {
+	if !environmentNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("environment %q: invalid name", c.Name)
+	}
+	if err := validateDashboardURL(c.DashboardURL); err != nil {
+		return fmt.Errorf("environment %q: %v", c.Name, err)
+	}
+	if err := c.api().validate(); err != nil {
+		return fmt.Errorf("environment %q: %v", c.Name, err)
+	}
+	return nil
+}
+
+func (c *EnvironmentConfig) label() string {
+	if c.Label == "" {
+		return c.Name
+	}
+	return c.Label
+}
+
+// dashboardURL returns the dashboard of the environment, empty for the default one.
+func (c *EnvironmentConfig) dashboardURL() string {
+	if c.DashboardURL == "" && c.Name == sandboxEnvironment {
+		return sandboxDashboardURL
+	}
+	return c.DashboardURL
+}
+
+// api returns the configuration of the API client of the environment, with the URL of the sandbox by default.
+func (c *EnvironmentConfig) api() *APIConfig {
+	if c.API == nil || c.API.URL != "" || c.Name != sandboxEnvironment {
+		return c.API
+	}
+	api := *c.API
+	api.URL = sandboxAPIURL
+	return &api
+}
+
+// newEnvironmentTable returns the routing table of the environment: the routing table of the configuration,
+// with the messages tagged and linked to the environment, and completed with its API.
+func newEnvironmentTable(routing *routingTable, c *EnvironmentConfig, secrets *Secrets) (*routingTable, error) {
+	formatting := *routing.formatting
+	formatting.environment = c.label()
+	if url := c.dashboardURL(); url != "" {
+		formatting.dashboardURL = url
+	}
+	table, err := buildRoutingTable(routing.name, routing.config, &formatting, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	environment := *routing
+	environment.services = table.services
+	environment.formatting = table.formatting
+	environment.tenants = nil
+	environment.environments = nil
+	if c.SigningSecret != "" {
+		if environment.signingSecret, err = secrets.Resolve(c.SigningSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	api := c.api()
+	if environment.api, err = newAPIClient(api, secrets); err != nil {
+		return nil, err
+	}
+	environment.enricher = nil
+	if environment.api != nil {
+		cache, err := newDetailsCache(api.Cache, secrets)
+		if err != nil {
+			return nil, err
+		}
+		environment.enricher = newDomainEnricher(environment.api, cache, api.Cache.ttl())
+	}
+	environment.accounts = newAccountResolver(environment.api)
+	return &environment, nil
+}
+
+// EnvironmentEvents handles a request to publish a webhook of another DNSimple environment, such as the sandbox.
+func (s *Server) EnvironmentEvents(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Request")
+
+	environment, ok := s.currentRouting().environments[params.ByName("environment")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.handleWebhook(w, r, environment)
+}
@@ -0,0 +1,83 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_EnvironmentEvents(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/1010/domains/1":
+			fmt.Fprint(w, `{"data": {"id": 1, "account_id": 1010, "name": "example.com", "auto_renew": true, "expires_at": "2022-06-16T12:00:00Z"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	var texts []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload slackPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		texts = append(texts, payload.Text)
+	}))
+	defer target.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": %q}],
+		"routes": [{"events": ["*"], "destinations": ["ops"]}],
+		"environments": [{"name": "sandbox", "label": "Sandbox", "api": {"token": "sandbox-token", "url": %q}}]
+	}`, target.URL, api.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	payload := `{"request_identifier": "%s", "name": "email_forward.create", "data": {"email_forward": {"id": 1, "domain_id": 1, "from": "jane@example.com", "to": "jane@example.org"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	for _, path := range []string{"/events", "/e/sandbox/events"} {
+		request, _ := http.NewRequest("POST", path, strings.NewReader(fmt.Sprintf(payload, "0d5e8f1a-env-0000-0000-"+strings.Replace(path, "/", "", -1))))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := http.StatusOK, response.Code; want != got {
+			t.Fatalf("POST %s expected HTTP %v, got %v", path, want, got)
+		}
+	}
+
+	want := []string{
+		"[<https://dnsimple.com/a/1010/account|User>] example@example.com created the email forward <https://dnsimple.com/a/1010/domains/1/email_forwards|jane@example.com → jane@example.org>",
+		"[Sandbox] [<https://sandbox.dnsimple.com/a/1010/account|User>] example@example.com created the email forward <https://sandbox.dnsimple.com/a/1010/domains/1/email_forwards|jane@example.com → jane@example.org> (example.com expires on 2022-06-16, auto-renewal enabled)",
+	}
+	if strings.Join(want, "\n") != strings.Join(texts, "\n") {
+		t.Errorf("expected the messages\n%v\ngot\n%v", strings.Join(want, "\n"), strings.Join(texts, "\n"))
+	}
+
+	request, _ := http.NewRequest("POST", "/e/staging/events", strings.NewReader(fmt.Sprintf(payload, "0d5e8f1a-env-0000-0000-000000000003")))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusNotFound, response.Code; want != got {
+		t.Errorf("POST /e/staging/events expected HTTP %v, got %v", want, got)
+	}
+}
+
+func TestEnvironmentConfig_api(t *testing.T) {
+	sandbox := &EnvironmentConfig{Name: "sandbox", API: &APIConfig{Token: "sandbox-token"}}
+	if want, got := sandboxAPIURL, sandbox.api().URL; want != got {
+		t.Errorf("api expected the URL %q, got %q", want, got)
+	}
+	if want, got := sandboxDashboardURL, sandbox.dashboardURL(); want != got {
+		t.Errorf("dashboardURL expected %q, got %q", want, got)
+	}
+
+	staging := &EnvironmentConfig{Name: "staging", API: &APIConfig{Token: "staging-token", URL: "https://api.staging.example.com"}}
+	if want, got := "https://api.staging.example.com", staging.api().URL; want != got {
+		t.Errorf("api expected the URL %q, got %q", want, got)
+	}
+	if err := (&EnvironmentConfig{Name: "Sandbox/1"}).validate(); err == nil {
+		t.Errorf("validate expected error for an invalid name")
+	}
+}
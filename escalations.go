@@ -0,0 +1,301 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultEscalationDelay is the time after which an event not acknowledged is escalated.
+const defaultEscalationDelay = 15 * time.Minute
+
+// EscalationsConfig configures the events that require an acknowledgement in Slack,
+// and are escalated to other destinations when they aren't acknowledged in time.
+// The events wait for the acknowledgement like the approvals, and the clicks and the reactions
+// are verified with the signing secret of the approvals.
+type EscalationsConfig struct {
+	// Reactions are the names of the emoji acknowledging the events when added as a reaction
+	// to the message, such as eyes. An empty list acknowledges the events with any reaction.
+	Reactions []string `json:"reactions,omitempty"`
+
+	// Policies are the events that require an acknowledgement.
+	Policies []EscalationPolicy `json:"policies"`
+}
+
+// EscalationPolicy asks for an acknowledgement of the matching events, and escalates them when
+// they aren't acknowledged within the delay.
+type EscalationPolicy struct {
+	Name string `json:"name"`
+
+	// Events are the event name patterns matched by the policy, using the path.Match syntax (e.g. "domain.*").
+	// An empty list matches every event.
+	Events []string `json:"events,omitempty"`
+
+	// MinSeverity restricts the policy to the events at least as severe. Defaults to critical.
+	MinSeverity Severity `json:"min_severity,omitempty"`
+
+	// Destination is the name of the Slack destination asked for the acknowledgement.
+	Destination string `json:"destination"`
+
+	// Escalation are the names of the destinations receiving the events not acknowledged in time,
+	// such as a pager or an SMS gateway registered with RegisterDestination.
+	Escalation []string `json:"escalation"`
+
+	// Delay is the time after which the event is escalated. Defaults to 15m.
+	Delay Duration `json:"delay,omitempty"`
+}
+
+func (c *EscalationsConfig) validate(destinations []DestinationConfig, approvals *ApprovalsConfig) error {
+	if c == nil {
+		return nil
+	}
+	if approvals == nil {
+		return fmt.Errorf("escalations: missing approvals, with the signing secret of the Slack app")
+	}
+	types := make(map[string]string, len(destinations))
+	for _, d := range destinations {
+		types[d.Name] = d.Type
+	}
+	names := make(map[string]bool, len(c.Policies))
+	for i, policy := range c.Policies {
+		if policy.Name == "" {
+			return fmt.Errorf("escalation #%d: missing name", i)
+		}
+		if names[policy.Name] {
+			return fmt.Errorf("escalation %q: duplicate name", policy.Name)
+		}
+		names[policy.Name] = true
+		for _, pattern := range policy.Events {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("escalation %q: invalid pattern %q", policy.Name, pattern)
+			}
+		}
+		if _, ok := severityRanks[policy.MinSeverity]; policy.MinSeverity != "" && !ok {
+			return fmt.Errorf("escalation %q: invalid min severity %q", policy.Name, policy.MinSeverity)
+		}
+		switch types[policy.Destination] {
+		case "slack":
+		case "":
+			return fmt.Errorf("escalation %q: unknown destination %q", policy.Name, policy.Destination)
+		default:
+			return fmt.Errorf("escalation %q: destination %q is not a Slack destination", policy.Name, policy.Destination)
+		}
+		if len(policy.Escalation) == 0 {
+			return fmt.Errorf("escalation %q: missing escalation destinations", policy.Name)
+		}
+		for _, name := range policy.Escalation {
+			if types[name] == "" {
+				return fmt.Errorf("escalation %q: unknown destination %q", policy.Name, name)
+			}
+		}
+		if policy.Delay < 0 {
+			return fmt.Errorf("escalation %q: delay must be positive", policy.Name)
+		}
+	}
+	return nil
+}
+
+// matches returns true if the event of the severity requires an acknowledgement with the policy.
+func (p *EscalationPolicy) matches(eventName string, severity Severity) bool {
+	if len(p.Events) > 0 && !matchesAny(p.Events, eventName) {
+		return false
+	}
+	min := p.MinSeverity
+	if min == "" {
+		min = SeverityCritical
+	}
+	return severity.AtLeast(min)
+}
+
+// rule returns the approval rule waiting for the acknowledgement of the events, escalated once after the delay.
+func (p *EscalationPolicy) rule() ApprovalRule {
+	delay := p.Delay
+	if delay == 0 {
+		delay = Duration(defaultEscalationDelay)
+	}
+	return ApprovalRule{Name: p.Name, Destination: p.Destination, Escalation: p.Escalation, Timeout: delay, MaxEscalations: 1}
+}
+
+// acknowledgesWith returns true if the reaction acknowledges the events.
+func (c *EscalationsConfig) acknowledgesWith(reaction string) bool {
+	if len(c.Reactions) == 0 {
+		return true
+	}
+	for _, name := range c.Reactions {
+		if name == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// Escalation is an event waiting for an acknowledgement.
+type Escalation struct {
+	ID        string    `json:"id"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Policy    string    `json:"policy"`
+	Event     string    `json:"event"`
+	RequestID string    `json:"request_id"`
+	Deadline  time.Time `json:"deadline"`
+}
+
+func newEscalation(approval *pendingApproval) *Escalation {
+	return &Escalation{
+		ID:        approval.id,
+		Tenant:    approval.tenant,
+		Policy:    approval.rule.Name,
+		Event:     approval.event.Name,
+		RequestID: approval.event.RequestID,
+		Deadline:  approval.deadline,
+	}
+}
+
+// acknowledgements returns the events waiting for an acknowledgement, by deadline.
+func (a *approvals) acknowledgements() []*Escalation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	list := []*Escalation{}
+	for _, approval := range a.pending {
+		if approval.acknowledgement {
+			list = append(list, newEscalation(approval))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Deadline.Before(list[j].Deadline) })
+	return list
+}
+
+// acknowledge releases the acknowledgement, and returns it, nil if unknown or already acknowledged.
+func (a *approvals) acknowledge(id string) *pendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval := a.pending[id]
+	if approval == nil || !approval.acknowledgement {
+		return nil
+	}
+	delete(a.pending, id)
+	return approval
+}
+
+// requireAcknowledgement asks for the acknowledgement of the event, if it matches an escalation policy.
+func (s *Server) requireAcknowledgement(ctx context.Context, routing *routingTable, event *webhook.Event) {
+	config := routing.config.Escalations
+	if config == nil {
+		return
+	}
+	severity := routing.severity(event.Name)
+	for _, policy := range config.Policies {
+		if !policy.matches(event.Name, severity) {
+			continue
+		}
+		approval := s.approvals.add(routing.name, policy.rule(), true, event, time.Now())
+		withEvent(log.Info(), event).Str("escalation", policy.Name).Str("approval_id", approval.id).Msg("Event waiting for acknowledgement")
+		ts := routing.postApproval(ctx, policy.Destination, approval, func(s Formatter) string {
+			return tprintf(s, "Acknowledgement required: %s", Message(s, event))
+		})
+		s.approvals.posted(approval.id, ts)
+	}
+}
+
+// escalate delivers the event not acknowledged in time to the escalation destinations of its policy.
+func (s *Server) escalate(routing *routingTable, approval *pendingApproval) {
+	withEvent(log.Warn(), approval.event).Str("escalation", approval.rule.Name).Str("approval_id", approval.id).Msg("Escalating event: not acknowledged")
+	for _, name := range approval.rule.Escalation {
+		service := routing.services[name]
+		if service == nil {
+			log.Warn().Str("destination", name).Str("approval_id", approval.id).Msg("Dropping the escalation: unknown destination")
+			continue
+		}
+		ctx, cancel := routing.deliveryContext(context.Background())
+		_, err := service.PostEvent(ctx, approval.event)
+		cancel()
+		if err != nil {
+			log.Error().Err(err).Str("destination", name).Str("approval_id", approval.id).Msg("Error escalating the event")
+		}
+	}
+}
+
+// slackEventCallback is a request of the Slack Events API: the verification of the URL,
+// or an event such as reaction_added.
+type slackEventCallback struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Event     struct {
+		Type     string `json:"type"`
+		User     string `json:"user"`
+		Reaction string `json:"reaction"`
+		Item     struct {
+			Type string `json:"type"`
+			TS   string `json:"ts"`
+		} `json:"item"`
+	} `json:"event"`
+}
+
+// SlackEvents handles the requests of the Slack Events API: the reactions to the messages
+// asking for an acknowledgement acknowledge their events.
+func (s *Server) SlackEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Request")
+
+	routing := s.currentRouting()
+	config := routing.config.Escalations
+	if config == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, routing.config.Inbound.maxBodySize()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackSignature(routing.approvalsSecret, r.Header, body, time.Now()); err != nil {
+		log.Warn().Err(err).Msg("Rejecting Slack event")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var callback slackEventCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	switch callback.Type {
+	case "url_verification":
+		writeJSON(w, http.StatusOK, map[string]string{"challenge": callback.Challenge})
+		return
+	case "event_callback":
+		event := callback.Event
+		if event.Type != "reaction_added" || event.Item.Type != "message" || !config.acknowledgesWith(event.Reaction) {
+			break
+		}
+		if approval := s.approvals.acknowledgeMessage(event.Item.TS); approval != nil {
+			withEvent(log.Info(), approval.event).Str("escalation", approval.rule.Name).Str("user_id", event.User).Str("reaction", event.Reaction).Msg("Event acknowledged")
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// AdminListEscalations returns the events waiting for an acknowledgement.
+func (s *Server) AdminListEscalations(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	writeJSON(w, http.StatusOK, s.approvals.acknowledgements())
+}
+
+// AdminAcknowledgeEscalation acknowledges an event waiting for an acknowledgement.
+func (s *Server) AdminAcknowledgeEscalation(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	approval := s.approvals.acknowledge(params.ByName("id"))
+	if approval == nil {
+		writeJSONError(w, http.StatusNotFound, "escalation not found")
+		return
+	}
+	withEvent(log.Info(), approval.event).Str("escalation", approval.rule.Name).Msg("Event acknowledged")
+	writeJSON(w, http.StatusOK, newEscalation(approval))
+}
@@ -0,0 +1,198 @@
+package strillone
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newSlackEventsRequest(secret, body string) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+
+	request, _ := http.NewRequest("POST", "/events/slack", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	request.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return request
+}
+
+func TestServer_Escalations(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]map[string]interface{}{}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], payload)
+		mu.Unlock()
+	}))
+	defer target.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "%[1]s/ops"},
+			{"name": "pager", "type": "slack", "url": "%[1]s/pager"}
+		],
+		"routes": [{"destinations": ["ops"]}],
+		"admin": {"token": "secret"},
+		"approvals": {"signing_secret": "slack-secret"},
+		"escalations": {
+			"reactions": ["eyes"],
+			"policies": [{"name": "oncall", "destination": "ops", "escalation": ["pager"], "delay": "10m"}]
+		}
+	}`, target.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	payload := `{"name": "%s", "request_identifier": "c3d4e5f6-escalate-0000-00000000000%d", "data": {"domain": {"id": %[2]d, "name": "example%[2]d.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	for i, name := range []string{"domain.create", "domain.delete", "domain.delete", "domain.delete", "domain.delete"} {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, name, i)))
+		server.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	mu.Lock()
+	requests := received["/ops"]
+	mu.Unlock()
+	var asked int
+	for _, request := range requests {
+		if strings.HasPrefix(request["text"].(string), "Acknowledgement required: ") {
+			asked++
+		}
+	}
+	if want, got := 4, asked; want != got {
+		t.Fatalf("expected %d acknowledgement requests, got %d", want, got)
+	}
+	pending := server.approvals.acknowledgements()
+	if want, got := 4, len(pending); want != got {
+		t.Fatalf("expected %d pending escalations, got %d", want, got)
+	}
+
+	// The events are acknowledged by the button, the admin API and a reaction.
+	interaction := fmt.Sprintf(`{"type": "block_actions", "user": {"id": "U0JANE"}, "actions": [{"action_id": "acknowledge", "value": %q}], "message": {"text": "Acknowledgement required"}, "response_url": "%s/response"}`, pending[0].ID, target.URL)
+	request := newSlackCommandRequest("slack-secret", url.Values{"payload": {interaction}})
+	request.URL.Path = "/interactions/slack"
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Errorf("POST /interactions/slack expected HTTP %v, got %v", want, got)
+	}
+
+	for _, want := range []int{http.StatusOK, http.StatusNotFound} {
+		request, _ := http.NewRequest("POST", "/admin/escalations/"+pending[1].ID+"/acknowledge", nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if got := response.Code; want != got {
+			t.Errorf("POST /admin/escalations/:id/acknowledge expected HTTP %v, got %v", want, got)
+		}
+	}
+
+	server.approvals.posted(pending[2].ID, "1600000000.000001")
+	for _, reaction := range []string{"thumbsup", "eyes"} {
+		body := fmt.Sprintf(`{"type": "event_callback", "event": {"type": "reaction_added", "user": "U0JOHN", "reaction": %q, "item": {"type": "message", "ts": "1600000000.000001"}}}`, reaction)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newSlackEventsRequest("slack-secret", body))
+		if want, got := http.StatusOK, response.Code; want != got {
+			t.Errorf("POST /events/slack expected HTTP %v, got %v", want, got)
+		}
+	}
+
+	// The event not acknowledged is escalated once, after the delay.
+	server.escalateApprovals(time.Now().Add(5 * time.Minute))
+	server.escalateApprovals(time.Now().Add(11 * time.Minute))
+	server.escalateApprovals(time.Now().Add(22 * time.Minute))
+	mu.Lock()
+	escalated := received["/pager"]
+	replies := received["/response"]
+	mu.Unlock()
+	if want, got := 1, len(escalated); want != got {
+		t.Fatalf("expected %d escalated event, got %d", want, got)
+	}
+	if text := escalated[0]["text"].(string); !strings.Contains(text, "example"+pending[3].RequestID[len(pending[3].RequestID)-1:]+".com") {
+		t.Errorf("expected the event not acknowledged to be escalated, got %q", text)
+	}
+	if want, got := 1, len(replies); want != got {
+		t.Fatalf("expected %d reply, got %d", want, got)
+	}
+	if want, got := "Acknowledgement required — acknowledged by <@U0JANE>", replies[0]["text"]; want != got {
+		t.Errorf("reply expected text %q, got %q", want, got)
+	}
+}
+
+func TestServer_SlackEventsVerification(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"approvals": {"signing_secret": "slack-secret"},
+		"escalations": {"policies": [{"name": "oncall", "destination": "ops", "escalation": ["ops"]}]}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	body := `{"type": "url_verification", "challenge": "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, newSlackEventsRequest("slack-secret", body))
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("POST /events/slack expected HTTP %v, got %v", want, got)
+	}
+	if want := `"challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"`; !strings.Contains(response.Body.String(), want) {
+		t.Errorf("POST /events/slack expected the challenge, got %s", response.Body.String())
+	}
+
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, newSlackEventsRequest("wrong-secret", body))
+	if want, got := http.StatusUnauthorized, response.Code; want != got {
+		t.Errorf("POST /events/slack with an invalid signature expected HTTP %v, got %v", want, got)
+	}
+}
+
+func TestEscalationsConfig_validate(t *testing.T) {
+	destinations := []DestinationConfig{{Name: "ops", Type: "slack"}, {Name: "pager", Type: "pagerduty"}}
+	policy := func(modify func(*EscalationPolicy)) *EscalationsConfig {
+		p := EscalationPolicy{Name: "oncall", Destination: "ops", Escalation: []string{"pager"}}
+		modify(&p)
+		return &EscalationsConfig{Policies: []EscalationPolicy{p}}
+	}
+	approvals := &ApprovalsConfig{SigningSecret: "slack-secret"}
+	tests := []struct {
+		config *EscalationsConfig
+		valid  bool
+	}{
+		{nil, true},
+		{policy(func(p *EscalationPolicy) {}), true},
+		{policy(func(p *EscalationPolicy) { p.Events = []string{"[domain"} }), false},
+		{policy(func(p *EscalationPolicy) { p.MinSeverity = "urgent" }), false},
+		{policy(func(p *EscalationPolicy) { p.Destination = "pager" }), false},
+		{policy(func(p *EscalationPolicy) { p.Escalation = nil }), false},
+		{policy(func(p *EscalationPolicy) { p.Escalation = []string{"unknown"} }), false},
+		{policy(func(p *EscalationPolicy) { p.Delay = -1 }), false},
+	}
+	for _, test := range tests {
+		err := test.config.validate(destinations, approvals)
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+
+	if err := policy(func(p *EscalationPolicy) {}).validate(destinations, nil); err == nil {
+		t.Errorf("validate without approvals expected error")
+	}
+}
@@ -0,0 +1,112 @@
+package strillone
+
+import (
+	"encoding/json"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// EventNames are the names of the events that DNSimple sends, and Strillone formats.
+var EventNames = []string{
+	"account.billing_settings_update", "account.update", "account.user_invitation_accept", "account.user_invitation_revoke", "account.user_invite", "account.user_remove",
+	"certificate.auto_renewal_disable", "certificate.auto_renewal_enable", "certificate.auto_renewal_failed", "certificate.issue", "certificate.reissue", "certificate.remove_private_key",
+	"contact.create", "contact.delete", "contact.update",
+	"dnssec.create", "dnssec.delete", "dnssec.rotation_complete", "dnssec.rotation_start",
+	"domain.auto_renewal_disable", "domain.auto_renewal_enable", "domain.create", "domain.delegation_change", "domain.delete", "domain.register", "domain.registrant_change", "domain.renew", "domain.resolution_disable", "domain.resolution_enable", "domain.token_reset", "domain.transfer", "domain.transfer_lock_disable", "domain.transfer_lock_enable",
+	"email_forward.create", "email_forward.delete", "email_forward.update",
+	"name_server.deregister", "name_server.register",
+	"push.accept", "push.initiate", "push.reject",
+	"subscription.migrate", "subscription.renew", "subscription.subscribe", "subscription.unsubscribe",
+	"webhook.create", "webhook.delete",
+	"whois_privacy.disable", "whois_privacy.enable", "whois_privacy.purchase", "whois_privacy.renew",
+	"zone.create", "zone.delete",
+	"zone_record.create", "zone_record.delete", "zone_record.update",
+}
+
+// PushEventData represents the data of the push.* events, sent when a domain moves to another account.
+type PushEventData struct {
+	Push   *dnsimple.DomainPush `json:"push"`
+	Domain *dnsimple.Domain     `json:"domain"`
+}
+
+// SubscriptionEventData represents the data of the subscription.* events.
+type SubscriptionEventData struct {
+	Subscription *Subscription `json:"subscription"`
+}
+
+// Subscription represents the subscription of an account to a DNSimple plan.
+type Subscription struct {
+	ID       int64  `json:"id,omitempty"`
+	PlanName string `json:"plan_name,omitempty"`
+	State    string `json:"state,omitempty"`
+}
+
+// NameServerEventData represents the data of the name_server.* events, about the vanity name servers.
+type NameServerEventData struct {
+	NameServer *dnsimple.VanityNameServer `json:"name_server"`
+}
+
+// catalogEventData are the types of the data of the events that the webhook package parses
+// as generic events, by event name.
+var catalogEventData = map[string]func() interface{}{
+	"certificate.auto_renewal_disable": func() interface{} { return &webhook.CertificateEventData{} },
+	"certificate.auto_renewal_enable":  func() interface{} { return &webhook.CertificateEventData{} },
+	"certificate.auto_renewal_failed":  func() interface{} { return &webhook.CertificateEventData{} },
+	"certificate.reissue":              func() interface{} { return &webhook.CertificateEventData{} },
+
+	"domain.transfer_lock_disable": func() interface{} { return &webhook.DomainEventData{} },
+	"domain.transfer_lock_enable":  func() interface{} { return &webhook.DomainEventData{} },
+
+	"name_server.deregister": func() interface{} { return &NameServerEventData{} },
+	"name_server.register":   func() interface{} { return &NameServerEventData{} },
+
+	"push.accept":   func() interface{} { return &PushEventData{} },
+	"push.initiate": func() interface{} { return &PushEventData{} },
+	"push.reject":   func() interface{} { return &PushEventData{} },
+
+	"subscription.migrate":     func() interface{} { return &SubscriptionEventData{} },
+	"subscription.renew":       func() interface{} { return &SubscriptionEventData{} },
+	"subscription.subscribe":   func() interface{} { return &SubscriptionEventData{} },
+	"subscription.unsubscribe": func() interface{} { return &SubscriptionEventData{} },
+}
+
+// eventData returns the typed data of the event. It completes the webhook package,
+// that parses the events missing from its catalog as generic events,
+// and adds the previous version of the records to the zone_record.* events.
+func eventData(e *webhook.Event) interface{} {
+	data := catalogData(e)
+	if certificate, ok := data.(*webhook.CertificateEventData); ok && certificate.Certificate != nil {
+		return &CertificateEvent{Certificate: certificate.Certificate}
+	}
+	return data
+}
+
+// catalogData returns the data of the event, typed through the catalog when the webhook package
+// parses it as a generic event.
+func catalogData(e *webhook.Event) interface{} {
+	data := e.GetData()
+	if _, ok := data.(*webhook.ZoneRecordEventData); ok {
+		if typed := parseZoneRecordEvent(e); typed != nil {
+			typed.Previous = detailsOf(e).PreviousZoneRecord
+			return typed
+		}
+		return data
+	}
+	if _, generic := data.(*webhook.GenericEventData); !generic {
+		return data
+	}
+	newData, ok := catalogEventData[e.Name]
+	if !ok {
+		return data
+	}
+
+	typed := newData()
+	container := struct {
+		Data interface{} `json:"data"`
+	}{Data: typed}
+	if err := json.Unmarshal(e.GetPayload(), &container); err != nil {
+		return data
+	}
+	return typed
+}
@@ -0,0 +1,85 @@
+package strillone
+
+import (
+	"fmt"
+)
+
+// exampleData are the data of the example payloads, by event family, in the format of the webhooks
+// DNSimple sends. The templates are validated against them.
+var exampleData = map[string]string{
+	"account": `{
+		"account": {"id": 1010, "email": "ops@example.com", "plan_identifier": "teams-v1-monthly", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"},
+		"user": {"id": 21, "email": "john@example.com", "created_at": "2020-09-01T10:20:00Z", "updated_at": "2021-03-01T11:58:12Z"},
+		"account_invitation": {"id": 3523, "email": "john@example.com", "account_id": 1010, "token": "eb5763dc-0f24-420b-b7f6-c7355c8b8309", "invitation_sent_at": "2021-03-01T11:58:12Z", "invitation_accepted_at": null, "created_at": "2021-03-01T11:58:12Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"certificate": `{
+		"certificate": {"id": 101967, "domain_id": 289333, "contact_id": 2511, "name": "www", "common_name": "www.example.com", "alternate_names": ["example.com", "www.example.com"], "years": 1, "csr": null, "state": "issued", "authority_identifier": "letsencrypt", "auto_renew": true, "created_at": "2021-03-01T11:46:13Z", "updated_at": "2021-03-01T11:58:12Z", "expires_at": "2021-05-30T11:58:12Z"}
+	}`,
+	"contact": `{
+		"contact": {"id": 2511, "account_id": 1010, "label": "Main", "first_name": "Jane", "last_name": "Doe", "job_title": "Operations", "organization_name": "Example Inc.", "email": "jane@example.com", "phone": "+1.5555555555", "fax": "", "address1": "1 Main Street", "address2": "", "city": "Jacksonville", "state_province": "FL", "postal_code": "32202", "country": "US", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"dnssec": `{
+		"domain": {"id": 289333, "account_id": 1010, "registrant_id": 2511, "name": "example.com", "unicode_name": "example.com", "state": "registered", "auto_renew": true, "private_whois": false, "expires_at": "2022-06-10T09:12:40Z", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"},
+		"delegation_signer_record": {"id": 4157, "domain_id": 289333, "algorithm": "13", "digest": "EF1D343203E03F1C98120646971F7B96806B759B66622F0A224551DA1A1EFC9A", "digest_type": "2", "keytag": "2371", "public_key": null, "created_at": "2021-03-01T11:58:12Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"domain": `{
+		"domain": {"id": 289333, "account_id": 1010, "registrant_id": 2511, "name": "example.com", "unicode_name": "example.com", "state": "registered", "auto_renew": true, "private_whois": false, "expires_at": "2022-06-10T09:12:40Z", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"email_forward": `{
+		"email_forward": {"id": 24809, "domain_id": 289333, "from": "hello@example.com", "to": "jane@example.org", "created_at": "2021-03-01T11:58:12Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"name_server": `{
+		"name_server": {"id": 812, "name": "ns1.example.com", "ipv4": "192.0.2.53", "ipv6": "2001:db8::53", "created_at": "2021-03-01T11:58:12Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"push": `{
+		"domain": {"id": 289333, "account_id": 1010, "registrant_id": 2511, "name": "example.com", "unicode_name": "example.com", "state": "registered", "auto_renew": true, "private_whois": false, "expires_at": "2022-06-10T09:12:40Z", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"},
+		"push": {"id": 6789, "domain_id": 289333, "contact_id": null, "account_id": 2020, "created_at": "2021-03-01T11:58:12Z", "updated_at": "2021-03-01T11:58:12Z", "accepted_at": null}
+	}`,
+	"subscription": `{
+		"subscription": {"id": 11101, "plan_name": "Teams", "state": "subscribed", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"webhook": `{
+		"webhook": {"id": 17396, "url": "https://hooks.example.com/dnsimple"}
+	}`,
+	"whois_privacy": `{
+		"domain": {"id": 289333, "account_id": 1010, "registrant_id": 2511, "name": "example.com", "unicode_name": "example.com", "state": "registered", "auto_renew": true, "private_whois": true, "expires_at": "2022-06-10T09:12:40Z", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"},
+		"whois_privacy": {"id": 902, "domain_id": 289333, "expires_on": "2022-03-01", "enabled": true, "created_at": "2021-03-01T11:58:12Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"zone": `{
+		"zone": {"id": 289333, "account_id": 1010, "name": "example.com", "reverse": false, "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+	"zone_record": `{
+		"zone_record": {"id": 64784, "zone_id": "example.com", "parent_id": null, "name": "www", "content": "192.0.2.1", "ttl": 3600, "priority": null, "type": "A", "regions": ["global"], "system_record": false, "created_at": "2021-03-01T11:58:12Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+}
+
+// exampleEventData override the data of the family for the events with more resources.
+var exampleEventData = map[string]string{
+	"domain.delegation_change": `{
+		"domain": {"id": 289333, "account_id": 1010, "registrant_id": 2511, "name": "example.com", "unicode_name": "example.com", "state": "registered", "auto_renew": true, "private_whois": false, "expires_at": "2022-06-10T09:12:40Z", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"},
+		"name_servers": ["ns1.dnsimple.com", "ns2.dnsimple-edge.net", "ns3.dnsimple.com", "ns4.dnsimple-edge.org"]
+	}`,
+	"domain.registrant_change": `{
+		"domain": {"id": 289333, "account_id": 1010, "registrant_id": 2512, "name": "example.com", "unicode_name": "example.com", "state": "registered", "auto_renew": true, "private_whois": false, "expires_at": "2022-06-10T09:12:40Z", "created_at": "2020-06-10T09:12:40Z", "updated_at": "2021-03-01T11:58:12Z"},
+		"registrant": {"id": 2512, "account_id": 1010, "label": "Legal", "first_name": "Jane", "last_name": "Doe", "organization_name": "Example Inc.", "email": "legal@example.com", "phone": "+1.5555555555", "address1": "1 Main Street", "city": "Jacksonville", "state_province": "FL", "postal_code": "32202", "country": "US", "created_at": "2021-03-01T11:58:12Z", "updated_at": "2021-03-01T11:58:12Z"}
+	}`,
+}
+
+// examplePayload returns the example payload of the event, nil if the event is unknown.
+func examplePayload(name string) []byte {
+	if !knownEvents[name] {
+		return nil
+	}
+	data, ok := exampleEventData[name]
+	if !ok {
+		data = exampleData[eventFamily(name)]
+	}
+	return []byte(fmt.Sprintf(`{
+		"name": %q,
+		"api_version": "v2",
+		"request_identifier": "example-%s",
+		"data": %s,
+		"account": {"id": 1010, "display": "Example", "identifier": "example"},
+		"actor": {"id": "1120", "entity": "user", "pretty": "jane@example.com"}
+	}`, name, name, data))
+}
@@ -0,0 +1,139 @@
+package strillone
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// HistoryExportFormats are the formats of the exports of the history.
+var HistoryExportFormats = []string{"csv", "ndjson"}
+
+// historyCSVHeader are the columns of the CSV exports.
+var historyCSVHeader = []string{"received_at", "tenant", "event", "request_identifier", "account_id", "domain", "actor", "message", "deliveries"}
+
+// ParseHistorySearch returns the filter of the events matching the search, such as
+// `type:zone_record.* since:2021-07-01 until:2021-10-01 NS`, without limit.
+func ParseHistorySearch(search string) (*HistoryFilter, error) {
+	filter := &HistoryFilter{}
+	if err := parseHistorySearch(search, time.Now(), filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// ExportHistory writes the events of the history matching the filter, newest first, in the format:
+// csv, with a row per event and its message and deliveries, or ndjson, with an event per line.
+func ExportHistory(w io.Writer, history History, filter *HistoryFilter, format string) error {
+	if !isHistoryExportFormat(format) {
+		return fmt.Errorf("invalid format %q: must be one of %s", format, strings.Join(HistoryExportFormats, ", "))
+	}
+	events, err := history.Query(filter)
+	if err != nil {
+		return err
+	}
+	return writeHistoryExport(w, events, format)
+}
+
+func writeHistoryExport(w io.Writer, events []*HistoryEvent, format string) error {
+	if format == "ndjson" {
+		encoder := json.NewEncoder(w)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(historyCSVHeader); err != nil {
+		return err
+	}
+	for _, event := range events {
+		var accountID string
+		if event.AccountID != 0 {
+			accountID = strconv.FormatInt(event.AccountID, 10)
+		}
+		deliveries := make([]string, 0, len(event.Deliveries))
+		for _, attempt := range event.Deliveries {
+			deliveries = append(deliveries, attempt.Destination+":"+attempt.Status)
+		}
+		err := writer.Write([]string{
+			event.ReceivedAt.UTC().Format(time.RFC3339),
+			event.Tenant,
+			event.Name,
+			event.RequestID,
+			accountID,
+			event.Domain,
+			historyActor(event),
+			dashboardMessage(event.Payload),
+			strings.Join(deliveries, " "),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func isHistoryExportFormat(format string) bool {
+	for _, f := range HistoryExportFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// APIExportEvents exports the recorded events in the format query parameter, csv by default,
+// filtered like /api/events. All the matching events are exported, unless limit is set.
+func (s *Server) APIExportEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if s.history == nil {
+		writeJSONError(w, http.StatusNotFound, "history not enabled")
+		return
+	}
+
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		filter.Limit = 0
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if !isHistoryExportFormat(format) {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid format %q: must be one of %s", format, strings.Join(HistoryExportFormats, ", ")))
+		return
+	}
+
+	events, err := s.history.Query(filter)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == "ndjson" {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="events.%s"`, format))
+	if err := writeHistoryExport(w, events, format); err != nil {
+		// the headers are sent, the export is truncated
+		log.Error().Err(err).Msg("Error exporting the history")
+	}
+}
@@ -0,0 +1,115 @@
+package strillone
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportHistory(t *testing.T) {
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	now := time.Date(2021, 8, 10, 12, 0, 0, 0, time.UTC)
+	events := []*HistoryEvent{
+		{Name: "zone_record.update", RequestID: "1", AccountID: 1010, Domain: "example.com", ReceivedAt: now.Add(-time.Hour),
+			Payload:    json.RawMessage(`{"name": "zone_record.update", "data": {"zone_record": {"id": 1, "zone_id": "example.com", "type": "NS", "name": "eu", "content": "ns1.dnsimple.com"}}, "actor": {"pretty": "jane@example.com"}, "account": {"id": 1010, "display": "User"}}`),
+			Deliveries: []*DeliveryAttempt{{Destination: "ops", Status: "delivered"}, {Destination: "dev", Status: "failed"}}},
+		{Name: "domain.create", RequestID: "2", AccountID: 1010, Domain: "example.org", ReceivedAt: now,
+			Payload: json.RawMessage(`{"name": "domain.create", "data": {"domain": {"id": 1, "name": "example.org"}}, "actor": {"pretty": "john@example.com"}, "account": {"id": 1010, "display": "User"}}`)},
+	}
+	for _, event := range events {
+		if err := history.Record(event); err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+	}
+
+	filter, err := ParseHistorySearch("type:zone_record.* since:2021-07-01 until:2021-10-01 ns")
+	if err != nil {
+		t.Fatalf("ParseHistorySearch returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := ExportHistory(&buf, history, filter, "csv"); err != nil {
+		t.Fatalf("ExportHistory returned error: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("ExportHistory returned invalid CSV: %v", err)
+	}
+	if want, got := 2, len(rows); want != got {
+		t.Fatalf("ExportHistory expected %v rows, got %v", want, got)
+	}
+	if want, got := strings.Join(historyCSVHeader, ","), strings.Join(rows[0], ","); want != got {
+		t.Errorf("ExportHistory expected header %v, got %v", want, got)
+	}
+	expected := []string{"2021-08-10T11:00:00Z", "", "zone_record.update", "1", "1010", "example.com", "jane@example.com", "[User] jane@example.com updated the record NS eu.example.com ns1.dnsimple.com", "ops:delivered dev:failed"}
+	if want, got := strings.Join(expected, "|"), strings.Join(rows[1], "|"); want != got {
+		t.Errorf("ExportHistory expected row\n%v\ngot\n%v", want, got)
+	}
+
+	buf.Reset()
+	if err := ExportHistory(&buf, history, &HistoryFilter{}, "ndjson"); err != nil {
+		t.Fatalf("ExportHistory returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if want, got := 2, len(lines); want != got {
+		t.Fatalf("ExportHistory expected %v lines, got %v", want, got)
+	}
+	event := &HistoryEvent{}
+	if err := json.Unmarshal([]byte(lines[0]), event); err != nil {
+		t.Fatalf("ExportHistory returned invalid JSON: %v", err)
+	}
+	if want, got := "2", event.RequestID; want != got {
+		t.Errorf("ExportHistory expected event %v first, got %v", want, got)
+	}
+
+	if err := ExportHistory(&buf, history, &HistoryFilter{}, "xml"); err == nil {
+		t.Errorf("ExportHistory with an invalid format expected error")
+	}
+}
+
+func TestServer_APIExportEvents(t *testing.T) {
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	server, _ := newAdminTestServer()
+	server.SetHistory(history)
+	for i := 0; i < defaultHistoryLimit+1; i++ {
+		history.Record(&HistoryEvent{Name: "domain.create", ReceivedAt: time.Now(), Payload: json.RawMessage(`{}`)})
+	}
+
+	response := adminRequest(server, "GET", "/api/events/export?format=ndjson", "")
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("GET /api/events/export expected HTTP %v, got %v", want, got)
+	}
+	if want, got := "application/x-ndjson", response.Header().Get("Content-Type"); want != got {
+		t.Errorf("GET /api/events/export expected content type %v, got %v", want, got)
+	}
+	if want, got := defaultHistoryLimit+1, strings.Count(response.Body.String(), "\n"); want != got {
+		t.Errorf("GET /api/events/export expected %v events, got %v", want, got)
+	}
+
+	response = adminRequest(server, "GET", "/api/events/export?limit=2", "")
+	if want, got := `attachment; filename="events.csv"`, response.Header().Get("Content-Disposition"); want != got {
+		t.Errorf("GET /api/events/export expected disposition %v, got %v", want, got)
+	}
+	if want, got := 3, strings.Count(response.Body.String(), "\n"); want != got {
+		t.Errorf("GET /api/events/export?limit=2 expected %v lines, got %v", want, got)
+	}
+
+	if want, got := http.StatusBadRequest, adminRequest(server, "GET", "/api/events/export?format=xml", "").Code; want != got {
+		t.Errorf("GET /api/events/export with invalid format expected HTTP %v, got %v", want, got)
+	}
+
+	request, _ := http.NewRequest("GET", "/api/events/export", nil)
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusUnauthorized, response.Code; want != got {
+		t.Errorf("GET /api/events/export without token expected HTTP %v, got %v", want, got)
+	}
+}
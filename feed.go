@@ -0,0 +1,267 @@
+package strillone
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultFeedLimit is the number of events of a feed.
+	defaultFeedLimit = 50
+
+	// defaultFeedTitle is the title of a feed.
+	defaultFeedTitle = "DNSimple events"
+
+	// feedScanPages is the number of pages of the history scanned to fill a feed whose events are filtered.
+	feedScanPages = 10
+)
+
+// FeedConfig exposes the recent events as an Atom feed, for the feed readers and the internal portals.
+// The events are read from the history.
+type FeedConfig struct {
+	// Token authenticates the readers of the feed, as a bearer token or in the token query parameter.
+	Token string `json:"token"`
+
+	// Title is the title of the feed. Defaults to "DNSimple events".
+	Title string `json:"title,omitempty"`
+
+	// Events are the patterns of the events in the feed, using the path.Match syntax. Defaults to all the events.
+	Events []string `json:"events,omitempty"`
+
+	// Limit is the number of events in the feed, newest first. Defaults to 50.
+	Limit int `json:"limit,omitempty"`
+}
+
+func (c *FeedConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Token == "" {
+		return fmt.Errorf("feed: missing token")
+	}
+	for _, pattern := range c.Events {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("feed: invalid event pattern %q", pattern)
+		}
+	}
+	if c.Limit < 0 || c.Limit > maxHistoryLimit {
+		return fmt.Errorf("feed: limit must be between 1 and %d, or 0 for the default", maxHistoryLimit)
+	}
+	return nil
+}
+
+func (c *FeedConfig) limit() int {
+	if c.Limit == 0 {
+		return defaultFeedLimit
+	}
+	return c.Limit
+}
+
+func (c *FeedConfig) title() string {
+	if c.Title == "" {
+		return defaultFeedTitle
+	}
+	return c.Title
+}
+
+// atomFeed is an Atom feed, as specified in RFC 4287.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// Feed returns the recent events of the tenant as an Atom feed, filtered by the same search as /api/events.
+func (s *Server) Feed(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Don't log the request URI, as it may contain the feed token.
+	log.Info().Str("method", r.Method).Str("path", r.URL.Path).Msg("Request")
+
+	routing := s.currentRouting()
+	keys := routing.adminKeys
+	routing = routing.tenantByName(params.ByName("tenant"))
+	if routing == nil || routing.config.Feed == nil || s.history == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !routing.feedAllows(r, keys) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="strillone"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filter := &HistoryFilter{}
+	if err := parseHistorySearch(r.URL.Query().Get("q"), time.Now(), filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events, err := s.feedEvents(routing, filter)
+	if err != nil {
+		log.Error().Err(err).Str("tenant", routing.name).Msg("Error querying the events of the feed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(newAtomFeed(routing, r, events, time.Now())); err != nil {
+		log.Error().Err(err).Msg("Error rendering the feed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// feedAllows returns true if the request has the feed token of the tenant, or an API key with the viewer role.
+func (t *routingTable) feedAllows(r *http.Request, keys []*adminKey) bool {
+	credential := bearerToken(r)
+	if credential == "" {
+		credential = r.URL.Query().Get("token")
+	}
+	if credential == "" {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(credential), []byte(t.feedToken)) == 1 {
+		return true
+	}
+	key := authenticate(keys, credential)
+	return key != nil && key.allows(RoleViewer)
+}
+
+// feedEvents returns the newest events of the tenant matching the feed and the filter.
+// The history doesn't filter the events of the top-level configuration, nor by several patterns,
+// so it is scanned by pages until the feed is full.
+func (s *Server) feedEvents(routing *routingTable, filter *HistoryFilter) ([]*HistoryEvent, error) {
+	config := routing.config.Feed
+	filter.Tenant = routing.name
+	filter.Limit = config.limit()
+
+	var events []*HistoryEvent
+	for page := 0; page < feedScanPages; page++ {
+		found, err := s.history.Query(filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range found {
+			if event.Tenant != routing.name || (len(config.Events) > 0 && !matchesAny(config.Events, event.Name)) {
+				continue
+			}
+			events = append(events, event)
+			if len(events) == config.limit() {
+				return events, nil
+			}
+		}
+		if len(found) < filter.Limit {
+			break
+		}
+		filter.Until = found[len(found)-1].ReceivedAt
+	}
+	return events, nil
+}
+
+// newAtomFeed returns the feed of the events of the tenant.
+func newAtomFeed(routing *routingTable, r *http.Request, events []*HistoryEvent, now time.Time) *atomFeed {
+	config := routing.config.Feed
+	self := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		self.Scheme = "https"
+	}
+	if q := r.URL.Query().Get("q"); q != "" {
+		self.RawQuery = url.Values{"q": {q}}.Encode()
+	}
+
+	feed := &atomFeed{
+		ID:      "urn:strillone:feed:" + url.PathEscape(routing.name),
+		Title:   config.title(),
+		Updated: now.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: Program},
+		Links:   []atomLink{{Rel: "self", Href: self.String()}},
+	}
+	if len(events) > 0 {
+		feed.Updated = events[0].ReceivedAt.UTC().Format(time.RFC3339)
+	}
+	for _, event := range events {
+		feed.Entries = append(feed.Entries, newAtomEntry(routing, event))
+	}
+	return feed
+}
+
+// newAtomEntry returns the entry of the event, with its message as the title,
+// and its details as the content. The personal data is masked like in the messages.
+func newAtomEntry(routing *routingTable, entry *HistoryEvent) atomEntry {
+	title := entry.Name
+	if event, err := webhook.ParseEvent(entry.Payload); err == nil {
+		if text, err := formatMessage(textRenderer{}, routing.redaction.redact(event)); err == nil {
+			title = text
+		}
+	}
+
+	content := "Event: " + entry.Name
+	if entry.AccountID != 0 {
+		content += "\nAccount: " + strconv.FormatInt(entry.AccountID, 10)
+	}
+	if entry.Domain != "" {
+		content += "\nDomain: " + entry.Domain
+	}
+	actor := historyActor(entry)
+	if actor != "" {
+		content += "\nActor: " + actor
+	}
+	content += "\nRequest: " + entry.RequestID
+
+	atom := atomEntry{
+		ID:         "urn:strillone:event:" + url.PathEscape(entry.Tenant) + ":" + url.PathEscape(entry.RequestID),
+		Title:      title,
+		Updated:    entry.ReceivedAt.UTC().Format(time.RFC3339),
+		Categories: []atomCategory{{Term: entry.Name}},
+		Content:    atomContent{Type: "text", Text: content},
+	}
+	if actor != "" {
+		atom.Author = &atomAuthor{Name: actor}
+	}
+	return atom
+}
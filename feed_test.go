@@ -0,0 +1,150 @@
+package strillone
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_Feed(t *testing.T) {
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	config, err := ParseConfig([]byte(`{
+		"admin": {"keys": [{"name": "portal", "token": "viewer-token", "role": "viewer"}]},
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [],
+		"feed": {"token": "feed-token", "title": "DNS changes", "events": ["zone_record.*", "contact.*"]},
+		"tenants": [
+			{"name": "team-a", "token": "tenant-a-inbound-token", "destinations": [], "routes": [], "feed": {"token": "team-a-feed-token"}},
+			{"name": "team-b", "token": "tenant-b-inbound-token", "destinations": [], "routes": []}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetHistory(history)
+
+	payload := `{"name": "%s", "request_identifier": "b9d5e8f0-feed-000000000%d", "data": %s, "actor": {"pretty": "jane@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	events := []struct {
+		path, name, data string
+	}{
+		{"/events", "zone_record.create", `{"zone_record": {"id": 1, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}`},
+		{"/events", "domain.create", `{"domain": {"id": 1, "name": "example.com"}}`},
+		{"/events", "contact.update", `{"contact": {"id": 2, "label": "Main", "email": "jane@example.com", "phone": "+1.5555555555"}}`},
+		{"/t/tenant-a-inbound-token/events", "domain.create", `{"domain": {"id": 2, "name": "example.org"}}`},
+	}
+	for i, event := range events {
+		request, _ := http.NewRequest("POST", event.path, strings.NewReader(fmt.Sprintf(payload, event.name, i, event.data)))
+		server.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	get := func(path, token string) (*httptest.ResponseRecorder, *atomFeed) {
+		request, _ := http.NewRequest("GET", path, nil)
+		request.Host = "strillone.example.com"
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		feed := &atomFeed{}
+		if response.Code == http.StatusOK {
+			if err := xml.Unmarshal(response.Body.Bytes(), feed); err != nil {
+				t.Fatalf("GET %v returned an invalid feed: %v", path, err)
+			}
+		}
+		return response, feed
+	}
+
+	tests := []struct {
+		path, token string
+		status      int
+	}{
+		{"/feed", "", http.StatusUnauthorized},
+		{"/feed", "team-a-feed-token", http.StatusUnauthorized},
+		{"/feed/team-a", "feed-token", http.StatusUnauthorized},
+		{"/feed/team-b", "viewer-token", http.StatusNotFound},
+		{"/feed/team-c", "viewer-token", http.StatusNotFound},
+		{"/feed?q=since:yesterday-ish", "feed-token", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if response, _ := get(test.path, test.token); test.status != response.Code {
+			t.Errorf("GET %v expected HTTP %v, got %v", test.path, test.status, response.Code)
+		}
+	}
+
+	response, feed := get("/feed?token=feed-token", "")
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("GET /feed expected HTTP %v, got %v", want, got)
+	}
+	if want, got := "application/atom+xml; charset=utf-8", response.Header().Get("Content-Type"); want != got {
+		t.Errorf("GET /feed expected Content-Type %v, got %v", want, got)
+	}
+	if want, got := "DNS changes", feed.Title; want != got {
+		t.Errorf("GET /feed expected title %v, got %v", want, got)
+	}
+	if want, got := "http://strillone.example.com/feed", feed.Links[0].Href; want != got {
+		t.Errorf("GET /feed expected the self link %v without the token, got %v", want, got)
+	}
+	if want, got := 2, len(feed.Entries); want != got {
+		t.Fatalf("GET /feed expected %v entries, got %+v", want, feed.Entries)
+	}
+	entry := feed.Entries[0]
+	if want, got := "contact.update", entry.Categories[0].Term; want != got {
+		t.Errorf("GET /feed expected the newest event %v first, got %v", want, got)
+	}
+	if strings.Contains(entry.Title, "5555") {
+		t.Errorf("GET /feed expected the personal data to be masked, got %v", entry.Title)
+	}
+	if want, got := "urn:strillone:event::b9d5e8f0-feed-0000000002", entry.ID; want != got {
+		t.Errorf("GET /feed expected id %v, got %v", want, got)
+	}
+	if want, got := "jane@example.com", entry.Author.Name; want != got {
+		t.Errorf("GET /feed expected author %v, got %v", want, got)
+	}
+	if want, got := "Event: zone_record.create\nAccount: 1010\nDomain: example.com\nActor: jane@example.com\nRequest: b9d5e8f0-feed-0000000000", feed.Entries[1].Content.Text; want != got {
+		t.Errorf("GET /feed expected content %q, got %q", want, got)
+	}
+
+	_, feed = get("/feed?q=type:zone_record.*", "viewer-token")
+	if want, got := 1, len(feed.Entries); want != got {
+		t.Errorf("GET /feed with a search expected %v entries, got %+v", want, feed.Entries)
+	}
+
+	_, feed = get("/feed/team-a", "team-a-feed-token")
+	if want, got := 1, len(feed.Entries); want != got {
+		t.Fatalf("GET /feed/team-a expected %v entries, got %+v", want, feed.Entries)
+	}
+	if want, got := "DNSimple events", feed.Title; want != got {
+		t.Errorf("GET /feed/team-a expected title %v, got %v", want, got)
+	}
+	if want, got := "urn:strillone:event:team-a:b9d5e8f0-feed-0000000003", feed.Entries[0].ID; want != got {
+		t.Errorf("GET /feed/team-a expected id %v, got %v", want, got)
+	}
+}
+
+func TestFeedConfig_validate(t *testing.T) {
+	tests := []struct {
+		config *FeedConfig
+		valid  bool
+	}{
+		{nil, true},
+		{&FeedConfig{Token: "secret", Events: []string{"domain.*"}, Limit: 100}, true},
+		{&FeedConfig{}, false},
+		{&FeedConfig{Token: "secret", Events: []string{"[domain"}}, false},
+		{&FeedConfig{Token: "secret", Limit: maxHistoryLimit + 1}, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate()
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package strillone
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcpMetadataURL is the metadata server of Google Cloud, providing the tokens of the service account.
+const gcpMetadataURL = "http://metadata.google.internal/computeMetadata/v1"
+
+// GCPCredentials provides the access tokens of the service account of the Cloud Function,
+// or of the Cloud Run service, from the metadata server.
+type GCPCredentials struct {
+	// Project is the project ID, used by the resources without project.
+	Project string
+
+	// MetadataURL overrides the metadata server, mostly useful for testing.
+	MetadataURL string
+	HTTPClient  *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// GCPCredentialsFromEnv returns the credentials when running in Google Cloud, detected with
+// the environment variables of Cloud Run (K_SERVICE) and of Cloud Functions (FUNCTION_TARGET).
+// It returns nil otherwise. The project is read from GOOGLE_CLOUD_PROJECT, or GCP_PROJECT.
+func GCPCredentialsFromEnv() *GCPCredentials {
+	if os.Getenv("K_SERVICE") == "" && os.Getenv("FUNCTION_TARGET") == "" {
+		return nil
+	}
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if project == "" {
+		project = os.Getenv("GCP_PROJECT")
+	}
+	return &GCPCredentials{Project: project}
+}
+
+// Token returns an access token, from the cache until a minute before it expires.
+func (c *GCPCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := c.metadata(ctx, "/instance/service-accounts/default/token", &response); err != nil {
+		return "", fmt.Errorf("access token: %v", err)
+	}
+	c.token = response.AccessToken
+	c.expires = time.Now().Add(time.Duration(response.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// project returns the project ID, from the metadata server if not set.
+func (c *GCPCredentials) project(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Project != "" {
+		return c.Project, nil
+	}
+	var project string
+	if err := c.metadata(ctx, "/project/project-id", &project); err != nil {
+		return "", fmt.Errorf("project: %v", err)
+	}
+	c.Project = project
+	return project, nil
+}
+
+// metadata reads the path of the metadata server in value, a string or a JSON response.
+func (c *GCPCredentials) metadata(ctx context.Context, path string, value interface{}) error {
+	base := c.MetadataURL
+	if base == "" {
+		base = gcpMetadataURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, err := doSecretsRequest(c.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	if s, ok := value.(*string); ok {
+		*s = strings.TrimSpace(string(body))
+		return nil
+	}
+	return json.Unmarshal(body, value)
+}
+
+// authorize sets the access token of the request.
+func (c *GCPCredentials) authorize(req *http.Request) error {
+	token, err := c.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// GCPSecretManagerBackend resolves secrets stored in Google Cloud Secret Manager.
+//
+// The path is the name of the secret, such as strillone-slack in the project of the credentials,
+// or projects/my-project/secrets/strillone-slack. The latest version is read, unless the path
+// ends with the version, such as projects/my-project/secrets/strillone-slack/versions/3.
+// When the key is set, the secret is expected to be a JSON object and the value of the key is returned.
+type GCPSecretManagerBackend struct {
+	Credentials *GCPCredentials
+
+	// Endpoint overrides the Secret Manager endpoint, mostly useful for testing.
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// Resolve implements SecretBackend
+func (b *GCPSecretManagerBackend) Resolve(ctx context.Context, path, key string) (string, error) {
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretmanager.googleapis.com"
+	}
+	name := path
+	if !strings.HasPrefix(name, "projects/") {
+		project, err := b.Credentials.project(ctx)
+		if err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("projects/%s/secrets/%s", project, path)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/v1/%s:access", endpoint, name), nil)
+	if err != nil {
+		return "", err
+	}
+	if err := b.Credentials.authorize(req); err != nil {
+		return "", err
+	}
+	body, err := doSecretsRequest(b.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(response.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+	return jsonSecretKey(string(data), key)
+}
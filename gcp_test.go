@@ -0,0 +1,88 @@
+package strillone
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestGCPMetadata returns a metadata server and the credentials using it. The tokens counts the tokens issued.
+func newTestGCPMetadata(t *testing.T) (*GCPCredentials, *int) {
+	tokens := 0
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/instance/service-accounts/default/token":
+			tokens++
+			fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3599, "token_type": "Bearer"}`, tokens)
+		case "/project/project-id":
+			fmt.Fprint(w, "my-project")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(metadata.Close)
+	return &GCPCredentials{MetadataURL: metadata.URL}, &tokens
+}
+
+func TestGCPCredentials_Token(t *testing.T) {
+	credentials, tokens := newTestGCPMetadata(t)
+	for i := 0; i < 2; i++ {
+		token, err := credentials.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token returned error: %v", err)
+		}
+		if want := "token-1"; want != token {
+			t.Errorf("Token expected %v, got %v", want, token)
+		}
+	}
+	if want, got := 1, *tokens; want != got {
+		t.Errorf("Token expected %v tokens issued, got %v", want, got)
+	}
+}
+
+func TestGCPSecretManagerBackend(t *testing.T) {
+	credentials, _ := newTestGCPMetadata(t)
+	secretManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, got := "Bearer token-1", r.Header.Get("Authorization"); want != got {
+			t.Errorf("request expected Authorization %v, got %v", want, got)
+		}
+		switch r.URL.Path {
+		case "/v1/projects/my-project/secrets/strillone-slack/versions/latest:access":
+			data := base64.StdEncoding.EncodeToString([]byte(`{"url": "https://hooks.slack.com/services/X/Y/Z"}`))
+			fmt.Fprintf(w, `{"name": "projects/123/secrets/strillone-slack/versions/2", "payload": {"data": %q}}`, data)
+		case "/v1/projects/other/secrets/strillone-config/versions/3:access":
+			fmt.Fprintf(w, `{"payload": {"data": %q}}`, base64.StdEncoding.EncodeToString([]byte("{}")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer secretManager.Close()
+
+	backend := &GCPSecretManagerBackend{Credentials: credentials, Endpoint: secretManager.URL}
+	got, err := backend.Resolve(context.Background(), "strillone-slack", "url")
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if want := "https://hooks.slack.com/services/X/Y/Z"; want != got {
+		t.Errorf("Resolve expected %v, got %v", want, got)
+	}
+
+	got, err = backend.Resolve(context.Background(), "projects/other/secrets/strillone-config/versions/3", "")
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if want := "{}"; want != got {
+		t.Errorf("Resolve expected %v, got %v", want, got)
+	}
+
+	if _, err := backend.Resolve(context.Background(), "missing", ""); err == nil {
+		t.Errorf("Resolve expected error for a missing secret")
+	}
+}
@@ -6,10 +6,19 @@ module github.com/dnsimple/strillone
 go 1.14
 
 require (
-	github.com/bluele/slack v0.0.0-20180528010058-b4b4d354a079
+	github.com/aws/aws-lambda-go v1.26.0
 	github.com/dnsimple/dnsimple-go v0.70.1
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/lib/pq v1.10.9
+	github.com/miekg/dns v1.1.43
+	github.com/rs/zerolog v1.20.0
 	github.com/wunderlist/ttlcache v0.0.0-20180801091818-7dbceb0d5094
-	golang.org/x/net v0.0.0-20190620200207-3b0461eec859 // indirect
+	go.etcd.io/bbolt v1.3.5
+	go.opentelemetry.io/otel v0.20.0
+	go.opentelemetry.io/otel/exporters/otlp v0.20.0
+	go.opentelemetry.io/otel/sdk v0.20.0
+	go.opentelemetry.io/otel/trace v0.20.0
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 	google.golang.org/appengine v1.6.1 // indirect
 )
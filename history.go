@@ -0,0 +1,410 @@
+package strillone
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+
+	// historyResponseSize is the maximum size of the response snippet of a failed delivery.
+	historyResponseSize = 512
+)
+
+// HistoryEvent represents a received event, and its delivery attempts.
+type HistoryEvent struct {
+	Tenant     string          `json:"tenant"`
+	Name       string          `json:"name"`
+	RequestID  string          `json:"request_identifier"`
+	AccountID  int64           `json:"account_id,omitempty"`
+	Domain     string          `json:"domain,omitempty"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Payload    json.RawMessage `json:"payload"`
+
+	Deliveries []*DeliveryAttempt `json:"deliveries"`
+
+	// key identifies the event in the history that returned it, to remove it.
+	key []byte
+}
+
+// DeliveryAttempt represents the outcome of the delivery of an event to a destination.
+type DeliveryAttempt struct {
+	Destination string    `json:"destination"`
+	Status      string    `json:"status"`
+	LatencyMs   int64     `json:"latency_ms"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Response    string    `json:"response,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// HistoryFilter restricts the events returned by a History.
+type HistoryFilter struct {
+	Tenant string
+
+	// Event is a pattern matched against the event name, using the path.Match syntax.
+	Event string
+
+	Domain string
+	Since  time.Time
+	Until  time.Time
+
+	// Status matches the events with at least a delivery attempt in the status.
+	Status string
+
+	// Actor matches the events whose actor contains the text, case-insensitively.
+	Actor string
+
+	// Keywords match the events containing all of them, case-insensitively, in their name,
+	// domain, request identifier or payload values.
+	Keywords []string
+
+	// Limit is the maximum number of events returned, newest first. All the events are returned when 0.
+	Limit int
+}
+
+// Matches returns true if the event matches the filter.
+func (f *HistoryFilter) Matches(event *HistoryEvent) bool {
+	if f.Tenant != "" && f.Tenant != event.Tenant {
+		return false
+	}
+	if f.Event != "" {
+		if ok, _ := path.Match(f.Event, event.Name); !ok {
+			return false
+		}
+	}
+	if f.Domain != "" && f.Domain != event.Domain {
+		return false
+	}
+	if !f.Since.IsZero() && event.ReceivedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !event.ReceivedAt.Before(f.Until) {
+		return false
+	}
+	if f.Actor != "" && !strings.Contains(strings.ToLower(historyActor(event)), strings.ToLower(f.Actor)) {
+		return false
+	}
+	if len(f.Keywords) > 0 {
+		text := historySearchText(event)
+		for _, keyword := range f.Keywords {
+			if !strings.Contains(text, keyword) {
+				return false
+			}
+		}
+	}
+	if f.Status != "" {
+		for _, attempt := range event.Deliveries {
+			if attempt.Status == f.Status {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// History stores the received events and their delivery attempts.
+type History interface {
+	// Record stores a received event.
+	Record(event *HistoryEvent) error
+
+	// AddAttempt appends a delivery attempt to the event of the tenant with the request identifier.
+	AddAttempt(tenant, requestID string, attempt *DeliveryAttempt) error
+
+	// Query returns the events matching the filter, newest first.
+	Query(filter *HistoryFilter) ([]*HistoryEvent, error)
+
+	// Get returns the event of the tenant with the request identifier.
+	Get(tenant, requestID string) (*HistoryEvent, error)
+
+	Close() error
+}
+
+// ErrEventNotFound is returned when an event is not in the history.
+var ErrEventNotFound = errors.New("event not found")
+
+var (
+	boltHistoryEventsBucket = []byte("events")
+	boltHistoryIndexBucket  = []byte("index")
+)
+
+// BoltHistory is a History stored in a BoltDB file.
+//
+// The events are keyed by the time they were received, and indexed by tenant and request identifier.
+type BoltHistory struct {
+	db  *bolt.DB
+	seq uint32
+}
+
+// OpenBoltHistory opens, or creates, the history stored in the file.
+func OpenBoltHistory(filename string) (*BoltHistory, error) {
+	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltHistoryEventsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltHistoryIndexBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltHistory{db: db}, nil
+}
+
+// Record implements History
+func (h *BoltHistory) Record(event *HistoryEvent) error {
+	// The sequence tells apart the events received in the same nanosecond.
+	key := make([]byte, 12)
+	binary.BigEndian.PutUint64(key, uint64(event.ReceivedAt.UnixNano()))
+	binary.BigEndian.PutUint32(key[8:], atomic.AddUint32(&h.seq, 1))
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return h.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltHistoryEventsBucket).Put(key, data); err != nil {
+			return err
+		}
+		return tx.Bucket(boltHistoryIndexBucket).Put(historyIndexKey(event.Tenant, event.RequestID), key)
+	})
+}
+
+// AddAttempt implements History
+func (h *BoltHistory) AddAttempt(tenant, requestID string, attempt *DeliveryAttempt) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		key := tx.Bucket(boltHistoryIndexBucket).Get(historyIndexKey(tenant, requestID))
+		if key == nil {
+			return ErrEventNotFound
+		}
+		events := tx.Bucket(boltHistoryEventsBucket)
+		event := &HistoryEvent{}
+		if err := json.Unmarshal(events.Get(key), event); err != nil {
+			return err
+		}
+		event.Deliveries = append(event.Deliveries, attempt)
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return events.Put(key, data)
+	})
+}
+
+// Query implements History
+func (h *BoltHistory) Query(filter *HistoryFilter) ([]*HistoryEvent, error) {
+	events := []*HistoryEvent{}
+	err := h.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltHistoryEventsBucket).Cursor()
+		for k, v := cursor.Last(); k != nil && (filter.Limit <= 0 || len(events) < filter.Limit); k, v = cursor.Prev() {
+			event := &HistoryEvent{}
+			if err := json.Unmarshal(v, event); err != nil {
+				return err
+			}
+			if !filter.Since.IsZero() && event.ReceivedAt.Before(filter.Since) {
+				break
+			}
+			if filter.Matches(event) {
+				event.key = append([]byte{}, k...)
+				events = append(events, event)
+			}
+		}
+		return nil
+	})
+	return events, err
+}
+
+// Get implements History
+func (h *BoltHistory) Get(tenant, requestID string) (*HistoryEvent, error) {
+	event := &HistoryEvent{}
+	err := h.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(boltHistoryIndexBucket).Get(historyIndexKey(tenant, requestID))
+		if key == nil {
+			return ErrEventNotFound
+		}
+		return json.Unmarshal(tx.Bucket(boltHistoryEventsBucket).Get(key), event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Close implements History
+func (h *BoltHistory) Close() error {
+	return h.db.Close()
+}
+
+func historyIndexKey(tenant, requestID string) []byte {
+	return []byte(tenant + "/" + requestID)
+}
+
+// SetHistory records the received events and their delivery attempts in the history,
+// and enables the /api/events endpoint and the dashboard.
+func (s *Server) SetHistory(history History) {
+	s.history = history
+}
+
+// recordEvent records the received event in the history.
+func (s *Server) recordEvent(routing *routingTable, event *webhook.Event) {
+	if s.history == nil {
+		return
+	}
+
+	entry := &HistoryEvent{
+		Tenant:     routing.name,
+		Name:       event.Name,
+		RequestID:  event.RequestID,
+		Domain:     eventDomain(event),
+		ReceivedAt: time.Now().UTC(),
+		Payload:    event.GetPayload(),
+		Deliveries: []*DeliveryAttempt{},
+	}
+	if event.Account != nil {
+		entry.AccountID = event.Account.ID
+	}
+	if err := s.history.Record(entry); err != nil {
+		withEvent(log.Error(), event).Err(err).Msg("Error recording the event")
+	}
+}
+
+// recordAttempt records the outcome of the delivery of the event to a destination in the history.
+func (s *Server) recordAttempt(routing *routingTable, name string, event *webhook.Event, status string, start time.Time, err error) {
+	if s.history == nil {
+		return
+	}
+
+	attempt := &DeliveryAttempt{
+		Destination: name,
+		Status:      status,
+		LatencyMs:   time.Since(start).Milliseconds(),
+		At:          time.Now().UTC(),
+	}
+	if err != nil {
+		attempt.Response = err.Error()
+		if len(attempt.Response) > historyResponseSize {
+			attempt.Response = attempt.Response[:historyResponseSize]
+		}
+		var status *statusError
+		if errors.As(err, &status) {
+			attempt.StatusCode = status.StatusCode
+		}
+	}
+	if err := s.history.AddAttempt(routing.name, event.RequestID, attempt); err != nil {
+		withEvent(log.Error(), event).Err(err).Str("destination", name).Msg("Error recording the delivery")
+	}
+}
+
+// deliveryStatus returns the status of a delivery that failed with err.
+func deliveryStatus(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "delivered"
+}
+
+// eventDomain returns the name of the domain, or zone, the event is about.
+func eventDomain(event *webhook.Event) string {
+	var payload struct {
+		Data struct {
+			Domain *struct {
+				Name string `json:"name"`
+			} `json:"domain"`
+			Zone *struct {
+				Name string `json:"name"`
+			} `json:"zone"`
+			ZoneRecord *struct {
+				ZoneID string `json:"zone_id"`
+			} `json:"zone_record"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(event.GetPayload(), &payload); err != nil {
+		return ""
+	}
+	switch {
+	case payload.Data.Domain != nil:
+		return payload.Data.Domain.Name
+	case payload.Data.Zone != nil:
+		return payload.Data.Zone.Name
+	case payload.Data.ZoneRecord != nil:
+		return payload.Data.ZoneRecord.ZoneID
+	}
+	return ""
+}
+
+// APIListEvents returns the recorded events, filtered by the event, tenant, domain,
+// since, until and status query parameters, and by the search in the q query parameter.
+func (s *Server) APIListEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if s.history == nil {
+		writeJSONError(w, http.StatusNotFound, "history not enabled")
+		return
+	}
+
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	events, err := s.history.Query(filter)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func parseHistoryFilter(r *http.Request) (*HistoryFilter, error) {
+	query := r.URL.Query()
+	filter := &HistoryFilter{
+		Tenant: query.Get("tenant"),
+		Event:  query.Get("event"),
+		Domain: query.Get("domain"),
+		Status: query.Get("status"),
+		Limit:  defaultHistoryLimit,
+	}
+	if filter.Event != "" {
+		if _, err := path.Match(filter.Event, ""); err != nil {
+			return nil, fmt.Errorf("invalid event pattern %q", filter.Event)
+		}
+	}
+	for name, value := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if param := query.Get(name); param != "" {
+			t, err := time.Parse(time.RFC3339, param)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", name, err)
+			}
+			*value = t
+		}
+	}
+	if param := query.Get("limit"); param != "" {
+		limit, err := strconv.Atoi(param)
+		if err != nil || limit <= 0 || limit > maxHistoryLimit {
+			return nil, fmt.Errorf("invalid limit: must be between 1 and %d", maxHistoryLimit)
+		}
+		filter.Limit = limit
+	}
+	if err := parseHistorySearch(query.Get("q"), time.Now(), filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
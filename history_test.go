@@ -0,0 +1,152 @@
+package strillone
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestHistory(t *testing.T) (*BoltHistory, func()) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := OpenBoltHistory(filepath.Join(dir, "history.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("OpenBoltHistory returned error: %v", err)
+	}
+	return history, func() {
+		history.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltHistory(t *testing.T) {
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	now := time.Now()
+	events := []*HistoryEvent{
+		{Name: "domain.create", RequestID: "1", Domain: "example.com", ReceivedAt: now.Add(-2 * time.Hour)},
+		{Name: "zone_record.create", RequestID: "2", Domain: "example.com", ReceivedAt: now.Add(-time.Hour)},
+		{Name: "domain.delete", RequestID: "3", Domain: "example.org", ReceivedAt: now},
+	}
+	for _, event := range events {
+		if err := history.Record(event); err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+	}
+	if err := history.AddAttempt("", "2", &DeliveryAttempt{Destination: "ops", Status: "failed"}); err != nil {
+		t.Fatalf("AddAttempt returned error: %v", err)
+	}
+	if err := history.AddAttempt("", "4", &DeliveryAttempt{Destination: "ops", Status: "failed"}); err != ErrEventNotFound {
+		t.Errorf("AddAttempt to an unknown event expected %v, got %v", ErrEventNotFound, err)
+	}
+
+	tests := []struct {
+		filter   HistoryFilter
+		expected []string
+	}{
+		{HistoryFilter{}, []string{"3", "2", "1"}},
+		{HistoryFilter{Event: "domain.*"}, []string{"3", "1"}},
+		{HistoryFilter{Domain: "example.com"}, []string{"2", "1"}},
+		{HistoryFilter{Since: now.Add(-90 * time.Minute)}, []string{"3", "2"}},
+		{HistoryFilter{Until: now.Add(-90 * time.Minute)}, []string{"1"}},
+		{HistoryFilter{Status: "failed"}, []string{"2"}},
+		{HistoryFilter{Limit: 1}, []string{"3"}},
+	}
+	for _, test := range tests {
+		if test.filter.Limit == 0 {
+			test.filter.Limit = defaultHistoryLimit
+		}
+		found, err := history.Query(&test.filter)
+		if err != nil {
+			t.Fatalf("Query returned error: %v", err)
+		}
+		var ids []string
+		for _, event := range found {
+			ids = append(ids, event.RequestID)
+		}
+		if want, got := strings.Join(test.expected, ","), strings.Join(ids, ","); want != got {
+			t.Errorf("Query(%+v) expected %v, got %v", test.filter, want, got)
+		}
+	}
+}
+
+func TestServer_History(t *testing.T) {
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	config, err := ParseConfig([]byte(`{
+		"admin": {"token": "secret"},
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "dev", "type": "slack", "url": "https://hooks.slack.com/services/B", "retry": {"max_attempts": 1}}
+		],
+		"routes": [{"destinations": ["ops", "dev"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetHistory(history)
+	server.routing.services["ops"] = &SlackService{Token: "-"}
+	server.routing.services["dev"] = &failingService{errs: []error{&statusError{StatusCode: 500, message: "internal_error"}}}
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "5c9a2e7f-history-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	server.ServeHTTP(httptest.NewRecorder(), request)
+
+	response := adminRequest(server, "GET", "/api/events?domain=example.com&status=failed", "")
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("GET /api/events expected HTTP %v, got %v", want, got)
+	}
+	var events []*HistoryEvent
+	if err := json.Unmarshal(response.Body.Bytes(), &events); err != nil {
+		t.Fatalf("GET /api/events returned invalid JSON: %v", err)
+	}
+	if want, got := 1, len(events); want != got {
+		t.Fatalf("GET /api/events expected %v events, got %v", want, got)
+	}
+	event := events[0]
+	if want, got := int64(1010), event.AccountID; want != got {
+		t.Errorf("event expected account %v, got %v", want, got)
+	}
+	if want, got := 2, len(event.Deliveries); want != got {
+		t.Fatalf("event expected %v deliveries, got %v", want, got)
+	}
+	statuses := map[string]*DeliveryAttempt{}
+	for _, attempt := range event.Deliveries {
+		statuses[attempt.Destination] = attempt
+	}
+	if want, got := "delivered", statuses["ops"].Status; want != got {
+		t.Errorf("ops delivery expected %v, got %v", want, got)
+	}
+	if want, got := "failed", statuses["dev"].Status; want != got {
+		t.Errorf("dev delivery expected %v, got %v", want, got)
+	}
+	if want, got := 500, statuses["dev"].StatusCode; want != got {
+		t.Errorf("dev delivery expected status code %v, got %v", want, got)
+	}
+	if want, got := "internal_error", statuses["dev"].Response; want != got {
+		t.Errorf("dev delivery expected response %v, got %v", want, got)
+	}
+
+	if want, got := http.StatusBadRequest, adminRequest(server, "GET", "/api/events?since=yesterday", "").Code; want != got {
+		t.Errorf("GET /api/events with invalid since expected HTTP %v, got %v", want, got)
+	}
+}
+
+func TestServer_HistoryDisabled(t *testing.T) {
+	server, _ := newAdminTestServer()
+	if want, got := http.StatusNotFound, adminRequest(server, "GET", "/api/events", "").Code; want != got {
+		t.Errorf("GET /api/events without history expected HTTP %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,226 @@
+package strillone
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// defaultDeliveryTimeout is the timeout of the requests to the destinations.
+	defaultDeliveryTimeout = 10 * time.Second
+
+	// defaultConnectTimeout is the timeout of the connections to the destinations, including the TLS handshake.
+	defaultConnectTimeout = 10 * time.Second
+
+	// directProxy is the proxy of the destinations connecting without proxy, regardless of the environment.
+	directProxy = "direct"
+)
+
+// tlsVersions are the TLS versions accepted in min_version.
+var tlsVersions = map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+// defaultHTTPClient is the HTTP client used for the destinations without specific settings.
+var defaultHTTPClient = &http.Client{Timeout: defaultDeliveryTimeout}
+
+// DestinationTLSConfig represents the TLS settings used to connect to a destination.
+type DestinationTLSConfig struct {
+	// CertFile and KeyFile are the client certificate presented to the destination (mutual TLS).
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// CAFile is a PEM bundle of the certificate authorities trusted to verify the destination,
+	// in place of the system ones. Useful for internal targets.
+	CAFile string `json:"ca_file,omitempty"`
+
+	// MinVersion is the minimum TLS version accepted from the destination, 1.2 or 1.3. Defaults to 1.2.
+	MinVersion string `json:"min_version,omitempty"`
+}
+
+// DestinationHTTPConfig represents the HTTP settings used to connect to a destination.
+type DestinationHTTPConfig struct {
+	// ConnectTimeout is the timeout of the connection, including the TLS handshake. Defaults to 10s.
+	ConnectTimeout Duration `json:"connect_timeout,omitempty"`
+
+	// RequestTimeout is the timeout of a request, including the response. Defaults to 10s.
+	RequestTimeout Duration `json:"request_timeout,omitempty"`
+
+	// Proxy is the URL of the proxy of the destination, such as http://proxy.internal:3128
+	// or socks5://proxy.internal:1080, in place of the proxy of the egress and of the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables. "direct" connects to the destination without proxy.
+	Proxy string `json:"proxy,omitempty"`
+
+	// allowedHosts are the hosts allowed by the egress.
+	allowedHosts []string
+}
+
+func (c *DestinationHTTPConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.ConnectTimeout < 0 || c.RequestTimeout < 0 {
+		return fmt.Errorf("http: timeouts must be positive")
+	}
+	if c.Proxy != "" && c.Proxy != directProxy {
+		if _, err := parseProxyURL(c.Proxy); err != nil {
+			return fmt.Errorf("http: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *DestinationHTTPConfig) connectTimeout() time.Duration {
+	if c == nil || c.ConnectTimeout == 0 {
+		return defaultConnectTimeout
+	}
+	return time.Duration(c.ConnectTimeout)
+}
+
+func (c *DestinationHTTPConfig) requestTimeout() time.Duration {
+	if c == nil || c.RequestTimeout == 0 {
+		return defaultDeliveryTimeout
+	}
+	return time.Duration(c.RequestTimeout)
+}
+
+// proxy returns the proxy function of the transport.
+func (c *DestinationHTTPConfig) proxy() (func(*http.Request) (*url.URL, error), error) {
+	switch {
+	case c == nil || c.Proxy == "":
+		return http.ProxyFromEnvironment, nil
+	case c.Proxy == directProxy:
+		return nil, nil
+	}
+	proxy, err := parseProxyURL(c.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyURL(proxy), nil
+}
+
+// parseProxyURL parses the URL of an HTTP or SOCKS5 proxy.
+func parseProxyURL(value string) (*url.URL, error) {
+	proxy, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %v", err)
+	}
+	if (proxy.Scheme != "http" && proxy.Scheme != "https" && proxy.Scheme != "socks5") || proxy.Host == "" {
+		return nil, fmt.Errorf("invalid proxy: expected an http, https or socks5 URL")
+	}
+	return proxy, nil
+}
+
+// newHTTPClient returns the HTTP client used to deliver the events to a destination.
+func newHTTPClient(config *DestinationTLSConfig, httpConfig *DestinationHTTPConfig) (*http.Client, error) {
+	if config == nil && httpConfig == nil {
+		return defaultHTTPClient, nil
+	}
+
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("tls: %v", err)
+	}
+	proxy, err := httpConfig.proxy()
+	if err != nil {
+		return nil, fmt.Errorf("http: %v", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy = proxy
+	transport.DialContext = (&net.Dialer{Timeout: httpConfig.connectTimeout(), KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = httpConfig.connectTimeout()
+	client := &http.Client{Transport: transport, Timeout: httpConfig.requestTimeout()}
+	if httpConfig != nil && len(httpConfig.allowedHosts) > 0 {
+		client.Transport = &egressTransport{allowed: httpConfig.allowedHosts, transport: transport}
+	}
+	return client, nil
+}
+
+// tlsConfig returns the TLS configuration of the connections to the destination.
+func (c *DestinationTLSConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c == nil {
+		return tlsConfig, nil
+	}
+
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid min version %q", c.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// loadCertPool reads a PEM bundle of certificates.
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %v", filename)
+	}
+	return pool, nil
+}
+
+// postJSON sends the payload as JSON to the URL, until the context is done.
+// It returns a *statusError with the response body if the response status is not 2xx.
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		t, _ := ioutil.ReadAll(resp.Body)
+		message := string(t)
+		if len(t) == 0 {
+			message = resp.Status
+		}
+		return &statusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			message:    message,
+		}
+	}
+	return nil
+}
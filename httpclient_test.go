@@ -0,0 +1,148 @@
+package strillone
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestClientCertificate writes a CA and a client certificate signed by it to dir,
+// and returns the CA pool and the file names of the client certificate and key.
+func writeTestClientCertificate(t *testing.T, dir string) (*x509.CertPool, string, string) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "strillone"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, caCert, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(clientKey)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	return pool, certFile, keyFile
+}
+
+func TestNewHTTPClient_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	clientCAs, certFile, keyFile := writeTestClientCertificate(t, dir)
+
+	target := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	target.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	target.StartTLS()
+	defer target.Close()
+
+	caFile := filepath.Join(dir, "server-ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: target.Certificate().Raw}), 0600)
+
+	client, err := newHTTPClient(&DestinationTLSConfig{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}, nil)
+	if err != nil {
+		t.Fatalf("newHTTPClient returned error: %v", err)
+	}
+	if err := postJSON(context.Background(), client, target.URL, map[string]string{"text": "hello"}); err != nil {
+		t.Errorf("postJSON with client certificate returned error: %v", err)
+	}
+
+	client, err = newHTTPClient(&DestinationTLSConfig{CAFile: caFile}, nil)
+	if err != nil {
+		t.Fatalf("newHTTPClient returned error: %v", err)
+	}
+	if err := postJSON(context.Background(), client, target.URL, map[string]string{"text": "hello"}); err == nil {
+		t.Errorf("postJSON without client certificate expected error")
+	}
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	client, err := newHTTPClient(nil, &DestinationHTTPConfig{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("newHTTPClient returned error: %v", err)
+	}
+	if err := postJSON(context.Background(), client, "http://hooks.example.test/services/A", map[string]string{"text": "hello"}); err != nil {
+		t.Fatalf("postJSON returned error: %v", err)
+	}
+	if want, got := "http://hooks.example.test/services/A", proxied; want != got {
+		t.Errorf("proxy expected request to %v, got %v", want, got)
+	}
+
+	if _, err := newHTTPClient(nil, &DestinationHTTPConfig{Proxy: "ftp://proxy.internal"}); err == nil {
+		t.Errorf("newHTTPClient expected error for an invalid proxy")
+	}
+	if _, err := newHTTPClient(&DestinationTLSConfig{MinVersion: "1.1"}, nil); err == nil {
+		t.Errorf("newHTTPClient expected error for an invalid TLS version")
+	}
+}
+
+func TestNewHTTPClient_RequestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer target.Close()
+	defer close(done)
+
+	client, err := newHTTPClient(nil, &DestinationHTTPConfig{RequestTimeout: Duration(50 * time.Millisecond)})
+	if err != nil {
+		t.Fatalf("newHTTPClient returned error: %v", err)
+	}
+	start := time.Now()
+	if err := postJSON(context.Background(), client, target.URL, map[string]string{"text": "hello"}); err == nil {
+		t.Fatalf("postJSON expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("postJSON expected to time out after the request timeout, took %v", elapsed)
+	}
+}
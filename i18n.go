@@ -0,0 +1,100 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// translationExtension is the extension of the translation catalogs, named after the language (e.g. fr.json).
+const translationExtension = ".json"
+
+// Translator is implemented by the messaging services that format the messages in a language other than English.
+type Translator interface {
+	// Translate returns the translation of the English message, empty if not translated.
+	Translate(message string) string
+}
+
+// translate returns the translation of the message by the service, the message itself otherwise.
+func translate(s Formatter, message string) string {
+	if translator, ok := s.(Translator); ok {
+		if translation := translator.Translate(message); translation != "" {
+			return translation
+		}
+	}
+	return message
+}
+
+// tprintf formats the translation of the format by the service.
+func tprintf(s Formatter, format string, a ...interface{}) string {
+	return fmt.Sprintf(translate(s, format), a...)
+}
+
+// catalogLanguage returns the catalog of the language, nil for English.
+// It returns an error if there is no catalog for the language.
+func catalogLanguage(catalogs map[string]map[string]string, language string) (map[string]string, error) {
+	if language == "" || language == "en" {
+		return nil, nil
+	}
+	catalog, ok := catalogs[language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language %q", language)
+	}
+	return catalog, nil
+}
+
+// LoadTranslations returns the built-in translation catalogs, overridden by the catalogs in the directory,
+// one JSON file per language (e.g. fr.json) mapping the English messages to their translations.
+// It returns an error if a catalog translates an unknown message, or changes its verbs.
+func LoadTranslations(dir string) (map[string]map[string]string, error) {
+	catalogs := make(map[string]map[string]string, len(builtinCatalogs))
+	for language, builtin := range builtinCatalogs {
+		catalog := make(map[string]string, len(builtin))
+		for message, translation := range builtin {
+			catalog[message] = translation
+		}
+		catalogs[language] = catalog
+	}
+	if dir == "" {
+		return catalogs, nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	// The messages are the keys of any built-in catalog, they all translate the same messages.
+	known := builtinCatalogs["fr"]
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != translationExtension {
+			continue
+		}
+		language := strings.TrimSuffix(file.Name(), translationExtension)
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var overrides map[string]string
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name(), err)
+		}
+
+		catalog := catalogs[language]
+		if catalog == nil {
+			catalog = make(map[string]string, len(overrides))
+			catalogs[language] = catalog
+		}
+		for message, translation := range overrides {
+			if _, ok := known[message]; !ok {
+				return nil, fmt.Errorf("%s: unknown message %q", file.Name(), message)
+			}
+			if strings.Count(message, "%s") != strings.Count(translation, "%s") {
+				return nil, fmt.Errorf("%s: the translation of %q must keep its %%s verbs", file.Name(), message)
+			}
+			catalog[message] = translation
+		}
+	}
+	return catalogs, nil
+}
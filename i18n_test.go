@@ -0,0 +1,135 @@
+package strillone
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_builtinCatalogs(t *testing.T) {
+	// Every message translated in the code is in every catalog.
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := regexp.MustCompile(`(?:tprintf|translate)\(s, ("[^"]*")`)
+	messages := map[string]bool{"info": true, "warning": true, "critical": true}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range pattern.FindAllStringSubmatch(string(source), -1) {
+			message, err := strconv.Unquote(match[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages[message] = true
+		}
+	}
+
+	for language, catalog := range builtinCatalogs {
+		for message := range messages {
+			if catalog[message] == "" {
+				t.Errorf("catalog %v is missing %q", language, message)
+			}
+		}
+		for message, translation := range catalog {
+			if !messages[message] {
+				t.Errorf("catalog %v translates the unknown message %q", language, message)
+			}
+			if strings.Count(message, "%s") != strings.Count(translation, "%s") {
+				t.Errorf("catalog %v changes the verbs of %q", language, message)
+			}
+		}
+	}
+}
+
+func TestLoadTranslations(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		valid bool
+	}{
+		{map[string]string{"fr.json": `{"%s created the domain %s": "%s a ajouté le domaine %s"}`, "de.json": `{"Domain": "Domain"}`, "README.md": "ignored"}, true},
+		{map[string]string{"fr.json": `{"%s created the domain": "%s a ajouté le domaine"}`}, false},
+		{map[string]string{"fr.json": `{"%s created the domain %s": "%s a ajouté un domaine"}`}, false},
+		{map[string]string{"fr.json": `["Domaine"]`}, false},
+	}
+	for _, test := range tests {
+		dir := writeTemplates(t, test.files)
+		catalogs, err := LoadTranslations(dir)
+		os.RemoveAll(dir)
+		if test.valid && err != nil {
+			t.Errorf("LoadTranslations(%v) returned error: %v", test.files, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("LoadTranslations(%v) expected error", test.files)
+		}
+		if err != nil || !test.valid {
+			continue
+		}
+
+		if want, got := "%s a ajouté le domaine %s", catalogs["fr"]["%s created the domain %s"]; want != got {
+			t.Errorf("LoadTranslations expected the override %q, got %q", want, got)
+		}
+		if want, got := "%s a supprimé le domaine %s", catalogs["fr"]["%s deleted the domain %s"]; want != got {
+			t.Errorf("LoadTranslations expected the built-in translation %q, got %q", want, got)
+		}
+		if want, got := "%s a créé le domaine %s", builtinCatalogs["fr"]["%s created the domain %s"]; want != got {
+			t.Errorf("LoadTranslations changed the built-in catalog to %q", got)
+		}
+		if _, ok := catalogs["de"]; !ok {
+			t.Errorf("LoadTranslations expected the new language de")
+		}
+	}
+}
+
+func Test_Message_Translated(t *testing.T) {
+	catalogs, err := LoadTranslations("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := newDestinationService(DestinationConfig{Type: "slack", URL: "https://hooks.slack.com/services/T/B/X", Language: "fr"}, &formatting{catalogs: catalogs})
+	if err != nil {
+		t.Fatalf("newDestinationService returned error: %v", err)
+	}
+	slack := service.(*retryingService).MessagingService.(*SlackService)
+
+	event, err := webhook.ParseEvent([]byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"entity": "token", "pretty": "jane@example.com (terraform)"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[<https://dnsimple.com/a/1010/account|User>] jane@example.com via le jeton d'API 'terraform' a créé le domaine <https://dnsimple.com/a/1010/domains/example.com|example.com>"
+	if got := Message(slack, event); want != got {
+		t.Errorf("Message expected %q, got %q", want, got)
+	}
+
+	blocks := slackEventBlocks(slack, event, "", SeverityWarning, "")
+	if want, got := "*Gravité*\navertissement", blocks[2].Fields[1].Text; want != got {
+		t.Errorf("slackEventBlocks expected field %q, got %q", want, got)
+	}
+}
+
+func TestNewDestinationService_Language(t *testing.T) {
+	catalogs, err := LoadTranslations("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, language := range []string{"", "en", "fr", "es"} {
+		if _, err := newDestinationService(DestinationConfig{Type: "slack", URL: "https://hooks.slack.com/services/T/B/X", Language: language}, &formatting{catalogs: catalogs}); err != nil {
+			t.Errorf("newDestinationService(%q) returned error: %v", language, err)
+		}
+	}
+	if _, err := newDestinationService(DestinationConfig{Type: "slack", URL: "https://hooks.slack.com/services/T/B/X", Language: "xx"}, &formatting{catalogs: catalogs}); err == nil {
+		t.Errorf("newDestinationService with an unknown language expected error")
+	}
+}
@@ -0,0 +1,210 @@
+package strillone
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+const defaultMaxBodySize = 1 << 20
+
+// errBodyTooLarge is the message of the error returned by http.MaxBytesReader.
+const errBodyTooLarge = "http: request body too large"
+
+// inbound wraps a webhook receiver handler with the inbound protections
+// configured in the current configuration.
+func (s *Server) inbound(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		r, span := startReceiveSpan(r)
+		recorder := &statusRecorder{ResponseWriter: w}
+		w = recorder
+		defer func() {
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(recorder.status))
+			if recorder.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.status))
+			}
+			span.End()
+		}()
+
+		routing := s.currentRouting()
+
+		ip := routing.allowlist.clientIP(r)
+		if !routing.allowlist.allowed(ip) {
+			log.Warn().Str("ip", ip.String()).Msg("Rejecting request: not in the allowlist")
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		if ok, retryAfter := s.ipLimiter.Allow(ip.String(), routing.config.Inbound.RateLimit); !ok {
+			log.Warn().Str("ip", ip.String()).Msg("Rejecting request: rate limit exceeded")
+			writeTooManyRequests(w, retryAfter)
+			return
+		}
+
+		if !routing.credentials.authenticate(r) {
+			log.Warn().Str("ip", ip.String()).Msg("Rejecting request: invalid credentials")
+			routing.credentials.challenge(w)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, routing.config.Inbound.maxBodySize())
+
+		if !s.drain.enter() {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer s.drain.leave()
+
+		handle(w, r, params)
+	}
+}
+
+// inboundCredentials are the credentials required to send webhooks.
+type inboundCredentials struct {
+	username    string
+	password    string
+	bearerToken string
+}
+
+func resolveInboundCredentials(config InboundConfig, secrets *Secrets) (inboundCredentials, error) {
+	var c inboundCredentials
+	var err error
+	c.username = config.Username
+	if c.password, err = secrets.Resolve(config.Password); err != nil {
+		return c, err
+	}
+	if c.bearerToken, err = secrets.Resolve(config.BearerToken); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// authenticate returns true if the request has valid credentials,
+// or if no credentials are required.
+func (c *inboundCredentials) authenticate(r *http.Request) bool {
+	if c.username == "" && c.bearerToken == "" {
+		return true
+	}
+
+	if c.username != "" {
+		if username, password, ok := r.BasicAuth(); ok {
+			userOK := subtle.ConstantTimeCompare([]byte(username), []byte(c.username)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(password), []byte(c.password)) == 1
+			if userOK && passOK {
+				return true
+			}
+		}
+	}
+
+	if c.bearerToken != "" {
+		if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			token := strings.TrimPrefix(header, "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(c.bearerToken)) == 1 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// challenge sets the WWW-Authenticate header for the configured authentication schemes.
+func (c *inboundCredentials) challenge(w http.ResponseWriter) {
+	if c.username != "" {
+		w.Header().Add("WWW-Authenticate", `Basic realm="strillone"`)
+	}
+	if c.bearerToken != "" {
+		w.Header().Add("WWW-Authenticate", `Bearer realm="strillone"`)
+	}
+}
+
+// ipAllowlist checks the source IP of the requests against a list of networks.
+// An empty list allows every address.
+type ipAllowlist struct {
+	networks       []*net.IPNet
+	trustedProxies []*net.IPNet
+}
+
+func newIPAllowlist(allowed, trustedProxies []string) (*ipAllowlist, error) {
+	allowedNets, err := parseCIDRs(allowed)
+	if err != nil {
+		return nil, err
+	}
+	proxyNets, err := parseCIDRs(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &ipAllowlist{networks: allowedNets, trustedProxies: proxyNets}, nil
+}
+
+// clientIP returns the IP of the client that originated the request.
+//
+// The X-Forwarded-For header is used only when the request comes from a trusted proxy.
+// The header is read from right to left, skipping the trusted proxies,
+// so that a client can't spoof its address by sending its own header.
+func (a *ipAllowlist) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(a.trustedProxies, ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !containsIP(a.trustedProxies, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (a *ipAllowlist) allowed(ip net.IP) bool {
+	if len(a.networks) == 0 {
+		return true
+	}
+	return ip != nil && containsIP(a.networks, ip)
+}
+
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
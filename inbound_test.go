@@ -0,0 +1,108 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIPAllowlist_ClientIP(t *testing.T) {
+	allowlist, err := newIPAllowlist([]string{"192.0.2.0/24"}, []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr   string
+		forwardedFor string
+		want         string
+		wantAllowed  bool
+	}{
+		{"192.0.2.10:1234", "", "192.0.2.10", true},
+		{"198.51.100.1:1234", "", "198.51.100.1", false},
+		// untrusted peer: the header is ignored
+		{"198.51.100.1:1234", "192.0.2.10", "198.51.100.1", false},
+		// trusted proxy: the closest untrusted hop is the client
+		{"10.0.0.1:1234", "192.0.2.10", "192.0.2.10", true},
+		{"10.0.0.1:1234", "192.0.2.10, 10.0.0.2", "192.0.2.10", true},
+		// a spoofed left-most entry is not used
+		{"10.0.0.1:1234", "192.0.2.10, 198.51.100.1", "198.51.100.1", false},
+	}
+
+	for _, tt := range tests {
+		request, _ := http.NewRequest("POST", "/events", nil)
+		request.RemoteAddr = tt.remoteAddr
+		if tt.forwardedFor != "" {
+			request.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+
+		ip := allowlist.clientIP(request)
+		if got := ip.String(); tt.want != got {
+			t.Errorf("clientIP(%v, %v) expected %v, got %v", tt.remoteAddr, tt.forwardedFor, tt.want, got)
+		}
+		if got := allowlist.allowed(ip); tt.wantAllowed != got {
+			t.Errorf("allowed(%v) expected %v, got %v", ip, tt.wantAllowed, got)
+		}
+	}
+}
+
+func TestInbound_AllowedIPs(t *testing.T) {
+	server := NewServer(&Config{Inbound: InboundConfig{AllowedIPs: []string{"192.0.2.10"}}})
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "7b2c1d0e-allowlist-0000-000000000001"}`
+
+	request, _ := http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(payload))
+	request.RemoteAddr = "198.51.100.1:1234"
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want := http.StatusForbidden; want != response.Code {
+		t.Errorf("POST /slack from outside the allowlist expected HTTP %v, got %v", want, response.Code)
+	}
+
+	request, _ = http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(payload))
+	request.RemoteAddr = "192.0.2.10:1234"
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want := http.StatusOK; want != response.Code {
+		t.Errorf("POST /slack from the allowlist expected HTTP %v, got %v", want, response.Code)
+	}
+}
+
+func TestParseConfig_InvalidAllowedIPs(t *testing.T) {
+	if _, err := ParseConfig([]byte(`{"inbound": {"allowed_ips": ["not-an-ip"]}}`)); err == nil {
+		t.Errorf("ParseConfig expected error for invalid network")
+	}
+}
+
+func TestInbound_Credentials(t *testing.T) {
+	server := NewServer(&Config{Inbound: InboundConfig{Username: "dnsimple", Password: "secret", BearerToken: "token"}})
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+
+	tests := []struct {
+		name   string
+		auth   func(r *http.Request)
+		status int
+	}{
+		{"none", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"wrong basic", func(r *http.Request) { r.SetBasicAuth("dnsimple", "wrong") }, http.StatusUnauthorized},
+		{"wrong bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("dnsimple", "secret") }, http.StatusOK},
+		{"bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+	}
+
+	for i, tt := range tests {
+		body := fmt.Sprintf(payload, fmt.Sprintf("8c3d2e1f-credentials-0000-00000000000%d", i))
+		request, _ := http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(body))
+		tt.auth(request)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want, got := tt.status, response.Code; want != got {
+			t.Errorf("POST /slack with %v credentials expected HTTP %v, got %v", tt.name, want, got)
+		}
+		if tt.status == http.StatusUnauthorized && len(response.Header().Values("WWW-Authenticate")) != 2 {
+			t.Errorf("POST /slack with %v credentials expected WWW-Authenticate challenges", tt.name)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package strillone
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// LambdaHandler runs an HTTP handler, such as the Server, in AWS Lambda behind API Gateway.
+// It implements the lambda.Handler interface of github.com/aws/aws-lambda-go, and accepts
+// both the REST API proxy events and the HTTP API events (payload format 2.0),
+// as well as the events of the function URLs.
+type LambdaHandler struct {
+	Handler http.Handler
+}
+
+// Invoke implements lambda.Handler
+func (h *LambdaHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(payload, &version); err != nil {
+		return nil, err
+	}
+
+	if version.Version == "2.0" {
+		var request events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+		r, err := lambdaHTTPRequestV2(ctx, &request)
+		if err != nil {
+			return nil, err
+		}
+		response := httptest.NewRecorder()
+		h.Handler.ServeHTTP(response, r)
+		body, encoded := lambdaBody(response.Body.Bytes())
+		return json.Marshal(&events.APIGatewayV2HTTPResponse{
+			StatusCode:        response.Code,
+			MultiValueHeaders: response.Header(),
+			Body:              body,
+			IsBase64Encoded:   encoded,
+		})
+	}
+
+	var request events.APIGatewayProxyRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, err
+	}
+	r, err := lambdaHTTPRequest(ctx, &request)
+	if err != nil {
+		return nil, err
+	}
+	response := httptest.NewRecorder()
+	h.Handler.ServeHTTP(response, r)
+	body, encoded := lambdaBody(response.Body.Bytes())
+	return json.Marshal(&events.APIGatewayProxyResponse{
+		StatusCode:        response.Code,
+		MultiValueHeaders: response.Header(),
+		Body:              body,
+		IsBase64Encoded:   encoded,
+	})
+}
+
+// lambdaHTTPRequest returns the HTTP request of a REST API proxy event.
+func lambdaHTTPRequest(ctx context.Context, request *events.APIGatewayProxyRequest) (*http.Request, error) {
+	query := url.Values{}
+	for name, value := range request.QueryStringParameters {
+		query.Set(name, value)
+	}
+	for name, values := range request.MultiValueQueryStringParameters {
+		query[name] = values
+	}
+	target := &url.URL{Path: request.Path, RawQuery: query.Encode()}
+
+	r, err := newLambdaRequest(ctx, request.HTTPMethod, target.RequestURI(), request.Body, request.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range request.Headers {
+		r.Header.Set(name, value)
+	}
+	for name, values := range request.MultiValueHeaders {
+		r.Header.Del(name)
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	setLambdaRemoteAddr(r, request.RequestContext.Identity.SourceIP)
+	return r, nil
+}
+
+// lambdaHTTPRequestV2 returns the HTTP request of an HTTP API event.
+func lambdaHTTPRequestV2(ctx context.Context, request *events.APIGatewayV2HTTPRequest) (*http.Request, error) {
+	target := request.RawPath
+	if request.RawQueryString != "" {
+		target += "?" + request.RawQueryString
+	}
+
+	r, err := newLambdaRequest(ctx, request.RequestContext.HTTP.Method, target, request.Body, request.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+	// The HTTP API joins the values of the repeated headers with commas.
+	for name, value := range request.Headers {
+		r.Header.Set(name, value)
+	}
+	if len(request.Cookies) > 0 {
+		r.Header.Set("Cookie", strings.Join(request.Cookies, "; "))
+	}
+	setLambdaRemoteAddr(r, request.RequestContext.HTTP.SourceIP)
+	return r, nil
+}
+
+func newLambdaRequest(ctx context.Context, method, target, body string, encoded bool) (*http.Request, error) {
+	data := []byte(body)
+	if encoded {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(body); err != nil {
+			return nil, err
+		}
+	}
+	r, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	r.RequestURI = target
+	return r, nil
+}
+
+// setLambdaRemoteAddr sets the address of the client, so that the IP allowlist applies.
+func setLambdaRemoteAddr(r *http.Request, ip string) {
+	if ip != "" {
+		r.RemoteAddr = net.JoinHostPort(ip, "0")
+	}
+}
+
+// lambdaBody returns the body of the response, base64-encoded if it isn't text.
+func lambdaBody(body []byte) (string, bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
@@ -0,0 +1,132 @@
+package strillone
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// echoHandler responds with the request it received.
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Header().Set("X-Remote-Addr", r.RemoteAddr)
+	w.Header().Add("X-Signature", r.Header.Get("X-Signature"))
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.RequestURI(), body)
+})
+
+func TestLambdaHandler_Invoke(t *testing.T) {
+	payload := `{
+		"resource": "/{proxy+}",
+		"path": "/events",
+		"httpMethod": "POST",
+		"headers": {"X-Signature": "abc"},
+		"multiValueQueryStringParameters": {"dryrun": ["1"]},
+		"requestContext": {"identity": {"sourceIp": "192.0.2.10"}},
+		"body": "{\"name\": \"domain.create\"}"
+	}`
+	handler := &LambdaHandler{Handler: echoHandler}
+	data, err := handler.Invoke(context.Background(), []byte(payload))
+	if err != nil {
+		t.Fatalf("Invoke returned error: %v", err)
+	}
+
+	var response events.APIGatewayProxyResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := http.StatusAccepted, response.StatusCode; want != got {
+		t.Errorf("Invoke expected status %v, got %v", want, got)
+	}
+	if want, got := `POST /events?dryrun=1 {"name": "domain.create"}`, response.Body; want != got {
+		t.Errorf("Invoke expected body %q, got %q", want, got)
+	}
+	if want, got := "abc", response.MultiValueHeaders["X-Signature"]; len(got) != 1 || want != got[0] {
+		t.Errorf("Invoke expected header %q, got %q", want, got)
+	}
+	if want, got := "192.0.2.10:0", response.MultiValueHeaders["X-Remote-Addr"]; len(got) != 1 || want != got[0] {
+		t.Errorf("Invoke expected remote address %q, got %q", want, got)
+	}
+}
+
+func TestLambdaHandler_Invoke_HTTPAPI(t *testing.T) {
+	body := base64.StdEncoding.EncodeToString([]byte(`{"name": "domain.delete"}`))
+	payload := `{
+		"version": "2.0",
+		"rawPath": "/t/team-a/events",
+		"rawQueryString": "dryrun=1",
+		"headers": {"x-signature": "def"},
+		"requestContext": {"http": {"method": "POST", "sourceIp": "192.0.2.20"}},
+		"body": "` + body + `",
+		"isBase64Encoded": true
+	}`
+	handler := &LambdaHandler{Handler: echoHandler}
+	data, err := handler.Invoke(context.Background(), []byte(payload))
+	if err != nil {
+		t.Fatalf("Invoke returned error: %v", err)
+	}
+
+	var response events.APIGatewayV2HTTPResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := http.StatusAccepted, response.StatusCode; want != got {
+		t.Errorf("Invoke expected status %v, got %v", want, got)
+	}
+	if want, got := `POST /t/team-a/events?dryrun=1 {"name": "domain.delete"}`, response.Body; want != got {
+		t.Errorf("Invoke expected body %q, got %q", want, got)
+	}
+	if want, got := "def", response.MultiValueHeaders["X-Signature"]; len(got) != 1 || want != got[0] {
+		t.Errorf("Invoke expected header %q, got %q", want, got)
+	}
+	if want, got := "192.0.2.20:0", response.MultiValueHeaders["X-Remote-Addr"]; len(got) != 1 || want != got[0] {
+		t.Errorf("Invoke expected remote address %q, got %q", want, got)
+	}
+}
+
+func TestLambdaHandler_Invoke_Server(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops := &failingService{}
+	server.routing.services["ops"] = ops
+
+	event, _ := json.Marshal(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "d7a3e5c1-lambda-0000-000000000001"}`)
+	payload := `{"path": "/events", "httpMethod": "POST", "body": ` + string(event) + `}`
+	handler := &LambdaHandler{Handler: server}
+	data, err := handler.Invoke(context.Background(), []byte(payload))
+	if err != nil {
+		t.Fatalf("Invoke returned error: %v", err)
+	}
+
+	var response events.APIGatewayProxyResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := http.StatusOK, response.StatusCode; want != got {
+		t.Errorf("Invoke expected status %v, got %v: %s", want, got, response.Body)
+	}
+	if want, got := 1, ops.sent; want != got {
+		t.Errorf("Invoke expected %v deliveries, got %v", want, got)
+	}
+}
+
+func Test_lambdaBody(t *testing.T) {
+	if body, encoded := lambdaBody([]byte("ok")); body != "ok" || encoded {
+		t.Errorf("lambdaBody expected text, got %q, %v", body, encoded)
+	}
+	if body, encoded := lambdaBody([]byte{0xff, 0xfe}); body != "//4=" || !encoded {
+		t.Errorf("lambdaBody expected base64, got %q, %v", body, encoded)
+	}
+}
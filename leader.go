@@ -0,0 +1,267 @@
+package strillone
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultLeaderLease is how long the leadership is held without being renewed.
+	defaultLeaderLease = 2 * time.Minute
+
+	redisLeaderKey = "strillone:leader"
+
+	// redisRenewScript extends the lease of the leader, if it is still held by the instance.
+	redisRenewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+
+	kubernetesServiceAccount = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesMicroTime      = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// LeaderElector elects the instance running the scheduled jobs among the instances behind a load balancer,
+// so that the reminders, the certificate checks, the drift checks and the registrar audits run once.
+type LeaderElector interface {
+	// Campaign acquires the leadership, or renews it, and returns true if the instance is the leader.
+	Campaign(ctx context.Context, now time.Time) (bool, error)
+}
+
+// SetLeaderElector runs the scheduled jobs only on the instance elected leader.
+// The leadership is acquired or renewed when a job is due, and the jobs are skipped when the election fails.
+func (s *Server) SetLeaderElector(elector LeaderElector) {
+	s.elector = elector
+}
+
+// isLeader returns true if the instance runs the scheduled jobs.
+func (s *Server) isLeader(ctx context.Context, now time.Time) bool {
+	if s.elector == nil {
+		return true
+	}
+	leader, err := s.elector.Campaign(ctx, now)
+	if err != nil {
+		log.Error().Err(err).Msg("Error electing the leader, skipping the scheduled job")
+		return false
+	}
+	return leader
+}
+
+// leaderIdentity returns the identity of the instance: its host name, such as the name of its pod, and a random suffix.
+func leaderIdentity() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return hostname + "-" + hex.EncodeToString(suffix)
+}
+
+// RedisElector elects the leader with a lock in Redis, expiring at the end of the lease unless renewed.
+type RedisElector struct {
+	client   *redisClient
+	identity string
+	lease    time.Duration
+}
+
+// NewRedisElector returns the elector using the Redis server at the URL, such as redis://:password@localhost:6379/0.
+// The lease is 2 minutes when 0.
+func NewRedisElector(rawURL string, lease time.Duration) (*RedisElector, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if lease == 0 {
+		lease = defaultLeaderLease
+	}
+	return &RedisElector{client: client, identity: leaderIdentity(), lease: lease}, nil
+}
+
+// Campaign implements LeaderElector
+func (e *RedisElector) Campaign(ctx context.Context, now time.Time) (bool, error) {
+	ms := strconv.FormatInt(e.lease.Milliseconds(), 10)
+	reply, err := e.client.do("SET", redisLeaderKey, e.identity, "NX", "PX", ms)
+	if err != nil {
+		return false, err
+	}
+	if reply != nil {
+		log.Info().Str("identity", e.identity).Msg("Elected leader")
+		return true, nil
+	}
+	reply, err = e.client.do("EVAL", redisRenewScript, "1", redisLeaderKey, e.identity, ms)
+	if err != nil {
+		return false, err
+	}
+	renewed, _ := reply.(int64)
+	return renewed == 1, nil
+}
+
+// KubernetesElector elects the leader with a Lease of the coordination.k8s.io API, like the Kubernetes controllers.
+// The service account of the pods needs the get, create and update verbs on the leases.
+type KubernetesElector struct {
+	// URL is the URL of the API server, and Token the bearer token of the service account.
+	URL   string
+	Token string
+
+	Namespace string
+	Name      string
+	Identity  string
+	Lease     time.Duration
+
+	HTTPClient *http.Client
+}
+
+// kubernetesLease is a coordination.k8s.io/v1 Lease.
+type kubernetesLease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+		LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+// NewKubernetesElector returns the elector using the Lease with the name in the namespace of the pod,
+// with the in-cluster configuration of the service account. The lease is 2 minutes when 0.
+func NewKubernetesElector(name string, lease time.Duration) (*KubernetesElector, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("kubernetes: not running in a cluster")
+	}
+	token, err := ioutil.ReadFile(kubernetesServiceAccount + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: %v", err)
+	}
+	namespace, err := ioutil.ReadFile(kubernetesServiceAccount + "/namespace")
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: %v", err)
+	}
+	ca, err := ioutil.ReadFile(kubernetesServiceAccount + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("kubernetes: invalid ca.crt")
+	}
+	if lease == 0 {
+		lease = defaultLeaderLease
+	}
+	return &KubernetesElector{
+		URL:       "https://" + net.JoinHostPort(host, port),
+		Token:     strings.TrimSpace(string(token)),
+		Namespace: strings.TrimSpace(string(namespace)),
+		Name:      name,
+		Identity:  leaderIdentity(),
+		Lease:     lease,
+		HTTPClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+	}, nil
+}
+
+// Campaign implements LeaderElector
+func (e *KubernetesElector) Campaign(ctx context.Context, now time.Time) (bool, error) {
+	leases := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", strings.TrimSuffix(e.URL, "/"), e.Namespace)
+	renewTime := now.UTC().Format(kubernetesMicroTime)
+
+	lease := &kubernetesLease{}
+	status, err := e.do(ctx, "GET", leases+"/"+e.Name, nil, lease)
+	if err != nil && status != http.StatusNotFound {
+		return false, err
+	}
+	if status == http.StatusNotFound {
+		lease = &kubernetesLease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		lease.Metadata.Name, lease.Metadata.Namespace = e.Name, e.Namespace
+		lease.Spec.HolderIdentity = e.Identity
+		lease.Spec.LeaseDurationSeconds = int(e.Lease.Seconds())
+		lease.Spec.AcquireTime, lease.Spec.RenewTime = renewTime, renewTime
+		status, err := e.do(ctx, "POST", leases, lease, nil)
+		if status == http.StatusConflict {
+			// created by another instance
+			return false, nil
+		}
+		if err == nil {
+			log.Info().Str("identity", e.Identity).Msg("Elected leader")
+		}
+		return err == nil, err
+	}
+
+	if lease.Spec.HolderIdentity != e.Identity {
+		renewed, err := time.Parse(kubernetesMicroTime, lease.Spec.RenewTime)
+		expires := renewed.Add(time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second)
+		if lease.Spec.HolderIdentity != "" && err == nil && now.Before(expires) {
+			return false, nil
+		}
+		lease.Spec.HolderIdentity = e.Identity
+		lease.Spec.AcquireTime = renewTime
+		lease.Spec.LeaseTransitions++
+		log.Info().Str("identity", e.Identity).Msg("Elected leader")
+	}
+	lease.Spec.LeaseDurationSeconds = int(e.Lease.Seconds())
+	lease.Spec.RenewTime = renewTime
+	// the resource version makes the update fail if another instance changed the lease since
+	status, err = e.do(ctx, "PUT", leases+"/"+e.Name, lease, nil)
+	if status == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// do sends the request with the body encoded in JSON, and decodes the response in out.
+// It returns the status code, and an error unless the request succeeded.
+func (e *KubernetesElector) do(ctx context.Context, method, url string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+e.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, &statusError{StatusCode: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+	if out != nil {
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,154 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stubElector struct {
+	leader bool
+	err    error
+}
+
+func (e *stubElector) Campaign(ctx context.Context, now time.Time) (bool, error) {
+	return e.leader, e.err
+}
+
+func TestServer_isLeader(t *testing.T) {
+	server := NewServer(nil)
+	if !server.isLeader(context.Background(), time.Now()) {
+		t.Errorf("isLeader without elector expected true")
+	}
+	tests := []struct {
+		elector  *stubElector
+		expected bool
+	}{
+		{&stubElector{leader: true}, true},
+		{&stubElector{leader: false}, false},
+		{&stubElector{err: errors.New("unavailable")}, false},
+	}
+	for _, tt := range tests {
+		server.SetLeaderElector(tt.elector)
+		if got := server.isLeader(context.Background(), time.Now()); tt.expected != got {
+			t.Errorf("isLeader with %+v expected %v, got %v", tt.elector, tt.expected, got)
+		}
+	}
+}
+
+func TestRedisElector(t *testing.T) {
+	url, _ := startFakeRedis(t)
+	electors := make([]*RedisElector, 2)
+	for i := range electors {
+		elector, err := NewRedisElector(url, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("NewRedisElector returned error: %v", err)
+		}
+		electors[i] = elector
+	}
+
+	for i, expected := range []bool{true, false, true} {
+		elector := electors[i%2]
+		if leader, err := elector.Campaign(context.Background(), time.Now()); err != nil || leader != expected {
+			t.Errorf("Campaign #%d expected %v, got %v %v", i, expected, leader, err)
+		}
+	}
+
+	// the leadership moves once the lease expires
+	time.Sleep(150 * time.Millisecond)
+	if leader, _ := electors[1].Campaign(context.Background(), time.Now()); !leader {
+		t.Errorf("Campaign after the lease expected the leadership")
+	}
+	if leader, _ := electors[0].Campaign(context.Background(), time.Now()); leader {
+		t.Errorf("Campaign of the previous leader expected no leadership")
+	}
+}
+
+// startTestLeases starts a Kubernetes API server storing a lease.
+func startTestLeases(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	var lease *kubernetesLease
+	version := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /apis/coordination.k8s.io/v1/namespaces/dns/leases/strillone":
+			if lease == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(lease)
+		case "POST /apis/coordination.k8s.io/v1/namespaces/dns/leases":
+			if lease != nil {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			lease = &kubernetesLease{}
+			json.NewDecoder(r.Body).Decode(lease)
+			version++
+			lease.Metadata.ResourceVersion = strconv.Itoa(version)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(lease)
+		case "PUT /apis/coordination.k8s.io/v1/namespaces/dns/leases/strillone":
+			update := &kubernetesLease{}
+			json.NewDecoder(r.Body).Decode(update)
+			if update.Metadata.ResourceVersion != lease.Metadata.ResourceVersion {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			version++
+			update.Metadata.ResourceVersion = strconv.Itoa(version)
+			lease = update
+			json.NewEncoder(w).Encode(lease)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestKubernetesElector(t *testing.T) {
+	api := startTestLeases(t)
+	electors := make([]*KubernetesElector, 2)
+	for i := range electors {
+		electors[i] = &KubernetesElector{URL: api.URL, Token: "token", Namespace: "dns", Name: "strillone", Identity: "pod-" + strconv.Itoa(i), Lease: time.Minute}
+	}
+
+	now := time.Now()
+	tests := []struct {
+		elector  int
+		at       time.Time
+		expected bool
+	}{
+		{0, now, true},
+		{1, now, false},
+		{0, now.Add(30 * time.Second), true},
+		{1, now.Add(80 * time.Second), false},
+		// the lease of the first pod expires
+		{1, now.Add(100 * time.Second), true},
+		{0, now.Add(110 * time.Second), false},
+	}
+	for i, tt := range tests {
+		leader, err := electors[tt.elector].Campaign(context.Background(), tt.at)
+		if err != nil || leader != tt.expected {
+			t.Errorf("Campaign #%d expected %v, got %v %v", i, tt.expected, leader, err)
+		}
+	}
+
+	electors[0].Token = "invalid"
+	if _, err := electors[0].Campaign(context.Background(), now); err == nil {
+		t.Errorf("Campaign with an invalid token expected error")
+	}
+}
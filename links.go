@@ -0,0 +1,76 @@
+package strillone
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// DashboardLinker is implemented by the messaging services linking a DNSimple dashboard
+// other than https://dnsimple.com, such as https://sandbox.dnsimple.com for the sandbox accounts.
+type DashboardLinker interface {
+	DashboardURL() string
+}
+
+// dashboardURL returns the base URL of the DNSimple dashboard linked by the service.
+func dashboardURL(s Formatter) string {
+	if linker, ok := s.(DashboardLinker); ok && linker.DashboardURL() != "" {
+		return strings.TrimSuffix(linker.DashboardURL(), "/")
+	}
+	return dnsimpleURL
+}
+
+// validateDashboardURL checks that the base URL of the dashboard is an absolute HTTP URL.
+func validateDashboardURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid dashboard url %q", value)
+	}
+	return nil
+}
+
+// fmtDashboardURL formats the path of a page of the dashboard at the base URL.
+func fmtDashboardURL(base, path string, a ...interface{}) string {
+	return fmt.Sprintf(base+path, a...)
+}
+
+// eventURL returns the page of the DNSimple dashboard about the resource of the event:
+// the domain, the record, the certificate, the contact or the webhook.
+// It returns the account page when the event isn't about a specific resource.
+func eventURL(base string, e *webhook.Event) string {
+	if e.Account == nil {
+		return base
+	}
+	accountID := e.Account.ID
+
+	switch data := eventData(e).(type) {
+	case *CertificateEvent:
+		return fmtDashboardURL(base, "/a/%d/domains/%d/certificates/%d", accountID, data.Certificate.DomainID, data.Certificate.ID)
+	case *webhook.ContactEventData:
+		if data.Contact != nil {
+			return fmtDashboardURL(base, "/a/%d/contacts/%d", accountID, data.Contact.ID)
+		}
+	case *webhook.WebhookEventData:
+		if data.Webhook != nil {
+			return fmtDashboardURL(base, "/a/%d/webhooks/%d", accountID, data.Webhook.ID)
+		}
+	case *ZoneRecordEvent:
+		if data.ZoneRecord != nil {
+			return fmtDashboardURL(base, "/a/%d/domains/%s/records/%d", accountID, data.ZoneRecord.ZoneID, data.ZoneRecord.ID)
+		}
+	case *webhook.ZoneEventData:
+		if data.Zone != nil {
+			return fmtDashboardURL(base, "/a/%d/domains/%s/records", accountID, data.Zone.Name)
+		}
+	}
+
+	if domain := eventDomain(e); domain != "" {
+		return fmtDashboardURL(base, "/a/%d/domains/%s", accountID, domain)
+	}
+	return fmtDashboardURL(base, "/a/%d/account", accountID)
+}
@@ -0,0 +1,66 @@
+package strillone
+
+import (
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_eventURL(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"name": "domain.create", "account": {"id": 1010}, "data": {"domain": {"id": 1, "name": "example.com"}}}`, "https://dnsimple.com/a/1010/domains/example.com"},
+		{`{"name": "certificate.issue", "account": {"id": 1010}, "data": {"certificate": {"id": 3, "domain_id": 2}}}`, "https://dnsimple.com/a/1010/domains/2/certificates/3"},
+		{`{"name": "contact.update", "account": {"id": 1010}, "data": {"contact": {"id": 4}}}`, "https://dnsimple.com/a/1010/contacts/4"},
+		{`{"name": "zone_record.update", "account": {"id": 1010}, "data": {"zone_record": {"id": 7, "zone_id": "example.com"}}}`, "https://dnsimple.com/a/1010/domains/example.com/records/7"},
+		{`{"name": "account.update", "account": {"id": 1010}, "data": {"account": {"id": 1010}}}`, "https://dnsimple.com/a/1010/account"},
+	}
+	for _, test := range tests {
+		event, err := webhook.ParseEvent([]byte(test.payload))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, test.payload)
+		}
+		if got := eventURL(dnsimpleURL, event); test.want != got {
+			t.Errorf("eventURL(%v) expected %v, got %v", event.Name, test.want, got)
+		}
+	}
+}
+
+func Test_Message_DashboardURL(t *testing.T) {
+	service := &SlackService{Dashboard: "https://sandbox.dnsimple.com/"}
+	event, _ := webhook.ParseEvent([]byte(`{"name": "domain.create", "actor": {"pretty": "john.doe@email.com"}, "account": {"id": 1010, "display": "Account"}, "data": {"domain": {"id": 1, "name": "example.com"}}}`))
+
+	if want, got := "[<https://sandbox.dnsimple.com/a/1010/account|Account>] john.doe@email.com created the domain <https://sandbox.dnsimple.com/a/1010/domains/example.com|example.com>", Message(service, event); want != got {
+		t.Errorf("Expected '%v', got '%v'", want, got)
+	}
+	blocks := slackEventBlocks(service, event, "", SeverityInfo, "")
+	if want, got := "https://sandbox.dnsimple.com/a/1010/domains/example.com", blocks[3].Elements[0].(*slackButton).URL; want != got {
+		t.Errorf("slackEventBlocks expected button to %v, got %v", want, got)
+	}
+}
+
+func TestConfig_DashboardURL(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"dashboard_url": "https://sandbox.dnsimple.com",
+		"tenants": [{"name": "team-a", "token": "0123456789abcdef", "dashboard_url": "https://dnsimple.com"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	routing, err := newRoutingTable(config, nil)
+	if err != nil {
+		t.Fatalf("newRoutingTable returned error: %v", err)
+	}
+	if want, got := "https://sandbox.dnsimple.com", routing.formatting.dashboardURL; want != got {
+		t.Errorf("dashboard url expected %v, got %v", want, got)
+	}
+	if want, got := "https://dnsimple.com", routing.tenantByName("team-a").formatting.dashboardURL; want != got {
+		t.Errorf("tenant dashboard url expected %v, got %v", want, got)
+	}
+
+	if _, err := ParseConfig([]byte(`{"dashboard_url": "sandbox.dnsimple.com"}`)); err == nil {
+		t.Errorf("ParseConfig with a relative dashboard url expected error")
+	}
+}
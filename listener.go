@@ -0,0 +1,125 @@
+package strillone
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ListenerOptions represents the protections of the HTTP listener against slow or abusive clients.
+type ListenerOptions struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// MaxConnsPerIP is the maximum number of concurrent connections from the same IP,
+	// zero means unlimited. Behind a proxy, the IP is the proxy one.
+	MaxConnsPerIP int
+}
+
+// DefaultListenerOptions returns the default listener options.
+func DefaultListenerOptions() *ListenerOptions {
+	return &ListenerOptions{
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+}
+
+// HTTPServer returns an http.Server for the handler with the configured timeouts.
+func (o *ListenerOptions) HTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: o.ReadHeaderTimeout,
+		ReadTimeout:       o.ReadTimeout,
+		WriteTimeout:      o.WriteTimeout,
+		IdleTimeout:       o.IdleTimeout,
+	}
+}
+
+// Listen announces on the TCP address, limiting the concurrent connections per IP.
+func (o *ListenerOptions) Listen(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if o.MaxConnsPerIP <= 0 {
+		return listener, nil
+	}
+	return &limitListener{Listener: listener, max: o.MaxConnsPerIP, conns: map[string]int{}}, nil
+}
+
+// limitListener is a net.Listener that closes the connections exceeding
+// the maximum number of concurrent connections from the same IP.
+type limitListener struct {
+	net.Listener
+	max int
+
+	mu    sync.Mutex
+	conns map[string]int
+}
+
+// Accept implements net.Listener
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := remoteIP(conn)
+		if l.acquire(ip) {
+			return &limitConn{Conn: conn, release: func() { l.release(ip) }}, nil
+		}
+
+		log.Warn().Str("ip", ip).Msg("Rejecting connection: too many concurrent connections")
+		conn.Close()
+	}
+}
+
+func (l *limitListener) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[ip] >= l.max {
+		return false
+	}
+	l.conns[ip]++
+	return true
+}
+
+func (l *limitListener) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conns[ip]--
+	if l.conns[ip] <= 0 {
+		delete(l.conns, ip)
+	}
+}
+
+// limitConn is a net.Conn that releases its slot when closed.
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close implements net.Conn
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
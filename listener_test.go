@@ -0,0 +1,74 @@
+package strillone
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenerOptions_MaxConnsPerIP(t *testing.T) {
+	options := &ListenerOptions{MaxConnsPerIP: 1}
+	listener, err := options.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	first, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	conn := <-accepted
+
+	second, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	// The second connection is closed by the server
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Errorf("second connection expected to be closed")
+	}
+
+	// Once the first connection is released, a new one is accepted
+	conn.Close()
+	third, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	select {
+	case <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Errorf("third connection expected to be accepted")
+	}
+}
+
+func TestInbound_MaxBodySize(t *testing.T) {
+	server := NewServer(&Config{Inbound: InboundConfig{MaxBodySize: 64}})
+
+	request, _ := http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(`{"name": "domain.create", "data": {"domain": {"name": "`+strings.Repeat("a", 100)+`"}}}`))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusRequestEntityTooLarge; want != response.Code {
+		t.Errorf("POST /slack with a large body expected HTTP %v, got %v", want, response.Code)
+	}
+}
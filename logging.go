@@ -0,0 +1,73 @@
+package strillone
+
+import (
+	"fmt"
+	stdlog "log"
+	"os"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	zerolog.DurationFieldUnit = time.Millisecond
+	zerolog.DurationFieldInteger = true
+}
+
+// LoggingOptions represents the configuration of the logs.
+type LoggingOptions struct {
+	// Level is the minimum level logged: debug, info (the default), warn or error.
+	Level string
+
+	// Pretty logs colored lines for humans instead of JSON lines, for development.
+	Pretty bool
+}
+
+// SetupLogging configures the global logger, which logs JSON lines to stderr by default.
+// The output of the standard library logger, used by the dependencies, goes through it too.
+func SetupLogging(options *LoggingOptions) error {
+	level := zerolog.InfoLevel
+	if options.Level != "" {
+		var err error
+		if level, err = zerolog.ParseLevel(options.Level); err != nil || level == zerolog.NoLevel {
+			return fmt.Errorf("logging: invalid level %q", options.Level)
+		}
+	}
+	zerolog.SetGlobalLevel(level)
+
+	logger := zerolog.New(os.Stderr)
+	if options.Pretty {
+		logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "15:04:05.000"})
+	}
+	log.Logger = logger.With().Timestamp().Logger()
+
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(log.Logger)
+	return nil
+}
+
+// withEvent adds the fields identifying the event to the log entry.
+func withEvent(entry *zerolog.Event, event *webhook.Event) *zerolog.Event {
+	entry = entry.Str("event", event.Name).Str("request_id", eventRequestID(event))
+	if event.Account != nil {
+		entry = entry.Int64("account_id", event.Account.ID)
+	}
+	return entry
+}
+
+// logDelivery logs the outcome and the latency of the delivery of the event to a destination.
+func logDelivery(event *webhook.Event, tenant, destination string, start time.Time, err error) {
+	entry, outcome := log.Info(), deliveryStatus(err)
+	if err != nil {
+		entry = log.Error().Err(err)
+	}
+	withEvent(entry, event).
+		Str("tenant", tenant).
+		Str("destination", destination).
+		Dur("latency", time.Since(start)).
+		Str("outcome", outcome).
+		Msg("Event " + outcome)
+}
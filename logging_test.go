@@ -0,0 +1,67 @@
+package strillone
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestSetupLogging(t *testing.T) {
+	level := zerolog.GlobalLevel()
+	defer zerolog.SetGlobalLevel(level)
+	logger := log.Logger
+	defer func() { log.Logger = logger }()
+
+	if err := SetupLogging(&LoggingOptions{Level: "warn"}); err != nil {
+		t.Fatalf("SetupLogging returned error: %v", err)
+	}
+	if want, got := zerolog.WarnLevel, zerolog.GlobalLevel(); want != got {
+		t.Errorf("SetupLogging expected level %v, got %v", want, got)
+	}
+
+	if err := SetupLogging(&LoggingOptions{Level: "loud"}); err == nil {
+		t.Errorf("SetupLogging with an invalid level expected error")
+	}
+}
+
+func TestLogDelivery(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buffer)
+	defer func() { log.Logger = logger }()
+
+	event, err := webhook.ParseEvent([]byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "1a5e7b3c-logging-0000-000000000001"}`))
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	logDelivery(event, "team-a", "ops", time.Now(), errors.New("downstream outage"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON line, got %q", buffer.String())
+	}
+	expected := map[string]interface{}{
+		"level":       "error",
+		"event":       "domain.create",
+		"request_id":  "1a5e7b3c-logging-0000-000000000001",
+		"account_id":  float64(1010),
+		"tenant":      "team-a",
+		"destination": "ops",
+		"outcome":     "failed",
+		"error":       "downstream outage",
+	}
+	for key, want := range expected {
+		if got := entry[key]; want != got {
+			t.Errorf("log entry %v expected %v, got %v", key, want, got)
+		}
+	}
+	if _, ok := entry["latency"]; !ok {
+		t.Errorf("log entry expected a latency")
+	}
+}
@@ -0,0 +1,81 @@
+package strillone
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// MentionConfig attaches a mention to the messages about the events matching the rule,
+// so that someone is notified.
+type MentionConfig struct {
+	// Events is the list of event name patterns matched by the rule, using the path.Match syntax
+	// (e.g. "domain.transfer_*"). An empty list matches every event.
+	Events []string `json:"events,omitempty"`
+
+	// MinSeverity, when set, restricts the rule to the events at least as severe (e.g. "critical").
+	MinSeverity Severity `json:"min_severity,omitempty"`
+
+	// Mention is "here", "channel", or the ID of a Slack user group (e.g. S0123ABCD).
+	Mention string `json:"mention"`
+}
+
+const (
+	mentionHere    = "here"
+	mentionChannel = "channel"
+)
+
+// userGroupPattern matches the IDs of the Slack user groups.
+var userGroupPattern = regexp.MustCompile(`^S[A-Z0-9]+$`)
+
+func (c *MentionConfig) validate() error {
+	if c.Mention != mentionHere && c.Mention != mentionChannel && !userGroupPattern.MatchString(c.Mention) {
+		return fmt.Errorf("mention: invalid mention %q, must be here, channel or a user group ID", c.Mention)
+	}
+	if _, ok := severityRanks[c.MinSeverity]; c.MinSeverity != "" && !ok {
+		return fmt.Errorf("mention: invalid min severity %q", c.MinSeverity)
+	}
+	for _, pattern := range c.Events {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("mention: invalid event pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// matches returns true if the rule matches the event name and severity.
+func (c *MentionConfig) matches(eventName string, severity Severity) bool {
+	if c.MinSeverity != "" && !severity.AtLeast(c.MinSeverity) {
+		return false
+	}
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, pattern := range c.Events {
+		if ok, _ := path.Match(pattern, eventName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// slackMentions returns the Slack mentions of the rules matching the event, without duplicates,
+// such as "<!here> <!subteam^S0123ABCD>".
+func slackMentions(rules []MentionConfig, eventName string, severity Severity) string {
+	var mentions []string
+	seen := map[string]bool{}
+	for i := range rules {
+		if !rules[i].matches(eventName, severity) || seen[rules[i].Mention] {
+			continue
+		}
+		seen[rules[i].Mention] = true
+		switch mention := rules[i].Mention; mention {
+		case mentionHere, mentionChannel:
+			mentions = append(mentions, "<!"+mention+">")
+		default:
+			mentions = append(mentions, "<!subteam^"+mention+">")
+		}
+	}
+	return strings.Join(mentions, " ")
+}
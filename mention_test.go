@@ -0,0 +1,90 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_slackMentions(t *testing.T) {
+	rules := []MentionConfig{
+		{Events: []string{"domain.transfer_*"}, Mention: "channel"},
+		{MinSeverity: SeverityWarning, Mention: "S0123ABCD"},
+		{MinSeverity: SeverityCritical, Mention: "S0123ABCD"},
+	}
+
+	tests := []struct {
+		rules     []MentionConfig
+		eventName string
+		severity  Severity
+		want      string
+	}{
+		{nil, "domain.delete", SeverityCritical, ""},
+		{nil, "domain.create", SeverityInfo, ""},
+		{rules, "domain.transfer_out", SeverityCritical, "<!channel> <!subteam^S0123ABCD>"},
+		{rules, "domain.transfer_lock_enable", SeverityInfo, "<!channel>"},
+		{rules, "contact.delete", SeverityWarning, "<!subteam^S0123ABCD>"},
+		{rules, "contact.create", SeverityInfo, ""},
+	}
+	for _, test := range tests {
+		if got := slackMentions(test.rules, test.eventName, test.severity); test.want != got {
+			t.Errorf("slackMentions(%v, %v) expected %q, got %q", test.eventName, test.severity, test.want, got)
+		}
+	}
+}
+
+func TestMentionConfig_validate(t *testing.T) {
+	tests := []struct {
+		config MentionConfig
+		valid  bool
+	}{
+		{MentionConfig{Mention: "here"}, true},
+		{MentionConfig{Events: []string{"domain.*"}, MinSeverity: SeverityWarning, Mention: "S0123ABCD"}, true},
+		{MentionConfig{}, false},
+		{MentionConfig{Mention: "everyone"}, false},
+		{MentionConfig{Events: []string{"domain.["}, Mention: "here"}, false},
+		{MentionConfig{MinSeverity: "urgent", Mention: "here"}, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate()
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
+
+func TestSlackService_WithoutMentions(t *testing.T) {
+	var payload slackPayload
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid Slack payload: %v", err)
+		}
+	}))
+	defer target.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": %q}],
+		"routes": [{"destinations": ["ops"]}]
+	}`, target.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	event, _ := webhook.ParseEvent([]byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.delete"}`))
+	if _, err := server.currentRouting().services["ops"].PostEvent(context.Background(), event); err != nil {
+		t.Fatalf("PostEvent returned error: %v", err)
+	}
+	if strings.Contains(payload.Text, "<!") {
+		t.Errorf("PostEvent of a destination without mentions expected no mention, got %v", payload.Text)
+	}
+}
@@ -7,143 +7,263 @@ import (
 	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
 )
 
-// Message formats the event into a text message suitable for being sent to a messaging service.
-func Message(s MessagingService, e *webhook.Event) (text string) {
+// Message formats the event into a text message suitable for being sent to a messaging service,
+// in the markup of its formatter.
+func Message(s Formatter, e *webhook.Event) (text string) {
 	account := e.Account
-	prefix := fmt.Sprintf("[%v] %v", s.FormatLink(account.Display, fmtURL("/a/%d/account", account.ID)), e.Actor.Pretty)
+	base := dashboardURL(s)
+	actor := formatActor(s, e.Actor)
+	prefix := fmt.Sprintf("[%v] %v", s.FormatLink(eventAccountDisplay(s, e), fmtDashboardURL(base, "/a/%d/account", account.ID)), actor)
+
+	switch data := eventData(e).(type) {
+	case *webhook.AccountEventData:
+		accountLink := s.FormatLink(fmt.Sprintf("%d", data.Account.ID), fmtDashboardURL(base, "/a/%d/account", data.Account.ID))
+		switch e.Name {
+		case "account.update":
+			text = tprintf(s, "%s updated the settings of the account %s", prefix, accountLink)
+		case "account.billing_settings_update":
+			text = tprintf(s, "%s updated the billing settings of the account %s", prefix, accountLink)
+		default:
+			text = tprintf(s, "%s performed %s", prefix, e.Name)
+		}
 
-	switch data := e.GetData().(type) {
 	case *webhook.AccountMembershipEventData:
-		membersLink := s.FormatLink(fmt.Sprintf("%d", data.Account.ID), fmtURL("/a/%d/account/members", data.Account.ID))
+		membersLink := s.FormatLink(fmt.Sprintf("%d", data.Account.ID), fmtDashboardURL(base, "/a/%d/account/members", data.Account.ID))
 		switch e.Name {
 		case "account.user_invite":
-			text = fmt.Sprintf("%s invited %s to account %s", e.Actor.Pretty, data.AccountInvitation.Email, membersLink)
+			text = tprintf(s, "%s invited %s to account %s", actor, escape(s, data.AccountInvitation.Email), membersLink)
 		case "account.user_invitation_accept":
-			text = fmt.Sprintf("%s accepted invitation to account %s", e.Actor.Pretty, membersLink)
+			text = tprintf(s, "%s accepted invitation to account %s", actor, membersLink)
 		case "account.user_invitation_revoke":
-			text = fmt.Sprintf("%s rejected invitation to account %s", e.Actor.Pretty, membersLink)
+			text = tprintf(s, "%s rejected invitation to account %s", actor, membersLink)
 		case "account.user_remove":
-			text = fmt.Sprintf("%s removed %s from account %s", e.Actor.Pretty, data.User.Email, membersLink)
+			text = tprintf(s, "%s removed %s from account %s", actor, escape(s, data.User.Email), membersLink)
 		default:
-			text = fmt.Sprintf("%s performed %s", prefix, e.Name)
+			text = tprintf(s, "%s performed %s", prefix, e.Name)
 		}
 
-	case *webhook.CertificateEventData:
+	case *CertificateEvent:
 		certificate := data.Certificate
 		certificateDisplay := certificate.CommonName
-		certificateLink := s.FormatLink(certificateDisplay, fmtURL("/a/%d/domains/%d/certificates/%d", account.ID, certificate.DomainID, certificate.ID))
+		certificateLink := s.FormatLink(certificateDisplay, fmtDashboardURL(base, "/a/%d/domains/%d/certificates/%d", account.ID, certificate.DomainID, certificate.ID))
+		details := certificateContext(s, data)
 		switch e.Name {
+		case "certificate.issue":
+			text = tprintf(s, "%s issued the certificate %s%s", prefix, certificateLink, details)
+		case "certificate.reissue":
+			text = tprintf(s, "%s reissued the certificate %s%s", prefix, certificateLink, details)
+		case "certificate.auto_renewal_enable":
+			text = tprintf(s, "%s enabled auto-renewal for the certificate %s%s", prefix, certificateLink, details)
+		case "certificate.auto_renewal_disable":
+			text = tprintf(s, "%s disabled auto-renewal for the certificate %s%s", prefix, certificateLink, details)
+		case "certificate.auto_renewal_failed":
+			text = tprintf(s, "%s failed to auto-renew the certificate %s%s", prefix, certificateLink, details)
 		case "certificate.remove_private_key":
-			text = fmt.Sprintf("%s deleted the private key for the certificate %s", prefix, certificateLink)
+			text = tprintf(s, "%s deleted the private key for the certificate %s%s", prefix, certificateLink, details)
 		default:
-			text = fmt.Sprintf("%s performed %s", prefix, e.Name)
+			text = tprintf(s, "%s performed %s", prefix, e.Name)
 		}
 
 	case *webhook.ContactEventData:
 		contactDisplay := fmt.Sprintf("%s %s", data.Contact.FirstName, data.Contact.LastName)
-		contactLink := s.FormatLink(contactDisplay, fmtURL("/a/%d/contacts/%d", account.ID, data.Contact.ID))
+		contactLink := s.FormatLink(contactDisplay, fmtDashboardURL(base, "/a/%d/contacts/%d", account.ID, data.Contact.ID))
 		switch e.Name {
 		case "contact.create":
-			text = fmt.Sprintf("%s created the contact %s", prefix, contactLink)
+			text = tprintf(s, "%s created the contact %s", prefix, contactLink)
 		case "contact.update":
-			text = fmt.Sprintf("%s updated the contact %s", prefix, contactLink)
+			text = tprintf(s, "%s updated the contact %s", prefix, contactLink)
 		case "contact.delete":
-			text = fmt.Sprintf("%s deleted the contact %s", prefix, contactLink)
+			text = tprintf(s, "%s deleted the contact %s", prefix, contactLink)
 		default:
-			text = fmt.Sprintf("%s performed %s", prefix, e.Name)
+			text = tprintf(s, "%s performed %s", prefix, e.Name)
+		}
+
+	case *webhook.DNSSECEventData:
+		domainDisplay := eventDomain(e)
+		var domainID interface{} = domainDisplay
+		if data.DelegationSignerRecord != nil && domainDisplay == "" {
+			domainDisplay = fmt.Sprintf("%d", data.DelegationSignerRecord.DomainID)
+			domainID = data.DelegationSignerRecord.DomainID
+		}
+		domainLink := s.FormatLink(domainDisplay, fmtDashboardURL(base, "/a/%d/domains/%v/dnssec", account.ID, domainID))
+		switch e.Name {
+		case "dnssec.create":
+			text = tprintf(s, "%s enabled DNSSEC for the domain %s", prefix, domainLink)
+		case "dnssec.delete":
+			text = tprintf(s, "%s disabled DNSSEC for the domain %s", prefix, domainLink)
+		case "dnssec.rotation_start":
+			text = tprintf(s, "%s started the DNSSEC key rotation for the domain %s", prefix, domainLink)
+		case "dnssec.rotation_complete":
+			text = tprintf(s, "%s completed the DNSSEC key rotation for the domain %s", prefix, domainLink)
+		default:
+			text = tprintf(s, "%s performed %s", prefix, e.Name)
 		}
 
 	case *webhook.DomainEventData:
 		domainDisplay := data.Domain.Name
-		domainLink := s.FormatLink(domainDisplay, fmtURL("/a/%d/domains/%s", account.ID, data.Domain.Name))
+		domainLink := s.FormatLink(domainDisplay, fmtDashboardURL(base, "/a/%d/domains/%s", account.ID, data.Domain.Name))
 		switch e.Name {
 		case "domain.auto_renewal_enable":
-			text = fmt.Sprintf("%s enabled auto-renewal for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s enabled auto-renewal for the domain %s", prefix, domainLink)
 		case "domain.auto_renewal_disable":
-			text = fmt.Sprintf("%s disabled auto-renewal for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s disabled auto-renewal for the domain %s", prefix, domainLink)
 		case "domain.create":
-			text = fmt.Sprintf("%s created the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s created the domain %s", prefix, domainLink)
 		case "domain.delete":
-			text = fmt.Sprintf("%s deleted the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s deleted the domain %s", prefix, domainLink)
 		case "domain.register":
-			text = fmt.Sprintf("%s registered the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s registered the domain %s", prefix, domainLink)
 		case "domain.renew":
-			text = fmt.Sprintf("%s renewed the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s renewed the domain %s", prefix, domainLink)
 		case "domain.delegation_change":
-			servers := strings.Join(*data.Delegation, ", ")
-			text = fmt.Sprintf("%s changed the delegation for the domain %s to %s", prefix, domainLink, servers)
+			servers := escape(s, strings.Join(*data.Delegation, ", "))
+			text = tprintf(s, "%s changed the delegation for the domain %s to %s", prefix, domainLink, servers)
 		case "domain.registrant_change":
-			registrant := data.Registrant.Label
-			text = fmt.Sprintf("%s changed the registrant for the domain %s to %s", prefix, domainLink, registrant)
+			registrant := escape(s, data.Registrant.Label)
+			text = tprintf(s, "%s changed the registrant for the domain %s to %s", prefix, domainLink, registrant)
 		case "domain.resolution_enable":
-			text = fmt.Sprintf("%s enabled resolution for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s enabled resolution for the domain %s", prefix, domainLink)
 		case "domain.resolution_disable":
-			text = fmt.Sprintf("%s disabled resolution for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s disabled resolution for the domain %s", prefix, domainLink)
 		case "domain.token_reset":
-			text = fmt.Sprintf("%s reset the token for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s reset the token for the domain %s", prefix, domainLink)
 		case "domain.transfer":
-			text = fmt.Sprintf("%s transferred the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s transferred the domain %s", prefix, domainLink)
+		case "domain.transfer_lock_enable":
+			text = tprintf(s, "%s enabled the transfer lock for the domain %s", prefix, domainLink)
+		case "domain.transfer_lock_disable":
+			text = tprintf(s, "%s disabled the transfer lock for the domain %s", prefix, domainLink)
 		default:
-			text = fmt.Sprintf("%s performed %s on domain %s", prefix, e.Name, domainLink)
+			text = tprintf(s, "%s performed %s on domain %s", prefix, e.Name, domainLink)
 		}
 
 	case *webhook.EmailForwardEventData:
 		emailforward := data.EmailForward
 		emailforwardDisplay := fmt.Sprintf("%s → %s", emailforward.From, emailforward.To)
 		// We don't individual email forwards pages
-		emailforwardLink := s.FormatLink(emailforwardDisplay, fmtURL("/a/%d/domains/%d/email_forwards", account.ID, emailforward.DomainID))
+		emailforwardLink := s.FormatLink(emailforwardDisplay, fmtDashboardURL(base, "/a/%d/domains/%d/email_forwards", account.ID, emailforward.DomainID))
 		switch e.Name {
 		case "email_forward.create":
-			text = fmt.Sprintf("%s created the email forward %s", prefix, emailforwardLink)
+			text = tprintf(s, "%s created the email forward %s", prefix, emailforwardLink)
 		case "email_forward.delete":
-			text = fmt.Sprintf("%s deleted the email forward %s", prefix, emailforwardLink)
+			text = tprintf(s, "%s deleted the email forward %s", prefix, emailforwardLink)
 		case "email_forward.update":
-			text = fmt.Sprintf("%s updated the email forward %s", prefix, emailforwardLink)
+			text = tprintf(s, "%s updated the email forward %s", prefix, emailforwardLink)
 		default:
-			text = fmt.Sprintf("%s performed %s", prefix, e.Name)
+			text = tprintf(s, "%s performed %s", prefix, e.Name)
 		}
 
 	case *webhook.WhoisPrivacyEventData:
 		domainDisplay := data.Domain.Name
-		domainLink := s.FormatLink(domainDisplay, fmtURL("/a/%d/domains/%s", account.ID, data.Domain.Name))
+		domainLink := s.FormatLink(domainDisplay, fmtDashboardURL(base, "/a/%d/domains/%s", account.ID, data.Domain.Name))
 		switch e.Name {
 		case "whois_privacy.disable":
-			text = fmt.Sprintf("%s disabled whois privacy for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s disabled whois privacy for the domain %s", prefix, domainLink)
 		case "whois_privacy.enable":
-			text = fmt.Sprintf("%s enabled whois privacy for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s enabled whois privacy for the domain %s", prefix, domainLink)
 		case "whois_privacy.purchase":
-			text = fmt.Sprintf("%s purchased whois privacy for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s purchased whois privacy for the domain %s", prefix, domainLink)
 		case "whois_privacy.renew":
-			text = fmt.Sprintf("%s renewed whois privacy for the domain %s", prefix, domainLink)
+			text = tprintf(s, "%s renewed whois privacy for the domain %s", prefix, domainLink)
+		default:
+			text = tprintf(s, "%s performed %s on domain %s", prefix, e.Name, domainLink)
+		}
+
+	case *NameServerEventData:
+		nameServer := escape(s, data.NameServer.Name)
+		switch e.Name {
+		case "name_server.register":
+			text = tprintf(s, "%s registered the name server %s", prefix, nameServer)
+		case "name_server.deregister":
+			text = tprintf(s, "%s deregistered the name server %s", prefix, nameServer)
+		default:
+			text = tprintf(s, "%s performed %s on name server %s", prefix, e.Name, nameServer)
+		}
+
+	case *PushEventData:
+		domainDisplay := fmt.Sprintf("%d", data.Push.DomainID)
+		if data.Domain != nil {
+			domainDisplay = data.Domain.Name
+		}
+		domainLink := s.FormatLink(domainDisplay, fmtDashboardURL(base, "/a/%d/domains/%d", account.ID, data.Push.DomainID))
+		switch e.Name {
+		case "push.initiate":
+			text = tprintf(s, "%s initiated the push of the domain %s to another account", prefix, domainLink)
+		case "push.accept":
+			text = tprintf(s, "%s accepted the push of the domain %s", prefix, domainLink)
+		case "push.reject":
+			text = tprintf(s, "%s rejected the push of the domain %s", prefix, domainLink)
+		default:
+			text = tprintf(s, "%s performed %s on domain %s", prefix, e.Name, domainLink)
+		}
+
+	case *SubscriptionEventData:
+		plan := escape(s, data.Subscription.PlanName)
+		switch e.Name {
+		case "subscription.subscribe":
+			text = tprintf(s, "%s subscribed to the %s plan", prefix, plan)
+		case "subscription.renew":
+			text = tprintf(s, "%s renewed the subscription to the %s plan", prefix, plan)
+		case "subscription.migrate":
+			text = tprintf(s, "%s changed the subscription to the %s plan", prefix, plan)
+		case "subscription.unsubscribe":
+			text = tprintf(s, "%s cancelled the subscription to the %s plan", prefix, plan)
+		default:
+			text = tprintf(s, "%s performed %s", prefix, e.Name)
 		}
 
-	case *webhook.ZoneRecordEventData:
+	case *webhook.ZoneEventData:
+		zoneLink := s.FormatLink(data.Zone.Name, fmtDashboardURL(base, "/a/%d/domains/%s/records", account.ID, data.Zone.Name))
+		switch e.Name {
+		case "zone.create":
+			text = tprintf(s, "%s created the zone %s", prefix, zoneLink)
+		case "zone.delete":
+			text = tprintf(s, "%s deleted the zone %s", prefix, zoneLink)
+		default:
+			text = tprintf(s, "%s performed %s on zone %s", prefix, e.Name, zoneLink)
+		}
+
+	case *ZoneRecordEvent:
 		zoneRecordDisplay := fmt.Sprintf("%s %s.%s %s", data.ZoneRecord.Type, data.ZoneRecord.Name, data.ZoneRecord.ZoneID, data.ZoneRecord.Content)
-		zoneRecordLink := s.FormatLink(zoneRecordDisplay, fmtURL("/a/%d/domains/%s/records/%d", account.ID, data.ZoneRecord.ZoneID, data.ZoneRecord.ID))
+		zoneRecordURL := fmtDashboardURL(base, "/a/%d/domains/%s/records/%d", account.ID, data.ZoneRecord.ZoneID, data.ZoneRecord.ID)
+		zoneRecordLink := s.FormatLink(zoneRecordDisplay, zoneRecordURL)
 		switch e.Name {
 		case "zone_record.create":
-			text = fmt.Sprintf("%s created the record %s", prefix, zoneRecordLink)
+			text = tprintf(s, "%s created the record %s", prefix, zoneRecordLink)
 		case "zone_record.update":
-			text = fmt.Sprintf("%s updated the record %s", prefix, zoneRecordLink)
+			if data.Previous != nil {
+				diffLink := s.FormatLink(zoneRecordDiff(data.Previous, data.ZoneRecord), zoneRecordURL)
+				text = tprintf(s, "%s updated the record %s in %s", prefix, diffLink, escape(s, data.ZoneRecord.ZoneID))
+				break
+			}
+			text = tprintf(s, "%s updated the record %s", prefix, zoneRecordLink)
 		case "zone_record.delete":
-			text = fmt.Sprintf("%s deleted the record %s", prefix, zoneRecordLink)
+			text = tprintf(s, "%s deleted the record %s", prefix, zoneRecordLink)
+		default:
+			text = tprintf(s, "%s performed %s on record %s", prefix, e.Name, zoneRecordLink)
 		}
 
 	case *webhook.WebhookEventData:
 		webhookDisplay := data.Webhook.URL
-		webhookLink := s.FormatLink(webhookDisplay, fmtURL("/a/%d/webhooks/%d", account.ID, data.Webhook.ID))
+		webhookLink := s.FormatLink(webhookDisplay, fmtDashboardURL(base, "/a/%d/webhooks/%d", account.ID, data.Webhook.ID))
 		switch e.Name {
 		case "webhook.create":
-			text = fmt.Sprintf("%s created the webhook %s", prefix, webhookLink)
+			text = tprintf(s, "%s created the webhook %s", prefix, webhookLink)
 		case "webhook.delete":
-			text = fmt.Sprintf("%s deleted the webhook %s", prefix, webhookLink)
+			text = tprintf(s, "%s deleted the webhook %s", prefix, webhookLink)
+		default:
+			text = tprintf(s, "%s performed %s", prefix, e.Name)
 		}
 
 	default:
-		text = fmt.Sprintf("%s performed %s", prefix, e.Name)
+		if !knownEvents[e.Name] {
+			text = unknownEventMessage(s, e, prefix)
+			break
+		}
+		text = tprintf(s, "%s performed %s", prefix, e.Name)
 	}
 
-	return
+	return text + domainContext(s, e)
 }
 
 func eventRequestID(e *webhook.Event) string {
@@ -151,5 +271,5 @@ func eventRequestID(e *webhook.Event) string {
 }
 
 func fmtURL(path string, a ...interface{}) string {
-	return fmt.Sprintf(dnsimpleURL+path, a...)
+	return fmtDashboardURL(dnsimpleURL, path, a...)
 }
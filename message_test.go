@@ -1,7 +1,9 @@
 package strillone
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
@@ -17,9 +19,12 @@ func NewTestMessagingService(name string) *TestMessagingService {
 func (*TestMessagingService) FormatLink(url, name string) string {
 	return fmt.Sprintf("<%s|%s>", url, name)
 }
-func (*TestMessagingService) PostEvent(event *webhook.Event) (string, error) {
+func (*TestMessagingService) PostEvent(_ context.Context, event *webhook.Event) (string, error) {
 	return "ok", nil
 }
+func (*TestMessagingService) PostMessage(_ context.Context, text string) error {
+	return nil
+}
 
 func Test_Message_AccountUserInviteEvent(t *testing.T) {
 	service := NewTestMessagingService("dummyMessagingService")
@@ -159,3 +164,84 @@ func Test_fmtURL(t *testing.T) {
 		t.Fatalf("Expected %v, got %v", want, got)
 	}
 }
+
+func Test_Message_Catalog(t *testing.T) {
+	service := NewTestMessagingService("dummyMessagingService")
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"account.update", `{"account": {"id": 1010}}`, "updated the settings of the account <1010|https://dnsimple.com/a/1010/account>"},
+		{"certificate.reissue", `{"certificate": {"id": 1, "domain_id": 2, "common_name": "www.example.com"}}`, "reissued the certificate <www.example.com|https://dnsimple.com/a/1010/domains/2/certificates/1>"},
+		{"certificate.auto_renewal_failed", `{"certificate": {"id": 1, "domain_id": 2, "common_name": "www.example.com"}}`, "failed to auto-renew the certificate <www.example.com|https://dnsimple.com/a/1010/domains/2/certificates/1>"},
+		{"dnssec.rotation_start", `{"domain": {"name": "example.com"}, "delegation_signer_record": {"domain_id": 2}}`, "started the DNSSEC key rotation for the domain <example.com|https://dnsimple.com/a/1010/domains/example.com/dnssec>"},
+		{"domain.transfer_lock_enable", `{"domain": {"name": "example.com"}}`, "enabled the transfer lock for the domain <example.com|https://dnsimple.com/a/1010/domains/example.com>"},
+		{"name_server.register", `{"name_server": {"name": "ns1.example.com"}}`, "registered the name server ns1.example.com"},
+		{"push.initiate", `{"push": {"id": 1, "domain_id": 2}, "domain": {"name": "example.com"}}`, "initiated the push of the domain <example.com|https://dnsimple.com/a/1010/domains/2> to another account"},
+		{"subscription.migrate", `{"subscription": {"plan_name": "Professional"}}`, "changed the subscription to the Professional plan"},
+		{"zone.create", `{"zone": {"name": "example.com"}}`, "created the zone <example.com|https://dnsimple.com/a/1010/domains/example.com/records>"},
+		{"zone_record.unknown", `{"zone_record": {"id": 1, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}`, "performed zone_record.unknown"},
+	}
+	for _, test := range tests {
+		payload := `{"name": "` + test.name + `", "actor": {"pretty": "john.doe@email.com"}, "account": {"id": 1010, "display": "Account"}, "data": ` + test.data + `}`
+		event, err := webhook.ParseEvent([]byte(payload))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, payload)
+		}
+
+		if want, got := "[<Account|https://dnsimple.com/a/1010/account>] john.doe@email.com "+test.want, Message(service, event); !strings.HasPrefix(got, want) {
+			t.Errorf("%s: expected '%v', got '%v'", test.name, want, got)
+		}
+	}
+}
+
+func Test_Message_CertificateExpiry(t *testing.T) {
+	service := NewTestMessagingService("dummyMessagingService")
+	tests := []struct {
+		name        string
+		certificate string
+		want        string
+	}{
+		{"certificate.issue", `{"id": 1, "domain_id": 2, "common_name": "www.example.com", "alternate_names": ["www.example.com", "example.com"], "expires_at": "2022-06-16T12:00:00Z"}`, "issued the certificate <www.example.com|https://dnsimple.com/a/1010/domains/2/certificates/1> covering example.com, expiring on 2022-06-16"},
+		{"certificate.reissue", `{"id": 1, "domain_id": 2, "common_name": "www.example.com", "expires_at": "2022-06-16"}`, "reissued the certificate <www.example.com|https://dnsimple.com/a/1010/domains/2/certificates/1> expiring on 2022-06-16"},
+		{"certificate.remove_private_key", `{"id": 1, "domain_id": 2, "common_name": "www.example.com", "alternate_names": ["a.example.com", "b.example.com"]}`, "deleted the private key for the certificate <www.example.com|https://dnsimple.com/a/1010/domains/2/certificates/1> covering a.example.com, b.example.com"},
+		{"certificate.auto_renewal_failed", `{"id": 1, "domain_id": 2, "common_name": "www.example.com", "expires_at": "soon"}`, "failed to auto-renew the certificate <www.example.com|https://dnsimple.com/a/1010/domains/2/certificates/1>"},
+	}
+	for _, test := range tests {
+		payload := `{"name": "` + test.name + `", "actor": {"pretty": "john.doe@email.com"}, "account": {"id": 1010, "display": "Account"}, "data": {"certificate": ` + test.certificate + `}}`
+		event, err := webhook.ParseEvent([]byte(payload))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, payload)
+		}
+
+		if want, got := "[<Account|https://dnsimple.com/a/1010/account>] john.doe@email.com "+test.want, Message(service, event); want != got {
+			t.Errorf("%s: expected '%v', got '%v'", test.name, want, got)
+		}
+	}
+}
+
+func Test_Message_Actor(t *testing.T) {
+	slack := &SlackService{Actors: map[string]string{"jane@example.com": "U024BE7LH"}}
+	tests := []struct {
+		actor string
+		want  string
+	}{
+		{`{"entity": "user", "pretty": "john.doe@email.com"}`, "john.doe@email.com"},
+		{`{"entity": "user", "pretty": "Jane@example.com"}`, "<@U024BE7LH>"},
+		{`{"entity": "api_token", "pretty": "jane@example.com (terraform)"}`, "<@U024BE7LH> via API token 'terraform'"},
+		{`{"entity": "api_token", "pretty": "terraform"}`, "API token 'terraform'"},
+		{`{"entity": "dnsimple", "pretty": "support@dnsimple.com"}`, "DNSimple"},
+	}
+	for _, test := range tests {
+		payload := `{"name": "domain.create", "actor": ` + test.actor + `, "account": {"id": 1010, "display": "Account"}, "data": {"domain": {"name": "example.com"}}}`
+		event, err := webhook.ParseEvent([]byte(payload))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, payload)
+		}
+
+		if want, got := "[<https://dnsimple.com/a/1010/account|Account>] "+test.want+" created the domain", Message(slack, event); !strings.HasPrefix(got, want) {
+			t.Errorf("%s: expected '%v', got '%v'", test.actor, want, got)
+		}
+	}
+}
@@ -0,0 +1,519 @@
+package strillone
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	oidcStateCookie   = "strillone_oidc"
+	oidcSessionCookie = "strillone_session"
+
+	// oidcLoginTimeout is how long the users have to log in with the identity provider.
+	oidcLoginTimeout = 10 * time.Minute
+
+	defaultOIDCGroupsClaim     = "groups"
+	defaultOIDCSessionDuration = 8 * time.Hour
+)
+
+var defaultOIDCScopes = []string{"openid", "email", "profile"}
+
+// OIDCConfig configures the login to the dashboard with OpenID Connect, such as with Okta, Google or Azure AD.
+type OIDCConfig struct {
+	// Issuer is the URL of the identity provider, whose configuration is discovered at /.well-known/openid-configuration.
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+
+	// RedirectURL is the URL of the /dashboard/callback endpoint, registered with the identity provider.
+	RedirectURL string `json:"redirect_url"`
+
+	// Scopes are the scopes requested. Defaults to openid, email and profile.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// GroupsClaim is the claim of the ID token listing the groups of the user. Defaults to groups.
+	GroupsClaim string `json:"groups_claim,omitempty"`
+
+	// Roles maps the groups, or the verified email addresses, of the users to their role. The users without a role are denied.
+	Roles map[string]string `json:"roles"`
+
+	// SessionDuration is how long the users stay logged in. Defaults to 8h.
+	SessionDuration Duration `json:"session_duration,omitempty"`
+}
+
+func (c *OIDCConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Issuer == "" || c.ClientID == "" || c.ClientSecret == "" {
+		return errors.New("admin: oidc: issuer, client_id and client_secret required")
+	}
+	if u, err := url.Parse(c.RedirectURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("admin: oidc: invalid redirect_url %q", c.RedirectURL)
+	}
+	if len(c.Roles) == 0 {
+		return errors.New("admin: oidc: roles required")
+	}
+	roles := make(map[string]string, len(c.Roles))
+	for group, role := range c.Roles {
+		if roleLevels[role] == 0 {
+			return fmt.Errorf("admin: oidc: invalid role %q of %q", role, group)
+		}
+		// The emails are compared in lowercase, the groups as they are.
+		if strings.Contains(group, "@") {
+			group = strings.ToLower(group)
+		}
+		if r, ok := roles[group]; ok && r != role {
+			return fmt.Errorf("admin: oidc: conflicting roles of %q", group)
+		}
+		roles[group] = role
+	}
+	c.Roles = roles
+	if c.SessionDuration < 0 {
+		return errors.New("admin: oidc: session duration must be positive")
+	}
+	return nil
+}
+
+// oidcProvider logs the users in with an OpenID Connect identity provider, and keeps their sessions in signed cookies.
+type oidcProvider struct {
+	config       *OIDCConfig
+	clientSecret string
+	client       *http.Client
+
+	// sessionKey signs the cookies.
+	sessionKey []byte
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+// oidcDiscovery is the configuration of the identity provider.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcSession is the signed content of the session cookie.
+type oidcSession struct {
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	Expires int64  `json:"exp"`
+}
+
+// oidcLogin is the signed content of the state cookie, during the login.
+type oidcLogin struct {
+	State   string `json:"state"`
+	Nonce   string `json:"nonce"`
+	Return  string `json:"return"`
+	Expires int64  `json:"exp"`
+}
+
+// newOIDCProvider returns the provider of the configuration, or nil if the login is disabled.
+func newOIDCProvider(config *OIDCConfig, secrets *Secrets) (*oidcProvider, error) {
+	if config == nil {
+		return nil, nil
+	}
+	clientSecret, err := secrets.Resolve(config.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("admin: oidc: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(clientSecret))
+	mac.Write([]byte("strillone session"))
+	return &oidcProvider{config: config, clientSecret: clientSecret, client: defaultHTTPClient, sessionKey: mac.Sum(nil)}, nil
+}
+
+// discover returns the configuration of the identity provider, fetched once.
+func (p *oidcProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := doSecretsRequest(p.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %v", err)
+	}
+	discovery := &oidcDiscovery{}
+	if err := json.Unmarshal(body, discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %v", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery: unexpected issuer %q", discovery.Issuer)
+	}
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// publicKey returns the signing key of the ID tokens, fetching the keys of the identity provider again
+// when the key is unknown, such as after a rotation.
+func (p *oidcProvider) publicKey(discovery *oidcDiscovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	req, err := http.NewRequest("GET", discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := doSecretsRequest(p.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc keys: %v", err)
+	}
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return nil, fmt.Errorf("oidc keys: %v", err)
+	}
+	p.keys = map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+		p.keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("oidc keys: unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// exchange exchanges the authorization code for the ID token, and returns its verified claims.
+func (p *oidcProvider) exchange(code, nonce string, now time.Time) (map[string]interface{}, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.clientSecret},
+	}
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	body, err := doSecretsRequest(p.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token: %v", err)
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.IDToken == "" {
+		return nil, errors.New("oidc token: missing ID token")
+	}
+	return p.verify(discovery, token.IDToken, nonce, now)
+}
+
+// verify checks the signature, the issuer, the audience, the expiration and the nonce of the ID token,
+// and returns its claims. Only the RS256 signatures are supported, the default of the identity providers.
+func (p *oidcProvider) verify(discovery *oidcDiscovery, idToken, nonce string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed ID token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("oidc: unsupported ID token algorithm %q", header.Alg)
+	}
+	key, err := p.publicKey(discovery, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("oidc: malformed ID token signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("oidc: invalid ID token signature")
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return nil, fmt.Errorf("oidc: unexpected ID token issuer %q", iss)
+	}
+	if !containsClaim(claims["aud"], p.config.ClientID) {
+		return nil, errors.New("oidc: unexpected ID token audience")
+	}
+	if exp, _ := claims["exp"].(float64); now.After(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return nil, errors.New("oidc: expired ID token")
+	}
+	if claims["nonce"] != nonce {
+		return nil, errors.New("oidc: invalid ID token nonce")
+	}
+	return claims, nil
+}
+
+// role returns the highest role of the groups and of the verified email of the claims, or an empty string.
+func (p *oidcProvider) role(claims map[string]interface{}) string {
+	groupsClaim := p.config.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = defaultOIDCGroupsClaim
+	}
+	var names []string
+	switch groups := claims[groupsClaim].(type) {
+	case string:
+		names = append(names, groups)
+	case []interface{}:
+		for _, group := range groups {
+			if group, ok := group.(string); ok {
+				names = append(names, group)
+			}
+		}
+	}
+	// The email maps to a role only once the identity provider verified that the user owns it.
+	if email, _ := claims["email"].(string); email != "" && claims["email_verified"] == true {
+		names = append(names, strings.ToLower(email))
+	}
+
+	var role string
+	for _, name := range names {
+		if r, ok := p.config.Roles[name]; ok && roleLevels[r] > roleLevels[role] {
+			role = r
+		}
+	}
+	return role
+}
+
+// session returns the key of the user logged in with the session cookie of the request, or nil.
+func (p *oidcProvider) session(r *http.Request, now time.Time) *adminKey {
+	cookie, err := r.Cookie(oidcSessionCookie)
+	if err != nil {
+		return nil
+	}
+	session := &oidcSession{}
+	if !p.readCookie(cookie.Value, session) || now.Unix() > session.Expires || roleLevels[session.Role] == 0 {
+		return nil
+	}
+	return &adminKey{name: session.Name, role: session.Role}
+}
+
+// signCookie returns the value of a cookie with the content signed with the session key.
+func (p *oidcProvider) signCookie(content interface{}) string {
+	data, _ := json.Marshal(content)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	mac := hmac.New(sha256.New, p.sessionKey)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// readCookie verifies the signature of the cookie value and decodes its content.
+func (p *oidcProvider) readCookie(value string, content interface{}) bool {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, p.sessionKey)
+	mac.Write([]byte(value[:i]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value[:i])
+	return err == nil && json.Unmarshal(data, content) == nil
+}
+
+// setCookie sets a cookie of the dashboard, only sent over HTTPS when the dashboard is.
+func (p *oidcProvider) setCookie(w http.ResponseWriter, name, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/dashboard",
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(p.config.RedirectURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("oidc: malformed ID token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("oidc: malformed ID token")
+	}
+	return nil
+}
+
+// containsClaim returns true if the claim is the value, or a list including it.
+func containsClaim(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == value
+	case []interface{}:
+		for _, v := range claim {
+			if v == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func randomToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// DashboardLogin redirects to the identity provider to log in to the dashboard.
+func (s *Server) DashboardLogin(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	provider := s.currentRouting().oidc
+	if provider == nil || s.history == nil {
+		http.NotFound(w, r)
+		return
+	}
+	discovery, err := provider.discover()
+	if err != nil {
+		log.Error().Err(err).Msg("Error discovering the identity provider")
+		http.Error(w, "the identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	login := &oidcLogin{State: randomToken(), Nonce: randomToken(), Return: "/dashboard", Expires: time.Now().Add(oidcLoginTimeout).Unix()}
+	if path := r.URL.Query().Get("return"); strings.HasPrefix(path, "/dashboard") {
+		login.Return = path
+	}
+	provider.setCookie(w, oidcStateCookie, provider.signCookie(login), int(oidcLoginTimeout.Seconds()))
+
+	scopes := provider.config.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultOIDCScopes
+	}
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {provider.config.ClientID},
+		"redirect_uri":  {provider.config.RedirectURL},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {login.State},
+		"nonce":         {login.Nonce},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	http.Redirect(w, r, discovery.AuthorizationEndpoint+separator+query.Encode(), http.StatusFound)
+}
+
+// DashboardCallback completes the login to the dashboard: it exchanges the authorization code for the ID token
+// of the user, maps the groups of the user to a role, and starts the session.
+func (s *Server) DashboardCallback(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	provider := s.currentRouting().oidc
+	if provider == nil || s.history == nil {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now()
+
+	login := &oidcLogin{}
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || !provider.readCookie(cookie.Value, login) || now.Unix() > login.Expires ||
+		!hmac.Equal([]byte(login.State), []byte(r.URL.Query().Get("state"))) {
+		http.Error(w, "invalid login state, log in again", http.StatusBadRequest)
+		return
+	}
+	provider.setCookie(w, oidcStateCookie, "", -1)
+	if message := r.URL.Query().Get("error"); message != "" {
+		http.Error(w, "login failed: "+message, http.StatusForbidden)
+		return
+	}
+
+	claims, err := provider.exchange(r.URL.Query().Get("code"), login.Nonce, now)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error logging in to the dashboard")
+		http.Error(w, "login failed", http.StatusForbidden)
+		return
+	}
+	name, _ := claims["email"].(string)
+	if name == "" {
+		name, _ = claims["sub"].(string)
+	}
+	role := provider.role(claims)
+	if role == "" {
+		log.Warn().Str("user", name).Msg("Dashboard login denied, no role")
+		http.Error(w, "no role is granted to "+name, http.StatusForbidden)
+		return
+	}
+
+	duration := time.Duration(provider.config.SessionDuration)
+	if duration == 0 {
+		duration = defaultOIDCSessionDuration
+	}
+	session := &oidcSession{Name: name, Role: role, Expires: now.Add(duration).Unix()}
+	provider.setCookie(w, oidcSessionCookie, provider.signCookie(session), int(duration.Seconds()))
+	log.Info().Str("user", name).Str("role", role).Msg("Dashboard login")
+	http.Redirect(w, r, login.Return, http.StatusSeeOther)
+}
+
+// DashboardLogout ends the session of the dashboard.
+func (s *Server) DashboardLogout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	provider := s.currentRouting().oidc
+	if provider == nil {
+		http.NotFound(w, r)
+		return
+	}
+	provider.setCookie(w, oidcSessionCookie, "", -1)
+	fmt.Fprintln(w, "Logged out.")
+}
@@ -0,0 +1,222 @@
+package strillone
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIdentityProvider is an OpenID Connect provider issuing the ID tokens of the claims.
+type fakeIdentityProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+}
+
+func startFakeIdentityProvider(t *testing.T) *fakeIdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdentityProvider{key: key}
+	idp.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": "%[1]s/authorize", "token_endpoint": "%[1]s/token", "jwks_uri": "%[1]s/keys"}`, idp.URL)
+		case "/keys":
+			n := base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+			e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+			fmt.Fprintf(w, `{"keys": [{"kid": "1", "kty": "RSA", "alg": "RS256", "n": %q, "e": %q}]}`, n, e)
+		case "/token":
+			if r.PostFormValue("code") != "code" || r.PostFormValue("client_secret") != "client-secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token": "access", "id_token": %q}`, idp.idToken(t))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return idp
+}
+
+func (idp *fakeIdentityProvider) idToken(t *testing.T) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg": "RS256", "kid": "1"}`))
+	data, _ := json.Marshal(idp.claims)
+	payload := header + "." + base64.RawURLEncoding.EncodeToString(data)
+	digest := sha256.Sum256([]byte(payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestDashboard_OIDCLogin(t *testing.T) {
+	idp := startFakeIdentityProvider(t)
+	defer idp.Close()
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	server := NewServer(&Config{Admin: AdminConfig{OIDC: &OIDCConfig{
+		Issuer:       idp.URL,
+		ClientID:     "strillone",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://strillone.example.com/dashboard/callback",
+		Roles:        map[string]string{"dns-readers": RoleViewer, "Ops@Example.com": RoleOperator},
+	}}})
+	server.SetHistory(history)
+
+	get := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", path, nil)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+	login := func(claims map[string]interface{}) *httptest.ResponseRecorder {
+		response := get("/dashboard/login?return=/dashboard?q=domain")
+		if want, got := http.StatusFound, response.Code; want != got {
+			t.Fatalf("GET /dashboard/login expected HTTP %v, got %v: %v", want, got, response.Body)
+		}
+		location, _ := url.Parse(response.Header().Get("Location"))
+		if want, got := idp.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path; want != got {
+			t.Fatalf("GET /dashboard/login expected a redirect to %v, got %v", want, got)
+		}
+		claims["iss"], claims["aud"], claims["exp"] = idp.URL, "strillone", time.Now().Add(time.Hour).Unix()
+		claims["nonce"] = location.Query().Get("nonce")
+		idp.claims = claims
+		state := response.Result().Cookies()[0]
+		return get("/dashboard/callback?code=code&state="+location.Query().Get("state"), state)
+	}
+
+	response := get("/dashboard")
+	if want, got := "/dashboard/login?return=%2Fdashboard", response.Header().Get("Location"); want != got {
+		t.Errorf("GET /dashboard expected a redirect to %v, got %v %v", want, response.Code, got)
+	}
+
+	response = login(map[string]interface{}{"sub": "1", "email": "jane@example.com", "groups": []string{"dns-readers"}})
+	if want, got := http.StatusSeeOther, response.Code; want != got {
+		t.Fatalf("GET /dashboard/callback expected HTTP %v, got %v: %v", want, got, response.Body)
+	}
+	if want, got := "/dashboard?q=domain", response.Header().Get("Location"); want != got {
+		t.Errorf("GET /dashboard/callback expected a redirect to %v, got %v", want, got)
+	}
+	var session *http.Cookie
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == oidcSessionCookie {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatalf("GET /dashboard/callback expected the session cookie")
+	}
+
+	response = get("/dashboard", session)
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("GET /dashboard with the session expected HTTP %v, got %v", want, got)
+	}
+	if body := response.Body.String(); !strings.Contains(body, "jane@example.com") || strings.Contains(body, "Redeliver</button>") {
+		t.Errorf("GET /dashboard expected the viewer logged in without the redeliveries, got %v", body)
+	}
+	request, _ := http.NewRequest("POST", "/dashboard/redeliver", nil)
+	request.AddCookie(session)
+	forbidden := httptest.NewRecorder()
+	server.ServeHTTP(forbidden, request)
+	if want, got := http.StatusForbidden, forbidden.Code; want != got {
+		t.Errorf("POST /dashboard/redeliver by a viewer expected HTTP %v, got %v", want, got)
+	}
+
+	// the verified email maps to a role too, whatever its case, and the users without a role are denied
+	response = login(map[string]interface{}{"sub": "2", "email": "ops@example.com", "email_verified": true})
+	if want, got := http.StatusSeeOther, response.Code; want != got {
+		t.Errorf("GET /dashboard/callback of an operator expected HTTP %v, got %v", want, got)
+	}
+	response = login(map[string]interface{}{"sub": "2", "email": "ops@example.com"})
+	if want, got := http.StatusForbidden, response.Code; want != got {
+		t.Errorf("GET /dashboard/callback with an unverified email expected HTTP %v, got %v", want, got)
+	}
+	response = login(map[string]interface{}{"sub": "3", "email": "john@example.com", "groups": []string{"sales"}})
+	if want, got := http.StatusForbidden, response.Code; want != got {
+		t.Errorf("GET /dashboard/callback without a role expected HTTP %v, got %v", want, got)
+	}
+
+	if want, got := http.StatusBadRequest, get("/dashboard/callback?code=code&state=forged").Code; want != got {
+		t.Errorf("GET /dashboard/callback without the login state expected HTTP %v, got %v", want, got)
+	}
+	forged := &http.Cookie{Name: oidcSessionCookie, Value: strings.Replace(session.Value, "a", "b", 1)}
+	if want, got := http.StatusFound, get("/dashboard", forged).Code; want != got {
+		t.Errorf("GET /dashboard with a forged session expected HTTP %v, got %v", want, got)
+	}
+}
+
+func TestOIDCProvider_verify(t *testing.T) {
+	idp := startFakeIdentityProvider(t)
+	defer idp.Close()
+	provider, err := newOIDCProvider(&OIDCConfig{Issuer: idp.URL, ClientID: "strillone", ClientSecret: "client-secret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discovery, err := provider.discover()
+	if err != nil {
+		t.Fatalf("discover returned error: %v", err)
+	}
+
+	now := time.Now()
+	valid := map[string]interface{}{"iss": idp.URL, "aud": []string{"strillone"}, "exp": now.Add(time.Hour).Unix(), "nonce": "n"}
+	tests := []struct {
+		claim string
+		value interface{}
+	}{
+		{"iss", "https://evil.example.com"},
+		{"aud", "other"},
+		{"exp", now.Add(-time.Hour).Unix()},
+		{"nonce", "other"},
+	}
+	idp.claims = valid
+	if _, err := provider.verify(discovery, idp.idToken(t), "n", now); err != nil {
+		t.Errorf("verify returned error: %v", err)
+	}
+	for _, test := range tests {
+		claims := map[string]interface{}{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[test.claim] = test.value
+		idp.claims = claims
+		if _, err := provider.verify(discovery, idp.idToken(t), "n", now); err == nil {
+			t.Errorf("verify with %v %v expected error", test.claim, test.value)
+		}
+	}
+}
+
+func TestOIDCConfig_validateRoles(t *testing.T) {
+	config := &OIDCConfig{Issuer: "https://idp.example.com", ClientID: "strillone", ClientSecret: "secret",
+		RedirectURL: "https://strillone.example.com/dashboard/callback",
+		Roles:       map[string]string{"DNS-Admins": RoleAdmin, "Ops@Example.com": RoleOperator}}
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate returned error: %v", err)
+	}
+	if want, got := (map[string]string{"DNS-Admins": RoleAdmin, "ops@example.com": RoleOperator}), config.Roles; !reflect.DeepEqual(want, got) {
+		t.Errorf("validate expected the roles %v, got %v", want, got)
+	}
+
+	config.Roles = map[string]string{"ops@example.com": RoleOperator, "OPS@example.com": RoleAdmin}
+	if err := config.validate(); err == nil {
+		t.Errorf("validate expected error for the conflicting roles of an email")
+	}
+}
@@ -0,0 +1,169 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+)
+
+// OwnershipRule maps the domains matching a pattern to the destinations of the team owning them,
+// such as *.shop.example to the channel of the commerce team.
+type OwnershipRule struct {
+	// Domain is the domain name pattern, using the path.Match syntax (e.g. "*.shop.example").
+	Domain string `json:"domain"`
+
+	// Owner is the name of the team owning the domains, optional.
+	Owner string `json:"owner,omitempty"`
+
+	// Destinations are the destinations receiving the events about the domains.
+	Destinations []string `json:"destinations"`
+
+	// Events are the event name patterns delivered to the owner. An empty list matches every event.
+	Events []string `json:"events,omitempty"`
+}
+
+func validateOwnership(rules []OwnershipRule, destinations map[string]bool) error {
+	domains := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.Domain == "" {
+			return fmt.Errorf("ownership: missing domain")
+		}
+		domain := strings.ToLower(rule.Domain)
+		if domains[domain] {
+			return fmt.Errorf("ownership %q: duplicate domain", rule.Domain)
+		}
+		domains[domain] = true
+		for _, pattern := range append([]string{domain}, rule.Events...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("ownership %q: invalid pattern %q", rule.Domain, pattern)
+			}
+		}
+		if len(rule.Destinations) == 0 {
+			return fmt.Errorf("ownership %q: missing destinations", rule.Domain)
+		}
+		for _, name := range rule.Destinations {
+			if !destinations[name] {
+				return fmt.Errorf("ownership %q: unknown destination %q", rule.Domain, name)
+			}
+		}
+	}
+	return nil
+}
+
+// owner returns the first ownership rule matching the domain, nil if none.
+func owner(rules []OwnershipRule, domain string) *OwnershipRule {
+	if domain == "" {
+		return nil
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	for i := range rules {
+		if ok, _ := path.Match(strings.ToLower(rules[i].Domain), domain); ok {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// lookupOwners adds to the destinations of the event the destinations of the owner of its domain.
+func (t *routingTable) lookupOwners(event *webhook.Event, names []string) []string {
+	rule := owner(t.config.Ownership, eventDomain(event))
+	if rule == nil || (len(rule.Events) > 0 && !matchesAny(rule.Events, event.Name)) {
+		return names
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, name := range rule.Destinations {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// AdminListOwnership returns the ownership rules.
+func (s *Server) AdminListOwnership(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	rules := s.currentRouting().config.Ownership
+	if rules == nil {
+		rules = []OwnershipRule{}
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// AdminReplaceOwnership replaces the ownership rules, such as with the content of a mapping file.
+func (s *Server) AdminReplaceOwnership(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var rules []OwnershipRule
+	if !readJSON(w, r, &rules) {
+		return
+	}
+
+	err := s.updateConfig(adminAudit(r, "ownership.replace", ""), func(config *Config) error {
+		config.Ownership = rules
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// AdminSetOwnership adds an ownership rule, or replaces the rule of the same domain.
+func (s *Server) AdminSetOwnership(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	rule := OwnershipRule{}
+	if !readJSON(w, r, &rule) {
+		return
+	}
+	if rule.Domain == "" {
+		writeJSONError(w, http.StatusBadRequest, "domain is required")
+		return
+	}
+
+	err := s.updateConfig(adminAudit(r, "ownership.set", rule.Domain), func(config *Config) error {
+		if i := findOwnership(config, rule.Domain); i >= 0 {
+			config.Ownership[i] = rule
+			return nil
+		}
+		config.Ownership = append(config.Ownership, rule)
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// AdminDeleteOwnership removes the ownership rule of the domain pattern, in the domain query parameter.
+func (s *Server) AdminDeleteOwnership(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	domain := r.URL.Query().Get("domain")
+	err := s.updateConfig(adminAudit(r, "ownership.delete", domain), func(config *Config) error {
+		i := findOwnership(config, domain)
+		if i < 0 {
+			return &adminError{http.StatusNotFound, "ownership not found"}
+		}
+		config.Ownership = append(config.Ownership[:i], config.Ownership[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findOwnership returns the index of the ownership rule of the domain pattern, or -1.
+func findOwnership(config *Config, domain string) int {
+	for i := range config.Ownership {
+		if strings.EqualFold(config.Ownership[i].Domain, domain) {
+			return i
+		}
+	}
+	return -1
+}
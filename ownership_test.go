@@ -0,0 +1,102 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEvents_Ownership(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "commerce", "type": "slack", "url": "https://hooks.slack.com/services/B"},
+			{"name": "it", "type": "slack", "url": "https://hooks.slack.com/services/C"}
+		],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops"]}],
+		"ownership": [
+			{"domain": "*.shop.example", "owner": "commerce", "destinations": ["commerce"]},
+			{"domain": "corp.example", "owner": "it", "destinations": ["it"], "events": ["zone_record.*"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	services := map[string]*failingService{"ops": {}, "commerce": {}, "it": {}}
+	for name, service := range services {
+		server.routing.services[name] = service
+	}
+
+	payload := `{"name": "%s", "request_identifier": "d4e5f6a7-owner-0000-00000000000%d", "data": {"domain": {"id": 1, "name": "%s"}, "zone_record": {"id": 1, "zone_id": "%[3]s", "type": "A", "name": "www", "content": "1.2.3.4"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	tests := []struct {
+		name   string
+		domain string
+	}{
+		{"domain.create", "eu.shop.example"},
+		{"domain.create", "corp.example"},
+		{"zone_record.create", "Corp.Example"},
+		{"zone_record.create", "shop.example"},
+	}
+	for i, tt := range tests {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, tt.name, i, tt.domain)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := http.StatusOK, response.Code; want != got {
+			t.Errorf("POST /events %v %v expected HTTP %v, got %v", tt.name, tt.domain, want, got)
+		}
+	}
+
+	for name, want := range map[string]int{"ops": 2, "commerce": 1, "it": 1} {
+		if got := services[name].sent; want != got {
+			t.Errorf("%v expected %v events, got %v", name, want, got)
+		}
+	}
+}
+
+func TestAdmin_Ownership(t *testing.T) {
+	server, store := newAdminTestServer()
+	adminRequest(server, "POST", "/admin/destinations", `{"name": "commerce", "type": "slack", "url": "https://hooks.slack.com/services/X/Y/Z"}`)
+
+	response := adminRequest(server, "POST", "/admin/ownership", `{"domain": "*.shop.example", "destinations": ["commerce"]}`)
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("POST /admin/ownership expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+	response = adminRequest(server, "POST", "/admin/ownership", `{"domain": "corp.example", "destinations": ["unknown"]}`)
+	if want := http.StatusUnprocessableEntity; want != response.Code {
+		t.Errorf("POST /admin/ownership (invalid) expected HTTP %v, got %v", want, response.Code)
+	}
+	response = adminRequest(server, "POST", "/admin/ownership", `{"domain": "*.shop.example", "owner": "commerce", "destinations": ["commerce"]}`)
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("POST /admin/ownership (update) expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+
+	response = adminRequest(server, "GET", "/admin/ownership", "")
+	var rules []OwnershipRule
+	if err := json.Unmarshal(response.Body.Bytes(), &rules); err != nil {
+		t.Fatalf("GET /admin/ownership returned invalid JSON: %v", err)
+	}
+	if want, got := 1, len(rules); want != got {
+		t.Fatalf("GET /admin/ownership expected %v rules, got %v", want, got)
+	}
+	if want, got := "commerce", rules[0].Owner; want != got {
+		t.Errorf("GET /admin/ownership expected owner %v, got %v", want, got)
+	}
+	saved, _ := store.Load()
+	if want, got := 1, len(saved.Ownership); want != got {
+		t.Errorf("expected %v persisted rules, got %v", want, got)
+	}
+
+	response = adminRequest(server, "DELETE", "/admin/ownership?domain="+url.QueryEscape("*.shop.example"), "")
+	if want := http.StatusNoContent; want != response.Code {
+		t.Errorf("DELETE /admin/ownership expected HTTP %v, got %v", want, response.Code)
+	}
+	response = adminRequest(server, "DELETE", "/admin/ownership?domain=corp.example", "")
+	if want := http.StatusNotFound; want != response.Code {
+		t.Errorf("DELETE /admin/ownership (unknown) expected HTTP %v, got %v", want, response.Code)
+	}
+}
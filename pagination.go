@@ -0,0 +1,100 @@
+package strillone
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+)
+
+// listPageSize is the number of entries requested per page, the maximum allowed by the API.
+const listPageSize = 100
+
+// listAll calls list for each page of a collection of the DNSimple API, from the first one
+// to the last one of the pagination, and stops at the first error.
+func listAll(ctx context.Context, list func(options dnsimple.ListOptions) (*dnsimple.Pagination, error)) error {
+	perPage := listPageSize
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		current := page
+		pagination, err := list(dnsimple.ListOptions{Page: &current, PerPage: &perPage})
+		if err != nil {
+			return err
+		}
+		if pagination == nil || page >= pagination.TotalPages {
+			return nil
+		}
+	}
+}
+
+// listDomains returns all the domains of the account.
+func listDomains(ctx context.Context, client *dnsimple.Client, accountID int64) ([]dnsimple.Domain, error) {
+	var domains []dnsimple.Domain
+	err := listAll(ctx, func(options dnsimple.ListOptions) (*dnsimple.Pagination, error) {
+		response, err := client.Domains.ListDomains(ctx, strconv.FormatInt(accountID, 10), &dnsimple.DomainListOptions{ListOptions: options})
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, response.Data...)
+		return response.Pagination, nil
+	})
+	return domains, err
+}
+
+// listContacts returns all the contacts of the account.
+func listContacts(ctx context.Context, client *dnsimple.Client, accountID int64) ([]dnsimple.Contact, error) {
+	var contacts []dnsimple.Contact
+	err := listAll(ctx, func(options dnsimple.ListOptions) (*dnsimple.Pagination, error) {
+		response, err := client.Contacts.ListContacts(ctx, strconv.FormatInt(accountID, 10), &options)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, response.Data...)
+		return response.Pagination, nil
+	})
+	return contacts, err
+}
+
+// listZones returns all the zones of the account.
+func listZones(ctx context.Context, client *dnsimple.Client, accountID int64) ([]dnsimple.Zone, error) {
+	var zones []dnsimple.Zone
+	err := listAll(ctx, func(options dnsimple.ListOptions) (*dnsimple.Pagination, error) {
+		response, err := client.Zones.ListZones(ctx, strconv.FormatInt(accountID, 10), &dnsimple.ZoneListOptions{ListOptions: options})
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, response.Data...)
+		return response.Pagination, nil
+	})
+	return zones, err
+}
+
+// listZoneRecords returns all the records of the zone.
+func listZoneRecords(ctx context.Context, client *dnsimple.Client, accountID int64, zone string) ([]dnsimple.ZoneRecord, error) {
+	var records []dnsimple.ZoneRecord
+	err := listAll(ctx, func(options dnsimple.ListOptions) (*dnsimple.Pagination, error) {
+		response, err := client.Zones.ListRecords(ctx, strconv.FormatInt(accountID, 10), zone, &dnsimple.ZoneRecordListOptions{ListOptions: options})
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, response.Data...)
+		return response.Pagination, nil
+	})
+	return records, err
+}
+
+// listCertificates returns all the certificates of the domain.
+func listCertificates(ctx context.Context, client *dnsimple.Client, accountID, domainID int64) ([]dnsimple.Certificate, error) {
+	var certificates []dnsimple.Certificate
+	err := listAll(ctx, func(options dnsimple.ListOptions) (*dnsimple.Pagination, error) {
+		response, err := client.Certificates.ListCertificates(ctx, strconv.FormatInt(accountID, 10), strconv.FormatInt(domainID, 10), &options)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, response.Data...)
+		return response.Pagination, nil
+	})
+	return certificates, err
+}
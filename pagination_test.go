@@ -0,0 +1,50 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+)
+
+func TestListAll(t *testing.T) {
+	var queries []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		switch r.URL.Query().Get("page") {
+		case "1":
+			fmt.Fprint(w, `{"data": [{"id": 1, "label": "Office"}, {"id": 2, "label": "Legal"}], "pagination": {"current_page": 1, "per_page": 2, "total_entries": 3, "total_pages": 2}}`)
+		default:
+			fmt.Fprint(w, `{"data": [{"id": 3, "label": "Tech"}], "pagination": {"current_page": 2, "per_page": 2, "total_entries": 3, "total_pages": 2}}`)
+		}
+	}))
+	defer api.Close()
+
+	client := dnsimple.NewClient(http.DefaultClient)
+	client.BaseURL = api.URL
+	contacts, err := listContacts(context.Background(), client, 1010)
+	if err != nil {
+		t.Fatalf("listContacts returned error: %v", err)
+	}
+	if want, got := 3, len(contacts); want != got {
+		t.Fatalf("listContacts expected %d contacts, got %d", want, got)
+	}
+	if want, got := "Tech", contacts[2].Label; want != got {
+		t.Errorf("listContacts expected the last contact %q, got %q", want, got)
+	}
+	if want, got := []string{"page=1&per_page=100", "page=2&per_page=100"}, queries; fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("listContacts expected the queries %v, got %v", want, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := listAll(ctx, func(dnsimple.ListOptions) (*dnsimple.Pagination, error) {
+		t.Error("listAll expected no call with a canceled context")
+		return nil, nil
+	}); err != context.Canceled {
+		t.Errorf("listAll expected %v, got %v", context.Canceled, err)
+	}
+}
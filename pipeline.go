@@ -0,0 +1,393 @@
+package strillone
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The stages of the webhook pipeline, in order.
+const (
+	StageVerifySignature = "verify_signature"
+	StageDeduplicate     = "deduplicate"
+	StageFilter          = "filter"
+	StageEnrich          = "enrich"
+	StageRedact          = "redact"
+	StageFormat          = "format"
+	StageDeliver         = "deliver"
+)
+
+var pipelineStages = []string{StageVerifySignature, StageDeduplicate, StageFilter, StageEnrich, StageRedact, StageFormat, StageDeliver}
+
+// skippableStages are the stages that the routes can skip.
+var skippableStages = map[string]bool{StageDeduplicate: true, StageEnrich: true}
+
+// Webhook is a webhook going through the stages of the pipeline.
+type Webhook struct {
+	Request  *http.Request
+	Response http.ResponseWriter
+
+	// Tenant is the name of the tenant, empty for the top-level configuration.
+	Tenant string
+
+	// Event is the event of the webhook, set by the verify_signature stage. The enrich stage replaces it
+	// with the event carrying its details, under the strillone field of the payload.
+	Event *webhook.Event
+
+	// Destinations are the destinations receiving the event immediately, set by the filter stage.
+	Destinations []string
+
+	// Messages are the messages of the event by destination, and Errors the formatting errors,
+	// set by the format stage.
+	Messages map[string]string
+	Errors   map[string]error
+
+	routing *routingTable
+	dryRun  bool
+
+	// duplicate is true if an event with the same content was received within the dedup window.
+	duplicate bool
+
+	digests []digestTarget
+	enrich  bool
+
+	// details are what the stages learn about the event, added to it by the enrich stage.
+	details eventDetails
+}
+
+// WebhookHandler processes a webhook. It writes the response when it stops the processing.
+type WebhookHandler func(webhook *Webhook)
+
+// Middleware is a stage of the pipeline. It calls next to continue the processing of the webhook,
+// or writes the response to stop it.
+type Middleware func(next WebhookHandler) WebhookHandler
+
+// Use adds the middleware to the pipeline, after the stage, and after the middlewares already added
+// to the stage. The middlewares are added before serving the webhooks, and can't follow the deliver stage.
+func (s *Server) Use(stage string, middleware Middleware) error {
+	if !isPipelineStage(stage) || stage == StageDeliver {
+		return fmt.Errorf("invalid pipeline stage %q", stage)
+	}
+	if s.middlewares == nil {
+		s.middlewares = map[string][]Middleware{}
+	}
+	s.middlewares[stage] = append(s.middlewares[stage], middleware)
+	s.pipeline = s.buildPipeline()
+	return nil
+}
+
+// buildPipeline chains the stages and the middlewares added after them.
+func (s *Server) buildPipeline() WebhookHandler {
+	stages := map[string]Middleware{
+		StageVerifySignature: s.verifySignature,
+		StageDeduplicate:     s.deduplicate,
+		StageFilter:          s.filter,
+		StageEnrich:          s.enrichEvent,
+		StageRedact:          s.redact,
+		StageFormat:          s.format,
+	}
+	handler := WebhookHandler(s.deliverEvent)
+	for i := len(pipelineStages) - 2; i >= 0; i-- {
+		stage := pipelineStages[i]
+		for j := len(s.middlewares[stage]) - 1; j >= 0; j-- {
+			handler = s.middlewares[stage][j](handler)
+		}
+		handler = stages[stage](handler)
+	}
+	return handler
+}
+
+func isPipelineStage(stage string) bool {
+	for _, s := range pipelineStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// handleWebhook runs the webhook of the request through the pipeline.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request, routing *routingTable) {
+	s.pipeline(&Webhook{Request: r, Response: w, Tenant: routing.name, routing: routing, dryRun: s.isDryRun(r)})
+}
+
+// skip stops the processing of the webhook, responding with the reason in the processing status.
+func (p *Webhook) skip(reason string) {
+	p.Response.Header().Set(headerProcessingStatus, "skipped;"+reason)
+	p.respond(http.StatusOK, &WebhookStatus{Status: webhookSkipped, Reason: reason})
+}
+
+// verifySignature reads the body of the request, verifies its timestamp and signature, and parses the event.
+func (s *Server) verifySignature(next WebhookHandler) WebhookHandler {
+	return func(p *Webhook) {
+		r, routing := p.Request, p.routing
+		if r.Method != "POST" {
+			p.reject(http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		_, span := tracer().Start(r.Context(), "webhook.parse")
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil && err.Error() == errBodyTooLarge {
+			recordError(span, err)
+			span.End()
+			p.reject(http.StatusRequestEntityTooLarge, err.Error())
+			log.Warn().Err(err).Msg("Error reading the body")
+			return
+		}
+		if err != nil {
+			recordError(span, err)
+			span.End()
+			p.reject(http.StatusBadRequest, err.Error())
+			log.Warn().Err(err).Msg("Error reading the body")
+			return
+		}
+
+		if err := routing.verifyTimestamp(r, time.Now()); err != nil {
+			recordError(span, err)
+			span.End()
+			p.reject(http.StatusUnauthorized, err.Error())
+			log.Warn().Err(err).Msg("Rejecting event")
+			return
+		}
+
+		if err := routing.verifySignature(r, data); err != nil {
+			recordError(span, err)
+			span.End()
+			p.reject(http.StatusUnauthorized, err.Error())
+			log.Warn().Err(err).Msg("Rejecting event")
+			return
+		}
+
+		event, err := webhook.ParseEvent(data)
+		if err != nil {
+			recordError(span, err)
+			span.End()
+			p.reject(http.StatusBadRequest, err.Error())
+			log.Warn().Err(err).Msg("Error parsing event")
+			return
+		}
+		span.SetAttributes(eventAttributes(event)...)
+		if err := validatePayload(event); err != nil {
+			recordError(span, err)
+			span.SetAttributes(attribute.String("event.skipped", "invalid-payload"))
+			span.End()
+			withEvent(log.Warn(), event).Err(err).Str("tenant", routing.name).Str("outcome", "skipped").Msg("Skipping event: invalid payload")
+			s.notifyOperator(routing, invalidPayloadNotice(event, err, data))
+			p.skip("invalid-payload")
+			return
+		}
+		span.End()
+		if timestamp, ok := parseHeaderTimestamp(r.Header.Get(routing.timestampHeader)); ok {
+			p.details.Timestamp = &timestamp
+		}
+
+		p.Event = event
+		next(p)
+	}
+}
+
+// deduplicate stops the events already processed and the replayed webhooks, and marks the events
+// with the same content as a recent event, that the filter stage only routes to the routes skipping
+// the deduplication. The events of the dry runs are released afterwards, so that they can be delivered.
+func (s *Server) deduplicate(next WebhookHandler) WebhookHandler {
+	return func(p *Webhook) {
+		routing, event := p.routing, p.Event
+
+		_, cacheExists := s.webhookCache.Get(routing.cacheKey(event))
+		if cacheExists && !s.devMode {
+			withEvent(log.Info(), event).Str("tenant", routing.name).Str("outcome", "skipped").Msg("Skipping event: already processed")
+			p.skip("already-processed")
+			return
+		}
+
+		if routing.replayWindow > 0 && !s.replays.claim(routing.cacheKey(event), routing.replayWindow) {
+			withEvent(log.Warn(), event).Str("tenant", routing.name).Msg("Rejecting event: replayed")
+			p.reject(http.StatusConflict, "replayed webhook")
+			return
+		}
+
+		if routing.dedupWindow > 0 && !s.duplicates.claim(routing.dedupKey(event), routing.dedupWindow) {
+			p.duplicate = true
+		}
+
+		if p.dryRun && p.duplicate {
+			defer s.replays.release(routing.cacheKey(event))
+		} else if p.dryRun {
+			defer s.forget(routing, event)
+		}
+		next(p)
+	}
+}
+
+// skipDuplicate stops the processing of a duplicate event.
+func (p *Webhook) skipDuplicate() {
+	withEvent(log.Info(), p.Event).Str("tenant", p.Tenant).Str("outcome", "skipped").Msg("Skipping event: duplicate")
+	p.skip("duplicate")
+}
+
+// filter selects the destinations of the event with the routes, and stops the events without destination.
+func (s *Server) filter(next WebhookHandler) WebhookHandler {
+	return func(p *Webhook) {
+		routing, event := p.routing, p.Event
+
+		var exclude func(route *RouteConfig) bool
+		if p.duplicate {
+			exclude = func(route *RouteConfig) bool { return !route.skips(StageDeduplicate) }
+		}
+		exclude = excludeTags(s.eventTags(p.Request.Context(), routing, event), excludeActor(event.Actor, exclude))
+		p.Destinations = routing.lookup(event.Name, exclude)
+		routed := len(p.Destinations)
+		if p.dryRun {
+			p.details.ContactEmailChanged = s.contactEmails.changed(routing.name, event)
+		} else {
+			p.details.ContactEmailChanged = s.contactEmails.observe(routing.name, event)
+		}
+		var security string
+		if !p.duplicate {
+			p.Destinations = routing.lookupOwners(event, p.Destinations)
+			security = routing.securityDestination(event, &p.details)
+		}
+		if security != "" && !containsString(p.Destinations, security) {
+			p.Destinations = append(p.Destinations, security)
+		}
+		p.digests = routing.lookupDigests(event.Name, p.Destinations, exclude)
+		if p.duplicate && len(p.Destinations) == 0 && len(p.digests) == 0 {
+			p.skipDuplicate()
+			return
+		}
+
+		stats := s.stats.Tenant(routing.name)
+		if !p.dryRun {
+			stats.received()
+			s.observeUnknownEvent(routing, stats, event)
+			s.recordEvent(routing, event)
+			s.archiveEvent(routing, event)
+			s.publishEvent(p.Request.Context(), routing, event)
+			s.detectAnomalies(p.Request.Context(), routing, event, time.Now())
+			p.details.PreviousZoneRecord = s.zoneRecords.observe(routing.name, event)
+		} else {
+			p.details.PreviousZoneRecord = s.zoneRecords.previousVersion(routing.name, event)
+		}
+
+		if silence := silenced(s.currentSilences(), event, time.Now()); silence != nil && !p.dryRun {
+			if security == "" {
+				withEvent(log.Info(), event).Str("tenant", routing.name).Str("silence", silence.ID).Str("outcome", "skipped").Msg("Skipping event: silenced")
+				stats.skipped()
+				p.skip("silenced")
+				return
+			}
+			withEvent(log.Info(), event).Str("tenant", routing.name).Str("silence", silence.ID).Msg("Delivering the silenced event to the security destination only")
+			p.Destinations, p.digests = []string{security}, nil
+		}
+		if !p.dryRun {
+			s.checkChangeWindows(p.Request.Context(), routing, event, time.Now())
+		}
+
+		approval := !p.dryRun && routing.requiresApproval(event)
+		if !p.dryRun && !approval && len(p.Destinations) == 0 && len(p.digests) == 0 {
+			withEvent(log.Info(), event).Str("tenant", routing.name).Str("outcome", "skipped").Msg("Skipping event: no route matches")
+			stats.skipped()
+			p.skip("no-route")
+			return
+		}
+
+		// the security events aren't sampled
+		if !p.dryRun && !approval && security == "" && !s.sampleEvent(routing, event, sampledDestinations(p), time.Now()) {
+			withEvent(log.Info(), event).Str("tenant", routing.name).Str("outcome", "skipped").Msg("Skipping event: sampled")
+			stats.skipped()
+			p.skip("sampled")
+			return
+		}
+
+		// the owners of the domains don't skip the enrichment
+		p.enrich = approval || len(p.Destinations) > routed
+		for i := range routing.routes {
+			route := &routing.routes[i]
+			if !p.enrich && !route.skips(StageEnrich) && !exclude(route) && routing.routeMatches(i, event.Name) {
+				p.enrich = true
+			}
+		}
+		next(p)
+	}
+}
+
+// enrichEvent completes the details of the event with those fetched from the DNSimple API,
+// unless every route of the event skips the enrichment, and adds the details to the event.
+func (s *Server) enrichEvent(next WebhookHandler) WebhookHandler {
+	return func(p *Webhook) {
+		if p.enrich {
+			s.enrich(p.Request.Context(), p.routing, p.Event, &p.details)
+		}
+		p.Event = withDetails(p.Event, &p.details)
+		next(p)
+	}
+}
+
+// format formats the messages of the event for its destinations.
+func (s *Server) format(next WebhookHandler) WebhookHandler {
+	return func(p *Webhook) {
+		p.Messages = map[string]string{}
+		for _, name := range p.Destinations {
+			text, err := formatMessage(p.routing.services[name], p.Event)
+			if err != nil {
+				if p.Errors == nil {
+					p.Errors = map[string]error{}
+				}
+				p.Errors[name] = err
+				continue
+			}
+			p.Messages[name] = text
+		}
+		next(p)
+	}
+}
+
+// deliverEvent delivers the event to its destinations, or returns the messages in the dry runs.
+func (s *Server) deliverEvent(p *Webhook) {
+	if p.dryRun {
+		s.preview(p)
+		return
+	}
+	s.holdForApproval(p.Request.Context(), p.routing, p.Event)
+	s.requireAcknowledgement(p.Request.Context(), p.routing, p.Event)
+	if len(p.Destinations) == 0 && len(p.digests) == 0 {
+		withEvent(log.Info(), p.Event).Str("tenant", p.Tenant).Str("outcome", "skipped").Msg("Skipping event: no route matches")
+		s.stats.Tenant(p.Tenant).skipped()
+		p.skip("no-route")
+		return
+	}
+	s.publish(p)
+}
+
+// readEvent verifies and parses the event in the request body, for the handlers outside of the pipeline,
+// and returns its details. It returns false if the request was already handled, either because of an error
+// or because the event was already processed by the tenant.
+func (s *Server) readEvent(w http.ResponseWriter, r *http.Request, routing *routingTable) (*webhook.Event, *eventDetails, bool) {
+	var event *webhook.Event
+	var details *eventDetails
+	read := s.verifySignature(s.deduplicate(func(p *Webhook) {
+		if p.duplicate {
+			p.skipDuplicate()
+			return
+		}
+		event, details = p.Event, &p.details
+	}))
+	read(&Webhook{Request: r, Response: w, Tenant: routing.name, routing: routing})
+	return event, details, event != nil
+}
+
+// skips returns true if the route skips the stage of the pipeline.
+func (r *RouteConfig) skips(stage string) bool {
+	for _, s := range r.Skip {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
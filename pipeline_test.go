@@ -0,0 +1,127 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEvents_SkipDeduplicate(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "audit", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [
+			{"destinations": ["ops"]},
+			{"destinations": ["audit"], "skip": ["deduplicate", "enrich"]}
+		],
+		"dedup_window": "10m"
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops, audit := &failingService{}, &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["audit"] = audit
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+	for _, requestID := range []string{"a1c4e7f0-pipeline-0000-000000000001", "a1c4e7f0-pipeline-0000-000000000002"} {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, requestID)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := http.StatusOK, response.Code; want != got {
+			t.Fatalf("POST /events %v expected HTTP %v, got %v", requestID, want, got)
+		}
+	}
+
+	if want, got := 1, ops.sent; want != got {
+		t.Errorf("ops expected %v events, got %v", want, got)
+	}
+	if want, got := 2, audit.sent; want != got {
+		t.Errorf("audit expected %v events, got %v", want, got)
+	}
+}
+
+func TestServer_Use(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops := &failingService{}
+	server.routing.services["ops"] = ops
+
+	var stages []string
+	var messages map[string]string
+	if err := server.Use(StageFilter, func(next WebhookHandler) WebhookHandler {
+		return func(webhook *Webhook) {
+			stages = append(stages, StageFilter)
+			if webhook.Event.Name == "domain.delete" {
+				webhook.Response.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next(webhook)
+		}
+	}); err != nil {
+		t.Fatalf("Use returned error: %v", err)
+	}
+	if err := server.Use(StageFormat, func(next WebhookHandler) WebhookHandler {
+		return func(webhook *Webhook) {
+			stages = append(stages, StageFormat)
+			messages = webhook.Messages
+			next(webhook)
+		}
+	}); err != nil {
+		t.Fatalf("Use returned error: %v", err)
+	}
+	if err := server.Use(StageDeliver, func(next WebhookHandler) WebhookHandler { return next }); err == nil {
+		t.Errorf("Use expected an error after the deliver stage")
+	}
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "%s", "request_identifier": "%s"}`
+	tests := []struct {
+		name      string
+		requestID string
+		want      int
+		stages    string
+	}{
+		{"domain.create", "b2d5f8a1-pipeline-0000-000000000001", http.StatusOK, "filter,format"},
+		{"domain.delete", "b2d5f8a1-pipeline-0000-000000000002", http.StatusNoContent, "filter"},
+	}
+	for _, tt := range tests {
+		stages = nil
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, tt.name, tt.requestID)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := tt.want, response.Code; want != got {
+			t.Errorf("POST /events %v expected HTTP %v, got %v", tt.name, want, got)
+		}
+		if want, got := tt.stages, strings.Join(stages, ","); want != got {
+			t.Errorf("POST /events %v expected stages %v, got %v", tt.name, want, got)
+		}
+	}
+
+	if want, got := 1, ops.sent; want != got {
+		t.Errorf("ops expected %v events, got %v", want, got)
+	}
+	if want := "example.com"; !strings.Contains(messages["ops"], want) {
+		t.Errorf("format middleware expected the message of ops to contain %q, got %q", want, messages["ops"])
+	}
+}
+
+func TestParseConfig_InvalidSkip(t *testing.T) {
+	_, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"], "skip": ["verify_signature"]}]
+	}`))
+	if err == nil {
+		t.Fatalf("ParseConfig expected an error")
+	}
+}
@@ -0,0 +1,129 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+const headerProcessingAccepted = "accepted"
+
+// workerPool runs the deliveries on a bounded number of goroutines,
+// with a bounded number of deliveries waiting for a worker.
+type workerPool struct {
+	size  int
+	tasks chan func()
+
+	mu      sync.RWMutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+func newWorkerPool(size, depth int) *workerPool {
+	p := &workerPool{size: size, tasks: make(chan func(), depth)}
+	p.running.Add(size)
+	for i := 0; i < size; i++ {
+		go func() {
+			defer p.running.Done()
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues the task, and returns false if the pool is full or closed.
+func (p *workerPool) submit(task func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.tasks <- task:
+		return true
+	default:
+		return false
+	}
+}
+
+// run queues the task, waiting for room in the pool. It returns false if the pool is closed.
+func (p *workerPool) run(task func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	p.tasks <- task
+	return true
+}
+
+// close stops accepting tasks, and waits for the queued ones or the end of the context.
+func (p *workerPool) close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetWorkerPool decouples the webhook ingestion from the delivery: the webhooks are acknowledged
+// with a 202 as soon as they are accepted, and delivered by size concurrent workers.
+// Up to depth webhooks can wait for a worker, then the webhooks are rejected with a 503.
+//
+// With the queue enabled, the workers deliver the queued jobs concurrently.
+func (s *Server) SetWorkerPool(size, depth int) {
+	if size <= 0 {
+		size = 1
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	s.pool = newWorkerPool(size, depth)
+}
+
+// accept submits the delivery of the event to the worker pool.
+// It returns an error if the pool is full, so that DNSimple sends the webhook again later.
+func (s *Server) accept(ctx context.Context, event *webhook.Event, routing *routingTable, names []string) error {
+	ctx = detachedContext(ctx)
+	accepted := s.pool.submit(func() {
+		s.deliverAsync(ctx, event, routing, names)
+	})
+	if !accepted {
+		s.forget(routing, event)
+		withEvent(log.Warn(), event).Str("tenant", routing.name).Msg("Rejecting event: delivery queue full")
+		return errors.New("delivery queue full")
+	}
+
+	s.webhookCache.Set(routing.cacheKey(event), "1")
+	return nil
+}
+
+// deliverAsync delivers the event to the destinations, on a worker.
+func (s *Server) deliverAsync(ctx context.Context, event *webhook.Event, routing *routingTable, names []string) {
+	stats := s.stats.Tenant(routing.name)
+	for _, name := range names {
+		if _, err := s.deliver(ctx, routing, name, event); err != nil {
+			stats.failed()
+			continue
+		}
+		stats.delivered()
+	}
+}
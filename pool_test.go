@@ -0,0 +1,93 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+type gatedService struct {
+	SlackService
+	started chan string
+	release chan struct{}
+}
+
+func (s *gatedService) PostEvent(_ context.Context, event *webhook.Event) (string, error) {
+	s.started <- event.RequestID
+	<-s.release
+	return "ok", nil
+}
+
+func TestServer_WorkerPool(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetWorkerPool(1, 1)
+	service := &gatedService{started: make(chan string, 2), release: make(chan struct{})}
+	server.routing.services["ops"] = service
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+	post := func(requestID string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, requestID)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	// the first event is delivering, the second waits for the worker
+	response := post("9f1c2a7e-pool-0000-000000000001")
+	if want, got := http.StatusAccepted, response.Code; want != got {
+		t.Fatalf("POST /events expected HTTP %v, got %v", want, got)
+	}
+	if want, got := headerProcessingAccepted, response.Header().Get(headerProcessingStatus); want != got {
+		t.Errorf("POST /events expected status header %v, got %v", want, got)
+	}
+	<-service.started
+	if want, got := http.StatusAccepted, post("9f1c2a7e-pool-0000-000000000002").Code; want != got {
+		t.Fatalf("POST /events expected HTTP %v, got %v", want, got)
+	}
+
+	// the third event doesn't fit in the pool
+	if want, got := http.StatusServiceUnavailable, post("9f1c2a7e-pool-0000-000000000003").Code; want != got {
+		t.Errorf("POST /events with a full pool expected HTTP %v, got %v", want, got)
+	}
+
+	close(service.release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+	if want, got := "9f1c2a7e-pool-0000-000000000002", <-service.started; want != got {
+		t.Errorf("second delivery expected %v, got %v", want, got)
+	}
+
+	stats := server.stats.Snapshot()
+	if want, got := (TenantStats{Received: 3, Delivered: 2}), stats[defaultTenant]; want != got {
+		t.Errorf("stats expected %+v, got %+v", want, got)
+	}
+}
+
+func TestWorkerPool_Closed(t *testing.T) {
+	pool := newWorkerPool(1, 1)
+	if err := pool.close(context.Background()); err != nil {
+		t.Fatalf("close returned error: %v", err)
+	}
+	if pool.submit(func() {}) {
+		t.Errorf("submit on a closed pool expected false")
+	}
+	if pool.run(func() {}) {
+		t.Errorf("run on a closed pool expected false")
+	}
+}
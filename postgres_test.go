@@ -0,0 +1,183 @@
+package strillone
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestPostgres opens the store in the database of STRILLONE_TEST_DATABASE_URL, emptied,
+// and skips the test when not set.
+func openTestPostgres(t *testing.T) *PostgresStore {
+	url := os.Getenv("STRILLONE_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("STRILLONE_TEST_DATABASE_URL not set")
+	}
+	store, err := OpenStore(url)
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	postgres := store.(*PostgresStore)
+	if _, err := postgres.db.Exec(`TRUNCATE strillone_events, strillone_jobs, strillone_job_keys`); err != nil {
+		t.Fatal(err)
+	}
+	// migrating again is a no-op
+	if err := postgres.migrate(context.Background()); err != nil {
+		t.Fatalf("migrate returned error: %v", err)
+	}
+	t.Cleanup(func() { postgres.db.Close() })
+	return postgres
+}
+
+func TestOpenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := OpenStore(filepath.Join(dir, "strillone.db"))
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	history, err := store.History()
+	if err != nil {
+		t.Fatalf("History returned error: %v", err)
+	}
+	if _, ok := history.(*BoltHistory); !ok {
+		t.Errorf("History expected a BoltHistory, got %T", history)
+	}
+	history.Close()
+}
+
+func TestPostgresHistory(t *testing.T) {
+	store := openTestPostgres(t)
+	history, _ := store.History()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	events := []*HistoryEvent{
+		{Name: "domain.create", RequestID: "1", Domain: "example.com", ReceivedAt: now.Add(-2 * time.Hour), Payload: []byte(`{"name":"domain.create"}`)},
+		{Name: "zone_record.create", RequestID: "2", Domain: "example.com", ReceivedAt: now.Add(-time.Hour)},
+		{Name: "domain.delete", RequestID: "3", Domain: "example.org", ReceivedAt: now},
+	}
+	for _, event := range events {
+		if err := history.Record(event); err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+	}
+	if err := history.AddAttempt("", "2", &DeliveryAttempt{Destination: "ops", Status: "failed"}); err != nil {
+		t.Fatalf("AddAttempt returned error: %v", err)
+	}
+	if err := history.AddAttempt("", "4", &DeliveryAttempt{Destination: "ops", Status: "failed"}); err != ErrEventNotFound {
+		t.Errorf("AddAttempt to an unknown event expected %v, got %v", ErrEventNotFound, err)
+	}
+
+	tests := []struct {
+		filter   HistoryFilter
+		expected []string
+	}{
+		{HistoryFilter{}, []string{"3", "2", "1"}},
+		{HistoryFilter{Event: "domain.*"}, []string{"3", "1"}},
+		{HistoryFilter{Domain: "example.com"}, []string{"2", "1"}},
+		{HistoryFilter{Since: now.Add(-90 * time.Minute)}, []string{"3", "2"}},
+		{HistoryFilter{Until: now.Add(-90 * time.Minute)}, []string{"1"}},
+		{HistoryFilter{Status: "failed"}, []string{"2"}},
+		{HistoryFilter{Limit: 1}, []string{"3"}},
+		{HistoryFilter{Event: "domain.*", Limit: 1}, []string{"3"}},
+		{HistoryFilter{Domain: "example.com", Limit: 1}, []string{"2"}},
+	}
+	for _, test := range tests {
+		found, err := history.Query(&test.filter)
+		if err != nil {
+			t.Fatalf("Query returned error: %v", err)
+		}
+		var ids []string
+		for _, event := range found {
+			ids = append(ids, event.RequestID)
+		}
+		if want, got := strings.Join(test.expected, ","), strings.Join(ids, ","); want != got {
+			t.Errorf("Query(%+v) expected %v, got %v", test.filter, want, got)
+		}
+	}
+
+	event, err := history.Get("", "1")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if want, got := `{"name":"domain.create"}`, string(event.Payload); want != got || !event.ReceivedAt.Equal(events[0].ReceivedAt) {
+		t.Errorf("Get expected payload %v received at %v, got %v at %v", want, events[0].ReceivedAt, got, event.ReceivedAt)
+	}
+
+	pruner := history.(HistoryPruner)
+	expired, err := pruner.Expired(now.Add(-90*time.Minute), 0, 10)
+	if err != nil {
+		t.Fatalf("Expired returned error: %v", err)
+	}
+	if want, got := 1, len(expired); want != got {
+		t.Fatalf("Expired expected %v events, got %v", want, got)
+	}
+	if err := pruner.Remove(expired); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	if _, err := history.Get("", "1"); err != ErrEventNotFound {
+		t.Errorf("Get of a removed event expected %v, got %v", ErrEventNotFound, err)
+	}
+}
+
+func TestPostgresQueue(t *testing.T) {
+	store := openTestPostgres(t)
+	queue, _ := store.Queue()
+
+	now := time.Now()
+	jobs := []*Job{
+		{Destination: "a", NextAttempt: now, Key: "a", CreatedAt: now},
+		{Destination: "b", NextAttempt: now.Add(time.Hour)},
+		{Destination: "c", NextAttempt: now},
+		{Destination: "a", NextAttempt: now, Key: "a", CreatedAt: now},
+	}
+	if err := queue.Enqueue(jobs...); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if jobs[0].ID == 0 || jobs[0].ID >= jobs[2].ID || jobs[3].ID != 0 {
+		t.Errorf("Enqueue expected increasing IDs and a duplicate skipped, got %v, %v and %v", jobs[0].ID, jobs[2].ID, jobs[3].ID)
+	}
+
+	due, err := queue.Due(now, 10)
+	if err != nil {
+		t.Fatalf("Due returned error: %v", err)
+	}
+	if want, got := 2, len(due); want != got {
+		t.Fatalf("Due expected %v jobs, got %v", want, got)
+	}
+	if want, got := "a", due[0].Destination; want != got {
+		t.Errorf("Due expected first job %v, got %v", want, got)
+	}
+	// the jobs are locked until updated
+	if again, _ := queue.Due(now, 10); len(again) != 0 {
+		t.Errorf("Due expected the jobs to be locked, got %v jobs", len(again))
+	}
+
+	if err := queue.Delete(due[0].ID); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if err := queue.Bury(due[1]); err != nil {
+		t.Fatalf("Bury returned error: %v", err)
+	}
+	if n, dead, err := queue.Len(); err != nil || n != 1 || dead != 1 {
+		t.Errorf("Len expected 1 job and 1 dead letter, got %v and %v (%v)", n, dead, err)
+	}
+	if err := queue.Redeliver(due[1].ID, now); err != nil {
+		t.Fatalf("Redeliver returned error: %v", err)
+	}
+	if err := queue.DeleteDeadLetter(due[1].ID); err != ErrJobNotFound {
+		t.Errorf("DeleteDeadLetter of a redelivered job expected %v, got %v", ErrJobNotFound, err)
+	}
+	due, _ = queue.Due(now, 10)
+	if want, got := 1, len(due); want != got || due[0].Destination != "c" {
+		t.Errorf("Due expected the redelivered job, got %v jobs", got)
+	}
+}
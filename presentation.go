@@ -0,0 +1,156 @@
+package strillone
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultSlackIcon is the avatar of the Slack messages.
+const defaultSlackIcon = "http://cl.ly/2t0u2Q380N3y/trusty.png"
+
+var (
+	hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+	emojiPattern    = regexp.MustCompile(`^:[a-z0-9_+\-']+:$`)
+	soundPattern    = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// namedColors are the hex colors of the Slack named colors, for the destinations without them, such as Teams.
+var namedColors = map[string]string{"good": "#2EB886", "warning": "#DAA038", "danger": "#A30200"}
+
+// The severities of the alerts of PagerDuty, and of the similar paging services.
+var alertSeverities = []string{"critical", "error", "warning", "info"}
+
+// PresentationConfig is how the messages about a family of events, or of a severity, are presented in every destination.
+// The values not set are the ones of the severity of the event, then the defaults of the severity.
+type PresentationConfig struct {
+	// Emoji marks the header of the messages, such as ":globe_with_meridians:".
+	Emoji string `json:"emoji,omitempty"`
+
+	// Color is the color of the messages: good, warning, danger, or a hex color such as #439FE0.
+	Color string `json:"color,omitempty"`
+
+	// Icon is the avatar of the messages: an emoji such as ":dnsimple:", or the URL of an image.
+	Icon string `json:"icon,omitempty"`
+
+	// Sound is the sound of the push notifications, such as the Pushover siren.
+	Sound string `json:"sound,omitempty"`
+
+	// AlertSeverity is the severity of the alerts of the paging services: critical, error, warning or info,
+	// such as the PagerDuty severity.
+	AlertSeverity string `json:"alert_severity,omitempty"`
+}
+
+// HexColor returns the color as a hex color, such as the theme color of a Teams card.
+func (p *PresentationConfig) HexColor() string {
+	if color, ok := namedColors[p.Color]; ok {
+		return color
+	}
+	return p.Color
+}
+
+func (p *PresentationConfig) validate() error {
+	if p.Emoji != "" && !emojiPattern.MatchString(p.Emoji) {
+		return fmt.Errorf("invalid emoji %q", p.Emoji)
+	}
+	switch {
+	case p.Color == "", p.Color == "good", p.Color == "warning", p.Color == "danger", hexColorPattern.MatchString(p.Color):
+	default:
+		return fmt.Errorf("invalid color %q", p.Color)
+	}
+	if p.Icon != "" && !emojiPattern.MatchString(p.Icon) && validateDashboardURL(p.Icon) != nil {
+		return fmt.Errorf("invalid icon %q", p.Icon)
+	}
+	if p.Sound != "" && !soundPattern.MatchString(p.Sound) {
+		return fmt.Errorf("invalid sound %q", p.Sound)
+	}
+	if p.AlertSeverity != "" && !isAlertSeverity(p.AlertSeverity) {
+		return fmt.Errorf("invalid alert severity %q, expected %s", p.AlertSeverity, strings.Join(alertSeverities, ", "))
+	}
+	return nil
+}
+
+func isAlertSeverity(severity string) bool {
+	for _, s := range alertSeverities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+// eventFamily returns the family of the event, such as domain for domain.create.
+func eventFamily(eventName string) string {
+	if i := strings.Index(eventName, "."); i >= 0 {
+		return eventName[:i]
+	}
+	return eventName
+}
+
+// validatePresentation checks the presentation of the event families.
+func validatePresentation(presentation map[string]PresentationConfig) error {
+	families := map[string]bool{}
+	for _, name := range EventNames {
+		families[eventFamily(name)] = true
+	}
+	for family, p := range presentation {
+		if !families[family] {
+			return fmt.Errorf("presentation: unknown event family %q", family)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("presentation: %s: %v", family, err)
+		}
+	}
+	return nil
+}
+
+// validateSeverityPresentation checks the presentation of the severities.
+func validateSeverityPresentation(presentation map[Severity]PresentationConfig) error {
+	for severity, p := range presentation {
+		if _, ok := severityRanks[severity]; !ok {
+			return fmt.Errorf("severity presentation: invalid severity %q", severity)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("severity presentation: %s: %v", severity, err)
+		}
+	}
+	return nil
+}
+
+// eventPresentation returns the presentation of the event: the one of its family,
+// completed with the one of its severity, then with the defaults of its severity.
+func eventPresentation(presentation map[string]PresentationConfig, severities map[Severity]PresentationConfig, eventName string, severity Severity) PresentationConfig {
+	p := presentation[eventFamily(eventName)]
+	byseverity := severities[severity]
+	if p.Emoji == "" {
+		p.Emoji = byseverity.Emoji
+	}
+	if p.Emoji == "" {
+		p.Emoji = severityEmoji(severity)
+	}
+	if p.Color == "" {
+		p.Color = byseverity.Color
+	}
+	if p.Color == "" {
+		p.Color = severityColor(severity)
+	}
+	if p.Icon == "" {
+		p.Icon = byseverity.Icon
+	}
+	if p.Icon == "" {
+		p.Icon = defaultSlackIcon
+	}
+	if p.Sound == "" {
+		p.Sound = byseverity.Sound
+	}
+	if p.Sound == "" {
+		p.Sound = severitySound(severity)
+	}
+	if p.AlertSeverity == "" {
+		p.AlertSeverity = byseverity.AlertSeverity
+	}
+	if p.AlertSeverity == "" {
+		p.AlertSeverity = string(severity)
+	}
+	return p
+}
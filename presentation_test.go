@@ -0,0 +1,116 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_eventPresentation(t *testing.T) {
+	presentation := map[string]PresentationConfig{
+		"domain":      {Emoji: ":globe_with_meridians:", Color: "#439FE0", Icon: ":dnsimple:"},
+		"certificate": {Color: "good"},
+	}
+	severities := map[Severity]PresentationConfig{
+		SeverityWarning: {Color: "#FFA500", Sound: "bugle", AlertSeverity: "error"},
+	}
+
+	tests := []struct {
+		eventName string
+		severity  Severity
+		want      PresentationConfig
+	}{
+		{"domain.create", SeverityInfo, PresentationConfig{Emoji: ":globe_with_meridians:", Color: "#439FE0", Icon: ":dnsimple:", Sound: "pushover", AlertSeverity: "info"}},
+		{"certificate.auto_renewal_failed", SeverityCritical, PresentationConfig{Emoji: ":rotating_light:", Color: "good", Icon: defaultSlackIcon, Sound: "siren", AlertSeverity: "critical"}},
+		{"contact.delete", SeverityWarning, PresentationConfig{Emoji: ":warning:", Color: "#FFA500", Icon: defaultSlackIcon, Sound: "bugle", AlertSeverity: "error"}},
+		{"domain.delete", SeverityWarning, PresentationConfig{Emoji: ":globe_with_meridians:", Color: "#439FE0", Icon: ":dnsimple:", Sound: "bugle", AlertSeverity: "error"}},
+		{"contact.create", SeverityInfo, PresentationConfig{Color: "good", Icon: defaultSlackIcon, Sound: "pushover", AlertSeverity: "info"}},
+	}
+	for _, test := range tests {
+		if got := eventPresentation(presentation, severities, test.eventName, test.severity); test.want != got {
+			t.Errorf("eventPresentation(%v) expected %+v, got %+v", test.eventName, test.want, got)
+		}
+	}
+}
+
+func TestPresentationConfig_HexColor(t *testing.T) {
+	for color, want := range map[string]string{"danger": "#A30200", "#439FE0": "#439FE0"} {
+		p := &PresentationConfig{Color: color}
+		if got := p.HexColor(); want != got {
+			t.Errorf("HexColor(%v) expected %v, got %v", color, want, got)
+		}
+	}
+}
+
+func Test_validateSeverityPresentation(t *testing.T) {
+	if err := validateSeverityPresentation(map[Severity]PresentationConfig{SeverityCritical: {Color: "danger", Sound: "siren", AlertSeverity: "critical"}}); err != nil {
+		t.Errorf("validateSeverityPresentation returned error: %v", err)
+	}
+	tests := []map[Severity]PresentationConfig{
+		{"fatal": {Color: "danger"}},
+		{SeverityCritical: {AlertSeverity: "high"}},
+		{SeverityCritical: {Sound: "Siren!"}},
+	}
+	for _, presentation := range tests {
+		if err := validateSeverityPresentation(presentation); err == nil {
+			t.Errorf("validateSeverityPresentation(%v) expected error", presentation)
+		}
+	}
+}
+
+func Test_validatePresentation(t *testing.T) {
+	tests := []struct {
+		presentation map[string]PresentationConfig
+		valid        bool
+	}{
+		{map[string]PresentationConfig{"zone_record": {Emoji: ":pencil2:", Color: "#aabbcc", Icon: "https://example.com/icon.png"}}, true},
+		{map[string]PresentationConfig{"zone-record": {Emoji: ":pencil2:"}}, false},
+		{map[string]PresentationConfig{"domain": {Emoji: "pencil2"}}, false},
+		{map[string]PresentationConfig{"domain": {Color: "red"}}, false},
+		{map[string]PresentationConfig{"domain": {Icon: "icon.png"}}, false},
+	}
+	for _, test := range tests {
+		err := validatePresentation(test.presentation)
+		if test.valid && err != nil {
+			t.Errorf("validatePresentation(%v) returned error: %v", test.presentation, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validatePresentation(%v) expected error", test.presentation)
+		}
+	}
+}
+
+func TestSlackService_PostEventPresentation(t *testing.T) {
+	var payload slackPayload
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid Slack payload: %v", err)
+		}
+	}))
+	defer target.Close()
+
+	service := &SlackService{URL: target.URL, Presentation: map[string]PresentationConfig{
+		"domain": {Emoji: ":globe_with_meridians:", Color: "#439FE0", Icon: ":dnsimple:"},
+	}}
+	event, _ := webhook.ParseEvent([]byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create"}`))
+	if _, err := service.PostEvent(context.Background(), event); err != nil {
+		t.Fatalf("PostEvent returned error: %v", err)
+	}
+
+	if want, got := ":dnsimple:", payload.IconEmoji; want != got {
+		t.Errorf("PostEvent expected icon %v, got %v", want, got)
+	}
+	if payload.IconURL != "" {
+		t.Errorf("PostEvent expected no icon URL, got %v", payload.IconURL)
+	}
+	if want, got := "#439FE0", payload.Attachments[0].Color; want != got {
+		t.Errorf("PostEvent expected color %v, got %v", want, got)
+	}
+	if want, got := ":globe_with_meridians: domain.create", payload.Attachments[0].Blocks[0].Text.Text; want != got {
+		t.Errorf("PostEvent expected header %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,94 @@
+package strillone
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Previewer is implemented by the destinations that render the payload they deliver, such as the Slack JSON,
+// a Teams card or an email, so that /api/preview returns it.
+type Previewer interface {
+	// Preview returns the payload delivering the message of the event, without delivering it.
+	// The context has the Annotations of the message.
+	Preview(ctx context.Context, event *webhook.Event, text string) (*Preview, error)
+}
+
+// Preview is the payload delivering a message to a destination.
+type Preview struct {
+	ContentType string
+	Body        []byte
+}
+
+// PreviewResponse represents the response of /api/preview.
+type PreviewResponse struct {
+	Destination string `json:"destination"`
+	Event       string `json:"event"`
+
+	// Text is the formatted message, and Payload the payload delivering it, of the ContentType.
+	Text        string `json:"text"`
+	ContentType string `json:"content_type"`
+	Payload     string `json:"payload"`
+}
+
+// APIPreview renders the message of the webhook payload of the request body for the destination of the destination
+// query parameter, of the tenant of the tenant parameter, and responds with the payload that would be delivered,
+// without delivering it. The raw parameter responds with the payload itself.
+//
+// The destinations that don't implement Previewer deliver the text of the message.
+func (s *Server) APIPreview(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	routing := s.currentRouting().tenantByName(r.URL.Query().Get("tenant"))
+	if routing == nil {
+		writeJSONError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+	name := r.URL.Query().Get("destination")
+	service, ok := routing.services[name]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "destination not found")
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, routing.config.Inbound.maxBodySize()))
+	if err != nil {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	event, err := webhook.ParseEvent(body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid payload: "+err.Error())
+		return
+	}
+	event = routing.redaction.redact(event)
+
+	text, err := formatMessage(service, event)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	preview := &Preview{ContentType: "text/plain; charset=utf-8", Body: []byte(text)}
+	if previewer, ok := unwrapService(service).(Previewer); ok {
+		ctx := withAnnotations(r.Context(), routing.securityAnnotations(event, name, routing.annotations(event.Name, name)))
+		if preview, err = previewer.Preview(ctx, event, text); err != nil {
+			writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+	}
+
+	if raw, _ := strconv.ParseBool(r.URL.Query().Get("raw")); raw {
+		w.Header().Set("Content-Type", preview.ContentType)
+		w.Write(preview.Body)
+		return
+	}
+	writeJSON(w, http.StatusOK, &PreviewResponse{
+		Destination: name,
+		Event:       event.Name,
+		Text:        text,
+		ContentType: preview.ContentType,
+		Payload:     string(preview.Body),
+	})
+}
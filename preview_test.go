@@ -0,0 +1,93 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+const previewTestPayload = `{"name": "domain.create", "request_identifier": "3e8d1f2a-preview-0000-000000000001",
+	"actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"},
+	"data": {"domain": {"id": 1, "name": "example.com"}}}`
+
+func TestAPIPreview(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"admin": {"token": "secret"},
+		"destinations": [{"name": "ops", "type": "slack", "bot_token": "xoxb-1", "channel": "#ops"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	response := adminRequest(server, "POST", "/api/preview?destination=ops", previewTestPayload)
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("POST /api/preview expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+	var preview PreviewResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &preview); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "example@example.com created the domain <https://dnsimple.com/a/1010/domains/example.com|example.com>", preview.Text; !strings.Contains(got, want) {
+		t.Errorf("POST /api/preview expected text %q, got %q", want, got)
+	}
+	var payload slackPayload
+	if err := json.Unmarshal([]byte(preview.Payload), &payload); err != nil {
+		t.Fatalf("POST /api/preview expected a Slack payload, got %v", preview.Payload)
+	}
+	if want, got := "#ops", payload.Channel; want != got {
+		t.Errorf("POST /api/preview expected channel %v, got %v", want, got)
+	}
+	if want, got := "application/json", preview.ContentType; want != got {
+		t.Errorf("POST /api/preview expected content type %v, got %v", want, got)
+	}
+
+	raw := adminRequest(server, "POST", "/api/preview?destination=ops&raw=1", previewTestPayload)
+	if want, got := preview.Payload, raw.Body.String(); want != got {
+		t.Errorf("POST /api/preview?raw=1 expected the payload %v, got %v", want, got)
+	}
+
+	tests := []struct {
+		path    string
+		payload string
+		status  int
+	}{
+		{"/api/preview?destination=dev", previewTestPayload, http.StatusNotFound},
+		{"/api/preview?destination=ops&tenant=team-a", previewTestPayload, http.StatusNotFound},
+		{"/api/preview?destination=ops", "{", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if got := adminRequest(server, "POST", test.path, test.payload).Code; test.status != got {
+			t.Errorf("POST %v expected HTTP %v, got %v", test.path, test.status, got)
+		}
+	}
+}
+
+// textDestination is a destination without a preview.
+type textDestination struct{}
+
+func (textDestination) Name() string { return "text" }
+func (textDestination) Format(event *webhook.Event) (string, error) {
+	return "text of " + event.Name, nil
+}
+func (textDestination) Deliver(context.Context, *webhook.Event, string) error { return nil }
+func (textDestination) HealthCheck(context.Context) error                     { return nil }
+
+func TestAPIPreview_Text(t *testing.T) {
+	server, _ := newAdminTestServer()
+	server.currentRouting().services["text"] = newDestinationMessagingService(textDestination{})
+
+	request, _ := http.NewRequest("POST", "/api/preview?destination=text&raw=true", strings.NewReader(previewTestPayload))
+	request.Header.Set("Authorization", "Bearer secret")
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := "text of domain.create", response.Body.String(); want != got {
+		t.Errorf("POST /api/preview expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,195 @@
+package strillone
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// PubSubTopic publishes the delivery jobs to a Google Cloud Pub/Sub topic, whose push subscription
+// delivers them back to the /pubsub endpoint. Pub/Sub retries the failed deliveries, with the
+// retry policy and the dead-letter topic of the subscription.
+type PubSubTopic struct {
+	// Name is the topic, such as projects/my-project/topics/strillone, or strillone in the project of the credentials.
+	Name        string
+	Credentials *GCPCredentials
+
+	// Endpoint overrides the Pub/Sub endpoint, mostly useful for testing.
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// pubSubMessage represents a Pub/Sub message, published or pushed.
+// The data is base64-encoded in JSON.
+type pubSubMessage struct {
+	Data       []byte            `json:"data"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	MessageID  string            `json:"messageId,omitempty"`
+}
+
+// pubSubPush represents the request of a push subscription.
+type pubSubPush struct {
+	Message      pubSubMessage `json:"message"`
+	Subscription string        `json:"subscription"`
+
+	// DeliveryAttempt is set when the subscription has a dead-letter topic.
+	DeliveryAttempt int `json:"deliveryAttempt"`
+}
+
+// Publish publishes one message per job.
+func (t *PubSubTopic) Publish(ctx context.Context, jobs ...*Job) error {
+	topic := t.Name
+	if !strings.HasPrefix(topic, "projects/") {
+		project, err := t.Credentials.project(ctx)
+		if err != nil {
+			return err
+		}
+		topic = fmt.Sprintf("projects/%s/topics/%s", project, topic)
+	}
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = "https://pubsub.googleapis.com"
+	}
+
+	messages := make([]pubSubMessage, 0, len(jobs))
+	for _, job := range jobs {
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, pubSubMessage{
+			Data:       data,
+			Attributes: map[string]string{"tenant": job.Tenant, "destination": job.Destination},
+		})
+	}
+	payload, err := json.Marshal(map[string]interface{}{"messages": messages})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v1/%s:publish", endpoint, topic), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := t.Credentials.authorize(req); err != nil {
+		return err
+	}
+	_, err = doSecretsRequest(t.HTTPClient, req)
+	return err
+}
+
+// SetPubSub enables the asynchronous deliveries through Pub/Sub: the webhooks are acknowledged
+// as soon as the deliveries are published to the topic, and delivered when the push subscription
+// posts them to /pubsub?token=<token>.
+func (s *Server) SetPubSub(topic *PubSubTopic, token string) {
+	s.pubsub = topic
+	s.pubsubToken = token
+}
+
+// publishJobs publishes one delivery job per destination.
+func (s *Server) publishJobs(ctx context.Context, event *webhook.Event, routing *routingTable, names []string) error {
+	now := time.Now()
+	jobs := make([]*Job, 0, len(names))
+	for _, name := range names {
+		jobs = append(jobs, &Job{
+			Tenant:      routing.name,
+			Destination: name,
+			Payload:     event.GetPayload(),
+			CreatedAt:   now,
+			NextAttempt: now,
+		})
+	}
+
+	if err := s.pubsub.Publish(ctx, jobs...); err != nil {
+		s.forget(routing, event)
+		withEvent(log.Error(), event).Err(err).Str("tenant", routing.name).Msg("Error publishing the event")
+		return err
+	}
+
+	s.webhookCache.Set(routing.cacheKey(event), "1")
+	return nil
+}
+
+// PubSubPush handles the delivery jobs pushed by the Pub/Sub subscription.
+// It responds with an error when the delivery fails, so that Pub/Sub retries it,
+// and acknowledges the jobs that can't be delivered, such as the jobs of a removed destination.
+func (s *Server) PubSubPush(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if s.pubsub == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(s.pubsubToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var push pubSubPush
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		http.Error(w, "invalid push request", http.StatusBadRequest)
+		return
+	}
+	var job Job
+	if err := json.Unmarshal(push.Message.Data, &job); err != nil {
+		log.Warn().Err(err).Str("message_id", push.Message.MessageID).Msg("Dropping Pub/Sub message: invalid job")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	routing := s.currentRouting().tenantByName(job.Tenant)
+	if routing == nil {
+		log.Warn().Str("message_id", push.Message.MessageID).Str("tenant", job.Tenant).Msg("Dropping Pub/Sub message: unknown tenant")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if _, ok := routing.services[job.Destination]; !ok {
+		log.Warn().Str("message_id", push.Message.MessageID).Str("tenant", job.Tenant).Str("destination", job.Destination).Msg("Dropping Pub/Sub message: unknown destination")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	event, err := webhook.ParseEvent(job.Payload)
+	if err != nil {
+		log.Warn().Err(err).Str("message_id", push.Message.MessageID).Msg("Dropping Pub/Sub message: invalid payload")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	stats := s.stats.Tenant(job.Tenant)
+	if _, err := s.deliver(r.Context(), routing, job.Destination, event); err != nil {
+		stats.failed()
+		withEvent(log.Warn(), event).Err(err).Str("message_id", push.Message.MessageID).Str("tenant", job.Tenant).Str("destination", job.Destination).Int("attempts", push.DeliveryAttempt).Msg("Error delivering Pub/Sub message")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stats.delivered()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PubSubFromEnv returns the topic in STRILLONE_PUBSUB_TOPIC, and the token of the push subscription
+// in STRILLONE_PUBSUB_TOKEN, when running in Google Cloud. It returns nil without topic.
+func PubSubFromEnv() (*PubSubTopic, string, error) {
+	name := os.Getenv("STRILLONE_PUBSUB_TOPIC")
+	if name == "" {
+		return nil, "", nil
+	}
+	credentials := GCPCredentialsFromEnv()
+	if credentials == nil {
+		return nil, "", errors.New("STRILLONE_PUBSUB_TOPIC requires Cloud Run or Cloud Functions")
+	}
+	token := os.Getenv("STRILLONE_PUBSUB_TOKEN")
+	if len(token) < 16 {
+		return nil, "", errors.New("STRILLONE_PUBSUB_TOKEN must be at least 16 characters")
+	}
+	return &PubSubTopic{Name: name, Credentials: credentials}, token, nil
+}
@@ -0,0 +1,131 @@
+package strillone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPubSubToken = "pubsub-token-0123456789"
+
+// newTestPubSub returns a server publishing to a fake Pub/Sub topic, and the messages published.
+func newTestPubSub(t *testing.T) (*Server, *failingService, *[]pubSubMessage) {
+	credentials, _ := newTestGCPMetadata(t)
+	var published []pubSubMessage
+	pubsub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, got := "/v1/projects/my-project/topics/strillone:publish", r.URL.Path; want != got {
+			t.Errorf("publish expected path %v, got %v", want, got)
+		}
+		var request struct {
+			Messages []pubSubMessage `json:"messages"`
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("publish request: %v", err)
+		}
+		published = append(published, request.Messages...)
+		fmt.Fprint(w, `{"messageIds": ["1"]}`)
+	}))
+	t.Cleanup(pubsub.Close)
+
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops := &failingService{}
+	server.routing.services["ops"] = ops
+	server.SetPubSub(&PubSubTopic{Name: "strillone", Credentials: credentials, Endpoint: pubsub.URL}, testPubSubToken)
+	return server, ops, &published
+}
+
+func TestEvents_PubSub(t *testing.T) {
+	server, ops, published := newTestPubSub(t)
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "d7a3e5c1-pubsub-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusAccepted; want != response.Code {
+		t.Fatalf("POST /events expected HTTP %v, got %v: %s", want, response.Code, response.Body)
+	}
+	if want, got := headerProcessingQueued, response.Header().Get(headerProcessingStatus); want != got {
+		t.Errorf("POST /events expected %v %q, got %q", headerProcessingStatus, want, got)
+	}
+	if want, got := 1, len(*published); want != got {
+		t.Fatalf("POST /events expected %v messages published, got %v", want, got)
+	}
+	if want, got := 0, ops.sent; want != got {
+		t.Errorf("POST /events expected %v deliveries, got %v", want, got)
+	}
+	message := (*published)[0]
+	if want, got := "ops", message.Attributes["destination"]; want != got {
+		t.Errorf("published message expected destination %v, got %v", want, got)
+	}
+
+	// The push subscription delivers the message.
+	push, _ := json.Marshal(&pubSubPush{Message: message, Subscription: "projects/my-project/subscriptions/strillone"})
+	request, _ = http.NewRequest("POST", "/pubsub?token="+testPubSubToken, strings.NewReader(string(push)))
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want := http.StatusNoContent; want != response.Code {
+		t.Fatalf("POST /pubsub expected HTTP %v, got %v: %s", want, response.Code, response.Body)
+	}
+	if want, got := 1, ops.sent; want != got {
+		t.Errorf("POST /pubsub expected %v deliveries, got %v", want, got)
+	}
+
+	// A failed delivery is retried by Pub/Sub.
+	ops.errs = []error{errors.New("slack is down")}
+	request, _ = http.NewRequest("POST", "/pubsub?token="+testPubSubToken, strings.NewReader(string(push)))
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want := http.StatusInternalServerError; want != response.Code {
+		t.Errorf("POST /pubsub expected HTTP %v, got %v", want, response.Code)
+	}
+}
+
+func TestServer_PubSubPush(t *testing.T) {
+	server, ops, _ := newTestPubSub(t)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"invalid token", "/pubsub?token=wrong", `{}`, http.StatusUnauthorized},
+		{"invalid request", "/pubsub?token=" + testPubSubToken, `{`, http.StatusBadRequest},
+		{"invalid job", "/pubsub?token=" + testPubSubToken, `{"message": {"data": "bm90IGpzb24="}}`, http.StatusNoContent},
+		{"unknown destination", "/pubsub?token=" + testPubSubToken, `{"message": {"data": "eyJkZXN0aW5hdGlvbiI6ICJnb25lIn0="}}`, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		request, _ := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if tt.want != response.Code {
+			t.Errorf("POST /pubsub (%s) expected HTTP %v, got %v", tt.name, tt.want, response.Code)
+		}
+	}
+	if want, got := 0, ops.sent; want != got {
+		t.Errorf("POST /pubsub expected %v deliveries, got %v", want, got)
+	}
+
+	// Without Pub/Sub, the endpoint doesn't exist.
+	server.SetPubSub(nil, "")
+	request, _ := http.NewRequest("POST", "/pubsub", strings.NewReader(`{}`))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want := http.StatusNotFound; want != response.Code {
+		t.Errorf("POST /pubsub expected HTTP %v, got %v", want, response.Code)
+	}
+}
@@ -0,0 +1,185 @@
+package strillone
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrPurgeUnsupported is returned when the history can't remove its events.
+var ErrPurgeUnsupported = errors.New("the history doesn't support the removal of events")
+
+// PurgeRequest selects the stored events purged for a data removal request:
+// the events whose payload includes the email address of a contact, or about a domain.
+type PurgeRequest struct {
+	Email  string `json:"email,omitempty"`
+	Domain string `json:"domain,omitempty"`
+
+	// DryRun reports the events without removing them.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// PurgeReport is the deletion report of a purge.
+type PurgeReport struct {
+	Email    string    `json:"email,omitempty"`
+	Domain   string    `json:"domain,omitempty"`
+	DryRun   bool      `json:"dry_run,omitempty"`
+	PurgedAt time.Time `json:"purged_at"`
+
+	// Events are the events removed from the history, and DeadLetters the jobs removed from the dead letters of the queue.
+	Events      []PurgedEvent `json:"events"`
+	DeadLetters []PurgedJob   `json:"dead_letters"`
+}
+
+// PurgedEvent identifies an event removed from the history.
+type PurgedEvent struct {
+	Tenant     string    `json:"tenant,omitempty"`
+	Name       string    `json:"name"`
+	RequestID  string    `json:"request_identifier"`
+	Domain     string    `json:"domain,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// PurgedJob identifies a dead letter removed from the queue.
+type PurgedJob struct {
+	ID          uint64    `json:"id"`
+	Tenant      string    `json:"tenant,omitempty"`
+	Destination string    `json:"destination"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (r *PurgeRequest) validate() error {
+	if r.Email == "" && r.Domain == "" {
+		return errors.New("email or domain required")
+	}
+	if r.Email != "" && !strings.Contains(r.Email, "@") {
+		return fmt.Errorf("invalid email %q", r.Email)
+	}
+	return nil
+}
+
+// matches returns true if the payload includes the email, or if the domain of the event, or its parent, is the domain.
+func (r *PurgeRequest) matches(domain string, payload []byte) bool {
+	if r.Email != "" && bytes.Contains(bytes.ToLower(payload), []byte(strings.ToLower(r.Email))) {
+		return true
+	}
+	if r.Domain != "" && domain != "" {
+		domain, purged := strings.ToLower(domain), strings.ToLower(strings.TrimSuffix(r.Domain, "."))
+		return domain == purged || strings.HasSuffix(domain, "."+purged)
+	}
+	return false
+}
+
+// target describes the request in the audit log, with the email masked.
+func (r *PurgeRequest) target() string {
+	var targets []string
+	if r.Email != "" {
+		targets = append(targets, "email:"+emailPattern.ReplaceAllString(r.Email, "$1***@$2"))
+	}
+	if r.Domain != "" {
+		targets = append(targets, "domain:"+r.Domain)
+	}
+	return strings.Join(targets, " ")
+}
+
+// PurgeEvents removes the events of the history, and the dead letters of the queue if not nil, matching the request,
+// and returns the deletion report. The history must implement HistoryPruner.
+func PurgeEvents(history History, queue Queue, request *PurgeRequest, now time.Time) (*PurgeReport, error) {
+	if err := request.validate(); err != nil {
+		return nil, err
+	}
+	pruner, ok := history.(HistoryPruner)
+	if !ok {
+		return nil, ErrPurgeUnsupported
+	}
+	report := &PurgeReport{Email: request.Email, Domain: request.Domain, DryRun: request.DryRun, PurgedAt: now.UTC(),
+		Events: []PurgedEvent{}, DeadLetters: []PurgedJob{}}
+
+	events, err := history.Query(&HistoryFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var purged []*HistoryEvent
+	for _, event := range events {
+		if request.matches(event.Domain, event.Payload) {
+			purged = append(purged, event)
+			report.Events = append(report.Events, PurgedEvent{Tenant: event.Tenant, Name: event.Name, RequestID: event.RequestID, Domain: event.Domain, ReceivedAt: event.ReceivedAt})
+		}
+	}
+	if len(purged) > 0 && !request.DryRun {
+		if err := pruner.Remove(purged); err != nil {
+			return nil, err
+		}
+	}
+
+	if queue == nil {
+		return report, nil
+	}
+	jobs, err := queue.DeadLetters()
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		var domain string
+		if event, err := webhook.ParseEvent(job.Payload); err == nil {
+			domain = eventDomain(event)
+		}
+		if !request.matches(domain, job.Payload) {
+			continue
+		}
+		if !request.DryRun {
+			if err := queue.DeleteDeadLetter(job.ID); err != nil && err != ErrJobNotFound {
+				return nil, err
+			}
+		}
+		report.DeadLetters = append(report.DeadLetters, PurgedJob{ID: job.ID, Tenant: job.Tenant, Destination: job.Destination, CreatedAt: job.CreatedAt})
+	}
+	return report, nil
+}
+
+// APIPurgeEvents removes the stored events matching the email or the domain of the request body,
+// and responds with the deletion report. The purges are recorded in the audit log.
+func (s *Server) APIPurgeEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if s.history == nil {
+		writeJSONError(w, http.StatusNotFound, "history not enabled")
+		return
+	}
+	request := &PurgeRequest{}
+	if !readJSON(w, r, request) {
+		return
+	}
+	if err := request.validate(); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	report, err := PurgeEvents(s.history, s.queue, request, time.Now())
+	if err == ErrPurgeUnsupported {
+		writeJSONError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	if !request.DryRun {
+		entry := adminAudit(r, "events.purge", request.target())
+		entry.Time = report.PurgedAt
+		entry.Changes = []string{fmt.Sprintf("events: %d removed", len(report.Events)), fmt.Sprintf("dead letters: %d removed", len(report.DeadLetters))}
+		log.Info().Str("actor", entry.Actor).Str("target", entry.Target).Strs("changes", entry.Changes).Msg("Events purged")
+		if s.auditLog != nil {
+			if err := s.auditLog.Record(entry); err != nil {
+				log.Error().Err(err).Str("action", entry.Action).Msg("Error recording the purge")
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,128 @@
+package strillone
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func recordPurgeTestEvents(t *testing.T, history History, queue Queue) {
+	now := time.Now()
+	events := []*HistoryEvent{
+		{Name: "contact.create", RequestID: "1", Domain: "", ReceivedAt: now, Payload: []byte(`{"name":"contact.create","data":{"contact":{"email":"Jane.Doe@example.com"}}}`)},
+		{Name: "domain.create", RequestID: "2", Domain: "example.com", ReceivedAt: now.Add(time.Minute), Payload: []byte(`{"name":"domain.create","data":{"domain":{"name":"example.com"}}}`)},
+		{Name: "zone_record.create", RequestID: "3", Domain: "www.example.com", ReceivedAt: now.Add(2 * time.Minute), Payload: []byte(`{"name":"zone_record.create","data":{"zone_record":{"zone_id":"www.example.com"}}}`)},
+		{Name: "domain.create", RequestID: "4", Domain: "notexample.com", ReceivedAt: now.Add(3 * time.Minute), Payload: []byte(`{"name":"domain.create","data":{"domain":{"name":"notexample.com"}}}`)},
+	}
+	for _, event := range events {
+		if err := history.Record(event); err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+	}
+
+	jobs := []*Job{
+		{Destination: "ops", Payload: []byte(`{"name":"domain.create","request_identifier":"2","data":{"domain":{"name":"example.com"}}}`), NextAttempt: now},
+		{Destination: "ops", Payload: []byte(`{"name":"domain.create","request_identifier":"4","data":{"domain":{"name":"notexample.com"}}}`), NextAttempt: now},
+	}
+	if err := queue.Enqueue(jobs...); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	for _, job := range jobs {
+		if err := queue.Bury(job); err != nil {
+			t.Fatalf("Bury returned error: %v", err)
+		}
+	}
+}
+
+func TestPurgeEvents(t *testing.T) {
+	history, cleanupHistory := openTestHistory(t)
+	defer cleanupHistory()
+	queue, cleanupQueue := openTestQueue(t)
+	defer cleanupQueue()
+	recordPurgeTestEvents(t, history, queue)
+
+	if _, err := PurgeEvents(history, queue, &PurgeRequest{}, time.Now()); err == nil {
+		t.Errorf("PurgeEvents without email or domain expected error")
+	}
+
+	report, err := PurgeEvents(history, queue, &PurgeRequest{Domain: "example.com", DryRun: true}, time.Now())
+	if err != nil {
+		t.Fatalf("PurgeEvents returned error: %v", err)
+	}
+	if len(report.Events) != 2 || len(report.DeadLetters) != 1 {
+		t.Errorf("PurgeEvents dry run expected 2 events and 1 dead letter, got %v and %v", len(report.Events), len(report.DeadLetters))
+	}
+	if events, _ := history.Query(&HistoryFilter{}); len(events) != 4 {
+		t.Errorf("PurgeEvents dry run expected the events kept, got %v events", len(events))
+	}
+
+	report, err = PurgeEvents(history, queue, &PurgeRequest{Email: "jane.doe@example.com", Domain: "example.com"}, time.Now())
+	if err != nil {
+		t.Fatalf("PurgeEvents returned error: %v", err)
+	}
+	if len(report.Events) != 3 || len(report.DeadLetters) != 1 {
+		t.Errorf("PurgeEvents expected 3 events and 1 dead letter, got %v and %v", len(report.Events), len(report.DeadLetters))
+	}
+	events, _ := history.Query(&HistoryFilter{})
+	if len(events) != 1 || events[0].RequestID != "4" {
+		t.Errorf("PurgeEvents expected the event of notexample.com kept, got %v events", len(events))
+	}
+	if jobs, _ := queue.DeadLetters(); len(jobs) != 1 {
+		t.Errorf("PurgeEvents expected 1 dead letter kept, got %v", len(jobs))
+	}
+}
+
+func TestAPIPurgeEvents(t *testing.T) {
+	server, _ := newAdminTestServer()
+	if want, got := http.StatusNotFound, adminRequest(server, "POST", "/api/events/purge", `{"domain": "example.com"}`).Code; want != got {
+		t.Errorf("POST /api/events/purge without history expected HTTP %v, got %v", want, got)
+	}
+
+	history, cleanupHistory := openTestHistory(t)
+	defer cleanupHistory()
+	queue, cleanupQueue := openTestQueue(t)
+	defer cleanupQueue()
+	recordPurgeTestEvents(t, history, queue)
+	server.SetHistory(history)
+	server.SetQueue(queue, 0)
+
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	audit, err := OpenFileAuditLog(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatalf("OpenFileAuditLog returned error: %v", err)
+	}
+	defer audit.Close()
+	server.SetAuditLog(audit)
+
+	if want, got := http.StatusUnprocessableEntity, adminRequest(server, "POST", "/api/events/purge", `{"email": "jane"}`).Code; want != got {
+		t.Errorf("POST /api/events/purge with an invalid email expected HTTP %v, got %v", want, got)
+	}
+
+	response := adminRequest(server, "POST", "/api/events/purge", `{"email": "jane.doe@example.com"}`)
+	if want := http.StatusOK; want != response.Code {
+		t.Fatalf("POST /api/events/purge expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+	var report PurgeReport
+	if err := json.Unmarshal(response.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Events) != 1 || report.Events[0].RequestID != "1" {
+		t.Errorf("POST /api/events/purge expected the event of jane.doe@example.com, got %+v", report.Events)
+	}
+
+	entries, err := audit.Query(&AuditFilter{Action: "events.purge", Limit: 10})
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Target != "email:j***@example.com" {
+		t.Errorf("POST /api/events/purge expected an audit entry with the email masked, got %+v", entries)
+	}
+}
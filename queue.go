@@ -0,0 +1,573 @@
+package strillone
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultQueueBatchSize   = 100
+	defaultQueueMaxAttempts = 10
+	queueInitialBackoff     = 10 * time.Second
+	queueMaxBackoff         = 10 * time.Minute
+	headerProcessingQueued  = "queued"
+
+	// queueIdempotencyWindow is how long the idempotency keys of the enqueued jobs are kept,
+	// covering the redeliveries of DNSimple.
+	queueIdempotencyWindow = 72 * time.Hour
+
+	// queueExpireInterval is the interval between the expirations of the idempotency keys.
+	queueExpireInterval = time.Hour
+)
+
+// Job represents the delivery of an event to a destination.
+type Job struct {
+	ID uint64 `json:"id"`
+
+	// Tenant is the tenant name, empty for the top-level configuration.
+	Tenant      string `json:"tenant,omitempty"`
+	Destination string `json:"destination"`
+
+	// Key is the idempotency key of the job: the tenant, the request identifier of the webhook and the destination.
+	Key string `json:"key,omitempty"`
+
+	// Payload is the webhook payload, as received.
+	Payload json.RawMessage `json:"payload"`
+
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	NextAttempt time.Time `json:"next_attempt"`
+
+	// FailedAt is the time the job was moved to the dead letters.
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+}
+
+// ErrJobNotFound is returned when a job doesn't exist.
+var ErrJobNotFound = errors.New("job not found")
+
+// Queue stores the deliveries between the webhook ingestion and the delivery to the destinations.
+//
+// A job is deleted only once delivered, so that the deliveries are performed at least once,
+// even across restarts.
+type Queue interface {
+	// Enqueue stores the jobs, and assigns their ID. The jobs with the key of a job already enqueued
+	// are skipped, and keep a zero ID, so that the redelivered webhooks are delivered once.
+	Enqueue(jobs ...*Job) error
+
+	// ExpireKeys forgets the keys of the jobs enqueued before the time.
+	ExpireKeys(before time.Time) error
+
+	// Due returns up to limit jobs whose next attempt is before now, oldest first.
+	Due(now time.Time, limit int) ([]*Job, error)
+
+	// Update stores the changes to a job.
+	Update(job *Job) error
+
+	// Delete removes a job.
+	Delete(id uint64) error
+
+	// Bury moves a job that exhausted its attempts to the dead letters.
+	Bury(job *Job) error
+
+	// DeadLetters returns the dead letters, oldest first.
+	DeadLetters() ([]*Job, error)
+
+	// Redeliver moves a dead letter back to the queue, due at now.
+	// It returns ErrJobNotFound if the dead letter doesn't exist.
+	Redeliver(id uint64, now time.Time) error
+
+	// DeleteDeadLetter removes a dead letter.
+	// It returns ErrJobNotFound if the dead letter doesn't exist.
+	DeleteDeadLetter(id uint64) error
+
+	// Len returns the number of pending jobs and dead letters.
+	Len() (jobs int, deadLetters int, err error)
+
+	Close() error
+}
+
+var (
+	boltJobsBucket = []byte("jobs")
+	boltDeadBucket = []byte("dead")
+	boltKeysBucket = []byte("keys")
+
+	// boltDueBucket indexes the pending jobs by next attempt, then ID, so that the due jobs are found
+	// without reading the others.
+	boltDueBucket = []byte("due")
+)
+
+// BoltQueue is a Queue stored in a BoltDB file.
+type BoltQueue struct {
+	db *bolt.DB
+}
+
+// OpenBoltQueue opens, or creates, the queue stored in the file.
+func OpenBoltQueue(filename string) (*BoltQueue, error) {
+	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltJobsBucket, boltDeadBucket, boltKeysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(boltDueBucket) != nil {
+			return nil
+		}
+		// index the jobs of the queues created before the index
+		due, err := tx.CreateBucket(boltDueBucket)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boltJobsBucket).ForEach(func(k, v []byte) error {
+			job := &Job{}
+			if err := json.Unmarshal(v, job); err != nil {
+				return err
+			}
+			return due.Put(dueKey(job), nil)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltQueue{db: db}, nil
+}
+
+// Enqueue implements Queue
+func (q *BoltQueue) Enqueue(jobs ...*Job) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		bucket, keys := tx.Bucket(boltJobsBucket), tx.Bucket(boltKeysBucket)
+		for _, job := range jobs {
+			if job.Key != "" {
+				if keys.Get([]byte(job.Key)) != nil {
+					continue
+				}
+				if err := keys.Put([]byte(job.Key), jobKey(uint64(job.CreatedAt.UnixNano()))); err != nil {
+					return err
+				}
+			}
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			job.ID = id
+			if err := putPendingJob(tx, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExpireKeys implements Queue
+func (q *BoltQueue) ExpireKeys(before time.Time) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(boltKeysBucket)
+		var expired [][]byte
+		err := keys.ForEach(func(k, v []byte) error {
+			if len(v) != 8 || int64(binary.BigEndian.Uint64(v)) < before.UnixNano() {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := keys.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Due implements Queue
+//
+// The jobs are returned by next attempt, the scan stopping at the first job that isn't due.
+func (q *BoltQueue) Due(now time.Time, limit int) ([]*Job, error) {
+	var jobs []*Job
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket, until := tx.Bucket(boltJobsBucket), dueTime(dueKey(&Job{NextAttempt: now}))
+		cursor := tx.Bucket(boltDueBucket).Cursor()
+		for k, _ := cursor.First(); k != nil && len(jobs) < limit; k, _ = cursor.Next() {
+			if dueTime(k) > until {
+				break
+			}
+			data := bucket.Get(k[8:])
+			if data == nil {
+				continue
+			}
+			job := &Job{}
+			if err := json.Unmarshal(data, job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// Update implements Queue
+func (q *BoltQueue) Update(job *Job) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return putPendingJob(tx, job)
+	})
+}
+
+// Delete implements Queue
+func (q *BoltQueue) Delete(id uint64) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return deletePendingJob(tx, id)
+	})
+}
+
+// Bury implements Queue
+func (q *BoltQueue) Bury(job *Job) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		if err := deletePendingJob(tx, job.ID); err != nil {
+			return err
+		}
+		return putJob(tx.Bucket(boltDeadBucket), job)
+	})
+}
+
+// DeadLetters implements Queue
+func (q *BoltQueue) DeadLetters() ([]*Job, error) {
+	jobs := []*Job{}
+	err := q.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDeadBucket).ForEach(func(k, v []byte) error {
+			job := &Job{}
+			if err := json.Unmarshal(v, job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// Redeliver implements Queue
+func (q *BoltQueue) Redeliver(id uint64, now time.Time) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		dead := tx.Bucket(boltDeadBucket)
+		data := dead.Get(jobKey(id))
+		if data == nil {
+			return ErrJobNotFound
+		}
+		job := &Job{}
+		if err := json.Unmarshal(data, job); err != nil {
+			return err
+		}
+		job.Attempts = 0
+		job.NextAttempt = now
+		job.FailedAt = nil
+		if err := dead.Delete(jobKey(id)); err != nil {
+			return err
+		}
+		return putPendingJob(tx, job)
+	})
+}
+
+// DeleteDeadLetter implements Queue
+func (q *BoltQueue) DeleteDeadLetter(id uint64) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		dead := tx.Bucket(boltDeadBucket)
+		if dead.Get(jobKey(id)) == nil {
+			return ErrJobNotFound
+		}
+		return dead.Delete(jobKey(id))
+	})
+}
+
+// Len implements Queue
+func (q *BoltQueue) Len() (jobs int, deadLetters int, err error) {
+	err = q.db.View(func(tx *bolt.Tx) error {
+		jobs = tx.Bucket(boltJobsBucket).Stats().KeyN
+		deadLetters = tx.Bucket(boltDeadBucket).Stats().KeyN
+		return nil
+	})
+	return jobs, deadLetters, err
+}
+
+// Close implements Queue
+func (q *BoltQueue) Close() error {
+	return q.db.Close()
+}
+
+func putJob(bucket *bolt.Bucket, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(jobKey(job.ID), data)
+}
+
+// putPendingJob stores the pending job, indexed by its next attempt in place of its previous one.
+func putPendingJob(tx *bolt.Tx, job *Job) error {
+	if err := deletePendingJob(tx, job.ID); err != nil {
+		return err
+	}
+	if err := putJob(tx.Bucket(boltJobsBucket), job); err != nil {
+		return err
+	}
+	return tx.Bucket(boltDueBucket).Put(dueKey(job), nil)
+}
+
+// deletePendingJob removes the pending job and its index, if it exists.
+func deletePendingJob(tx *bolt.Tx, id uint64) error {
+	bucket := tx.Bucket(boltJobsBucket)
+	data := bucket.Get(jobKey(id))
+	if data == nil {
+		return nil
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltDueBucket).Delete(dueKey(job)); err != nil {
+		return err
+	}
+	return bucket.Delete(jobKey(id))
+}
+
+// dueKey encodes the next attempt of the job followed by its ID, so that the index is sorted by next attempt.
+// The next attempts before 1970 are encoded as 1970.
+func dueKey(job *Job) []byte {
+	var nanos int64
+	if job.NextAttempt.After(time.Unix(0, 0)) {
+		nanos = job.NextAttempt.UnixNano()
+	}
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(nanos))
+	binary.BigEndian.PutUint64(key[8:], job.ID)
+	return key
+}
+
+// dueTime returns the next attempt encoded in the due key, in nanoseconds.
+func dueTime(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key)
+}
+
+// jobKey encodes the ID in big endian, so that the jobs are sorted by ID.
+func jobKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// SetQueue enables the delivery queue: the webhooks are acknowledged as soon as
+// the deliveries are stored in the queue, and delivered by ProcessQueue.
+//
+// A job failing maxAttempts times is moved to the dead letters. Zero means the default of 10 attempts.
+func (s *Server) SetQueue(queue Queue, maxAttempts int) {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultQueueMaxAttempts
+	}
+	s.queue = queue
+	s.queueMaxAttempts = maxAttempts
+}
+
+// enqueue stores one delivery job per destination in the queue, keyed on the request identifier of the event.
+// It returns the number of jobs enqueued, without the jobs of the destinations that already received the event.
+func (s *Server) enqueue(event *webhook.Event, routing *routingTable, names []string) (int, error) {
+	now := time.Now()
+	jobs := make([]*Job, 0, len(names))
+	for _, name := range names {
+		job := &Job{
+			Tenant:      routing.name,
+			Destination: name,
+			Payload:     event.GetPayload(),
+			CreatedAt:   now,
+			NextAttempt: now,
+		}
+		if event.RequestID != "" {
+			job.Key = routing.cacheKey(event) + "/" + name
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := s.queue.Enqueue(jobs...); err != nil {
+		s.forget(routing, event)
+		withEvent(log.Error(), event).Err(err).Str("tenant", routing.name).Msg("Error enqueuing the event")
+		return 0, err
+	}
+
+	s.webhookCache.Set(routing.cacheKey(event), "1")
+	enqueued := 0
+	for _, job := range jobs {
+		if job.ID != 0 {
+			enqueued++
+		}
+	}
+	return enqueued, nil
+}
+
+// ProcessQueue delivers the queued jobs, polling the queue at every interval,
+// until done is closed or the server is shut down.
+func (s *Server) ProcessQueue(interval time.Duration, done <-chan struct{}) {
+	s.workers.Add(1)
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var expired time.Time
+	for {
+		now := time.Now()
+		s.processDueJobs(now)
+		if now.Sub(expired) >= queueExpireInterval {
+			if err := s.queue.ExpireKeys(now.Add(-queueIdempotencyWindow)); err != nil {
+				log.Error().Err(err).Msg("Error expiring the idempotency keys of the queue")
+			}
+			expired = now
+		}
+
+		select {
+		case <-done:
+			return
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processDueJobs delivers the jobs due at now.
+func (s *Server) processDueJobs(now time.Time) {
+	for {
+		jobs, err := s.queue.Due(now, defaultQueueBatchSize)
+		if err != nil {
+			log.Error().Err(err).Msg("Error reading the queue")
+			return
+		}
+		if s.pool == nil {
+			for _, job := range jobs {
+				s.deliverJob(job, now)
+			}
+		} else {
+			var wg sync.WaitGroup
+			for _, job := range jobs {
+				job := job
+				wg.Add(1)
+				if !s.pool.run(func() { defer wg.Done(); s.deliverJob(job, now) }) {
+					wg.Done()
+				}
+			}
+			wg.Wait()
+		}
+		if len(jobs) < defaultQueueBatchSize {
+			return
+		}
+	}
+}
+
+// deliverJob delivers the job with the current configuration.
+// The job is deleted once delivered, or rescheduled with exponential backoff,
+// or moved to the dead letters when it exhausted its attempts.
+func (s *Server) deliverJob(job *Job, now time.Time) {
+	routing := s.currentRouting().tenantByName(job.Tenant)
+	if routing == nil {
+		log.Warn().Uint64("job_id", job.ID).Str("tenant", job.Tenant).Msg("Dropping job: unknown tenant")
+		s.queue.Delete(job.ID)
+		return
+	}
+	if _, ok := routing.services[job.Destination]; !ok {
+		log.Warn().Uint64("job_id", job.ID).Str("tenant", job.Tenant).Str("destination", job.Destination).Msg("Dropping job: unknown destination")
+		s.queue.Delete(job.ID)
+		return
+	}
+	event, err := webhook.ParseEvent(job.Payload)
+	if err != nil {
+		log.Warn().Err(err).Uint64("job_id", job.ID).Msg("Dropping job: invalid payload")
+		s.queue.Delete(job.ID)
+		return
+	}
+
+	stats := s.stats.Tenant(job.Tenant)
+	_, err = s.deliver(context.Background(), routing, job.Destination, event)
+	if open, ok := err.(*circuitOpenError); ok {
+		job.NextAttempt = open.until
+		if err := s.queue.Update(job); err != nil {
+			log.Error().Err(err).Uint64("job_id", job.ID).Msg("Error updating job")
+		}
+		return
+	}
+
+	job.Attempts++
+	if err != nil {
+		stats.failed()
+		job.LastError = err.Error()
+		if job.Attempts >= s.queueMaxAttempts {
+			withEvent(log.Error(), event).Err(err).Uint64("job_id", job.ID).Str("tenant", job.Tenant).Str("destination", job.Destination).Int("attempts", job.Attempts).Msg("Moving job to the dead letters")
+			job.FailedAt = &now
+			if err := s.queue.Bury(job); err != nil {
+				log.Error().Err(err).Uint64("job_id", job.ID).Msg("Error burying job")
+			}
+			return
+		}
+		job.NextAttempt = now.Add(queueBackoff(job.Attempts))
+		withEvent(log.Warn(), event).Err(err).Uint64("job_id", job.ID).Str("tenant", job.Tenant).Str("destination", job.Destination).Int("attempts", job.Attempts).Time("next_attempt", job.NextAttempt).Msg("Rescheduling job")
+		if err := s.queue.Update(job); err != nil {
+			log.Error().Err(err).Uint64("job_id", job.ID).Msg("Error updating job")
+		}
+		return
+	}
+
+	stats.delivered()
+	if err := s.queue.Delete(job.ID); err != nil {
+		log.Error().Err(err).Uint64("job_id", job.ID).Msg("Error deleting job")
+	}
+}
+
+// requeue stores again in the queue the events held for the destination, in a batch, an aggregate or a quiet window,
+// once their summary failed to be sent. Their jobs were removed from the queue when they were held, so they are
+// enqueued again as failed once, and attempted with the backoff of the queue. Without a queue, the events are dropped.
+//
+// The held events are only in memory: they are sent on shutdown, but lost if the process stops abruptly.
+func (s *Server) requeue(tenant, destination string, events []*webhook.Event, err error) {
+	if s.queue == nil {
+		return
+	}
+	now := time.Now()
+	jobs := make([]*Job, len(events))
+	for i, event := range events {
+		jobs[i] = &Job{
+			Tenant:      tenant,
+			Destination: destination,
+			Payload:     event.GetPayload(),
+			Attempts:    1,
+			LastError:   err.Error(),
+			CreatedAt:   now,
+			NextAttempt: now.Add(queueBackoff(1)),
+		}
+	}
+	if err := s.queue.Enqueue(jobs...); err != nil {
+		log.Error().Err(err).Str("tenant", tenant).Str("destination", destination).Int("events", len(events)).Msg("Error enqueuing the held events again")
+		return
+	}
+	log.Info().Str("tenant", tenant).Str("destination", destination).Int("events", len(events)).Msg("Held events enqueued again")
+}
+
+// queueBackoff returns the wait before the next attempt of a job, after the attempts.
+func queueBackoff(attempts int) time.Duration {
+	backoff := queueInitialBackoff << uint(attempts-1)
+	if backoff > queueMaxBackoff || backoff <= 0 {
+		return queueMaxBackoff
+	}
+	return backoff
+}
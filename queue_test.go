@@ -0,0 +1,292 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestQueue(t *testing.T) (*BoltQueue, func()) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue, err := OpenBoltQueue(filepath.Join(dir, "queue.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("OpenBoltQueue returned error: %v", err)
+	}
+	return queue, func() {
+		queue.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltQueue(t *testing.T) {
+	queue, cleanup := openTestQueue(t)
+	defer cleanup()
+
+	now := time.Now()
+	jobs := []*Job{
+		{Destination: "a", NextAttempt: now},
+		{Destination: "b", NextAttempt: now.Add(time.Hour)},
+		{Destination: "c", NextAttempt: now},
+	}
+	if err := queue.Enqueue(jobs...); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if jobs[0].ID == 0 || jobs[0].ID >= jobs[2].ID {
+		t.Errorf("Enqueue expected increasing IDs, got %v and %v", jobs[0].ID, jobs[2].ID)
+	}
+
+	due, err := queue.Due(now, 10)
+	if err != nil {
+		t.Fatalf("Due returned error: %v", err)
+	}
+	if want, got := 2, len(due); want != got {
+		t.Fatalf("Due expected %v jobs, got %v", want, got)
+	}
+	if want, got := "a", due[0].Destination; want != got {
+		t.Errorf("Due expected first job %v, got %v", want, got)
+	}
+
+	if err := queue.Delete(due[0].ID); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	due[1].NextAttempt = now.Add(time.Hour)
+	if err := queue.Update(due[1]); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	due, _ = queue.Due(now, 10)
+	if want, got := 0, len(due); want != got {
+		t.Errorf("Due expected %v jobs, got %v", want, got)
+	}
+	due, _ = queue.Due(now.Add(2*time.Hour), 10)
+	if want, got := 2, len(due); want != got {
+		t.Errorf("Due later expected %v jobs, got %v", want, got)
+	}
+}
+
+func TestBoltQueue_DueIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "queue.db")
+	queue, err := OpenBoltQueue(filename)
+	if err != nil {
+		t.Fatalf("OpenBoltQueue returned error: %v", err)
+	}
+
+	now := time.Now()
+	jobs := []*Job{
+		{Destination: "a", NextAttempt: now.Add(time.Minute)},
+		{Destination: "b", NextAttempt: now.Add(-time.Minute)},
+		{Destination: "c"},
+		{Destination: "d", NextAttempt: now.Add(time.Hour)},
+	}
+	if err := queue.Enqueue(jobs...); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if err := queue.Bury(jobs[2]); err != nil {
+		t.Fatalf("Bury returned error: %v", err)
+	}
+
+	// the queues created before the index are indexed when opened
+	queue.db.Update(func(tx *bolt.Tx) error { return tx.DeleteBucket(boltDueBucket) })
+	queue.Close()
+	if queue, err = OpenBoltQueue(filename); err != nil {
+		t.Fatalf("OpenBoltQueue returned error: %v", err)
+	}
+	defer queue.Close()
+
+	destinations := func(now time.Time) string {
+		due, err := queue.Due(now, 10)
+		if err != nil {
+			t.Fatalf("Due returned error: %v", err)
+		}
+		var names []string
+		for _, job := range due {
+			names = append(names, job.Destination)
+		}
+		return strings.Join(names, ",")
+	}
+	if want, got := "b,a", destinations(now.Add(30*time.Minute)); want != got {
+		t.Errorf("Due expected the jobs %v, got %v", want, got)
+	}
+	if err := queue.Redeliver(jobs[2].ID, now); err != nil {
+		t.Fatalf("Redeliver returned error: %v", err)
+	}
+	jobs[1].NextAttempt = now.Add(2 * time.Hour)
+	if err := queue.Update(jobs[1]); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if want, got := "c,a", destinations(now.Add(30*time.Minute)); want != got {
+		t.Errorf("Due expected the jobs %v, got %v", want, got)
+	}
+	if err := queue.Delete(jobs[0].ID); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if want, got := "c,d,b", destinations(now.Add(3*time.Hour)); want != got {
+		t.Errorf("Due expected the jobs %v, got %v", want, got)
+	}
+}
+
+type failingService struct {
+	SlackService
+	errs     []error
+	sent     int
+	messages []string
+
+	// messageErr fails the messages, such as the summaries of the batches.
+	messageErr error
+}
+
+func (s *failingService) PostMessage(_ context.Context, text string) error {
+	if s.messageErr != nil {
+		return s.messageErr
+	}
+	s.messages = append(s.messages, text)
+	return nil
+}
+
+func (s *failingService) PostEvent(_ context.Context, event *webhook.Event) (string, error) {
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return "", err
+	}
+	s.sent++
+	return "", nil
+}
+
+func TestEvents_Queue(t *testing.T) {
+	queue, cleanup := openTestQueue(t)
+	defer cleanup()
+
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetQueue(queue, 0)
+	service := &failingService{errs: []error{errors.New("downstream outage")}}
+	server.routing.services["ops"] = service
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "8a4f2d6b-queue-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusAccepted; want != response.Code {
+		t.Errorf("POST /events expected HTTP %v, got %v", want, response.Code)
+	}
+	if want, got := "queued", response.Header().Get(headerProcessingStatus); want != got {
+		t.Errorf("POST /events expected status %v, got %v", want, got)
+	}
+
+	now := time.Now()
+	server.processDueJobs(now)
+	if want, got := 0, service.sent; want != got {
+		t.Errorf("first attempt expected %v sent, got %v", want, got)
+	}
+	jobs, _ := queue.Due(now.Add(time.Hour), 10)
+	if len(jobs) != 1 || jobs[0].Attempts != 1 || jobs[0].LastError != "downstream outage" {
+		t.Fatalf("failed job expected to be rescheduled, got %+v", jobs)
+	}
+
+	server.processDueJobs(now.Add(time.Hour))
+	if want, got := 1, service.sent; want != got {
+		t.Errorf("second attempt expected %v sent, got %v", want, got)
+	}
+	jobs, _ = queue.Due(now.Add(time.Hour), 10)
+	if want, got := 0, len(jobs); want != got {
+		t.Errorf("delivered job expected to be deleted, got %v jobs", got)
+	}
+}
+
+func TestEvents_QueueIdempotency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "queue.db")
+
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "8a4f2d6b-queue-0000-000000000002"}`
+
+	// The same webhook is received before and after a restart, once delivered.
+	for i, want := range []string{"queued", "skipped;already-processed"} {
+		queue, err := OpenBoltQueue(filename)
+		if err != nil {
+			t.Fatalf("OpenBoltQueue returned error: %v", err)
+		}
+		server := NewServer(config)
+		server.SetQueue(queue, 0)
+		service := &failingService{}
+		server.routing.services["ops"] = service
+
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if got := response.Header().Get(headerProcessingStatus); want != got {
+			t.Errorf("POST /events #%d expected status %v, got %v", i, want, got)
+		}
+		server.processDueJobs(time.Now())
+		if want, got := 1-i, service.sent; want != got {
+			t.Errorf("POST /events #%d expected %v sent, got %v", i, want, got)
+		}
+		queue.Close()
+	}
+}
+
+func TestBoltQueue_ExpireKeys(t *testing.T) {
+	queue, cleanup := openTestQueue(t)
+	defer cleanup()
+
+	now := time.Now()
+	old := &Job{Destination: "ops", Key: "a/ops", CreatedAt: now.Add(-100 * time.Hour), NextAttempt: now}
+	recent := &Job{Destination: "ops", Key: "b/ops", CreatedAt: now, NextAttempt: now}
+	if err := queue.Enqueue(old, recent); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if err := queue.ExpireKeys(now.Add(-queueIdempotencyWindow)); err != nil {
+		t.Fatalf("ExpireKeys returned error: %v", err)
+	}
+
+	again := []*Job{
+		{Destination: "ops", Key: "a/ops", CreatedAt: now, NextAttempt: now},
+		{Destination: "ops", Key: "b/ops", CreatedAt: now, NextAttempt: now},
+	}
+	if err := queue.Enqueue(again...); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if again[0].ID == 0 {
+		t.Errorf("Enqueue expected the job with an expired key to be enqueued")
+	}
+	if again[1].ID != 0 {
+		t.Errorf("Enqueue expected the job with a known key to be skipped")
+	}
+}
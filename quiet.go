@@ -0,0 +1,137 @@
+package strillone
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// QuietWindowConfig represents a period during which the events to a destination are held or dropped,
+// such as quiet hours or a maintenance window.
+//
+// The window is either recurring, starting at every time matching Schedule and lasting Duration,
+// or a single period between From and Until.
+type QuietWindowConfig struct {
+	// Schedule is a cron expression (minute, hour, day of month, month, day of week)
+	// for the start of the window, e.g. "0 22 * * *" for every day at 22:00.
+	Schedule string   `json:"schedule,omitempty"`
+	Duration Duration `json:"duration,omitempty"`
+
+	// Timezone is the IANA timezone of the schedule, e.g. "Europe/Rome". Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+
+	From  *time.Time `json:"from,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+
+	// Action is "hold" (the default) to deliver the events in a single summary at the end
+	// of the window, or "drop" to discard them.
+	Action string `json:"action,omitempty"`
+}
+
+const (
+	quietActionHold = "hold"
+	quietActionDrop = "drop"
+)
+
+func (c *QuietWindowConfig) validate() error {
+	switch c.Action {
+	case "", quietActionHold, quietActionDrop:
+	default:
+		return fmt.Errorf("quiet window: unsupported action %q", c.Action)
+	}
+
+	if c.Schedule != "" {
+		if c.Duration <= 0 {
+			return errors.New("quiet window: a schedule requires a duration")
+		}
+		if _, err := parseCron(c.Schedule); err != nil {
+			return fmt.Errorf("quiet window: %v", err)
+		}
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("quiet window: %v", err)
+		}
+		return nil
+	}
+
+	if c.Until == nil {
+		return errors.New("quiet window: either schedule or until is required")
+	}
+	if c.From != nil && !c.From.Before(*c.Until) {
+		return errors.New("quiet window: from must be before until")
+	}
+	return nil
+}
+
+// activeUntil returns the end of the window, if the window is active at now.
+func (c *QuietWindowConfig) activeUntil(now time.Time) (time.Time, bool) {
+	if c.Schedule == "" {
+		if c.Until == nil || !now.Before(*c.Until) || (c.From != nil && now.Before(*c.From)) {
+			return time.Time{}, false
+		}
+		return *c.Until, true
+	}
+
+	schedule, err := parseCron(c.Schedule)
+	if err != nil {
+		return time.Time{}, false
+	}
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// look for the most recent start of the window within the duration
+	duration := time.Duration(c.Duration)
+	current := now.In(location).Truncate(time.Minute)
+	for start := current; now.Sub(start) < duration; start = start.Add(-time.Minute) {
+		if schedule.Matches(start) {
+			return start.Add(duration), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// quietWindow returns the action of the quiet window active at now for the destination,
+// and its end. When several windows are active, a drop prevails, and the latest end is used.
+func quietWindow(windows []QuietWindowConfig, now time.Time) (string, time.Time, bool) {
+	var action string
+	var until time.Time
+	for i := range windows {
+		end, ok := windows[i].activeUntil(now)
+		if !ok {
+			continue
+		}
+		if action != quietActionDrop {
+			action = windows[i].Action
+			if action == "" {
+				action = quietActionHold
+			}
+		}
+		if end.After(until) {
+			until = end
+		}
+	}
+	return action, until, action != ""
+}
+
+// quiet holds or drops the event if the destination is in a quiet window.
+// It returns false if the event must not be delivered now.
+func (s *Server) quiet(routing *routingTable, name string, event *webhook.Event, windows []QuietWindowConfig) bool {
+	action, until, ok := quietWindow(windows, time.Now())
+	if !ok {
+		return true
+	}
+
+	if action == quietActionDrop {
+		withEvent(log.Info(), event).Str("tenant", routing.name).Str("destination", name).Str("outcome", "dropped").Time("until", until).Msg("Dropping the event: destination in a quiet window")
+		return false
+	}
+
+	withEvent(log.Info(), event).Str("tenant", routing.name).Str("destination", name).Str("outcome", "held").Time("until", until).Msg("Holding the event: destination in a quiet window")
+	key := routing.name + "/" + name + "/quiet"
+	s.digests.hold(key, &digest{tenant: routing.name, destination: name, title: "Held during the quiet window", due: until, held: true}, event)
+	return false
+}
@@ -0,0 +1,93 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietWindowConfig_ActiveUntil(t *testing.T) {
+	window := &QuietWindowConfig{Schedule: "0 22 * * *", Duration: Duration(9 * time.Hour), Timezone: "UTC"}
+
+	tests := []struct {
+		now       time.Time
+		wantUntil time.Time
+		want      bool
+	}{
+		{time.Date(2021, 3, 1, 21, 59, 0, 0, time.UTC), time.Time{}, false},
+		{time.Date(2021, 3, 1, 22, 0, 0, 0, time.UTC), time.Date(2021, 3, 2, 7, 0, 0, 0, time.UTC), true},
+		{time.Date(2021, 3, 2, 3, 0, 0, 0, time.UTC), time.Date(2021, 3, 2, 7, 0, 0, 0, time.UTC), true},
+		{time.Date(2021, 3, 2, 7, 0, 0, 0, time.UTC), time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		until, ok := window.activeUntil(tt.now)
+		if tt.want != ok || !tt.wantUntil.Equal(until) {
+			t.Errorf("activeUntil(%v) expected %v %v, got %v %v", tt.now, tt.wantUntil, tt.want, until, ok)
+		}
+	}
+}
+
+func TestQuietWindow(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	until := now.Add(time.Hour)
+	later := now.Add(2 * time.Hour)
+
+	action, end, ok := quietWindow([]QuietWindowConfig{
+		{Until: &until},
+		{Until: &later, Action: "drop"},
+	}, now)
+	if !ok || action != "drop" || !end.Equal(later) {
+		t.Errorf("quietWindow expected drop until %v, got %v %v %v", later, action, end, ok)
+	}
+
+	if _, _, ok := quietWindow([]QuietWindowConfig{{Until: &until}}, later); ok {
+		t.Errorf("quietWindow after the end expected no window")
+	}
+}
+
+func TestEvents_QuietWindow(t *testing.T) {
+	until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A", "quiet_windows": [{"until": %q}]}],
+		"routes": [{"destinations": ["ops"]}]
+	}`, until)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	service := &failingService{}
+	server.routing.services["ops"] = service
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "d4b8f2a6-quiet-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusOK; want != response.Code {
+		t.Errorf("POST /events expected HTTP %v, got %v", want, response.Code)
+	}
+	if want, got := 0, service.sent; want != got {
+		t.Errorf("destination expected %v events during the quiet window, got %v", want, got)
+	}
+
+	server.flushDigests(time.Now().Add(2 * time.Hour))
+	if want, got := 1, len(service.messages); want != got {
+		t.Fatalf("destination expected %v held message, got %v", want, got)
+	}
+	if !strings.HasPrefix(service.messages[0], "Held during the quiet window: 1 events") {
+		t.Errorf("held message unexpected: %v", service.messages[0])
+	}
+}
+
+func TestParseConfig_InvalidQuietWindow(t *testing.T) {
+	for _, window := range []string{`{"schedule": "0 22 * * *"}`, `{"schedule": "bad", "duration": "1h"}`, `{}`, `{"until": "2021-01-01T00:00:00Z", "action": "ignore"}`} {
+		data := fmt.Sprintf(`{"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A", "quiet_windows": [%s]}]}`, window)
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("ParseConfig expected error for quiet window %v", window)
+		}
+	}
+}
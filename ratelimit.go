@@ -0,0 +1,113 @@
+package strillone
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig represents a token bucket rate limit.
+type RateLimitConfig struct {
+	// Rate is the number of requests per second refilled in the bucket.
+	Rate float64 `json:"rate"`
+
+	// Burst is the size of the bucket, the maximum number of requests accepted at once.
+	Burst int `json:"burst"`
+}
+
+func (c *RateLimitConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Rate <= 0 || c.Burst <= 0 {
+		return fmt.Errorf("rate limit: rate and burst must be positive")
+	}
+	return nil
+}
+
+// rateLimiter is a set of token buckets, by key.
+//
+// The limits are given on every call, so that the buckets survive configuration reloads.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+
+	// fullAt is when the bucket is full again, with the limit of its last call.
+	fullAt time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: map[string]*tokenBucket{}, now: time.Now}
+}
+
+// Allow takes a token from the bucket of the key.
+// If the bucket is empty, it returns false and the time to wait for the next token.
+func (l *rateLimiter) Allow(key string, limit *RateLimitConfig) (bool, time.Duration) {
+	if limit == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+elapsed*limit.Rate)
+	bucket.last = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	bucket.fullAt = now.Add(time.Duration((float64(limit.Burst) - bucket.tokens) / limit.Rate * float64(time.Second)))
+	if allowed {
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep removes the buckets that are full again, at most once a minute. Each bucket is full
+// at its own time, since the keys can have different limits.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// writeTooManyRequests responds with a 429 and the Retry-After header, in seconds.
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
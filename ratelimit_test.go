@@ -0,0 +1,129 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter()
+	limiter.now = func() time.Time { return now }
+	limit := &RateLimitConfig{Rate: 1, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("a", limit); !ok {
+			t.Errorf("Allow(a) #%d expected to be allowed", i)
+		}
+	}
+	ok, retryAfter := limiter.Allow("a", limit)
+	if ok {
+		t.Errorf("Allow(a) expected to be limited")
+	}
+	if want, got := time.Second, retryAfter; want != got {
+		t.Errorf("Allow(a) expected retry after %v, got %v", want, got)
+	}
+
+	if ok, _ := limiter.Allow("b", limit); !ok {
+		t.Errorf("Allow(b) expected to be allowed")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := limiter.Allow("a", limit); !ok {
+		t.Errorf("Allow(a) after refill expected to be allowed")
+	}
+
+	if ok, _ := limiter.Allow("a", nil); !ok {
+		t.Errorf("Allow(a) without limit expected to be allowed")
+	}
+}
+
+func TestRateLimiter_SweepDifferentLimits(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter()
+	limiter.now = func() time.Time { return now }
+	fast := &RateLimitConfig{Rate: 10, Burst: 10}
+	slow := &RateLimitConfig{Rate: 0.001, Burst: 1}
+
+	if ok, _ := limiter.Allow("slow", slow); !ok {
+		t.Fatalf("Allow(slow) expected to be allowed")
+	}
+
+	// The sweep of a call with the fast limit keeps the bucket of the slow key, not refilled yet.
+	now = now.Add(2 * time.Minute)
+	limiter.Allow("fast", fast)
+	if ok, _ := limiter.Allow("slow", slow); ok {
+		t.Errorf("Allow(slow) after the sweep expected to be limited")
+	}
+
+	// The buckets full again are removed.
+	now = now.Add(time.Hour)
+	limiter.Allow("fast", fast)
+	if _, ok := limiter.buckets["slow"]; ok {
+		t.Errorf("sweep expected to remove the full bucket of the slow key")
+	}
+}
+
+func TestInbound_RateLimit(t *testing.T) {
+	server := NewServer(&Config{Inbound: InboundConfig{RateLimit: &RateLimitConfig{Rate: 0.1, Burst: 1}}})
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+
+	post := func(remoteAddr, requestID string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(fmt.Sprintf(payload, requestID)))
+		request.RemoteAddr = remoteAddr
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response
+	}
+
+	if response := post("192.0.2.1:1234", "3f6a1c2e-ratelimit-0000-000000000001"); http.StatusOK != response.Code {
+		t.Errorf("POST /slack expected HTTP %v, got %v", http.StatusOK, response.Code)
+	}
+	response := post("192.0.2.1:1234", "3f6a1c2e-ratelimit-0000-000000000002")
+	if want := http.StatusTooManyRequests; want != response.Code {
+		t.Errorf("POST /slack over the limit expected HTTP %v, got %v", want, response.Code)
+	}
+	if want, got := "10", response.Header().Get("Retry-After"); want != got {
+		t.Errorf("POST /slack over the limit expected Retry-After %v, got %v", want, got)
+	}
+	if response := post("192.0.2.2:1234", "3f6a1c2e-ratelimit-0000-000000000003"); http.StatusOK != response.Code {
+		t.Errorf("POST /slack from another IP expected HTTP %v, got %v", http.StatusOK, response.Code)
+	}
+}
+
+func TestTenantEvents_RateLimit(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"tenants": [
+			{
+				"name": "team-a",
+				"token": "aaaaaaaaaaaaaaaaaaaa",
+				"rate_limit": {"rate": 0.1, "burst": 1}
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		request, _ := http.NewRequest("POST", "/t/aaaaaaaaaaaaaaaaaaaa/events", strings.NewReader(fmt.Sprintf(payload, fmt.Sprintf("3f6a1c2e-tenant-limit-0000-00000000000%d", i))))
+		request.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", i+1)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want != response.Code {
+			t.Errorf("POST /t/:token/events #%d expected HTTP %v, got %v", i, want, response.Code)
+		}
+	}
+}
+
+func TestParseConfig_InvalidRateLimit(t *testing.T) {
+	if _, err := ParseConfig([]byte(`{"inbound": {"rate_limit": {"rate": 0, "burst": 1}}}`)); err == nil {
+		t.Errorf("ParseConfig expected error for invalid rate limit")
+	}
+}
@@ -0,0 +1,135 @@
+package strillone
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The roles of the API keys: the viewers read the events, the configuration without its credentials and the statistics,
+// the operators also redeliver the events, and manage the dead letters, the escalations and the silences,
+// and the admins also change the configuration.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var roleLevels = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// maskedCredential replaces the credentials of the destinations listed to the viewers and operators.
+const maskedCredential = "***"
+
+func (c *AdminConfig) validate() error {
+	names := make(map[string]bool, len(c.Keys))
+	tokens := make(map[string]bool, len(c.Keys))
+	for i, key := range c.Keys {
+		if key.Name == "" {
+			return fmt.Errorf("admin: key #%d: missing name", i)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("admin: key %q: duplicate name", key.Name)
+		}
+		if key.Token == "" {
+			return fmt.Errorf("admin: key %q: missing token", key.Name)
+		}
+		if tokens[key.Token] || key.Token == c.Token {
+			return fmt.Errorf("admin: key %q: duplicate token", key.Name)
+		}
+		if roleLevels[key.Role] == 0 {
+			return fmt.Errorf("admin: key %q: invalid role %q, expected %s, %s or %s", key.Name, key.Role, RoleViewer, RoleOperator, RoleAdmin)
+		}
+		names[key.Name] = true
+		tokens[key.Token] = true
+	}
+	return c.OIDC.validate()
+}
+
+// adminKey is a resolved API key.
+type adminKey struct {
+	name  string
+	token string
+	role  string
+}
+
+// allows returns true if the role of the key includes the role.
+func (k *adminKey) allows(role string) bool {
+	return roleLevels[k.role] >= roleLevels[role]
+}
+
+// resolveAdminKeys returns the keys of the configuration, with the resolved admin token as an unnamed admin key.
+func resolveAdminKeys(token string, config []APIKeyConfig, secrets *Secrets) ([]*adminKey, error) {
+	var keys []*adminKey
+	if token != "" {
+		keys = append(keys, &adminKey{token: token, role: RoleAdmin})
+	}
+	for _, key := range config {
+		token, err := secrets.Resolve(key.Token)
+		if err != nil {
+			return nil, fmt.Errorf("admin: key %q: %v", key.Name, err)
+		}
+		keys = append(keys, &adminKey{name: key.Name, token: token, role: key.Role})
+	}
+	return keys, nil
+}
+
+// authenticate returns the key of the credential, or nil.
+// All the keys are compared, so that the time taken doesn't reveal which one matched.
+func authenticate(keys []*adminKey, credential string) *adminKey {
+	var found *adminKey
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(credential), []byte(key.token)) == 1 {
+			found = key
+		}
+	}
+	return found
+}
+
+type adminKeyContext struct{}
+
+// withAdminKey returns the request with the key that authenticated it.
+func withAdminKey(r *http.Request, key *adminKey) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), adminKeyContext{}, key))
+}
+
+// requestAdminKey returns the key that authenticated the request, or nil.
+func requestAdminKey(r *http.Request) *adminKey {
+	key, _ := r.Context().Value(adminKeyContext{}).(*adminKey)
+	return key
+}
+
+// WithRole returns the request authorized with the role, for the applications embedding Strillone that call
+// the admin handlers directly, after authenticating the request themselves. The name identifies the actor
+// in the audit log. Without it, the handlers deny the requests the role that they require.
+func WithRole(r *http.Request, name, role string) *http.Request {
+	return withAdminKey(r, &adminKey{name: name, role: role})
+}
+
+// requestAllows returns true if the key that authenticated the request includes the role,
+// false if the request isn't authenticated.
+func requestAllows(r *http.Request, role string) bool {
+	key := requestAdminKey(r)
+	return key != nil && key.allows(role)
+}
+
+// maskDestinations returns a copy of the destinations with their credentials masked.
+func maskDestinations(destinations []DestinationConfig) []DestinationConfig {
+	masked := (&Config{Destinations: destinations}).Clone().Destinations
+	updateDestinationCredentials(masked, func(credential string) string {
+		if credential == "" {
+			return credential
+		}
+		return maskedCredential
+	})
+	if masked == nil {
+		masked = []DestinationConfig{}
+	}
+	return masked
+}
+
+// bearerToken returns the bearer token of the request.
+func bearerToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
@@ -0,0 +1,129 @@
+package strillone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newRBACTestServer(t *testing.T) *Server {
+	config, err := ParseConfig([]byte(`{
+		"admin": {"token": "secret", "keys": [
+			{"name": "support", "token": "viewer-secret", "role": "viewer"},
+			{"name": "oncall", "token": "operator-secret", "role": "operator"}
+		]},
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetConfigStore(&MemoryConfigStore{})
+	return server
+}
+
+func keyRequest(server *Server, token, method, path, body string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+token)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+func TestAdmin_Roles(t *testing.T) {
+	server := newRBACTestServer(t)
+
+	tests := []struct {
+		token  string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"wrong", "GET", "/admin/destinations", "", http.StatusUnauthorized},
+		{"viewer-secret", "GET", "/admin/routes", "", http.StatusOK},
+		{"viewer-secret", "GET", "/api/silences", "", http.StatusOK},
+		{"viewer-secret", "POST", "/api/silences", `{"events": ["zone_record.*"], "duration": "1h"}`, http.StatusForbidden},
+		{"viewer-secret", "DELETE", "/admin/routes/ops", "", http.StatusForbidden},
+		{"operator-secret", "POST", "/api/silences", `{"events": ["zone_record.*"], "duration": "1h"}`, http.StatusCreated},
+		{"operator-secret", "POST", "/admin/destinations", `{"name": "dev", "type": "slack", "url": "https://hooks.slack.com/services/B"}`, http.StatusForbidden},
+		{"secret", "POST", "/admin/destinations", `{"name": "dev", "type": "slack", "url": "https://hooks.slack.com/services/B"}`, http.StatusCreated},
+	}
+	for _, test := range tests {
+		response := keyRequest(server, test.token, test.method, test.path, test.body)
+		if test.status != response.Code {
+			t.Errorf("%s %s with %s expected HTTP %v, got %v: %v", test.method, test.path, test.token, test.status, response.Code, response.Body)
+		}
+	}
+}
+
+func TestAdmin_MasksCredentials(t *testing.T) {
+	server := newRBACTestServer(t)
+
+	var destinations []DestinationConfig
+	response := keyRequest(server, "viewer-secret", "GET", "/admin/destinations", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &destinations); err != nil {
+		t.Fatalf("GET /admin/destinations returned %v: %v", response.Code, response.Body)
+	}
+	if len(destinations) != 1 || destinations[0].URL != maskedCredential {
+		t.Errorf("GET /admin/destinations by a viewer expected the URL masked, got %+v", destinations)
+	}
+
+	var destination DestinationConfig
+	response = keyRequest(server, "secret", "GET", "/admin/destinations/ops", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &destination); err != nil {
+		t.Fatalf("GET /admin/destinations/ops returned %v: %v", response.Code, response.Body)
+	}
+	if want, got := "https://hooks.slack.com/services/A", destination.URL; want != got {
+		t.Errorf("GET /admin/destinations/ops by an admin expected URL %v, got %v", want, got)
+	}
+	if got := server.currentRouting().config.Destinations[0].URL; got == maskedCredential {
+		t.Errorf("expected the configuration unchanged by the masking")
+	}
+}
+
+func TestWithRole(t *testing.T) {
+	server := newRBACTestServer(t)
+
+	tests := []struct {
+		request *http.Request
+		masked  bool
+	}{
+		{httptest.NewRequest("GET", "/admin/destinations", nil), true},
+		{WithRole(httptest.NewRequest("GET", "/admin/destinations", nil), "portal", RoleViewer), true},
+		{WithRole(httptest.NewRequest("GET", "/admin/destinations", nil), "portal", RoleAdmin), false},
+	}
+	for _, test := range tests {
+		response := httptest.NewRecorder()
+		server.AdminListDestinations(response, test.request, nil)
+		var destinations []DestinationConfig
+		if err := json.Unmarshal(response.Body.Bytes(), &destinations); err != nil {
+			t.Fatalf("AdminListDestinations returned %v: %v", response.Code, response.Body)
+		}
+		if masked := destinations[0].URL == maskedCredential; test.masked != masked {
+			t.Errorf("AdminListDestinations with the key %+v expected masked %v, got %v", requestAdminKey(test.request), test.masked, masked)
+		}
+	}
+
+	if want, got := "portal", requestActor(WithRole(httptest.NewRequest("GET", "/", nil), "portal", RoleAdmin)); want != got {
+		t.Errorf("requestActor expected %q, got %q", want, got)
+	}
+}
+
+func TestAdminConfig_validate(t *testing.T) {
+	tests := []AdminConfig{
+		{Keys: []APIKeyConfig{{Token: "a", Role: RoleViewer}}},
+		{Keys: []APIKeyConfig{{Name: "a", Role: RoleViewer}}},
+		{Keys: []APIKeyConfig{{Name: "a", Token: "a", Role: "owner"}}},
+		{Keys: []APIKeyConfig{{Name: "a", Token: "a", Role: RoleViewer}, {Name: "a", Token: "b", Role: RoleViewer}}},
+		{Token: "a", Keys: []APIKeyConfig{{Name: "a", Token: "a", Role: RoleViewer}}},
+	}
+	for _, config := range tests {
+		if err := config.validate(); err == nil {
+			t.Errorf("validate(%+v) expected error", config)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// The kinds of personal data masked by the redaction.
+const (
+	RedactEmail   = "email"
+	RedactPhone   = "phone"
+	RedactAddress = "address"
+)
+
+var redactionKinds = []string{RedactEmail, RedactPhone, RedactAddress}
+
+// defaultRedactedEvents are the events redacted by default, about the contacts of the domain registrations.
+var defaultRedactedEvents = []string{"contact.*"}
+
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+
+// phoneFields and addressFields are the fields of the payloads holding phone numbers and postal addresses.
+var (
+	phoneFields   = map[string]bool{"phone": true, "fax": true}
+	addressFields = map[string]bool{"address1": true, "address2": true, "postal_code": true}
+)
+
+// RedactionConfig configures the masking of the personal data in the messages of the events,
+// so that it doesn't leak in the history of the chat channels.
+type RedactionConfig struct {
+	// Events are the patterns of the events redacted. Defaults to contact.*; an empty list disables the redaction.
+	Events []string `json:"events"`
+
+	// Data are the kinds of personal data masked: email, phone and address. Defaults to all of them.
+	Data []string `json:"data,omitempty"`
+}
+
+func (c *RedactionConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, pattern := range c.Events {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("redaction: invalid event pattern %q", pattern)
+		}
+	}
+	for _, kind := range c.Data {
+		if kind != RedactEmail && kind != RedactPhone && kind != RedactAddress {
+			return fmt.Errorf("redaction: invalid data %q, expected %s", kind, strings.Join(redactionKinds, ", "))
+		}
+	}
+	return nil
+}
+
+// redaction masks the personal data in the payloads of the matching events.
+type redaction struct {
+	events []string
+	kinds  map[string]bool
+}
+
+// newRedaction returns the redaction of the configuration, the contact events by default.
+func newRedaction(c *RedactionConfig) *redaction {
+	r := &redaction{events: defaultRedactedEvents, kinds: map[string]bool{}}
+	kinds := redactionKinds
+	if c != nil {
+		if c.Events != nil {
+			r.events = c.Events
+		}
+		if len(c.Data) > 0 {
+			kinds = c.Data
+		}
+	}
+	for _, kind := range kinds {
+		r.kinds[kind] = true
+	}
+	return r
+}
+
+// matches returns true if the event is redacted.
+func (r *redaction) matches(eventName string) bool {
+	if r == nil {
+		return false
+	}
+	for _, pattern := range r.events {
+		if ok, _ := path.Match(pattern, eventName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns the event with the personal data of its payload masked, or the event unchanged
+// when it isn't redacted.
+func (r *redaction) redact(event *webhook.Event) *webhook.Event {
+	if !r.matches(event.Name) {
+		return event
+	}
+	var payload interface{}
+	if err := json.Unmarshal(event.GetPayload(), &payload); err != nil {
+		return event
+	}
+	data, err := json.Marshal(r.redactValue("", payload))
+	if err != nil {
+		return event
+	}
+	redacted, err := webhook.ParseEvent(data)
+	if err != nil {
+		withEvent(log.Warn(), event).Err(err).Msg("Error redacting the event")
+		return event
+	}
+	return redacted
+}
+
+// redactValue masks the personal data in the value of the field.
+func (r *redaction) redactValue(field string, value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, v := range value {
+			value[key] = r.redactValue(key, v)
+		}
+		return value
+	case []interface{}:
+		for i, v := range value {
+			value[i] = r.redactValue(field, v)
+		}
+		return value
+	case string:
+		switch {
+		case value == "":
+			return value
+		case r.kinds[RedactAddress] && addressFields[field]:
+			return "***"
+		case r.kinds[RedactPhone] && phoneFields[field]:
+			return maskPhone(value)
+		case r.kinds[RedactEmail]:
+			return emailPattern.ReplaceAllString(value, "$1***@$2")
+		}
+	}
+	return value
+}
+
+// maskPhone masks the digits of the phone number but the last two, keeping its format.
+func maskPhone(phone string) string {
+	digits := 0
+	for _, c := range phone {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	masked := []rune(phone)
+	for i, c := range masked {
+		if c >= '0' && c <= '9' && digits > 2 {
+			masked[i] = '*'
+			digits--
+		}
+	}
+	return string(masked)
+}
+
+// redact masks the personal data of the event for the formatting of its messages.
+func (s *Server) redact(next WebhookHandler) WebhookHandler {
+	return func(p *Webhook) {
+		p.Event = p.routing.redaction.redact(p.Event)
+		next(p)
+	}
+}
@@ -0,0 +1,79 @@
+package strillone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+const redactTestPayload = `{"name": "contact.create", "request_identifier": "e2b8c4d1-redact-0000-000000000001",
+	"actor": {"pretty": "jane.doe@example.com"}, "account": {"id": 1010, "display": "User"},
+	"data": {"contact": {"id": 1, "first_name": "Jane", "last_name": "Doe", "email": "jane.doe@example.com",
+		"phone": "+1.5555551234", "fax": "", "address1": "1 Main Street", "postal_code": "12345", "city": "Springfield"}}}`
+
+func TestRedaction_redact(t *testing.T) {
+	event, err := webhook.ParseEvent([]byte(redactTestPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		config   *RedactionConfig
+		expected map[string]string
+	}{
+		{nil, map[string]string{"email": "j***@example.com", "phone": "+*.********34", "address1": "***", "postal_code": "***", "city": "Springfield"}},
+		{&RedactionConfig{Events: []string{"contact.*"}, Data: []string{"phone"}}, map[string]string{"email": "jane.doe@example.com", "phone": "+*.********34", "address1": "1 Main Street"}},
+		{&RedactionConfig{Events: []string{}}, map[string]string{"email": "jane.doe@example.com", "phone": "+1.5555551234", "address1": "1 Main Street"}},
+	}
+	for _, test := range tests {
+		redacted := newRedaction(test.config).redact(event)
+		var payload struct {
+			Data struct {
+				Contact map[string]interface{} `json:"contact"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(redacted.GetPayload(), &payload); err != nil {
+			t.Fatal(err)
+		}
+		for field, want := range test.expected {
+			if got := payload.Data.Contact[field]; want != got {
+				t.Errorf("redact(%+v) expected %v %q, got %q", test.config, field, want, got)
+			}
+		}
+	}
+
+	// the other events aren't redacted
+	domain, _ := webhook.ParseEvent([]byte(`{"name": "domain.create", "actor": {"pretty": "jane.doe@example.com"}, "data": {"domain": {"name": "example.com"}}}`))
+	if redacted := newRedaction(nil).redact(domain); redacted != domain {
+		t.Errorf("redact expected the domain event unchanged")
+	}
+}
+
+func TestEvents_Redaction(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.routing.services["ops"] = &failingService{}
+
+	request, _ := http.NewRequest("POST", "/events?dryrun=1", strings.NewReader(redactTestPayload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	var result DryRunResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+		t.Fatalf("POST /events?dryrun=1 returned %v: %v", response.Code, response.Body)
+	}
+	message := result.Messages["ops"]
+	if !strings.Contains(message, "j***@example.com created the contact") || strings.Contains(message, "jane.doe@") {
+		t.Errorf("POST /events?dryrun=1 expected the email masked, got %q", message)
+	}
+}
@@ -0,0 +1,262 @@
+package strillone
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SetRedis shares with the other instances running behind a load balancer, in the Redis server at the URL,
+// the webhooks seen in the replay and deduplication windows, and the silences.
+func (s *Server) SetRedis(rawURL string) error {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return err
+	}
+	s.replays = &redisGuard{client: client, prefix: "strillone:replay:"}
+	s.duplicates = &redisGuard{client: client, prefix: "strillone:dedup:"}
+	s.silences = &redisSilences{client: client}
+	return nil
+}
+
+// redisTimeout is the timeout of the commands sent to Redis.
+const redisTimeout = time.Second
+
+// redisOptions are the settings of the connection to Redis.
+type redisOptions struct {
+	addr     string
+	tls      bool
+	username string
+	password string
+	db       int
+}
+
+// parseRedisURL parses a redis:// or rediss:// (TLS) URL.
+func parseRedisURL(rawURL string) (*redisOptions, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, fmt.Errorf("invalid redis url")
+	}
+	options := &redisOptions{addr: u.Host, tls: u.Scheme == "rediss"}
+	if u.Port() == "" {
+		options.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		options.username = u.User.Username()
+		options.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if options.db, err = strconv.Atoi(db); err != nil || options.db < 0 {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return options, nil
+}
+
+// redisClient speaks the RESP protocol to Redis, on a single connection opened again after the errors.
+type redisClient struct {
+	options *redisOptions
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newRedisClient returns the client of the Redis server at the URL.
+func newRedisClient(rawURL string) (*redisClient, error) {
+	options, err := parseRedisURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &redisClient{options: options}, nil
+}
+
+// do sends the command, and returns its reply: a string, an int64, a []byte, a []interface{}, or nil.
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.command(args...)
+	if err != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// transaction sends the commands in a MULTI/EXEC transaction, and returns their replies.
+func (c *redisClient) transaction(commands ...[]string) ([]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	replies, err := c.exec(commands)
+	if err != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return replies, err
+}
+
+func (c *redisClient) exec(commands [][]string) ([]interface{}, error) {
+	if _, err := c.command("MULTI"); err != nil {
+		return nil, err
+	}
+	for _, args := range commands {
+		if _, err := c.command(args...); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.command("EXEC")
+	if err != nil {
+		return nil, err
+	}
+	replies, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: transaction aborted")
+	}
+	for _, reply := range replies {
+		if err, ok := reply.(redisError); ok {
+			return replies, err
+		}
+	}
+	return replies, nil
+}
+
+// close closes the connection.
+func (c *redisClient) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// connect opens the connection, authenticates, and selects the database.
+func (c *redisClient) connect() error {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if c.options.tls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.options.addr, &tls.Config{MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", c.options.addr)
+	}
+	if err != nil {
+		return err
+	}
+	c.conn, c.reader = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	if c.options.password != "" && c.options.username != "" {
+		setup = append(setup, []string{"AUTH", c.options.username, c.options.password})
+	} else if c.options.password != "" {
+		setup = append(setup, []string{"AUTH", c.options.password})
+	}
+	if c.options.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.options.db)})
+	}
+	for _, args := range setup {
+		if _, err := c.command(args...); err != nil {
+			conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// command writes the command as an array of bulk strings, and reads the reply.
+func (c *redisClient) command(args ...string) (interface{}, error) {
+	_ = c.conn.SetDeadline(time.Now().Add(redisTimeout))
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.reader)
+}
+
+// redisError is an error reply.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readRedisReply reads a simple string, error, integer, bulk string or array reply.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+		return value[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			value, err := readRedisReply(r)
+			if reply, ok := err.(redisError); ok {
+				// the errors of the commands of a transaction are replies
+				value = reply
+			} else if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,323 @@
+package strillone
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-memory Redis server implementing the commands used by Strillone.
+type fakeRedis struct {
+	mu      sync.Mutex
+	strings map[string]string
+	expires map[string]time.Time
+	hashes  map[string]map[string]string
+	zsets   map[string]map[string]float64
+
+	// received are the commands received, in order.
+	received []string
+}
+
+// startFakeRedis starts a fakeRedis, and returns its URL.
+func startFakeRedis(t *testing.T) (string, *fakeRedis) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redis := &fakeRedis{
+		strings: map[string]string{},
+		expires: map[string]time.Time{},
+		hashes:  map[string]map[string]string{},
+		zsets:   map[string]map[string]float64{},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go redis.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return "redis://" + listener.Addr().String(), redis
+}
+
+// commands returns the commands received, with their arguments.
+func (r *fakeRedis) commands() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.received...)
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	var queued [][]string
+	multi := false
+	for {
+		args, err := readTestRedisCommand(reader)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		r.received = append(r.received, strings.Join(args, " "))
+		r.mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "MULTI":
+			multi, queued = true, nil
+			io.WriteString(conn, "+OK\r\n")
+		case "EXEC":
+			r.mu.Lock()
+			fmt.Fprintf(conn, "*%d\r\n", len(queued))
+			for _, args := range queued {
+				io.WriteString(conn, r.execute(args))
+			}
+			r.mu.Unlock()
+			multi = false
+		default:
+			if multi {
+				queued = append(queued, args)
+				io.WriteString(conn, "+QUEUED\r\n")
+				continue
+			}
+			r.mu.Lock()
+			io.WriteString(conn, r.execute(args))
+			r.mu.Unlock()
+		}
+	}
+}
+
+func readTestRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// execute runs the command, and returns its encoded reply. The lock must be held.
+func (r *fakeRedis) execute(args []string) string {
+	bulk := func(value string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value) }
+	array := func(values []string) string {
+		reply := fmt.Sprintf("*%d\r\n", len(values))
+		for _, value := range values {
+			reply += bulk(value)
+		}
+		return reply
+	}
+	key := ""
+	if strings.ToUpper(args[0]) == "EVAL" && len(args) > 3 {
+		key = args[3]
+	} else if len(args) > 1 {
+		key = args[1]
+	}
+	if key != "" {
+		if at, ok := r.expires[key]; ok && !time.Now().Before(at) {
+			delete(r.strings, key)
+			delete(r.expires, key)
+		}
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if value, ok := r.strings[key]; ok {
+			return bulk(value)
+		}
+		return "$-1\r\n"
+	case "SET":
+		_, exists := r.strings[key]
+		var expires time.Time
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if exists {
+					return "$-1\r\n"
+				}
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+				i++
+			}
+		}
+		r.strings[key] = args[2]
+		delete(r.expires, key)
+		if !expires.IsZero() {
+			r.expires[key] = expires
+		}
+		return "+OK\r\n"
+	case "DEL":
+		_, ok := r.strings[key]
+		delete(r.strings, key)
+		delete(r.expires, key)
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "EVAL":
+		// the only script is the renewal of the leader
+		if args[1] != redisRenewScript || r.strings[key] != args[4] {
+			return ":0\r\n"
+		}
+		ms, _ := strconv.Atoi(args[5])
+		r.expires[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	case "INCR":
+		n, _ := strconv.Atoi(r.strings[key])
+		r.strings[key] = strconv.Itoa(n + 1)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	case "HSET":
+		if r.hashes[key] == nil {
+			r.hashes[key] = map[string]string{}
+		}
+		r.hashes[key][args[2]] = args[3]
+		return ":1\r\n"
+	case "HGET":
+		if value, ok := r.hashes[key][args[2]]; ok {
+			return bulk(value)
+		}
+		return "$-1\r\n"
+	case "HDEL":
+		_, ok := r.hashes[key][args[2]]
+		delete(r.hashes[key], args[2])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "HLEN":
+		return fmt.Sprintf(":%d\r\n", len(r.hashes[key]))
+	case "HVALS":
+		var values []string
+		for _, value := range r.hashes[key] {
+			values = append(values, value)
+		}
+		return array(values)
+	case "ZADD":
+		if r.zsets[key] == nil {
+			r.zsets[key] = map[string]float64{}
+		}
+		score, _ := strconv.ParseFloat(args[2], 64)
+		r.zsets[key][args[3]] = score
+		return ":1\r\n"
+	case "ZREM":
+		delete(r.zsets[key], args[2])
+		return ":1\r\n"
+	case "ZRANGEBYSCORE":
+		max, _ := strconv.ParseFloat(args[3], 64)
+		limit, _ := strconv.Atoi(args[6])
+		var members []string
+		for member, score := range r.zsets[key] {
+			if score <= max {
+				members = append(members, member)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool {
+			si, sj := r.zsets[key][members[i]], r.zsets[key][members[j]]
+			return si < sj || (si == sj && members[i] < members[j])
+		})
+		if len(members) > limit {
+			members = members[:limit]
+		}
+		return array(members)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+func TestRedisClient_transaction(t *testing.T) {
+	url, _ := startFakeRedis(t)
+	client, err := newRedisClient(url)
+	if err != nil {
+		t.Fatalf("newRedisClient returned error: %v", err)
+	}
+	defer client.close()
+
+	replies, err := client.transaction([]string{"INCR", "counter"}, []string{"HSET", "hash", "a", "1"}, []string{"HVALS", "hash"})
+	if err != nil {
+		t.Fatalf("transaction returned error: %v", err)
+	}
+	if want, got := int64(1), replies[0]; want != got {
+		t.Errorf("transaction expected reply %v, got %v", want, got)
+	}
+	if values, ok := replies[2].([]interface{}); !ok || len(values) != 1 || string(values[0].([]byte)) != "1" {
+		t.Errorf("transaction expected the values of the hash, got %v", replies[2])
+	}
+
+	if _, err := client.transaction([]string{"INCR", "counter"}, []string{"UNKNOWN"}); err == nil {
+		t.Errorf("transaction with an invalid command expected error")
+	}
+	if reply, err := client.do("GET", "counter"); err != nil || string(reply.([]byte)) != "2" {
+		t.Errorf("do after a failed transaction expected 2, got %v %v", reply, err)
+	}
+}
+
+func TestServer_SetRedis(t *testing.T) {
+	url, _ := startFakeRedis(t)
+	servers := make([]*Server, 2)
+	for i := range servers {
+		server, _ := newAdminTestServer()
+		if err := server.SetRedis(url); err != nil {
+			t.Fatalf("SetRedis returned error: %v", err)
+		}
+		servers[i] = server
+	}
+
+	// the webhooks claimed by an instance are seen by the others
+	if !servers[0].replays.claim("a", time.Minute) {
+		t.Errorf("claim expected a new key to be claimed")
+	}
+	if servers[1].replays.claim("a", time.Minute) || !servers[1].duplicates.claim("a", time.Minute) {
+		t.Errorf("claim expected the replay key to be shared, and apart from the duplicate keys")
+	}
+	servers[0].replays.release("a")
+	if !servers[1].replays.claim("a", time.Minute) {
+		t.Errorf("claim expected a released key to be claimed")
+	}
+
+	// the silences created on an instance apply to the others
+	response := adminRequest(servers[0], "POST", "/api/silences", `{"domains": ["example.com"], "duration": "1h"}`)
+	if want := http.StatusCreated; want != response.Code {
+		t.Fatalf("POST /api/silences expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+	if want, got := 1, len(servers[1].currentSilences()); want != got {
+		t.Errorf("currentSilences expected %v silences, got %v", want, got)
+	}
+	if want, got := 0, len(servers[1].currentRouting().config.Silences); want != got {
+		t.Errorf("expected %v silences in the configuration, got %v", want, got)
+	}
+	response = adminRequest(servers[1], "POST", "/api/silences", `{"duration": "1h"}`)
+	if want := http.StatusUnprocessableEntity; want != response.Code {
+		t.Errorf("POST /api/silences of an invalid silence expected HTTP %v, got %v", want, response.Code)
+	}
+
+	silence := servers[1].currentSilences()[0]
+	if want, got := http.StatusNoContent, adminRequest(servers[1], "DELETE", "/api/silences/"+silence.ID, "").Code; want != got {
+		t.Errorf("DELETE /api/silences/:id expected HTTP %v, got %v", want, got)
+	}
+	if want, got := 0, len(servers[0].currentSilences()); want != got {
+		t.Errorf("currentSilences expected %v silences, got %v", want, got)
+	}
+}
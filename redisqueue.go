@@ -0,0 +1,248 @@
+package strillone
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	redisQueueJobs    = "strillone:queue:jobs"
+	redisQueueDue     = "strillone:queue:due"
+	redisQueueDead    = "strillone:queue:dead"
+	redisQueueSeq     = "strillone:queue:seq"
+	redisQueueKeys    = "strillone:queue:key:"
+	redisQueueLocks   = "strillone:queue:lock:"
+	redisQueueLeaseMs = "300000"
+)
+
+// RedisQueue is a Queue stored in Redis, shared by the instances behind a load balancer.
+//
+// The jobs are stored in a hash by ID, and scheduled in a sorted set by next attempt.
+// An instance locks the due jobs it delivers for 5 minutes, so that the other instances
+// don't deliver them too. The idempotency keys expire on their own.
+type RedisQueue struct {
+	client *redisClient
+
+	// owner identifies the instance in the locks.
+	owner string
+}
+
+// OpenRedisQueue connects to the queue in the Redis server at the URL, such as redis://:password@localhost:6379/0.
+func OpenRedisQueue(rawURL string) (*RedisQueue, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.do("PING"); err != nil {
+		return nil, err
+	}
+	owner := make([]byte, 8)
+	_, _ = rand.Read(owner)
+	return &RedisQueue{client: client, owner: hex.EncodeToString(owner)}, nil
+}
+
+// Enqueue implements Queue
+func (q *RedisQueue) Enqueue(jobs ...*Job) error {
+	for _, job := range jobs {
+		if job.Key != "" {
+			if ttl := queueIdempotencyWindow - time.Since(job.CreatedAt); ttl > 0 {
+				reply, err := q.client.do("SET", redisQueueKeys+job.Key, "1", "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+				if err != nil {
+					return err
+				}
+				if reply == nil {
+					continue
+				}
+			}
+		}
+		reply, err := q.client.do("INCR", redisQueueSeq)
+		if err != nil {
+			return err
+		}
+		id, _ := reply.(int64)
+		job.ID = uint64(id)
+		if err := q.put(job); err != nil {
+			job.ID = 0
+			if job.Key != "" {
+				q.client.do("DEL", redisQueueKeys+job.Key)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpireKeys implements Queue. The keys expire in Redis, at the end of the idempotency window.
+func (q *RedisQueue) ExpireKeys(before time.Time) error {
+	return nil
+}
+
+// Due implements Queue. The jobs returned are locked, until updated, deleted or buried.
+func (q *RedisQueue) Due(now time.Time, limit int) ([]*Job, error) {
+	reply, err := q.client.do("ZRANGEBYSCORE", redisQueueDue, "-inf", redisScore(now), "LIMIT", "0", strconv.Itoa(limit))
+	if err != nil {
+		return nil, err
+	}
+	ids, _ := reply.([]interface{})
+	var jobs []*Job
+	for _, id := range ids {
+		member, _ := id.([]byte)
+		locked, err := q.client.do("SET", redisQueueLocks+string(member), q.owner, "NX", "PX", redisQueueLeaseMs)
+		if err != nil {
+			return jobs, err
+		}
+		if locked == nil {
+			continue
+		}
+		data, err := q.client.do("HGET", redisQueueJobs, string(member))
+		if err != nil {
+			return jobs, err
+		}
+		if data == nil {
+			// deleted by another instance since
+			q.client.do("DEL", redisQueueLocks+string(member))
+			continue
+		}
+		job := &Job{}
+		if err := json.Unmarshal(data.([]byte), job); err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Update implements Queue
+func (q *RedisQueue) Update(job *Job) error {
+	return q.put(job)
+}
+
+// Delete implements Queue
+func (q *RedisQueue) Delete(id uint64) error {
+	member := strconv.FormatUint(id, 10)
+	_, err := q.client.transaction(
+		[]string{"HDEL", redisQueueJobs, member},
+		[]string{"ZREM", redisQueueDue, member},
+		[]string{"DEL", redisQueueLocks + member},
+	)
+	return err
+}
+
+// Bury implements Queue
+func (q *RedisQueue) Bury(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	member := strconv.FormatUint(job.ID, 10)
+	_, err = q.client.transaction(
+		[]string{"HDEL", redisQueueJobs, member},
+		[]string{"ZREM", redisQueueDue, member},
+		[]string{"HSET", redisQueueDead, member, string(data)},
+		[]string{"DEL", redisQueueLocks + member},
+	)
+	return err
+}
+
+// DeadLetters implements Queue
+func (q *RedisQueue) DeadLetters() ([]*Job, error) {
+	reply, err := q.client.do("HVALS", redisQueueDead)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := reply.([]interface{})
+	jobs := make([]*Job, 0, len(values))
+	for _, value := range values {
+		job := &Job{}
+		if err := json.Unmarshal(value.([]byte), job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+// Redeliver implements Queue
+func (q *RedisQueue) Redeliver(id uint64, now time.Time) error {
+	member := strconv.FormatUint(id, 10)
+	reply, err := q.client.do("HGET", redisQueueDead, member)
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return ErrJobNotFound
+	}
+	job := &Job{}
+	if err := json.Unmarshal(reply.([]byte), job); err != nil {
+		return err
+	}
+	job.Attempts = 0
+	job.NextAttempt = now
+	job.FailedAt = nil
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = q.client.transaction(
+		[]string{"HDEL", redisQueueDead, member},
+		[]string{"HSET", redisQueueJobs, member, string(data)},
+		[]string{"ZADD", redisQueueDue, redisScore(now), member},
+	)
+	return err
+}
+
+// DeleteDeadLetter implements Queue
+func (q *RedisQueue) DeleteDeadLetter(id uint64) error {
+	reply, err := q.client.do("HDEL", redisQueueDead, strconv.FormatUint(id, 10))
+	if err != nil {
+		return err
+	}
+	if n, _ := reply.(int64); n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// Len implements Queue
+func (q *RedisQueue) Len() (jobs int, deadLetters int, err error) {
+	replies, err := q.client.transaction(
+		[]string{"HLEN", redisQueueJobs},
+		[]string{"HLEN", redisQueueDead},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, _ := replies[0].(int64)
+	dead, _ := replies[1].(int64)
+	return int(n), int(dead), nil
+}
+
+// Close implements Queue
+func (q *RedisQueue) Close() error {
+	return q.client.close()
+}
+
+// put stores and schedules the job, and releases its lock.
+func (q *RedisQueue) put(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	member := strconv.FormatUint(job.ID, 10)
+	_, err = q.client.transaction(
+		[]string{"HSET", redisQueueJobs, member, string(data)},
+		[]string{"ZADD", redisQueueDue, redisScore(job.NextAttempt), member},
+		[]string{"DEL", redisQueueLocks + member},
+	)
+	return err
+}
+
+// redisScore returns the score of a time in a sorted set, in milliseconds.
+func redisScore(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
@@ -0,0 +1,94 @@
+package strillone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedisQueue(t *testing.T) {
+	url, _ := startFakeRedis(t)
+	queue, err := OpenRedisQueue(url)
+	if err != nil {
+		t.Fatalf("OpenRedisQueue returned error: %v", err)
+	}
+	defer queue.Close()
+	other, err := OpenRedisQueue(url)
+	if err != nil {
+		t.Fatalf("OpenRedisQueue returned error: %v", err)
+	}
+	defer other.Close()
+
+	now := time.Now()
+	jobs := []*Job{
+		{Destination: "a", Key: "1/a", CreatedAt: now, NextAttempt: now},
+		{Destination: "b", Key: "1/b", CreatedAt: now, NextAttempt: now.Add(time.Hour)},
+		{Destination: "c", NextAttempt: now},
+		{Destination: "a", Key: "1/a", CreatedAt: now, NextAttempt: now},
+	}
+	if err := queue.Enqueue(jobs...); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if jobs[0].ID == 0 || jobs[0].ID >= jobs[2].ID {
+		t.Errorf("Enqueue expected increasing IDs, got %v and %v", jobs[0].ID, jobs[2].ID)
+	}
+	if jobs[3].ID != 0 {
+		t.Errorf("Enqueue expected the job with a known key to be skipped")
+	}
+
+	due, err := queue.Due(now, 10)
+	if err != nil {
+		t.Fatalf("Due returned error: %v", err)
+	}
+	if want, got := 2, len(due); want != got {
+		t.Fatalf("Due expected %v jobs, got %v", want, got)
+	}
+	if want, got := "a", due[0].Destination; want != got {
+		t.Errorf("Due expected first job %v, got %v", want, got)
+	}
+	// the jobs are locked until updated
+	if locked, _ := other.Due(now, 10); len(locked) != 0 {
+		t.Errorf("Due on another instance expected no jobs, got %v", len(locked))
+	}
+
+	if err := queue.Delete(due[0].ID); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	due[1].NextAttempt = now.Add(time.Hour)
+	if err := queue.Update(due[1]); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	due, _ = other.Due(now, 10)
+	if want, got := 0, len(due); want != got {
+		t.Errorf("Due expected %v jobs, got %v", want, got)
+	}
+	due, _ = other.Due(now.Add(2*time.Hour), 10)
+	if want, got := 2, len(due); want != got {
+		t.Fatalf("Due later expected %v jobs, got %v", want, got)
+	}
+
+	failedAt := now
+	due[0].FailedAt = &failedAt
+	if err := other.Bury(due[0]); err != nil {
+		t.Fatalf("Bury returned error: %v", err)
+	}
+	if jobs, dead, err := queue.Len(); err != nil || jobs != 1 || dead != 1 {
+		t.Errorf("Len expected 1 job and 1 dead letter, got %v %v %v", jobs, dead, err)
+	}
+	letters, err := queue.DeadLetters()
+	if err != nil || len(letters) != 1 || letters[0].ID != due[0].ID {
+		t.Fatalf("DeadLetters expected the buried job, got %v %v", letters, err)
+	}
+	if err := queue.Redeliver(due[0].ID, now); err != nil {
+		t.Fatalf("Redeliver returned error: %v", err)
+	}
+	if err := queue.Redeliver(due[0].ID, now); err != ErrJobNotFound {
+		t.Errorf("Redeliver of an unknown dead letter expected %v, got %v", ErrJobNotFound, err)
+	}
+	if err := queue.DeleteDeadLetter(due[0].ID); err != ErrJobNotFound {
+		t.Errorf("DeleteDeadLetter of an unknown dead letter expected %v, got %v", ErrJobNotFound, err)
+	}
+	redelivered, _ := queue.Due(now, 10)
+	if len(redelivered) != 1 || redelivered[0].Attempts != 0 || redelivered[0].FailedAt != nil {
+		t.Errorf("Due expected the redelivered job, got %v", redelivered)
+	}
+}
@@ -0,0 +1,238 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultRegistrarSchedule checks the registered domains every hour.
+	defaultRegistrarSchedule = "0 * * * *"
+
+	// defaultRegistrarReportSchedule reports the registered domains every Monday at 09:00.
+	defaultRegistrarReportSchedule = "0 9 * * 1"
+)
+
+// RegistrarWatchdogConfig configures the audits of the transfer lock and the WHOIS privacy
+// of the registered domains, with the DNSimple API independently of the webhooks.
+type RegistrarWatchdogConfig struct {
+	// Destinations are the names of the destinations receiving the alerts and the reports.
+	Destinations []string `json:"destinations"`
+
+	// Accounts are the IDs of the accounts whose domains are audited.
+	// Defaults to the accounts accessible with the API token.
+	Accounts []int64 `json:"accounts,omitempty"`
+
+	// Schedule is a cron expression for the checks, in the Timezone, alerting the domains
+	// that were unlocked or lost their WHOIS privacy since the previous check. Defaults to every hour.
+	Schedule string `json:"schedule,omitempty"`
+
+	// ReportSchedule is a cron expression for the reports of the domains unlocked or without WHOIS privacy,
+	// in the Timezone. Defaults to every Monday at 09:00.
+	ReportSchedule string `json:"report_schedule,omitempty"`
+
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (c *RegistrarWatchdogConfig) validate(destinations map[string]bool, api *APIConfig) error {
+	if c == nil {
+		return nil
+	}
+	if api == nil {
+		return fmt.Errorf("registrar watchdog: the api is required")
+	}
+	if len(c.Destinations) == 0 {
+		return fmt.Errorf("registrar watchdog: missing destinations")
+	}
+	for _, name := range c.Destinations {
+		if !destinations[name] {
+			return fmt.Errorf("registrar watchdog: unknown destination %q", name)
+		}
+	}
+	if _, err := parseCron(c.schedule()); err != nil {
+		return fmt.Errorf("registrar watchdog: %v", err)
+	}
+	if _, err := parseCron(c.reportSchedule()); err != nil {
+		return fmt.Errorf("registrar watchdog: report: %v", err)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("registrar watchdog: %v", err)
+	}
+	return nil
+}
+
+func (c *RegistrarWatchdogConfig) schedule() string {
+	if c.Schedule == "" {
+		return defaultRegistrarSchedule
+	}
+	return c.Schedule
+}
+
+func (c *RegistrarWatchdogConfig) reportSchedule() string {
+	if c.ReportSchedule == "" {
+		return defaultRegistrarReportSchedule
+	}
+	return c.ReportSchedule
+}
+
+// ProcessRegistrarAudits audits the registered domains when the checks or the reports are scheduled,
+// checking the schedules at every interval, until done is closed or the server is shut down.
+func (s *Server) ProcessRegistrarAudits(interval time.Duration, done <-chan struct{}) {
+	s.processSchedule(interval, done, s.registrarAuditDue, func(ctx context.Context, now time.Time) {
+		config := s.currentRouting().config.RegistrarWatchdog
+		report := config != nil && scheduleDue(config.reportSchedule(), config.Timezone, now.Truncate(time.Minute))
+		s.auditRegistrar(ctx, report)
+	})
+}
+
+// registrarAuditDue returns true if the checks or the reports of the registered domains are scheduled at the minute.
+func (s *Server) registrarAuditDue(minute time.Time) bool {
+	config := s.currentRouting().config.RegistrarWatchdog
+	if config == nil {
+		return false
+	}
+	return scheduleDue(config.schedule(), config.Timezone, minute) || scheduleDue(config.reportSchedule(), config.Timezone, minute)
+}
+
+// auditRegistrar checks the transfer lock and the WHOIS privacy of the registered domains,
+// alerts the domains that lost them since the previous check, and reports the domains without them.
+func (s *Server) auditRegistrar(ctx context.Context, report bool) {
+	routing := s.currentRouting()
+	config := routing.config.RegistrarWatchdog
+	if config == nil || routing.api == nil {
+		return
+	}
+
+	accountIDs, err := routing.scheduledAccounts(ctx, config.Accounts)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching the accounts of the registrar watchdog")
+		return
+	}
+
+	for _, accountID := range accountIDs {
+		domains, err := listDomains(ctx, routing.api, accountID)
+		if err != nil {
+			log.Error().Err(err).Int64("account_id", accountID).Msg("Error listing the domains of the registrar watchdog")
+			continue
+		}
+
+		var audited int
+		var unlocked, unprotected []string
+		for _, domain := range domains {
+			if domain.State != "registered" {
+				continue
+			}
+			locked, err := transferLock(ctx, routing, accountID, domain.Name)
+			if err != nil {
+				log.Error().Err(err).Int64("account_id", accountID).Str("domain", domain.Name).Msg("Error fetching the transfer lock of the registrar watchdog")
+				continue
+			}
+			audited++
+			if !locked {
+				unlocked = append(unlocked, domain.Name)
+			}
+			if !domain.PrivateWhois {
+				unprotected = append(unprotected, domain.Name)
+			}
+
+			current := registrarState{locked: locked, privateWhois: domain.PrivateWhois}
+			previous, known := s.registrar.update(accountID, domain.Name, current)
+			if !known {
+				continue
+			}
+			name := domain.Name
+			if previous.locked && !current.locked {
+				routing.postScheduled(ctx, config.Destinations, func(s Formatter) string {
+					return registrarAlertMessage(s, accountID, name, true)
+				})
+			}
+			if previous.privateWhois && !current.privateWhois {
+				routing.postScheduled(ctx, config.Destinations, func(s Formatter) string {
+					return registrarAlertMessage(s, accountID, name, false)
+				})
+			}
+		}
+
+		if report {
+			routing.postScheduled(ctx, config.Destinations, func(s Formatter) string {
+				return registrarReportMessage(s, accountID, audited, unlocked, unprotected)
+			})
+		}
+	}
+}
+
+// transferLock returns true if the domain is locked against transfers.
+func transferLock(ctx context.Context, routing *routingTable, accountID int64, domain string) (bool, error) {
+	// The transfer lock is not supported by the vendored client yet.
+	response := struct {
+		Data struct {
+			Enabled bool `json:"enabled"`
+		} `json:"data"`
+	}{}
+	path := fmt.Sprintf("/v2/%d/registrar/domains/%s/transfer_lock", accountID, domain)
+	if _, err := routing.api.Request(ctx, http.MethodGet, path, nil, &response, nil); err != nil {
+		return false, err
+	}
+	return response.Data.Enabled, nil
+}
+
+// registrarAlertMessage returns the alert of the domain unlocked, or without WHOIS privacy.
+func registrarAlertMessage(s Formatter, accountID int64, domain string, unlocked bool) string {
+	account := scheduledAccountLink(s, accountID)
+	domainLink := s.FormatLink(domain, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s", accountID, domain))
+	if unlocked {
+		return fmt.Sprintf("[%s] %s", account, tprintf(s, "The domain %s is no longer locked against transfers", domainLink))
+	}
+	return fmt.Sprintf("[%s] %s", account, tprintf(s, "The WHOIS privacy of the domain %s is no longer enabled", domainLink))
+}
+
+// registrarReportMessage returns the report of the registered domains of the account.
+func registrarReportMessage(s Formatter, accountID int64, audited int, unlocked, unprotected []string) string {
+	account := scheduledAccountLink(s, accountID)
+	domainList := func(domains []string) string {
+		if len(domains) == 0 {
+			return translate(s, "none")
+		}
+		sort.Strings(domains)
+		links := make([]string, len(domains))
+		for i, domain := range domains {
+			links[i] = s.FormatLink(domain, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s", accountID, domain))
+		}
+		return strings.Join(links, ", ")
+	}
+	return fmt.Sprintf("[%s] %s", account, tprintf(s, "Registrar audit of %s domains: transfer lock disabled on %s, WHOIS privacy disabled on %s", fmt.Sprint(audited), domainList(unlocked), domainList(unprotected)))
+}
+
+// registrarState is the state of a registered domain found by the last check.
+type registrarState struct {
+	locked       bool
+	privateWhois bool
+}
+
+// registrarStates remembers the state of the registered domains between the checks.
+type registrarStates struct {
+	mu      sync.Mutex
+	domains map[string]registrarState
+}
+
+func newRegistrarStates() *registrarStates {
+	return &registrarStates{domains: map[string]registrarState{}}
+}
+
+// update records the state of the domain and returns its previous state, false if the domain wasn't checked before.
+func (r *registrarStates) update(accountID int64, domain string, state registrarState) (registrarState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := fmt.Sprintf("%d/%s", accountID, domain)
+	previous, known := r.domains[key]
+	r.domains[key] = state
+	return previous, known
+}
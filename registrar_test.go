@@ -0,0 +1,89 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_AuditRegistrar(t *testing.T) {
+	locked := map[string]bool{"example.com": true, "example.org": false}
+	privateWhois := true
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/1010/domains":
+			fmt.Fprintf(w, `{"data": [
+				{"id": 1, "name": "example.com", "state": "registered", "private_whois": %v},
+				{"id": 2, "name": "example.org", "state": "registered", "private_whois": false},
+				{"id": 3, "name": "example.net", "state": "hosted"}
+			], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 3, "total_pages": 1}}`, privateWhois)
+		case "/v2/1010/registrar/domains/example.com/transfer_lock":
+			fmt.Fprintf(w, `{"data": {"enabled": %v}}`, locked["example.com"])
+		case "/v2/1010/registrar/domains/example.org/transfer_lock":
+			fmt.Fprintf(w, `{"data": {"enabled": %v}}`, locked["example.org"])
+		default:
+			t.Errorf("unexpected API request %v", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"api": {"token": "api-token", "url": %q},
+		"registrar_watchdog": {"destinations": ["ops"], "accounts": [1010]}
+	}`, api.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	service := &failingService{}
+	server.routing.services["ops"] = service
+
+	// The first check records the state of the domains, without alerts.
+	server.auditRegistrar(context.Background(), false)
+	if len(service.messages) != 0 {
+		t.Errorf("auditRegistrar expected no alerts on the first check, got %v", service.messages)
+	}
+
+	locked["example.com"] = false
+	privateWhois = false
+	server.auditRegistrar(context.Background(), true)
+
+	want := []string{
+		"[<https://dnsimple.com/a/1010/account|1010>] The domain <https://dnsimple.com/a/1010/domains/example.com|example.com> is no longer locked against transfers",
+		"[<https://dnsimple.com/a/1010/account|1010>] The WHOIS privacy of the domain <https://dnsimple.com/a/1010/domains/example.com|example.com> is no longer enabled",
+		"[<https://dnsimple.com/a/1010/account|1010>] Registrar audit of 2 domains: transfer lock disabled on <https://dnsimple.com/a/1010/domains/example.com|example.com>, <https://dnsimple.com/a/1010/domains/example.org|example.org>, WHOIS privacy disabled on <https://dnsimple.com/a/1010/domains/example.com|example.com>, <https://dnsimple.com/a/1010/domains/example.org|example.org>",
+	}
+	if got := service.messages; strings.Join(want, "\n") != strings.Join(got, "\n") {
+		t.Errorf("auditRegistrar expected\n%v\ngot\n%v", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestRegistrarWatchdogConfig_validate(t *testing.T) {
+	destinations := map[string]bool{"ops": true}
+	api := &APIConfig{Token: "api-token"}
+	tests := []struct {
+		config *RegistrarWatchdogConfig
+		api    *APIConfig
+		valid  bool
+	}{
+		{nil, nil, true},
+		{&RegistrarWatchdogConfig{Destinations: []string{"ops"}, Schedule: "*/15 * * * *", ReportSchedule: "0 9 1 * *"}, api, true},
+		{&RegistrarWatchdogConfig{Destinations: []string{"ops"}}, nil, false},
+		{&RegistrarWatchdogConfig{Destinations: []string{"pager"}}, api, false},
+		{&RegistrarWatchdogConfig{Destinations: []string{"ops"}, ReportSchedule: "weekly"}, api, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate(destinations, test.api)
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
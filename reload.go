@@ -0,0 +1,73 @@
+package strillone
+
+import (
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// ConfigReloader reloads the configuration of a Server from a file.
+type ConfigReloader struct {
+	Path   string
+	Server *Server
+}
+
+// Reload reads the configuration file and applies it to the server, and audits the changes.
+// If the file is invalid, the current configuration is left untouched.
+func (r *ConfigReloader) Reload() error {
+	config, err := LoadConfig(r.Path)
+	if err != nil {
+		return err
+	}
+	current := r.Server.currentRouting().config
+	if err := r.Server.Reload(config); err != nil {
+		return err
+	}
+	r.Server.audit(&AuditEntry{Source: "reload", Actor: r.Path, Action: "reload"}, current, config)
+	return nil
+}
+
+// Watch reloads the configuration every time the file changes, until done is closed.
+//
+// The parent directory is watched rather than the file itself, so that editors
+// and deploy tools that replace the file with a rename are supported.
+func (r *ConfigReloader) Watch(done <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(r.Path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		target := filepath.Clean(r.Path)
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				log.Info().Str("path", r.Path).Msg("Configuration file changed, reloading")
+				if err := r.Reload(); err != nil {
+					log.Error().Err(err).Msg("Error reloading configuration")
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("Error watching configuration")
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultReminderSchedule runs the reminders every night.
+const defaultReminderSchedule = "0 2 * * *"
+
+// defaultReminderDays are the days before the expiration of the domains when the reminders are sent.
+var defaultReminderDays = []int{60, 30, 7, 1}
+
+// RemindersConfig configures the reminders of the domains expiring without auto-renewal,
+// checked with the DNSimple API independently of the webhooks.
+type RemindersConfig struct {
+	// Destinations are the names of the destinations receiving the reminders.
+	Destinations []string `json:"destinations"`
+
+	// Accounts are the IDs of the accounts whose domains are checked.
+	// Defaults to the accounts accessible with the API token.
+	Accounts []int64 `json:"accounts,omitempty"`
+
+	// Days are the days before the expiration when the reminders are sent. Defaults to 60, 30, 7 and 1.
+	Days []int `json:"days,omitempty"`
+
+	// Schedule is a cron expression for the checks, in the Timezone. Defaults to every night at 02:00.
+	Schedule string `json:"schedule,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (c *RemindersConfig) validate(destinations map[string]bool, api *APIConfig) error {
+	if c == nil {
+		return nil
+	}
+	if api == nil {
+		return fmt.Errorf("reminders: the api is required")
+	}
+	if len(c.Destinations) == 0 {
+		return fmt.Errorf("reminders: missing destinations")
+	}
+	for _, name := range c.Destinations {
+		if !destinations[name] {
+			return fmt.Errorf("reminders: unknown destination %q", name)
+		}
+	}
+	for _, days := range c.Days {
+		if days <= 0 {
+			return fmt.Errorf("reminders: days must be positive")
+		}
+	}
+	if _, err := parseCron(c.schedule()); err != nil {
+		return fmt.Errorf("reminders: %v", err)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("reminders: %v", err)
+	}
+	return nil
+}
+
+func (c *RemindersConfig) schedule() string {
+	if c.Schedule == "" {
+		return defaultReminderSchedule
+	}
+	return c.Schedule
+}
+
+func (c *RemindersConfig) days() []int {
+	if len(c.Days) == 0 {
+		return defaultReminderDays
+	}
+	return c.Days
+}
+
+// ProcessReminders checks the domains expiring without auto-renewal when the reminders are scheduled,
+// checking the schedule at every interval, until done is closed or the server is shut down.
+func (s *Server) ProcessReminders(interval time.Duration, done <-chan struct{}) {
+	s.processSchedule(interval, done, s.remindersDue, s.sendReminders)
+}
+
+// remindersDue returns true if the reminders are scheduled at the minute.
+func (s *Server) remindersDue(minute time.Time) bool {
+	config := s.currentRouting().config.Reminders
+	return config != nil && scheduleDue(config.schedule(), config.Timezone, minute)
+}
+
+// sendReminders sends the reminders of the domains expiring without auto-renewal at one of the reminder days.
+func (s *Server) sendReminders(ctx context.Context, now time.Time) {
+	routing := s.currentRouting()
+	config := routing.config.Reminders
+	if config == nil || routing.api == nil {
+		return
+	}
+
+	accountIDs, err := routing.scheduledAccounts(ctx, config.Accounts)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching the accounts of the reminders")
+		return
+	}
+
+	reminderDays := map[int]bool{}
+	for _, days := range config.days() {
+		reminderDays[days] = true
+	}
+
+	for _, accountID := range accountIDs {
+		domains, err := listDomains(ctx, routing.api, accountID)
+		if err != nil {
+			log.Error().Err(err).Int64("account_id", accountID).Msg("Error listing the domains of the reminders")
+			continue
+		}
+		for _, domain := range domains {
+			if domain.AutoRenew || domain.ExpiresAt == "" {
+				continue
+			}
+			expiresAt, ok := parseTimestamp(domain.ExpiresAt)
+			if !ok {
+				continue
+			}
+			days := daysUntil(now, expiresAt)
+			if !reminderDays[days] {
+				continue
+			}
+			routing.postScheduled(ctx, config.Destinations, func(s Formatter) string {
+				return reminderMessage(s, accountID, domain.Name, days, expiresAt)
+			})
+		}
+	}
+}
+
+// scheduledAccounts returns the configured accounts, or the accounts accessible with the API token.
+func (t *routingTable) scheduledAccounts(ctx context.Context, configured []int64) ([]int64, error) {
+	if len(configured) > 0 {
+		return configured, nil
+	}
+	accounts, err := t.accounts.accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accountIDs := make([]int64, 0, len(accounts))
+	for _, account := range accounts {
+		accountIDs = append(accountIDs, account.ID)
+	}
+	return accountIDs, nil
+}
+
+// postMessage posts the message to the service, within the delivery timeout.
+func (t *routingTable) postMessage(ctx context.Context, service MessagingService, text string) error {
+	ctx, cancel := t.deliveryContext(ctx)
+	defer cancel()
+	return service.PostMessage(ctx, text)
+}
+
+// postScheduled posts the message of a scheduled job to the destinations, formatted for each of them.
+func (t *routingTable) postScheduled(ctx context.Context, destinations []string, message func(s Formatter) string) {
+	for _, name := range destinations {
+		service := t.services[name]
+		if service == nil {
+			continue
+		}
+		if err := t.postMessage(ctx, service, message(unwrapService(service))); err != nil {
+			log.Error().Err(err).Str("destination", name).Msg("Error sending the scheduled message")
+		}
+	}
+}
+
+// daysUntil returns the number of days from the day of now to the day of the time, in UTC.
+func daysUntil(now, t time.Time) int {
+	from := now.UTC().Truncate(24 * time.Hour)
+	to := t.UTC().Truncate(24 * time.Hour)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// reminderMessage returns the reminder of the domain expiring in the days.
+func reminderMessage(s Formatter, accountID int64, domain string, days int, expiresAt time.Time) string {
+	account := scheduledAccountLink(s, accountID)
+	domainLink := s.FormatLink(domain, fmtDashboardURL(dashboardURL(s), "/a/%d/domains/%s", accountID, domain))
+	date := expiresAt.Format(certificateDateFormat)
+	if days == 1 {
+		return fmt.Sprintf("[%s] %s", account, tprintf(s, "The domain %s expires tomorrow, on %s, and auto-renewal is disabled", domainLink, date))
+	}
+	return fmt.Sprintf("[%s] %s", account, tprintf(s, "The domain %s expires in %d days, on %s, and auto-renewal is disabled", domainLink, days, date))
+}
+
+// scheduledAccountLink returns the link to the account checked by a scheduled job, labeled like the accounts of the events.
+func scheduledAccountLink(s Formatter, accountID int64) string {
+	label := accountDisplay(s, &webhook.Account{Account: dnsimple.Account{ID: accountID}, Display: strconv.FormatInt(accountID, 10)}, "")
+	return s.FormatLink(label, fmtDashboardURL(dashboardURL(s), "/a/%d/account", accountID))
+}
@@ -0,0 +1,127 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_SendReminders(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path + "?" + r.URL.Query().Get("page") {
+		case "/v2/1010/domains?1":
+			fmt.Fprint(w, `{"data": [
+				{"id": 1, "name": "example.com", "auto_renew": false, "expires_at": "2021-04-30T10:00:00Z"},
+				{"id": 2, "name": "example.org", "auto_renew": true, "expires_at": "2021-04-30T10:00:00Z"},
+				{"id": 3, "name": "example.net", "auto_renew": false, "expires_at": "2021-04-29T10:00:00Z"}
+			], "pagination": {"current_page": 1, "per_page": 3, "total_entries": 4, "total_pages": 2}}`)
+		case "/v2/1010/domains?2":
+			fmt.Fprint(w, `{"data": [
+				{"id": 4, "name": "example.io", "auto_renew": false, "expires_at": "2021-03-02T23:00:00Z"}
+			], "pagination": {"current_page": 2, "per_page": 3, "total_entries": 4, "total_pages": 2}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"api": {"token": "api-token", "url": %q},
+		"reminders": {"destinations": ["ops"], "accounts": [1010]}
+	}`, api.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	service := &failingService{}
+	server.routing.services["ops"] = service
+
+	server.sendReminders(context.Background(), time.Date(2021, 3, 1, 2, 0, 0, 0, time.UTC))
+
+	want := []string{
+		"[<https://dnsimple.com/a/1010/account|1010>] The domain <https://dnsimple.com/a/1010/domains/example.com|example.com> expires in 60 days, on 2021-04-30, and auto-renewal is disabled",
+		"[<https://dnsimple.com/a/1010/account|1010>] The domain <https://dnsimple.com/a/1010/domains/example.io|example.io> expires tomorrow, on 2021-03-02, and auto-renewal is disabled",
+	}
+	if got := service.messages; strings.Join(want, "\n") != strings.Join(got, "\n") {
+		t.Errorf("sendReminders expected\n%v\ngot\n%v", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestRemindersConfig_validate(t *testing.T) {
+	destinations := map[string]bool{"ops": true}
+	api := &APIConfig{Token: "api-token"}
+	tests := []struct {
+		config *RemindersConfig
+		api    *APIConfig
+		valid  bool
+	}{
+		{nil, nil, true},
+		{&RemindersConfig{Destinations: []string{"ops"}, Days: []int{14, 1}, Schedule: "0 9 * * 1-5", Timezone: "Europe/Rome"}, api, true},
+		{&RemindersConfig{Destinations: []string{"ops"}}, nil, false},
+		{&RemindersConfig{}, api, false},
+		{&RemindersConfig{Destinations: []string{"pager"}}, api, false},
+		{&RemindersConfig{Destinations: []string{"ops"}, Days: []int{0}}, api, false},
+		{&RemindersConfig{Destinations: []string{"ops"}, Schedule: "nightly"}, api, false},
+	}
+	for _, test := range tests {
+		err := test.config.validate(destinations, test.api)
+		if test.valid && err != nil {
+			t.Errorf("validate(%+v) returned error: %v", test.config, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("validate(%+v) expected error", test.config)
+		}
+	}
+}
+
+func Test_daysUntil(t *testing.T) {
+	now := time.Date(2021, 3, 1, 23, 30, 0, 0, time.UTC)
+	if want, got := 1, daysUntil(now, time.Date(2021, 3, 2, 0, 10, 0, 0, time.UTC)); want != got {
+		t.Errorf("daysUntil expected %v, got %v", want, got)
+	}
+	if want, got := 0, daysUntil(now, time.Date(2021, 3, 1, 23, 50, 0, 0, time.UTC)); want != got {
+		t.Errorf("daysUntil expected %v, got %v", want, got)
+	}
+}
+
+func TestServer_SendRemindersFormatting(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": [
+			{"id": 1, "name": "example.com", "auto_renew": false, "expires_at": "2021-03-02T10:00:00Z"}
+		], "pagination": {"current_page": 1, "per_page": 30, "total_entries": 1, "total_pages": 1}}`)
+	}))
+	defer api.Close()
+
+	var got string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload slackPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		got = payload.Text
+	}))
+	defer target.Close()
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": %q, "language": "fr"}],
+		"dashboard_url": "https://dnsimple.test",
+		"accounts": {"1010": "Production"},
+		"api": {"token": "api-token", "url": %q},
+		"reminders": {"destinations": ["ops"], "accounts": [1010]}
+	}`, target.URL, api.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	// The messages are formatted by the destination, behind its retries.
+	server.sendReminders(context.Background(), time.Date(2021, 3, 1, 2, 0, 0, 0, time.UTC))
+	want := "[<https://dnsimple.test/a/1010/account|Production>] Le domaine <https://dnsimple.test/a/1010/domains/example.com|example.com> expire demain, le 2021-03-02, et le renouvellement automatique est désactivé"
+	if want != got {
+		t.Errorf("sendReminders expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,103 @@
+package strillone
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Formatter formats the links of the messages in the markup of a messaging service.
+type Formatter interface {
+	FormatLink(name, url string) string
+}
+
+// Renderer renders the messages in a markup: Slack mrkdwn, CommonMark, plain text or HTML.
+// The messages are the same for every markup, only the links and the escaping differ.
+type Renderer interface {
+	Formatter
+
+	// Escape escapes the text, such as a domain or an email, so that it is rendered as is.
+	Escape(text string) string
+}
+
+// The markups of the renderers.
+const (
+	FormatMrkdwn   = "mrkdwn"
+	FormatMarkdown = "markdown"
+	FormatText     = "text"
+	FormatHTML     = "html"
+)
+
+// NewRenderer returns the renderer of the markup: mrkdwn, markdown, text or html.
+func NewRenderer(format string) (Renderer, error) {
+	switch format {
+	case FormatMrkdwn:
+		return mrkdwnRenderer{}, nil
+	case FormatMarkdown:
+		return markdownRenderer{}, nil
+	case FormatText:
+		return textRenderer{}, nil
+	case FormatHTML:
+		return htmlRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// escape escapes the text for the formatter, when it is a renderer.
+func escape(s Formatter, text string) string {
+	if renderer, ok := s.(Renderer); ok {
+		return renderer.Escape(text)
+	}
+	return text
+}
+
+// mrkdwnRenderer renders the Slack mrkdwn, such as <https://dnsimple.com|DNSimple>.
+type mrkdwnRenderer struct{}
+
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (mrkdwnRenderer) FormatLink(name, url string) string {
+	return fmt.Sprintf("<%s|%s>", url, mrkdwnEscaper.Replace(name))
+}
+
+func (mrkdwnRenderer) Escape(text string) string {
+	return mrkdwnEscaper.Replace(text)
+}
+
+// markdownRenderer renders CommonMark, such as [DNSimple](https://dnsimple.com).
+type markdownRenderer struct{}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+)
+
+func (markdownRenderer) FormatLink(name, url string) string {
+	return fmt.Sprintf("[%s](<%s>)", markdownEscaper.Replace(name), url)
+}
+
+func (markdownRenderer) Escape(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// textRenderer renders plain text: the links are their name.
+type textRenderer struct{}
+
+func (textRenderer) FormatLink(name, _ string) string {
+	return name
+}
+
+func (textRenderer) Escape(text string) string {
+	return text
+}
+
+// htmlRenderer renders HTML, such as <a href="https://dnsimple.com">DNSimple</a>.
+type htmlRenderer struct{}
+
+func (htmlRenderer) FormatLink(name, url string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(name))
+}
+
+func (htmlRenderer) Escape(text string) string {
+	return html.EscapeString(text)
+}
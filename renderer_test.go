@@ -0,0 +1,55 @@
+package strillone
+
+import (
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_Message_Renderers(t *testing.T) {
+	event, err := webhook.ParseEvent([]byte(`{"data": {"account_invitation": {"email": "<jane>@example.com"}, "account": {"id": 1010}}, "actor": {"pretty": "a&b@example.com"}, "account": {"id": 1010, "display": "R&D"}, "name": "account.user_invite"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatMrkdwn, "a&amp;b@example.com invited &lt;jane&gt;@example.com to account <https://dnsimple.com/a/1010/account/members|1010>"},
+		{FormatMarkdown, `a&b@example.com invited \<jane\>@example.com to account [1010](<https://dnsimple.com/a/1010/account/members>)`},
+		{FormatText, "a&b@example.com invited <jane>@example.com to account 1010"},
+		{FormatHTML, `a&amp;b@example.com invited &lt;jane&gt;@example.com to account <a href="https://dnsimple.com/a/1010/account/members">1010</a>`},
+	}
+	for _, test := range tests {
+		renderer, err := NewRenderer(test.format)
+		if err != nil {
+			t.Fatalf("NewRenderer(%v) returned error: %v", test.format, err)
+		}
+		if got := Message(renderer, event); test.want != got {
+			t.Errorf("Message(%v) expected %q, got %q", test.format, test.want, got)
+		}
+	}
+}
+
+func TestRenderer_FormatLink(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatMrkdwn, "<https://dnsimple.com/a/1010/account|R&amp;D>"},
+		{FormatMarkdown, "[R&D](<https://dnsimple.com/a/1010/account>)"},
+		{FormatText, "R&D"},
+		{FormatHTML, `<a href="https://dnsimple.com/a/1010/account">R&amp;D</a>`},
+	}
+	for _, test := range tests {
+		renderer, _ := NewRenderer(test.format)
+		if got := renderer.FormatLink("R&D", "https://dnsimple.com/a/1010/account"); test.want != got {
+			t.Errorf("FormatLink(%v) expected %q, got %q", test.format, test.want, got)
+		}
+	}
+
+	if _, err := NewRenderer("rtf"); err == nil {
+		t.Errorf("NewRenderer with an unknown format expected error")
+	}
+}
@@ -0,0 +1,117 @@
+package strillone
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultTimestampHeader = "X-DNSimple-Timestamp"
+
+var (
+	errTimestampMissing = errors.New("missing webhook timestamp")
+	errTimestampInvalid = errors.New("invalid webhook timestamp")
+	errTimestampStale   = errors.New("webhook timestamp outside of the replay window")
+)
+
+// verifyTimestamp checks that the webhook timestamp, in seconds since the epoch
+// or as an ISO8601 timestamp, is within the replay window, when replay protection is enabled.
+func (t *routingTable) verifyTimestamp(r *http.Request, now time.Time) error {
+	if t.replayWindow <= 0 {
+		return nil
+	}
+
+	value := r.Header.Get(t.timestampHeader)
+	if value == "" {
+		return errTimestampMissing
+	}
+	timestamp, ok := parseHeaderTimestamp(value)
+	if !ok {
+		return errTimestampInvalid
+	}
+
+	age := now.Sub(timestamp)
+	if age > t.replayWindow || age < -t.replayWindow {
+		return errTimestampStale
+	}
+	return nil
+}
+
+// keyGuard tracks the keys seen in a window.
+type keyGuard interface {
+	// claim records the key, and returns false if the key was already seen in the window.
+	claim(key string, window time.Duration) bool
+
+	// release forgets the key.
+	release(key string)
+}
+
+// replayGuard tracks the keys seen in a window: the webhook request identifiers
+// in the replay window, or the event contents in the deduplication window.
+//
+// An identifier is claimed when the webhook is received, so that concurrent
+// copies of the same webhook are rejected, and released if the delivery fails,
+// so that the sender can retry.
+type replayGuard struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+
+	now func() time.Time
+}
+
+func newReplayGuard() *replayGuard {
+	return &replayGuard{seen: map[string]time.Time{}, now: time.Now}
+}
+
+// claim records the key, and returns false if the key was already seen in the window.
+func (g *replayGuard) claim(key string, window time.Duration) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	for k, seenAt := range g.seen {
+		if now.Sub(seenAt) > window {
+			delete(g.seen, k)
+		}
+	}
+
+	if _, ok := g.seen[key]; ok {
+		return false
+	}
+	g.seen[key] = now
+	return true
+}
+
+// release forgets the key.
+func (g *replayGuard) release(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.seen, key)
+}
+
+// redisGuard is a keyGuard in Redis, shared by the instances. The keys are claimed with SET NX,
+// and expire at the end of the window. When Redis can't be reached, the keys are claimed,
+// so that the events are delivered rather than lost.
+type redisGuard struct {
+	client *redisClient
+	prefix string
+}
+
+func (g *redisGuard) claim(key string, window time.Duration) bool {
+	reply, err := g.client.do("SET", g.prefix+key, "1", "NX", "PX", strconv.FormatInt(window.Milliseconds(), 10))
+	if err != nil {
+		log.Warn().Err(err).Msg("Error claiming the webhook in Redis")
+		return true
+	}
+	return reply != nil
+}
+
+func (g *redisGuard) release(key string) {
+	if _, err := g.client.do("DEL", g.prefix+key); err != nil {
+		log.Warn().Err(err).Msg("Error releasing the webhook in Redis")
+	}
+}
@@ -0,0 +1,85 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wunderlist/ttlcache"
+)
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	guard := newReplayGuard()
+	guard.now = func() time.Time { return now }
+
+	if !guard.claim("a", time.Minute) {
+		t.Errorf("claim(a) expected true")
+	}
+	if guard.claim("a", time.Minute) {
+		t.Errorf("claim(a) again expected false")
+	}
+
+	guard.release("a")
+	if !guard.claim("a", time.Minute) {
+		t.Errorf("claim(a) after release expected true")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if !guard.claim("a", time.Minute) {
+		t.Errorf("claim(a) after the window expected true")
+	}
+}
+
+func TestEvents_ReplayProtection(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}],
+		"inbound": {"signing_secret": "shared-secret", "replay_window": "5m"}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.routing.services["ops"] = &SlackService{Token: "-"}
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+	post := func(requestID string, timestamp time.Time, signedTimestamp time.Time) int {
+		body := fmt.Sprintf(payload, requestID)
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(body))
+		request.Header.Set("X-DNSimple-Timestamp", strconv.FormatInt(timestamp.Unix(), 10))
+		request.Header.Set("X-DNSimple-Signature", SignPayload("shared-secret", []byte(strconv.FormatInt(signedTimestamp.Unix(), 10)+"."+body)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	now := time.Now()
+	if want, got := http.StatusOK, post("9d2e7b1a-replay-0000-000000000001", now, now); want != got {
+		t.Errorf("POST /events expected HTTP %v, got %v", want, got)
+	}
+
+	old := now.Add(-10 * time.Minute)
+	if want, got := http.StatusUnauthorized, post("9d2e7b1a-replay-0000-000000000002", old, old); want != got {
+		t.Errorf("POST /events with a stale timestamp expected HTTP %v, got %v", want, got)
+	}
+	if want, got := http.StatusUnauthorized, post("9d2e7b1a-replay-0000-000000000002", now, old); want != got {
+		t.Errorf("POST /events with a forged timestamp expected HTTP %v, got %v", want, got)
+	}
+
+	// the delivered webhooks are cached for 5 minutes, drop the cache to test the replay guard
+	server.webhookCache = ttlcache.NewCache(cacheTTL * time.Second)
+	if want, got := http.StatusConflict, post("9d2e7b1a-replay-0000-000000000001", now, now); want != got {
+		t.Errorf("POST /events replayed expected HTTP %v, got %v", want, got)
+	}
+}
+
+func TestParseConfig_InvalidReplayWindow(t *testing.T) {
+	if _, err := ParseConfig([]byte(`{"inbound": {"replay_window": "five minutes"}}`)); err == nil {
+		t.Errorf("ParseConfig expected error for invalid replay window")
+	}
+}
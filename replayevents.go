@@ -0,0 +1,195 @@
+package strillone
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// ReplayOptions represents how events are replayed.
+type ReplayOptions struct {
+	// Tenant is the name of the tenant whose routes and destinations are used, empty for the top-level ones.
+	Tenant string
+
+	// Destinations overrides the destinations matched by the routes.
+	Destinations []string
+
+	// DryRun formats the messages without delivering them.
+	DryRun bool
+}
+
+// ReplayResult represents the outcome of the replay of an event.
+type ReplayResult struct {
+	Event     string
+	RequestID string
+
+	// Messages are the formatted messages, by destination.
+	Messages map[string]string
+
+	// Errors are the delivery errors, by destination.
+	Errors map[string]error
+}
+
+// Replay runs the payload through the formatting and delivery pipeline again,
+// bypassing the verification and the deduplication of the received webhooks.
+// When the event is in the history, the delivery attempts are added to it.
+func (s *Server) Replay(ctx context.Context, payload []byte, options *ReplayOptions) (*ReplayResult, error) {
+	routing := s.currentRouting().tenantByName(options.Tenant)
+	if routing == nil {
+		return nil, fmt.Errorf("unknown tenant %q", options.Tenant)
+	}
+	event, err := webhook.ParseEvent(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	names := options.Destinations
+	if len(names) == 0 {
+		names = routing.lookupOwners(event, routing.lookup(event.Name, excludeTags(s.eventTags(ctx, routing, event), excludeActor(event.Actor, nil))))
+		if security := routing.securityDestination(event, detailsOf(event)); security != "" && !containsString(names, security) {
+			names = append(names, security)
+		}
+	}
+	for _, name := range names {
+		if _, ok := routing.services[name]; !ok {
+			return nil, fmt.Errorf("unknown destination %q", name)
+		}
+	}
+
+	result := &ReplayResult{
+		Event:     event.Name,
+		RequestID: event.RequestID,
+		Messages:  map[string]string{},
+		Errors:    map[string]error{},
+	}
+	if !options.DryRun {
+		withEvent(log.Info(), event).Str("tenant", routing.name).Strs("destinations", names).Msg("Replaying event")
+	}
+	stats := s.stats.Tenant(routing.name)
+	for _, name := range names {
+		if options.DryRun {
+			text, err := formatMessage(routing.services[name], event)
+			if err != nil {
+				result.Errors[name] = err
+				continue
+			}
+			result.Messages[name] = text
+			continue
+		}
+
+		text, err := s.deliver(ctx, routing, name, event)
+		if err != nil {
+			stats.failed()
+			result.Errors[name] = err
+			continue
+		}
+		stats.delivered()
+		result.Messages[name] = text
+	}
+	return result, nil
+}
+
+// formatMessage formats the event for the service, with the template of the destination,
+// failing on the template errors and on the payloads missing the data of their event.
+func formatMessage(service Formatter, event *webhook.Event) (text string, err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("unsupported payload for %s", event.Name)
+		}
+	}()
+	if messaging, ok := service.(MessagingService); ok {
+		service = unwrapService(messaging)
+	}
+	if destination, ok := service.(Destination); ok {
+		return destination.Format(event)
+	}
+	return Message(service, event), nil
+}
+
+// ReadPayloads reads the event payloads in the file, or in the files of the directory sorted by name.
+//
+// The files are single JSON payloads, or NDJSON files, optionally gzipped, such as the archive objects.
+// The lines of the archive objects are unwrapped to their payload.
+func ReadPayloads(path string) ([]json.RawMessage, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return readPayloadFile(path)
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	var payloads []json.RawMessage
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		found, err := readPayloadFile(filepath.Join(path, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, found...)
+	}
+	return payloads, nil
+}
+
+func readPayloadFile(filename string) ([]json.RawMessage, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(filename, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var payloads []json.RawMessage
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var payload json.RawMessage
+		err := decoder.Decode(&payload)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		payloads = append(payloads, unwrapArchiveLine(payload))
+	}
+	log.Debug().Str("file", filename).Int("events", len(payloads)).Msg("Payloads read")
+	return payloads, nil
+}
+
+// unwrapArchiveLine returns the payload of an archive line, or the payload itself.
+func unwrapArchiveLine(payload json.RawMessage) json.RawMessage {
+	var line archiveLine
+	if err := json.Unmarshal(payload, &line); err == nil && len(bytes.TrimSpace(line.Payload)) > 0 && !line.ReceivedAt.IsZero() {
+		return line.Payload
+	}
+	return payload
+}
@@ -0,0 +1,92 @@
+package strillone
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServer_Replay(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "dev", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops, dev := &failingService{}, &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["dev"] = dev
+
+	payload := []byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "5c9a2e7f-replay-1"}`)
+
+	result, err := server.Replay(context.Background(), payload, &ReplayOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Replay returned error: %v", err)
+	}
+	if want, got := "created the domain", result.Messages["ops"]; !strings.Contains(got, want) {
+		t.Errorf("Replay expected message containing %q, got %v", want, got)
+	}
+	if want, got := 0, ops.sent; want != got {
+		t.Errorf("dry-run expected %v deliveries, got %v", want, got)
+	}
+
+	if _, err := server.Replay(context.Background(), payload, &ReplayOptions{Destinations: []string{"dev"}}); err != nil {
+		t.Fatalf("Replay returned error: %v", err)
+	}
+	if want, got := 0, ops.sent; want != got {
+		t.Errorf("ops expected %v deliveries, got %v", want, got)
+	}
+	if want, got := 1, dev.sent; want != got {
+		t.Errorf("dev expected %v deliveries, got %v", want, got)
+	}
+
+	if _, err := server.Replay(context.Background(), payload, &ReplayOptions{Destinations: []string{"unknown"}}); err == nil {
+		t.Errorf("Replay to an unknown destination expected error")
+	}
+	if _, err := server.Replay(context.Background(), payload, &ReplayOptions{Tenant: "unknown"}); err == nil {
+		t.Errorf("Replay for an unknown tenant expected error")
+	}
+}
+
+func TestReadPayloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "1.ndjson.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte(`{"tenant": "ops", "received_at": "2021-03-01T12:00:00Z", "payload": {"request_identifier": "1"}}` + "\n"))
+	zw.Write([]byte(`{"request_identifier": "2"}` + "\n"))
+	zw.Close()
+	f.Close()
+	if err := ioutil.WriteFile(filepath.Join(dir, "2.json"), []byte("{\n  \"request_identifier\": \"3\"\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads, err := ReadPayloads(dir)
+	if err != nil {
+		t.Fatalf("ReadPayloads returned error: %v", err)
+	}
+	var found []string
+	for _, payload := range payloads {
+		found = append(found, strings.Join(strings.Fields(string(payload)), ""))
+	}
+	expected := []string{`{"request_identifier":"1"}`, `{"request_identifier":"2"}`, `{"request_identifier":"3"}`}
+	if want, got := strings.Join(expected, " "), strings.Join(found, " "); want != got {
+		t.Errorf("ReadPayloads expected %v, got %v", want, got)
+	}
+}
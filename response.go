@@ -0,0 +1,82 @@
+package strillone
+
+// The outcomes of the webhooks and of their deliveries, in the responses.
+const (
+	webhookDelivered    = "delivered"
+	webhookFailed       = "failed"
+	webhookSkipped      = "skipped"
+	webhookRejected     = "rejected"
+	webhookDigested     = "digested"
+	webhookNotAttempted = "not_attempted"
+)
+
+// WebhookStatus is the response to a webhook, describing what Strillone did with it,
+// so that the webhook delivery log of DNSimple is useful to debug the deliveries.
+type WebhookStatus struct {
+	// Status is the outcome of the webhook: delivered, failed, accepted or queued (delivered in the background),
+	// digested, skipped or rejected.
+	Status string `json:"status"`
+
+	// Reason explains the skipped, the rejected and the failed webhooks, such as no-route.
+	Reason string `json:"reason,omitempty"`
+
+	// Event is the event of the webhook, unless it couldn't be parsed.
+	Event *WebhookEventStatus `json:"event,omitempty"`
+
+	// Destinations are the outcomes of the deliveries, by destination matched by the routes.
+	Destinations []DestinationStatus `json:"destinations,omitempty"`
+
+	// Digests are the destinations whose digests include the event.
+	Digests []string `json:"digests,omitempty"`
+}
+
+// WebhookEventStatus describes the event of a webhook.
+type WebhookEventStatus struct {
+	Name      string `json:"name"`
+	RequestID string `json:"request_id"`
+
+	// Recognized is false for the events unknown to Strillone, formatted as generic messages.
+	Recognized bool `json:"recognized"`
+}
+
+// DestinationStatus is the outcome of the delivery of an event to a destination:
+// delivered, failed, accepted, queued or not_attempted after a failed delivery.
+type DestinationStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+
+	// Message is the delivered message.
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// respond writes the status of the webhook, completed with its event and its digests.
+func (p *Webhook) respond(code int, status *WebhookStatus) {
+	if p.Event != nil {
+		status.Event = &WebhookEventStatus{Name: p.Event.Name, RequestID: p.Event.RequestID, Recognized: knownEvents[p.Event.Name]}
+	}
+	for _, digest := range p.digests {
+		status.Digests = append(status.Digests, digest.destination)
+	}
+	writeJSON(p.Response, code, status)
+}
+
+// reject stops the processing of the webhook with the error status code, and the reason.
+func (p *Webhook) reject(code int, reason string) {
+	p.respond(code, &WebhookStatus{Status: webhookRejected, Reason: reason})
+}
+
+// respondDestinations writes the status of the webhook, with the same status for every destination.
+func (p *Webhook) respondDestinations(code int, status string) {
+	response := &WebhookStatus{Status: status}
+	for _, name := range p.Destinations {
+		response.Destinations = append(response.Destinations, DestinationStatus{Name: name, Status: status})
+	}
+	p.Response.Header().Set(headerProcessingStatus, status)
+	p.respond(code, response)
+}
+
+// fail writes the status of a webhook that couldn't be published, so that DNSimple sends it again.
+func (p *Webhook) fail(code int, err error) {
+	p.respond(code, &WebhookStatus{Status: webhookFailed, Reason: err.Error()})
+}
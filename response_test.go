@@ -0,0 +1,80 @@
+package strillone
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvents_ResponseStatus(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A", "retry": {"max_attempts": 1}},
+			{"name": "alerts", "type": "slack", "url": "https://hooks.slack.com/services/B", "retry": {"max_attempts": 1}},
+			{"name": "audit", "type": "slack", "url": "https://hooks.slack.com/services/C", "retry": {"max_attempts": 1}}
+		],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops", "alerts", "audit"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.routing.services["ops"] = &failingService{}
+	server.routing.services["alerts"] = &failingService{errs: []error{errors.New("unavailable")}}
+	server.routing.services["audit"] = &failingService{}
+
+	tests := []struct {
+		payload string
+		code    int
+		want    *WebhookStatus
+	}{
+		{
+			`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.delete", "request_identifier": "c3e6a9b2-response-0000-000000000001"}`,
+			http.StatusInternalServerError,
+			&WebhookStatus{
+				Status: "failed",
+				Reason: "delivery to alerts failed",
+				Event:  &WebhookEventStatus{Name: "domain.delete", RequestID: "c3e6a9b2-response-0000-000000000001", Recognized: true},
+				Destinations: []DestinationStatus{
+					{Name: "ops", Status: "delivered"},
+					{Name: "alerts", Status: "failed", Error: "unavailable"},
+					{Name: "audit", Status: "not_attempted"},
+				},
+			},
+		},
+		{
+			`{"data": {"contact": {"id": 1}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "contact.create", "request_identifier": "c3e6a9b2-response-0000-000000000002"}`,
+			http.StatusOK,
+			&WebhookStatus{
+				Status: "skipped",
+				Reason: "no-route",
+				Event:  &WebhookEventStatus{Name: "contact.create", RequestID: "c3e6a9b2-response-0000-000000000002", Recognized: true},
+			},
+		},
+		{
+			`{`,
+			http.StatusBadRequest,
+			&WebhookStatus{Status: "rejected", Reason: "unexpected end of JSON input"},
+		},
+	}
+	for _, tt := range tests {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(tt.payload))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want, got := tt.code, response.Code; want != got {
+			t.Errorf("POST /events expected HTTP %v, got %v", want, got)
+		}
+		var got WebhookStatus
+		if err := json.Unmarshal(response.Body.Bytes(), &got); err != nil {
+			t.Fatalf("POST /events returned invalid JSON: %v", err)
+		}
+		if !reflect.DeepEqual(tt.want, &got) {
+			t.Errorf("POST /events expected response\n\t%+v\ngot\n\t%+v", tt.want, &got)
+		}
+	}
+}
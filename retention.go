@@ -0,0 +1,178 @@
+package strillone
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// historyPruneBatch is the number of events removed, and archived, at a time.
+const historyPruneBatch = 1000
+
+// HistoryRetention removes the events of the history received before MaxAge, or beyond the MaxEvents newest.
+type HistoryRetention struct {
+	// MaxAge is how long the events are kept, forever when 0.
+	MaxAge time.Duration
+
+	// MaxEvents is the number of events kept, unlimited when 0.
+	MaxEvents int
+
+	// Archive stores the events before their removal, in archive objects whose keys start with ArchivePrefix. Optional.
+	Archive       ArchiveStore
+	ArchivePrefix string
+}
+
+// HistoryPruner is implemented by the histories that can remove their oldest events.
+type HistoryPruner interface {
+	// Expired returns, oldest first, up to limit events received before the time or beyond the max newest events.
+	// Either bound is disabled when zero.
+	Expired(before time.Time, max int, limit int) ([]*HistoryEvent, error)
+
+	// Remove removes events returned by Expired.
+	Remove(events []*HistoryEvent) error
+}
+
+// Expired implements HistoryPruner
+func (h *BoltHistory) Expired(before time.Time, max int, limit int) ([]*HistoryEvent, error) {
+	events := []*HistoryEvent{}
+	err := h.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltHistoryEventsBucket)
+		excess := 0
+		if max > 0 {
+			excess = bucket.Stats().KeyN - max
+		}
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil && len(events) < limit; k, v = cursor.Next() {
+			event := &HistoryEvent{}
+			if err := json.Unmarshal(v, event); err != nil {
+				return err
+			}
+			if len(events) >= excess && (before.IsZero() || !event.ReceivedAt.Before(before)) {
+				break
+			}
+			event.key = append([]byte{}, k...)
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// Remove implements HistoryPruner
+func (h *BoltHistory) Remove(events []*HistoryEvent) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		bucket, index := tx.Bucket(boltHistoryEventsBucket), tx.Bucket(boltHistoryIndexBucket)
+		for _, event := range events {
+			if event.key == nil {
+				continue
+			}
+			if err := bucket.Delete(event.key); err != nil {
+				return err
+			}
+			// the index refers to the newest event with the request identifier
+			indexKey := historyIndexKey(event.Tenant, event.RequestID)
+			if bytes.Equal(index.Get(indexKey), event.key) {
+				if err := index.Delete(indexKey); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// SetHistoryRetention removes the events of the history beyond the retention, with ProcessHistoryRetention.
+func (s *Server) SetHistoryRetention(retention *HistoryRetention) {
+	s.historyRetention = retention
+}
+
+// ProcessHistoryRetention removes the events beyond the retention every interval, until done is closed
+// or the server shuts down.
+func (s *Server) ProcessHistoryRetention(interval time.Duration, done <-chan struct{}) {
+	s.workers.Add(1)
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.pruneHistory(context.Background(), now)
+		}
+	}
+}
+
+// pruneHistory removes the events beyond the retention at now, archiving them first when enabled.
+// The events whose archive upload fails are kept, and pruned the next time.
+func (s *Server) pruneHistory(ctx context.Context, now time.Time) error {
+	retention := s.historyRetention
+	pruner, ok := s.history.(HistoryPruner)
+	if retention == nil || !ok || (retention.MaxAge <= 0 && retention.MaxEvents <= 0) {
+		return nil
+	}
+	var before time.Time
+	if retention.MaxAge > 0 {
+		before = now.Add(-retention.MaxAge)
+	}
+
+	removed := 0
+	for {
+		events, err := pruner.Expired(before, retention.MaxEvents, historyPruneBatch)
+		if err != nil {
+			log.Error().Err(err).Msg("Error pruning the history")
+			return err
+		}
+		if len(events) == 0 {
+			break
+		}
+		if retention.Archive != nil {
+			if err := archiveHistory(ctx, retention, events); err != nil {
+				log.Error().Err(err).Int("events", len(events)).Msg("Error archiving the history, pruning postponed")
+				return err
+			}
+		}
+		if err := pruner.Remove(events); err != nil {
+			log.Error().Err(err).Msg("Error pruning the history")
+			return err
+		}
+		removed += len(events)
+	}
+	if removed > 0 {
+		log.Info().Int("events", removed).Msg("History pruned")
+	}
+	return nil
+}
+
+// archiveHistory uploads the events in an archive object named after the oldest one,
+// in the format of the archive of the received events.
+func archiveHistory(ctx context.Context, retention *HistoryRetention, events []*HistoryEvent) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	for _, event := range events {
+		line, err := json.Marshal(&archiveLine{Tenant: event.Tenant, ReceivedAt: event.ReceivedAt, Payload: event.Payload})
+		if err != nil {
+			return err
+		}
+		zw.Write(line)
+		zw.Write([]byte("\n"))
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	key := archiveKey(retention.ArchivePrefix, events[0].ReceivedAt)
+	if err := retention.Archive.Put(ctx, key, body.Bytes()); err != nil {
+		return err
+	}
+	log.Info().Str("key", key).Int("events", len(events)).Msg("History archived")
+	return nil
+}
@@ -0,0 +1,92 @@
+package strillone
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_pruneHistory(t *testing.T) {
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= 5; i++ {
+		event := &HistoryEvent{
+			Name:       "domain.create",
+			RequestID:  fmt.Sprint(i),
+			ReceivedAt: now.Add(time.Duration(i-5) * 24 * time.Hour),
+			Payload:    json.RawMessage(fmt.Sprintf(`{"name": "domain.create", "request_identifier": "%d"}`, i)),
+		}
+		if err := history.Record(event); err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+	}
+	remaining := func() string {
+		events, _ := history.Query(&HistoryFilter{})
+		var ids []string
+		for _, event := range events {
+			ids = append(ids, event.RequestID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	server := NewServer(nil)
+	server.SetHistory(history)
+	store := &memoryArchiveStore{objects: map[string][]byte{}, err: errors.New("unavailable")}
+	server.SetHistoryRetention(&HistoryRetention{MaxAge: 36 * time.Hour, Archive: store, ArchivePrefix: "history"})
+
+	// the events are kept when they can't be archived
+	if err := server.pruneHistory(context.Background(), now); err == nil {
+		t.Errorf("pruneHistory expected error")
+	}
+	if want, got := "5,4,3,2,1", remaining(); want != got {
+		t.Errorf("pruneHistory expected events %v, got %v", want, got)
+	}
+
+	store.err = nil
+	if err := server.pruneHistory(context.Background(), now); err != nil {
+		t.Fatalf("pruneHistory returned error: %v", err)
+	}
+	if want, got := "5,4", remaining(); want != got {
+		t.Errorf("pruneHistory expected events %v, got %v", want, got)
+	}
+	if _, err := history.Get("", "1"); err != ErrEventNotFound {
+		t.Errorf("Get of a pruned event expected %v, got %v", ErrEventNotFound, err)
+	}
+	body, ok := store.objects["history/2021/03/06/20210306T120000.000000000Z.ndjson.gz"]
+	if !ok {
+		t.Fatalf("pruneHistory expected an archive object, got %v", store.objects)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("archive object is not gzipped: %v", err)
+	}
+	data, _ := ioutil.ReadAll(zr)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if want, got := 3, len(lines); want != got {
+		t.Fatalf("archive object expected %v events, got %v", want, got)
+	}
+	line := &archiveLine{}
+	if err := json.Unmarshal([]byte(lines[0]), line); err != nil {
+		t.Fatalf("archive object has invalid line: %v", err)
+	}
+	if want, got := `{"name":"domain.create","request_identifier":"1"}`, string(line.Payload); want != got {
+		t.Errorf("archive object expected payload %v, got %v", want, got)
+	}
+
+	server.SetHistoryRetention(&HistoryRetention{MaxEvents: 1})
+	if err := server.pruneHistory(context.Background(), now); err != nil {
+		t.Fatalf("pruneHistory returned error: %v", err)
+	}
+	if want, got := "5", remaining(); want != got {
+		t.Errorf("pruneHistory expected events %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,204 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMaxBackoff     = 30 * time.Second
+)
+
+// RetryConfig represents how the deliveries to a destination are retried
+// when the destination fails with a 5xx, a 429, or a network error.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts, including the first one. Defaults to 3,
+	// set it to 1 to disable the retries.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+
+	// InitialBackoff is the wait before the first retry, doubled at each retry. Defaults to 1s.
+	InitialBackoff Duration `json:"initial_backoff,omitempty"`
+
+	// MaxBackoff is the maximum wait between two attempts. Defaults to 30s.
+	// A Retry-After longer than MaxBackoff stops the retries.
+	MaxBackoff Duration `json:"max_backoff,omitempty"`
+}
+
+func (c *RetryConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxAttempts < 0 || c.InitialBackoff < 0 || c.MaxBackoff < 0 {
+		return errors.New("retry: values must be positive")
+	}
+	return nil
+}
+
+// retryingService is a MessagingService that retries the failed deliveries
+// with exponential backoff and jitter.
+type retryingService struct {
+	MessagingService
+
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// withRetries wraps the service to retry the failed deliveries.
+// A nil config uses the default values.
+func withRetries(service MessagingService, config *RetryConfig) MessagingService {
+	r := &retryingService{
+		MessagingService: service,
+		maxAttempts:      defaultRetryMaxAttempts,
+		initialBackoff:   defaultRetryInitialBackoff,
+		maxBackoff:       defaultRetryMaxBackoff,
+		sleep:            sleepContext,
+	}
+	if config != nil {
+		if config.MaxAttempts > 0 {
+			r.maxAttempts = config.MaxAttempts
+		}
+		if config.InitialBackoff > 0 {
+			r.initialBackoff = time.Duration(config.InitialBackoff)
+		}
+		if config.MaxBackoff > 0 {
+			r.maxBackoff = time.Duration(config.MaxBackoff)
+		}
+	}
+	return r
+}
+
+// unwrapService returns the service wrapped by the retries and the dry-run mode, if any,
+// so that the messages are formatted with the settings of the destination.
+func unwrapService(service MessagingService) MessagingService {
+	for {
+		switch wrapper := service.(type) {
+		case *retryingService:
+			service = wrapper.MessagingService
+		case *dryRunService:
+			service = wrapper.MessagingService
+		default:
+			return service
+		}
+	}
+}
+
+// PostEvent implements MessagingService
+func (r *retryingService) PostEvent(ctx context.Context, event *webhook.Event) (string, error) {
+	var text string
+	err := r.retry(ctx, eventRequestID(event), func() (err error) {
+		text, err = r.MessagingService.PostEvent(ctx, event)
+		return err
+	})
+	return text, err
+}
+
+// PostMessage implements MessagingService
+func (r *retryingService) PostMessage(ctx context.Context, text string) error {
+	return r.retry(ctx, "", func() error {
+		return r.MessagingService.PostMessage(ctx, text)
+	})
+}
+
+// retry calls post until it succeeds, fails with a permanent error, exhausts the attempts,
+// or the context is done.
+func (r *retryingService) retry(ctx context.Context, requestID string, post func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := post()
+		if err == nil || attempt >= r.maxAttempts || !isRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		wait := r.backoff(attempt)
+		if retryAfter := retryAfterOf(err); retryAfter > 0 {
+			if retryAfter > r.maxBackoff {
+				return fmt.Errorf("%v (retry after %v)", err, retryAfter)
+			}
+			wait = retryAfter
+		}
+
+		log.Warn().Err(err).Str("request_id", requestID).Dur("wait", wait).Int("attempt", attempt+1).Int("max_attempts", r.maxAttempts).Msg("Retrying delivery")
+		if canceled := r.sleep(ctx, wait); canceled != nil {
+			return fmt.Errorf("%v (retry canceled: %v)", err, canceled)
+		}
+	}
+}
+
+// sleepContext waits for the duration, or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns the wait before the retry following the attempt:
+// the exponential backoff, capped to the maximum, with a random jitter of up to half of it.
+func (r *retryingService) backoff(attempt int) time.Duration {
+	backoff := r.initialBackoff << uint(attempt-1)
+	if backoff > r.maxBackoff || backoff <= 0 {
+		backoff = r.maxBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// statusError is the error returned when a destination responds with a non-2xx status.
+type statusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	message    string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// isRetryable returns true if the delivery failed because of a temporary error.
+func isRetryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func retryAfterOf(err error) time.Duration {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter parses the value of the Retry-After header,
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
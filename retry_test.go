@@ -0,0 +1,93 @@
+package strillone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestRetryingService_PostEvent(t *testing.T) {
+	var requests int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer target.Close()
+
+	var waits []time.Duration
+	service := withRetries(&SlackService{URL: target.URL}, &RetryConfig{MaxAttempts: 3}).(*retryingService)
+	service.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	event, _ := webhook.ParseEvent([]byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "5e1b7a2c-retry-0000-000000000001"}`))
+	if _, err := service.PostEvent(context.Background(), event); err != nil {
+		t.Fatalf("PostEvent returned error: %v", err)
+	}
+
+	if want, got := 3, requests; want != got {
+		t.Errorf("PostEvent expected %v requests, got %v", want, got)
+	}
+	if len(waits) != 2 {
+		t.Fatalf("PostEvent expected 2 retries, got %v", len(waits))
+	}
+	if waits[0] < defaultRetryInitialBackoff/2 || waits[0] > defaultRetryInitialBackoff {
+		t.Errorf("PostEvent first backoff expected between %v and %v, got %v", defaultRetryInitialBackoff/2, defaultRetryInitialBackoff, waits[0])
+	}
+	if want, got := 7*time.Second, waits[1]; want != got {
+		t.Errorf("PostEvent expected to honor Retry-After %v, got %v", want, got)
+	}
+}
+
+func TestRetryingService_PostEventNotRetryable(t *testing.T) {
+	var requests int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer target.Close()
+
+	service := withRetries(&SlackService{URL: target.URL}, nil).(*retryingService)
+	service.sleep = func(context.Context, time.Duration) error { return nil }
+
+	event, _ := webhook.ParseEvent([]byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "5e1b7a2c-retry-0000-000000000002"}`))
+	if _, err := service.PostEvent(context.Background(), event); err == nil {
+		t.Errorf("PostEvent expected error")
+	}
+	if want, got := 1, requests; want != got {
+		t.Errorf("PostEvent expected %v requests, got %v", want, got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"Fri, 01 Jan 2021 00:00:30 GMT", 30 * time.Second},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); tt.want != got {
+			t.Errorf("parseRetryAfter(%q) expected %v, got %v", tt.value, tt.want, got)
+		}
+	}
+}
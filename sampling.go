@@ -0,0 +1,228 @@
+package strillone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultSamplingSummary is the period of the summaries of the suppressed events.
+const defaultSamplingSummary = time.Hour
+
+// SamplingConfig delivers 1 in Rate of the matching events, such as the zone_record.update events
+// of the API token of Terraform, and periodically summarizes the suppressed ones.
+type SamplingConfig struct {
+	// Name describes the sampled events in the summaries, optional.
+	Name string `json:"name,omitempty"`
+
+	// Events are the event name patterns matched by the sampling, using the path.Match syntax (e.g. "zone_record.*").
+	// An empty list matches every event.
+	Events []string `json:"events,omitempty"`
+
+	// Actors are the actor patterns matched by the sampling, using the path.Match syntax: the user,
+	// such as "jane@example.com", or the name of the API token, such as "terraform". An empty list matches every actor.
+	Actors []string `json:"actors,omitempty"`
+
+	// Rate delivers one event in Rate, starting with the first one.
+	Rate int `json:"rate"`
+
+	// Summary is the period of the summaries of the suppressed events. Defaults to 1h.
+	Summary Duration `json:"summary,omitempty"`
+}
+
+func (c *SamplingConfig) validate() error {
+	if len(c.Events) == 0 && len(c.Actors) == 0 {
+		return errors.New("sampling: missing events or actors")
+	}
+	for _, pattern := range append(append([]string{}, c.Events...), c.Actors...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("sampling: invalid pattern %q", pattern)
+		}
+	}
+	if c.Rate < 2 {
+		return fmt.Errorf("sampling: rate must be at least 2")
+	}
+	if c.Summary < 0 {
+		return fmt.Errorf("sampling: summary must be positive")
+	}
+	return nil
+}
+
+func (c *SamplingConfig) matches(event *webhook.Event) bool {
+	if len(c.Events) > 0 && !matchesAny(c.Events, event.Name) {
+		return false
+	}
+	return len(c.Actors) == 0 || matchActor(c.Actors, event.Actor)
+}
+
+func (c *SamplingConfig) summary() time.Duration {
+	if c.Summary == 0 {
+		return defaultSamplingSummary
+	}
+	return time.Duration(c.Summary)
+}
+
+// describe returns the description of the sampled events, such as "zone_record.update by terraform".
+func (c *SamplingConfig) describe() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	description := "events"
+	if len(c.Events) > 0 {
+		description = strings.Join(c.Events, ", ")
+	}
+	if len(c.Actors) > 0 {
+		description += " by " + strings.Join(c.Actors, ", ")
+	}
+	return description
+}
+
+// eventSample counts the events of a sampling, and the ones suppressed since the last summary.
+type eventSample struct {
+	tenant      string
+	description string
+	rate        int
+	seen        int
+
+	// suppressed are the events suppressed since the last summary, due at due, and destinations
+	// the destinations that would have received them.
+	suppressed   int
+	destinations []string
+	due          time.Time
+}
+
+// eventSamples are the samplings, by tenant and sampling.
+type eventSamples struct {
+	mu      sync.Mutex
+	samples map[string]*eventSample
+}
+
+func newEventSamples() *eventSamples {
+	return &eventSamples{samples: map[string]*eventSample{}}
+}
+
+// sample counts the event in the sampling, and returns false if it's suppressed,
+// remembering the destinations that would have received it for the summary.
+func (e *eventSamples) sample(tenant string, index int, config *SamplingConfig, destinations []string, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := tenant + "/" + strconv.Itoa(index)
+	sample, ok := e.samples[key]
+	if !ok || sample.rate != config.Rate {
+		sample = &eventSample{tenant: tenant, rate: config.Rate}
+		e.samples[key] = sample
+	}
+	sample.description = config.describe()
+	sample.seen++
+	if sample.seen%sample.rate == 1 {
+		return true
+	}
+
+	if sample.suppressed == 0 {
+		sample.due = now.Add(config.summary())
+	}
+	sample.suppressed++
+	for _, name := range destinations {
+		if !containsString(sample.destinations, name) {
+			sample.destinations = append(sample.destinations, name)
+		}
+	}
+	return false
+}
+
+// due removes and returns the summaries due at now, or all of them if all is true.
+func (e *eventSamples) due(now time.Time, all bool) []eventSample {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var due []eventSample
+	for _, sample := range e.samples {
+		if sample.suppressed > 0 && (all || !now.Before(sample.due)) {
+			due = append(due, *sample)
+			sample.suppressed, sample.destinations = 0, nil
+		}
+	}
+	return due
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleEvent returns false if a sampling of the routing suppresses the event, the first sampling matching.
+func (s *Server) sampleEvent(routing *routingTable, event *webhook.Event, destinations []string, now time.Time) bool {
+	for i := range routing.config.Sampling {
+		config := &routing.config.Sampling[i]
+		if config.matches(event) {
+			return s.samples.sample(routing.name, i, config, destinations, now)
+		}
+	}
+	return true
+}
+
+// ProcessSampling sends the summaries of the suppressed events when they are due, checking at every interval,
+// until done is closed or the server is shut down.
+func (s *Server) ProcessSampling(interval time.Duration, done <-chan struct{}) {
+	s.workers.Add(1)
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.sendSamplingSummaries(s.samples.due(now, false))
+		}
+	}
+}
+
+// sendSamplingSummaries sends the summaries with the current configuration.
+func (s *Server) sendSamplingSummaries(list []eventSample) {
+	for _, sample := range list {
+		routing := s.currentRouting().tenantByName(sample.tenant)
+		if routing == nil {
+			continue
+		}
+		text := fmt.Sprintf("Suppressed %d similar events: %s (1 in %d delivered)", sample.suppressed, sample.description, sample.rate)
+		for _, name := range sample.destinations {
+			service := routing.services[name]
+			if service == nil {
+				log.Warn().Str("tenant", sample.tenant).Str("destination", name).Int("events", sample.suppressed).Msg("Dropping the sampling summary: unknown destination")
+				continue
+			}
+			if err := routing.postMessage(context.Background(), service, text); err != nil {
+				log.Error().Err(err).Str("tenant", sample.tenant).Str("destination", name).Int("events", sample.suppressed).Msg("Error sending the sampling summary")
+			}
+		}
+	}
+}
+
+// sampledDestinations returns the destinations of the webhook, immediately or in a digest.
+func sampledDestinations(p *Webhook) []string {
+	destinations := append([]string{}, p.Destinations...)
+	for _, target := range p.digests {
+		if !containsString(destinations, target.destination) {
+			destinations = append(destinations, target.destination)
+		}
+	}
+	return destinations
+}
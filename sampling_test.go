@@ -0,0 +1,75 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingConfig_validate(t *testing.T) {
+	tests := []SamplingConfig{
+		{Rate: 10},
+		{Events: []string{"zone_record.update"}, Rate: 1},
+		{Actors: []string{"[terraform"}, Rate: 10},
+		{Events: []string{"zone_record.update"}, Rate: 10, Summary: Duration(-time.Hour)},
+	}
+	for _, test := range tests {
+		if err := test.validate(); err == nil {
+			t.Errorf("validate(%+v) expected error", test)
+		}
+	}
+}
+
+func TestEvents_Sampling(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}],
+		"sampling": [{"events": ["zone_record.update"], "actors": ["terraform"], "rate": 3}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	service := &failingService{}
+	server.routing.services["ops"] = service
+
+	payload := `{"name": "zone_record.update", "request_identifier": "b3d9e2a4-sample-0000-0000000000%02d", "data": {"zone_record": {"id": %d, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}, "actor": {"entity": "%s", "pretty": "%s"}, "account": {"id": 1010, "display": "User"}}`
+	for i := 0; i < 8; i++ {
+		entity, actor := "api_token", "terraform"
+		if i == 7 {
+			entity, actor = "user", "jane@example.com"
+		}
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, i, i, entity, actor)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		want := ""
+		if i != 0 && i != 3 && i != 6 && i != 7 {
+			want = "skipped;sampled"
+		}
+		if got := response.Header().Get(headerProcessingStatus); want != got {
+			t.Errorf("POST /events #%d expected status %q, got %q", i, want, got)
+		}
+	}
+	if want, got := 4, service.sent; want != got {
+		t.Errorf("expected %v events delivered, got %v", want, got)
+	}
+
+	server.sendSamplingSummaries(server.samples.due(time.Now(), false))
+	if want, got := 0, len(service.messages); want != got {
+		t.Errorf("sampling summary expected to be pending, got %v messages", got)
+	}
+	server.sendSamplingSummaries(server.samples.due(time.Now().Add(time.Hour), false))
+	if want, got := 1, len(service.messages); want != got {
+		t.Fatalf("sampling summary expected %v message, got %v", want, got)
+	}
+	if want, got := "Suppressed 4 similar events: zone_record.update by terraform (1 in 3 delivered)", service.messages[0]; want != got {
+		t.Errorf("sampling summary expected %q, got %q", want, got)
+	}
+	if got := server.samples.due(time.Now().Add(2*time.Hour), false); len(got) != 0 {
+		t.Errorf("sampling summary expected to be sent once, got %v", len(got))
+	}
+}
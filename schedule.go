@@ -0,0 +1,140 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression with 5 fields:
+// minute, hour, day of month, month and day of week (0 is Sunday).
+//
+// Each field supports "*", single values, ranges ("1-5"), steps ("*/15", "0-30/10") and lists ("1,15").
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek map[int]bool
+
+	// domStar and dowStar are true when the day fields are "*",
+	// to apply the cron rule: if both are restricted, either one matches.
+	domStar, dowStar bool
+}
+
+var cronFieldRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// parseCron parses a cron expression.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", expr)
+	}
+
+	var sets [5]map[int]bool
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFieldRanges[i][0], cronFieldRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", expr, err)
+		}
+		sets[i] = set
+	}
+
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], dayOfMonth: sets[2], month: sets[3], dayOfWeek: sets[4],
+		domStar: fields[2] == "*", dowStar: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Matches returns true if the schedule fires at the minute of t.
+func (c *cronSchedule) Matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := c.dayOfMonth[t.Day()], c.dayOfWeek[int(t.Weekday())]
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// processSchedule runs the job at the minutes for which due returns true, checking at every interval,
+// until done is closed or the server is shut down. With several instances, only the leader runs the job.
+func (s *Server) processSchedule(interval time.Duration, done <-chan struct{}, due func(minute time.Time) bool, run func(ctx context.Context, now time.Time)) {
+	s.workers.Add(1)
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			// The schedule is checked once per minute.
+			minute := now.Truncate(time.Minute)
+			if minute.Equal(last) {
+				continue
+			}
+			last = minute
+			if due(minute) && s.isLeader(context.Background(), now) {
+				run(context.Background(), now)
+			}
+		}
+	}
+}
+
+// scheduleDue returns true if the cron expression matches the minute in the timezone.
+func scheduleDue(expr, timezone string, minute time.Time) bool {
+	schedule, err := parseCron(expr)
+	if err != nil {
+		return false
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return false
+	}
+	return schedule.Matches(minute.In(location))
+}
@@ -0,0 +1,43 @@
+package strillone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr string
+		time time.Time
+		want bool
+	}{
+		{"0 22 * * *", time.Date(2021, 3, 1, 22, 0, 0, 0, time.UTC), true},
+		{"0 22 * * *", time.Date(2021, 3, 1, 22, 1, 0, 0, time.UTC), false},
+		{"*/15 * * * *", time.Date(2021, 3, 1, 10, 45, 0, 0, time.UTC), true},
+		{"*/15 * * * *", time.Date(2021, 3, 1, 10, 46, 0, 0, time.UTC), false},
+		// 2021-03-06 is a Saturday
+		{"0 2 * * 6", time.Date(2021, 3, 6, 2, 0, 0, 0, time.UTC), true},
+		{"0 2 * * 1-5", time.Date(2021, 3, 6, 2, 0, 0, 0, time.UTC), false},
+		{"0 0 1,15 * *", time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC), true},
+		// day of month or day of week when both are restricted
+		{"0 0 1 * 6", time.Date(2021, 3, 6, 0, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		schedule, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q) returned error: %v", tt.expr, err)
+		}
+		if got := schedule.Matches(tt.time); tt.want != got {
+			t.Errorf("parseCron(%q).Matches(%v) expected %v, got %v", tt.expr, tt.time, tt.want, got)
+		}
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * 7", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) expected error", expr)
+		}
+	}
+}
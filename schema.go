@@ -0,0 +1,155 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// maxPayloadExcerpt is the length of the payload excerpts in the operator notices.
+const maxPayloadExcerpt = 500
+
+// payloadSchema describes the resource the messages of a family of events are formatted from:
+// the key of the resource in the data of the events, and its fields that must be present.
+type payloadSchema struct {
+	resource string
+	fields   []string
+}
+
+// payloadSchemas are the schemas of the payloads, by event family.
+// The families missing from the schemas are only checked for the envelope of the events.
+var payloadSchemas = map[string]payloadSchema{
+	"account":       {resource: "account"},
+	"certificate":   {resource: "certificate", fields: []string{"id"}},
+	"contact":       {resource: "contact", fields: []string{"id"}},
+	"dnssec":        {resource: "delegation_signer_record"},
+	"domain":        {resource: "domain", fields: []string{"name"}},
+	"email_forward": {resource: "email_forward", fields: []string{"id"}},
+	"name_server":   {resource: "name_server", fields: []string{"name"}},
+	"push":          {resource: "push", fields: []string{"id"}},
+	"subscription":  {resource: "subscription"},
+	"webhook":       {resource: "webhook", fields: []string{"url"}},
+	"whois_privacy": {resource: "domain", fields: []string{"name"}},
+	"zone":          {resource: "zone", fields: []string{"name"}},
+	"zone_record":   {resource: "zone_record", fields: []string{"zone_id", "type"}},
+}
+
+// payloadError describes why a payload doesn't match the schema of its event, with the path
+// of the offending field, such as "data.domain.name: missing".
+type payloadError struct {
+	path   string
+	reason string
+}
+
+func (e *payloadError) Error() string {
+	return e.path + ": " + e.reason
+}
+
+// validatePayload checks that the payload has the envelope of the events, and the resource
+// the messages of its event are formatted from, so that the payloads DNSimple changed are
+// reported instead of producing garbled messages.
+func validatePayload(e *webhook.Event) error {
+	var envelope struct {
+		Name    *string                    `json:"name"`
+		Account map[string]json.RawMessage `json:"account"`
+		Data    map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(e.GetPayload(), &envelope); err != nil {
+		return jsonPayloadError(err)
+	}
+	if envelope.Name == nil || *envelope.Name == "" {
+		return &payloadError{path: "name", reason: "missing"}
+	}
+	if envelope.Account == nil {
+		return &payloadError{path: "account", reason: "missing"}
+	}
+	if err := requireField(envelope.Account, "account", "id"); err != nil {
+		return err
+	}
+	if envelope.Data == nil {
+		return &payloadError{path: "data", reason: "missing"}
+	}
+
+	// The webhook package parses the events missing from its catalog as generic events,
+	// so their data are checked against their types here.
+	if newData, ok := catalogEventData[e.Name]; ok {
+		container := struct {
+			Data interface{} `json:"data"`
+		}{Data: newData()}
+		if err := json.Unmarshal(e.GetPayload(), &container); err != nil {
+			return jsonPayloadError(err)
+		}
+	}
+
+	schema, ok := payloadSchemas[eventFamily(e.Name)]
+	if !ok {
+		return nil
+	}
+	var resource map[string]json.RawMessage
+	path := "data." + schema.resource
+	if raw, ok := envelope.Data[schema.resource]; !ok || json.Unmarshal(raw, &resource) != nil || resource == nil {
+		return &payloadError{path: path, reason: "missing"}
+	}
+	for _, field := range schema.fields {
+		if err := requireField(resource, path, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonPayloadError returns the path of the mismatched field of a decoding error.
+func jsonPayloadError(err error) error {
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return &payloadError{path: typeError.Field, reason: "expected " + typeError.Type.String() + ", got " + typeError.Value}
+	}
+	return err
+}
+
+// requireField checks that the field of the object is a non-empty string or a number.
+func requireField(object map[string]json.RawMessage, path, field string) error {
+	raw, ok := object[field]
+	if !ok || string(raw) == "null" || string(raw) == `""` {
+		return &payloadError{path: path + "." + field, reason: "missing"}
+	}
+	switch raw[0] {
+	case '{', '[', 't', 'f':
+		return &payloadError{path: path + "." + field, reason: "expected a string or a number"}
+	}
+	return nil
+}
+
+// payloadExcerpt returns the beginning of the payload, to include in the notices.
+func payloadExcerpt(payload []byte) string {
+	if len(payload) <= maxPayloadExcerpt {
+		return string(payload)
+	}
+	excerpt := payload[:maxPayloadExcerpt]
+	for len(excerpt) > 0 && !utf8.Valid(excerpt) {
+		excerpt = excerpt[:len(excerpt)-1]
+	}
+	return string(excerpt) + "…"
+}
+
+// invalidPayloadNotice describes the invalid payload of the event for the operators.
+func invalidPayloadNotice(e *webhook.Event, err error, payload []byte) string {
+	return fmt.Sprintf("Invalid payload for the event %s (request %s): %v. The event was not delivered.\n```\n%s\n```",
+		e.Name, e.RequestID, err, payloadExcerpt(payload))
+}
+
+// notifyOperator posts a notice to the operator destination, when configured.
+func (s *Server) notifyOperator(routing *routingTable, text string) {
+	operator := routing.config.OperatorDestination
+	if operator == "" {
+		return
+	}
+	if err := routing.postMessage(context.Background(), routing.services[operator], text); err != nil {
+		log.Error().Err(err).Str("tenant", routing.name).Str("destination", operator).Msg("Error sending the operator notice")
+	}
+}
@@ -0,0 +1,101 @@
+package strillone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_validatePayload(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"name": "domain.create", "account": {"id": 1010}, "data": {"domain": {"id": 1, "name": "example.com"}}}`, ""},
+		{`{"name": "domain.create", "account": {"id": 1010}, "data": {"domain": {"id": 1}}}`, "data.domain.name: missing"},
+		{`{"name": "domain.create", "account": {"id": 1010}, "data": {"zone": {"name": "example.com"}}}`, "data.domain: missing"},
+		{`{"name": "push.initiate", "account": {"id": 1010}, "data": {"push": {"id": "one"}}}`, "data.push.id: expected int64, got string"},
+		{`{"name": "domain.create", "data": {"domain": {"name": "example.com"}}}`, "account: missing"},
+		{`{"name": "domain.create", "account": {"display": "User"}, "data": {"domain": {"name": "example.com"}}}`, "account.id: missing"},
+		{`{"account": {"id": 1010}, "data": {}}`, "name: missing"},
+		{`{"name": "service.create", "account": {"id": 1010}}`, "data: missing"},
+		{`{"name": "service.create", "account": {"id": 1010}, "data": {"service": {}}}`, ""},
+	}
+	for _, test := range tests {
+		event, err := webhook.ParseEvent([]byte(test.payload))
+		if err != nil {
+			t.Fatalf("ParseEvent(%s) returned error: %v", test.payload, err)
+		}
+		got := ""
+		if err := validatePayload(event); err != nil {
+			got = err.Error()
+		}
+		if test.want != got {
+			t.Errorf("validatePayload(%s) expected %q, got %q", test.payload, test.want, got)
+		}
+	}
+}
+
+func Test_requireField(t *testing.T) {
+	object := map[string]json.RawMessage{"id": json.RawMessage(`1`), "name": json.RawMessage(`""`), "type": json.RawMessage(`{"name": "A"}`)}
+	if err := requireField(object, "data.zone_record", "id"); err != nil {
+		t.Errorf("requireField(id) returned error: %v", err)
+	}
+	if want, got := "data.zone_record.name: missing", requireField(object, "data.zone_record", "name"); got == nil || want != got.Error() {
+		t.Errorf("requireField(name) expected %q, got %v", want, got)
+	}
+	if want, got := "data.zone_record.type: expected a string or a number", requireField(object, "data.zone_record", "type"); got == nil || want != got.Error() {
+		t.Errorf("requireField(type) expected %q, got %v", want, got)
+	}
+}
+
+func Test_payloadExcerpt(t *testing.T) {
+	payload := []byte(strings.Repeat("a", maxPayloadExcerpt-1) + "é")
+	if want, got := strings.Repeat("a", maxPayloadExcerpt-1)+"…", payloadExcerpt(payload); want != got {
+		t.Errorf("payloadExcerpt expected %q, got %q", want, got)
+	}
+}
+
+func TestEvents_InvalidPayload(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "operators", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops"]}],
+		"operator_destination": "operators"
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops := &failingService{}
+	operators := &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["operators"] = operators
+
+	payload := `{"data": {"domain": {"id": 1}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "c4e1a7b2-schema-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusOK; want != response.Code {
+		t.Errorf("POST /events expected HTTP %v, got %v", want, response.Code)
+	}
+	if want, got := "skipped;invalid-payload", response.Header().Get(headerProcessingStatus); want != got {
+		t.Errorf("POST /events expected %v %q, got %q", headerProcessingStatus, want, got)
+	}
+	if want, got := 0, ops.sent; want != got {
+		t.Errorf("POST /events expected %v deliveries, got %v", want, got)
+	}
+	if want, got := 1, len(operators.messages); want != got {
+		t.Fatalf("operator destination expected %v notice, got %v", want, got)
+	}
+	if notice := operators.messages[0]; !strings.Contains(notice, "domain.create (request c4e1a7b2-schema-0000-000000000001): data.domain.name: missing") || !strings.Contains(notice, `"domain": {"id": 1}`) {
+		t.Errorf("operator notice unexpected: %v", notice)
+	}
+}
@@ -0,0 +1,152 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// parseHistorySearch adds to the filter the terms of a search, such as
+// `domain:example.com type:zone_record.update since:7d ns1`.
+//
+// The terms with a known key (domain, type or event, tenant, status, actor, since and until) restrict
+// the filter, the other terms are keywords the events must contain. The values with spaces are quoted.
+func parseHistorySearch(search string, now time.Time, filter *HistoryFilter) error {
+	terms, err := splitSearch(search)
+	if err != nil {
+		return err
+	}
+	for _, term := range terms {
+		key, value := "", term
+		if i := strings.Index(term, ":"); i > 0 {
+			key, value = strings.ToLower(term[:i]), strings.Trim(term[i+1:], `"`)
+		}
+		switch key {
+		case "domain":
+			filter.Domain = value
+		case "type", "event":
+			if _, err := path.Match(value, ""); err != nil {
+				return fmt.Errorf("invalid event pattern %q", value)
+			}
+			filter.Event = value
+		case "tenant":
+			filter.Tenant = value
+		case "status":
+			filter.Status = value
+		case "actor":
+			filter.Actor = value
+		case "since", "until":
+			t, err := parseSearchTime(value, now)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %v", key, err)
+			}
+			if key == "since" {
+				filter.Since = t
+			} else {
+				filter.Until = t
+			}
+		default:
+			filter.Keywords = append(filter.Keywords, strings.ToLower(strings.Trim(term, `"`)))
+		}
+	}
+	return nil
+}
+
+// splitSearch splits the search on the spaces outside of the quotes.
+func splitSearch(search string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+	for _, r := range search {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			term.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("invalid search: unterminated quote")
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+// parseSearchTime parses a time of a search: a duration before now, such as 7d or 12h,
+// a date such as 2021-03-01, or an RFC 3339 time.
+func parseSearchTime(value string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a duration (7d, 12h), a date or an RFC 3339 time", value)
+}
+
+// historySearchText returns the lowercase text the keywords of a search are matched against:
+// the name, the domain and the request identifier of the event, and the values of its payload.
+func historySearchText(event *HistoryEvent) string {
+	var text strings.Builder
+	for _, s := range []string{event.Name, event.Domain, event.RequestID} {
+		text.WriteString(strings.ToLower(s))
+		text.WriteByte('\n')
+	}
+	var payload interface{}
+	if err := json.Unmarshal(event.Payload, &payload); err == nil {
+		writeSearchValues(&text, payload)
+	}
+	return text.String()
+}
+
+func writeSearchValues(text *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, nested := range v {
+			writeSearchValues(text, nested)
+		}
+	case []interface{}:
+		for _, nested := range v {
+			writeSearchValues(text, nested)
+		}
+	case string:
+		text.WriteString(strings.ToLower(v))
+		text.WriteByte('\n')
+	case float64:
+		text.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		text.WriteByte('\n')
+	}
+}
+
+// historyActor returns the actor of the event, from its payload.
+func historyActor(event *HistoryEvent) string {
+	var payload struct {
+		Actor *struct {
+			Pretty string `json:"pretty"`
+		} `json:"actor"`
+	}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil || payload.Actor == nil {
+		return ""
+	}
+	return payload.Actor.Pretty
+}
@@ -0,0 +1,122 @@
+package strillone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseHistorySearch(t *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		search   string
+		expected HistoryFilter
+	}{
+		{"", HistoryFilter{}},
+		{"domain:example.com type:zone_record.update since:7d", HistoryFilter{Domain: "example.com", Event: "zone_record.update", Since: now.AddDate(0, 0, -7)}},
+		{"event:domain.* tenant:acme status:failed until:12h", HistoryFilter{Event: "domain.*", Tenant: "acme", Status: "failed", Until: now.Add(-12 * time.Hour)}},
+		{`actor:"Jane Doe" since:2021-03-01 NS1 "load balancer"`, HistoryFilter{Actor: "Jane Doe", Since: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), Keywords: []string{"ns1", "load balancer"}}},
+		{"https://example.com", HistoryFilter{Keywords: []string{"https://example.com"}}},
+	}
+	for _, tt := range tests {
+		filter := HistoryFilter{}
+		if err := parseHistorySearch(tt.search, now, &filter); err != nil {
+			t.Errorf("parseHistorySearch(%q) returned error: %v", tt.search, err)
+			continue
+		}
+		want, _ := json.Marshal(tt.expected)
+		got, _ := json.Marshal(filter)
+		if string(want) != string(got) {
+			t.Errorf("parseHistorySearch(%q) expected %s, got %s", tt.search, want, got)
+		}
+	}
+
+	for _, search := range []string{"since:yesterday", "type:[domain", `"unterminated`} {
+		if err := parseHistorySearch(search, now, &HistoryFilter{}); err == nil {
+			t.Errorf("parseHistorySearch(%q) expected error", search)
+		}
+	}
+}
+
+func TestHistoryFilter_MatchesSearch(t *testing.T) {
+	event := &HistoryEvent{
+		Name:      "zone_record.update",
+		RequestID: "1",
+		Domain:    "example.com",
+		Payload:   json.RawMessage(`{"data": {"zone_record": {"id": 42, "zone_id": "example.com", "type": "NS", "content": "ns1.dnsimple.com"}}, "actor": {"pretty": "jane@example.com"}}`),
+	}
+	tests := []struct {
+		filter   HistoryFilter
+		expected bool
+	}{
+		{HistoryFilter{Keywords: []string{"ns1.dnsimple"}}, true},
+		{HistoryFilter{Keywords: []string{"ns1.dnsimple", "42"}}, true},
+		{HistoryFilter{Keywords: []string{"ns1.dnsimple", "ns2"}}, false},
+		// the keywords match the values, not the keys
+		{HistoryFilter{Keywords: []string{"content"}}, false},
+		{HistoryFilter{Actor: "JANE"}, true},
+		{HistoryFilter{Actor: "john"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Matches(event); tt.expected != got {
+			t.Errorf("Matches(%+v) expected %v, got %v", tt.filter, tt.expected, got)
+		}
+	}
+}
+
+func TestServer_HistorySearch(t *testing.T) {
+	history, cleanup := openTestHistory(t)
+	defer cleanup()
+
+	config, err := ParseConfig([]byte(`{
+		"admin": {"token": "secret"},
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetHistory(history)
+	server.routing.services["ops"] = &failingService{}
+
+	for _, payload := range []string{
+		`{"data": {"zone_record": {"id": 1, "zone_id": "example.com", "type": "NS", "name": "", "content": "ns1.dnsimple.com"}}, "actor": {"pretty": "jane@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "zone_record.update", "request_identifier": "5c9a2e7f-search-0000-000000000001"}`,
+		`{"data": {"zone_record": {"id": 2, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}, "actor": {"pretty": "jane@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "zone_record.update", "request_identifier": "5c9a2e7f-search-0000-000000000002"}`,
+		`{"data": {"zone_record": {"id": 3, "zone_id": "example.org", "type": "NS", "name": "", "content": "ns1.dnsimple.com"}}, "actor": {"pretty": "jane@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "zone_record.update", "request_identifier": "5c9a2e7f-search-0000-000000000003"}`,
+	} {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+		server.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	response := adminRequest(server, "GET", "/api/events?q=domain:example.com+type:zone_record.update+since:7d+ns1", "")
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("GET /api/events?q= expected HTTP %v, got %v", want, got)
+	}
+	var events []*HistoryEvent
+	if err := json.Unmarshal(response.Body.Bytes(), &events); err != nil {
+		t.Fatalf("GET /api/events?q= returned invalid JSON: %v", err)
+	}
+	if want, got := 1, len(events); want != got {
+		t.Fatalf("GET /api/events?q= expected %v events, got %v", want, got)
+	}
+	if want, got := "5c9a2e7f-search-0000-000000000001", events[0].RequestID; want != got {
+		t.Errorf("GET /api/events?q= expected event %v, got %v", want, got)
+	}
+
+	if want, got := http.StatusBadRequest, adminRequest(server, "GET", "/api/events?q=since:yesterday", "").Code; want != got {
+		t.Errorf("GET /api/events?q= with invalid since expected HTTP %v, got %v", want, got)
+	}
+
+	request, _ := http.NewRequest("GET", "/dashboard?q=ns1+domain:example.org", nil)
+	request.SetBasicAuth("admin", "secret")
+	dashboard := httptest.NewRecorder()
+	server.ServeHTTP(dashboard, request)
+	body := dashboard.Body.String()
+	if !strings.Contains(body, "5c9a2e7f-search-0000-000000000003") || strings.Contains(body, "5c9a2e7f-search-0000-000000000001") {
+		t.Errorf("GET /dashboard?q= expected only the matching event, got %v", body)
+	}
+}
@@ -0,0 +1,391 @@
+package strillone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const secretsTimeout = 10 * time.Second
+
+// builtinSchemes are the schemes of the built-in backends and of the encrypted references.
+var builtinSchemes = map[string]bool{
+	"vault":          true,
+	"aws-sm":         true,
+	"aws-ssm":        true,
+	"aws-kms":        true,
+	"gcp-sm":         true,
+	"gcp-kms":        true,
+	EncryptionScheme: true,
+}
+
+// SecretBackend resolves the secrets stored in an external service.
+type SecretBackend interface {
+	// Resolve returns the value of the secret at the path.
+	// The key, if not empty, selects a field of a secret made of several key/value pairs.
+	Resolve(ctx context.Context, path, key string) (string, error)
+}
+
+// Secrets resolves the secret references used in the configuration.
+//
+// A reference has the form <scheme>:<path>[#<key>], for example
+// vault:secret/data/strillone#slack_url or aws-sm:strillone/slack.
+// Values that don't start with a registered scheme are returned unchanged, except the references
+// to the built-in backends that aren't configured, which fail to resolve.
+//
+// Resolved values are cached, and Refresh resolves them again to pick up rotated secrets.
+type Secrets struct {
+	backends map[string]SecretBackend
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// NewSecrets returns a Secrets with the backends, by scheme.
+func NewSecrets(backends map[string]SecretBackend) *Secrets {
+	return &Secrets{backends: backends, values: map[string]string{}}
+}
+
+// NewSecretsFromEnv returns a Secrets with the backends configured in the environment:
+// Vault with VAULT_ADDR and VAULT_TOKEN, AWS Secrets Manager and Parameter Store with the AWS credentials,
+// and Google Cloud Secret Manager in Cloud Run and Cloud Functions, along with AWS KMS and Google Cloud KMS.
+func NewSecretsFromEnv() *Secrets {
+	backends := map[string]SecretBackend{}
+	if addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"); addr != "" && token != "" {
+		backends["vault"] = &VaultBackend{Address: addr, Token: token}
+	}
+	if creds := AWSCredentialsFromEnv(); creds != nil {
+		backends["aws-sm"] = &AWSSecretsManagerBackend{Credentials: creds}
+		backends["aws-ssm"] = &AWSSSMParameterBackend{Credentials: creds}
+		backends["aws-kms"] = &AWSKMSBackend{Credentials: creds}
+	}
+	if creds := GCPCredentialsFromEnv(); creds != nil {
+		backends["gcp-sm"] = &GCPSecretManagerBackend{Credentials: creds}
+		backends["gcp-kms"] = &GCPKMSBackend{Credentials: creds}
+	}
+	return NewSecrets(backends)
+}
+
+// Register adds the backend of the scheme, before the secrets are resolved.
+func (s *Secrets) Register(scheme string, backend SecretBackend) {
+	if s.backends == nil {
+		s.backends = map[string]SecretBackend{}
+	}
+	s.backends[scheme] = backend
+}
+
+// IsReference returns true if the value is a reference to a registered backend.
+func (s *Secrets) IsReference(value string) bool {
+	if s == nil {
+		return false
+	}
+	_, _, _, ok := s.parse(value)
+	return ok
+}
+
+// Resolve returns the value of the secret reference, from the cache if available.
+func (s *Secrets) Resolve(value string) (string, error) {
+	if !s.IsReference(value) {
+		// The reference itself is never used as the credential.
+		if i := strings.Index(value, ":"); i > 0 && builtinSchemes[value[:i]] {
+			return "", fmt.Errorf("error resolving secret %s: the %s backend is not configured", value, value[:i])
+		}
+		return value, nil
+	}
+
+	s.mu.Lock()
+	resolved, ok := s.values[value]
+	s.mu.Unlock()
+	if ok {
+		return resolved, nil
+	}
+
+	resolved, err := s.fetch(value)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.values[value] = resolved
+	s.mu.Unlock()
+	return resolved, nil
+}
+
+// Refresh resolves again every cached reference.
+// It returns true if at least one secret changed.
+func (s *Secrets) Refresh() (bool, error) {
+	s.mu.Lock()
+	refs := make([]string, 0, len(s.values))
+	for ref := range s.values {
+		refs = append(refs, ref)
+	}
+	s.mu.Unlock()
+
+	changed := false
+	for _, ref := range refs {
+		resolved, err := s.fetch(ref)
+		if err != nil {
+			return changed, err
+		}
+
+		s.mu.Lock()
+		if s.values[ref] != resolved {
+			s.values[ref] = resolved
+			changed = true
+		}
+		s.mu.Unlock()
+	}
+	return changed, nil
+}
+
+func (s *Secrets) fetch(value string) (string, error) {
+	backend, path, key, _ := s.parse(value)
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+
+	resolved, err := backend.Resolve(ctx, path, key)
+	if err != nil {
+		return "", fmt.Errorf("error resolving secret %s: %v", value, err)
+	}
+	return resolved, nil
+}
+
+func (s *Secrets) parse(value string) (backend SecretBackend, path, key string, ok bool) {
+	i := strings.Index(value, ":")
+	if i <= 0 {
+		return nil, "", "", false
+	}
+	backend, ok = s.backends[value[:i]]
+	if !ok {
+		return nil, "", "", false
+	}
+
+	path = value[i+1:]
+	if j := strings.LastIndex(path, "#"); j >= 0 {
+		path, key = path[:j], path[j+1:]
+	}
+	return backend, path, key, true
+}
+
+// SetSecrets sets the resolver for the secret references in the configuration,
+// and reloads the current configuration with it.
+func (s *Server) SetSecrets(secrets *Secrets) error {
+	s.secrets = secrets
+	return s.Reload(s.currentRouting().config)
+}
+
+// WatchSecrets periodically resolves the secrets again, and reloads the configuration
+// when at least one of them was rotated. It stops when done is closed.
+func (s *Server) WatchSecrets(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				changed, err := s.secrets.Refresh()
+				if err != nil {
+					log.Error().Err(err).Msg("Error refreshing secrets")
+				}
+				if changed {
+					log.Info().Msg("Secrets rotated, reloading configuration")
+					if err := s.Reload(s.currentRouting().config); err != nil {
+						log.Error().Err(err).Msg("Error reloading configuration")
+					}
+				}
+			}
+		}
+	}()
+}
+
+// VaultBackend resolves secrets stored in HashiCorp Vault.
+//
+// Both the KV version 1 and version 2 engines are supported.
+type VaultBackend struct {
+	Address string
+	Token   string
+
+	HTTPClient *http.Client
+}
+
+// Resolve implements SecretBackend
+func (b *VaultBackend) Resolve(ctx context.Context, path, key string) (string, error) {
+	url := strings.TrimSuffix(b.Address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", b.Token)
+
+	body, err := doSecretsRequest(b.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+
+	data := response.Data
+	// KV version 2 nests the secret in data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	if key == "" {
+		key = "value"
+	}
+	value, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %q not found", key)
+	}
+	return value, nil
+}
+
+// AWSSecretsManagerBackend resolves secrets stored in AWS Secrets Manager.
+//
+// When the key is set, the secret string is expected to be a JSON object
+// and the value of the key is returned.
+type AWSSecretsManagerBackend struct {
+	Credentials *AWSCredentials
+
+	// Endpoint overrides the regional endpoint, mostly useful for testing.
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// Resolve implements SecretBackend
+func (b *AWSSecretsManagerBackend) Resolve(ctx context.Context, path, key string) (string, error) {
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", b.Credentials.Region)
+	}
+
+	payload, err := json.Marshal(map[string]string{"SecretId": path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, payload, "secretsmanager", b.Credentials, time.Now())
+
+	body, err := doSecretsRequest(b.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+	return jsonSecretKey(response.SecretString, key)
+}
+
+// AWSSSMParameterBackend resolves secrets stored in the AWS Systems Manager Parameter Store.
+// The secure strings are decrypted.
+//
+// When the key is set, the parameter is expected to be a JSON object
+// and the value of the key is returned.
+type AWSSSMParameterBackend struct {
+	Credentials *AWSCredentials
+
+	// Endpoint overrides the regional endpoint, mostly useful for testing.
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// Resolve implements SecretBackend
+func (b *AWSSSMParameterBackend) Resolve(ctx context.Context, path, key string) (string, error) {
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://ssm.%s.amazonaws.com/", b.Credentials.Region)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"Name": path, "WithDecryption": true})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	signAWSRequest(req, payload, "ssm", b.Credentials, time.Now())
+
+	body, err := doSecretsRequest(b.HTTPClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+	return jsonSecretKey(response.Parameter.Value, key)
+}
+
+// jsonSecretKey returns the value of the key of the JSON object in the secret,
+// or the whole secret without key.
+func jsonSecretKey(secret, key string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %v", err)
+	}
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %q not found", key)
+	}
+	return value, nil
+}
+
+func doSecretsRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
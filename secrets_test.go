@@ -0,0 +1,170 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type staticSecretBackend map[string]string
+
+func (b staticSecretBackend) Resolve(_ context.Context, path, key string) (string, error) {
+	value, ok := b[path+"#"+key]
+	if !ok {
+		return "", fmt.Errorf("not found")
+	}
+	return value, nil
+}
+
+func TestSecrets_Resolve(t *testing.T) {
+	backend := staticSecretBackend{"slack#url": "https://hooks.slack.com/services/X/Y/Z"}
+	secrets := NewSecrets(map[string]SecretBackend{"test": backend})
+
+	if got, _ := secrets.Resolve("https://example.com"); got != "https://example.com" {
+		t.Errorf("Resolve expected plain value unchanged, got %v", got)
+	}
+	if got, _ := secrets.Resolve("unknown:slack#url"); got != "unknown:slack#url" {
+		t.Errorf("Resolve expected unknown scheme unchanged, got %v", got)
+	}
+	for _, reference := range []string{"vault:secret/slack#token", "aws-sm:strillone/slack", "gcp-sm:slack", "enc:bXlzZWNyZXQ=", "aws-kms:AQICAHg=", "gcp-kms:CiQA"} {
+		if got, err := secrets.Resolve(reference); err == nil {
+			t.Errorf("Resolve(%v) without its backend expected error, got %v", reference, got)
+		}
+	}
+
+	got, err := secrets.Resolve("test:slack#url")
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if want := "https://hooks.slack.com/services/X/Y/Z"; want != got {
+		t.Errorf("Resolve expected %v, got %v", want, got)
+	}
+
+	if _, err := secrets.Resolve("test:missing"); err == nil {
+		t.Errorf("Resolve expected error for missing secret")
+	}
+
+	backend["slack#url"] = "https://hooks.slack.com/services/A/B/C"
+	changed, err := secrets.Refresh()
+	if err != nil || !changed {
+		t.Fatalf("Refresh expected change, got %v (%v)", changed, err)
+	}
+	if got, _ := secrets.Resolve("test:slack#url"); got != "https://hooks.slack.com/services/A/B/C" {
+		t.Errorf("Resolve after rotation expected new value, got %v", got)
+	}
+}
+
+func TestServer_ResolvesSecrets(t *testing.T) {
+	backend := staticSecretBackend{"admin#": "secret"}
+	server := NewServer(nil)
+	if err := server.SetSecrets(NewSecrets(map[string]SecretBackend{"test": backend})); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Reload(&Config{Admin: AdminConfig{Token: "test:admin"}}); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if want, got := "secret", server.currentRouting().adminToken; want != got {
+		t.Errorf("admin token expected %v, got %v", want, got)
+	}
+	if want, got := "test:admin", server.currentRouting().config.Admin.Token; want != got {
+		t.Errorf("config expected to keep the reference %v, got %v", want, got)
+	}
+}
+
+func TestVaultBackend(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/strillone":
+			fmt.Fprint(w, `{"data": {"data": {"slack": "kv2"}, "metadata": {"version": 1}}}`)
+		case "/v1/kv/strillone":
+			fmt.Fprint(w, `{"data": {"slack": "kv1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	backend := &VaultBackend{Address: vault.URL, Token: "token"}
+	for path, want := range map[string]string{"secret/data/strillone": "kv2", "kv/strillone": "kv1"} {
+		got, err := backend.Resolve(context.Background(), path, "slack")
+		if err != nil {
+			t.Fatalf("Resolve(%v) returned error: %v", path, err)
+		}
+		if want != got {
+			t.Errorf("Resolve(%v) expected %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestAWSSecretsManagerBackend(t *testing.T) {
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if want, got := `{"SecretId":"strillone/slack"}`, string(body); want != got {
+			t.Errorf("request body expected %v, got %v", want, got)
+		}
+		fmt.Fprint(w, `{"SecretString": "{\"url\": \"https://hooks.slack.com/services/X/Y/Z\"}"}`)
+	}))
+	defer aws.Close()
+
+	backend := &AWSSecretsManagerBackend{
+		Credentials: &AWSCredentials{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Endpoint:    aws.URL,
+	}
+	got, err := backend.Resolve(context.Background(), "strillone/slack", "url")
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if want := "https://hooks.slack.com/services/X/Y/Z"; want != got {
+		t.Errorf("Resolve expected %v, got %v", want, got)
+	}
+}
+
+func TestAWSSSMParameterBackend(t *testing.T) {
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(r.Header.Get("Authorization"), "/ssm/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if want, got := "AmazonSSM.GetParameter", r.Header.Get("X-Amz-Target"); want != got {
+			t.Errorf("request target expected %v, got %v", want, got)
+		}
+		if want, got := `{"Name":"/strillone/slack","WithDecryption":true}`, string(body); want != got {
+			t.Errorf("request body expected %v, got %v", want, got)
+		}
+		fmt.Fprint(w, `{"Parameter": {"Name": "/strillone/slack", "Type": "SecureString", "Value": "{\"url\": \"https://hooks.slack.com/services/X/Y/Z\"}"}}`)
+	}))
+	defer aws.Close()
+
+	backend := &AWSSSMParameterBackend{
+		Credentials: &AWSCredentials{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Endpoint:    aws.URL,
+	}
+	got, err := backend.Resolve(context.Background(), "/strillone/slack", "url")
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if want := "https://hooks.slack.com/services/X/Y/Z"; want != got {
+		t.Errorf("Resolve expected %v, got %v", want, got)
+	}
+
+	got, err = backend.Resolve(context.Background(), "/strillone/slack", "")
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if want := `{"url": "https://hooks.slack.com/services/X/Y/Z"}`; want != got {
+		t.Errorf("Resolve expected %v, got %v", want, got)
+	}
+}
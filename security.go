@@ -0,0 +1,183 @@
+package strillone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// securityAnnotation is the annotation of the messages delivered to the security destination, with the reason of the alert.
+const securityAnnotation = "security"
+
+// SecurityConfig routes the security-sensitive events to a security destination, regardless of the routes,
+// the actor filters and the silences. The security events aren't sampled.
+//
+// The built-in profile covers the domain token resets, the transfer lock disables, the changes of the name servers
+// and of the NS records, the DNSSEC disables and the changes of the contact emails.
+type SecurityConfig struct {
+	// Destination is the name of the destination receiving the security events.
+	Destination string `json:"destination"`
+
+	// Events are event name patterns added to the built-in profile, using the path.Match syntax, optional.
+	Events []string `json:"events,omitempty"`
+}
+
+func (c *SecurityConfig) validate(destinations map[string]bool) error {
+	if c == nil {
+		return nil
+	}
+	if c.Destination == "" {
+		return errors.New("security: missing destination")
+	}
+	if !destinations[c.Destination] {
+		return fmt.Errorf("security: unknown destination %q", c.Destination)
+	}
+	for _, pattern := range c.Events {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("security: invalid event pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// reason returns why the event is security-sensitive, such as "transfer lock disabled", empty if it isn't.
+// The details of the event tell whether a contact.update changed the email.
+func (c *SecurityConfig) reason(e *webhook.Event, details *eventDetails) string {
+	if c == nil {
+		return ""
+	}
+	switch e.Name {
+	case "domain.token_reset":
+		return "domain token reset"
+	case "domain.transfer_lock_disable":
+		return "transfer lock disabled"
+	case "domain.delegation_change":
+		return "name servers changed"
+	case "dnssec.delete":
+		return "DNSSEC disabled"
+	case "contact.update":
+		if details.ContactEmailChanged {
+			return "contact email changed"
+		}
+	case "zone_record.create", "zone_record.update", "zone_record.delete":
+		if data := parseZoneRecordEvent(e); data != nil && data.ZoneRecord != nil && strings.EqualFold(data.ZoneRecord.Type, "NS") {
+			return "NS record changed"
+		}
+	}
+	if matchesAny(c.Events, e.Name) {
+		return e.Name
+	}
+	return ""
+}
+
+// securityDestination returns the security destination of the event, empty if the event isn't security-sensitive.
+func (t *routingTable) securityDestination(e *webhook.Event, details *eventDetails) string {
+	if t.config.Security.reason(e, details) == "" {
+		return ""
+	}
+	return t.config.Security.Destination
+}
+
+// securityAnnotations adds the reason of the alert to the annotations of the messages of the security destination.
+func (t *routingTable) securityAnnotations(e *webhook.Event, destination string, annotations map[string]string) map[string]string {
+	config := t.config.Security
+	if config == nil || destination != config.Destination {
+		return annotations
+	}
+	reason := config.reason(e, detailsOf(e))
+	if reason == "" {
+		return annotations
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[securityAnnotation] = reason
+	return annotations
+}
+
+// maxContactEmails is the number of contacts whose email is remembered.
+const maxContactEmails = 10000
+
+// contactEmailStates remembers the last email received of the contacts of a server, hashed,
+// and the updates that changed it, since the DNSimple payloads only include the updated contact.
+// Like the zone records, the states are bounded.
+type contactEmailStates struct {
+	mu sync.Mutex
+
+	// emails are the hashes of the last emails of the contacts, by tenant, account and contact ID.
+	emails map[string]string
+	order  []string
+
+	// updates are the tenants and request identifiers of the updates that changed the email.
+	updates      map[string]bool
+	updatesOrder []string
+}
+
+func newContactEmailStates() *contactEmailStates {
+	return &contactEmailStates{emails: map[string]string{}, updates: map[string]bool{}}
+}
+
+// observe remembers the email of the contact of a contact.create or contact.update event of the tenant,
+// and returns true if the update changed it.
+func (c *contactEmailStates) observe(tenant string, e *webhook.Event) bool {
+	return c.lookup(tenant, e, true)
+}
+
+// changed returns true if the update of the tenant changes the email of the contact,
+// without remembering the email, for the dry runs.
+func (c *contactEmailStates) changed(tenant string, e *webhook.Event) bool {
+	return c.lookup(tenant, e, false)
+}
+
+func (c *contactEmailStates) lookup(tenant string, e *webhook.Event, remember bool) bool {
+	if (e.Name != "contact.create" && e.Name != "contact.update") || e.Account == nil {
+		return false
+	}
+	data, ok := eventData(e).(*webhook.ContactEventData)
+	if !ok || data.Contact == nil {
+		return false
+	}
+	key := fmt.Sprintf("%s/%d/%d", tenant, e.Account.ID, data.Contact.ID)
+	update := tenant + "/" + e.RequestID
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The webhooks retried by DNSimple are observed again.
+	if c.updates[update] {
+		return true
+	}
+
+	sum := sha256.Sum256([]byte(strings.ToLower(data.Contact.Email)))
+	email := hex.EncodeToString(sum[:])
+
+	previous, ok := c.emails[key]
+	changed := ok && e.Name == "contact.update" && previous != email
+	if !remember {
+		return changed
+	}
+
+	if changed {
+		c.updates[update] = true
+		c.updatesOrder = append(c.updatesOrder, update)
+		for len(c.updatesOrder) > maxContactEmails {
+			delete(c.updates, c.updatesOrder[0])
+			c.updatesOrder = c.updatesOrder[1:]
+		}
+	}
+	if !ok {
+		c.order = append(c.order, key)
+	}
+	c.emails[key] = email
+	for len(c.order) > maxContactEmails {
+		delete(c.emails, c.order[0])
+		c.order = c.order[1:]
+	}
+	return changed
+}
@@ -0,0 +1,130 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestSecurityConfig_reason(t *testing.T) {
+	config := &SecurityConfig{Destination: "security", Events: []string{"webhook.delete"}}
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"name": "domain.token_reset", "request_identifier": "d5f1a4c6-sec-1", "data": {"domain": {"id": 1, "name": "example.com"}}}`, "domain token reset"},
+		{`{"name": "domain.transfer_lock_disable", "request_identifier": "d5f1a4c6-sec-2", "data": {"domain": {"id": 1, "name": "example.com"}}}`, "transfer lock disabled"},
+		{`{"name": "domain.delegation_change", "request_identifier": "d5f1a4c6-sec-3", "data": {"domain": {"id": 1, "name": "example.com"}, "name_servers": ["ns1.example.net"]}}`, "name servers changed"},
+		{`{"name": "dnssec.delete", "request_identifier": "d5f1a4c6-sec-4", "data": {"dnssec": {"enabled": false}}}`, "DNSSEC disabled"},
+		{`{"name": "zone_record.update", "request_identifier": "d5f1a4c6-sec-5", "data": {"zone_record": {"id": 1, "zone_id": "example.com", "type": "NS", "content": "ns1.example.net"}}}`, "NS record changed"},
+		{`{"name": "zone_record.update", "request_identifier": "d5f1a4c6-sec-6", "data": {"zone_record": {"id": 2, "zone_id": "example.com", "type": "A", "content": "1.2.3.4"}}}`, ""},
+		{`{"name": "webhook.delete", "request_identifier": "d5f1a4c6-sec-7", "data": {"webhook": {"id": 1, "url": "https://example.com"}}}`, "webhook.delete"},
+		{`{"name": "domain.create", "request_identifier": "d5f1a4c6-sec-8", "data": {"domain": {"id": 1, "name": "example.com"}}}`, ""},
+	}
+	for _, test := range tests {
+		event, err := webhook.ParseEvent([]byte(test.payload))
+		if err != nil {
+			t.Fatalf("ParseEvent returned error: %v", err)
+		}
+		if got := config.reason(event, &eventDetails{}); test.want != got {
+			t.Errorf("reason(%v) expected %q, got %q", event.Name, test.want, got)
+		}
+	}
+
+	var nilConfig *SecurityConfig
+	event, _ := webhook.ParseEvent([]byte(tests[0].payload))
+	if got := nilConfig.reason(event, &eventDetails{}); got != "" {
+		t.Errorf("reason without configuration expected no reason, got %q", got)
+	}
+}
+
+func TestContactEmailStates_observe(t *testing.T) {
+	contacts := newContactEmailStates()
+	payload := `{"name": "%s", "request_identifier": "%s", "data": {"contact": {"id": 42, "email": "%s", "phone": "%s"}}, "account": {"id": 1010, "display": "User"}}`
+	tests := []struct {
+		name, requestID, email, phone string
+		changed                       bool
+	}{
+		{"contact.update", "e6a2b5d7-contact-1", "jane@example.com", "+1.555", false},
+		{"contact.update", "e6a2b5d7-contact-2", "Jane@Example.com", "+1.556", false},
+		{"contact.update", "e6a2b5d7-contact-3", "attacker@example.net", "+1.556", true},
+		{"contact.create", "e6a2b5d7-contact-4", "jane@example.com", "+1.556", false},
+	}
+	for _, test := range tests {
+		event, err := webhook.ParseEvent([]byte(fmt.Sprintf(payload, test.name, test.requestID, test.email, test.phone)))
+		if err != nil {
+			t.Fatalf("ParseEvent returned error: %v", err)
+		}
+		if got := contacts.observe("", event); test.changed != got {
+			t.Errorf("observe(%v %v) expected %v, got %v", test.name, test.email, test.changed, got)
+		}
+	}
+	for _, email := range contacts.emails {
+		if strings.Contains(email, "@") {
+			t.Errorf("observe expected the emails to be hashed, got %v", email)
+		}
+	}
+}
+
+func TestEvents_Security(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "security", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [{"events": ["zone_record.*"], "destinations": ["ops"], "ignore_actors": ["terraform"]}],
+		"sampling": [{"events": ["zone_record.*"], "rate": 2}],
+		"security": {"destination": "security"},
+		"silences": [{"id": "migration", "events": ["domain.*"], "starts_at": "2000-01-01T00:00:00Z", "ends_at": "2100-01-01T00:00:00Z"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops, security := &failingService{}, &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["security"] = security
+
+	payload := `{"name": "%s", "request_identifier": "f7b3c6e8-security-000000000%d", "data": %s, "actor": {"entity": "api_token", "pretty": "%s"}, "account": {"id": 1010, "display": "User"}}`
+	tests := []struct {
+		name, data, actor string
+		status            string
+	}{
+		// ignored by the routes
+		{"zone_record.create", `{"zone_record": {"id": 1, "zone_id": "example.com", "type": "NS", "content": "ns1.example.net"}}`, "terraform", ""},
+		// sampled, except the security events
+		{"zone_record.create", `{"zone_record": {"id": 2, "zone_id": "example.com", "type": "A", "content": "1.2.3.4"}}`, "jane", ""},
+		{"zone_record.update", `{"zone_record": {"id": 3, "zone_id": "example.com", "type": "NS", "content": "ns2.example.net"}}`, "jane", ""},
+		{"zone_record.update", `{"zone_record": {"id": 4, "zone_id": "example.com", "type": "A", "content": "1.2.3.5"}}`, "jane", "skipped;sampled"},
+		{"zone_record.update", `{"zone_record": {"id": 5, "zone_id": "example.com", "type": "A", "content": "1.2.3.6"}}`, "jane", ""},
+		// silenced
+		{"domain.transfer_lock_disable", `{"domain": {"id": 1, "name": "example.com"}}`, "jane", ""},
+		{"domain.create", `{"domain": {"id": 2, "name": "example.org"}}`, "jane", "skipped;silenced"},
+	}
+	for i, test := range tests {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, test.name, i, test.data, test.actor)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := test.status, response.Header().Get(headerProcessingStatus); want != got {
+			t.Errorf("POST /events %v %v expected status %q, got %q", test.name, test.data, want, got)
+		}
+	}
+	if want, got := 3, ops.sent; want != got {
+		t.Errorf("ops expected %v events delivered, got %v", want, got)
+	}
+	if want, got := 3, security.sent; want != got {
+		t.Errorf("security expected %v events delivered, got %v", want, got)
+	}
+
+	event, _ := webhook.ParseEvent([]byte(fmt.Sprintf(payload, tests[5].name, 5, tests[5].data, "jane")))
+	if want, got := "transfer lock disabled", server.routing.securityAnnotations(event, "security", nil)[securityAnnotation]; want != got {
+		t.Errorf("securityAnnotations expected %q, got %q", want, got)
+	}
+	if got := server.routing.securityAnnotations(event, "ops", nil); got != nil {
+		t.Errorf("securityAnnotations expected no annotation for ops, got %v", got)
+	}
+}
@@ -1,14 +1,16 @@
 package strillone
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/dnsimple/dnsimple-go/dnsimple"
 	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
 	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
 	"github.com/wunderlist/ttlcache"
 )
 
@@ -30,23 +32,223 @@ var (
 type Server struct {
 	mux          *httprouter.Router
 	webhookCache *ttlcache.Cache
+
+	// routingMu guards routing, that is replaced on configuration reload.
+	routingMu sync.RWMutex
+	routing   *routingTable
+
+	// adminMu serializes the configuration changes made with the admin API.
+	adminMu     sync.Mutex
+	configStore ConfigStore
+
+	stats *deliveryStats
+
+	// secrets resolves the secret references in the configuration.
+	secrets *Secrets
+
+	// cipher encrypts the credentials of the configuration saved with the admin API, optional.
+	cipher *Cipher
+
+	// ipLimiter and tenantLimiter rate limit the webhooks, by source IP and by tenant.
+	ipLimiter     *rateLimiter
+	tenantLimiter *rateLimiter
+
+	// replays tracks the webhooks seen in the replay window.
+	replays keyGuard
+
+	// duplicates tracks the content of the events seen in the deduplication window.
+	duplicates keyGuard
+
+	// silences stores the silences shared with the other instances, nil when they are in the configuration.
+	silences silenceStore
+
+	// elector elects the instance running the scheduled jobs, nil when the instance runs them.
+	elector LeaderElector
+
+	// unknownEvents remembers the unknown events already reported to the operators.
+	unknownEvents *unknownEvents
+
+	// queue stores the deliveries, nil when the deliveries are synchronous.
+	queue            Queue
+	queueMaxAttempts int
+
+	// pubsub publishes the deliveries to Pub/Sub, pushing them back to /pubsub with the pubsubToken.
+	pubsub      *PubSubTopic
+	pubsubToken string
+
+	// breakers are the circuit breakers of the destinations.
+	breakers *circuitBreakers
+
+	// outboundLimiter and batches rate limit the deliveries to the destinations.
+	outboundLimiter *rateLimiter
+	batches         *eventBatches
+
+	// aggregates collapses the bursts of records created in the zones.
+	aggregates *eventAggregates
+
+	// digests accumulates the events sent in periodic summaries.
+	digests *digests
+
+	// samples counts the sampled events, and the ones suppressed since the last summary.
+	samples *eventSamples
+
+	// volumes counts the events of the accounts and of the zones, to detect the anomalies.
+	volumes *eventVolumes
+
+	// streams are the live streams of the events of /stream.
+	streams *eventStreams
+
+	// drifts remembers the drifts of the zones already alerted.
+	drifts *driftAlerts
+
+	// registrar remembers the transfer lock and the WHOIS privacy of the registered domains.
+	registrar *registrarStates
+
+	// zoneRecords remembers the versions of the zone records, and contactEmails the emails of the contacts.
+	zoneRecords   *zoneRecordStates
+	contactEmails *contactEmailStates
+
+	// approvals are the events held for an approval.
+	approvals *approvals
+
+	// tagger returns the tags of the domains matched by the routes with tags, cached in tagsCache for tagsTTL.
+	tagger    DomainTagger
+	tagsCache detailsCache
+	tagsTTL   time.Duration
+
+	// history records the events and their delivery attempts, nil when disabled.
+	history History
+
+	// historyRetention removes the oldest events of the history, nil when they are kept forever.
+	historyRetention *HistoryRetention
+
+	// auditLog records the configuration changes, nil when disabled.
+	auditLog AuditLog
+
+	// archiveStore receives the archive objects of the events in archive, with the archivePrefix.
+	archiveStore  ArchiveStore
+	archivePrefix string
+	archive       archiveBuffer
+
+	// pool delivers the events concurrently, nil when the deliveries are synchronous.
+	pool *workerPool
+
+	// dryRun logs the messages instead of delivering them.
+	dryRun bool
+
+	// pipeline handles the webhooks, with the middlewares added after its stages.
+	pipeline    WebhookHandler
+	middlewares map[string][]Middleware
+
+	// devMode processes the webhooks already processed again, so that the sample payloads can be posted again.
+	devMode bool
+
+	// debug enables the diagnostics endpoints, started is the start time reported by them.
+	debug   bool
+	started time.Time
+
+	// drain tracks the in-flight webhooks, stop and workers the background processing.
+	drain    drain
+	stop     chan struct{}
+	stopOnce sync.Once
+	workers  sync.WaitGroup
 }
 
 // NewServer returns a new front-end web server that handles HTTP requests for the app.
-func NewServer() *Server {
+// The config is optional, and it can be nil.
+func NewServer(config *Config) *Server {
 	cache := ttlcache.NewCache(cacheTTL * time.Second)
 
 	router := httprouter.New()
 	server := &Server{
 		mux:          router,
 		webhookCache: cache,
+		routing:      &routingTable{config: &Config{}, services: map[string]MessagingService{}, allowlist: &ipAllowlist{}},
+		configStore:  &MemoryConfigStore{},
+		stats:        newDeliveryStats(),
+
+		ipLimiter:     newRateLimiter(),
+		tenantLimiter: newRateLimiter(),
+		replays:       newReplayGuard(),
+		duplicates:    newReplayGuard(),
+		unknownEvents: newUnknownEvents(),
+		breakers:      newCircuitBreakers(),
+
+		outboundLimiter: newRateLimiter(),
+		batches:         newEventBatches(),
+		aggregates:      newEventAggregates(),
+		digests:         newDigests(),
+		samples:         newEventSamples(),
+		volumes:         newEventVolumes(),
+		streams:         newEventStreams(),
+		drifts:          newDriftAlerts(),
+		registrar:       newRegistrarStates(),
+		zoneRecords:     newZoneRecordStates(),
+		contactEmails:   newContactEmailStates(),
+		approvals:       newApprovals(),
+		stop:            make(chan struct{}),
+		started:         time.Now(),
+	}
+	if config != nil {
+		if err := server.Reload(config); err != nil {
+			log.Error().Err(err).Msg("Error loading configuration")
+		}
 	}
 
+	server.pipeline = server.buildPipeline()
+
 	router.GET("/", server.Root)
-	router.POST("/slack/:slackAlpha/:slackBeta/:slackGamma", server.Slack)
+	router.POST("/events", server.inbound(server.Events))
+	router.POST("/t/:token/events", server.inbound(server.TenantEvents))
+	router.POST("/e/:environment/events", server.inbound(server.EnvironmentEvents))
+	router.POST("/slack/:slackAlpha/:slackBeta/:slackGamma", server.inbound(server.Slack))
+	router.POST("/commands/slack", server.SlackCommand)
+	router.POST("/interactions/slack", server.SlackInteraction)
+	router.POST("/events/slack", server.SlackEvents)
+	router.POST("/pubsub", server.PubSubPush)
+	router.GET("/feed", server.Feed)
+	router.GET("/feed/:tenant", server.Feed)
+	router.GET("/stream", server.Stream)
+	router.GET("/stream/:tenant", server.Stream)
+	server.registerAdminRoutes(router)
 	return server
 }
 
+// Reload replaces the routing rules and destinations with the ones in the config.
+//
+// Events that are being processed when Reload is called are delivered
+// using the configuration that was active when they were received.
+func (s *Server) Reload(config *Config) error {
+	routing, err := newRoutingTable(config, s.secrets)
+	if err != nil {
+		return err
+	}
+	if s.dryRun {
+		routing.withDryRun()
+	}
+
+	s.routingMu.Lock()
+	s.routing = routing
+	s.routingMu.Unlock()
+
+	log.Info().Int("destinations", len(config.Destinations)).Int("routes", len(config.Routes)).Msg("Configuration loaded")
+	return nil
+}
+
+// SetConfigStore sets the store where the configuration changes made with the admin API are persisted.
+// By default, the changes are kept in memory and lost on restart.
+func (s *Server) SetConfigStore(store ConfigStore) {
+	s.adminMu.Lock()
+	defer s.adminMu.Unlock()
+	s.configStore = store
+}
+
+func (s *Server) currentRouting() *routingTable {
+	s.routingMu.RLock()
+	defer s.routingMu.RUnlock()
+	return s.routing
+}
+
 // ServeHTTP implements http.Handler.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
@@ -55,52 +257,138 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // Root is the handler for the HTTP requests to /.
 // It returns a simple uptime message useful for monitoring.
 func (s *Server) Root(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	log.Printf("%s %s\n", r.Method, r.URL.RequestURI())
+	log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Request")
 	w.Header().Set("Content-type", "application/json")
 
 	fmt.Fprintln(w, fmt.Sprintf(`{"ping":"%v","what":"%s"}`, time.Now().Unix(), Program))
 }
 
-// Slack handles a request to publish a webhook to a Slack channel.
-func (s *Server) Slack(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	log.Printf("%s %s\n", r.Method, r.URL.RequestURI())
+// Events handles a request to publish a webhook to the destinations
+// matched by the configured routes.
+func (s *Server) Events(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Request")
 
-	if r.Method != "POST" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	s.handleWebhook(w, r, s.currentRouting())
+}
+
+// publish delivers the event to the destinations selected by the filter stage,
+// and adds it to the digests.
+func (s *Server) publish(p *Webhook) {
+	ctx, event, routing, names := p.Request.Context(), p.Event, p.routing, p.Destinations
+	stats := s.stats.Tenant(routing.name)
+
+	if len(names) == 0 {
+		s.addToDigests(routing, event, p.digests)
+		s.webhookCache.Set(routing.cacheKey(event), "1")
+		p.Response.Header().Set(headerProcessingStatus, webhookDigested)
+		p.respond(http.StatusOK, &WebhookStatus{Status: webhookDigested})
 		return
 	}
 
-	data, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		log.Printf("Error parsing body: %v\n", err)
+	if s.pubsub != nil {
+		if err := s.publishJobs(ctx, event, routing, names); err != nil {
+			p.fail(http.StatusInternalServerError, err)
+			return
+		}
+		s.addToDigests(routing, event, p.digests)
+		p.respondDestinations(http.StatusAccepted, headerProcessingQueued)
 		return
 	}
 
-	event, err := webhook.ParseEvent(data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		log.Printf("Error parsing event: %v\n", err)
+	if s.queue != nil {
+		enqueued, err := s.enqueue(event, routing, names)
+		if err != nil {
+			p.fail(http.StatusInternalServerError, err)
+			return
+		}
+		if enqueued == 0 {
+			withEvent(log.Info(), event).Str("tenant", routing.name).Str("outcome", "skipped").Msg("Skipping event: already queued")
+			p.skip("already-processed")
+			return
+		}
+		s.addToDigests(routing, event, p.digests)
+		p.respondDestinations(http.StatusAccepted, headerProcessingQueued)
 		return
 	}
 
-	// Check if the event was already processed
-	_, cacheExists := s.webhookCache.Get(event.RequestID)
-	if cacheExists {
-		log.Printf("Skipping event %v as already processed\n", event.RequestID)
-		w.Header().Set(headerProcessingStatus, "skipped;already-processed")
-		w.WriteHeader(http.StatusOK)
+	if s.pool != nil {
+		if err := s.accept(ctx, event, routing, names); err != nil {
+			p.fail(http.StatusServiceUnavailable, err)
+			return
+		}
+		s.addToDigests(routing, event, p.digests)
+		p.respondDestinations(http.StatusAccepted, headerProcessingAccepted)
+		return
+	}
+
+	response := &WebhookStatus{Status: webhookDelivered}
+	for i, name := range names {
+		text, err := s.deliver(ctx, routing, name, event)
+		if err != nil {
+			stats.failed()
+			s.forget(routing, event)
+			response.Status, response.Reason = webhookFailed, fmt.Sprintf("delivery to %s failed", name)
+			response.Destinations = append(response.Destinations, DestinationStatus{Name: name, Status: webhookFailed, Error: err.Error()})
+			for _, name := range names[i+1:] {
+				response.Destinations = append(response.Destinations, DestinationStatus{Name: name, Status: webhookNotAttempted})
+			}
+			p.respond(http.StatusInternalServerError, response)
+			return
+		}
+		stats.delivered()
+		response.Destinations = append(response.Destinations, DestinationStatus{Name: name, Status: webhookDelivered, Message: text})
+	}
+
+	s.addToDigests(routing, event, p.digests)
+	s.webhookCache.Set(routing.cacheKey(event), "1")
+	p.respond(http.StatusOK, response)
+}
+
+// enrich completes the details of the event with those fetched from the DNSimple API, within the enrichment timeout.
+func (s *Server) enrich(ctx context.Context, routing *routingTable, event *webhook.Event, details *eventDetails) {
+	ctx, cancel := routing.enrichmentContext(ctx)
+	defer cancel()
+	details.Domain = routing.enricher.enrich(ctx, event)
+	details.Account = routing.accounts.resolve(ctx, event)
+}
+
+// Slack handles a request to publish a webhook to a Slack channel.
+func (s *Server) Slack(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	log.Info().Str("method", r.Method).Str("path", r.URL.RequestURI()).Msg("Request")
+
+	routing := s.currentRouting()
+	event, details, ok := s.readEvent(w, r, routing)
+	if !ok {
 		return
 	}
 
 	slackAlpha, slackBeta, slackGamma := params.ByName("slackAlpha"), params.ByName("slackBeta"), params.ByName("slackGamma")
 	slackToken := fmt.Sprintf("%s/%s/%s", slackAlpha, slackBeta, slackGamma)
 
-	service := &SlackService{Token: slackToken}
-	text, err := service.PostEvent(event)
+	_, span := startDeliverSpan(r.Context(), routing.name, "slack", event)
+	slack := &SlackService{Token: slackToken}
+	if routing.formatting != nil {
+		routing.formatting.configure(slack)
+	}
+	service := withRetries(slack, nil)
+	s.recordEvent(routing, event)
+	s.archiveEvent(routing, event)
+	details.PreviousZoneRecord = s.zoneRecords.observe(routing.name, event)
+	s.enrich(r.Context(), routing, event, details)
+	event = routing.redaction.redact(withDetails(event, details))
+	ctx, cancel := routing.deliveryContext(r.Context())
+	defer cancel()
+	start := time.Now()
+	text, err := service.PostEvent(ctx, event)
+	logDelivery(event, routing.name, "slack", start, err)
+	s.recordAttempt(routing, "slack", event, deliveryStatus(err), start, err)
+	if err != nil {
+		recordError(span, err)
+	}
+	span.End()
 	if err != nil {
+		s.forget(routing, event)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		log.Printf("Internal Error: %v\n", err)
 		return
 	}
 
@@ -108,3 +396,307 @@ func (s *Server) Slack(w http.ResponseWriter, r *http.Request, params httprouter
 
 	fmt.Fprintln(w, text)
 }
+
+// routingTable is the routing state built from a Config.
+type routingTable struct {
+	// name is the tenant name, empty for the top-level configuration.
+	name     string
+	config   *Config
+	routes   []RouteConfig
+	services map[string]MessagingService
+
+	// tenants are the tenant routing tables, by token.
+	tenants map[string]*routingTable
+
+	// adminToken is the resolved admin token, and adminKeys the keys of the admin API, including the token.
+	adminToken string
+	adminKeys  []*adminKey
+
+	// feedToken is the resolved token of the readers of the feed.
+	feedToken string
+
+	// oidc logs the users in to the dashboard, if enabled.
+	oidc *oidcProvider
+
+	// signatureHeader and signingSecret are used to verify the webhook signatures.
+	signatureHeader string
+	signingSecret   string
+
+	// timestampHeader and replayWindow are used to reject the replayed webhooks.
+	timestampHeader string
+	replayWindow    time.Duration
+
+	// dedupWindow is the window in which the events with the same content are notified once.
+	dedupWindow time.Duration
+
+	// allowlist restricts the source of the webhooks.
+	allowlist *ipAllowlist
+
+	// credentials are the resolved credentials required on the webhook endpoints.
+	credentials inboundCredentials
+
+	// rateLimit limits the webhooks of the tenant.
+	rateLimit *RateLimitConfig
+
+	// formatting are the settings of the messages.
+	formatting *formatting
+
+	// api is the client of the DNSimple API, nil if not configured.
+	api *dnsimple.Client
+
+	// enricher completes the events with the details of their domain, nil without the API.
+	enricher *domainEnricher
+
+	// accounts resolves the names of the accounts of the events, nil without the API.
+	accounts *accountResolver
+
+	// commandsSecret is the resolved signing secret of the Slack slash command.
+	commandsSecret string
+
+	// approvalsSecret is the resolved signing secret of the Slack app receiving the approvals.
+	approvalsSecret string
+
+	// timeouts are the timeouts of the stages of the processing of the events.
+	timeouts *TimeoutsConfig
+
+	// redaction masks the personal data of the events in the messages.
+	redaction *redaction
+
+	// environments are the routing tables of the other DNSimple environments, by name.
+	environments map[string]*routingTable
+}
+
+func newRoutingTable(config *Config, secrets *Secrets) (*routingTable, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	formatting := &formatting{severities: config.Severities, dashboardURL: config.DashboardURL, presentation: config.Presentation,
+		severityPresentation: config.SeverityPresentation}
+	accountLabels, err := parseAccountLabels(config.Accounts)
+	if err != nil {
+		return nil, err
+	}
+	formatting.accountLabels = accountLabels
+	if config.Templates != "" {
+		templates, err := LoadMessageTemplates(config.Templates)
+		if err != nil {
+			return nil, fmt.Errorf("templates: %v", err)
+		}
+		formatting.templates = templates
+	}
+	catalogs, err := LoadTranslations(config.Translations)
+	if err != nil {
+		return nil, fmt.Errorf("translations: %v", err)
+	}
+	formatting.catalogs = catalogs
+
+	routing, err := buildRoutingTable("", config, formatting, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	if routing.adminToken, err = secrets.Resolve(config.Admin.Token); err != nil {
+		return nil, fmt.Errorf("admin: %v", err)
+	}
+	if routing.adminKeys, err = resolveAdminKeys(routing.adminToken, config.Admin.Keys, secrets); err != nil {
+		return nil, err
+	}
+	if routing.oidc, err = newOIDCProvider(config.Admin.OIDC, secrets); err != nil {
+		return nil, err
+	}
+
+	if routing.allowlist, err = newIPAllowlist(config.Inbound.AllowedIPs, config.Inbound.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("inbound: %v", err)
+	}
+
+	if routing.credentials, err = resolveInboundCredentials(config.Inbound, secrets); err != nil {
+		return nil, fmt.Errorf("inbound: %v", err)
+	}
+
+	client, err := newAPIClient(config.API, secrets)
+	if err != nil {
+		return nil, err
+	}
+	routing.api = client
+	if client != nil {
+		cache, err := newDetailsCache(config.API.Cache, secrets)
+		if err != nil {
+			return nil, err
+		}
+		routing.enricher = newDomainEnricher(client, cache, config.API.Cache.ttl())
+	}
+	routing.accounts = newAccountResolver(client)
+	if config.Commands != nil {
+		if routing.commandsSecret, err = secrets.Resolve(config.Commands.SigningSecret); err != nil {
+			return nil, fmt.Errorf("commands: %v", err)
+		}
+	}
+	if config.Approvals != nil {
+		if routing.approvalsSecret, err = secrets.Resolve(config.Approvals.SigningSecret); err != nil {
+			return nil, fmt.Errorf("approvals: %v", err)
+		}
+	}
+
+	routing.signatureHeader = config.Inbound.signatureHeader()
+	routing.timestampHeader = config.Inbound.timestampHeader()
+	routing.replayWindow = time.Duration(config.Inbound.ReplayWindow)
+	routing.dedupWindow = time.Duration(config.DedupWindow)
+	routing.timeouts = config.Timeouts
+	routing.redaction = newRedaction(config.Redaction)
+	if routing.signingSecret, err = secrets.Resolve(config.Inbound.SigningSecret); err != nil {
+		return nil, fmt.Errorf("inbound: %v", err)
+	}
+
+	routing.tenants = make(map[string]*routingTable, len(config.Tenants))
+	for _, t := range config.Tenants {
+		tenantFormatting := formatting
+		if t.DashboardURL != "" {
+			override := *formatting
+			override.dashboardURL = t.DashboardURL
+			tenantFormatting = &override
+		}
+		tenantConfig := t.routingConfig()
+		tenantConfig.Egress = config.Egress
+		tenant, err := buildRoutingTable(t.Name, tenantConfig, tenantFormatting, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %v", t.Name, err)
+		}
+
+		tenant.enricher = routing.enricher
+		tenant.accounts = routing.accounts
+		tenant.signatureHeader = routing.signatureHeader
+		tenant.timestampHeader = routing.timestampHeader
+		tenant.replayWindow = routing.replayWindow
+		tenant.dedupWindow = routing.dedupWindow
+		tenant.timeouts = routing.timeouts
+		tenant.redaction = routing.redaction
+		tenant.signingSecret = routing.signingSecret
+		if t.SigningSecret != "" {
+			if tenant.signingSecret, err = secrets.Resolve(t.SigningSecret); err != nil {
+				return nil, fmt.Errorf("tenant %q: %v", t.Name, err)
+			}
+		}
+		tenant.rateLimit = t.RateLimit
+		routing.tenants[t.Token] = tenant
+	}
+
+	routing.environments = make(map[string]*routingTable, len(config.Environments))
+	for i := range config.Environments {
+		environment, err := newEnvironmentTable(routing, &config.Environments[i], secrets)
+		if err != nil {
+			return nil, fmt.Errorf("environment %q: %v", config.Environments[i].Name, err)
+		}
+		routing.environments[config.Environments[i].Name] = environment
+	}
+
+	return routing, nil
+}
+
+func buildRoutingTable(name string, config *Config, formatting *formatting, secrets *Secrets) (*routingTable, error) {
+	services := make(map[string]MessagingService, len(config.Destinations))
+	for _, d := range config.Destinations {
+		url, err := secrets.Resolve(d.URL)
+		if err != nil {
+			return nil, fmt.Errorf("destination %q: %v", d.Name, err)
+		}
+		d.URL = url
+		if d.BotToken, err = secrets.Resolve(d.BotToken); err != nil {
+			return nil, fmt.Errorf("destination %q: %v", d.Name, err)
+		}
+		if len(d.Settings) > 0 {
+			settings := make(map[string]string, len(d.Settings))
+			for key, value := range d.Settings {
+				if settings[key], err = secrets.Resolve(value); err != nil {
+					return nil, fmt.Errorf("destination %q: %s: %v", d.Name, key, err)
+				}
+			}
+			d.Settings = settings
+		}
+
+		if err := config.Egress.checkDestinationHost(d.URL); err != nil {
+			return nil, fmt.Errorf("destination %q: %v", d.Name, err)
+		}
+		d.HTTP = config.Egress.apply(d.HTTP)
+
+		service, err := newDestinationService(d, formatting)
+		if err != nil {
+			return nil, fmt.Errorf("destination %q: %v", d.Name, err)
+		}
+		services[d.Name] = service
+	}
+
+	routing := &routingTable{name: name, config: config, routes: config.Routes, services: services, formatting: formatting}
+	if config.Feed != nil {
+		feedToken, err := secrets.Resolve(config.Feed.Token)
+		if err != nil {
+			return nil, fmt.Errorf("feed: %v", err)
+		}
+		routing.feedToken = feedToken
+	}
+	return routing, nil
+}
+
+// cacheKey returns the key of the event in the processed events cache.
+// Keys are scoped by tenant, so that each tenant processes its own copy of an event.
+func (t *routingTable) cacheKey(event *webhook.Event) string {
+	if t.name == "" {
+		return event.RequestID
+	}
+	return t.name + "/" + event.RequestID
+}
+
+// tenantByName returns the routing table of the tenant, or the table itself for an empty name.
+// It returns nil if the tenant doesn't exist.
+func (t *routingTable) tenantByName(name string) *routingTable {
+	if name == "" {
+		return t
+	}
+	for _, tenant := range t.tenants {
+		if tenant.name == name {
+			return tenant
+		}
+	}
+	return nil
+}
+
+// severity returns the severity of the event.
+func (t *routingTable) severity(eventName string) Severity {
+	if t.formatting == nil {
+		return SeverityConfig(nil).Of(eventName)
+	}
+	return t.formatting.severities.Of(eventName)
+}
+
+// routeMatches returns true if the route matches the event name, and its severity.
+func (t *routingTable) routeMatches(i int, eventName string) bool {
+	route := &t.routes[i]
+	if route.MinSeverity != "" && !t.severity(eventName).AtLeast(route.MinSeverity) {
+		return false
+	}
+	return route.Matches(eventName)
+}
+
+// Lookup returns the names of the destinations that should receive the event immediately,
+// without duplicates and in the order they are first referenced by the routes without tags.
+func (t *routingTable) Lookup(eventName string) []string {
+	return t.lookup(eventName, excludeTags(nil, nil))
+}
+
+// lookup returns the destinations of the event, without the routes excluded, if exclude is set.
+func (t *routingTable) lookup(eventName string, exclude func(route *RouteConfig) bool) []string {
+	var names []string
+	seen := map[string]bool{}
+	for i := range t.routes {
+		if t.routes[i].Digest > 0 || !t.routeMatches(i, eventName) || (exclude != nil && exclude(&t.routes[i])) {
+			continue
+		}
+		for _, name := range t.routes[i].Destinations {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
@@ -1,8 +1,10 @@
 package strillone
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -14,7 +16,7 @@ var (
 )
 
 func init() {
-	server = NewServer()
+	server = NewServer(nil)
 }
 
 func TestRoot(t *testing.T) {
@@ -37,7 +39,7 @@ func TestSlack(t *testing.T) {
 	request, _ := http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(payload))
 	response := httptest.NewRecorder()
 
-	server.Slack(response, request, httprouter.Params{})
+	server.ServeHTTP(response, request)
 
 	if want := http.StatusOK; want != response.Code {
 		t.Errorf("POST /slack expected HTTP %v, got %v", want, response.Code)
@@ -55,7 +57,7 @@ func TestSlackTwice(t *testing.T) {
 	request, _ := http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(payload))
 	response := httptest.NewRecorder()
 
-	server.Slack(response, request, httprouter.Params{})
+	server.ServeHTTP(response, request)
 
 	if want := http.StatusOK; want != response.Code {
 		t.Errorf("POST /slack expected HTTP %v, got %v", want, response.Code)
@@ -67,7 +69,7 @@ func TestSlackTwice(t *testing.T) {
 	requestDuplicate, _ := http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(payload))
 	responseDuplicate := httptest.NewRecorder()
 
-	server.Slack(responseDuplicate, requestDuplicate, httprouter.Params{})
+	server.ServeHTTP(responseDuplicate, requestDuplicate)
 
 	if want := http.StatusOK; want != responseDuplicate.Code {
 		t.Errorf("POST /slack (duplicate) expected HTTP %v, got %v", want, responseDuplicate.Code)
@@ -76,3 +78,76 @@ func TestSlackTwice(t *testing.T) {
 		t.Errorf("POST /slack (duplicate) X-Processing-Status expected %v, got %v", want, got)
 	}
 }
+
+func TestEvents(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/-"}],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.routing.services["ops"] = &SlackService{Token: "-"}
+
+	payload := `{"data": {"contact": {"id": 1}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "contact.create", "request_identifier": "1e4b1b0c-3a0f-4b5e-9a0d-events000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want, got := "skipped;no-route", response.Header().Get(headerProcessingStatus); want != got {
+		t.Errorf("POST /events X-Processing-Status expected %v, got %v", want, got)
+	}
+
+	payload = `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "1e4b1b0c-3a0f-4b5e-9a0d-events000002"}`
+	request, _ = http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	if want := http.StatusOK; want != response.Code {
+		t.Errorf("POST /events expected HTTP %v, got %v", want, response.Code)
+	}
+	var status WebhookStatus
+	if err := json.Unmarshal(response.Body.Bytes(), &status); err != nil {
+		t.Fatalf("POST /events returned invalid JSON: %v", err)
+	}
+	want := &WebhookStatus{
+		Status: "delivered",
+		Event:  &WebhookEventStatus{Name: "domain.create", RequestID: "1e4b1b0c-3a0f-4b5e-9a0d-events000002", Recognized: true},
+		Destinations: []DestinationStatus{{
+			Name:    "ops",
+			Status:  "delivered",
+			Message: "[<https://dnsimple.com/a/1010/account|User>] example@example.com created the domain <https://dnsimple.com/a/1010/domains/example.com|example.com>",
+		}},
+	}
+	if !reflect.DeepEqual(want, &status) {
+		t.Errorf("POST /events expected response\n\t%+v\ngot\n\t%+v", want, &status)
+	}
+}
+
+func TestServer_Reload(t *testing.T) {
+	server := NewServer(nil)
+
+	if names := server.currentRouting().Lookup("domain.create"); len(names) != 0 {
+		t.Fatalf("Lookup expected no destinations, got %v", names)
+	}
+
+	config := &Config{
+		Destinations: []DestinationConfig{{Name: "ops", Type: "slack", URL: "https://hooks.slack.com/services/X/Y/Z"}},
+		Routes:       []RouteConfig{{Destinations: []string{"ops"}}},
+	}
+	if err := server.Reload(config); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if want, got := []string{"ops"}, server.currentRouting().Lookup("domain.create"); !reflect.DeepEqual(want, got) {
+		t.Errorf("Lookup expected %v, got %v", want, got)
+	}
+
+	invalid := &Config{Routes: []RouteConfig{{Destinations: []string{"missing"}}}}
+	if err := server.Reload(invalid); err == nil {
+		t.Errorf("Reload expected error for invalid config")
+	}
+	if want, got := []string{"ops"}, server.currentRouting().Lookup("domain.create"); !reflect.DeepEqual(want, got) {
+		t.Errorf("Lookup after invalid reload expected %v, got %v", want, got)
+	}
+}
@@ -1,28 +1,240 @@
 package strillone
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
+	"strings"
+	"time"
 
-	"github.com/bluele/slack"
 	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
 )
 
 // MessagingService represents a service where the event is published.
 // Some examples are Slack, HipChat, and Campfire.
 type MessagingService interface {
-	FormatLink(name, url string) string
-	PostEvent(event *webhook.Event) (string, error)
+	Formatter
+	PostEvent(ctx context.Context, event *webhook.Event) (string, error)
+
+	// PostMessage publishes a message from Strillone itself, such as a health notification.
+	PostMessage(ctx context.Context, text string) error
 }
 
 // SlackService represents the Slack message service.
 type SlackService struct {
 	Token string
+
+	// URL is the full Slack incoming webhook URL.
+	// When set, it takes precedence over the Token.
+	URL string
+
+	// HTTPClient is the client used to send the messages.
+	// When nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Actors maps the emails of the actors, in lower case, to the Slack user IDs they are mentioned with.
+	Actors map[string]string
+
+	// Severities override the severities of the events, that set the color of the messages.
+	Severities SeverityConfig
+
+	// Mentions are the rules mentioning @here, @channel or user groups in the messages, none by default.
+	Mentions []MentionConfig
+
+	// Templates override the default messages, optional.
+	Templates *MessageTemplates
+
+	// Dashboard is the base URL of the DNSimple dashboard linked in the messages, optional.
+	Dashboard string
+
+	// Presentation overrides the emoji, color and icon of the messages, by event family,
+	// and SeverityPresentation by severity.
+	Presentation         map[string]PresentationConfig
+	SeverityPresentation map[Severity]PresentationConfig
+
+	// AccountLabels label the accounts of the events, by account ID, optional.
+	AccountLabels map[int64]string
+
+	// Environment tags the messages of the events of another DNSimple environment, such as the sandbox, optional.
+	Environment string
+
+	// Catalog translates the English messages in the language of the destination. When nil, the messages are in English.
+	Catalog map[string]string
+
+	// BotToken and Channel post the messages with the Slack Web API instead of the incoming webhook,
+	// threading the events about the same domain or certificate under the first message.
+	BotToken string
+	Channel  string
+
+	// APIURL is the base URL of the Slack Web API. When empty, https://slack.com/api is used.
+	APIURL string
+
+	// name is the name of the destination in the configuration.
+	name    string
+	threads *slackThreads
+
+	// correlations are the operations in progress, whose messages are updated when they complete.
+	correlations *correlations
+}
+
+// formatting are the settings of the messages shared by the destinations.
+type formatting struct {
+	severities   SeverityConfig
+	templates    *MessageTemplates
+	dashboardURL string
+	presentation map[string]PresentationConfig
+
+	// severityPresentation is the presentation of the messages by severity.
+	severityPresentation map[Severity]PresentationConfig
+
+	accountLabels map[int64]string
+
+	// environment tags the messages of another DNSimple environment, such as the sandbox.
+	environment string
+
+	// catalogs are the translation catalogs, by language. When nil, the languages are not checked.
+	catalogs map[string]map[string]string
+}
+
+// configure applies the settings to the Slack service.
+func (f *formatting) configure(s *SlackService) {
+	s.Severities, s.Templates, s.Dashboard, s.Presentation = f.severities, f.templates, f.dashboardURL, f.presentation
+	s.SeverityPresentation = f.severityPresentation
+	s.AccountLabels = f.accountLabels
+	s.Environment = f.environment
+}
+
+// newDestinationService returns the MessagingService for the destination configuration.
+func newDestinationService(d DestinationConfig, formatting *formatting) (MessagingService, error) {
+	if err := d.Retry.validate(); err != nil {
+		return nil, err
+	}
+	if err := d.CircuitBreaker.validate(); err != nil {
+		return nil, err
+	}
+	if err := d.RateLimit.validate(); err != nil {
+		return nil, err
+	}
+	if err := d.HTTP.validate(); err != nil {
+		return nil, err
+	}
+	if err := d.Aggregation.validate(); err != nil {
+		return nil, err
+	}
+	for i := range d.QuietWindows {
+		if err := d.QuietWindows[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var catalog map[string]string
+	if formatting.catalogs != nil {
+		var err error
+		if catalog, err = catalogLanguage(formatting.catalogs, d.Language); err != nil {
+			return nil, err
+		}
+	}
+	client, err := newHTTPClient(d.TLS, d.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	factory, ok := destinationFactory(d.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %q", d.Type)
+	}
+	destination, err := factory(d, &DestinationOptions{
+		Format:     &MessageFormat{formatting: formatting, catalog: catalog},
+		HTTPClient: client,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withRetries(newDestinationMessagingService(destination), d.Retry), nil
+}
+
+// newSlackDestination returns the Slack destination of the configuration.
+func newSlackDestination(d DestinationConfig, options *DestinationOptions) (Destination, error) {
+	if d.BotToken != "" && d.Channel == "" {
+		return nil, fmt.Errorf("missing channel")
+	}
+	if d.BotToken == "" && d.URL == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	if d.ThreadWindow < 0 {
+		return nil, fmt.Errorf("thread window must be positive")
+	}
+	for i := range d.Mentions {
+		if err := d.Mentions[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range d.Correlations {
+		if err := d.Correlations[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	actors := map[string]string{}
+	for email, userID := range d.Actors {
+		if userID == "" {
+			return nil, fmt.Errorf("missing Slack user ID for the actor %q", email)
+		}
+		actors[strings.ToLower(email)] = userID
+	}
+	service := &SlackService{
+		URL:        d.URL,
+		HTTPClient: options.HTTPClient,
+		Actors:     actors,
+		BotToken:   d.BotToken,
+		Channel:    d.Channel,
+		Mentions:   d.Mentions,
+		name:       d.Name,
+	}
+	options.Format.formatting.configure(service)
+	service.Catalog = options.Format.catalog
+	if d.BotToken != "" {
+		service.threads = newSlackThreads(time.Duration(d.ThreadWindow))
+		service.correlations = newCorrelations(d.Correlations)
+	}
+	return service, nil
 }
 
 // FormatLink implements MessagingService
 func (s *SlackService) FormatLink(name, url string) string {
-	return fmt.Sprintf("<%s|%s>", url, name)
+	return mrkdwnRenderer{}.FormatLink(name, url)
+}
+
+// Escape implements Renderer
+func (s *SlackService) Escape(text string) string {
+	return mrkdwnRenderer{}.Escape(text)
+}
+
+// Translate implements Translator
+func (s *SlackService) Translate(message string) string {
+	return s.Catalog[message]
+}
+
+// AccountLabel implements AccountLabeler
+func (s *SlackService) AccountLabel(accountID int64) (string, bool) {
+	label, ok := s.AccountLabels[accountID]
+	return label, ok
+}
+
+// DashboardURL implements DashboardLinker
+func (s *SlackService) DashboardURL() string {
+	return s.Dashboard
+}
+
+// MentionActor implements ActorMentioner
+func (s *SlackService) MentionActor(email string) (string, bool) {
+	userID, ok := s.Actors[strings.ToLower(email)]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("<@%s>", userID), true
 }
 
 // FormatMessage implements MessagingService
@@ -30,42 +242,230 @@ func (s *SlackService) FormatMessage(message string) string {
 	return message
 }
 
+// Name implements Destination
+func (s *SlackService) Name() string {
+	return s.name
+}
+
+// Format implements Destination
+func (s *SlackService) Format(event *webhook.Event) (string, error) {
+	text, err := s.Templates.render(s, event, s.Severities.Of(event.Name))
+	if err != nil {
+		return "", err
+	}
+	return s.withEnvironment(text), nil
+}
+
+// withEnvironment tags the text with the environment of the events, if any.
+func (s *SlackService) withEnvironment(text string) string {
+	if s.Environment != "" {
+		return fmt.Sprintf("[%s] %s", s.Escape(s.Environment), text)
+	}
+	return text
+}
+
 // PostEvent implements MessagingService
-func (s *SlackService) PostEvent(event *webhook.Event) (string, error) {
-	eventID := eventRequestID(event)
-	text := Message(s, event)
+func (s *SlackService) PostEvent(ctx context.Context, event *webhook.Event) (string, error) {
+	text := s.withEnvironment(s.Templates.Message(s, event, s.Severities.Of(event.Name)))
 
 	// Send the webhook to Logs
-	log.Printf("[event:%v] %s", eventID, text)
+	withEvent(log.Info(), event).Str("text", text).Msg("Event message")
+
+	return text, s.Deliver(ctx, event, text)
+}
+
+// Deliver implements Destination
+func (s *SlackService) Deliver(ctx context.Context, event *webhook.Event, text string) error {
+	if event == nil {
+		return s.PostMessage(ctx, text)
+	}
 
 	// Don't send to Slack
-	if s.Token[0] == '-' {
+	if s.dryRun() {
+		return nil
+	}
+
+	withEvent(log.Debug(), event).Str("url", s.webhookURL()).Msg("Sending event to Slack")
+	payload := s.eventPayload(ctx, event, text)
+
+	now := time.Now()
+	if key, op := s.correlations.complete(event, now); op != nil {
+		if err := s.completeOperation(ctx, payload, op); err != nil {
+			return err
+		}
+		s.correlations.forget(key)
+		return nil
+	}
+	ts, err := s.send(ctx, payload, slackThreadKey(event))
+	if err != nil {
+		return err
+	}
+	s.correlations.start(event, ts, payload.Text, now)
+	return nil
+}
+
+// eventPayload returns the Slack payload of the message of the event, with the annotations of the context.
+func (s *SlackService) eventPayload(ctx context.Context, event *webhook.Event, text string) *slackPayload {
+	severity := s.Severities.Of(event.Name)
+	p := eventPresentation(s.Presentation, s.SeverityPresentation, event.Name, severity)
+	blocks := slackEventBlocks(s, event, text, severity, p.Emoji)
+	if annotations := Annotations(ctx); len(annotations) > 0 {
+		blocks = append(blocks, slackAnnotationsBlock(s, annotations))
+	}
+	return newSlackPayload(slackNotification(text, slackMentions(s.Mentions, event.Name, severity)), blocks, p.Color, p.Icon)
+}
+
+// Preview implements Previewer. The replies in the threads and to the operations aren't previewed.
+func (s *SlackService) Preview(ctx context.Context, event *webhook.Event, text string) (*Preview, error) {
+	payload := s.eventPayload(ctx, event, text)
+	payload.Channel = s.Channel
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Preview{ContentType: "application/json", Body: body}, nil
+}
+
+// completeOperation replies to the message of the operation with the payload of the completing event,
+// and updates the message with the color and the details of the completion.
+func (s *SlackService) completeOperation(ctx context.Context, payload *slackPayload, op *operation) error {
+	client := s.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	apiURL := s.APIURL
+	if apiURL == "" {
+		apiURL = slackAPIURL
+	}
+
+	payload.Channel, payload.ThreadTS = s.Channel, op.ts
+	if _, err := postSlackAPI(ctx, client, apiURL, s.BotToken, payload); err != nil {
+		return err
+	}
+
+	update := *payload
+	update.ThreadTS, update.TS, update.Text = "", op.ts, op.text
+	if _, err := callSlackAPI(ctx, client, apiURL, "chat.update", s.BotToken, &update); err != nil {
+		log.Warn().Err(err).Str("destination", s.name).Str("ts", op.ts).Msg("Error updating the message of the operation")
+	}
+	return nil
+}
+
+// HealthCheck implements Destination
+//
+// With a bot token, the token is verified with auth.test. Otherwise, an empty message is posted
+// to the incoming webhook: Slack rejects it with a 400 when the webhook exists.
+func (s *SlackService) HealthCheck(ctx context.Context) error {
+	if s.dryRun() {
+		return nil
+	}
+	client := s.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	if s.BotToken != "" {
+		apiURL := s.APIURL
+		if apiURL == "" {
+			apiURL = slackAPIURL
+		}
+		return slackAuthTest(ctx, client, apiURL, s.BotToken)
+	}
+
+	err := postJSON(ctx, client, s.webhookURL(), map[string]string{})
+	if status, ok := err.(*statusError); ok && status.StatusCode == http.StatusBadRequest {
+		return nil
+	}
+	if err == nil {
+		return errors.New("unexpected response to an empty message")
+	}
+	return err
+}
+
+// PostMessage implements MessagingService
+func (s *SlackService) PostMessage(ctx context.Context, text string) error {
+	log.Info().Str("text", text).Msg("Message")
+
+	if s.dryRun() {
+		return nil
+	}
+	return s.post(ctx, newSlackPayload(text, slackTextBlocks("Strillone", text), "warning", defaultSlackIcon), "")
+}
+
+// PostApproval implements Approver
+func (s *SlackService) PostApproval(ctx context.Context, text, approvalID string, approve bool) (string, error) {
+	log.Info().Str("text", text).Str("approval_id", approvalID).Msg("Approval request")
+
+	if s.dryRun() {
 		return "", nil
 	}
+	return s.send(ctx, newSlackPayload(text, slackApprovalBlocks(s, text, approvalID, approve), "danger", defaultSlackIcon), "")
+}
 
-	slackWebhookURL := fmt.Sprintf("https://hooks.slack.com/services/%s", s.Token)
-	log.Printf("[event:%v] Sending event to slack %v\n", eventID, slackWebhookURL)
-
-	webhook := slack.NewWebHook(slackWebhookURL)
-	webhookErr := webhook.PostMessage(&slack.WebHookPostPayload{
-		Username: "DNSimple",
-		IconUrl:  "http://cl.ly/2t0u2Q380N3y/trusty.png",
-		Attachments: []*slack.Attachment{
-			{
-				Fallback: text,
-				Color:    "good",
-				Fields: []*slack.AttachmentField{
-					{
-						Title: event.Name,
-						Value: text,
-					},
-				},
-			},
-		},
-	})
-	if webhookErr != nil {
-		log.Printf("[event:%v] Error sending to slack: %v\n", eventID, webhookErr)
+// PostDetails implements DetailPoster. With a bot token, the details are posted in the thread
+// of the message, otherwise they follow the message, that Slack collapses when long.
+func (s *SlackService) PostDetails(ctx context.Context, text string, details []string) error {
+	log.Info().Str("text", text).Int("details", len(details)).Msg("Message")
+
+	if s.dryRun() {
+		return nil
+	}
+	if s.BotToken == "" {
+		blocks := append(slackTextBlocks("Strillone", text), slackDetailBlocks(details)...)
+		return s.post(ctx, newSlackPayload(text, blocks, "good", defaultSlackIcon), "")
 	}
 
-	return text, webhookErr
+	ts, err := s.send(ctx, newSlackPayload(text, slackTextBlocks("Strillone", text), "good", defaultSlackIcon), "")
+	if err != nil {
+		return err
+	}
+	reply := newSlackPayload(text, slackDetailBlocks(details), "good", defaultSlackIcon)
+	reply.ThreadTS = ts
+	return s.post(ctx, reply, "")
+}
+
+// dryRun returns true if the messages are only logged, for the /slack/-/-/- test endpoint.
+func (s *SlackService) dryRun() bool {
+	return s.URL == "" && s.BotToken == "" && (s.Token == "" || s.Token[0] == '-')
+}
+
+// post sends the message. With a bot token, the message is threaded under the last message
+// with the same thread key, when not empty.
+func (s *SlackService) post(ctx context.Context, payload *slackPayload, threadKey string) error {
+	_, err := s.send(ctx, payload, threadKey)
+	return err
+}
+
+// send posts the payload, and returns the timestamp of the message posted with the Web API.
+func (s *SlackService) send(ctx context.Context, payload *slackPayload, threadKey string) (string, error) {
+	client := s.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	if s.BotToken == "" {
+		return "", postJSON(ctx, client, s.webhookURL(), payload)
+	}
+
+	apiURL := s.APIURL
+	if apiURL == "" {
+		apiURL = slackAPIURL
+	}
+	now := time.Now()
+	payload.Channel = s.Channel
+	payload.ThreadTS = s.threads.get(threadKey, now)
+	ts, err := postSlackAPI(ctx, client, apiURL, s.BotToken, payload)
+	if err != nil {
+		return "", err
+	}
+	s.threads.touch(threadKey, ts, now)
+	return ts, nil
+}
+
+func (s *SlackService) webhookURL() string {
+	if s.BotToken != "" {
+		return "slack:" + s.Channel
+	}
+	if s.URL != "" {
+		return s.URL
+	}
+	return fmt.Sprintf("https://hooks.slack.com/services/%s", s.Token)
 }
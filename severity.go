@@ -0,0 +1,140 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+)
+
+// Severity is the importance of an event: info, warning or critical.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// severityRanks orders the severities, from the least to the most important.
+var severityRanks = map[Severity]int{SeverityInfo: 1, SeverityWarning: 2, SeverityCritical: 3}
+
+// AtLeast returns true if the severity is as important as the other severity, or more.
+// An empty severity is the lowest.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityRanks[s] >= severityRanks[other]
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("severity must be a string, e.g. \"critical\"")
+	}
+	if _, ok := severityRanks[Severity(value)]; !ok {
+		return fmt.Errorf("invalid severity %q, must be info, warning or critical", value)
+	}
+	*s = Severity(value)
+	return nil
+}
+
+// defaultSeverities are the severities of the events, by event name pattern.
+// The events not matched are info.
+var defaultSeverities = SeverityConfig{
+	"*.delete":                         SeverityWarning,
+	"account.user_remove":              SeverityWarning,
+	"certificate.auto_renewal_disable": SeverityWarning,
+	"certificate.auto_renewal_failed":  SeverityCritical,
+	"certificate.remove_private_key":   SeverityWarning,
+	"dnssec.delete":                    SeverityCritical,
+	"domain.auto_renewal_disable":      SeverityWarning,
+	"domain.delegation_change":         SeverityWarning,
+	"domain.delete":                    SeverityCritical,
+	"domain.registrant_change":         SeverityWarning,
+	"domain.resolution_disable":        SeverityCritical,
+	"domain.transfer_lock_disable":     SeverityCritical,
+	"domain.transfer_out":              SeverityCritical,
+	"push.initiate":                    SeverityWarning,
+	"zone.delete":                      SeverityCritical,
+}
+
+// SeverityConfig maps the event name patterns, using the path.Match syntax, to severities.
+// When several patterns match, the most specific one applies: the exact name, then the longest pattern.
+type SeverityConfig map[string]Severity
+
+// Of returns the severity of the event: the configured one, the default one otherwise.
+func (c SeverityConfig) Of(eventName string) Severity {
+	if severity, ok := c.lookup(eventName); ok {
+		return severity
+	}
+	if severity, ok := defaultSeverities.lookup(eventName); ok {
+		return severity
+	}
+	return SeverityInfo
+}
+
+func (c SeverityConfig) lookup(eventName string) (Severity, bool) {
+	if severity, ok := c[eventName]; ok {
+		return severity, true
+	}
+	var match string
+	for pattern := range c {
+		if ok, _ := path.Match(pattern, eventName); !ok {
+			continue
+		}
+		if len(pattern) > len(match) || (len(pattern) == len(match) && pattern < match) {
+			match = pattern
+		}
+	}
+	if match == "" {
+		return "", false
+	}
+	return c[match], true
+}
+
+func (c SeverityConfig) validate() error {
+	for pattern, severity := range c {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid event pattern %q", pattern)
+		}
+		if _, ok := severityRanks[severity]; !ok {
+			return fmt.Errorf("%s: invalid severity %q", pattern, severity)
+		}
+	}
+	return nil
+}
+
+// severityColor returns the color of the messages with the severity.
+func severityColor(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return "danger"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "good"
+	}
+}
+
+// severitySound returns the sound of the push notifications of the severity, named after the Pushover sounds.
+func severitySound(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return "siren"
+	case SeverityWarning:
+		return "intermission"
+	default:
+		return "pushover"
+	}
+}
+
+// severityEmoji returns the emoji marking the messages with the severity, empty for info.
+func severityEmoji(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return ":rotating_light:"
+	case SeverityWarning:
+		return ":warning:"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,107 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestSeverityConfig_Of(t *testing.T) {
+	severities := SeverityConfig{"zone_record.*": SeverityWarning, "zone_record.delete": SeverityCritical, "domain.delete": SeverityInfo}
+
+	tests := []struct {
+		eventName string
+		want      Severity
+	}{
+		{"domain.create", SeverityInfo},
+		{"domain.delete", SeverityInfo},
+		{"zone.delete", SeverityCritical},
+		{"contact.delete", SeverityWarning},
+		{"zone_record.create", SeverityWarning},
+		{"zone_record.delete", SeverityCritical},
+	}
+	for _, test := range tests {
+		if got := severities.Of(test.eventName); test.want != got {
+			t.Errorf("Of(%v) expected %v, got %v", test.eventName, test.want, got)
+		}
+	}
+
+	if want, got := SeverityCritical, SeverityConfig(nil).Of("domain.delete"); want != got {
+		t.Errorf("Of(domain.delete) without overrides expected %v, got %v", want, got)
+	}
+}
+
+func TestParseConfig_Severities(t *testing.T) {
+	if _, err := ParseConfig([]byte(`{"severities": {"domain.*": "urgent"}}`)); err == nil {
+		t.Errorf("ParseConfig with an invalid severity expected error")
+	}
+	if _, err := ParseConfig([]byte(`{"severities": {"[": "critical"}}`)); err == nil {
+		t.Errorf("ParseConfig with an invalid pattern expected error")
+	}
+}
+
+func TestRoutingTable_LookupMinSeverity(t *testing.T) {
+	routing := &routingTable{
+		routes: []RouteConfig{
+			{Destinations: []string{"ops"}},
+			{Destinations: []string{"pager"}, MinSeverity: SeverityCritical},
+		},
+		formatting: &formatting{severities: SeverityConfig{"zone_record.delete": SeverityCritical}},
+	}
+
+	tests := []struct {
+		eventName string
+		want      string
+	}{
+		{"domain.create", "ops"},
+		{"domain.delete", "ops,pager"},
+		{"zone_record.delete", "ops,pager"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(routing.Lookup(test.eventName), ","); test.want != got {
+			t.Errorf("Lookup(%v) expected %v, got %v", test.eventName, test.want, got)
+		}
+	}
+}
+
+func TestSlackService_PostEventSeverity(t *testing.T) {
+	var payload slackPayload
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid Slack payload: %v", err)
+		}
+	}))
+	defer target.Close()
+
+	service := &SlackService{URL: target.URL, Mentions: []MentionConfig{{MinSeverity: SeverityCritical, Mention: "here"}}}
+	event, _ := webhook.ParseEvent([]byte(`{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.delete"}`))
+	if _, err := service.PostEvent(context.Background(), event); err != nil {
+		t.Fatalf("PostEvent returned error: %v", err)
+	}
+	if want, got := "<!here> ", payload.Text; !strings.HasPrefix(got, want) {
+		t.Errorf("PostEvent expected text %v, got %v", want, got)
+	}
+	if want, got := "danger", payload.Attachments[0].Color; want != got {
+		t.Errorf("PostEvent expected color %v, got %v", want, got)
+	}
+	if want, got := ":rotating_light: domain.delete", payload.Attachments[0].Blocks[0].Text.Text; want != got {
+		t.Errorf("PostEvent expected title %v, got %v", want, got)
+	}
+
+	service.Severities = SeverityConfig{"domain.delete": SeverityInfo}
+	payload = slackPayload{}
+	if _, err := service.PostEvent(context.Background(), event); err != nil {
+		t.Fatalf("PostEvent returned error: %v", err)
+	}
+	if want, got := "good", payload.Attachments[0].Color; want != got {
+		t.Errorf("PostEvent with override expected color %v, got %v", want, got)
+	}
+	if strings.HasPrefix(payload.Text, "<!here>") {
+		t.Errorf("PostEvent with override expected no notification, got %v", payload.Text)
+	}
+}
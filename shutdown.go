@@ -0,0 +1,115 @@
+package strillone
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// drain tracks the in-flight webhooks, to wait for them on shutdown.
+type drain struct {
+	mu       sync.Mutex
+	closing  bool
+	inflight int
+	drained  chan struct{}
+}
+
+// enter registers an in-flight webhook. It returns false when shutting down.
+func (d *drain) enter() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closing {
+		return false
+	}
+	d.inflight++
+	return true
+}
+
+// leave unregisters an in-flight webhook.
+func (d *drain) leave() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inflight--
+	if d.closing && d.inflight == 0 {
+		close(d.drained)
+	}
+}
+
+// close rejects the new webhooks, and waits for the in-flight ones or the end of the context.
+func (d *drain) close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closing {
+		d.closing = true
+		d.drained = make(chan struct{})
+		if d.inflight == 0 {
+			close(d.drained)
+		}
+	}
+	drained := d.drained
+	d.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops accepting webhooks, and waits for the in-flight ones to be delivered.
+// Then it stops the background processing, waits for the worker pool, sends the pending batches,
+// aggregates, digests and held events, uploads the last archived events, and closes the history, the audit log
+// and the queue, whose pending jobs are delivered on the next start.
+//
+// Shutdown returns the context error if the context ends first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.drain.close(ctx); err != nil {
+		return err
+	}
+
+	s.stopOnce.Do(func() { close(s.stop) })
+	workers := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(workers)
+	}()
+	select {
+	case <-workers:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if s.pool != nil {
+		if err := s.pool.close(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.flushAllBatches()
+	s.flushAllAggregates()
+	s.sendDigests(s.digests.all())
+	s.sendSamplingSummaries(s.samples.due(time.Now(), true))
+	if s.archiveStore != nil {
+		if err := s.flushArchive(ctx, time.Now()); err != nil {
+			log.Error().Err(err).Msg("Error archiving the last events")
+		}
+	}
+
+	if s.history != nil {
+		if err := s.history.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing the history")
+		}
+	}
+	if s.auditLog != nil {
+		if err := s.auditLog.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing the audit log")
+		}
+	}
+	if s.queue != nil {
+		log.Info().Msg("Closing the queue")
+		return s.queue.Close()
+	}
+	return nil
+}
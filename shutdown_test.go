@@ -0,0 +1,92 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+type blockingService struct {
+	SlackService
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingService) PostEvent(_ context.Context, event *webhook.Event) (string, error) {
+	close(s.started)
+	<-s.release
+	return "ok", nil
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	service := &blockingService{started: make(chan struct{}), release: make(chan struct{})}
+	server.routing.services["ops"] = service
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "%s"}`
+	post := func(requestID string) int {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, requestID)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	inflight := make(chan int)
+	go func() { inflight <- post("6b3d9e2f-shutdown-0000-000000000001") }()
+	<-service.started
+
+	shutdown := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- server.Shutdown(ctx)
+	}()
+
+	// wait for the shutdown to start
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.drain.isClosing() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if want, got := http.StatusServiceUnavailable, post("6b3d9e2f-shutdown-0000-000000000002"); want != got {
+		t.Errorf("POST /events during shutdown expected HTTP %v, got %v", want, got)
+	}
+
+	close(service.release)
+	if want, got := http.StatusOK, <-inflight; want != got {
+		t.Errorf("in-flight POST /events expected HTTP %v, got %v", want, got)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown returned error: %v", err)
+	}
+}
+
+func (d *drain) isClosing() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closing
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	server := NewServer(nil)
+	server.drain.enter()
+	defer server.drain.leave()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
@@ -0,0 +1,55 @@
+package strillone
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const defaultSignatureHeader = "X-DNSimple-Signature"
+
+var (
+	errSignatureMissing = errors.New("missing webhook signature")
+	errSignatureInvalid = errors.New("invalid webhook signature")
+)
+
+// SignPayload returns the signature of the webhook payload:
+// the hex-encoded HMAC-SHA256 of the payload with the secret.
+func SignPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks the signature of the webhook payload,
+// when a signing secret is configured.
+//
+// With the replay protection enabled, the timestamp is signed together with the payload,
+// as "<timestamp>.<payload>", so that it can't be changed.
+func (t *routingTable) verifySignature(r *http.Request, payload []byte) error {
+	if t.signingSecret == "" {
+		return nil
+	}
+
+	signature := r.Header.Get(t.signatureHeader)
+	if signature == "" {
+		return errSignatureMissing
+	}
+	signature = strings.TrimPrefix(signature, "sha256=")
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return errSignatureInvalid
+	}
+	if t.replayWindow > 0 {
+		payload = append([]byte(r.Header.Get(t.timestampHeader)+"."), payload...)
+	}
+	expected, _ := hex.DecodeString(SignPayload(t.signingSecret, payload))
+	if !hmac.Equal(given, expected) {
+		return errSignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package strillone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	server := NewServer(&Config{Inbound: InboundConfig{SigningSecret: "shared-secret"}})
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "5d8e1f6a-signature-0000-000000000001"}`
+
+	tests := []struct {
+		signature string
+		status    int
+	}{
+		{"", http.StatusUnauthorized},
+		{"not-hex", http.StatusUnauthorized},
+		{SignPayload("wrong-secret", []byte(payload)), http.StatusUnauthorized},
+		{SignPayload("shared-secret", []byte(payload)), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		request, _ := http.NewRequest("POST", "/slack/-/-/-", strings.NewReader(payload))
+		if tt.signature != "" {
+			request.Header.Set("X-DNSimple-Signature", tt.signature)
+		}
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want, got := tt.status, response.Code; want != got {
+			t.Errorf("POST /slack with signature %q expected HTTP %v, got %v", tt.signature, want, got)
+		}
+	}
+}
+
+func TestSignature_Prefix(t *testing.T) {
+	routing := &routingTable{signingSecret: "shared-secret", signatureHeader: "X-Signature"}
+	payload := []byte(`{}`)
+
+	request, _ := http.NewRequest("POST", "/events", nil)
+	request.Header.Set("X-Signature", "sha256="+SignPayload("shared-secret", payload))
+	if err := routing.verifySignature(request, payload); err != nil {
+		t.Errorf("verifySignature returned error: %v", err)
+	}
+}
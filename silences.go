@@ -0,0 +1,258 @@
+package strillone
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// Silence suppresses the notifications of the matching events during a period, such as a planned migration.
+type Silence struct {
+	ID string `json:"id"`
+
+	// Events are the event name patterns matched by the silence, using the path.Match syntax (e.g. "zone_record.*").
+	// An empty list matches every event.
+	Events []string `json:"events,omitempty"`
+
+	// Domains are the domain or zone name patterns matched by the silence, using the path.Match syntax.
+	// An empty list matches every domain.
+	Domains []string `json:"domains,omitempty"`
+
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+
+	// CreatedBy is the name of the API key that created the silence, like the actors of the audit log.
+	CreatedBy string `json:"created_by,omitempty"`
+
+	// ClaimedBy and Comment describe who asked for the silence and why, as given by the client, optional.
+	ClaimedBy string `json:"claimed_by,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+func (s *Silence) validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("silence: missing id")
+	}
+	if len(s.Events) == 0 && len(s.Domains) == 0 {
+		return fmt.Errorf("silence %s: missing events or domains", s.ID)
+	}
+	for _, pattern := range append(append([]string{}, s.Events...), s.Domains...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("silence %s: invalid pattern %q", s.ID, pattern)
+		}
+	}
+	if s.EndsAt.IsZero() || !s.StartsAt.Before(s.EndsAt) {
+		return fmt.Errorf("silence %s: ends_at must be after starts_at", s.ID)
+	}
+	return nil
+}
+
+// active returns true if the silence is active at now.
+func (s *Silence) active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// matches returns true if the silence suppresses the event.
+func (s *Silence) matches(e *webhook.Event) bool {
+	if len(s.Events) > 0 && !matchesAny(s.Events, e.Name) {
+		return false
+	}
+	return len(s.Domains) == 0 || matchesAny(s.Domains, strings.ToLower(eventDomain(e)))
+}
+
+// silenced returns the active silence suppressing the event at now, nil if none.
+func silenced(silences []Silence, e *webhook.Event, now time.Time) *Silence {
+	for i := range silences {
+		if silences[i].active(now) && silences[i].matches(e) {
+			return &silences[i]
+		}
+	}
+	return nil
+}
+
+// silenceRequest is the body of a new silence, lasting until ends_at or for the duration.
+type silenceRequest struct {
+	Silence
+	Duration Duration `json:"duration,omitempty"`
+}
+
+// APIListSilences returns the active and the future silences, by start.
+func (s *Server) APIListSilences(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	now := time.Now()
+	silences := []Silence{}
+	for _, silence := range s.currentSilences() {
+		if now.Before(silence.EndsAt) {
+			silences = append(silences, silence)
+		}
+	}
+	sort.Slice(silences, func(i, j int) bool { return silences[i].StartsAt.Before(silences[j].StartsAt) })
+	writeJSON(w, http.StatusOK, silences)
+}
+
+// APICreateSilence adds a silence, and removes the expired ones.
+func (s *Server) APICreateSilence(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	request := silenceRequest{}
+	if !readJSON(w, r, &request) {
+		return
+	}
+	silence := request.Silence
+	now := time.Now().UTC()
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	if silence.EndsAt.IsZero() && request.Duration > 0 {
+		silence.EndsAt = silence.StartsAt.Add(time.Duration(request.Duration))
+	}
+	if silence.ClaimedBy == "" {
+		silence.ClaimedBy = r.Header.Get(headerAuditActor)
+	}
+	silence.CreatedBy = requestActor(r)
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	silence.ID = hex.EncodeToString(id)
+
+	err := s.updateSilences(adminAudit(r, "silence.create", silence.ID), func(existing []Silence) ([]Silence, error) {
+		silences := []Silence{}
+		for _, other := range existing {
+			if now.Before(other.EndsAt) {
+				silences = append(silences, other)
+			}
+		}
+		return append(silences, silence), nil
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, silence)
+}
+
+// APIDeleteSilence removes a silence, ending it immediately.
+func (s *Server) APIDeleteSilence(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id := params.ByName("id")
+	err := s.updateSilences(adminAudit(r, "silence.delete", id), func(silences []Silence) ([]Silence, error) {
+		for i := range silences {
+			if silences[i].ID == id {
+				return append(silences[:i], silences[i+1:]...), nil
+			}
+		}
+		return nil, &adminError{http.StatusNotFound, "silence not found"}
+	})
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// silenceStore stores the silences shared by the instances, instead of the configuration.
+type silenceStore interface {
+	list() ([]Silence, error)
+
+	// replace replaces the silences before with the silences after.
+	replace(before, after []Silence) error
+}
+
+// currentSilences returns the silences, from the shared store when enabled, from the configuration otherwise.
+// The silences can't be read from the store on errors, and nil is returned.
+func (s *Server) currentSilences() []Silence {
+	if s.silences == nil {
+		return s.currentRouting().config.Silences
+	}
+	silences, err := s.silences.list()
+	if err != nil {
+		log.Warn().Err(err).Msg("Error reading the silences")
+		return nil
+	}
+	return silences
+}
+
+// updateSilences changes the silences, in the shared store when enabled, in the configuration otherwise.
+func (s *Server) updateSilences(entry *AuditEntry, change func(silences []Silence) ([]Silence, error)) error {
+	if s.silences == nil {
+		return s.updateConfig(entry, func(config *Config) error {
+			silences, err := change(config.Silences)
+			config.Silences = silences
+			return err
+		})
+	}
+
+	s.adminMu.Lock()
+	defer s.adminMu.Unlock()
+
+	before, err := s.silences.list()
+	if err != nil {
+		return err
+	}
+	after, err := change(append([]Silence{}, before...))
+	if err != nil {
+		return err
+	}
+	for i := range after {
+		if err := after[i].validate(); err != nil {
+			return &adminError{http.StatusUnprocessableEntity, err.Error()}
+		}
+	}
+	if err := s.silences.replace(before, after); err != nil {
+		return err
+	}
+	s.audit(entry, &Config{Silences: before}, &Config{Silences: after})
+	return nil
+}
+
+// redisSilences is a silenceStore in Redis, with the silences in a hash by ID.
+type redisSilences struct {
+	client *redisClient
+}
+
+const redisSilencesKey = "strillone:silences"
+
+func (r *redisSilences) list() ([]Silence, error) {
+	reply, err := r.client.do("HVALS", redisSilencesKey)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := reply.([]interface{})
+	silences := make([]Silence, 0, len(values))
+	for _, value := range values {
+		var silence Silence
+		if err := json.Unmarshal(value.([]byte), &silence); err != nil {
+			return nil, err
+		}
+		silences = append(silences, silence)
+	}
+	return silences, nil
+}
+
+func (r *redisSilences) replace(before, after []Silence) error {
+	var commands [][]string
+	kept := make(map[string]bool, len(after))
+	for _, silence := range after {
+		data, err := json.Marshal(&silence)
+		if err != nil {
+			return err
+		}
+		kept[silence.ID] = true
+		commands = append(commands, []string{"HSET", redisSilencesKey, silence.ID, string(data)})
+	}
+	for _, silence := range before {
+		if !kept[silence.ID] {
+			commands = append(commands, []string{"HDEL", redisSilencesKey, silence.ID})
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	_, err := r.client.transaction(commands...)
+	return err
+}
@@ -0,0 +1,86 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI_Silences(t *testing.T) {
+	server, store := newAdminTestServer()
+	adminRequest(server, "POST", "/admin/destinations", `{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/X/Y/Z"}`)
+	adminRequest(server, "POST", "/admin/routes", `{"name": "all", "destinations": ["ops"]}`)
+	ops := &failingService{}
+	server.routing.services["ops"] = ops
+
+	response := adminRequest(server, "POST", "/api/silences", `{"events": ["zone_record.*"], "domains": ["*.example.com"], "duration": "2h", "comment": "migration", "claimed_by": "alice"}`)
+	if want := http.StatusCreated; want != response.Code {
+		t.Fatalf("POST /api/silences expected HTTP %v, got %v: %v", want, response.Code, response.Body)
+	}
+	var silence Silence
+	if err := json.Unmarshal(response.Body.Bytes(), &silence); err != nil {
+		t.Fatalf("POST /api/silences returned invalid JSON: %v", err)
+	}
+	if want, got := 2*time.Hour, silence.EndsAt.Sub(silence.StartsAt); want != got {
+		t.Errorf("POST /api/silences expected a silence of %v, got %v", want, got)
+	}
+	if silence.CreatedBy != sharedTokenActor || silence.ClaimedBy != "alice" {
+		t.Errorf("POST /api/silences expected to be created by %v, claimed by alice, got %v, %v", sharedTokenActor, silence.CreatedBy, silence.ClaimedBy)
+	}
+	for _, body := range []string{`{"duration": "1h"}`, `{"events": ["domain.*"]}`, `{"events": ["[domain"], "duration": "1h"}`} {
+		response := adminRequest(server, "POST", "/api/silences", body)
+		if want := http.StatusUnprocessableEntity; want != response.Code {
+			t.Errorf("POST /api/silences %s expected HTTP %v, got %v", body, want, response.Code)
+		}
+	}
+	ops = &failingService{}
+	server.routing.services["ops"] = ops
+
+	payload := `{"name": "%s", "request_identifier": "f6a7b8c9-silence-0000-00000000000%d", "data": %s, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	tests := []struct {
+		name   string
+		data   string
+		status string
+	}{
+		{"zone_record.create", `{"zone_record": {"id": 1, "zone_id": "eu.example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}`, "skipped;silenced"},
+		{"zone_record.create", `{"zone_record": {"id": 2, "zone_id": "example.org", "type": "A", "name": "www", "content": "1.2.3.4"}}`, ""},
+		{"zone.create", `{"zone": {"id": 3, "name": "eu.example.com"}}`, ""},
+	}
+	for i, tt := range tests {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, tt.name, i, tt.data)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := tt.status, response.Header().Get(headerProcessingStatus); want != got {
+			t.Errorf("POST /events %v %v expected status %q, got %q", tt.name, tt.data, want, got)
+		}
+	}
+	if want, got := 2, ops.sent; want != got {
+		t.Errorf("expected %v events delivered, got %v", want, got)
+	}
+
+	response = adminRequest(server, "GET", "/api/silences", "")
+	var silences []Silence
+	if err := json.Unmarshal(response.Body.Bytes(), &silences); err != nil {
+		t.Fatalf("GET /api/silences returned invalid JSON: %v", err)
+	}
+	if want, got := 1, len(silences); want != got {
+		t.Fatalf("GET /api/silences expected %v silences, got %v", want, got)
+	}
+
+	response = adminRequest(server, "DELETE", "/api/silences/"+silence.ID, "")
+	if want := http.StatusNoContent; want != response.Code {
+		t.Errorf("DELETE /api/silences/:id expected HTTP %v, got %v", want, response.Code)
+	}
+	response = adminRequest(server, "DELETE", "/api/silences/"+silence.ID, "")
+	if want := http.StatusNotFound; want != response.Code {
+		t.Errorf("DELETE /api/silences/:id (deleted) expected HTTP %v, got %v", want, response.Code)
+	}
+	saved, _ := store.Load()
+	if want, got := 0, len(saved.Silences); want != got {
+		t.Errorf("expected %v persisted silences, got %v", want, got)
+	}
+}
@@ -0,0 +1,165 @@
+package strillone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+const (
+	// slackAPIURL is the base URL of the Slack Web API.
+	slackAPIURL = "https://slack.com/api"
+
+	// defaultSlackThreadWindow is how long the events are threaded under the last message about
+	// the same domain or certificate.
+	defaultSlackThreadWindow = 24 * time.Hour
+
+	// maxSlackThreads is the number of threads remembered by destination.
+	maxSlackThreads = 10000
+)
+
+// slackThreads remembers the messages starting a thread, by domain or certificate.
+// A thread is continued until it is idle for the window.
+type slackThreads struct {
+	mu      sync.Mutex
+	window  time.Duration
+	threads map[string]*slackThread
+	order   []string
+}
+
+type slackThread struct {
+	ts      string
+	updated time.Time
+}
+
+func newSlackThreads(window time.Duration) *slackThreads {
+	if window <= 0 {
+		window = defaultSlackThreadWindow
+	}
+	return &slackThreads{window: window, threads: map[string]*slackThread{}}
+}
+
+// get returns the timestamp of the message starting the thread, empty if there is no active thread.
+func (t *slackThreads) get(key string, now time.Time) string {
+	if t == nil || key == "" {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	thread, ok := t.threads[key]
+	if !ok || now.Sub(thread.updated) > t.window {
+		return ""
+	}
+	return thread.ts
+}
+
+// touch records a message in the thread, started by the message with the timestamp ts
+// when the thread is new.
+func (t *slackThreads) touch(key, ts string, now time.Time) {
+	if t == nil || key == "" || ts == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if thread, ok := t.threads[key]; ok && now.Sub(thread.updated) <= t.window {
+		thread.updated = now
+		return
+	}
+	if _, ok := t.threads[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.threads[key] = &slackThread{ts: ts, updated: now}
+	for len(t.order) > maxSlackThreads {
+		delete(t.threads, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// slackThreadKey returns the key of the thread of the event: its certificate, or its domain.
+// It returns an empty key for the events that are not threaded.
+func slackThreadKey(e *webhook.Event) string {
+	if e.Account == nil {
+		return ""
+	}
+	if data, ok := eventData(e).(*CertificateEvent); ok {
+		return fmt.Sprintf("%d/certificate/%d", e.Account.ID, data.Certificate.ID)
+	}
+	if domain := eventDomain(e); domain != "" {
+		return fmt.Sprintf("%d/domain/%s", e.Account.ID, domain)
+	}
+	return ""
+}
+
+// slackAPIResponse is the response of the Slack Web API methods.
+type slackAPIResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	TS    string `json:"ts"`
+}
+
+// postSlackAPI calls the chat.postMessage method of the Slack Web API, and returns the timestamp of the message.
+func postSlackAPI(ctx context.Context, client *http.Client, apiURL, token string, payload *slackPayload) (string, error) {
+	result, err := callSlackAPI(ctx, client, apiURL, "chat.postMessage", token, payload)
+	if err != nil {
+		return "", err
+	}
+	return result.TS, nil
+}
+
+// slackAuthTest calls the auth.test method of the Slack Web API, verifying the token.
+func slackAuthTest(ctx context.Context, client *http.Client, apiURL, token string) error {
+	_, err := callSlackAPI(ctx, client, apiURL, "auth.test", token, struct{}{})
+	return err
+}
+
+// callSlackAPI calls the method of the Slack Web API with the JSON payload.
+func callSlackAPI(ctx context.Context, client *http.Client, apiURL, method, token string, payload interface{}) (*slackAPIResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := string(data)
+		if len(data) == 0 {
+			message = resp.Status
+		}
+		return nil, &statusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			message:    message,
+		}
+	}
+
+	var result slackAPIResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("slack: invalid response: %v", err)
+	}
+	if !result.OK {
+		return nil, fmt.Errorf("slack: %s", result.Error)
+	}
+	return &result, nil
+}
@@ -0,0 +1,102 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestSlackService_PostEventThreads(t *testing.T) {
+	var payloads []slackPayload
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, got := "/chat.postMessage", r.URL.Path; want != got {
+			t.Errorf("expected request to %v, got %v", want, got)
+		}
+		if want, got := "Bearer xoxb-token", r.Header.Get("Authorization"); want != got {
+			t.Errorf("expected Authorization %v, got %v", want, got)
+		}
+		var payload slackPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid Slack payload: %v", err)
+		}
+		payloads = append(payloads, payload)
+		if payload.Channel == "#missing" {
+			fmt.Fprint(w, `{"ok": false, "error": "channel_not_found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ok": true, "ts": "1600000000.00000%d"}`, len(payloads))
+	}))
+	defer target.Close()
+
+	service, err := newDestinationService(DestinationConfig{Name: "ops", Type: "slack", BotToken: "xoxb-token", Channel: "#ops"}, &formatting{})
+	if err != nil {
+		t.Fatalf("newDestinationService returned error: %v", err)
+	}
+	slack := service.(*retryingService).MessagingService.(*SlackService)
+	slack.APIURL = target.URL
+
+	events := []string{
+		`{"name": "zone_record.create", "data": {"zone_record": {"id": 1, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}}`,
+		`{"name": "zone_record.create", "data": {"zone_record": {"id": 2, "zone_id": "example.com", "type": "A", "name": "api", "content": "1.2.3.4"}}}`,
+		`{"name": "domain.create", "data": {"domain": {"id": 1, "name": "example.org"}}}`,
+		`{"name": "zone_record.delete", "data": {"zone_record": {"id": 1, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}}`,
+	}
+	for _, payload := range events {
+		event, err := webhook.ParseEvent([]byte(payload[:len(payload)-1] + `, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, payload)
+		}
+		if _, err := slack.PostEvent(context.Background(), event); err != nil {
+			t.Fatalf("PostEvent returned error: %v", err)
+		}
+	}
+
+	var threads []string
+	for _, payload := range payloads {
+		threads = append(threads, payload.ThreadTS)
+		if want, got := "#ops", payload.Channel; want != got {
+			t.Errorf("PostEvent expected channel %v, got %v", want, got)
+		}
+	}
+	if want, got := fmt.Sprint([]string{"", "1600000000.000001", "", "1600000000.000001"}), fmt.Sprint(threads); want != got {
+		t.Errorf("PostEvent expected threads %v, got %v", want, got)
+	}
+
+	slack.Channel = "#missing"
+	if err := slack.PostMessage(context.Background(), "test"); err == nil || err.Error() != "slack: channel_not_found" {
+		t.Errorf("PostMessage expected channel_not_found error, got %v", err)
+	}
+}
+
+func TestSlackThreads_Window(t *testing.T) {
+	threads := newSlackThreads(time.Hour)
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	threads.touch("example.com", "1", now)
+	threads.touch("example.com", "2", now.Add(50*time.Minute))
+	if want, got := "1", threads.get("example.com", now.Add(100*time.Minute)); want != got {
+		t.Errorf("get expected the thread continued by the last message %v, got %v", want, got)
+	}
+	if want, got := "", threads.get("example.com", now.Add(200*time.Minute)); want != got {
+		t.Errorf("get expected the idle thread to expire, got %v", got)
+	}
+	threads.touch("example.com", "3", now.Add(200*time.Minute))
+	if want, got := "3", threads.get("example.com", now.Add(200*time.Minute)); want != got {
+		t.Errorf("get expected a new thread %v, got %v", want, got)
+	}
+}
+
+func TestNewDestinationService_SlackBotToken(t *testing.T) {
+	if _, err := newDestinationService(DestinationConfig{Type: "slack", BotToken: "xoxb-token"}, &formatting{}); err == nil {
+		t.Errorf("newDestinationService with a bot token and no channel expected error")
+	}
+	if _, err := newDestinationService(DestinationConfig{Type: "slack", BotToken: "xoxb-token", Channel: "#ops", ThreadWindow: -1}, &formatting{}); err == nil {
+		t.Errorf("newDestinationService with a negative thread window expected error")
+	}
+}
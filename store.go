@@ -0,0 +1,77 @@
+package strillone
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ConfigStore persists the configuration managed with the admin API.
+type ConfigStore interface {
+	Load() (*Config, error)
+	Save(config *Config) error
+}
+
+// MemoryConfigStore is a ConfigStore that keeps the configuration in memory.
+type MemoryConfigStore struct {
+	mu     sync.Mutex
+	config *Config
+}
+
+// Load implements ConfigStore
+func (s *MemoryConfigStore) Load() (*Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config == nil {
+		return &Config{}, nil
+	}
+	return s.config.Clone(), nil
+}
+
+// Save implements ConfigStore
+func (s *MemoryConfigStore) Save(config *Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config.Clone()
+	return nil
+}
+
+// FileConfigStore is a ConfigStore that writes the configuration to a JSON file.
+type FileConfigStore struct {
+	Path string
+}
+
+// Load implements ConfigStore
+func (s *FileConfigStore) Load() (*Config, error) {
+	return LoadConfig(s.Path)
+}
+
+// Save implements ConfigStore
+//
+// The file is replaced atomically, so that a concurrent reload never reads a partial file.
+func (s *FileConfigStore) Save(config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
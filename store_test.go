@@ -0,0 +1,33 @@
+package strillone
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileConfigStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &FileConfigStore{Path: filepath.Join(dir, "config.json")}
+	config := &Config{
+		Destinations: []DestinationConfig{{Name: "ops", Type: "slack", URL: "https://hooks.slack.com/services/X/Y/Z"}},
+		Routes:       []RouteConfig{{Name: "all", Destinations: []string{"ops"}}},
+	}
+	if err := store.Save(config); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if want, got := "all", loaded.Routes[0].Name; want != got {
+		t.Errorf("Load expected route %v, got %v", want, got)
+	}
+}
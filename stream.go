@@ -0,0 +1,322 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxStreamSubscribers is the number of concurrent streams.
+	maxStreamSubscribers = 100
+
+	// streamBuffer is the number of events buffered for a slow stream, before they are dropped.
+	streamBuffer = 64
+
+	// streamKeepalive is the period of the comments keeping the idle streams open through the proxies.
+	streamKeepalive = 15 * time.Second
+
+	// streamRetry is the delay before the clients reconnect, as sent to them.
+	streamRetry = time.Second
+
+	// streamMargin is how long before the write timeout of the HTTP server the streams end.
+	streamMargin = 5 * time.Second
+)
+
+// streamEvent is an event pushed to the streams.
+type streamEvent struct {
+	Tenant     string          `json:"tenant"`
+	Name       string          `json:"name"`
+	RequestID  string          `json:"request_identifier"`
+	AccountID  int64           `json:"account_id,omitempty"`
+	Domain     string          `json:"domain,omitempty"`
+	Actor      string          `json:"actor,omitempty"`
+	Severity   Severity        `json:"severity"`
+	Message    string          `json:"message"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// streamSubscriber is a client of the stream of a tenant, receiving the events matching its filter.
+type streamSubscriber struct {
+	tenant string
+
+	// filter is a route without destinations, matching the events like the routes.
+	filter RouteConfig
+
+	events chan *streamEvent
+}
+
+// matches returns true if the subscriber receives the event, with the tags of its domain.
+func (sub *streamSubscriber) matches(routing *routingTable, event *webhook.Event, tags []string) bool {
+	filter := &sub.filter
+	if filter.MinSeverity != "" && !routing.severity(event.Name).AtLeast(filter.MinSeverity) {
+		return false
+	}
+	return filter.Matches(event.Name) && filter.matchesTags(tags) && filter.matchesActor(event.Actor)
+}
+
+// eventStreams are the live streams of the events.
+type eventStreams struct {
+	mu          sync.Mutex
+	subscribers map[*streamSubscriber]bool
+
+	// writeTimeout is the write timeout of the HTTP server, 0 if none.
+	writeTimeout time.Duration
+
+	// closed is closed when the HTTP server shuts down.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newEventStreams() *eventStreams {
+	return &eventStreams{subscribers: map[*streamSubscriber]bool{}, closed: make(chan struct{})}
+}
+
+// subscribe adds a subscriber to the events of the tenant matching the filter.
+func (e *eventStreams) subscribe(tenant string, filter RouteConfig) (*streamSubscriber, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	select {
+	case <-e.closed:
+		return nil, fmt.Errorf("shutting down")
+	default:
+	}
+	if len(e.subscribers) >= maxStreamSubscribers {
+		return nil, fmt.Errorf("too many streams")
+	}
+	sub := &streamSubscriber{tenant: tenant, filter: filter, events: make(chan *streamEvent, streamBuffer)}
+	e.subscribers[sub] = true
+	return sub, nil
+}
+
+func (e *eventStreams) unsubscribe(sub *streamSubscriber) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.subscribers, sub)
+}
+
+// tenantSubscribers returns the subscribers of the tenant.
+func (e *eventStreams) tenantSubscribers(tenant string) []*streamSubscriber {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var subscribers []*streamSubscriber
+	for sub := range e.subscribers {
+		if sub.tenant == tenant {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	return subscribers
+}
+
+// duration returns how long a stream lasts before the clients reconnect, 0 if unlimited.
+func (e *eventStreams) duration() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case e.writeTimeout <= 0:
+		return 0
+	case e.writeTimeout > 2*streamMargin:
+		return e.writeTimeout - streamMargin
+	default:
+		return e.writeTimeout / 2
+	}
+}
+
+// SetStreamTimeout sets the write timeout of the HTTP server serving the streams of /stream,
+// so that they end before it, and the clients reconnect. The streams are unlimited by default.
+func (s *Server) SetStreamTimeout(writeTimeout time.Duration) {
+	s.streams.mu.Lock()
+	defer s.streams.mu.Unlock()
+	s.streams.writeTimeout = writeTimeout
+}
+
+// CloseStreams ends the streams of /stream, which the HTTP server doesn't wait for when it shuts down,
+// such as with http.Server.RegisterOnShutdown.
+func (s *Server) CloseStreams() {
+	s.streams.closeOnce.Do(func() { close(s.streams.closed) })
+}
+
+// publishEvent pushes the event to the matching streams of the tenant. The events aren't delayed for
+// the slow streams: their events are dropped when their buffer is full.
+func (s *Server) publishEvent(ctx context.Context, routing *routingTable, event *webhook.Event) {
+	subscribers := s.streams.tenantSubscribers(routing.name)
+	if len(subscribers) == 0 {
+		return
+	}
+
+	var tags []string
+	for _, sub := range subscribers {
+		if len(sub.filter.Tags) > 0 {
+			tags = s.domainTags(ctx, event)
+			break
+		}
+	}
+
+	var published *streamEvent
+	for _, sub := range subscribers {
+		if !sub.matches(routing, event, tags) {
+			continue
+		}
+		if published == nil {
+			published = newStreamEvent(routing, event, time.Now())
+		}
+		select {
+		case sub.events <- published:
+		default:
+			withEvent(log.Warn(), event).Str("tenant", routing.name).Msg("Dropping the event of a slow stream")
+		}
+	}
+}
+
+// newStreamEvent returns the event pushed to the streams, with the personal data masked like in the messages.
+func newStreamEvent(routing *routingTable, event *webhook.Event, now time.Time) *streamEvent {
+	event = routing.redaction.redact(event)
+	published := &streamEvent{
+		Tenant:     routing.name,
+		Name:       event.Name,
+		RequestID:  event.RequestID,
+		Domain:     eventDomain(event),
+		Severity:   routing.severity(event.Name),
+		ReceivedAt: now.UTC(),
+		Payload:    event.GetPayload(),
+	}
+	if event.Account != nil {
+		published.AccountID = event.Account.ID
+	}
+	if event.Actor != nil {
+		published.Actor = event.Actor.Pretty
+	}
+	if text, err := formatMessage(textRenderer{}, event); err == nil {
+		published.Message = text
+	}
+	return published
+}
+
+// streamField returns the value without the line breaks, which would end the field of the event.
+func streamField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// parseStreamFilter returns the filter of the query parameters, with the syntax of the routes:
+// events, min_severity, tags, actors and ignore_actors, the lists separated by commas or repeated.
+func parseStreamFilter(query url.Values) (RouteConfig, error) {
+	list := func(name string) []string {
+		var values []string
+		for _, param := range query[name] {
+			for _, value := range strings.Split(param, ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					values = append(values, value)
+				}
+			}
+		}
+		return values
+	}
+
+	filter := RouteConfig{
+		Events:       list("events"),
+		MinSeverity:  Severity(query.Get("min_severity")),
+		Tags:         list("tags"),
+		Actors:       list("actors"),
+		IgnoreActors: list("ignore_actors"),
+	}
+	if _, ok := severityRanks[filter.MinSeverity]; filter.MinSeverity != "" && !ok {
+		return filter, fmt.Errorf("invalid min severity %q", filter.MinSeverity)
+	}
+	for name, patterns := range map[string][]string{"event": filter.Events, "actor": filter.Actors, "ignored actor": filter.IgnoreActors} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return filter, fmt.Errorf("invalid %s pattern %q", name, pattern)
+			}
+		}
+	}
+	return filter, nil
+}
+
+// Stream pushes the events of the tenant as they are received, as Server-Sent Events,
+// filtered by the query parameters like the routes. The readers authenticate like the feed.
+func (s *Server) Stream(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Don't log the request URI, as it may contain the feed token.
+	log.Info().Str("method", r.Method).Str("path", r.URL.Path).Msg("Request")
+
+	routing := s.currentRouting()
+	keys := routing.adminKeys
+	routing = routing.tenantByName(params.ByName("tenant"))
+	if routing == nil || (routing.config.Feed == nil && len(keys) == 0) {
+		http.NotFound(w, r)
+		return
+	}
+	if !routing.feedAllows(r, keys) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="strillone"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	filter, err := parseStreamFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, err := s.streams.subscribe(routing.name, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer s.streams.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Disable the buffering of the proxies, such as nginx.
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	flusher.Flush()
+
+	var end <-chan time.Time
+	if duration := s.streams.duration(); duration > 0 {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		end = timer.C
+	}
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streams.closed:
+			return
+		case <-end:
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case event := <-sub.events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Error().Err(err).Str("tenant", routing.name).Str("request_id", event.RequestID).Msg("Error encoding the streamed event")
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", streamField(event.RequestID), streamField(event.Name), data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package strillone
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Stream(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"admin": {"keys": [{"name": "portal", "token": "viewer-token", "role": "viewer"}]},
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [],
+		"tenants": [{"name": "team-a", "token": "tenant-a-inbound-token", "destinations": [], "routes": [], "feed": {"token": "team-a-feed-token"}}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/stream", http.StatusUnauthorized},
+		{"/stream?token=team-a-feed-token", http.StatusUnauthorized},
+		{"/stream/team-b?token=viewer-token", http.StatusNotFound},
+		{"/stream?token=viewer-token&min_severity=loud", http.StatusBadRequest},
+		{"/stream?token=viewer-token&events=[domain", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		response, err := httpServer.Client().Get(httpServer.URL + test.path)
+		if err != nil {
+			t.Fatalf("GET %v returned error: %v", test.path, err)
+		}
+		response.Body.Close()
+		if want, got := test.status, response.StatusCode; want != got {
+			t.Errorf("GET %v expected HTTP %v, got %v", test.path, want, got)
+		}
+	}
+
+	// open the stream, and wait for the subscription
+	open := func(path string) (*bufio.Reader, io.Closer) {
+		response, err := httpServer.Client().Get(httpServer.URL + path)
+		if err != nil {
+			t.Fatalf("GET %v returned error: %v", path, err)
+		}
+		if want, got := "text/event-stream", response.Header.Get("Content-Type"); want != got {
+			t.Fatalf("GET %v expected Content-Type %v, got %v", path, want, got)
+		}
+		reader := bufio.NewReader(response.Body)
+		if line, _ := reader.ReadString('\n'); line != "retry: 1000\n" {
+			t.Fatalf("GET %v expected the retry delay first, got %q", path, line)
+		}
+		reader.ReadString('\n')
+		return reader, response.Body
+	}
+	// read the next event
+	next := func(reader *bufio.Reader) (string, *streamEvent) {
+		var id string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("stream returned error: %v", err)
+			}
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimSpace(strings.TrimPrefix(line, "id: "))
+			case strings.HasPrefix(line, "data: "):
+				event := &streamEvent{}
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), event); err != nil {
+					t.Fatalf("stream returned an invalid event: %v", err)
+				}
+				return id, event
+			}
+		}
+	}
+
+	query := url.Values{"token": {"viewer-token"}, "events": {"zone_record.*,contact.*"}, "ignore_actors": {"terraform"}}
+	stream, body := open("/stream?" + query.Encode())
+	defer body.Close()
+	tenantStream, tenantBody := open("/stream/team-a?token=team-a-feed-token")
+	defer tenantBody.Close()
+
+	payload := `{"name": "%s", "request_identifier": "c1e6f9a2-stream-000000000%d", "data": %s, "actor": {"entity": "api_token", "pretty": "%s"}, "account": {"id": 1010, "display": "User"}}`
+	events := []struct {
+		path, name, data, actor string
+	}{
+		{"/events", "domain.create", `{"domain": {"id": 1, "name": "example.com"}}`, "jane"},
+		{"/events", "zone_record.create", `{"zone_record": {"id": 1, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}`, "terraform"},
+		{"/t/tenant-a-inbound-token/events", "domain.create", `{"domain": {"id": 2, "name": "example.org"}}`, "jane"},
+		{"/events", "contact.update", `{"contact": {"id": 2, "label": "Main", "email": "jane@example.com", "phone": "+1.5555555555"}}`, "jane"},
+	}
+	for i, event := range events {
+		request := httptest.NewRequest("POST", event.path, strings.NewReader(fmt.Sprintf(payload, event.name, i, event.data, event.actor)))
+		server.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	id, event := next(stream)
+	if want, got := "c1e6f9a2-stream-0000000003", id; want != got {
+		t.Errorf("stream expected the event %v, got %v", want, got)
+	}
+	if want, got := "contact.update", event.Name; want != got {
+		t.Errorf("stream expected the event %v, got %v", want, got)
+	}
+	if want, got := int64(1010), event.AccountID; want != got {
+		t.Errorf("stream expected the account %v, got %v", want, got)
+	}
+	if strings.Contains(event.Message, "5555") || strings.Contains(string(event.Payload), "5555") {
+		t.Errorf("stream expected the personal data to be masked, got %v %s", event.Message, event.Payload)
+	}
+
+	if _, event := next(tenantStream); event.Tenant != "team-a" || event.Domain != "example.org" {
+		t.Errorf("stream of the tenant expected its event, got %+v", event)
+	}
+
+	server.CloseStreams()
+	done := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(stream)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("stream expected to end when closed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream didn't end when closed")
+	}
+}
+
+func TestServer_StreamTimeout(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"admin": {"token": "secret"}, "destinations": [], "routes": []}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.SetStreamTimeout(200 * time.Millisecond)
+
+	request := httptest.NewRequest("GET", "/stream?token=secret", nil)
+	response := httptest.NewRecorder()
+	start := time.Now()
+	server.ServeHTTP(response, request)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stream expected to end before the write timeout, lasted %v", elapsed)
+	}
+	if want, got := "retry: 1000\n\n", response.Body.String(); want != got {
+		t.Errorf("stream expected %q, got %q", want, got)
+	}
+}
+
+func Test_parseStreamFilter(t *testing.T) {
+	query, _ := url.ParseQuery("events=domain.*,zone_record.*&events=contact.update&min_severity=warning&tags=production&actors=jane*&ignore_actors=terraform,+external-dns")
+	filter, err := parseStreamFilter(query)
+	if err != nil {
+		t.Fatalf("parseStreamFilter returned error: %v", err)
+	}
+	want := RouteConfig{
+		Events:       []string{"domain.*", "zone_record.*", "contact.update"},
+		MinSeverity:  SeverityWarning,
+		Tags:         []string{"production"},
+		Actors:       []string{"jane*"},
+		IgnoreActors: []string{"terraform", "external-dns"},
+	}
+	if fmt.Sprint(want) != fmt.Sprint(filter) {
+		t.Errorf("parseStreamFilter expected %+v, got %+v", want, filter)
+	}
+	if want, got := "abc", streamField("a\r\nb\nc"); want != got {
+		t.Errorf("streamField expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,160 @@
+package strillone
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+)
+
+// syntheticActor is the actor of the synthetic events, so that their messages can't be
+// mistaken for real changes.
+const syntheticActor = "strillone test-event"
+
+// SyntheticEventOptions represents the synthetic event to generate.
+type SyntheticEventOptions struct {
+	// Name is the name of the event, such as domain.delete.
+	Name string
+
+	// Domain is the domain of the event. Defaults to example.com.
+	Domain string
+
+	// AccountID is the account of the event. Defaults to 1010.
+	AccountID int64
+}
+
+// SyntheticEvent returns a realistic payload of the event, with the shape of the webhooks
+// DNSimple sends, to verify the routes and the destinations without changing a real domain.
+func SyntheticEvent(options *SyntheticEventOptions) ([]byte, error) {
+	return syntheticEvent(options, time.Now())
+}
+
+func syntheticEvent(options *SyntheticEventOptions, now time.Time) ([]byte, error) {
+	if !knownEvents[options.Name] {
+		return nil, fmt.Errorf("unknown event %q", options.Name)
+	}
+	domain := options.Domain
+	if domain == "" {
+		domain = "example.com"
+	}
+	accountID := options.AccountID
+	if accountID == 0 {
+		accountID = 1010
+	}
+
+	payload := map[string]interface{}{
+		"name":               options.Name,
+		"api_version":        "v2",
+		"request_identifier": syntheticRequestID(),
+		"data":               syntheticData(options.Name, domain, accountID, now),
+		"account": map[string]interface{}{
+			"id":         accountID,
+			"display":    "Test account",
+			"identifier": "test",
+		},
+		"actor": map[string]interface{}{
+			"id":     "0",
+			"entity": "user",
+			"pretty": syntheticActor,
+		},
+	}
+	return json.Marshal(payload)
+}
+
+// syntheticData returns the data of the event, with the resources of its family.
+func syntheticData(name, domainName string, accountID int64, now time.Time) map[string]interface{} {
+	created := now.Add(-30 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	updated := now.UTC().Format(time.RFC3339)
+	domain := &dnsimple.Domain{
+		ID: 1, AccountID: accountID, RegistrantID: 2, Name: domainName, UnicodeName: domainName,
+		State: "registered", AutoRenew: true, ExpiresAt: now.AddDate(1, 0, 0).UTC().Format(time.RFC3339),
+		CreatedAt: created, UpdatedAt: updated,
+	}
+	contact := &dnsimple.Contact{
+		ID: 2, AccountID: accountID, Label: "Main", FirstName: "Jane", LastName: "Doe",
+		Email: "jane@" + domainName, Phone: "+1.5555555555", Address1: "1 Main Street", City: "Jacksonville",
+		StateProvince: "FL", PostalCode: "32202", Country: "US", CreatedAt: created, UpdatedAt: updated,
+	}
+
+	switch eventFamily(name) {
+	case "account":
+		return map[string]interface{}{
+			"account":            &dnsimple.Account{ID: accountID, Email: "ops@" + domainName, PlanIdentifier: "teams-v1-monthly", CreatedAt: created, UpdatedAt: updated},
+			"user":               &dnsimple.User{ID: 3, Email: "john@" + domainName},
+			"account_invitation": &dnsimple.AccountInvitation{ID: 4, Email: "john@" + domainName, AccountID: accountID, CreatedAt: created, UpdatedAt: updated},
+		}
+	case "certificate":
+		return map[string]interface{}{
+			"certificate": &dnsimple.Certificate{
+				ID: 5, DomainID: domain.ID, ContactID: contact.ID, CommonName: "www." + domainName,
+				AlternateNames: []string{"www." + domainName, domainName}, Years: 1, State: "issued",
+				AuthorityIdentifier: "letsencrypt", AutoRenew: true, CreatedAt: created, UpdatedAt: updated,
+				ExpiresAt: now.Add(90 * 24 * time.Hour).UTC().Format(time.RFC3339),
+			},
+		}
+	case "contact":
+		return map[string]interface{}{"contact": contact}
+	case "dnssec":
+		return map[string]interface{}{
+			"domain": domain,
+			"delegation_signer_record": &dnsimple.DelegationSignerRecord{
+				ID: 6, DomainID: domain.ID, Algorithm: "13", DigestType: "2", Keytag: "2371",
+				Digest: "EF1D343203E03F1C98120646971F7B96806B759B66622F0A224551DA1A1EFC9A", CreatedAt: created, UpdatedAt: updated,
+			},
+		}
+	case "domain":
+		data := map[string]interface{}{"domain": domain}
+		switch name {
+		case "domain.delegation_change":
+			data["name_servers"] = []string{"ns1.dnsimple.com", "ns2.dnsimple-edge.net", "ns3.dnsimple.com", "ns4.dnsimple-edge.org"}
+		case "domain.registrant_change":
+			data["registrant"] = contact
+		}
+		return data
+	case "email_forward":
+		return map[string]interface{}{
+			"email_forward": &dnsimple.EmailForward{ID: 7, DomainID: domain.ID, From: "hello@" + domainName, To: "jane@example.org", CreatedAt: created, UpdatedAt: updated},
+		}
+	case "name_server":
+		return map[string]interface{}{
+			"name_server": &dnsimple.VanityNameServer{ID: 8, Name: "ns1." + domainName, IPv4: "192.0.2.53", IPv6: "2001:db8::53", CreatedAt: created, UpdatedAt: updated},
+		}
+	case "push":
+		return map[string]interface{}{
+			"domain": domain,
+			"push":   &dnsimple.DomainPush{ID: 9, DomainID: domain.ID, ContactID: contact.ID, AccountID: accountID, CreatedAt: created, UpdatedAt: updated},
+		}
+	case "subscription":
+		return map[string]interface{}{"subscription": &Subscription{ID: 10, PlanName: "Teams", State: "subscribed"}}
+	case "webhook":
+		return map[string]interface{}{"webhook": &dnsimple.Webhook{ID: 11, URL: "https://hooks." + domainName + "/dnsimple"}}
+	case "whois_privacy":
+		return map[string]interface{}{
+			"domain":        domain,
+			"whois_privacy": &dnsimple.WhoisPrivacy{ID: 12, DomainID: domain.ID, Enabled: true, ExpiresOn: now.AddDate(1, 0, 0).UTC().Format(certificateDateFormat), CreatedAt: created, UpdatedAt: updated},
+		}
+	case "zone":
+		return map[string]interface{}{
+			"zone": &dnsimple.Zone{ID: 13, AccountID: accountID, Name: domainName, CreatedAt: created, UpdatedAt: updated},
+		}
+	case "zone_record":
+		return map[string]interface{}{
+			"zone_record": &dnsimple.ZoneRecord{
+				ID: 14, ZoneID: domainName, Name: "www", Content: "192.0.2.1", TTL: 3600, Type: "A",
+				Regions: []string{"global"}, CreatedAt: created, UpdatedAt: updated,
+			},
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// syntheticRequestID returns a random request identifier, formatted as a UUID.
+func syntheticRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
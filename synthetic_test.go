@@ -0,0 +1,47 @@
+package strillone
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_syntheticEvent(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range EventNames {
+		payload, err := syntheticEvent(&SyntheticEventOptions{Name: name, Domain: "example.org"}, now)
+		if err != nil {
+			t.Fatalf("syntheticEvent(%s) returned error: %v", name, err)
+		}
+		event, err := webhook.ParseEvent(payload)
+		if err != nil {
+			t.Fatalf("ParseEvent(%s) returned error: %v", name, err)
+		}
+		if err := validatePayload(event); err != nil {
+			t.Errorf("validatePayload(%s) returned error: %v", name, err)
+		}
+		text, err := formatMessage(NewTestMessagingService("test"), event)
+		if err != nil {
+			t.Errorf("formatMessage(%s) returned error: %v", name, err)
+		}
+		if !strings.Contains(text, syntheticActor) {
+			t.Errorf("formatMessage(%s) expected the synthetic actor, got %q", name, text)
+		}
+		if want, got := int64(1010), event.Account.ID; want != got {
+			t.Errorf("syntheticEvent(%s) expected account %v, got %v", name, want, got)
+		}
+	}
+
+	payload, _ := syntheticEvent(&SyntheticEventOptions{Name: "domain.delete", Domain: "example.org"}, now)
+	event, _ := webhook.ParseEvent(payload)
+	want := "[<Test account|https://dnsimple.com/a/1010/account>] strillone test-event deleted the domain <example.org|https://dnsimple.com/a/1010/domains/example.org>"
+	if got := Message(NewTestMessagingService("test"), event); want != got {
+		t.Errorf("Message expected %q, got %q", want, got)
+	}
+
+	if _, err := syntheticEvent(&SyntheticEventOptions{Name: "domain.explode"}, now); err == nil {
+		t.Errorf("syntheticEvent(domain.explode) expected error")
+	}
+}
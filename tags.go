@@ -0,0 +1,101 @@
+package strillone
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// DomainTagger returns the tags of the domains, such as production or staging, that the routes
+// with tags match. The DNSimple API doesn't expose labels on the domains, so the tags come from
+// an inventory of the domains, such as a CMDB.
+type DomainTagger interface {
+	DomainTags(ctx context.Context, accountID int64, domain string) ([]string, error)
+}
+
+// SetDomainTagger enables the routes with tags, matching the events about the domains with
+// the tags returned by the tagger. The tags are cached for the ttl, 1h when 0.
+func (s *Server) SetDomainTagger(tagger DomainTagger, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = defaultDetailsCacheTTL
+	}
+	s.tagger = tagger
+	s.tagsCache = newMemoryCache(maxCachedDetails)
+	s.tagsTTL = ttl
+}
+
+// matchesTags returns true if the route has no tags, or if one of its tags is one of the tags.
+func (r *RouteConfig) matchesTags(tags []string) bool {
+	if len(r.Tags) == 0 {
+		return true
+	}
+	for _, tag := range r.Tags {
+		for _, other := range tags {
+			if strings.EqualFold(tag, other) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasTaggedRoutes returns true if one of the routes has tags.
+func (t *routingTable) hasTaggedRoutes() bool {
+	for i := range t.routes {
+		if len(t.routes[i].Tags) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTags returns the tags of the domain of the event, from the cache or from the tagger.
+// It returns nil if the routes have no tags, or if the event has no domain. The errors are logged,
+// and the event only routed by the routes without tags.
+func (s *Server) eventTags(ctx context.Context, routing *routingTable, event *webhook.Event) []string {
+	if !routing.hasTaggedRoutes() {
+		return nil
+	}
+	return s.domainTags(ctx, event)
+}
+
+// domainTags returns the tags of the domain of the event, from the cache or from the tagger,
+// nil if the event has no domain.
+func (s *Server) domainTags(ctx context.Context, event *webhook.Event) []string {
+	if s.tagger == nil || event.Account == nil {
+		return nil
+	}
+	domain := strings.ToLower(eventDomain(event))
+	if domain == "" {
+		return nil
+	}
+
+	key := "strillone:tags:" + strconv.FormatInt(event.Account.ID, 10) + "/" + domain
+	if data, ok := s.tagsCache.get(key); ok {
+		var tags []string
+		if err := json.Unmarshal(data, &tags); err == nil {
+			return tags
+		}
+	}
+	tags, err := s.tagger.DomainTags(ctx, event.Account.ID, domain)
+	if err != nil {
+		withEvent(log.Warn(), event).Err(err).Str("domain", domain).Msg("Error fetching the domain tags")
+		return nil
+	}
+	if data, err := json.Marshal(tags); err == nil {
+		s.tagsCache.set(key, data, s.tagsTTL)
+	}
+	return tags
+}
+
+// excludeTags returns the exclusion of the routes with tags not matching the tags of the event.
+func excludeTags(tags []string, exclude func(route *RouteConfig) bool) func(route *RouteConfig) bool {
+	return func(route *RouteConfig) bool {
+		return !route.matchesTags(tags) || (exclude != nil && exclude(route))
+	}
+}
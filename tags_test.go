@@ -0,0 +1,69 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticTagger struct {
+	tags  map[string][]string
+	calls int
+}
+
+func (t *staticTagger) DomainTags(_ context.Context, accountID int64, domain string) ([]string, error) {
+	t.calls++
+	if accountID != 1010 {
+		return nil, fmt.Errorf("unknown account %d", accountID)
+	}
+	return t.tags[domain], nil
+}
+
+func TestEvents_Tags(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "prod", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [
+			{"destinations": ["ops"]},
+			{"destinations": ["prod"], "tags": ["production"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	tagger := &staticTagger{tags: map[string][]string{"example.com": {"Production"}, "example.org": {"staging"}}}
+	server.SetDomainTagger(tagger, time.Hour)
+	ops, prod := &failingService{}, &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["prod"] = prod
+
+	payload := `{"name": "domain.create", "request_identifier": "e5f6a7b8-tags-0000-00000000000%d", "data": {"domain": {"id": 1, "name": "%s"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	for i, domain := range []string{"example.com", "example.org", "example.com"} {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, i, domain)))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+		if want, got := http.StatusOK, response.Code; want != got {
+			t.Errorf("POST /events %v expected HTTP %v, got %v", domain, want, got)
+		}
+	}
+
+	if want, got := 3, ops.sent; want != got {
+		t.Errorf("ops expected %v events, got %v", want, got)
+	}
+	if want, got := 2, prod.sent; want != got {
+		t.Errorf("prod expected %v events, got %v", want, got)
+	}
+	if want, got := 2, tagger.calls; want != got {
+		t.Errorf("expected the tags of %v domains fetched, got %v", want, got)
+	}
+	if want, got := "ops", strings.Join(server.routing.Lookup("domain.create"), ","); want != got {
+		t.Errorf("Lookup expected the destinations of the routes without tags %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,188 @@
+package strillone
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// templateExtension is the extension of the message templates, named after the event (e.g. domain.create.tmpl).
+const templateExtension = ".tmpl"
+
+// MessageTemplates are the message templates overriding the default messages, by event name.
+type MessageTemplates struct {
+	dir       string
+	templates map[string]*template.Template
+}
+
+// TemplateData is the data available to the message templates.
+type TemplateData struct {
+	// Event is the event, with its name, request identifier, actor and account.
+	Event *webhook.Event
+
+	// Actor describes who performed the event, such as jane@example.com.
+	Actor string
+
+	// Account links the account of the event.
+	Account string
+
+	// Domain is the name of the domain, or zone, the event is about, empty if none.
+	Domain string
+
+	// URL is the page of the DNSimple dashboard about the resource of the event.
+	URL string
+
+	// Data is the typed data of the event, such as *webhook.DomainEventData or *ZoneRecordEvent.
+	Data interface{}
+
+	// Times are the timestamps of the event, such as {{ ago .Times.UpdatedAt }}.
+	Times EventTimes
+
+	// Severity is the severity of the event.
+	Severity Severity
+
+	// Message is the default message of the event.
+	Message string
+}
+
+// templateFuncs are the helper functions of the message templates. The link and url functions
+// are replaced when the message is formatted, by the ones of the messaging service.
+var templateFuncs = template.FuncMap{
+	"link": func(name, url string) string { return name },
+	"code": func(value interface{}) string { return fmt.Sprintf("`%v`", value) },
+	"ago":  func(value interface{}) (string, error) { return ago(value, time.Now()) },
+	"url":  fmtURL,
+	"join": strings.Join,
+}
+
+// LoadMessageTemplates parses the message templates in the directory, one file per event name
+// (e.g. domain.create.tmpl). It returns an error if a template is invalid,
+// or if it is not named after an event.
+func LoadMessageTemplates(dir string) (*MessageTemplates, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &MessageTemplates{dir: dir, templates: make(map[string]*template.Template, len(files))}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != templateExtension {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name(), templateExtension)
+		if !knownEvents[name] {
+			return nil, fmt.Errorf("%s: unknown event %q", file.Name(), name)
+		}
+		text, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(file.Name()).Funcs(templateFuncs).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return nil, err
+		}
+		t.templates[name] = tmpl
+	}
+	return t, nil
+}
+
+// Message formats the event with its template, and the default message when the event
+// has no template or the template fails.
+func (t *MessageTemplates) Message(s Formatter, e *webhook.Event, severity Severity) string {
+	text, err := t.render(s, e, severity)
+	if err != nil {
+		withEvent(log.Warn(), e).Err(err).Str("templates", t.dir).Msg("Error formatting event template")
+		return Message(s, e)
+	}
+	return text
+}
+
+// render formats the event with its template, and the default message when the event has no template.
+// It returns the error of the template.
+func (t *MessageTemplates) render(s Formatter, e *webhook.Event, severity Severity) (string, error) {
+	message := Message(s, e)
+	if t == nil || t.templates[e.Name] == nil {
+		return message, nil
+	}
+
+	tmpl, err := t.templates[e.Name].Clone()
+	if err != nil {
+		return "", err
+	}
+	base := dashboardURL(s)
+	tmpl = tmpl.Funcs(template.FuncMap{
+		"link": s.FormatLink,
+		"url":  func(path string, a ...interface{}) string { return fmtDashboardURL(base, path, a...) },
+	})
+	var buffer bytes.Buffer
+	err = tmpl.Execute(&buffer, &TemplateData{
+		Event:    e,
+		Actor:    formatActor(s, e.Actor),
+		Account:  s.FormatLink(eventAccountDisplay(s, e), fmtDashboardURL(base, "/a/%d/account", e.Account.ID)),
+		URL:      eventURL(base, e),
+		Domain:   eventDomain(e),
+		Data:     eventData(e),
+		Times:    eventTimes(e),
+		Severity: severity,
+		Message:  message,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// ago describes the time since the time, or the RFC 3339 timestamp, such as "3 hours ago".
+func ago(value interface{}, now time.Time) (string, error) {
+	var at time.Time
+	switch v := value.(type) {
+	case time.Time:
+		at = v
+	case string:
+		parsed, ok := parseTimestamp(v)
+		if !ok {
+			return "", fmt.Errorf("ago: invalid timestamp %q", v)
+		}
+		at = parsed
+	default:
+		return "", fmt.Errorf("ago: unsupported value %T", value)
+	}
+
+	if at.IsZero() {
+		return "", nil
+	}
+
+	elapsed, suffix := now.Sub(at), " ago"
+	if elapsed < 0 {
+		elapsed, suffix = -elapsed, ""
+	}
+	var text string
+	switch {
+	case elapsed < time.Minute:
+		return "just now", nil
+	case elapsed < time.Hour:
+		text = plural(int(elapsed/time.Minute), "minute")
+	case elapsed < 24*time.Hour:
+		text = plural(int(elapsed/time.Hour), "hour")
+	default:
+		text = plural(int(elapsed/(24*time.Hour)), "day")
+	}
+	if suffix == "" {
+		return "in " + text, nil
+	}
+	return text + suffix, nil
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
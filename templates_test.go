@@ -0,0 +1,110 @@
+package strillone
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "strillone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadMessageTemplates(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		valid bool
+	}{
+		{map[string]string{"domain.create.tmpl": "{{.Actor}} created {{.Domain}}", "README.md": "ignored"}, true},
+		{map[string]string{"domain.creat.tmpl": "{{.Actor}}"}, false},
+		{map[string]string{"domain.create.tmpl": "{{.Actor"}, false},
+		{map[string]string{"domain.create.tmpl": "{{unknown .Actor}}"}, false},
+	}
+	for _, test := range tests {
+		dir := writeTemplates(t, test.files)
+		_, err := LoadMessageTemplates(dir)
+		os.RemoveAll(dir)
+		if test.valid && err != nil {
+			t.Errorf("LoadMessageTemplates(%v) returned error: %v", test.files, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("LoadMessageTemplates(%v) expected error", test.files)
+		}
+	}
+
+	if _, err := newRoutingTable(&Config{Templates: "/nonexistent"}, nil); err == nil {
+		t.Errorf("newRoutingTable with a missing templates directory expected error")
+	}
+}
+
+func TestMessageTemplates_Message(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"domain.create.tmpl":      "{{.Account}} {{.Actor}} registered {{link .Data.Domain.Name (url \"/a/%d/domains/%d\" .Event.Account.ID .Data.Domain.ID)}} ({{.Severity}})\n",
+		"zone_record.create.tmpl": "New record {{code .Data.ZoneRecord.Content}} in {{.Domain}}",
+		"domain.delete.tmpl":      "{{.Data.Missing}}",
+	})
+	defer os.RemoveAll(dir)
+
+	templates, err := LoadMessageTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadMessageTemplates returned error: %v", err)
+	}
+	service := NewTestMessagingService("dummyMessagingService")
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"domain.create", `{"domain": {"id": 1, "name": "example.com"}}`, "<Account|https://dnsimple.com/a/1010/account> john.doe@email.com registered <example.com|https://dnsimple.com/a/1010/domains/1> (info)"},
+		{"zone_record.create", `{"zone_record": {"id": 1, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4"}}`, "New record `1.2.3.4` in example.com"},
+		{"domain.delete", `{"domain": {"id": 1, "name": "example.com"}}`, "[<Account|https://dnsimple.com/a/1010/account>] john.doe@email.com deleted the domain <example.com|https://dnsimple.com/a/1010/domains/example.com>"},
+		{"contact.create", `{"contact": {"id": 1, "first_name": "John", "last_name": "Doe"}}`, "[<Account|https://dnsimple.com/a/1010/account>] john.doe@email.com created the contact <John Doe|https://dnsimple.com/a/1010/contacts/1>"},
+	}
+	for _, test := range tests {
+		payload := `{"name": "` + test.name + `", "actor": {"pretty": "john.doe@email.com"}, "account": {"id": 1010, "display": "Account"}, "data": ` + test.data + `}`
+		event, err := webhook.ParseEvent([]byte(payload))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, payload)
+		}
+		if got := templates.Message(service, event, SeverityInfo); test.want != got {
+			t.Errorf("%s: expected '%v', got '%v'", test.name, test.want, got)
+		}
+	}
+}
+
+func Test_ago(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{now.Add(-30 * time.Second), "just now"},
+		{now.Add(-time.Minute), "1 minute ago"},
+		{"2021-03-01T09:00:00Z", "3 hours ago"},
+		{"2021-03-11T12:00:00Z", "in 10 days"},
+		{"2021-03-01T09:59:59.5+01:00", "3 hours ago"},
+		{time.Time{}, ""},
+	}
+	for _, test := range tests {
+		if got, err := ago(test.value, now); err != nil || test.want != got {
+			t.Errorf("ago(%v) expected %v, got %v (%v)", test.value, test.want, got, err)
+		}
+	}
+	if _, err := ago(42, now); err == nil {
+		t.Errorf("ago(42) expected error")
+	}
+}
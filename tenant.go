@@ -0,0 +1,146 @@
+package strillone
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultTenant is the name used in the statistics for the top-level configuration.
+const defaultTenant = "default"
+
+// TenantEvents handles a request to publish a webhook to the destinations of a tenant.
+func (s *Server) TenantEvents(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	// Don't log the request URI, as it contains the tenant token.
+	log.Info().Str("method", r.Method).Str("path", "/t/:token/events").Msg("Request")
+
+	tenant, ok := s.currentRouting().tenants[params.ByName("token")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if ok, retryAfter := s.tenantLimiter.Allow(tenant.name, tenant.rateLimit); !ok {
+		log.Warn().Str("tenant", tenant.name).Msg("Rejecting request: rate limit exceeded")
+		writeTooManyRequests(w, retryAfter)
+		return
+	}
+
+	s.handleWebhook(w, r, tenant)
+}
+
+// AdminListTenants returns the configured tenants with their delivery statistics.
+// The tenant tokens are not included.
+func (s *Server) AdminListTenants(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	type tenant struct {
+		Name         string      `json:"name"`
+		Destinations int         `json:"destinations"`
+		Routes       int         `json:"routes"`
+		Stats        TenantStats `json:"stats"`
+	}
+
+	config := s.currentRouting().config
+	tenants := make([]tenant, 0, len(config.Tenants))
+	for _, t := range config.Tenants {
+		tenants = append(tenants, tenant{
+			Name:         t.Name,
+			Destinations: len(t.Destinations),
+			Routes:       len(t.Routes),
+			Stats:        s.stats.Tenant(t.Name).Snapshot(),
+		})
+	}
+	writeJSON(w, http.StatusOK, tenants)
+}
+
+// AdminGetStats returns the delivery statistics of every tenant.
+func (s *Server) AdminGetStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	writeJSON(w, http.StatusOK, s.stats.Snapshot())
+}
+
+// TenantStats represents the delivery statistics of a tenant.
+type TenantStats struct {
+	Received  int64 `json:"received"`
+	Skipped   int64 `json:"skipped"`
+	Delivered int64 `json:"delivered"`
+	Failed    int64 `json:"failed"`
+
+	// UnknownEvents is the number of events missing from EventNames, introduced by DNSimple
+	// after this version of Strillone.
+	UnknownEvents int64 `json:"unknown_event_total"`
+}
+
+// tenantStats holds the counters of a tenant.
+type tenantStats struct {
+	receivedCount  int64
+	skippedCount   int64
+	deliveredCount int64
+	failedCount    int64
+	unknownCount   int64
+}
+
+func (t *tenantStats) received()  { atomic.AddInt64(&t.receivedCount, 1) }
+func (t *tenantStats) skipped()   { atomic.AddInt64(&t.skippedCount, 1) }
+func (t *tenantStats) delivered() { atomic.AddInt64(&t.deliveredCount, 1) }
+func (t *tenantStats) failed()    { atomic.AddInt64(&t.failedCount, 1) }
+
+func (t *tenantStats) unknownEvent() { atomic.AddInt64(&t.unknownCount, 1) }
+
+// Snapshot returns the current value of the counters.
+func (t *tenantStats) Snapshot() TenantStats {
+	return TenantStats{
+		Received:  atomic.LoadInt64(&t.receivedCount),
+		Skipped:   atomic.LoadInt64(&t.skippedCount),
+		Delivered: atomic.LoadInt64(&t.deliveredCount),
+		Failed:    atomic.LoadInt64(&t.failedCount),
+
+		UnknownEvents: atomic.LoadInt64(&t.unknownCount),
+	}
+}
+
+// deliveryStats holds the delivery statistics, by tenant.
+// Statistics survive configuration reloads.
+type deliveryStats struct {
+	mu      sync.Mutex
+	tenants map[string]*tenantStats
+}
+
+func newDeliveryStats() *deliveryStats {
+	return &deliveryStats{tenants: map[string]*tenantStats{}}
+}
+
+// Tenant returns the statistics of the tenant, creating them if needed.
+func (d *deliveryStats) Tenant(name string) *tenantStats {
+	if name == "" {
+		name = defaultTenant
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats, ok := d.tenants[name]
+	if !ok {
+		stats = &tenantStats{}
+		d.tenants[name] = stats
+	}
+	return stats
+}
+
+// Snapshot returns the current statistics of every tenant, by tenant name.
+func (d *deliveryStats) Snapshot() map[string]TenantStats {
+	d.mu.Lock()
+	names := make([]string, 0, len(d.tenants))
+	for name := range d.tenants {
+		names = append(names, name)
+	}
+	d.mu.Unlock()
+
+	sort.Strings(names)
+	snapshot := make(map[string]TenantStats, len(names))
+	for _, name := range names {
+		snapshot[name] = d.Tenant(name).Snapshot()
+	}
+	return snapshot
+}
@@ -0,0 +1,76 @@
+package strillone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTenantEvents(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"tenants": [
+			{
+				"name": "team-a",
+				"token": "aaaaaaaaaaaaaaaaaaaa",
+				"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+				"routes": [{"events": ["domain.*"], "destinations": ["ops"]}]
+			},
+			{
+				"name": "team-b",
+				"token": "bbbbbbbbbbbbbbbbbbbb",
+				"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/B"}],
+				"routes": [{"events": ["contact.*"], "destinations": ["ops"]}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	for _, tenant := range server.currentRouting().tenants {
+		tenant.services["ops"] = &SlackService{Token: "-"}
+	}
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "2c1d8b0e-tenant-0000-0000-000000000001"}`
+
+	for _, token := range []string{"aaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbb"} {
+		request, _ := http.NewRequest("POST", "/t/"+token+"/events", strings.NewReader(payload))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want := http.StatusOK; want != response.Code {
+			t.Errorf("POST /t/:token/events expected HTTP %v, got %v", want, response.Code)
+		}
+	}
+
+	request, _ := http.NewRequest("POST", "/t/unknown/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want := http.StatusNotFound; want != response.Code {
+		t.Errorf("POST /t/unknown/events expected HTTP %v, got %v", want, response.Code)
+	}
+
+	stats := server.stats.Snapshot()
+	if want, got := (TenantStats{Received: 1, Delivered: 1}), stats["team-a"]; want != got {
+		t.Errorf("team-a stats expected %+v, got %+v", want, got)
+	}
+	if want, got := (TenantStats{Received: 1, Skipped: 1}), stats["team-b"]; want != got {
+		t.Errorf("team-b stats expected %+v, got %+v", want, got)
+	}
+}
+
+func TestParseConfig_InvalidTenant(t *testing.T) {
+	tests := map[string]string{
+		"short token":     `{"tenants": [{"name": "a", "token": "short"}]}`,
+		"duplicate token": `{"tenants": [{"name": "a", "token": "aaaaaaaaaaaaaaaa"}, {"name": "b", "token": "aaaaaaaaaaaaaaaa"}]}`,
+		"reserved name":   `{"tenants": [{"name": "default", "token": "aaaaaaaaaaaaaaaa"}]}`,
+		"isolation":       `{"destinations": [{"name": "ops", "type": "slack", "url": "https://example.com"}], "tenants": [{"name": "a", "token": "aaaaaaaaaaaaaaaa", "routes": [{"destinations": ["ops"]}]}]}`,
+	}
+
+	for name, data := range tests {
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("ParseConfig(%v) expected error", name)
+		}
+	}
+}
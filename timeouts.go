@@ -0,0 +1,60 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultEnrichmentTimeout is the timeout of the calls to the DNSimple API completing an event.
+	defaultEnrichmentTimeout = 10 * time.Second
+
+	// defaultDeliveryStageTimeout is the timeout of the delivery of an event to a destination, including the retries.
+	defaultDeliveryStageTimeout = 2 * time.Minute
+)
+
+// TimeoutsConfig configures the timeouts of the stages of the processing of the events,
+// so that a hung API call or destination doesn't hold the event, and its goroutine, forever.
+type TimeoutsConfig struct {
+	// Enrichment is the timeout of the calls to the DNSimple API completing an event,
+	// after which the event is delivered without the details. Defaults to 10s.
+	Enrichment Duration `json:"enrichment,omitempty"`
+
+	// Delivery is the timeout of the delivery to a destination, including the retries. Defaults to 2m.
+	Delivery Duration `json:"delivery,omitempty"`
+}
+
+func (c *TimeoutsConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Enrichment < 0 || c.Delivery < 0 {
+		return fmt.Errorf("timeouts: values must be positive")
+	}
+	return nil
+}
+
+func (c *TimeoutsConfig) enrichment() time.Duration {
+	if c == nil || c.Enrichment == 0 {
+		return defaultEnrichmentTimeout
+	}
+	return time.Duration(c.Enrichment)
+}
+
+func (c *TimeoutsConfig) delivery() time.Duration {
+	if c == nil || c.Delivery == 0 {
+		return defaultDeliveryStageTimeout
+	}
+	return time.Duration(c.Delivery)
+}
+
+// enrichmentContext returns the context of the enrichment of an event.
+func (t *routingTable) enrichmentContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, t.timeouts.enrichment())
+}
+
+// deliveryContext returns the context of a delivery to a destination.
+func (t *routingTable) deliveryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, t.timeouts.delivery())
+}
@@ -0,0 +1,74 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_DeliveryTimeout(t *testing.T) {
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer target.Close()
+	defer close(release)
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": %q, "retry": {"max_attempts": 1}}],
+		"routes": [{"events": ["domain.*"], "destinations": ["ops"]}],
+		"timeouts": {"delivery": "50ms"}
+	}`, target.URL)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "7c2d9e4a-timeout-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	start := time.Now()
+	server.ServeHTTP(response, request)
+
+	if want, got := http.StatusInternalServerError, response.Code; want != got {
+		t.Errorf("POST /events to a hung destination expected HTTP %v, got %v", want, got)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("POST /events to a hung destination expected to time out, took %v", elapsed)
+	}
+}
+
+func TestRetryingService_Canceled(t *testing.T) {
+	var requests int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	service := withRetries(&SlackService{URL: target.URL}, &RetryConfig{MaxAttempts: 3, InitialBackoff: Duration(time.Hour)})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := service.PostMessage(ctx, "hello"); err == nil || !strings.Contains(err.Error(), "retry canceled") {
+		t.Errorf("PostMessage expected the retries to be canceled, got %v", err)
+	}
+	if want, got := 1, requests; want != got {
+		t.Errorf("PostMessage expected %v request, got %v", want, got)
+	}
+}
+
+func TestTimeoutsConfig_validate(t *testing.T) {
+	if err := (&TimeoutsConfig{Enrichment: Duration(time.Second), Delivery: Duration(time.Minute)}).validate(); err != nil {
+		t.Errorf("validate returned error: %v", err)
+	}
+	if err := (&TimeoutsConfig{Delivery: -1}).validate(); err == nil {
+		t.Errorf("validate expected error for a negative timeout")
+	}
+}
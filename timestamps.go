@@ -0,0 +1,81 @@
+package strillone
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// timestampLayouts are the formats of the timestamps of the API: ISO8601 with or without
+// fractional seconds, and the dates without time, such as the expiration of the certificates.
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05Z0700", certificateDateFormat}
+
+// parseTimestamp parses a timestamp of the API in UTC, false if empty or invalid.
+func parseTimestamp(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseHeaderTimestamp parses the webhook timestamp header, in seconds since the epoch
+// or as an ISO8601 timestamp, false if invalid.
+func parseHeaderTimestamp(value string) (time.Time, bool) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), true
+	}
+	return parseTimestamp(value)
+}
+
+// EventTimes are the timestamps of an event. The zero time means unknown.
+type EventTimes struct {
+	// CreatedAt is when the resource of the event was created.
+	CreatedAt time.Time
+
+	// UpdatedAt is when the resource of the event was last updated.
+	UpdatedAt time.Time
+
+	// Timestamp is when DNSimple sent the webhook, from the timestamp header.
+	Timestamp time.Time
+}
+
+// eventTimes returns the timestamps of the event, parsed from its resource and from the webhook header carried by its details.
+func eventTimes(e *webhook.Event) EventTimes {
+	times := EventTimes{}
+	if timestamp := detailsOf(e).Timestamp; timestamp != nil {
+		times.Timestamp = *timestamp
+	}
+
+	var container struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(e.GetPayload(), &container); err != nil {
+		return times
+	}
+
+	// The resource is named after the event, such as the domain of the domain.* events,
+	// and the data of the other events have a single resource.
+	resource := eventFamily(e.Name)
+	raw, ok := container.Data[resource]
+	if !ok && len(container.Data) == 1 {
+		for _, value := range container.Data {
+			raw = value
+		}
+	}
+	var fields struct {
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+	}
+	if json.Unmarshal(raw, &fields) == nil {
+		times.CreatedAt, _ = parseTimestamp(fields.CreatedAt)
+		times.UpdatedAt, _ = parseTimestamp(fields.UpdatedAt)
+	}
+	return times
+}
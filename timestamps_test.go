@@ -0,0 +1,64 @@
+package strillone
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_parseTimestamp(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"2021-03-01T11:00:00Z", time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC), true},
+		{"2021-03-01T11:00:00.250Z", time.Date(2021, 3, 1, 11, 0, 0, 250000000, time.UTC), true},
+		{"2021-03-01T12:00:00+01:00", time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC), true},
+		{"2021-03-01T12:00:00+0100", time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC), true},
+		{"2021-03-01", time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+	}
+	for _, test := range tests {
+		got, ok := parseTimestamp(test.value)
+		if ok != test.ok || !got.Equal(test.want) {
+			t.Errorf("parseTimestamp(%q) expected %v %v, got %v %v", test.value, test.want, test.ok, got, ok)
+		}
+		if ok && got.Location() != time.UTC {
+			t.Errorf("parseTimestamp(%q) expected UTC, got %v", test.value, got.Location())
+		}
+	}
+
+	if got, ok := parseHeaderTimestamp("1614596400"); !ok || !got.Equal(time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseHeaderTimestamp expected 2021-03-01 11:00, got %v %v", got, ok)
+	}
+}
+
+func Test_eventTimes(t *testing.T) {
+	payload := `{"request_identifier": "times-0000-0000-000000000001", "name": "push.initiate", "data": {"push": {"id": 1, "created_at": "2021-03-01T10:00:00Z", "updated_at": "2021-03-01T11:00:00Z"}, "domain": {"id": 2, "created_at": "2020-01-01T00:00:00Z"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	event, err := webhook.ParseEvent([]byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Date(2021, 3, 1, 11, 0, 5, 0, time.UTC)
+	event = withDetails(event, &eventDetails{Timestamp: &timestamp})
+
+	want := EventTimes{
+		CreatedAt: time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC),
+		Timestamp: timestamp,
+	}
+	if got := eventTimes(event); got != want {
+		t.Errorf("eventTimes expected %+v, got %+v", want, got)
+	}
+
+	// The events with a single resource use it, whatever its name.
+	payload = `{"request_identifier": "times-0000-0000-000000000002", "name": "dnssec.create", "data": {"delegation_signer_record": {"id": 1, "created_at": "2021-03-01T10:00:00Z"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}}`
+	event, _ = webhook.ParseEvent([]byte(payload))
+	got := eventTimes(event)
+	if !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.IsZero() || !got.Timestamp.IsZero() {
+		t.Errorf("eventTimes expected the creation only, got %+v", got)
+	}
+}
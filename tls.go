@@ -0,0 +1,92 @@
+package strillone
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSOptions represents the TLS configuration of the HTTP listener.
+//
+// Either a static certificate (CertFile and KeyFile) or automatic certificates
+// from Let's Encrypt (AutocertDomains) can be used.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+
+	// AutocertDomains is the list of host names Let's Encrypt certificates are requested for.
+	AutocertDomains []string
+	// AutocertCacheDir is the directory where the certificates are stored between restarts.
+	AutocertCacheDir string
+	// AutocertEmail is the contact email for the Let's Encrypt account, optional.
+	AutocertEmail string
+
+	// ClientCAFile is a PEM bundle of the certificate authorities used to verify client certificates.
+	// When set, clients must present a valid certificate (mutual TLS).
+	ClientCAFile string
+}
+
+// Enabled returns true if TLS is configured.
+func (o *TLSOptions) Enabled() bool {
+	return o.CertFile != "" || len(o.AutocertDomains) > 0
+}
+
+// TLSConfig returns the TLS configuration for the listener.
+//
+// With automatic certificates, it also returns the handler that must be served
+// over plain HTTP on port 80 to answer the ACME HTTP-01 challenges.
+// The handler redirects every other request to HTTPS.
+func (o *TLSOptions) TLSConfig() (*tls.Config, http.Handler, error) {
+	config, handler, err := o.serverTLSConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if o.ClientCAFile != "" {
+		pool, err := loadCertPool(o.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tls: %v", err)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, handler, nil
+}
+
+func (o *TLSOptions) serverTLSConfig() (*tls.Config, http.Handler, error) {
+	if o.CertFile != "" && len(o.AutocertDomains) > 0 {
+		return nil, nil, errors.New("tls: a static certificate and automatic certificates can't be used together")
+	}
+
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}, nil, nil
+	}
+
+	if len(o.AutocertDomains) > 0 {
+		if o.AutocertCacheDir == "" {
+			return nil, nil, errors.New("tls: automatic certificates require a cache directory")
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(o.AutocertDomains...),
+			Cache:      autocert.DirCache(o.AutocertCacheDir),
+			Email:      o.AutocertEmail,
+		}
+		config := manager.TLSConfig()
+		config.MinVersion = tls.VersionTLS12
+		return config, manager.HTTPHandler(nil), nil
+	}
+
+	return nil, nil, errors.New("tls: not configured")
+}
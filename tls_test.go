@@ -0,0 +1,34 @@
+package strillone
+
+import (
+	"testing"
+)
+
+func TestTLSOptions_TLSConfig(t *testing.T) {
+	if (&TLSOptions{}).Enabled() {
+		t.Errorf("Enabled expected false without certificates")
+	}
+
+	options := &TLSOptions{AutocertDomains: []string{"strillone.example.com"}, AutocertCacheDir: "/tmp/strillone-certs"}
+	config, challenge, err := options.TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig returned error: %v", err)
+	}
+	if config.GetCertificate == nil {
+		t.Errorf("TLSConfig expected GetCertificate for automatic certificates")
+	}
+	if challenge == nil {
+		t.Errorf("TLSConfig expected the ACME challenge handler")
+	}
+
+	invalid := []*TLSOptions{
+		{CertFile: "cert.pem", KeyFile: "key.pem", AutocertDomains: []string{"strillone.example.com"}},
+		{AutocertDomains: []string{"strillone.example.com"}},
+		{CertFile: "missing.pem", KeyFile: "missing.pem"},
+	}
+	for _, options := range invalid {
+		if _, _, err := options.TLSConfig(); err == nil {
+			t.Errorf("TLSConfig(%+v) expected error", options)
+		}
+	}
+}
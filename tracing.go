@@ -0,0 +1,159 @@
+package strillone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the instrumentation library.
+const tracerName = "github.com/dnsimple/strillone"
+
+// TracingOptions represents the configuration of the OTLP trace exporter.
+type TracingOptions struct {
+	// Endpoint is the URL of the OTLP/HTTP collector, e.g. http://localhost:55681.
+	// The traces are sent to /v1/traces, unless the URL has a path.
+	Endpoint string
+
+	// Headers are sent with every export, e.g. to authenticate with the collector.
+	Headers map[string]string
+
+	// ServiceName is the service.name of the traces.
+	ServiceName string
+
+	// SampleRatio is the fraction of the webhooks traced, when the sender doesn't decide.
+	// Zero means every webhook.
+	SampleRatio float64
+}
+
+// SetupTracing installs a global tracer provider exporting the spans to the OTLP collector,
+// and the W3C trace context propagator, so that the traces started by the senders continue here.
+// The returned function flushes the pending spans and stops the exporter.
+func SetupTracing(options *TracingOptions) (func(context.Context) error, error) {
+	endpoint, err := url.Parse(options.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("tracing: invalid endpoint %q", options.Endpoint)
+	}
+	if options.SampleRatio < 0 || options.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing: sample ratio must be between 0 and 1")
+	}
+
+	driverOptions := []otlphttp.Option{otlphttp.WithEndpoint(endpoint.Host)}
+	if endpoint.Scheme == "http" {
+		driverOptions = append(driverOptions, otlphttp.WithInsecure())
+	}
+	if path := strings.TrimSuffix(endpoint.Path, "/"); path != "" {
+		driverOptions = append(driverOptions, otlphttp.WithTracesURLPath(path))
+	}
+	if len(options.Headers) > 0 {
+		driverOptions = append(driverOptions, otlphttp.WithHeaders(options.Headers))
+	}
+
+	exporter, err := otlp.NewExporter(context.Background(), otlphttp.NewDriver(driverOptions...))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %v", err)
+	}
+
+	serviceName := options.ServiceName
+	if serviceName == "" {
+		serviceName = Program
+	}
+	sampler := sdktrace.AlwaysSample()
+	if options.SampleRatio > 0 {
+		sampler = sdktrace.TraceIDRatioBased(options.SampleRatio)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// tracer returns the tracer of the global tracer provider, a no-op one until the tracing is set up.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// startReceiveSpan starts the span of a webhook request, continuing the trace of the sender.
+func startReceiveSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer().Start(ctx, "webhook.receive",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPMethodKey.String(r.Method)),
+	)
+	return r.WithContext(ctx), span
+}
+
+// eventAttributes returns the span attributes identifying the event.
+func eventAttributes(event *webhook.Event) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.String("event.name", event.Name),
+		attribute.String("event.request_id", event.RequestID),
+	}
+	if event.Account != nil {
+		attributes = append(attributes, attribute.Int64("event.account_id", event.Account.ID))
+	}
+	return attributes
+}
+
+// startDeliverSpan starts the span of the delivery of the event to a destination.
+func startDeliverSpan(ctx context.Context, tenant, destination string, event *webhook.Event) (context.Context, trace.Span) {
+	attributes := append(eventAttributes(event),
+		attribute.String("tenant", tenant),
+		attribute.String("destination", destination),
+	)
+	return tracer().Start(ctx, "webhook.deliver", trace.WithAttributes(attributes...))
+}
+
+// recordError marks the span as failed.
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// detachedContext returns a context carrying the span of ctx, but not its cancellation,
+// for the deliveries that outlive the webhook request.
+func detachedContext(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}
+
+// statusRecorder is a http.ResponseWriter recording the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
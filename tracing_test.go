@@ -0,0 +1,115 @@
+package strillone
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*sdktrace.SpanSnapshot
+}
+
+func (e *recordingExporter) ExportSpans(ctx context.Context, spans []*sdktrace.SpanSnapshot) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *recordingExporter) byName() map[string]*sdktrace.SpanSnapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := map[string]*sdktrace.SpanSnapshot{}
+	for _, span := range e.spans {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func setupTestTracing() (*recordingExporter, func()) {
+	exporter := &recordingExporter{}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return exporter, func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	}
+}
+
+func TestServer_Tracing(t *testing.T) {
+	exporter, cleanup := setupTestTracing()
+	defer cleanup()
+
+	config, err := ParseConfig([]byte(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	server.routing.services["ops"] = &SlackService{Token: "-"}
+
+	payload := `{"data": {"domain": {"id": 1, "name": "example.com"}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "domain.create", "request_identifier": "7e2b9c4d-tracing-0000-000000000001"}`
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(payload))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusOK, response.Code; want != got {
+		t.Fatalf("POST /events expected HTTP %v, got %v", want, got)
+	}
+
+	spans := exporter.byName()
+	receive, parse, deliver := spans["webhook.receive"], spans["webhook.parse"], spans["webhook.deliver"]
+	if receive == nil || parse == nil || deliver == nil {
+		t.Fatalf("expected receive, parse and deliver spans, got %v", spans)
+	}
+	if parse.Parent.SpanID() != receive.SpanContext.SpanID() {
+		t.Errorf("parse span expected to be a child of the receive span")
+	}
+	if deliver.Parent.SpanID() != receive.SpanContext.SpanID() {
+		t.Errorf("deliver span expected to be a child of the receive span")
+	}
+
+	attributes := map[string]string{}
+	for _, attribute := range deliver.Attributes {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	for key, want := range map[string]string{"event.name": "domain.create", "event.account_id": "1010", "destination": "ops", "delivery.outcome": "delivered"} {
+		if got := attributes[key]; want != got {
+			t.Errorf("deliver span attribute %v expected %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestServer_TracingParseError(t *testing.T) {
+	exporter, cleanup := setupTestTracing()
+	defer cleanup()
+
+	server := NewServer(nil)
+	request, _ := http.NewRequest("POST", "/events", strings.NewReader(`{`))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	if want, got := http.StatusBadRequest, response.Code; want != got {
+		t.Fatalf("POST /events expected HTTP %v, got %v", want, got)
+	}
+
+	parse := exporter.byName()["webhook.parse"]
+	if parse == nil {
+		t.Fatalf("expected a parse span")
+	}
+	if want, got := codes.Error, parse.StatusCode; want != got {
+		t.Errorf("parse span expected status %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,71 @@
+package strillone
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// knownEvents are the names of the events Strillone formats.
+var knownEvents = func() map[string]bool {
+	known := make(map[string]bool, len(EventNames))
+	for _, name := range EventNames {
+		known[name] = true
+	}
+	return known
+}()
+
+// unknownEventMessage formats the events DNSimple introduced after this version of Strillone,
+// with the keys of their data, such as "[User] jane@example.com performed service.create on domain, service".
+func unknownEventMessage(s Formatter, e *webhook.Event, prefix string) string {
+	data, ok := e.GetData().(*webhook.GenericEventData)
+	if !ok || len(*data) == 0 {
+		return tprintf(s, "%s performed %s", prefix, e.Name)
+	}
+	keys := make([]string, 0, len(*data))
+	for key := range *data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return tprintf(s, "%s performed %s on %s", prefix, e.Name, escape(s, strings.Join(keys, ", ")))
+}
+
+// unknownEvents remembers the unknown events already reported to the operators, by tenant and event name.
+type unknownEvents struct {
+	mu       sync.Mutex
+	reported map[string]bool
+}
+
+func newUnknownEvents() *unknownEvents {
+	return &unknownEvents{reported: map[string]bool{}}
+}
+
+// report returns true the first time the event is received by the tenant.
+func (u *unknownEvents) report(tenant, name string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := tenant + "/" + name
+	if u.reported[key] {
+		return false
+	}
+	u.reported[key] = true
+	return true
+}
+
+// observeUnknownEvent counts the events missing from EventNames, and notifies the operators
+// the first time each one is received, so that the maintainers know to add its support.
+func (s *Server) observeUnknownEvent(routing *routingTable, stats *tenantStats, e *webhook.Event) {
+	if knownEvents[e.Name] {
+		return
+	}
+	stats.unknownEvent()
+	if !s.unknownEvents.report(routing.name, e.Name) {
+		return
+	}
+	withEvent(log.Warn(), e).Str("tenant", routing.name).Msg("Unknown event")
+	s.notifyOperator(routing, fmt.Sprintf("Received the unknown event %s (request %s). It is formatted as a generic message until Strillone supports it.", e.Name, e.RequestID))
+}
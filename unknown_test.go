@@ -0,0 +1,65 @@
+package strillone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func TestMessage_UnknownEvent(t *testing.T) {
+	event, err := webhook.ParseEvent([]byte(`{"name": "service.create", "data": {"service": {"id": 1}, "domain": {"id": 2}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "request_identifier": "e3a9c1d7-unknown-0000-000000000001"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[<User|https://dnsimple.com/a/1010/account>] example@example.com performed service.create on domain, service"
+	if got := Message(NewTestMessagingService("test"), event); want != got {
+		t.Errorf("Message expected %q, got %q", want, got)
+	}
+}
+
+func TestEvents_UnknownEvent(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"destinations": [
+			{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"},
+			{"name": "operators", "type": "slack", "url": "https://hooks.slack.com/services/B"}
+		],
+		"routes": [{"events": ["service.*"], "destinations": ["ops"]}],
+		"operator_destination": "operators"
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+	ops := &failingService{}
+	operators := &failingService{}
+	server.routing.services["ops"] = ops
+	server.routing.services["operators"] = operators
+
+	payload := `{"data": {"service": {"id": 1}}, "actor": {"pretty": "example@example.com"}, "account": {"id": 1010, "display": "User"}, "name": "service.create", "request_identifier": "%s"}`
+	for i := 1; i <= 2; i++ {
+		request, _ := http.NewRequest("POST", "/events", strings.NewReader(fmt.Sprintf(payload, fmt.Sprintf("e3a9c1d7-unknown-0000-00000000001%d", i))))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		if want := http.StatusOK; want != response.Code {
+			t.Errorf("POST /events #%d expected HTTP %v, got %v", i, want, response.Code)
+		}
+	}
+
+	if want, got := 2, ops.sent; want != got {
+		t.Errorf("POST /events expected %v deliveries, got %v", want, got)
+	}
+	if want, got := 1, len(operators.messages); want != got {
+		t.Fatalf("operator destination expected %v notice, got %v", want, got)
+	}
+	if !strings.Contains(operators.messages[0], "unknown event service.create") {
+		t.Errorf("operator notice unexpected: %v", operators.messages[0])
+	}
+	if want, got := int64(2), server.stats.Tenant("").Snapshot().UnknownEvents; want != got {
+		t.Errorf("unknown_event_total expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,43 @@
+package strillone
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// ValidateMessages formats the example payload of every event for every destination, with the templates,
+// the translations and the formatting of the configuration, and returns the errors, such as
+// "tenant team-a: destination ops: domain.create: template: domain.create.tmpl:1:12: ...".
+func (s *Server) ValidateMessages() []error {
+	routing := s.currentRouting()
+	errs := routing.validateMessages("")
+	for _, tenant := range routing.tenants {
+		errs = append(errs, tenant.validateMessages(fmt.Sprintf("tenant %s: ", tenant.name))...)
+	}
+	for name, environment := range routing.environments {
+		errs = append(errs, environment.validateMessages(fmt.Sprintf("environment %s: ", name))...)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// validateMessages formats the example payloads for the destinations of the routing table,
+// and returns the errors prefixed with the prefix.
+func (t *routingTable) validateMessages(prefix string) []error {
+	var errs []error
+	for name, service := range t.services {
+		for _, event := range EventNames {
+			e, err := webhook.ParseEvent(examplePayload(event))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%sdestination %s: %s: %v", prefix, name, event, err))
+				continue
+			}
+			if _, err := formatMessage(service, e); err != nil {
+				errs = append(errs, fmt.Errorf("%sdestination %s: %s: %v", prefix, name, event, err))
+			}
+		}
+	}
+	return errs
+}
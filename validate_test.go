@@ -0,0 +1,58 @@
+package strillone
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_examplePayload(t *testing.T) {
+	for _, name := range EventNames {
+		event, err := webhook.ParseEvent(examplePayload(name))
+		if err != nil {
+			t.Fatalf("ParseEvent(%s) returned error: %v", name, err)
+		}
+		if err := validatePayload(event); err != nil {
+			t.Errorf("validatePayload(%s) returned error: %v", name, err)
+		}
+		if _, err := formatMessage(NewTestMessagingService("test"), event); err != nil {
+			t.Errorf("formatMessage(%s) returned error: %v", name, err)
+		}
+	}
+	if payload := examplePayload("domain.explode"); payload != nil {
+		t.Errorf("examplePayload(domain.explode) expected nil, got %s", payload)
+	}
+}
+
+func TestServer_ValidateMessages(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"domain.create.tmpl":      "{{.Actor}} created {{.Data.Domain.Name}} {{ago .Times.UpdatedAt}}",
+		"zone_record.create.tmpl": "{{.Actor}} created {{.Data.ZoneRecord.Nme}}",
+	})
+	defer os.RemoveAll(dir)
+
+	config, err := ParseConfig([]byte(fmt.Sprintf(`{
+		"destinations": [{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/A"}],
+		"routes": [{"destinations": ["ops"]}],
+		"tenants": [{"name": "team-a", "token": "team-a-0123456789", "destinations": [{"name": "team", "type": "slack", "url": "https://hooks.slack.com/services/B"}], "routes": [{"destinations": ["team"]}]}],
+		"templates": %q
+	}`, dir)))
+	if err != nil {
+		t.Fatalf("ParseConfig returned error: %v", err)
+	}
+	server := NewServer(config)
+
+	errs := server.ValidateMessages()
+	if want, got := 2, len(errs); want != got {
+		t.Fatalf("ValidateMessages expected %v errors, got %v: %v", want, got, errs)
+	}
+	if want, got := "destination ops: zone_record.create: template: zone_record.create.tmpl:1:26:", errs[0].Error(); !strings.HasPrefix(got, want) {
+		t.Errorf("ValidateMessages expected %q, got %q", want, got)
+	}
+	if want, got := "tenant team-a: destination team: zone_record.create:", errs[1].Error(); !strings.HasPrefix(got, want) {
+		t.Errorf("ValidateMessages expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,158 @@
+package strillone
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+// maxZoneRecordStates is the number of records, and of updates, whose versions are remembered.
+const maxZoneRecordStates = 10000
+
+// ZoneRecordEvent represents the data of the zone_record.* events.
+type ZoneRecordEvent struct {
+	ZoneRecord *dnsimple.ZoneRecord `json:"zone_record"`
+
+	// Previous is the record before an update, when the previous version of the record was received,
+	// from the details of the event. The DNSimple payloads only include the updated record.
+	Previous *dnsimple.ZoneRecord `json:"-"`
+}
+
+// zoneRecordStates remembers the last version received of the records of a server, and the version
+// each update replaced, so that the updates are formatted as a diff. The states are bounded:
+// the oldest are forgotten first.
+type zoneRecordStates struct {
+	mu sync.Mutex
+
+	// records are the last versions of the records, by tenant, account and record ID.
+	records      map[string]*dnsimple.ZoneRecord
+	recordsOrder []string
+
+	// previous are the versions replaced by the updates, by tenant and request identifier.
+	previous      map[string]*dnsimple.ZoneRecord
+	previousOrder []string
+}
+
+func newZoneRecordStates() *zoneRecordStates {
+	return &zoneRecordStates{
+		records:  map[string]*dnsimple.ZoneRecord{},
+		previous: map[string]*dnsimple.ZoneRecord{},
+	}
+}
+
+// observe remembers the record of a zone_record.* event of the tenant, and returns the version
+// replaced by an update, nil if unknown.
+func (z *zoneRecordStates) observe(tenant string, e *webhook.Event) *dnsimple.ZoneRecord {
+	return z.lookup(tenant, e, true)
+}
+
+// previousVersion returns the version of the record replaced by an update of the tenant, nil if unknown,
+// without remembering the record, for the dry runs.
+func (z *zoneRecordStates) previousVersion(tenant string, e *webhook.Event) *dnsimple.ZoneRecord {
+	return z.lookup(tenant, e, false)
+}
+
+func (z *zoneRecordStates) lookup(tenant string, e *webhook.Event, remember bool) *dnsimple.ZoneRecord {
+	if !strings.HasPrefix(e.Name, "zone_record.") || e.Account == nil {
+		return nil
+	}
+	data := parseZoneRecordEvent(e)
+	if data == nil || data.ZoneRecord == nil {
+		return nil
+	}
+	key := fmt.Sprintf("%s/%d/%d", tenant, e.Account.ID, data.ZoneRecord.ID)
+	update := tenant + "/" + e.RequestID
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	// The webhooks retried by DNSimple are observed again.
+	if previous, seen := z.previous[update]; seen {
+		return previous
+	}
+	previous, ok := z.records[key]
+	if ok && previous.UpdatedAt != "" && previous.UpdatedAt == data.ZoneRecord.UpdatedAt {
+		return nil
+	}
+	if !ok || e.Name != "zone_record.update" {
+		previous = nil
+	}
+	if !remember {
+		return previous
+	}
+
+	if previous != nil {
+		z.previous[update] = previous
+		z.previousOrder = evictOldest(z.previous, append(z.previousOrder, update))
+	}
+	if !ok {
+		z.recordsOrder = append(z.recordsOrder, key)
+	}
+	z.records[key] = data.ZoneRecord
+	z.recordsOrder = evictOldest(z.records, z.recordsOrder)
+	return previous
+}
+
+// evictOldest removes the oldest keys beyond the limit, and returns the remaining keys.
+func evictOldest(states map[string]*dnsimple.ZoneRecord, order []string) []string {
+	for len(order) > maxZoneRecordStates {
+		delete(states, order[0])
+		order = order[1:]
+	}
+	return order
+}
+
+// parseZoneRecordEvent returns the data of a zone_record.* event, nil if the payload is invalid.
+func parseZoneRecordEvent(e *webhook.Event) *ZoneRecordEvent {
+	data := &ZoneRecordEvent{}
+	container := struct {
+		Data *ZoneRecordEvent `json:"data"`
+	}{Data: data}
+	if err := json.Unmarshal(e.GetPayload(), &container); err != nil {
+		return nil
+	}
+	return data
+}
+
+// zoneRecordLabel returns the name and the type of the record, such as www A, with @ for the apex.
+func zoneRecordLabel(record *dnsimple.ZoneRecord) string {
+	name := record.Name
+	if name == "" {
+		name = "@"
+	}
+	return name + " " + record.Type
+}
+
+// zoneRecordDiff describes the changes between two versions of a record,
+// such as www A 1.2.3.4 → 5.6.7.8, TTL 3600 → 300.
+func zoneRecordDiff(previous, current *dnsimple.ZoneRecord) string {
+	label, separator := zoneRecordLabel(current), " "
+	if previous.Name != current.Name {
+		label, separator = zoneRecordLabel(previous)+" → "+zoneRecordLabel(current), ", "
+	}
+
+	var changes []string
+	if previous.Content != current.Content {
+		changes = append(changes, fmt.Sprintf("%s → %s", previous.Content, current.Content))
+	}
+	if previous.TTL != current.TTL {
+		changes = append(changes, fmt.Sprintf("TTL %d → %d", previous.TTL, current.TTL))
+	}
+	if previous.Priority != current.Priority {
+		changes = append(changes, fmt.Sprintf("priority %d → %d", previous.Priority, current.Priority))
+	}
+	if strings.Join(previous.Regions, ",") != strings.Join(current.Regions, ",") {
+		changes = append(changes, fmt.Sprintf("regions %s → %s", strings.Join(previous.Regions, ","), strings.Join(current.Regions, ",")))
+	}
+	if len(changes) == 0 {
+		if previous.Name != current.Name {
+			return label
+		}
+		return fmt.Sprintf("%s %s (unchanged)", label, current.Content)
+	}
+	return label + separator + strings.Join(changes, ", ")
+}
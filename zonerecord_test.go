@@ -0,0 +1,56 @@
+package strillone
+
+import (
+	"testing"
+
+	"github.com/dnsimple/dnsimple-go/dnsimple"
+	"github.com/dnsimple/dnsimple-go/dnsimple/webhook"
+)
+
+func Test_zoneRecordDiff(t *testing.T) {
+	www := dnsimple.ZoneRecord{Name: "www", Type: "A", Content: "1.2.3.4", TTL: 3600}
+	tests := []struct {
+		change func(record *dnsimple.ZoneRecord)
+		want   string
+	}{
+		{func(r *dnsimple.ZoneRecord) { r.Content, r.TTL = "5.6.7.8", 300 }, "www A 1.2.3.4 → 5.6.7.8, TTL 3600 → 300"},
+		{func(r *dnsimple.ZoneRecord) { r.Name = "" }, "www A → @ A"},
+		{func(r *dnsimple.ZoneRecord) { r.Name, r.Priority = "api", 10 }, "www A → api A, priority 0 → 10"},
+		{func(r *dnsimple.ZoneRecord) {}, "www A 1.2.3.4 (unchanged)"},
+	}
+	for _, test := range tests {
+		current := www
+		test.change(&current)
+		if got := zoneRecordDiff(&www, &current); test.want != got {
+			t.Errorf("zoneRecordDiff expected %v, got %v", test.want, got)
+		}
+	}
+}
+
+func Test_Message_ZoneRecordUpdateDiff(t *testing.T) {
+	service := NewTestMessagingService("dummyMessagingService")
+	parse := func(name, requestID, record string) *webhook.Event {
+		payload := `{"name": "` + name + `", "request_identifier": "` + requestID + `", "actor": {"pretty": "john.doe@email.com"}, "account": {"id": 4242, "display": "Account"}, "data": {"zone_record": ` + record + `}}`
+		event, err := webhook.ParseEvent([]byte(payload))
+		if err != nil {
+			t.Fatalf("Error parsing: %v.\n%v", err, payload)
+		}
+		return event
+	}
+
+	update := parse("zone_record.update", "diff-0", `{"id": 7, "zone_id": "example.com", "type": "A", "name": "www", "content": "5.6.7.8", "ttl": 300, "updated_at": "2021-03-01T12:00:00Z"}`)
+	if want, got := "[<Account|https://dnsimple.com/a/4242/account>] john.doe@email.com updated the record <A www.example.com 5.6.7.8|https://dnsimple.com/a/4242/domains/example.com/records/7>", Message(service, update); want != got {
+		t.Errorf("Expected '%v', got '%v'", want, got)
+	}
+
+	records := newZoneRecordStates()
+	records.observe("", parse("zone_record.create", "diff-1", `{"id": 7, "zone_id": "example.com", "type": "A", "name": "www", "content": "1.2.3.4", "ttl": 3600, "updated_at": "2021-03-01T11:00:00Z"}`))
+	update = parse("zone_record.update", "diff-2", `{"id": 7, "zone_id": "example.com", "type": "A", "name": "www", "content": "5.6.7.8", "ttl": 300, "updated_at": "2021-03-01T12:00:00Z"}`)
+	records.observe("", update)
+	// A retried webhook doesn't change the previous version.
+	update = withDetails(update, &eventDetails{PreviousZoneRecord: records.observe("", update)})
+
+	if want, got := "[<Account|https://dnsimple.com/a/4242/account>] john.doe@email.com updated the record <www A 1.2.3.4 → 5.6.7.8, TTL 3600 → 300|https://dnsimple.com/a/4242/domains/example.com/records/7> in example.com", Message(service, update); want != got {
+		t.Errorf("Expected '%v', got '%v'", want, got)
+	}
+}